@@ -44,7 +44,7 @@ import (
 
 const (
 	// heartbeatTimeout is the maximum time to wait between heartbeats
-	// without disconnecting
+	// without disconnecting, unless configured otherwise
 	heartbeatTimeout = 5 * time.Minute
 	heartbeatJitter  = 3 * time.Minute
 
@@ -74,12 +74,37 @@ type StartSessionArguments struct {
 	StateManager                  statemanager.StateManager
 	AcceptInvalidCert             bool
 	CredentialsManager            rolecredentials.Manager
+	RetryPolicy                   RetryPolicy
+	ConnectionStatus              *ConnectionStatus
 	_time                         ttime.Time
 	_heartbeatTimeout             time.Duration
 	_heartbeatJitter              time.Duration
 	_timeOnce                     sync.Once
 }
 
+// ConnectionStatus records whether the agent is connected to ACS. It is
+// updated by sessions started with it set in their StartSessionArguments
+type ConnectionStatus struct {
+	connected bool
+	lock      sync.RWMutex
+}
+
+// Connected returns true if the agent is connected to ACS
+func (status *ConnectionStatus) Connected() bool {
+	status.lock.RLock()
+	defer status.lock.RUnlock()
+	return status.connected
+}
+
+func (status *ConnectionStatus) setConnected(connected bool) {
+	if status == nil {
+		return
+	}
+	status.lock.Lock()
+	defer status.lock.Unlock()
+	status.connected = connected
+}
+
 // sessionState defines state recorder interface for the
 // session established with ACS. It can be used to record and
 // retrieve data shared across multiple connections to ACS
@@ -90,6 +115,9 @@ type sessionState interface {
 	// getSendCredentialsURLParameter retrieves the value for
 	// the 'sendCredentials' URL parameter
 	getSendCredentialsURLParameter() string
+	// getAppliedTaskPayloads retrieves the record of the task payloads
+	// applied from ACS
+	getAppliedTaskPayloads() *appliedTaskPayloads
 }
 
 func (a *StartSessionArguments) time() ttime.Time {
@@ -112,11 +140,18 @@ func (a *StartSessionArguments) initTime() {
 		if a._time == nil {
 			a._time = &ttime.DefaultTime{}
 		}
+		configured := a.Config != nil && a.Config.ACSHeartbeatTimeout > 0
 		if a._heartbeatTimeout == 0 {
 			a._heartbeatTimeout = heartbeatTimeout
+			if configured {
+				a._heartbeatTimeout = a.Config.ACSHeartbeatTimeout
+			}
 		}
 		if a._heartbeatJitter == 0 {
 			a._heartbeatJitter = heartbeatJitter
+			if configured {
+				a._heartbeatJitter = time.Duration(float64(a._heartbeatTimeout) * a.Config.ACSHeartbeatGraceFactor)
+			}
 		}
 	})
 }
@@ -144,13 +179,21 @@ type acsSessionResources struct {
 	// It is set to 'true' for the very first successful connection on
 	// agent start. It is set to false for all successive connections
 	sendCredentials bool
+	// appliedTasks records the task payloads applied from ACS, so that
+	// payloads redelivered on a new connection aren't applied again
+	appliedTasks *appliedTaskPayloads
 }
 
 // StartSession creates a session with ACS and handles requests from ACS.
 // It creates resources required to invoke the package scoped 'startSession()'
-// method and invokes the same to repeatedly connect to ACS when disconnected
+// method and invokes the same to repeatedly connect to ACS when disconnected.
+// Reconnects are delayed by args.RetryPolicy, or by exponential backoff with the
+// jitter bounds from the config if it's not set
 func StartSession(ctx context.Context, args StartSessionArguments) error {
-	backoff := utils.NewSimpleBackoff(connectionBackoffMin, connectionBackoffMax, connectionBackoffJitter, connectionBackoffMultiplier)
+	backoff := args.RetryPolicy
+	if backoff == nil {
+		backoff = newDefaultRetryPolicy(args.Config)
+	}
 	session := newSessionResources(args)
 	return startSession(ctx, args, backoff, session)
 }
@@ -159,12 +202,13 @@ func newSessionResources(args StartSessionArguments) sessionResources {
 	return &acsSessionResources{
 		startSessionArguments: args,
 		sendCredentials:       true,
+		appliedTasks:          newAppliedTaskPayloads(),
 	}
 }
 
 // startSession creates a session with ACS and handles requests from ACS
 // It also tries to repeatedly connect to ACS when disconnected
-func startSession(ctx context.Context, args StartSessionArguments, backoff *utils.SimpleBackoff, acsResources sessionResources) error {
+func startSession(ctx context.Context, args StartSessionArguments, backoff RetryPolicy, acsResources sessionResources) error {
 	for {
 		acsError := startSessionOnce(ctx, args, backoff, acsResources)
 		select {
@@ -190,7 +234,7 @@ func startSession(ctx context.Context, args StartSessionArguments, backoff *util
 
 // startSessionOnce creates a session with ACS and handles requests using the passed
 // in arguments
-func startSessionOnce(ctx context.Context, args StartSessionArguments, backoff *utils.SimpleBackoff, acsResources sessionResources) error {
+func startSessionOnce(ctx context.Context, args StartSessionArguments, backoff RetryPolicy, acsResources sessionResources) error {
 	acsEndpoint, err := args.ECSClient.DiscoverPollEndpoint(args.ContainerInstanceArn)
 	if err != nil {
 		seelog.Errorf("Unable to discover poll endpoint, err: %v", err)
@@ -248,6 +292,12 @@ func (acsResources *acsSessionResources) getSendCredentialsURLParameter() string
 	return strconv.FormatBool(acsResources.sendCredentials)
 }
 
+// getAppliedTaskPayloads gets the record of the task payloads applied from
+// ACS across connections
+func (acsResources *acsSessionResources) getAppliedTaskPayloads() *appliedTaskPayloads {
+	return acsResources.appliedTasks
+}
+
 // newDisconnectionTimer creates a new time object, with a callback to
 // disconnect from ACS on inactivity
 func newDisconnectionTimer(client wsclient.ClientServer, _time ttime.Time, timeout time.Duration, jitter time.Duration) ttime.Timer {
@@ -265,9 +315,9 @@ func newDisconnectionTimer(client wsclient.ClientServer, _time ttime.Time, timeo
 // startACSSession starts a session with ACS. It adds request handlers for various
 // kinds of messages expected from ACS. It returns on server disconnection or when
 // the context is cancelled
-func startACSSession(ctx context.Context, client wsclient.ClientServer, timer ttime.Timer, args StartSessionArguments, backoff *utils.SimpleBackoff, acsSessionState sessionState) error {
+func startACSSession(ctx context.Context, client wsclient.ClientServer, timer ttime.Timer, args StartSessionArguments, backoff RetryPolicy, acsSessionState sessionState) error {
 	// Any message from the server resets the disconnect timeout
-	client.SetAnyRequestHandler(anyMessageHandler(timer))
+	client.SetAnyRequestHandler(anyMessageHandler(timer, args.heartbeatTimeout(), args.heartbeatJitter()))
 	cfg := args.Config
 
 	refreshCredsHandler := newRefreshCredentialsHandler(ctx, cfg.Cluster, args.ContainerInstanceArn, client, args.CredentialsManager, args.TaskEngine)
@@ -278,7 +328,7 @@ func startACSSession(ctx context.Context, client wsclient.ClientServer, timer tt
 	client.AddRequestHandler(refreshCredsHandler.handlerFunc())
 
 	// Add request handler for handling payload messages from ACS
-	payloadHandler := newPayloadRequestHandler(ctx, args.TaskEngine, args.ECSClient, cfg.Cluster, args.ContainerInstanceArn, client, args.StateManager, refreshCredsHandler, args.CredentialsManager)
+	payloadHandler := newPayloadRequestHandler(ctx, args.TaskEngine, args.ECSClient, cfg.Cluster, args.ContainerInstanceArn, client, args.StateManager, refreshCredsHandler, args.CredentialsManager, acsSessionState.getAppliedTaskPayloads())
 	// Clear the acks channel on return because acks of messageids don't have any value across sessions
 	defer payloadHandler.clearAcks()
 	payloadHandler.start()
@@ -297,6 +347,8 @@ func startACSSession(ctx context.Context, client wsclient.ClientServer, timer tt
 		return err
 	}
 	acsSessionState.connectedToACS()
+	args.ConnectionStatus.setConnected(true)
+	defer args.ConnectionStatus.setConnected(false)
 
 	backoffResetTimer := args.time().AfterFunc(utils.AddJitter(args.heartbeatTimeout(), args.heartbeatJitter()), func() {
 		// If we do not have an error connecting and remain connected for at
@@ -329,9 +381,9 @@ func startACSSession(ctx context.Context, client wsclient.ClientServer, timer tt
 
 // anyMessageHandler handles any server message. Any server message means the
 // connection is active and thus the heartbeat disconnect should not occur
-func anyMessageHandler(timer ttime.Timer) func(interface{}) {
+func anyMessageHandler(timer ttime.Timer, timeout, jitter time.Duration) func(interface{}) {
 	return func(interface{}) {
 		seelog.Debug("ACS activity occured")
-		timer.Reset(utils.AddJitter(heartbeatTimeout, heartbeatJitter))
+		timer.Reset(utils.AddJitter(timeout, jitter))
 	}
 }
@@ -111,7 +111,8 @@ const (
 )
 
 type mockSession struct {
-	client wsclient.ClientServer
+	client       wsclient.ClientServer
+	appliedTasks *appliedTaskPayloads
 }
 
 func (m *mockSession) createACSClient(url string) wsclient.ClientServer {
@@ -124,6 +125,13 @@ func (m *mockSession) connectedToACS() {
 func (m *mockSession) getSendCredentialsURLParameter() string {
 	return "true"
 }
+
+func (m *mockSession) getAppliedTaskPayloads() *appliedTaskPayloads {
+	if m.appliedTasks == nil {
+		m.appliedTasks = newAppliedTaskPayloads()
+	}
+	return m.appliedTasks
+}
 func TestAcsWsUrl(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -193,7 +201,7 @@ func TestHandlerReconnectsOnConnectErrors(t *testing.T) {
 			cancel()
 		}).Return(io.EOF),
 	)
-	session := &mockSession{mockWsClient}
+	session := &mockSession{client: mockWsClient}
 
 	args := StartSessionArguments{
 		ContainerInstanceArn: "myArn",
@@ -246,7 +254,7 @@ func TestHandlerReconnectsOnServeErrors(t *testing.T) {
 			cancel()
 		}).Return(io.EOF),
 	)
-	session := &mockSession{mockWsClient}
+	session := &mockSession{client: mockWsClient}
 
 	args := StartSessionArguments{
 		ContainerInstanceArn: "myArn",
@@ -293,7 +301,7 @@ func TestHandlerReconnectsOnDiscoverPollEndpointError(t *testing.T) {
 		cancel()
 	}).Return(io.EOF)
 
-	session := &mockSession{mockWsClient}
+	session := &mockSession{client: mockWsClient}
 
 	gomock.InOrder(
 		// DiscoverPollEndpoint returns an error on its first invocation
@@ -388,6 +396,129 @@ func TestConnectionIsClosedOnIdle(t *testing.T) {
 	<-connectionClosed
 }
 
+// TestHandlerReconnectsOnStalledConnection tests if the handler closes a
+// connection that receives no messages within the configured heartbeat
+// timeout and establishes a new one
+func TestHandlerReconnectsOnStalledConnection(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := engine.NewMockTaskEngine(ctrl)
+	taskEngine.EXPECT().Version().Return("Docker: 1.5.0", nil).AnyTimes()
+
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	ecsClient.EXPECT().DiscoverPollEndpoint(gomock.Any()).Return(acsURL, nil).AnyTimes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	closed := make(chan struct{}, 1)
+	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
+	mockWsClient.EXPECT().SetAnyRequestHandler(gomock.Any()).AnyTimes()
+	mockWsClient.EXPECT().AddRequestHandler(gomock.Any()).AnyTimes()
+	mockWsClient.EXPECT().Connect().Return(nil).Times(2)
+	mockWsClient.EXPECT().Close().Do(func() {
+		select {
+		case closed <- struct{}{}:
+		default:
+		}
+	}).Return(nil).AnyTimes()
+	gomock.InOrder(
+		// The first connection stalls: nothing is received until the
+		// heartbeat timer closes it
+		mockWsClient.EXPECT().Serve().Do(func() {
+			select {
+			case <-closed:
+			case <-time.After(time.Second):
+				t.Error("Expected the stalled connection to be closed")
+			}
+		}).Return(io.EOF),
+		// Cancel the context once the handler has reconnected
+		mockWsClient.EXPECT().Serve().Do(func() {
+			cancel()
+		}).Return(io.EOF),
+	)
+
+	backoff := utils.NewSimpleBackoff(time.Millisecond, time.Millisecond, 0, 1)
+	err := startSession(ctx, StartSessionArguments{
+		ContainerInstanceArn: "myArn",
+		CredentialProvider:   credentials.AnonymousCredentials,
+		Config: &config.Config{
+			Cluster:                 "someCluster",
+			ACSHeartbeatTimeout:     20 * time.Millisecond,
+			ACSHeartbeatGraceFactor: 0.5,
+		},
+		TaskEngine:        taskEngine,
+		ECSClient:         ecsClient,
+		StateManager:      statemanager.NewNoopStateManager(),
+		AcceptInvalidCert: true,
+	}, backoff, &mockSession{client: mockWsClient})
+	if err != context.Canceled {
+		t.Errorf("Expected the session to end when the context is canceled, got: %v", err)
+	}
+}
+
+func TestHeartbeatFromConfig(t *testing.T) {
+	args := StartSessionArguments{
+		Config: &config.Config{
+			ACSHeartbeatTimeout:     time.Minute,
+			ACSHeartbeatGraceFactor: 0.5,
+		},
+	}
+	if args.heartbeatTimeout() != time.Minute {
+		t.Errorf("Expected the configured heartbeat timeout, got: %v", args.heartbeatTimeout())
+	}
+	if args.heartbeatJitter() != 30*time.Second {
+		t.Errorf("Expected the heartbeat jitter to be the configured fraction of the timeout, got: %v", args.heartbeatJitter())
+	}
+
+	args = StartSessionArguments{Config: &config.Config{}}
+	if args.heartbeatTimeout() != heartbeatTimeout || args.heartbeatJitter() != heartbeatJitter {
+		t.Errorf("Expected the default heartbeat, got: %v, %v", args.heartbeatTimeout(), args.heartbeatJitter())
+	}
+}
+
+func TestConnectionStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := engine.NewMockTaskEngine(ctrl)
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+
+	status := &ConnectionStatus{}
+	args := StartSessionArguments{
+		ContainerInstanceArn: "myArn",
+		CredentialProvider:   credentials.AnonymousCredentials,
+		Config:               &config.Config{Cluster: "someCluster"},
+		TaskEngine:           taskEngine,
+		ECSClient:            ecsClient,
+		StateManager:         statemanager.NewNoopStateManager(),
+		ConnectionStatus:     status,
+		_heartbeatTimeout:    time.Minute,
+		_heartbeatJitter:     time.Millisecond,
+	}
+
+	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
+	mockWsClient.EXPECT().SetAnyRequestHandler(gomock.Any()).AnyTimes()
+	mockWsClient.EXPECT().AddRequestHandler(gomock.Any()).AnyTimes()
+	mockWsClient.EXPECT().Connect().Return(nil)
+	mockWsClient.EXPECT().Serve().Do(func() {
+		if !status.Connected() {
+			t.Error("Expected the session to be connected while serving")
+		}
+	}).Return(io.EOF)
+
+	if status.Connected() {
+		t.Error("Expected the session not to be connected before starting")
+	}
+	backoff := utils.NewSimpleBackoff(connectionBackoffMin, connectionBackoffMax, connectionBackoffJitter, connectionBackoffMultiplier)
+	timer := newDisconnectionTimer(mockWsClient, args.time(), args.heartbeatTimeout(), args.heartbeatJitter())
+	defer timer.Stop()
+	err := startACSSession(context.Background(), mockWsClient, timer, args, backoff, &mockSession{})
+	if err != io.EOF {
+		t.Errorf("Expected the session to end with EOF, got: %v", err)
+	}
+	if status.Connected() {
+		t.Error("Expected the session not to be connected after it ends")
+	}
+}
+
 func TestHandlerDoesntLeakGouroutines(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
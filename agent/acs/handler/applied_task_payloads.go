@@ -0,0 +1,68 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+// appliedTaskPayloadExpiry is how long a task's desired status is remembered
+// once applied. ACS redelivers payloads when reconnecting, well within it
+const appliedTaskPayloadExpiry = 3 * time.Hour
+
+// appliedTaskPayloads records the desired status of the tasks added to the
+// task engine from ACS payloads, so that a payload redelivered by ACS, such as
+// after a reconnection, isn't applied twice. It is shared by the connections
+// to ACS
+type appliedTaskPayloads struct {
+	tasks map[string]appliedTaskPayload
+	lock  sync.Mutex
+}
+
+type appliedTaskPayload struct {
+	desiredStatus api.TaskStatus
+	appliedAt     time.Time
+}
+
+func newAppliedTaskPayloads() *appliedTaskPayloads {
+	return &appliedTaskPayloads{
+		tasks: make(map[string]appliedTaskPayload),
+	}
+}
+
+// applied returns true if the task was already applied with this desired
+// status
+func (payloads *appliedTaskPayloads) applied(taskArn string, desiredStatus api.TaskStatus) bool {
+	payloads.lock.Lock()
+	defer payloads.lock.Unlock()
+	payload, ok := payloads.tasks[taskArn]
+	return ok && payload.desiredStatus == desiredStatus
+}
+
+// record records that the task was applied with this desired status, and
+// forgets the tasks applied too long ago to be redelivered
+func (payloads *appliedTaskPayloads) record(taskArn string, desiredStatus api.TaskStatus) {
+	payloads.lock.Lock()
+	defer payloads.lock.Unlock()
+	now := time.Now()
+	for arn, payload := range payloads.tasks {
+		if now.Sub(payload.appliedAt) > appliedTaskPayloadExpiry {
+			delete(payloads.tasks, arn)
+		}
+	}
+	payloads.tasks[taskArn] = appliedTaskPayload{desiredStatus: desiredStatus, appliedAt: now}
+}
@@ -44,10 +44,13 @@ type payloadRequestHandler struct {
 	acsClient            wsclient.ClientServer
 	refreshHandler       refreshCredentialsHandler
 	credentialsManager   credentials.Manager
+	// appliedTasks keeps tasks redelivered by ACS from being added to the
+	// task engine again
+	appliedTasks *appliedTaskPayloads
 }
 
 // newPayloadRequestHandler returns a new payloadRequestHandler object
-func newPayloadRequestHandler(ctx context.Context, taskEngine engine.TaskEngine, ecsClient api.ECSClient, cluster string, containerInstanceArn string, acsClient wsclient.ClientServer, saver statemanager.Saver, refreshHandler refreshCredentialsHandler, credentialsManager credentials.Manager) payloadRequestHandler {
+func newPayloadRequestHandler(ctx context.Context, taskEngine engine.TaskEngine, ecsClient api.ECSClient, cluster string, containerInstanceArn string, acsClient wsclient.ClientServer, saver statemanager.Saver, refreshHandler refreshCredentialsHandler, credentialsManager credentials.Manager, appliedTasks *appliedTaskPayloads) payloadRequestHandler {
 	// Create a cancelable context from the parent context
 	derivedContext, cancel := context.WithCancel(ctx)
 	return payloadRequestHandler{
@@ -63,6 +66,7 @@ func newPayloadRequestHandler(ctx context.Context, taskEngine engine.TaskEngine,
 		acsClient:            acsClient,
 		refreshHandler:       refreshHandler,
 		credentialsManager:   credentialsManager,
+		appliedTasks:         appliedTasks,
 	}
 }
 
@@ -217,11 +221,26 @@ func (payloadHandler *payloadRequestHandler) addTasks(payload *ecsacs.PayloadMes
 	allTasksOK := true
 	var credentialsAcks []*ecsacs.IAMRoleCredentialsAckRequest
 	for _, task := range tasks {
-		if skipAddTask(task.GetDesiredStatus()) {
+		desiredStatus := task.GetDesiredStatus()
+		if skipAddTask(desiredStatus) {
 			continue
 		}
-		err := payloadHandler.taskEngine.AddTask(task)
-		if err != nil {
+		var err error
+		if payloadHandler.appliedTasks.applied(task.Arn, desiredStatus) {
+			// Still acked, along with the task's credentials
+			seelog.Infof("Ignoring task %s redelivered with desired status %s already applied", task.Arn, desiredStatus.String())
+		} else {
+			err = payloadHandler.taskEngine.AddTask(task)
+			if err == nil {
+				payloadHandler.appliedTasks.record(task.Arn, desiredStatus)
+			}
+		}
+		if _, ok := err.(engine.TaskEngineDrainingError); ok {
+			// Don't ack, so that the task isn't considered to be placed on
+			// this instance
+			seelog.Infof("Rejecting task while draining, err: %v", err)
+			allTasksOK = false
+		} else if err != nil {
 			seelog.Warnf("Could not add task; taskengine probably disabled, err: %v", err)
 			// Don't ack
 			allTasksOK = false
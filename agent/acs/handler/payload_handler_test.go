@@ -16,6 +16,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/amazon-ecs-agent/agent/api"
@@ -47,7 +48,7 @@ func TestHandlePayloadMessageWithNoMessageId(t *testing.T) {
 	credentialsManager := credentials.NewManager()
 
 	ctx := context.Background()
-	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager)
+	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager, newAppliedTaskPayloads())
 
 	// test adding a payload message without the MessageId field
 	payloadMessage := &ecsacs.PayloadMessage{
@@ -86,7 +87,7 @@ func TestHandlePayloadMessageAddTaskError(t *testing.T) {
 	taskEngine.EXPECT().AddTask(gomock.Any()).Return(fmt.Errorf("oops")).Times(2)
 
 	ctx := context.Background()
-	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager)
+	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager, newAppliedTaskPayloads())
 
 	// Test AddTask error with RUNNING task
 	payloadMessage := &ecsacs.PayloadMessage{
@@ -139,7 +140,7 @@ func TestHandlePayloadMessageStateSaveError(t *testing.T) {
 	stateManager.EXPECT().Save().Return(fmt.Errorf("oops"))
 
 	ctx := context.Background()
-	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager)
+	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager, newAppliedTaskPayloads())
 
 	// Check if handleSingleMessage returns an error when state manager returns error on Save()
 	err := buffer.handleSingleMessage(&ecsacs.PayloadMessage{
@@ -185,7 +186,7 @@ func TestHandlePayloadMessageAckedWhenTaskAdded(t *testing.T) {
 		ackRequested = ackRequest
 		cancel()
 	}).Times(1)
-	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, mockWsClient, stateManager, refreshCredentialsHandler{}, credentialsManager)
+	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, mockWsClient, stateManager, refreshCredentialsHandler{}, credentialsManager, newAppliedTaskPayloads())
 	go buffer.start()
 
 	// Send a payload message
@@ -258,7 +259,7 @@ func TestHandlePayloadMessageCredentialsAckedWhenTaskAdded(t *testing.T) {
 	defer refreshCredsHandler.clearAcks()
 	refreshCredsHandler.start()
 
-	payloadHandler := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, mockWsClient, stateManager, refreshCredsHandler, credentialsManager)
+	payloadHandler := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, mockWsClient, stateManager, refreshCredsHandler, credentialsManager, newAppliedTaskPayloads())
 	go payloadHandler.start()
 
 	taskArn := "t1"
@@ -356,7 +357,7 @@ func TestAddPayloadTaskAddsNonStoppedTasksAfterStoppedTasks(t *testing.T) {
 
 	ctx := context.Background()
 	stateManager := statemanager.NewNoopStateManager()
-	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager)
+	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager, newAppliedTaskPayloads())
 	_, ok := buffer.addPayloadTasks(payloadMessage)
 	if !ok {
 		t.Error("addPayloadTasks returned false")
@@ -406,7 +407,7 @@ func TestPayloadBufferHandler(t *testing.T) {
 		cancel()
 	}).Times(1)
 
-	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, mockWsClient, stateManager, refreshCredentialsHandler{}, credentialsManager)
+	buffer := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, mockWsClient, stateManager, refreshCredentialsHandler{}, credentialsManager, newAppliedTaskPayloads())
 	go buffer.start()
 	// Send a payload message to the payloadBufferChannel
 	taskArn := "t1"
@@ -485,7 +486,7 @@ func TestPayloadBufferHandlerWithCredentials(t *testing.T) {
 	refreshCredsHandler := newRefreshCredentialsHandler(ctx, clusterName, containerInstanceArn, mockWsClient, credentialsManager, taskEngine)
 	defer refreshCredsHandler.clearAcks()
 	refreshCredsHandler.start()
-	payloadHandler := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, mockWsClient, stateManager, refreshCredsHandler, credentialsManager)
+	payloadHandler := newPayloadRequestHandler(ctx, taskEngine, ecsClient, clusterName, containerInstanceArn, mockWsClient, stateManager, refreshCredsHandler, credentialsManager, newAppliedTaskPayloads())
 	go payloadHandler.start()
 
 	firstTaskArn := "t1"
@@ -604,3 +605,156 @@ func validateTaskAndCredentials(taskCredentialsAck, expectedCredentialsAckForTas
 	}
 	return nil
 }
+
+// waitForPayloadAck waits for the handler to request an ack of a payload message
+func waitForPayloadAck(t *testing.T, payloadHandler payloadRequestHandler, expectedMessageID string) {
+	select {
+	case messageID := <-payloadHandler.ackRequest:
+		if messageID != expectedMessageID {
+			t.Errorf("Message Id mismatch. Expected: %s, got: %s", expectedMessageID, messageID)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for message %s to be acked", expectedMessageID)
+	}
+}
+
+func runningTaskPayload(messageID string) *ecsacs.PayloadMessage {
+	return &ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{
+			&ecsacs.Task{
+				Arn:           aws.String("t1"),
+				DesiredStatus: aws.String("RUNNING"),
+			},
+		},
+		MessageId: aws.String(messageID),
+	}
+}
+
+// TestHandlePayloadMessageDuplicateTask tests that a task redelivered with the
+// desired status it was already added with isn't added to the task engine
+// again, but is still acked
+func TestHandlePayloadMessageDuplicateTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	stateManager := statemanager.NewNoopStateManager()
+	credentialsManager := credentials.NewManager()
+	taskEngine := engine.NewMockTaskEngine(ctrl)
+
+	taskEngine.EXPECT().AddTask(gomock.Any()).Times(1)
+
+	appliedTasks := newAppliedTaskPayloads()
+	payloadHandler := newPayloadRequestHandler(context.Background(), taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager, appliedTasks)
+
+	for _, messageID := range []string{"m1", "m2"} {
+		err := payloadHandler.handleSingleMessage(runningTaskPayload(messageID))
+		if err != nil {
+			t.Errorf("Error handling payload message %s: %v", messageID, err)
+		}
+		waitForPayloadAck(t, payloadHandler, messageID)
+	}
+
+	// A payload redelivered on a new connection to ACS is ignored too
+	reconnectedHandler := newPayloadRequestHandler(context.Background(), taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager, appliedTasks)
+	err := reconnectedHandler.handleSingleMessage(runningTaskPayload("m1"))
+	if err != nil {
+		t.Errorf("Error handling redelivered payload message: %v", err)
+	}
+	waitForPayloadAck(t, reconnectedHandler, "m1")
+}
+
+// TestHandlePayloadMessageDesiredStatusChange tests that a task redelivered
+// with a new desired status is added to the task engine again
+func TestHandlePayloadMessageDesiredStatusChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	stateManager := statemanager.NewNoopStateManager()
+	credentialsManager := credentials.NewManager()
+	taskEngine := engine.NewMockTaskEngine(ctrl)
+
+	var addedStatuses []api.TaskStatus
+	taskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *api.Task) {
+		addedStatuses = append(addedStatuses, task.GetDesiredStatus())
+	}).Times(2)
+
+	payloadHandler := newPayloadRequestHandler(context.Background(), taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager, newAppliedTaskPayloads())
+
+	err := payloadHandler.handleSingleMessage(runningTaskPayload("m1"))
+	if err != nil {
+		t.Errorf("Error handling payload message: %v", err)
+	}
+	waitForPayloadAck(t, payloadHandler, "m1")
+
+	err = payloadHandler.handleSingleMessage(&ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{
+			&ecsacs.Task{
+				Arn:           aws.String("t1"),
+				DesiredStatus: aws.String("STOPPED"),
+			},
+		},
+		MessageId: aws.String("m2"),
+	})
+	if err != nil {
+		t.Errorf("Error handling payload message: %v", err)
+	}
+	waitForPayloadAck(t, payloadHandler, "m2")
+
+	expectedStatuses := []api.TaskStatus{api.TaskRunning, api.TaskStopped}
+	if !reflect.DeepEqual(addedStatuses, expectedStatuses) {
+		t.Errorf("Mismatch between expected and added task statuses, expected: %v, added: %v", expectedStatuses, addedStatuses)
+	}
+}
+
+// TestHandlePayloadMessageRetriesFailedTask tests that a task the task engine
+// failed to add is added again when redelivered
+func TestHandlePayloadMessageRetriesFailedTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	stateManager := statemanager.NewNoopStateManager()
+	credentialsManager := credentials.NewManager()
+	taskEngine := engine.NewMockTaskEngine(ctrl)
+
+	gomock.InOrder(
+		taskEngine.EXPECT().AddTask(gomock.Any()).Return(fmt.Errorf("oops")),
+		taskEngine.EXPECT().AddTask(gomock.Any()).Return(nil),
+	)
+
+	payloadHandler := newPayloadRequestHandler(context.Background(), taskEngine, ecsClient, clusterName, containerInstanceArn, nil, stateManager, refreshCredentialsHandler{}, credentialsManager, newAppliedTaskPayloads())
+
+	err := payloadHandler.handleSingleMessage(runningTaskPayload(payloadMessageId))
+	if err == nil {
+		t.Error("Expected error while adding the task")
+	}
+
+	err = payloadHandler.handleSingleMessage(runningTaskPayload(payloadMessageId))
+	if err != nil {
+		t.Errorf("Error handling redelivered payload message: %v", err)
+	}
+	waitForPayloadAck(t, payloadHandler, payloadMessageId)
+}
+
+// TestAppliedTaskPayloadsExpire tests that tasks applied too long ago are
+// forgotten when another task is recorded
+func TestAppliedTaskPayloadsExpire(t *testing.T) {
+	appliedTasks := newAppliedTaskPayloads()
+	appliedTasks.record("t1", api.TaskRunning)
+	if !appliedTasks.applied("t1", api.TaskRunning) {
+		t.Error("Expected task to be applied with its recorded desired status")
+	}
+	if appliedTasks.applied("t1", api.TaskStopped) {
+		t.Error("Expected task not to be applied with another desired status")
+	}
+
+	expired := appliedTasks.tasks["t1"]
+	expired.appliedAt = time.Now().Add(-appliedTaskPayloadExpiry - time.Minute)
+	appliedTasks.tasks["t1"] = expired
+	appliedTasks.record("t2", api.TaskRunning)
+	if appliedTasks.applied("t1", api.TaskRunning) {
+		t.Error("Expected task applied too long ago to be forgotten")
+	}
+	if !appliedTasks.applied("t2", api.TaskRunning) {
+		t.Error("Expected recorded task to be applied")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+)
+
+// RetryPolicy decides how long to wait before reconnecting to ACS after the
+// session is disconnected
+type RetryPolicy interface {
+	// Duration returns the time to wait before the next connection attempt
+	Duration() time.Duration
+	// Reset is invoked once a connection has been healthy for long enough that
+	// the next reconnect should wait as little as possible again
+	Reset()
+}
+
+// exponentialRetryPolicy is a RetryPolicy with exponential backoff, where a
+// random amount of jitter between jitterMin and jitterMax times the backoff is
+// added to every duration. Spreading the jitter across a range keeps a fleet
+// of agents from reconnecting at the same time after ACS becomes available
+type exponentialRetryPolicy struct {
+	backoff   *utils.SimpleBackoff
+	jitterMin float64
+	jitterMax float64
+}
+
+// NewExponentialRetryPolicy creates a RetryPolicy which waits from min to max,
+// increasing by multiplier each time. A random amount of jitter between
+// jitterMin and jitterMax times the backoff is added to each duration (that
+// is, jitterMin = 0.1 and jitterMax = 0.3 add between 10% and 30% jitter)
+func NewExponentialRetryPolicy(min, max time.Duration, multiplier, jitterMin, jitterMax float64) RetryPolicy {
+	return &exponentialRetryPolicy{
+		backoff:   utils.NewSimpleBackoff(min, max, 0, multiplier),
+		jitterMin: jitterMin,
+		jitterMax: jitterMax,
+	}
+}
+
+func (policy *exponentialRetryPolicy) Duration() time.Duration {
+	duration := policy.backoff.Duration()
+	minJitter := time.Duration(float64(duration) * policy.jitterMin)
+	jitterRange := time.Duration(float64(duration) * (policy.jitterMax - policy.jitterMin))
+	return utils.AddJitter(duration+minJitter, jitterRange)
+}
+
+func (policy *exponentialRetryPolicy) Reset() {
+	policy.backoff.Reset()
+}
+
+// newDefaultRetryPolicy creates the RetryPolicy used when none is specified in
+// StartSessionArguments, with the jitter bounds from the config
+func newDefaultRetryPolicy(cfg *config.Config) RetryPolicy {
+	jitterMin := 0.0
+	jitterMax := connectionBackoffJitter
+	if cfg != nil && (cfg.ACSReconnectJitterMin != 0 || cfg.ACSReconnectJitterMax != 0) {
+		jitterMin = cfg.ACSReconnectJitterMin
+		jitterMax = cfg.ACSReconnectJitterMax
+	}
+	return NewExponentialRetryPolicy(connectionBackoffMin, connectionBackoffMax, connectionBackoffMultiplier, jitterMin, jitterMax)
+}
@@ -0,0 +1,78 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+func TestExponentialRetryPolicyGrows(t *testing.T) {
+	policy := NewExponentialRetryPolicy(time.Second, time.Minute, 2, 0, 0)
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute}
+	for i, duration := range expected {
+		if actual := policy.Duration(); actual != duration {
+			t.Errorf("Incorrect duration for attempt %d. Expected: %v, got: %v", i, duration, actual)
+		}
+	}
+
+	policy.Reset()
+	if actual := policy.Duration(); actual != time.Second {
+		t.Errorf("Incorrect duration after reset. Expected: %v, got: %v", time.Second, actual)
+	}
+}
+
+func TestExponentialRetryPolicyJitterWithinBounds(t *testing.T) {
+	jitterMin := 0.1
+	jitterMax := 0.5
+	policy := NewExponentialRetryPolicy(time.Second, time.Minute, 1.5, jitterMin, jitterMax)
+
+	backoff := time.Second
+	for i := 0; i < 1000; i++ {
+		if i%10 == 0 {
+			policy.Reset()
+			backoff = time.Second
+		}
+		duration := policy.Duration()
+		lower := backoff + time.Duration(float64(backoff)*jitterMin)
+		upper := backoff + time.Duration(float64(backoff)*jitterMax)
+		if duration < lower || duration > upper {
+			t.Fatalf("Duration %v out of bounds [%v, %v] for backoff %v", duration, lower, upper, backoff)
+		}
+		backoff = time.Duration(float64(backoff) * 1.5)
+		if backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+func TestDefaultRetryPolicyUsesConfiguredJitter(t *testing.T) {
+	policy := newDefaultRetryPolicy(&config.Config{
+		ACSReconnectJitterMin: 1,
+		ACSReconnectJitterMax: 1,
+	})
+	if duration := policy.Duration(); duration != 2*connectionBackoffMin {
+		t.Errorf("Expected jitter from config to be used. Expected: %v, got: %v", 2*connectionBackoffMin, duration)
+	}
+
+	policy = newDefaultRetryPolicy(&config.Config{})
+	duration := policy.Duration()
+	upper := connectionBackoffMin + time.Duration(float64(connectionBackoffMin)*connectionBackoffJitter)
+	if duration < connectionBackoffMin || duration > upper {
+		t.Errorf("Duration %v out of default bounds [%v, %v]", duration, connectionBackoffMin, upper)
+	}
+}
@@ -67,6 +67,24 @@ func (s BadRequestException) GoString() string {
 	return s.String()
 }
 
+type BlkioDeviceLimit struct {
+	_ struct{} `type:"structure"`
+
+	Path *string `locationName:"path" type:"string"`
+
+	Rate *int64 `locationName:"rate" type:"long"`
+}
+
+// String returns the string representation
+func (s BlkioDeviceLimit) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s BlkioDeviceLimit) GoString() string {
+	return s.String()
+}
+
 type CloseMessage struct {
 	_ struct{} `type:"structure"`
 
@@ -90,31 +108,75 @@ type Container struct {
 
 	Cpu *int64 `locationName:"cpu" type:"integer"`
 
+	DependsOn []*ContainerDependency `locationName:"dependsOn" type:"list"`
+
+	DnsSearchDomains []*string `locationName:"dnsSearchDomains" type:"list"`
+
+	DnsServers []*string `locationName:"dnsServers" type:"list"`
+
 	DockerConfig *DockerConfig `locationName:"dockerConfig" type:"structure"`
 
+	DockerSecurityOptions []*string `locationName:"dockerSecurityOptions" type:"list"`
+
 	EntryPoint []*string `locationName:"entryPoint" type:"list"`
 
 	Environment map[string]*string `locationName:"environment" type:"map"`
 
+	EnvironmentFiles []*EnvironmentFile `locationName:"environmentFiles" type:"list"`
+
 	Essential *bool `locationName:"essential" type:"boolean"`
 
+	ExtraHosts []*HostEntry `locationName:"extraHosts" type:"list"`
+
 	Image *string `locationName:"image" type:"string"`
 
 	Links []*string `locationName:"links" type:"list"`
 
+	LinuxParameters *LinuxParameters `locationName:"linuxParameters" type:"structure"`
+
+	LogSecretOptions []*Secret `locationName:"logSecretOptions" type:"list"`
+
 	Memory *int64 `locationName:"memory" type:"integer"`
 
+	MemoryReservation *int64 `locationName:"memoryReservation" type:"integer"`
+
+	MemorySwap *int64 `locationName:"memorySwap" type:"integer"`
+
 	MountPoints []*MountPoint `locationName:"mountPoints" type:"list"`
 
 	Name *string `locationName:"name" type:"string"`
 
+	NetworkMode *string `locationName:"networkMode" type:"string"`
+
 	Overrides *string `locationName:"overrides" type:"string"`
 
 	PortMappings []*PortMapping `locationName:"portMappings" type:"list"`
 
+	ReadonlyRootFilesystem *bool `locationName:"readonlyRootFilesystem" type:"boolean"`
+
 	RegistryAuthentication *RegistryAuthenticationData `locationName:"registryAuthentication" type:"structure"`
 
+	ResourceRequirements []*ResourceRequirement `locationName:"resourceRequirements" type:"list"`
+
+	RestartPolicy *string `locationName:"restartPolicy" type:"string"`
+
+	Secrets []*Secret `locationName:"secrets" type:"list"`
+
+	StopOnUnhealthyDependency *bool `locationName:"stopOnUnhealthyDependency" type:"boolean"`
+
+	StopSignal *string `locationName:"stopSignal" type:"string"`
+
+	StopTimeout *int64 `locationName:"stopTimeout" type:"integer"`
+
+	Tmpfs []*Tmpfs `locationName:"tmpfs" type:"list"`
+
+	Ulimits []*Ulimit `locationName:"ulimits" type:"list"`
+
+	User *string `locationName:"user" type:"string"`
+
 	VolumesFrom []*VolumeFrom `locationName:"volumesFrom" type:"list"`
+
+	WorkingDirectory *string `locationName:"workingDirectory" type:"string"`
 }
 
 // String returns the string representation
@@ -127,6 +189,44 @@ func (s Container) GoString() string {
 	return s.String()
 }
 
+type ContainerDependency struct {
+	_ struct{} `type:"structure"`
+
+	Condition *string `locationName:"condition" type:"string"`
+
+	ContainerName *string `locationName:"containerName" type:"string"`
+}
+
+// String returns the string representation
+func (s ContainerDependency) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s ContainerDependency) GoString() string {
+	return s.String()
+}
+
+type Device struct {
+	_ struct{} `type:"structure"`
+
+	ContainerPath *string `locationName:"containerPath" type:"string"`
+
+	HostPath *string `locationName:"hostPath" type:"string"`
+
+	Permissions *string `locationName:"permissions" type:"string"`
+}
+
+// String returns the string representation
+func (s Device) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s Device) GoString() string {
+	return s.String()
+}
+
 type DockerConfig struct {
 	_ struct{} `type:"structure"`
 
@@ -147,6 +247,26 @@ func (s DockerConfig) GoString() string {
 	return s.String()
 }
 
+type DockerVolumeConfiguration struct {
+	_ struct{} `type:"structure"`
+
+	Driver *string `locationName:"driver" type:"string"`
+
+	DriverOpts map[string]*string `locationName:"driverOpts" type:"map"`
+
+	Labels map[string]*string `locationName:"labels" type:"map"`
+}
+
+// String returns the string representation
+func (s DockerVolumeConfiguration) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s DockerVolumeConfiguration) GoString() string {
+	return s.String()
+}
+
 type ECRAuthData struct {
 	_ struct{} `type:"structure"`
 
@@ -167,6 +287,24 @@ func (s ECRAuthData) GoString() string {
 	return s.String()
 }
 
+type EnvironmentFile struct {
+	_ struct{} `type:"structure"`
+
+	Type *string `locationName:"type" type:"string"`
+
+	Value *string `locationName:"value" type:"string"`
+}
+
+// String returns the string representation
+func (s EnvironmentFile) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s EnvironmentFile) GoString() string {
+	return s.String()
+}
+
 type ErrorMessage struct {
 	_ struct{} `type:"structure"`
 
@@ -227,6 +365,24 @@ func (s HeartbeatOutput) GoString() string {
 	return s.String()
 }
 
+type HostEntry struct {
+	_ struct{} `type:"structure"`
+
+	Hostname *string `locationName:"hostname" type:"string"`
+
+	IpAddress *string `locationName:"ipAddress" type:"string"`
+}
+
+// String returns the string representation
+func (s HostEntry) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s HostEntry) GoString() string {
+	return s.String()
+}
+
 type HostVolumeProperties struct {
 	_ struct{} `type:"structure"`
 
@@ -357,6 +513,54 @@ func (s InvalidInstanceException) GoString() string {
 	return s.String()
 }
 
+type KernelCapabilities struct {
+	_ struct{} `type:"structure"`
+
+	Add []*string `locationName:"add" type:"list"`
+
+	Drop []*string `locationName:"drop" type:"list"`
+}
+
+// String returns the string representation
+func (s KernelCapabilities) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s KernelCapabilities) GoString() string {
+	return s.String()
+}
+
+type LinuxParameters struct {
+	_ struct{} `type:"structure"`
+
+	BlkioWeight *int64 `locationName:"blkioWeight" type:"integer"`
+
+	Capabilities *KernelCapabilities `locationName:"capabilities" type:"structure"`
+
+	DeviceReadBps []*BlkioDeviceLimit `locationName:"deviceReadBps" type:"list"`
+
+	DeviceWriteBps []*BlkioDeviceLimit `locationName:"deviceWriteBps" type:"list"`
+
+	Devices []*Device `locationName:"devices" type:"list"`
+
+	Init *bool `locationName:"init" type:"boolean"`
+
+	ShmSize *int64 `locationName:"shmSize" type:"integer"`
+
+	SystemControls []*SystemControl `locationName:"systemControls" type:"list"`
+}
+
+// String returns the string representation
+func (s LinuxParameters) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s LinuxParameters) GoString() string {
+	return s.String()
+}
+
 type MountPoint struct {
 	_ struct{} `type:"structure"`
 
@@ -509,6 +713,42 @@ func (s RegistryAuthenticationData) GoString() string {
 	return s.String()
 }
 
+type ResourceRequirement struct {
+	_ struct{} `type:"structure"`
+
+	Type *string `locationName:"type" type:"string"`
+
+	Value *string `locationName:"value" type:"string"`
+}
+
+// String returns the string representation
+func (s ResourceRequirement) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s ResourceRequirement) GoString() string {
+	return s.String()
+}
+
+type Secret struct {
+	_ struct{} `type:"structure"`
+
+	Name *string `locationName:"name" type:"string"`
+
+	ValueFrom *string `locationName:"valueFrom" type:"string"`
+}
+
+// String returns the string representation
+func (s Secret) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s Secret) GoString() string {
+	return s.String()
+}
+
 type ServerException struct {
 	_ struct{} `type:"structure"`
 
@@ -547,6 +787,24 @@ func (s StageUpdateMessage) GoString() string {
 	return s.String()
 }
 
+type SystemControl struct {
+	_ struct{} `type:"structure"`
+
+	Namespace *string `locationName:"namespace" type:"string"`
+
+	Value *string `locationName:"value" type:"string"`
+}
+
+// String returns the string representation
+func (s SystemControl) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s SystemControl) GoString() string {
+	return s.String()
+}
+
 type Task struct {
 	_ struct{} `type:"structure"`
 
@@ -554,12 +812,24 @@ type Task struct {
 
 	Containers []*Container `locationName:"containers" type:"list"`
 
+	Cpu *int64 `locationName:"cpu" type:"integer"`
+
 	DesiredStatus *string `locationName:"desiredStatus" type:"string"`
 
+	FailOnEssentialExitCode *bool `locationName:"failOnEssentialExitCode" type:"boolean"`
+
 	Family *string `locationName:"family" type:"string"`
 
+	IpcMode *string `locationName:"ipcMode" type:"string"`
+
+	Memory *int64 `locationName:"memory" type:"integer"`
+
+	NetworkMode *string `locationName:"networkMode" type:"string"`
+
 	Overrides *string `locationName:"overrides" type:"string"`
 
+	PidMode *string `locationName:"pidMode" type:"string"`
+
 	RoleCredentials *IAMRoleCredentials `locationName:"roleCredentials" type:"structure"`
 
 	TaskDefinitionAccountId *string `locationName:"taskDefinitionAccountId" type:"string"`
@@ -579,6 +849,46 @@ func (s Task) GoString() string {
 	return s.String()
 }
 
+type Tmpfs struct {
+	_ struct{} `type:"structure"`
+
+	ContainerPath *string `locationName:"containerPath" type:"string"`
+
+	MountOptions []*string `locationName:"mountOptions" type:"list"`
+
+	Size *string `locationName:"size" type:"string"`
+}
+
+// String returns the string representation
+func (s Tmpfs) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s Tmpfs) GoString() string {
+	return s.String()
+}
+
+type Ulimit struct {
+	_ struct{} `type:"structure"`
+
+	HardLimit *int64 `locationName:"hardLimit" type:"integer"`
+
+	Name *string `locationName:"name" type:"string"`
+
+	SoftLimit *int64 `locationName:"softLimit" type:"integer"`
+}
+
+// String returns the string representation
+func (s Ulimit) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s Ulimit) GoString() string {
+	return s.String()
+}
+
 type UpdateFailureOutput struct {
 	_ struct{} `type:"structure"`
 }
@@ -634,6 +944,8 @@ func (s VersionInfo) GoString() string {
 type Volume struct {
 	_ struct{} `type:"structure"`
 
+	DockerVolumeConfiguration *DockerVolumeConfiguration `locationName:"dockerVolumeConfiguration" type:"structure"`
+
 	Host *HostVolumeProperties `locationName:"host" type:"structure"`
 
 	Name *string `locationName:"name" type:"string"`
@@ -38,6 +38,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/tcs/handler"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/version"
@@ -97,8 +98,20 @@ func _main() int {
 	}
 	log.Info("Loading configuration")
 	cfg, cfgErr := config.NewConfig(ec2MetadataClient)
+	// Fail before connecting to Docker or ACS, reporting every problem with
+	// the config at once. Printing the version only needs the Docker endpoint
+	if cfgErr != nil && !*versionFlag {
+		log.Criticalf("Error loading config: %v", cfgErr)
+		// All required config values can be inferred from EC2 Metadata, so this error could be transient.
+		return exitcodes.ExitError
+	}
 	// Load cfg and create Docker client before doing 'versionFlag' so that it has the DOCKER_HOST variable loaded if needed
-	clientFactory := dockerclient.NewFactory(cfg.DockerEndpoint)
+	clientFactory := dockerclient.NewFactory(cfg.DockerEndpoint,
+		dockerclient.WithVersionChangeHandler(func(oldVersion, newVersion dockerclient.DockerVersion) {
+			log.Warnf("Docker API version changed from %s to %s", oldVersion, newVersion)
+		}),
+		dockerclient.WithMinimumVersion(cfg.DockerMinimumAPIVersion),
+		dockerclient.WithCircuitBreaker(dockerclient.DefaultCircuitBreakerThreshold, dockerclient.DefaultCircuitBreakerCoolDown))
 	dockerClient, err := engine.NewDockerGoClient(clientFactory, *acceptInsecureCert, cfg)
 	if err != nil {
 		log.Criticalf("Error creating Docker client: %v", err)
@@ -124,15 +137,13 @@ func _main() int {
 
 	sighandlers.StartDebugHandler()
 
-	if cfgErr != nil {
-		log.Criticalf("Error loading config: %v", err)
-		// All required config values can be inferred from EC2 Metadata, so this error could be transient.
-		return exitcodes.ExitError
-	}
 	log.Debug("Loaded config: " + cfg.String())
 
 	var currentEc2InstanceID, containerInstanceArn string
 	var taskEngine engine.TaskEngine
+	// The stats engine is created here so that its saved state can be loaded;
+	// it is initialized when the metrics session starts
+	statsEngine := stats.NewDockerStatsEngine(cfg, dockerClient, containerChangeEventStream)
 
 	if cfg.Checkpoint {
 		log.Info("Checkpointing is enabled. Attempting to load state")
@@ -140,7 +151,7 @@ func _main() int {
 		previousTaskEngine := engine.NewTaskEngine(cfg, dockerClient, credentialsManager, containerChangeEventStream, imageManager, state)
 		// previousState is used to verify that our current runtime configuration is
 		// compatible with our past configuration as reflected by our state-file
-		previousState, err := initializeStateManager(cfg, previousTaskEngine, &previousCluster, &previousContainerInstanceArn, &previousEc2InstanceID)
+		previousState, err := initializeStateManager(cfg, previousTaskEngine, statsEngine, &previousCluster, &previousContainerInstanceArn, &previousEc2InstanceID)
 		if err != nil {
 			log.Criticalf("Error creating state manager: %v", err)
 			return exitcodes.ExitTerminal
@@ -188,7 +199,7 @@ func _main() int {
 		taskEngine = engine.NewTaskEngine(cfg, dockerClient, credentialsManager, containerChangeEventStream, imageManager, state)
 	}
 
-	stateManager, err := initializeStateManager(cfg, taskEngine, &cfg.Cluster, &containerInstanceArn, &currentEc2InstanceID)
+	stateManager, err := initializeStateManager(cfg, taskEngine, statsEngine, &cfg.Cluster, &containerInstanceArn, &currentEc2InstanceID)
 	if err != nil {
 		log.Criticalf("Error creating state manager: %v", err)
 		return exitcodes.ExitTerminal
@@ -243,10 +254,20 @@ func _main() int {
 		go imageManager.StartImageCleanupProcess(ctx)
 	}
 
-	go sighandlers.StartTerminationHandler(stateManager, taskEngine)
+	// Metrics and state changes that haven't been sent yet are flushed to the
+	// backend before the agent exits
+	metricsFlusher := tcshandler.NewMetricsFlusher()
+	shutdownFlushers := []sighandlers.Flusher{
+		metricsFlusher.Flush,
+		func(timeout time.Duration) error {
+			return eventhandler.Flush(client, timeout)
+		},
+	}
+	go sighandlers.StartTerminationHandler(stateManager, taskEngine, cfg.DrainTimeout, shutdownFlushers)
 
 	// Agent introspection api
-	go handlers.ServeHttp(&containerInstanceArn, taskEngine, cfg)
+	acsConnectionStatus := &acshandler.ConnectionStatus{}
+	go handlers.ServeHttp(&containerInstanceArn, taskEngine, statsEngine, statsEngine, acsConnectionStatus, stateManager, cfg)
 
 	// Start serving the endpoint to fetch IAM Role credentials
 	go credentialshandler.ServeHTTP(credentialsManager, containerInstanceArn, cfg)
@@ -267,6 +288,7 @@ func _main() int {
 		AcceptInvalidCert:             *acceptInsecureCert,
 		ECSClient:                     client,
 		TaskEngine:                    taskEngine,
+		MetricsFlusher:                metricsFlusher,
 	}
 
 	// Start metrics session in a go routine
@@ -283,16 +305,19 @@ func _main() int {
 		StateManager:                  stateManager,
 		TaskEngine:                    taskEngine,
 		CredentialsManager:            credentialsManager,
+		ConnectionStatus:              acsConnectionStatus,
 	})
 	if err != nil {
 		log.Criticalf("Unretriable error starting communicating with ACS: %v", err)
+		sighandlers.FlushBeforeExit(shutdownFlushers)
 		return exitcodes.ExitTerminal
 	}
 	log.Critical("ACS Session handler should never exit")
+	sighandlers.FlushBeforeExit(shutdownFlushers)
 	return exitcodes.ExitError
 }
 
-func initializeStateManager(cfg *config.Config, taskEngine engine.TaskEngine, cluster, containerInstanceArn, savedInstanceID *string) (statemanager.StateManager, error) {
+func initializeStateManager(cfg *config.Config, taskEngine engine.TaskEngine, statsEngine *stats.DockerStatsEngine, cluster, containerInstanceArn, savedInstanceID *string) (statemanager.StateManager, error) {
 	if !cfg.Checkpoint {
 		return statemanager.NewNoopStateManager(), nil
 	}
@@ -301,6 +326,7 @@ func initializeStateManager(cfg *config.Config, taskEngine engine.TaskEngine, cl
 		statemanager.AddSaveable("ContainerInstanceArn", containerInstanceArn),
 		statemanager.AddSaveable("Cluster", cluster),
 		statemanager.AddSaveable("EC2InstanceID", savedInstanceID),
+		statemanager.AddSaveable("StatsEngine", statsEngine),
 		//The ACSSeqNum field is retained for compatibility with statemanager.EcsDataVersion 4 and
 		//can be removed in the future with a version bump.
 		statemanager.AddSaveable("ACSSeqNum", 1),
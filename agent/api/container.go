@@ -13,6 +13,13 @@
 
 package api
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 const DOCKER_MINIMUM_MEMORY = 4 * 1024 * 1024 // 4MB
 
 // Overriden returns
@@ -62,3 +69,106 @@ func (c *Container) SetDesiredStatus(status ContainerStatus) {
 
 	c.DesiredStatus = status
 }
+
+// NetworkContainer returns the name of the container of the task whose network
+// stack the container joins, or an empty string if it doesn't join one
+func (c *Container) NetworkContainer() string {
+	if !strings.HasPrefix(c.NetworkMode, NetworkModeContainerPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(c.NetworkMode, NetworkModeContainerPrefix)
+}
+
+// GPUCount returns the number of GPUs the container requires
+func (c *Container) GPUCount() (int, error) {
+	count := 0
+	for _, requirement := range c.ResourceRequirements {
+		if requirement.Type != ResourceTypeGPU {
+			continue
+		}
+		gpus, err := strconv.Atoi(requirement.Value)
+		if err != nil || gpus <= 0 {
+			return 0, fmt.Errorf("Invalid GPU requirement %q of container %s: expected a positive number", requirement.Value, c.Name)
+		}
+		count += gpus
+	}
+	return count, nil
+}
+
+// ResolvedBy returns true if the dependency has reached the condition it is
+// waiting for
+func (dependency DependsOn) ResolvedBy(container *Container) bool {
+	switch dependency.Condition {
+	case DependencyConditionStart:
+		return container.GetKnownStatus() >= ContainerRunning
+	case DependencyConditionHealthy:
+		return container.GetHealthStatus() == ContainerHealthy
+	case DependencyConditionSuccess:
+		return container.KnownTerminal() && container.KnownExitCode != nil && *container.KnownExitCode == 0
+	}
+	return false
+}
+
+// MayResolve returns true if the dependency has not reached its condition yet,
+// but is expected to without any action from the engine
+func (dependency DependsOn) MayResolve(container *Container) bool {
+	if container.GetKnownStatus() != ContainerRunning {
+		return false
+	}
+	switch dependency.Condition {
+	case DependencyConditionHealthy:
+		return container.GetHealthStatus() == ContainerHealthStarting
+	case DependencyConditionSuccess:
+		return true
+	}
+	return false
+}
+
+// GetHealthStatus returns the health of the container as reported by its
+// health check
+func (c *Container) GetHealthStatus() ContainerHealthStatus {
+	c.healthLock.RLock()
+	defer c.healthLock.RUnlock()
+
+	return c.Health
+}
+
+// SetHealthStatus sets the health of the container
+func (c *Container) SetHealthStatus(health ContainerHealthStatus) {
+	c.healthLock.Lock()
+	defer c.healthLock.Unlock()
+
+	c.Health = health
+}
+
+// GetCreatedAt returns when docker created the container
+func (c *Container) GetCreatedAt() time.Time {
+	c.timestampsLock.RLock()
+	defer c.timestampsLock.RUnlock()
+
+	return c.CreatedAt
+}
+
+// SetCreatedAt sets when docker created the container
+func (c *Container) SetCreatedAt(createdAt time.Time) {
+	c.timestampsLock.Lock()
+	defer c.timestampsLock.Unlock()
+
+	c.CreatedAt = createdAt
+}
+
+// GetStartedAt returns when docker last started the container
+func (c *Container) GetStartedAt() time.Time {
+	c.timestampsLock.RLock()
+	defer c.timestampsLock.RUnlock()
+
+	return c.StartedAt
+}
+
+// SetStartedAt sets when docker last started the container
+func (c *Container) SetStartedAt(startedAt time.Time) {
+	c.timestampsLock.Lock()
+	defer c.timestampsLock.Unlock()
+
+	c.StartedAt = startedAt
+}
@@ -51,6 +51,25 @@ func TestOverridden(t *testing.T) {
 	}
 }
 
+func TestGPUCount(t *testing.T) {
+	container := &Container{ResourceRequirements: []ResourceRequirement{
+		{Type: ResourceTypeGPU, Value: "2"},
+		{Type: "InferenceAccelerator", Value: "device"},
+		{Type: ResourceTypeGPU, Value: "1"},
+	}}
+	count, err := container.GPUCount()
+	if err != nil || count != 3 {
+		t.Errorf("Expected 3 GPUs, got: %d, %v", count, err)
+	}
+
+	for _, value := range []string{"0", "-1", "one"} {
+		container := &Container{ResourceRequirements: []ResourceRequirement{{Type: ResourceTypeGPU, Value: value}}}
+		if _, err := container.GPUCount(); err == nil {
+			t.Errorf("Expected an error for GPU requirement %q", value)
+		}
+	}
+}
+
 type configPair struct {
 	Container *Container
 	Config    *docker.Config
@@ -17,7 +17,9 @@ import (
 	"errors"
 	"net/http"
 	"runtime"
+	"sort"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
 	"github.com/aws/amazon-ecs-agent/agent/async"
@@ -137,6 +139,7 @@ func (client *APIECSClient) registerContainerInstance(clusterRef string, contain
 	for _, attribute := range additionalAttributes {
 		registerRequest.Attributes = append(registerRequest.Attributes, attribute)
 	}
+	registerRequest.Attributes = append(registerRequest.Attributes, client.getCustomAttributes()...)
 
 	instanceIdentityDoc, err := client.ec2metadata.ReadResource(ec2.INSTANCE_IDENTITY_DOCUMENT_RESOURCE)
 	iidRetrieved := true
@@ -164,6 +167,7 @@ func (client *APIECSClient) registerContainerInstance(clusterRef string, contain
 
 	cpu, mem := getCpuAndMemory()
 	mem = mem - int64(client.config.ReservedMemory)
+	cpu = cpu - int64(client.config.ReservedCPU)
 
 	cpuResource := ecs.Resource{
 		Name:         utils.Strptr("CPU"),
@@ -219,6 +223,26 @@ func getAdditionalAttributes() []*ecs.Attribute {
 	}}
 }
 
+// getCustomAttributes returns the attributes configured for the instance,
+// sorted by name
+func (client *APIECSClient) getCustomAttributes() []*ecs.Attribute {
+	names := make([]string, 0, len(client.config.InstanceAttributes))
+	for name := range client.config.InstanceAttributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var attributes []*ecs.Attribute
+	for _, name := range names {
+		attribute := &ecs.Attribute{Name: aws.String(name)}
+		if value := client.config.InstanceAttributes[name]; value != "" {
+			attribute.Value = aws.String(value)
+		}
+		attributes = append(attributes, attribute)
+	}
+	return attributes
+}
+
 func (client *APIECSClient) SubmitTaskStateChange(change api.TaskStateChange) error {
 	if change.Status == api.TaskStatusNone {
 		log.Warn("SubmitTaskStateChange called with an invalid change", "change", change)
@@ -232,11 +256,12 @@ func (client *APIECSClient) SubmitTaskStateChange(change api.TaskStateChange) er
 	}
 
 	status := change.Status.String()
+	reason := trimReason(change.Reason)
 	_, err := client.submitStateChangeClient.SubmitTaskStateChange(&ecs.SubmitTaskStateChangeInput{
 		Cluster: &client.config.Cluster,
 		Task:    &change.TaskArn,
 		Status:  &status,
-		Reason:  &change.Reason,
+		Reason:  &reason,
 	})
 	if err != nil {
 		log.Warn("Could not submit a task state change", "err", err)
@@ -252,12 +277,8 @@ func (client *APIECSClient) SubmitContainerStateChange(change api.ContainerState
 		ContainerName: &change.ContainerName,
 	}
 	if change.Reason != "" {
-		if len(change.Reason) > ecsMaxReasonLength {
-			trimmed := change.Reason[0:ecsMaxReasonLength]
-			req.Reason = &trimmed
-		} else {
-			req.Reason = &change.Reason
-		}
+		reason := trimReason(change.Reason)
+		req.Reason = &reason
 	}
 	stat := change.Status.String()
 	if stat == "DEAD" {
@@ -295,6 +316,19 @@ func (client *APIECSClient) SubmitContainerStateChange(change api.ContainerState
 	return nil
 }
 
+// trimReason truncates a state change reason to the longest prefix ECS
+// accepts, without splitting a multi-byte character
+func trimReason(reason string) string {
+	if len(reason) <= ecsMaxReasonLength {
+		return reason
+	}
+	end := ecsMaxReasonLength
+	for end > 0 && !utf8.RuneStart(reason[end]) {
+		end--
+	}
+	return reason[:end]
+}
+
 func (client *APIECSClient) DiscoverPollEndpoint(containerInstanceArn string) (string, error) {
 	resp, err := client.discoverPollEndpoint(containerInstanceArn)
 	if err != nil {
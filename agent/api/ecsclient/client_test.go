@@ -231,6 +231,59 @@ func TestSubmitContainerStateChangeLongReason(t *testing.T) {
 	}
 }
 
+func TestSubmitContainerStateChangeLongMultibyteReason(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient())
+	exitCode := 137
+	// Each character is two bytes, so the limit falls in the middle of one
+	trimmedReason := strings.Repeat("é", ecsMaxReasonLength/2)
+	reason := strings.Repeat("é", ecsMaxReasonLength)
+
+	mockSubmitStateClient.EXPECT().SubmitContainerStateChange(&containerSubmitInputMatcher{
+		ecs.SubmitContainerStateChangeInput{
+			Cluster:         strptr(configuredCluster),
+			Task:            strptr("arn"),
+			ContainerName:   strptr("cont"),
+			Status:          strptr("STOPPED"),
+			ExitCode:        int64ptr(&exitCode),
+			Reason:          strptr(trimmedReason),
+			NetworkBindings: []*ecs.NetworkBinding{},
+		},
+	})
+	err := client.SubmitContainerStateChange(api.ContainerStateChange{
+		TaskArn:       "arn",
+		ContainerName: "cont",
+		Status:        api.ContainerStopped,
+		ExitCode:      &exitCode,
+		Reason:        reason,
+	})
+	if err != nil {
+		t.Errorf("Unable to submit container state change: %v", err)
+	}
+}
+
+func TestSubmitTaskStateChangeLongReason(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient())
+	reason := strings.Repeat("a", ecsMaxReasonLength+1)
+
+	mockSubmitStateClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(input *ecs.SubmitTaskStateChangeInput) {
+		if aws.StringValue(input.Reason) != reason[:ecsMaxReasonLength] {
+			t.Errorf("Expected the reason to be trimmed, got: %s", aws.StringValue(input.Reason))
+		}
+	})
+	err := client.SubmitTaskStateChange(api.TaskStateChange{
+		TaskArn: "arn",
+		Status:  api.TaskStopped,
+		Reason:  reason,
+	})
+	if err != nil {
+		t.Errorf("Unable to submit task state change: %v", err)
+	}
+}
+
 func TestRegisterContainerInstance(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -269,6 +322,66 @@ func TestRegisterContainerInstance(t *testing.T) {
 	}
 }
 
+func TestRegisterContainerInstanceCustomAttributes(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEC2Metadata := mock_ec2.NewMockEC2MetadataClient(mockCtrl)
+	mc := mock_api.NewMockECSSDK(mockCtrl)
+	client := NewECSClient(credentials.AnonymousCredentials, &config.Config{
+		Cluster:            configuredCluster,
+		AWSRegion:          "us-east-1",
+		InstanceAttributes: map[string]string{"stack": "prod", "gpu": ""},
+	}, http.DefaultClient, mockEC2Metadata)
+	client.(*APIECSClient).SetSDK(mc)
+
+	mockEC2Metadata.EXPECT().ReadResource(ec2.INSTANCE_IDENTITY_DOCUMENT_RESOURCE).Return([]byte("instanceIdentityDocument"), nil)
+	mockEC2Metadata.EXPECT().ReadResource(ec2.INSTANCE_IDENTITY_DOCUMENT_SIGNATURE_RESOURCE).Return([]byte("signature"), nil)
+	mc.EXPECT().RegisterContainerInstance(gomock.Any()).Do(func(req *ecs.RegisterContainerInstanceInput) {
+		// The custom attributes follow the detected capabilities
+		if assert.Len(t, req.Attributes, 4) {
+			assert.Equal(t, "capability1", *req.Attributes[0].Name)
+			assert.Equal(t, "ecs.os-type", *req.Attributes[1].Name)
+			assert.Equal(t, "gpu", *req.Attributes[2].Name)
+			assert.Nil(t, req.Attributes[2].Value)
+			assert.Equal(t, "stack", *req.Attributes[3].Name)
+			assert.Equal(t, "prod", *req.Attributes[3].Value)
+		}
+	}).Return(&ecs.RegisterContainerInstanceOutput{ContainerInstance: &ecs.ContainerInstance{ContainerInstanceArn: aws.String("registerArn")}}, nil)
+
+	_, err := client.RegisterContainerInstance("", []string{"capability1"})
+	assert.Nil(t, err)
+}
+
+func TestRegisterContainerInstanceReservedResources(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEC2Metadata := mock_ec2.NewMockEC2MetadataClient(mockCtrl)
+	mc := mock_api.NewMockECSSDK(mockCtrl)
+	client := NewECSClient(credentials.AnonymousCredentials, &config.Config{
+		Cluster:        configuredCluster,
+		AWSRegion:      "us-east-1",
+		ReservedMemory: 128,
+		ReservedCPU:    256,
+	}, http.DefaultClient, mockEC2Metadata)
+	client.(*APIECSClient).SetSDK(mc)
+
+	cpu, mem := getCpuAndMemory()
+	mockEC2Metadata.EXPECT().ReadResource(gomock.Any()).Return(nil, errors.New("unavailable"))
+	mc.EXPECT().RegisterContainerInstance(gomock.Any()).Do(func(req *ecs.RegisterContainerInstanceInput) {
+		resource, ok := findResource(req.TotalResources, "CPU")
+		if assert.True(t, ok, `Could not find resource "CPU"`) {
+			assert.Equal(t, cpu-256, *resource.IntegerValue, "Expected the reserved cpu to be left out")
+		}
+		resource, ok = findResource(req.TotalResources, "MEMORY")
+		if assert.True(t, ok, `Could not find resource "MEMORY"`) {
+			assert.Equal(t, mem-128, *resource.IntegerValue, "Expected the reserved memory to be left out")
+		}
+	}).Return(&ecs.RegisterContainerInstanceOutput{ContainerInstance: &ecs.ContainerInstance{ContainerInstanceArn: aws.String("registerArn")}}, nil)
+
+	_, err := client.RegisterContainerInstance("", nil)
+	assert.Nil(t, err)
+}
+
 func findResource(resources []*ecs.Resource, name string) (*ecs.Resource, bool) {
 	for _, resource := range resources {
 		if name == *resource.Name {
@@ -78,3 +78,21 @@ type DockerClientConfigError struct {
 
 func (err *DockerClientConfigError) Error() string     { return err.msg }
 func (err *DockerClientConfigError) ErrorName() string { return "DockerClientConfigError" }
+
+// TaskDependencyError is returned when the dependencies declared between a
+// task's containers can never be satisfied
+type TaskDependencyError struct {
+	msg string
+}
+
+func (err *TaskDependencyError) Error() string     { return err.msg }
+func (err *TaskDependencyError) ErrorName() string { return "TaskDependencyError" }
+
+// TaskResourceError is returned when a task's containers reserve more
+// resources than the task is limited to
+type TaskResourceError struct {
+	msg string
+}
+
+func (err *TaskResourceError) Error() string     { return err.msg }
+func (err *TaskResourceError) ErrorName() string { return "TaskResourceError" }
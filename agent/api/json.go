@@ -93,6 +93,32 @@ func (cs *ContainerStatus) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + cs.String() + `"`), nil
 }
 
+func (hs *ContainerHealthStatus) UnmarshalJSON(b []byte) error {
+	if strings.ToLower(string(b)) == "null" {
+		*hs = ContainerHealthUnknown
+		return nil
+	}
+	if b[0] != '"' || b[len(b)-1] != '"' {
+		*hs = ContainerHealthUnknown
+		return errors.New("ContainerHealthStatus must be a string or null; Got " + string(b))
+	}
+
+	stat, ok := containerHealthStatusMap[string(b[1:len(b)-1])]
+	if !ok {
+		*hs = ContainerHealthUnknown
+		return errors.New("Unrecognized ContainerHealthStatus")
+	}
+	*hs = stat
+	return nil
+}
+
+func (hs *ContainerHealthStatus) MarshalJSON() ([]byte, error) {
+	if hs == nil {
+		return nil, nil
+	}
+	return []byte(`"` + hs.String() + `"`), nil
+}
+
 // A type alias that doesn't have a custom unmarshaller so we can unmarshal into
 // something without recursing
 type ContainerOverridesCopy ContainerOverrides
@@ -143,7 +169,8 @@ func (overrides *ContainerOverrides) UnmarshalJSON(b []byte) error {
 // UnmarshalJSON for TaskVolume determines the name and volume type, and
 // unmarshals it into the appropriate HostVolume fulfilling interfaces
 func (tv *TaskVolume) UnmarshalJSON(b []byte) error {
-	// Format: {name: volumeName, host: emptyVolumeOrHostVolume}
+	// Format: {name: volumeName, host: emptyVolumeOrHostVolume} or
+	// {name: volumeName, dockerVolumeConfiguration: dockerVolume}
 	intermediate := make(map[string]json.RawMessage)
 	if err := json.Unmarshal(b, &intermediate); err != nil {
 		return err
@@ -156,6 +183,15 @@ func (tv *TaskVolume) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	if rawdockerdata, ok := intermediate["dockerVolumeConfiguration"]; ok && string(rawdockerdata) != "null" {
+		dockerVolume := &DockerVolume{}
+		if err := json.Unmarshal(rawdockerdata, dockerVolume); err != nil {
+			return err
+		}
+		tv.Volume = dockerVolume
+		return nil
+	}
+
 	if rawhostdata, ok := intermediate["host"]; ok {
 		// Default to trying to unmarshal it as a FSHostVolume
 		var hostvolume FSHostVolume
@@ -189,6 +225,8 @@ func (tv *TaskVolume) MarshalJSON() ([]byte, error) {
 		result["host"] = v
 	case *EmptyHostVolume:
 		result["host"] = v
+	case *DockerVolume:
+		result["dockerVolumeConfiguration"] = v
 	default:
 		log.Crit("Unknown task volume type in marshal")
 	}
@@ -149,6 +149,7 @@ func TestMarshalUnmarshalTaskVolumes(t *testing.T) {
 		Volumes: []TaskVolume{
 			TaskVolume{Name: "1", Volume: &EmptyHostVolume{}},
 			TaskVolume{Name: "2", Volume: &FSHostVolume{FSSourcePath: "/path"}},
+			TaskVolume{Name: "3", Volume: &DockerVolume{DockerName: "ecs-volume", Driver: "local", DriverOpts: map[string]string{"type": "tmpfs"}}},
 		},
 	}
 
@@ -163,17 +164,20 @@ func TestMarshalUnmarshalTaskVolumes(t *testing.T) {
 		t.Fatal("Could not unmarshal: ", err)
 	}
 
-	if len(out.Volumes) != 2 {
+	if len(out.Volumes) != 3 {
 		t.Fatal("Incorrect number of volumes")
 	}
 
-	var v1, v2 TaskVolume
+	var v1, v2, v3 TaskVolume
 
 	for _, v := range out.Volumes {
-		if v.Name == "1" {
+		switch v.Name {
+		case "1":
 			v1 = v
-		} else {
+		case "2":
 			v2 = v
+		default:
+			v3 = v
 		}
 	}
 
@@ -188,6 +192,10 @@ func TestMarshalUnmarshalTaskVolumes(t *testing.T) {
 	if !ok || fs.FSSourcePath != "/path" {
 		t.Error("Unmarshaled v2 didn't match marshalled v2")
 	}
+
+	if !reflect.DeepEqual(v3.Volume, task.Volumes[2].Volume) {
+		t.Errorf("Unmarshaled v3 didn't match marshalled v3: %v", v3.Volume)
+	}
 }
 
 func TestUnmarshalTransportProtocol_Null(t *testing.T) {
@@ -93,6 +93,22 @@ func (cs *ContainerStatus) BackendRecognized() bool {
 	return *cs == ContainerRunning || *cs == ContainerStopped
 }
 
+var containerHealthStatusMap = map[string]ContainerHealthStatus{
+	"UNKNOWN":   ContainerHealthUnknown,
+	"STARTING":  ContainerHealthStarting,
+	"HEALTHY":   ContainerHealthy,
+	"UNHEALTHY": ContainerUnhealthy,
+}
+
+func (hs ContainerHealthStatus) String() string {
+	for k, v := range containerHealthStatusMap {
+		if v == hs {
+			return k
+		}
+	}
+	return "UNKNOWN"
+}
+
 func (cs ContainerStatus) Terminal() bool {
 	return cs == ContainerStopped
 }
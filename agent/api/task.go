@@ -16,13 +16,17 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	"github.com/aws/amazon-ecs-agent/agent/engine/emptyvolume"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
@@ -47,6 +51,8 @@ func (task *Task) PostUnmarshalTask(credentialsManager credentials.Manager) {
 	// hook into this
 	task.adjustForPlatform()
 	task.initializeEmptyVolumes()
+	task.initializeDockerVolumes()
+	task.initializeNamespaceSharing()
 	task.initializeCredentialsEndpoint(credentialsManager)
 }
 
@@ -97,6 +103,47 @@ func (task *Task) initializeEmptyVolumes() {
 
 }
 
+// initializeDockerVolumes names the docker volumes of the task. Names are
+// derived from the task so that they're the same when the task is restored
+func (task *Task) initializeDockerVolumes() {
+	for _, volume := range task.Volumes {
+		dockerVolume, ok := volume.Volume.(*DockerVolume)
+		if !ok || dockerVolume.DockerName != "" {
+			continue
+		}
+		taskID := task.Arn[strings.LastIndex(task.Arn, "/")+1:]
+		dockerVolume.DockerName = "ecs-" + task.Family + "-" + task.Version + "-" + dockerVolumeNameChars(volume.Name) + "-" + dockerVolumeNameChars(taskID)
+	}
+}
+
+// dockerVolumeNameChars removes the characters docker doesn't allow in volume
+// names
+func dockerVolumeNameChars(name string) string {
+	result := ""
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if (c <= '9' && c >= '0') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '-' || c == '_' || c == '.' {
+			result += string(c)
+		}
+	}
+	return result
+}
+
+// DockerVolumes returns the docker volumes the container mounts
+func (task *Task) DockerVolumes(container *Container) []*DockerVolume {
+	var volumes []*DockerVolume
+	for _, mountPoint := range container.MountPoints {
+		hostVolume, ok := task.HostVolumeByName(mountPoint.SourceVolume)
+		if !ok {
+			continue
+		}
+		if dockerVolume, ok := hostVolume.(*DockerVolume); ok {
+			volumes = append(volumes, dockerVolume)
+		}
+	}
+	return volumes
+}
+
 // initializeCredentialsEndpoint sets the credentials endpoint for all containers in a task if needed.
 func (task *Task) initializeCredentialsEndpoint(credentialsManager credentials.Manager) {
 	id := task.GetCredentialsId()
@@ -207,6 +254,12 @@ func (task *Task) updateTaskKnownStatus() (newStatus TaskStatus) {
 		llog.Debug("Essential container is stopped while other containers are running, not update task status")
 		return TaskStatusNone
 	}
+	// If an essential container's health check hasn't reported a result yet,
+	// don't consider the task running until it does
+	if earliestStatus == ContainerRunning && task.WaitingOnHealthCheck() {
+		llog.Debug("Essential container health check is still starting, not update task status")
+		return TaskStatusNone
+	}
 	llog.Debug("Earliest status is " + earliestStatus.String())
 	if task.GetKnownStatus() < earliestStatus.TaskStatus() {
 		task.UpdateKnownStatusAndTime(earliestStatus.TaskStatus())
@@ -215,6 +268,150 @@ func (task *Task) updateTaskKnownStatus() (newStatus TaskStatus) {
 	return TaskStatusNone
 }
 
+// ContainersResources returns the cpu units and MiB of memory reserved by the
+// task's containers. Containers created by the agent itself are not counted
+func (task *Task) ContainersResources() (cpu uint, memory uint) {
+	for _, cont := range task.Containers {
+		if cont.IsInternal {
+			continue
+		}
+		cpu += cont.Cpu
+		if cont.Memory > 0 {
+			memory += cont.Memory
+		} else {
+			// Containers without a hard limit reserve their soft limit
+			memory += cont.MemoryReservation
+		}
+	}
+	return cpu, memory
+}
+
+// Validate returns a *TaskResourceError if the cpu or memory reserved by the
+// task's containers exceeds the task's limit. A limit of zero is treated as
+// unlimited
+func (task *Task) Validate() error {
+	cpu, memory := task.ContainersResources()
+	if task.Cpu > 0 && cpu > task.Cpu {
+		return &TaskResourceError{fmt.Sprintf("containers reserve %d cpu units, exceeding the task limit of %d", cpu, task.Cpu)}
+	}
+	if task.Memory > 0 && memory > task.Memory {
+		return &TaskResourceError{fmt.Sprintf("containers reserve %d MiB of memory, exceeding the task limit of %d MiB", memory, task.Memory)}
+	}
+	return nil
+}
+
+// EssentialContainerExitReason returns the reason the task stopped if it
+// reports failures of its essential containers and one of them exited with a
+// non-zero code, or an empty string otherwise
+func (task *Task) EssentialContainerExitReason() string {
+	if !task.FailOnEssentialExitCode {
+		return ""
+	}
+	for _, cont := range task.Containers {
+		if !cont.Essential || cont.GetKnownStatus() != ContainerStopped {
+			continue
+		}
+		if cont.KnownExitCode != nil && *cont.KnownExitCode != 0 {
+			return fmt.Sprintf("Essential container in task exited: %s exited with code %d", cont.Name, *cont.KnownExitCode)
+		}
+	}
+	return ""
+}
+
+// WaitingOnHealthCheck returns true if any essential container has a health
+// check that hasn't reported a result yet
+func (task *Task) WaitingOnHealthCheck() bool {
+	for _, cont := range task.Containers {
+		if cont.Essential && cont.GetHealthStatus() == ContainerHealthStarting {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitingOnDependencies returns true if a container of the task can't
+// progress until a dependency reaches its condition, which it is expected to
+// do on its own, such as by exiting or passing its health check
+func (task *Task) WaitingOnDependencies() bool {
+	containers := make(map[string]*Container)
+	for _, cont := range task.Containers {
+		containers[cont.Name] = cont
+	}
+	for _, cont := range task.Containers {
+		if cont.DesiredTerminal() {
+			continue
+		}
+		for _, dependency := range cont.DependsOn {
+			dependencyContainer, ok := containers[dependency.Container]
+			if ok && !dependency.ResolvedBy(dependencyContainer) && dependency.MayResolve(dependencyContainer) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateDependsOn verifies that every container the containers of the task
+// depend on exists, that the conditions are valid and that the dependencies
+// don't form a cycle
+func (task *Task) ValidateDependsOn() error {
+	containers := make(map[string]*Container)
+	for _, cont := range task.Containers {
+		containers[cont.Name] = cont
+	}
+	for _, cont := range task.Containers {
+		for _, dependency := range cont.DependsOn {
+			if _, ok := containers[dependency.Container]; !ok {
+				return &TaskDependencyError{fmt.Sprintf("Container %s depends on unknown container %s", cont.Name, dependency.Container)}
+			}
+			switch dependency.Condition {
+			case DependencyConditionStart, DependencyConditionHealthy, DependencyConditionSuccess:
+			default:
+				return &TaskDependencyError{fmt.Sprintf("Container %s depends on container %s with invalid condition %q", cont.Name, dependency.Container, dependency.Condition)}
+			}
+		}
+	}
+
+	// Depth first search, where a container that is visited again while it
+	// is still on the path closes a cycle
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			for i, onPath := range path {
+				if onPath == name {
+					cycle := append(path[i:], name)
+					return &TaskDependencyError{"Dependency cycle between containers: " + strings.Join(cycle, " -> ")}
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range containers[name].DependsOn {
+			if err := visit(dependency.Container); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, cont := range task.Containers {
+		if err := visit(cont.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Overridden returns a copy of the task with all container's overridden and
 // itself overridden as well
 func (task *Task) Overridden() *Task {
@@ -278,9 +475,97 @@ func (task *Task) dockerConfig(container *Container) (*docker.Config, *DockerCli
 		config.Labels = make(map[string]string)
 	}
 
+	// The user and working directory of the definition take precedence over
+	// any set in the docker config
+	if container.User != "" {
+		if err := validateUser(container.User); err != nil {
+			return nil, &DockerClientConfigError{err.Error()}
+		}
+		config.User = container.User
+	}
+	if container.WorkingDirectory != "" {
+		if err := validateWorkingDirectory(container.WorkingDirectory); err != nil {
+			return nil, &DockerClientConfigError{err.Error()}
+		}
+		config.WorkingDir = container.WorkingDirectory
+	}
+	if container.StopSignal != "" {
+		stopSignal, err := dockerStopSignal(container.StopSignal)
+		if err != nil {
+			return nil, &DockerClientConfigError{err.Error()}
+		}
+		config.StopSignal = stopSignal
+	}
+
 	return config, nil
 }
 
+// stopSignals are the names of the signals containers may be stopped with,
+// without their SIG prefix
+var stopSignals = map[string]bool{
+	"ABRT": true, "ALRM": true, "BUS": true, "CHLD": true, "CONT": true,
+	"FPE": true, "HUP": true, "ILL": true, "INT": true, "IO": true,
+	"KILL": true, "PIPE": true, "PROF": true, "PWR": true, "QUIT": true,
+	"SEGV": true, "STKFLT": true, "STOP": true, "SYS": true, "TERM": true,
+	"TRAP": true, "TSTP": true, "TTIN": true, "TTOU": true, "URG": true,
+	"USR1": true, "USR2": true, "VTALRM": true, "WINCH": true, "XCPU": true,
+	"XFSZ": true,
+}
+
+// maximumStopSignal is the highest signal number, the last of the real-time
+// signals
+const maximumStopSignal = 64
+
+// dockerStopSignal checks that the stop signal of a container is a signal
+// name, with or without its SIG prefix, or a signal number, and returns it
+// the way docker takes it
+func dockerStopSignal(signal string) (string, error) {
+	if number, err := strconv.Atoi(signal); err == nil {
+		if number < 1 || number > maximumStopSignal {
+			return "", fmt.Errorf("Invalid stop signal %q: signal numbers range from 1 to %d", signal, maximumStopSignal)
+		}
+		return signal, nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+	if !stopSignals[name] {
+		return "", fmt.Errorf("Invalid stop signal %q: unknown signal", signal)
+	}
+	return "SIG" + name, nil
+}
+
+// userPartPattern matches a user or group name, or a numeric id
+var userPartPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// validateUser checks that a user is given as "user" or "user:group", where
+// each of them is either a name or a numeric id
+func validateUser(user string) error {
+	parts := strings.Split(user, ":")
+	if len(parts) > 2 {
+		return fmt.Errorf("Invalid user %q: expected user or user:group", user)
+	}
+	for _, part := range parts {
+		if !userPartPattern.MatchString(part) {
+			return fmt.Errorf("Invalid user %q: malformed user or group %q", user, part)
+		}
+		if _, err := strconv.ParseUint(part, 10, 32); err != nil && strings.Trim(part, "0123456789") == "" {
+			return fmt.Errorf("Invalid user %q: id %s is out of range", user, part)
+		}
+	}
+	return nil
+}
+
+// validateWorkingDirectory checks that a working directory is an absolute
+// path without control characters
+func validateWorkingDirectory(workingDirectory string) error {
+	if strings.IndexFunc(workingDirectory, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+		return fmt.Errorf("Invalid working directory %q: contains control characters", workingDirectory)
+	}
+	if !filepath.IsAbs(workingDirectory) {
+		return fmt.Errorf("Invalid working directory %q: must be an absolute path", workingDirectory)
+	}
+	return nil
+}
+
 // Docker silently converts 0 to 1024 CPU shares, which is probably not what we
 // want.  Instead, we convert 0 to 2 to be closer to expected behavior. The
 // reason for 2 over 1 is that 1 is an invalid value (Linux's choice, not
@@ -325,11 +610,11 @@ func (task *Task) dockerConfigVolumes(container *Container) (map[string]struct{}
 	return volumeMap, nil
 }
 
-func (task *Task) DockerHostConfig(container *Container, dockerContainerMap map[string]*DockerContainer) (*docker.HostConfig, *HostConfigError) {
+func (task *Task) DockerHostConfig(container *Container, dockerContainerMap map[string]*DockerContainer) (*dockeriface.HostConfig, *HostConfigError) {
 	return task.Overridden().dockerHostConfig(container.Overridden(), dockerContainerMap)
 }
 
-func (task *Task) dockerHostConfig(container *Container, dockerContainerMap map[string]*DockerContainer) (*docker.HostConfig, *HostConfigError) {
+func (task *Task) dockerHostConfig(container *Container, dockerContainerMap map[string]*DockerContainer) (*dockeriface.HostConfig, *HostConfigError) {
 	dockerLinkArr, err := task.dockerLinks(container, dockerContainerMap)
 	if err != nil {
 		return nil, &HostConfigError{err.Error()}
@@ -352,12 +637,114 @@ func (task *Task) dockerHostConfig(container *Container, dockerContainerMap map[
 		return nil, &HostConfigError{err.Error()}
 	}
 
-	hostConfig := &docker.HostConfig{
-		Links:        dockerLinkArr,
-		Binds:        binds,
-		PortBindings: dockerPortMap,
-		VolumesFrom:  volumesFrom,
-		ShmSize:      shmSize,
+	memoryReservation, memorySwap, err := dockerMemoryLimits(container)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	tmpfs, err := task.dockerTmpfs(container)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	ulimits, err := task.dockerUlimits(container)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	dns, err := task.dockerDNSServers(container)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	extraHosts, err := task.dockerExtraHosts(container)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	capAdd, capDrop, err := task.dockerCapabilities(container)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	devices, err := task.dockerDevices(container)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	blkioWeight, readBps, writeBps, err := task.dockerBlkio(container)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	networkMode, err := task.dockerNetworkMode(container, dockerContainerMap)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	restartPolicy, err := dockerRestartPolicy(container.RestartPolicy)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	securityOpt, err := dockerSecurityOptions(container.DockerSecurityOptions)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	pidMode, err := task.dockerNamespaceMode("PID", task.PidMode, container, dockerContainerMap)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	ipcMode, err := task.dockerNamespaceMode("IPC", task.IpcMode, container, dockerContainerMap)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+	if ipcMode != "" && shmSize != 0 {
+		return nil, &HostConfigError{"Shared memory size is not supported for containers using the IPC namespace of the instance or of another container"}
+	}
+
+	sysctls, err := task.dockerSysctls(container, networkMode, ipcMode)
+	if err != nil {
+		return nil, &HostConfigError{err.Error()}
+	}
+
+	hostConfig := &dockeriface.HostConfig{
+		HostConfig: &docker.HostConfig{
+			Links:         dockerLinkArr,
+			Binds:         binds,
+			PortBindings:  dockerPortMap,
+			VolumesFrom:   volumesFrom,
+			ShmSize:       shmSize,
+			Tmpfs:         tmpfs,
+			Ulimits:       ulimits,
+			DNS:           dns,
+			DNSSearch:     container.DNSSearchDomains,
+			ExtraHosts:    extraHosts,
+			CapAdd:        capAdd,
+			CapDrop:       capDrop,
+			Devices:       devices,
+			NetworkMode:   networkMode,
+			RestartPolicy: restartPolicy,
+			SecurityOpt:   securityOpt,
+			PidMode:       pidMode,
+			IpcMode:       ipcMode,
+			Sysctls:       sysctls,
+			// Tmpfs mounts stay writable when the root filesystem is read only
+			ReadonlyRootfs: container.ReadonlyRootFilesystem,
+			// The hard memory limit is part of the container's config
+			MemoryReservation: memoryReservation,
+			MemorySwap:        memorySwap,
+			// Relative block IO weight
+			BlkioWeight: blkioWeight,
+		},
+		// Device bandwidth limits
+		BlkioDeviceReadBps:  readBps,
+		BlkioDeviceWriteBps: writeBps,
+	}
+	if container.LinuxParameters != nil {
+		hostConfig.Init = container.LinuxParameters.Init
 	}
 
 	if container.DockerConfig.HostConfig != nil {
@@ -370,7 +757,143 @@ func (task *Task) dockerHostConfig(container *Container, dockerContainerMap map[
 	return hostConfig, nil
 }
 
+// dockerRestartPolicy translates the restart policy of a container definition
+// into the docker restart policy. An empty policy leaves the docker default,
+// which is to never restart the container
+func dockerRestartPolicy(policy string) (docker.RestartPolicy, error) {
+	switch {
+	case policy == "":
+		return docker.RestartPolicy{}, nil
+	case policy == RestartPolicyNo || policy == RestartPolicyAlways || policy == RestartPolicyUnlessStopped:
+		return docker.RestartPolicy{Name: policy}, nil
+	case policy == RestartPolicyOnFailure:
+		return docker.RestartPolicy{Name: RestartPolicyOnFailure}, nil
+	case strings.HasPrefix(policy, RestartPolicyOnFailure+":"):
+		retries, err := strconv.Atoi(strings.TrimPrefix(policy, RestartPolicyOnFailure+":"))
+		if err != nil || retries < 0 {
+			return docker.RestartPolicy{}, fmt.Errorf("Invalid restart policy %q: the maximum number of restarts must be a non-negative integer", policy)
+		}
+		return docker.RestartPolicy{Name: RestartPolicyOnFailure, MaximumRetryCount: retries}, nil
+	}
+	return docker.RestartPolicy{}, fmt.Errorf("Invalid restart policy %q: must be one of no, always, unless-stopped or on-failure[:max-retries]", policy)
+}
+
+// SeccompUnconfined and AppArmorUnconfined are the profiles that run a
+// container without seccomp filtering or AppArmor confinement
+const (
+	SeccompUnconfined  = "unconfined"
+	AppArmorUnconfined = "unconfined"
+)
+
+// seccompProfileNamePattern matches the names of the seccomp profiles of the
+// instance, which can't point outside of the directory holding them
+var seccompProfileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// appArmorProfileNamePattern matches the names of AppArmor profiles, which
+// may be the paths of the programs they confine
+var appArmorProfileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9/_][a-zA-Z0-9._/-]*$`)
+
+// securityOption splits a docker security option into its name and value,
+// which may be separated by either ':' or '='
+func securityOption(option string) (string, string, bool) {
+	separator := strings.IndexAny(option, ":=")
+	if separator < 0 {
+		return "", "", false
+	}
+	return option[:separator], option[separator+1:], true
+}
+
+// SeccompProfile returns the profile of a docker seccomp security option
+func SeccompProfile(option string) (string, bool) {
+	name, profile, ok := securityOption(option)
+	return profile, ok && name == "seccomp"
+}
+
+// AppArmorProfile returns the profile of a docker apparmor security option
+func AppArmorProfile(option string) (string, bool) {
+	name, profile, ok := securityOption(option)
+	return profile, ok && name == "apparmor"
+}
+
+// InlineSeccompProfile returns whether a seccomp profile is the JSON profile
+// itself rather than the name of one
+func InlineSeccompProfile(profile string) bool {
+	return strings.HasPrefix(strings.TrimSpace(profile), "{")
+}
+
+// dockerSecurityOptions translates the security options of a container
+// definition into docker's. Seccomp and AppArmor are supported, each set at
+// most once. Seccomp is set to unconfined, the name of a profile of the
+// instance or an inline JSON profile, and AppArmor to the name of a profile
+func dockerSecurityOptions(options []string) ([]string, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	securityOpt := make([]string, 0, len(options))
+	seen := make(map[string]bool)
+	for _, option := range options {
+		name, profile, ok := securityOption(option)
+		if !ok || (name != "seccomp" && name != "apparmor") {
+			return nil, fmt.Errorf("Invalid security option %q: only seccomp:<profile> and apparmor:<profile> are supported", option)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("Invalid security options %q: %s may only be set once", options, name)
+		}
+		seen[name] = true
+
+		switch {
+		case name == "apparmor":
+			if !appArmorProfileNamePattern.MatchString(profile) {
+				return nil, fmt.Errorf("Invalid AppArmor profile %q: expected unconfined or the name of a profile", profile)
+			}
+		case profile == SeccompUnconfined:
+		case InlineSeccompProfile(profile):
+			var parsed map[string]interface{}
+			if err := json.Unmarshal([]byte(profile), &parsed); err != nil {
+				return nil, fmt.Errorf("Invalid inline seccomp profile: %v", err)
+			}
+		case !seccompProfileNamePattern.MatchString(profile):
+			return nil, fmt.Errorf("Invalid seccomp profile %q: expected unconfined, the name of a profile or a JSON profile", profile)
+		}
+		securityOpt = append(securityOpt, name+"="+profile)
+	}
+	return securityOpt, nil
+}
+
+// dockerMemoryLimits returns the soft memory limit and the memory and swap
+// limit of the container in bytes. The soft limit may not be above the hard
+// limit, and the memory and swap limit may not be below it
+func dockerMemoryLimits(container *Container) (int64, int64, error) {
+	if container.Memory > 0 && container.MemoryReservation > container.Memory {
+		return 0, 0, fmt.Errorf("Invalid memory reservation %d: must not be above the memory limit of %d MiB", container.MemoryReservation, container.Memory)
+	}
+	reservation := int64(container.MemoryReservation) * 1024 * 1024
+
+	switch {
+	case container.MemorySwap == 0:
+		return reservation, 0, nil
+	case container.MemorySwap == -1:
+		return reservation, -1, nil
+	case container.MemorySwap < 0:
+		return 0, 0, fmt.Errorf("Invalid memory swap %d: must be positive or -1 for unlimited swap", container.MemorySwap)
+	case container.Memory == 0:
+		return 0, 0, fmt.Errorf("Invalid memory swap %d: a memory limit is required to limit swap", container.MemorySwap)
+	case container.MemorySwap < int64(container.Memory):
+		return 0, 0, fmt.Errorf("Invalid memory swap %d: must not be below the memory limit of %d MiB", container.MemorySwap, container.Memory)
+	}
+	return reservation, container.MemorySwap * 1024 * 1024, nil
+}
+
+// dockerShmSize returns the size of /dev/shm of the container in bytes, from
+// its linux parameters or the ECS_SHM_SIZE environment variable
 func (task *Task) dockerShmSize(container *Container) (int64, error) {
+	if container.LinuxParameters != nil && container.LinuxParameters.ShmSize != nil {
+		shmSize := *container.LinuxParameters.ShmSize
+		if shmSize <= 0 {
+			return 0, fmt.Errorf("Invalid shared memory size %d: must be positive", shmSize)
+		}
+		return shmSize * 1024 * 1024, nil
+	}
 	if s, ok := container.Environment["ECS_SHM_SIZE"]; ok {
 		return strconv.ParseInt(s, 10, 64)
 	}
@@ -378,6 +901,457 @@ func (task *Task) dockerShmSize(container *Container) (int64, error) {
 	return 0, nil
 }
 
+// dockerDNSServers returns the DNS servers of the container, failing if one
+// isn't an IP address
+func (task *Task) dockerDNSServers(container *Container) ([]string, error) {
+	for _, server := range container.DNSServers {
+		if net.ParseIP(server) == nil {
+			return nil, fmt.Errorf("Invalid DNS server %q: must be an IP address", server)
+		}
+	}
+	return container.DNSServers, nil
+}
+
+// hostnamePattern matches hostnames made of dot separated labels of letters,
+// digits and hyphens that don't start or end with a hyphen
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// dockerExtraHosts returns the /etc/hosts entries of the container in the
+// hostname:ip format expected by docker, failing if an entry is malformed or
+// maps a hostname to more than one IP address. Repeated entries are only added
+// once
+func (task *Task) dockerExtraHosts(container *Container) ([]string, error) {
+	if len(container.ExtraHosts) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]string)
+	extraHosts := make([]string, 0, len(container.ExtraHosts))
+	for _, entry := range container.ExtraHosts {
+		if len(entry.Hostname) > 253 || !hostnamePattern.MatchString(entry.Hostname) {
+			return nil, fmt.Errorf("Invalid extra host %q: malformed hostname", entry.Hostname)
+		}
+		ip := net.ParseIP(entry.IPAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid extra host %s: %q is not an IP address", entry.Hostname, entry.IPAddress)
+		}
+		if mapped, ok := seen[entry.Hostname]; ok {
+			if mapped != ip.String() {
+				return nil, fmt.Errorf("Invalid extra host %s: mapped to both %s and %s", entry.Hostname, mapped, ip.String())
+			}
+			continue
+		}
+		seen[entry.Hostname] = ip.String()
+		extraHosts = append(extraHosts, entry.Hostname+":"+entry.IPAddress)
+	}
+	return extraHosts, nil
+}
+
+// networkNamePattern matches the names of user-defined docker networks
+var networkNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// dockerNetworkMode returns the network mode of the container, which is the
+// network mode of the task unless the container sets its own, failing if the
+// mode is malformed or can't be combined with the container's port mappings or
+// links. Containers joining the network stack of another container of the
+// task refer to it by its docker id once it has been created
+func (task *Task) dockerNetworkMode(container *Container, dockerContainerMap map[string]*DockerContainer) (string, error) {
+	if strings.HasPrefix(task.NetworkMode, NetworkModeContainerPrefix) {
+		return "", fmt.Errorf("Invalid task network mode %q: only containers may join the network stack of another container", task.NetworkMode)
+	}
+	mode := container.NetworkMode
+	if mode == "" {
+		mode = task.NetworkMode
+	}
+
+	switch {
+	case mode == "" || mode == NetworkModeBridge:
+		return mode, nil
+	case mode == NetworkModeHost:
+		for _, binding := range container.Ports {
+			if binding.HostPort != 0 && binding.HostPort != binding.ContainerPort {
+				return "", fmt.Errorf("Invalid port mapping %d:%d for the host network mode: the host port must be the container port", binding.HostPort, binding.ContainerPort)
+			}
+		}
+	case mode == NetworkModeNone:
+		if len(container.Ports) > 0 {
+			return "", fmt.Errorf("Port mappings are not supported with the %s network mode", mode)
+		}
+	case strings.HasPrefix(mode, NetworkModeContainerPrefix):
+		name := container.NetworkContainer()
+		if name == container.Name {
+			return "", fmt.Errorf("Invalid network mode %q: a container can't join its own network stack", mode)
+		}
+		if len(container.Ports) > 0 || len(container.Links) > 0 {
+			return "", errors.New("Port mappings and links are not supported for containers joining the network stack of another container")
+		}
+		target, ok := dockerContainerMap[name]
+		if !ok {
+			return "", errors.New("Network container not available: " + name)
+		}
+		if target.DockerId != "" {
+			return NetworkModeContainerPrefix + target.DockerId, nil
+		}
+		return NetworkModeContainerPrefix + target.DockerName, nil
+	default:
+		if !networkNamePattern.MatchString(mode) {
+			return "", fmt.Errorf("Invalid network mode %q", mode)
+		}
+		return mode, nil
+	}
+
+	if len(container.Links) > 0 {
+		return "", fmt.Errorf("Links are not supported with the %s network mode", mode)
+	}
+	return mode, nil
+}
+
+// namespaceContainer returns the container whose PID and IPC namespaces the
+// other containers of the task join in the task namespace mode, which is the
+// first container of the task definition
+func (task *Task) namespaceContainer() *Container {
+	for _, container := range task.Containers {
+		if !container.IsInternal {
+			return container
+		}
+	}
+	return nil
+}
+
+// initializeNamespaceSharing makes the containers joining the namespaces of
+// the namespace container wait for it to run, as docker can only join the
+// namespaces of a running container
+func (task *Task) initializeNamespaceSharing() {
+	if task.PidMode != NamespaceModeTask && task.IpcMode != NamespaceModeTask {
+		return
+	}
+	namespaceContainer := task.namespaceContainer()
+	for _, container := range task.Containers {
+		if container == namespaceContainer || container.IsInternal {
+			continue
+		}
+		container.RunDependencies = append(container.RunDependencies, namespaceContainer.Name)
+	}
+}
+
+// dockerNamespaceMode returns the docker PID or IPC mode of the container for
+// the task's mode of the namespace. In the task mode, the namespace container
+// gets a namespace of its own which the other containers join by its docker
+// id once it has been created. Internal containers always get their own
+func (task *Task) dockerNamespaceMode(namespace string, mode string, container *Container, dockerContainerMap map[string]*DockerContainer) (string, error) {
+	switch mode {
+	case "", NamespaceModeNone:
+		return "", nil
+	case NamespaceModeHost:
+		if container.IsInternal {
+			return "", nil
+		}
+		return NamespaceModeHost, nil
+	case NamespaceModeTask:
+		namespaceContainer := task.namespaceContainer()
+		if container.IsInternal || container.Name == namespaceContainer.Name {
+			return "", nil
+		}
+		target, ok := dockerContainerMap[namespaceContainer.Name]
+		if !ok {
+			return "", fmt.Errorf("%s namespace container not available: %s", namespace, namespaceContainer.Name)
+		}
+		if target.DockerId != "" {
+			return NetworkModeContainerPrefix + target.DockerId, nil
+		}
+		return NetworkModeContainerPrefix + target.DockerName, nil
+	}
+	return "", fmt.Errorf("Invalid %s mode %q: must be one of task, host or none", namespace, mode)
+}
+
+// tmpfsSizePattern matches tmpfs sizes, such as "64m"
+var tmpfsSizePattern = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// tmpfsMountOptions are the mount options allowed for tmpfs mounts. Options
+// that take a value, such as "mode", are listed with a trailing "="
+var tmpfsMountOptions = []string{
+	"defaults", "ro", "rw", "suid", "nosuid", "dev", "nodev", "exec", "noexec",
+	"sync", "async", "dirsync", "remount", "mand", "nomand", "atime", "noatime",
+	"diratime", "nodiratime", "relatime", "norelatime", "strictatime", "nostrictatime",
+	"mode=", "uid=", "gid=", "nr_inodes=", "nr_blocks=", "mpol=",
+}
+
+// dockerTmpfs returns the tmpfs mounts for the container, keyed by container
+// path, with the size and mount options in the format expected by docker
+func (task *Task) dockerTmpfs(container *Container) (map[string]string, error) {
+	if len(container.Tmpfs) == 0 {
+		return nil, nil
+	}
+	tmpfs := make(map[string]string)
+	for _, mount := range container.Tmpfs {
+		if !strings.HasPrefix(mount.ContainerPath, "/") {
+			return nil, fmt.Errorf("Invalid tmpfs container path %q: must be an absolute path", mount.ContainerPath)
+		}
+		if _, ok := tmpfs[mount.ContainerPath]; ok {
+			return nil, fmt.Errorf("Invalid tmpfs container path %q: mounted more than once", mount.ContainerPath)
+		}
+		options := make([]string, 0, len(mount.MountOptions)+1)
+		for _, option := range mount.MountOptions {
+			if !validTmpfsMountOption(option) {
+				return nil, fmt.Errorf("Invalid tmpfs mount option %q for %s", option, mount.ContainerPath)
+			}
+			options = append(options, option)
+		}
+		if mount.Size != "" {
+			if !tmpfsSizePattern.MatchString(mount.Size) {
+				return nil, fmt.Errorf("Invalid tmpfs size %q for %s: expected a number with an optional unit of b, k, m or g", mount.Size, mount.ContainerPath)
+			}
+			options = append(options, "size="+mount.Size)
+		}
+		tmpfs[mount.ContainerPath] = strings.Join(options, ",")
+	}
+	return tmpfs, nil
+}
+
+func validTmpfsMountOption(option string) bool {
+	for _, allowed := range tmpfsMountOptions {
+		if strings.HasSuffix(allowed, "=") {
+			if strings.HasPrefix(option, allowed) && len(option) > len(allowed) {
+				return true
+			}
+		} else if option == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// linuxCapabilities are the names of the Linux capabilities docker can add
+// to or drop from containers. "ALL" stands for every capability
+var linuxCapabilities = []string{
+	"ALL", "AUDIT_CONTROL", "AUDIT_READ", "AUDIT_WRITE", "BLOCK_SUSPEND", "CHOWN",
+	"DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER", "FSETID", "IPC_LOCK", "IPC_OWNER",
+	"KILL", "LEASE", "LINUX_IMMUTABLE", "MAC_ADMIN", "MAC_OVERRIDE", "MKNOD",
+	"NET_ADMIN", "NET_BIND_SERVICE", "NET_BROADCAST", "NET_RAW", "SETFCAP",
+	"SETGID", "SETPCAP", "SETUID", "SYS_ADMIN", "SYS_BOOT", "SYS_CHROOT",
+	"SYS_MODULE", "SYS_NICE", "SYS_PACCT", "SYS_PTRACE", "SYS_RAWIO",
+	"SYS_RESOURCE", "SYS_TIME", "SYS_TTY_CONFIG", "SYSLOG", "WAKE_ALARM",
+}
+
+// dockerCapabilities returns the capabilities added to and dropped from the
+// container, failing if a capability is unknown
+func (task *Task) dockerCapabilities(container *Container) ([]string, []string, error) {
+	if container.LinuxParameters == nil || container.LinuxParameters.Capabilities == nil {
+		return nil, nil, nil
+	}
+	capabilities := container.LinuxParameters.Capabilities
+	for _, capability := range append(append([]string(nil), capabilities.Add...), capabilities.Drop...) {
+		if !validCapability(capability) {
+			return nil, nil, fmt.Errorf("Invalid capability %q: expected a Linux capability without the CAP_ prefix, such as NET_ADMIN", capability)
+		}
+	}
+	return capabilities.Add, capabilities.Drop, nil
+}
+
+func validCapability(capability string) bool {
+	for _, known := range linuxCapabilities {
+		if capability == known {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultDevicePermissions are the cgroup permissions of containers on
+// devices that don't set any
+const defaultDevicePermissions = "rwm"
+
+// dockerDevices returns the host devices exposed to the container, failing if
+// a path isn't absolute or the permissions aren't a combination of r, w and m
+func (task *Task) dockerDevices(container *Container) ([]docker.Device, error) {
+	if container.LinuxParameters == nil || len(container.LinuxParameters.Devices) == 0 {
+		return nil, nil
+	}
+	devices := make([]docker.Device, 0, len(container.LinuxParameters.Devices))
+	for _, device := range container.LinuxParameters.Devices {
+		if !filepath.IsAbs(device.HostPath) {
+			return nil, fmt.Errorf("Invalid device host path %q: must be an absolute path", device.HostPath)
+		}
+		containerPath := device.ContainerPath
+		if containerPath == "" {
+			containerPath = device.HostPath
+		} else if !filepath.IsAbs(containerPath) {
+			return nil, fmt.Errorf("Invalid device container path %q: must be an absolute path", containerPath)
+		}
+		permissions := device.Permissions
+		if permissions == "" {
+			permissions = defaultDevicePermissions
+		} else if !validDevicePermissions(permissions) {
+			return nil, fmt.Errorf("Invalid permissions %q for device %s: expected a combination of r, w and m", permissions, device.HostPath)
+		}
+		devices = append(devices, docker.Device{PathOnHost: device.HostPath, PathInContainer: containerPath, CgroupPermissions: permissions})
+	}
+	return devices, nil
+}
+
+func validDevicePermissions(permissions string) bool {
+	seen := make(map[rune]bool)
+	for _, permission := range permissions {
+		if !strings.ContainsRune(defaultDevicePermissions, permission) || seen[permission] {
+			return false
+		}
+		seen[permission] = true
+	}
+	return true
+}
+
+const (
+	// minimumBlkioWeight and maximumBlkioWeight bound the block IO weight of
+	// containers, as enforced by the kernel
+	minimumBlkioWeight = 10
+	maximumBlkioWeight = 1000
+)
+
+// dockerBlkio returns the block IO weight of the container and its read and
+// write bandwidth limits to host devices, failing if the weight is out of
+// range, a device path isn't absolute, a rate isn't positive or a device is
+// limited more than once
+func (task *Task) dockerBlkio(container *Container) (int64, []dockeriface.BlockLimit, []dockeriface.BlockLimit, error) {
+	if container.LinuxParameters == nil {
+		return 0, nil, nil, nil
+	}
+	var weight int64
+	if container.LinuxParameters.BlkioWeight != nil {
+		weight = *container.LinuxParameters.BlkioWeight
+		if weight < minimumBlkioWeight || weight > maximumBlkioWeight {
+			return 0, nil, nil, fmt.Errorf("Invalid block IO weight %d: must be between %d and %d", weight, minimumBlkioWeight, maximumBlkioWeight)
+		}
+	}
+	readBps, err := dockerBlockLimits("read", container.LinuxParameters.DeviceReadBps)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	writeBps, err := dockerBlockLimits("write", container.LinuxParameters.DeviceWriteBps)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return weight, readBps, writeBps, nil
+}
+
+func dockerBlockLimits(direction string, limits []BlkioDeviceLimit) ([]dockeriface.BlockLimit, error) {
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]struct{})
+	blockLimits := make([]dockeriface.BlockLimit, 0, len(limits))
+	for _, limit := range limits {
+		if !filepath.IsAbs(limit.Path) || filepath.Clean(limit.Path) != limit.Path {
+			return nil, fmt.Errorf("Invalid %s bandwidth limit device path %q: must be a clean absolute path", direction, limit.Path)
+		}
+		if _, ok := seen[limit.Path]; ok {
+			return nil, fmt.Errorf("Invalid %s bandwidth limits: device %s is limited more than once", direction, limit.Path)
+		}
+		seen[limit.Path] = struct{}{}
+		if limit.Rate <= 0 {
+			return nil, fmt.Errorf("Invalid %s bandwidth limit %d for device %s: must be positive", direction, limit.Rate, limit.Path)
+		}
+		blockLimits = append(blockLimits, dockeriface.BlockLimit{Path: limit.Path, Rate: limit.Rate})
+	}
+	return blockLimits, nil
+}
+
+// sysctlPattern matches the dot separated names of kernel parameters, such as
+// net.core.somaxconn
+var sysctlPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)+$`)
+
+// ipcSysctls are the kernel parameters of the IPC namespace, besides those
+// prefixed with fs.mqueue.
+var ipcSysctls = []string{
+	"kernel.msgmax", "kernel.msgmnb", "kernel.msgmni", "kernel.sem",
+	"kernel.shm_rmid_forced", "kernel.shmall", "kernel.shmmax", "kernel.shmmni",
+}
+
+// dockerSysctls returns the kernel parameters to set in the namespaces of the
+// container, failing if a parameter is malformed, set more than once, not
+// namespaced, or belongs to a namespace the container shares with the
+// instance or another container
+func (task *Task) dockerSysctls(container *Container, networkMode string, ipcMode string) (map[string]string, error) {
+	if container.LinuxParameters == nil || len(container.LinuxParameters.SystemControls) == 0 {
+		return nil, nil
+	}
+	sysctls := make(map[string]string, len(container.LinuxParameters.SystemControls))
+	for _, sysctl := range container.LinuxParameters.SystemControls {
+		if !sysctlPattern.MatchString(sysctl.Namespace) {
+			return nil, fmt.Errorf("Invalid system control %q: expected a dot separated kernel parameter name", sysctl.Namespace)
+		}
+		if _, ok := sysctls[sysctl.Namespace]; ok {
+			return nil, fmt.Errorf("Invalid system controls: %s is set more than once", sysctl.Namespace)
+		}
+		if sysctl.Value == "" {
+			return nil, fmt.Errorf("Invalid system control %s: value must not be empty", sysctl.Namespace)
+		}
+		switch {
+		case strings.HasPrefix(sysctl.Namespace, "net."):
+			if networkMode == NetworkModeHost || strings.HasPrefix(networkMode, NetworkModeContainerPrefix) {
+				return nil, fmt.Errorf("System control %s is not supported for containers using the network stack of the instance or of another container", sysctl.Namespace)
+			}
+		case isIPCSysctl(sysctl.Namespace):
+			if ipcMode != "" {
+				return nil, fmt.Errorf("System control %s is not supported for containers using the IPC namespace of the instance or of another container", sysctl.Namespace)
+			}
+		default:
+			return nil, fmt.Errorf("Invalid system control %s: only parameters of the network and IPC namespaces may be set", sysctl.Namespace)
+		}
+		sysctls[sysctl.Namespace] = sysctl.Value
+	}
+	return sysctls, nil
+}
+
+func isIPCSysctl(name string) bool {
+	if strings.HasPrefix(name, "fs.mqueue.") {
+		return true
+	}
+	for _, ipcSysctl := range ipcSysctls {
+		if name == ipcSysctl {
+			return true
+		}
+	}
+	return false
+}
+
+// ulimitNames are the names of the resource limits docker can set
+var ulimitNames = []string{
+	"core", "cpu", "data", "fsize", "locks", "memlock", "msgqueue", "nice",
+	"nofile", "nproc", "rss", "rtprio", "rttime", "sigpending", "stack",
+}
+
+// dockerUlimits returns the resource limits of the container, failing if a
+// limit is unknown, set more than once or has a soft limit above its hard limit
+func (task *Task) dockerUlimits(container *Container) ([]docker.ULimit, error) {
+	if len(container.Ulimits) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]struct{})
+	ulimits := make([]docker.ULimit, 0, len(container.Ulimits))
+	for _, ulimit := range container.Ulimits {
+		if !validUlimitName(ulimit.Name) {
+			return nil, fmt.Errorf("Invalid ulimit name %q", ulimit.Name)
+		}
+		if _, ok := seen[ulimit.Name]; ok {
+			return nil, fmt.Errorf("Invalid ulimit %s: set more than once", ulimit.Name)
+		}
+		seen[ulimit.Name] = struct{}{}
+		if ulimit.SoftLimit > ulimit.HardLimit {
+			return nil, fmt.Errorf("Invalid ulimit %s: soft limit %d is above hard limit %d", ulimit.Name, ulimit.SoftLimit, ulimit.HardLimit)
+		}
+		ulimits = append(ulimits, docker.ULimit{Name: ulimit.Name, Soft: ulimit.SoftLimit, Hard: ulimit.HardLimit})
+	}
+	return ulimits, nil
+}
+
+func validUlimitName(name string) bool {
+	for _, allowed := range ulimitNames {
+		if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
 func (task *Task) dockerLinks(container *Container, dockerContainerMap map[string]*DockerContainer) ([]string, error) {
 	dockerLinkArr := make([]string, len(container.Links))
 	for i, link := range container.Links {
@@ -476,6 +1450,10 @@ func TaskFromACS(acsTask *ecsacs.Task, envelope *ecsacs.PayloadMessage) (*Task,
 	if err != nil {
 		return nil, err
 	}
+	err = task.ValidateDependsOn()
+	if err != nil {
+		return nil, err
+	}
 
 	if task.GetDesiredStatus() == TaskRunning && envelope.SeqNum != nil {
 		task.StartSequenceNumber = *envelope.SeqNum
@@ -22,10 +22,12 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/credentials/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func strptr(s string) *string { return &s }
@@ -167,6 +169,826 @@ func TestDockerHostConfigPortBinding(t *testing.T) {
 	assert.Equal(t, portBindingHostIP, bindings[0].HostIP, "Wrong hostIP")
 }
 
+func TestDockerHostConfigTmpfs(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name: "c1",
+				Tmpfs: []TmpfsMount{
+					TmpfsMount{ContainerPath: "/run", Size: "64m", MountOptions: []string{"noexec", "mode=1777"}},
+					TmpfsMount{ContainerPath: "/tmp"},
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{"/run": "noexec,mode=1777,size=64m", "/tmp": ""}, config.Tmpfs, "Wrong tmpfs mounts")
+}
+
+func TestDockerHostConfigReadonlyRootFilesystem(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name:                   "c1",
+				ReadonlyRootFilesystem: true,
+				Tmpfs: []TmpfsMount{
+					TmpfsMount{ContainerPath: "/tmp", Size: "64m"},
+				},
+			},
+			&Container{Name: "c2"},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, config.ReadonlyRootfs, "Expected a read only root filesystem")
+	assert.Equal(t, map[string]string{"/tmp": "size=64m"}, config.Tmpfs, "Wrong tmpfs mounts")
+
+	config, err = testTask.DockerHostConfig(testTask.Containers[1], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, config.ReadonlyRootfs, "Expected a writable root filesystem")
+}
+
+func TestDockerHostConfigInvalidTmpfs(t *testing.T) {
+	testCases := []struct {
+		mount         TmpfsMount
+		expectedError string
+	}{
+		{TmpfsMount{ContainerPath: "run", Size: "64m"}, "must be an absolute path"},
+		{TmpfsMount{ContainerPath: "/run", Size: "64 megabytes"}, "Invalid tmpfs size"},
+		{TmpfsMount{ContainerPath: "/run", Size: "-1m"}, "Invalid tmpfs size"},
+		{TmpfsMount{ContainerPath: "/run", MountOptions: []string{"bogus"}}, "Invalid tmpfs mount option"},
+		{TmpfsMount{ContainerPath: "/run", MountOptions: []string{"mode="}}, "Invalid tmpfs mount option"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", Tmpfs: []TmpfsMount{tc.mount}},
+			},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for tmpfs mount %+v", tc.mount)
+			continue
+		}
+		assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for tmpfs mount %+v", tc.mount)
+	}
+}
+
+func TestDockerHostConfigUlimits(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name: "c1",
+				Ulimits: []Ulimit{
+					Ulimit{Name: "nofile", SoftLimit: 1024, HardLimit: 4096},
+					Ulimit{Name: "core", SoftLimit: 0, HardLimit: 0},
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []docker.ULimit{
+		docker.ULimit{Name: "nofile", Soft: 1024, Hard: 4096},
+		docker.ULimit{Name: "core", Soft: 0, Hard: 0},
+	}
+	assert.Equal(t, expected, config.Ulimits, "Wrong ulimits")
+}
+
+func TestDockerHostConfigInvalidUlimits(t *testing.T) {
+	testCases := []struct {
+		ulimits       []Ulimit
+		expectedError string
+	}{
+		{[]Ulimit{Ulimit{Name: "nofile", SoftLimit: 4096, HardLimit: 1024}}, "soft limit 4096 is above hard limit 1024"},
+		{[]Ulimit{Ulimit{Name: "RLIMIT_NOFILE", SoftLimit: 1024, HardLimit: 1024}}, "Invalid ulimit name"},
+		{[]Ulimit{Ulimit{Name: "", SoftLimit: 1024, HardLimit: 1024}}, "Invalid ulimit name"},
+		{[]Ulimit{Ulimit{Name: "nproc", SoftLimit: 1, HardLimit: 1}, Ulimit{Name: "nproc", SoftLimit: 2, HardLimit: 2}}, "set more than once"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", Ulimits: tc.ulimits},
+			},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for ulimits %+v", tc.ulimits)
+			continue
+		}
+		assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for ulimits %+v", tc.ulimits)
+	}
+}
+
+func TestDockerHostConfigDNS(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name:             "c1",
+				DNSServers:       []string{"10.0.0.2", "fd00::2"},
+				DNSSearchDomains: []string{"example.com", "internal.example.com"},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"10.0.0.2", "fd00::2"}, config.DNS, "Wrong DNS servers")
+	assert.Equal(t, []string{"example.com", "internal.example.com"}, config.DNSSearch, "Wrong DNS search domains")
+}
+
+func TestDockerHostConfigInvalidDNSServers(t *testing.T) {
+	for _, server := range []string{"", "example.com", "10.0.0.256", "10.0.0.2:53"} {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", DNSServers: []string{"10.0.0.2", server}},
+			},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for DNS server %q", server)
+			continue
+		}
+		assert.Contains(t, err.Error(), "Invalid DNS server", "Wrong error for DNS server %q", server)
+	}
+}
+
+func TestDockerHostConfigExtraHosts(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name: "c1",
+				ExtraHosts: []HostEntry{
+					HostEntry{Hostname: "db.example.com", IPAddress: "10.0.0.5"},
+					HostEntry{Hostname: "cache", IPAddress: "fd00::5"},
+					HostEntry{Hostname: "db.example.com", IPAddress: "10.0.0.5"},
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"db.example.com:10.0.0.5", "cache:fd00::5"}, config.ExtraHosts, "Wrong extra hosts")
+}
+
+func TestDockerHostConfigInvalidExtraHosts(t *testing.T) {
+	testCases := []struct {
+		extraHosts    []HostEntry
+		expectedError string
+	}{
+		{[]HostEntry{HostEntry{Hostname: "db", IPAddress: "10.0.0.256"}}, "is not an IP address"},
+		{[]HostEntry{HostEntry{Hostname: "db", IPAddress: ""}}, "is not an IP address"},
+		{[]HostEntry{HostEntry{Hostname: "", IPAddress: "10.0.0.5"}}, "malformed hostname"},
+		{[]HostEntry{HostEntry{Hostname: "-db", IPAddress: "10.0.0.5"}}, "malformed hostname"},
+		{[]HostEntry{HostEntry{Hostname: "db:5432", IPAddress: "10.0.0.5"}}, "malformed hostname"},
+		{[]HostEntry{HostEntry{Hostname: "db", IPAddress: "10.0.0.5"}, HostEntry{Hostname: "db", IPAddress: "10.0.0.6"}}, "mapped to both 10.0.0.5 and 10.0.0.6"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", ExtraHosts: tc.extraHosts},
+			},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for extra hosts %+v", tc.extraHosts)
+			continue
+		}
+		assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for extra hosts %+v", tc.extraHosts)
+	}
+}
+
+func TestDockerHostConfigCapabilities(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name: "c1",
+				LinuxParameters: &LinuxParameters{Capabilities: &KernelCapabilities{
+					Add:  []string{"NET_ADMIN", "SYS_PTRACE"},
+					Drop: []string{"MKNOD"},
+				}},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"NET_ADMIN", "SYS_PTRACE"}, config.CapAdd, "Wrong added capabilities")
+	assert.Equal(t, []string{"MKNOD"}, config.CapDrop, "Wrong dropped capabilities")
+}
+
+func TestDockerHostConfigInvalidCapabilities(t *testing.T) {
+	testCases := []KernelCapabilities{
+		KernelCapabilities{Add: []string{"CAP_NET_ADMIN"}},
+		KernelCapabilities{Add: []string{"net_admin"}},
+		KernelCapabilities{Drop: []string{"NET_ADMIN", "BOGUS"}},
+		KernelCapabilities{Drop: []string{""}},
+	}
+	for _, capabilities := range testCases {
+		capabilities := capabilities
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", LinuxParameters: &LinuxParameters{Capabilities: &capabilities}},
+			},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for capabilities %+v", capabilities)
+			continue
+		}
+		assert.Contains(t, err.Error(), "Invalid capability", "Wrong error for capabilities %+v", capabilities)
+	}
+}
+
+func TestDockerHostConfigDevices(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name: "c1",
+				LinuxParameters: &LinuxParameters{Devices: []Device{
+					Device{HostPath: "/dev/fuse"},
+					Device{HostPath: "/dev/sdb", ContainerPath: "/dev/xvdb", Permissions: "r"},
+				}},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []docker.Device{
+		docker.Device{PathOnHost: "/dev/fuse", PathInContainer: "/dev/fuse", CgroupPermissions: "rwm"},
+		docker.Device{PathOnHost: "/dev/sdb", PathInContainer: "/dev/xvdb", CgroupPermissions: "r"},
+	}
+	assert.Equal(t, expected, config.Devices, "Wrong devices")
+}
+
+func TestDockerHostConfigInvalidDevices(t *testing.T) {
+	testCases := []struct {
+		device        Device
+		expectedError string
+	}{
+		{Device{HostPath: "dev/fuse"}, "Invalid device host path"},
+		{Device{HostPath: ""}, "Invalid device host path"},
+		{Device{HostPath: "/dev/fuse", ContainerPath: "fuse"}, "Invalid device container path"},
+		{Device{HostPath: "/dev/fuse", Permissions: "rx"}, "Invalid permissions"},
+		{Device{HostPath: "/dev/fuse", Permissions: "rr"}, "Invalid permissions"},
+		{Device{HostPath: "/dev/fuse", Permissions: "read"}, "Invalid permissions"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", LinuxParameters: &LinuxParameters{Devices: []Device{tc.device}}},
+			},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for device %+v", tc.device)
+			continue
+		}
+		assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for device %+v", tc.device)
+	}
+}
+
+func TestDockerHostConfigBlkio(t *testing.T) {
+	weight := int64(500)
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name: "c1",
+				LinuxParameters: &LinuxParameters{
+					BlkioWeight: &weight,
+					DeviceReadBps: []BlkioDeviceLimit{
+						BlkioDeviceLimit{Path: "/dev/xvda", Rate: 10485760},
+						BlkioDeviceLimit{Path: "/dev/xvdb", Rate: 1048576},
+					},
+					DeviceWriteBps: []BlkioDeviceLimit{
+						BlkioDeviceLimit{Path: "/dev/xvda", Rate: 5242880},
+					},
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(500), config.BlkioWeight, "Wrong block IO weight")
+	assert.Equal(t, []dockeriface.BlockLimit{
+		dockeriface.BlockLimit{Path: "/dev/xvda", Rate: 10485760},
+		dockeriface.BlockLimit{Path: "/dev/xvdb", Rate: 1048576},
+	}, config.BlkioDeviceReadBps, "Wrong read bandwidth limits")
+	assert.Equal(t, []dockeriface.BlockLimit{
+		dockeriface.BlockLimit{Path: "/dev/xvda", Rate: 5242880},
+	}, config.BlkioDeviceWriteBps, "Wrong write bandwidth limits")
+}
+
+func TestDockerHostConfigNoBlkio(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{Name: "c1", LinuxParameters: &LinuxParameters{Init: true}},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Zero(t, config.BlkioWeight, "Expected the docker default block IO weight")
+	assert.Empty(t, config.BlkioDeviceReadBps)
+	assert.Empty(t, config.BlkioDeviceWriteBps)
+}
+
+func TestDockerHostConfigInvalidBlkio(t *testing.T) {
+	weight := func(w int64) *int64 { return &w }
+	testCases := []struct {
+		name            string
+		linuxParameters LinuxParameters
+		expectedError   string
+	}{
+		{"weight too low", LinuxParameters{BlkioWeight: weight(9)}, "Invalid block IO weight"},
+		{"weight too high", LinuxParameters{BlkioWeight: weight(1001)}, "Invalid block IO weight"},
+		{"relative read path", LinuxParameters{DeviceReadBps: []BlkioDeviceLimit{{Path: "dev/xvda", Rate: 1}}}, "Invalid read bandwidth limit device path"},
+		{"empty write path", LinuxParameters{DeviceWriteBps: []BlkioDeviceLimit{{Path: "", Rate: 1}}}, "Invalid write bandwidth limit device path"},
+		{"unclean path", LinuxParameters{DeviceReadBps: []BlkioDeviceLimit{{Path: "/dev/../etc/passwd", Rate: 1}}}, "Invalid read bandwidth limit device path"},
+		{"zero rate", LinuxParameters{DeviceWriteBps: []BlkioDeviceLimit{{Path: "/dev/xvda", Rate: 0}}}, "Invalid write bandwidth limit 0"},
+		{"negative rate", LinuxParameters{DeviceReadBps: []BlkioDeviceLimit{{Path: "/dev/xvda", Rate: -1}}}, "Invalid read bandwidth limit -1"},
+		{"repeated device", LinuxParameters{DeviceReadBps: []BlkioDeviceLimit{{Path: "/dev/xvda", Rate: 1}, {Path: "/dev/xvda", Rate: 2}}}, "limited more than once"},
+	}
+	for _, tc := range testCases {
+		linuxParameters := tc.linuxParameters
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", LinuxParameters: &linuxParameters},
+			},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for %s", tc.name)
+			continue
+		}
+		assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for %s", tc.name)
+	}
+}
+
+func TestDockerHostConfigNetworkMode(t *testing.T) {
+	testCases := []struct {
+		taskMode      string
+		containerMode string
+		expected      string
+	}{
+		{"", "", ""},
+		{NetworkModeBridge, "", "bridge"},
+		{NetworkModeHost, "", "host"},
+		{NetworkModeNone, "", "none"},
+		{"backend_net", "", "backend_net"},
+		{NetworkModeBridge, NetworkModeNone, "none"},
+		{NetworkModeBridge, "container:pause", "container:dockerid-pause"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			NetworkMode: tc.taskMode,
+			Containers: []*Container{
+				&Container{Name: "c1", NetworkMode: tc.containerMode},
+				&Container{Name: "pause"},
+			},
+		}
+		config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err != nil {
+			t.Errorf("%q %q: unexpected error: %v", tc.taskMode, tc.containerMode, err)
+			continue
+		}
+		assert.Equal(t, tc.expected, config.NetworkMode, "Wrong network mode for %q %q", tc.taskMode, tc.containerMode)
+	}
+}
+
+func TestDockerHostConfigNetworkContainerNotCreated(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{Name: "c1", NetworkMode: "container:pause"},
+			&Container{Name: "pause"},
+		},
+	}
+
+	// The docker name is known as soon as the container is being created
+	containerMap := dockerMap(testTask)
+	containerMap["pause"].DockerId = ""
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], containerMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "container:dockername-pause", config.NetworkMode, "Wrong network mode")
+}
+
+func TestDockerHostConfigInvalidNetworkMode(t *testing.T) {
+	testCases := []struct {
+		taskMode      string
+		container     *Container
+		expectedError string
+	}{
+		{NetworkModeNone, &Container{Name: "c1", Ports: []PortBinding{PortBinding{ContainerPort: 80}}}, "Port mappings are not supported"},
+		{NetworkModeNone, &Container{Name: "c1", Links: []string{"pause:pause"}}, "Links are not supported"},
+		{NetworkModeHost, &Container{Name: "c1", Ports: []PortBinding{PortBinding{ContainerPort: 80, HostPort: 8080}}}, "the host port must be the container port"},
+		{NetworkModeHost, &Container{Name: "c1", Links: []string{"pause:pause"}}, "Links are not supported"},
+		{"", &Container{Name: "c1", NetworkMode: "container:c1"}, "can't join its own network stack"},
+		{"", &Container{Name: "c1", NetworkMode: "container:missing"}, "Network container not available"},
+		{"", &Container{Name: "c1", NetworkMode: "container:pause", Ports: []PortBinding{PortBinding{ContainerPort: 80}}}, "Port mappings and links are not supported"},
+		{"container:pause", &Container{Name: "c1"}, "Invalid task network mode"},
+		{"", &Container{Name: "c1", NetworkMode: "bad net"}, "Invalid network mode"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			NetworkMode: tc.taskMode,
+			Containers:  []*Container{tc.container, &Container{Name: "pause"}},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for network mode %q %q", tc.taskMode, tc.container.NetworkMode)
+			continue
+		}
+		assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for network mode %q %q", tc.taskMode, tc.container.NetworkMode)
+	}
+}
+
+func TestDockerHostConfigSysctls(t *testing.T) {
+	testTask := &Task{
+		IpcMode: NamespaceModeTask,
+		Containers: []*Container{
+			&Container{
+				Name: "c1",
+				LinuxParameters: &LinuxParameters{
+					SystemControls: []SystemControl{
+						SystemControl{Namespace: "net.core.somaxconn", Value: "1024"},
+						SystemControl{Namespace: "net.ipv4.tcp_keepalive_time", Value: "300"},
+						SystemControl{Namespace: "kernel.shmmax", Value: "68719476736"},
+						SystemControl{Namespace: "fs.mqueue.msg_max", Value: "64"},
+					},
+				},
+			},
+			&Container{Name: "c2"},
+		},
+	}
+
+	containerMap := dockerMap(testTask)
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], containerMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{
+		"net.core.somaxconn":          "1024",
+		"net.ipv4.tcp_keepalive_time": "300",
+		"kernel.shmmax":               "68719476736",
+		"fs.mqueue.msg_max":           "64",
+	}, config.Sysctls, "Wrong sysctls")
+
+	config, err = testTask.DockerHostConfig(testTask.Containers[1], containerMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, config.Sysctls)
+}
+
+func TestDockerHostConfigInvalidSysctls(t *testing.T) {
+	sysctls := func(controls ...SystemControl) *LinuxParameters {
+		return &LinuxParameters{SystemControls: controls}
+	}
+	testCases := []struct {
+		name          string
+		task          *Task
+		container     *Container
+		expectedError string
+	}{
+		{"no dot", &Task{}, &Container{LinuxParameters: sysctls(SystemControl{"somaxconn", "1"})}, "Invalid system control"},
+		{"bad character", &Task{}, &Container{LinuxParameters: sysctls(SystemControl{"net.core.somaxconn=1", "1"})}, "Invalid system control"},
+		{"empty value", &Task{}, &Container{LinuxParameters: sysctls(SystemControl{"net.core.somaxconn", ""})}, "value must not be empty"},
+		{"repeated", &Task{}, &Container{LinuxParameters: sysctls(SystemControl{"net.core.somaxconn", "1"}, SystemControl{"net.core.somaxconn", "2"})}, "set more than once"},
+		{"not namespaced", &Task{}, &Container{LinuxParameters: sysctls(SystemControl{"vm.swappiness", "0"})}, "only parameters of the network and IPC namespaces"},
+		{"kernel not namespaced", &Task{}, &Container{LinuxParameters: sysctls(SystemControl{"kernel.pid_max", "1"})}, "only parameters of the network and IPC namespaces"},
+		{"host network", &Task{NetworkMode: NetworkModeHost}, &Container{LinuxParameters: sysctls(SystemControl{"net.core.somaxconn", "1"})}, "network stack of the instance or of another container"},
+		{"container network", &Task{}, &Container{NetworkMode: "container:c1", LinuxParameters: sysctls(SystemControl{"net.core.somaxconn", "1"})}, "network stack of the instance or of another container"},
+		{"host IPC", &Task{IpcMode: NamespaceModeHost}, &Container{LinuxParameters: sysctls(SystemControl{"kernel.shmmax", "1"})}, "IPC namespace of the instance or of another container"},
+		{"task IPC", &Task{IpcMode: NamespaceModeTask}, &Container{LinuxParameters: sysctls(SystemControl{"fs.mqueue.msg_max", "1"})}, "IPC namespace of the instance or of another container"},
+	}
+	for _, tc := range testCases {
+		tc.container.Name = "c2"
+		tc.task.Containers = []*Container{&Container{Name: "c1"}, tc.container}
+		_, err := tc.task.DockerHostConfig(tc.container, dockerMap(tc.task))
+		if err == nil {
+			t.Errorf("Expected error for %s", tc.name)
+			continue
+		}
+		assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for %s", tc.name)
+	}
+
+	// Containers with a namespace of their own may set its parameters
+	testTask := &Task{
+		NetworkMode: NetworkModeHost,
+		Containers: []*Container{
+			&Container{Name: "c1", LinuxParameters: sysctls(SystemControl{"kernel.msgmax", "65536"})},
+		},
+	}
+	_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	assert.Nil(t, err, "Expected IPC parameters to be allowed with the host network")
+}
+
+func TestDockerHostConfigNamespaceModes(t *testing.T) {
+	testCases := []struct {
+		mode        string
+		expectedC1  string
+		expectedC2  string
+		description string
+	}{
+		{"", "", "", "default"},
+		{NamespaceModeNone, "", "", "none"},
+		{NamespaceModeHost, "host", "host", "host"},
+		{NamespaceModeTask, "", "container:dockerid-c1", "task"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			PidMode: tc.mode,
+			IpcMode: tc.mode,
+			Containers: []*Container{
+				&Container{Name: "c1"},
+				&Container{Name: "c2"},
+			},
+		}
+		containerMap := dockerMap(testTask)
+		config1, err := testTask.DockerHostConfig(testTask.Containers[0], containerMap)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.description, err)
+			continue
+		}
+		config2, err := testTask.DockerHostConfig(testTask.Containers[1], containerMap)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.description, err)
+			continue
+		}
+		assert.Equal(t, tc.expectedC1, config1.PidMode, "Wrong PID mode of c1 for %s", tc.description)
+		assert.Equal(t, tc.expectedC1, config1.IpcMode, "Wrong IPC mode of c1 for %s", tc.description)
+		assert.Equal(t, tc.expectedC2, config2.PidMode, "Wrong PID mode of c2 for %s", tc.description)
+		assert.Equal(t, tc.expectedC2, config2.IpcMode, "Wrong IPC mode of c2 for %s", tc.description)
+	}
+}
+
+func TestDockerHostConfigInvalidNamespaceModes(t *testing.T) {
+	shmSize := int64(64)
+	testCases := []struct {
+		task          *Task
+		expectedError string
+	}{
+		{&Task{PidMode: "shared"}, "Invalid PID mode"},
+		{&Task{IpcMode: "container:c1"}, "Invalid IPC mode"},
+		{&Task{IpcMode: NamespaceModeHost}, "Shared memory size is not supported"},
+		{&Task{IpcMode: NamespaceModeTask}, "Shared memory size is not supported"},
+	}
+	for _, tc := range testCases {
+		tc.task.Containers = []*Container{
+			&Container{Name: "c1"},
+			&Container{Name: "c2", LinuxParameters: &LinuxParameters{ShmSize: &shmSize}},
+		}
+		_, err := tc.task.DockerHostConfig(tc.task.Containers[1], dockerMap(tc.task))
+		if assert.Error(t, err, "Expected error for PID mode %q and IPC mode %q", tc.task.PidMode, tc.task.IpcMode) {
+			assert.Contains(t, err.Error(), tc.expectedError)
+		}
+	}
+
+	// The task's namespace container may set the size of its shared memory
+	testTask := &Task{
+		IpcMode: NamespaceModeTask,
+		Containers: []*Container{
+			&Container{Name: "c1", LinuxParameters: &LinuxParameters{ShmSize: &shmSize}},
+			&Container{Name: "c2"},
+		},
+	}
+	_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func TestDockerHostConfigMemoryLimits(t *testing.T) {
+	testCases := []struct {
+		container           *Container
+		expectedReservation int64
+		expectedSwap        int64
+	}{
+		{&Container{Name: "c"}, 0, 0},
+		{&Container{Name: "c", MemoryReservation: 64}, 64 * 1024 * 1024, 0},
+		{&Container{Name: "c", Memory: 128, MemoryReservation: 128}, 128 * 1024 * 1024, 0},
+		{&Container{Name: "c", Memory: 128, MemorySwap: 128}, 0, 128 * 1024 * 1024},
+		{&Container{Name: "c", Memory: 128, MemoryReservation: 64, MemorySwap: 512}, 64 * 1024 * 1024, 512 * 1024 * 1024},
+		{&Container{Name: "c", Memory: 128, MemorySwap: -1}, 0, -1},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{Containers: []*Container{tc.container}}
+		hostConfig, err := testTask.DockerHostConfig(tc.container, dockerMap(testTask))
+		if err != nil {
+			t.Errorf("Unexpected error for %+v: %v", tc.container, err)
+			continue
+		}
+		assert.Equal(t, tc.expectedReservation, hostConfig.MemoryReservation, "Wrong memory reservation for %+v", tc.container)
+		assert.Equal(t, tc.expectedSwap, hostConfig.MemorySwap, "Wrong memory swap for %+v", tc.container)
+	}
+}
+
+func TestDockerHostConfigInvalidMemoryLimits(t *testing.T) {
+	testCases := []struct {
+		container     *Container
+		expectedError string
+	}{
+		{&Container{Name: "c", Memory: 128, MemoryReservation: 256}, "Invalid memory reservation 256: must not be above the memory limit of 128 MiB"},
+		{&Container{Name: "c", Memory: 128, MemorySwap: 64}, "Invalid memory swap 64: must not be below the memory limit of 128 MiB"},
+		{&Container{Name: "c", MemorySwap: 256}, "Invalid memory swap 256: a memory limit is required to limit swap"},
+		{&Container{Name: "c", Memory: 128, MemorySwap: -2}, "Invalid memory swap -2: must be positive or -1 for unlimited swap"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{Containers: []*Container{tc.container}}
+		_, err := testTask.DockerHostConfig(tc.container, dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for %+v", tc.container)
+			continue
+		}
+		assert.Equal(t, tc.expectedError, err.Error())
+	}
+}
+
+func TestPostUnmarshalTaskNamespaceSharing(t *testing.T) {
+	testTask := &Task{
+		PidMode: NamespaceModeTask,
+		Containers: []*Container{
+			&Container{Name: "c1"},
+			&Container{Name: "c2"},
+			&Container{Name: "c3"},
+		},
+	}
+	testTask.PostUnmarshalTask(nil)
+
+	assert.Empty(t, testTask.Containers[0].RunDependencies)
+	assert.Equal(t, []string{"c1"}, testTask.Containers[1].RunDependencies)
+	assert.Equal(t, []string{"c1"}, testTask.Containers[2].RunDependencies)
+}
+
+func TestDockerHostConfigRestartPolicy(t *testing.T) {
+	testCases := []struct {
+		policy   string
+		expected docker.RestartPolicy
+	}{
+		{"", docker.RestartPolicy{}},
+		{"no", docker.RestartPolicy{Name: "no"}},
+		{"always", docker.RestartPolicy{Name: "always"}},
+		{"unless-stopped", docker.RestartPolicy{Name: "unless-stopped"}},
+		{"on-failure", docker.RestartPolicy{Name: "on-failure"}},
+		{"on-failure:5", docker.RestartPolicy{Name: "on-failure", MaximumRetryCount: 5}},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{Containers: []*Container{&Container{Name: "c1", RestartPolicy: tc.policy}}}
+		config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.policy, err)
+			continue
+		}
+		assert.Equal(t, tc.expected, config.RestartPolicy, "Wrong restart policy for %q", tc.policy)
+	}
+}
+
+func TestDockerHostConfigInvalidRestartPolicy(t *testing.T) {
+	for _, policy := range []string{"sometimes", "on-failure:", "on-failure:-1", "on-failure:many", "always:3"} {
+		testTask := &Task{Containers: []*Container{&Container{Name: "c1", RestartPolicy: policy}}}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if assert.Error(t, err, "Expected error for restart policy %q", policy) {
+			assert.Contains(t, err.Error(), "Invalid restart policy", "Wrong error for restart policy %q", policy)
+		}
+	}
+}
+
+func TestDockerHostConfigSecurityOptions(t *testing.T) {
+	testCases := []struct {
+		options  []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"seccomp:unconfined"}, []string{"seccomp=unconfined"}},
+		{[]string{"seccomp=unconfined"}, []string{"seccomp=unconfined"}},
+		{[]string{"seccomp:restricted.v2"}, []string{"seccomp=restricted.v2"}},
+		{[]string{`seccomp:{"defaultAction":"SCMP_ACT_ERRNO"}`}, []string{`seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`}},
+		{[]string{"apparmor:docker-nginx"}, []string{"apparmor=docker-nginx"}},
+		{[]string{"apparmor=/usr/sbin/nginx"}, []string{"apparmor=/usr/sbin/nginx"}},
+		{[]string{"seccomp:restricted", "apparmor:unconfined"}, []string{"seccomp=restricted", "apparmor=unconfined"}},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{Containers: []*Container{&Container{Name: "c1", DockerSecurityOptions: tc.options}}}
+		config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.options, err)
+			continue
+		}
+		assert.Equal(t, tc.expected, config.SecurityOpt, "Wrong security options for %q", tc.options)
+	}
+}
+
+func TestDockerHostConfigInvalidSecurityOptions(t *testing.T) {
+	testCases := []struct {
+		options       []string
+		expectedError string
+	}{
+		{[]string{`seccomp:{"defaultAction":}`}, "Invalid inline seccomp profile"},
+		{[]string{`seccomp:{"defaultAction":"SCMP_ACT_ERRNO"`}, "Invalid inline seccomp profile"},
+		{[]string{"seccomp:../../etc/profile"}, "Invalid seccomp profile"},
+		{[]string{"seccomp:"}, "Invalid seccomp profile"},
+		{[]string{"apparmor:"}, "Invalid AppArmor profile"},
+		{[]string{"apparmor:docker nginx"}, "Invalid AppArmor profile"},
+		{[]string{"label:disable"}, "only seccomp:<profile> and apparmor:<profile>"},
+		{[]string{"no-new-privileges"}, "only seccomp:<profile> and apparmor:<profile>"},
+		{[]string{"seccomp:unconfined", "seccomp:restricted"}, "seccomp may only be set once"},
+		{[]string{"apparmor:one", "apparmor:two"}, "apparmor may only be set once"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{Containers: []*Container{&Container{Name: "c1", DockerSecurityOptions: tc.options}}}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if assert.Error(t, err, "Expected error for security options %q", tc.options) {
+			assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for security options %q", tc.options)
+		}
+	}
+}
+
+func TestDockerHostConfigShmSize(t *testing.T) {
+	shmSize := int64(256)
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name:            "c1",
+				LinuxParameters: &LinuxParameters{ShmSize: &shmSize},
+				Environment:     map[string]string{"ECS_SHM_SIZE": "1024"},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(256*1024*1024), config.ShmSize, "Wrong shared memory size")
+}
+
+func TestDockerHostConfigInit(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{Name: "c1", LinuxParameters: &LinuxParameters{Init: true}},
+			&Container{Name: "c2"},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, config.Init, "Expected an init process")
+
+	config, err = testTask.DockerHostConfig(testTask.Containers[1], dockerMap(testTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, config.Init, "Expected no init process")
+}
+
+func TestDockerHostConfigInvalidShmSize(t *testing.T) {
+	for _, shmSize := range []int64{0, -64} {
+		size := shmSize
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", LinuxParameters: &LinuxParameters{ShmSize: &size}},
+			},
+		}
+		_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask))
+		if err == nil {
+			t.Errorf("Expected error for shared memory size %d", shmSize)
+			continue
+		}
+		assert.Contains(t, err.Error(), "must be positive", "Wrong error for shared memory size %d", shmSize)
+	}
+}
+
 func TestDockerHostConfigVolumesFrom(t *testing.T) {
 	testTask := &Task{
 		Containers: []*Container{
@@ -230,7 +1052,7 @@ func TestDockerHostConfigRawConfig(t *testing.T) {
 
 	expectedOutput := rawHostConfigInput
 
-	assertSetStructFieldsEqual(t, expectedOutput, *config)
+	assertSetStructFieldsEqual(t, expectedOutput, *config.HostConfig)
 }
 
 func TestDockerHostConfigRawConfigMerging(t *testing.T) {
@@ -281,7 +1103,7 @@ func TestDockerHostConfigRawConfigMerging(t *testing.T) {
 		VolumesFrom: []string{"dockername-c2"},
 	}
 
-	assertSetStructFieldsEqual(t, expected, *hostConfig)
+	assertSetStructFieldsEqual(t, expected, *hostConfig.HostConfig)
 }
 
 func TestBadDockerHostConfigRawConfig(t *testing.T) {
@@ -440,6 +1262,153 @@ func TestBadDockerConfigRawConfig(t *testing.T) {
 	}
 }
 
+func TestDockerConfigUserAndWorkingDirectory(t *testing.T) {
+	for _, user := range []string{"1000", "1000:1000", "nginx", "nginx:www-data", "nginx:33", "0:root"} {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", User: user, WorkingDirectory: "/srv/app"},
+			},
+		}
+		config, err := testTask.DockerConfig(testTask.Containers[0])
+		if !assert.Nil(t, err, "Unexpected error for user %q", user) {
+			continue
+		}
+		assert.Equal(t, user, config.User, "Wrong user")
+		assert.Equal(t, "/srv/app", config.WorkingDir, "Wrong working directory")
+	}
+}
+
+func TestDockerConfigStopSignal(t *testing.T) {
+	testCases := []struct {
+		signal   string
+		expected string
+	}{
+		{"", ""},
+		{"SIGTERM", "SIGTERM"},
+		{"TERM", "SIGTERM"},
+		{"sigquit", "SIGQUIT"},
+		{"usr1", "SIGUSR1"},
+		{"15", "15"},
+		{"64", "64"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", StopSignal: tc.signal},
+			},
+		}
+		config, err := testTask.DockerConfig(testTask.Containers[0])
+		if !assert.Nil(t, err, "Unexpected error for stop signal %q", tc.signal) {
+			continue
+		}
+		assert.Equal(t, tc.expected, config.StopSignal, "Wrong stop signal for %q", tc.signal)
+	}
+}
+
+func TestDockerConfigInvalidStopSignal(t *testing.T) {
+	testCases := []struct {
+		signal        string
+		expectedError string
+	}{
+		{"SIGFOO", `Invalid stop signal "SIGFOO": unknown signal`},
+		{"TERM2", `Invalid stop signal "TERM2": unknown signal`},
+		{"SIG", `Invalid stop signal "SIG": unknown signal`},
+		{"0", `Invalid stop signal "0": signal numbers range from 1 to 64`},
+		{"65", `Invalid stop signal "65": signal numbers range from 1 to 64`},
+		{"-9", `Invalid stop signal "-9": signal numbers range from 1 to 64`},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", StopSignal: tc.signal},
+			},
+		}
+		_, err := testTask.DockerConfig(testTask.Containers[0])
+		if assert.NotNil(t, err, "Expected error for stop signal %q", tc.signal) {
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+	}
+}
+
+func TestDockerConfigStopSignalOverridesRawConfig(t *testing.T) {
+	rawConfig := `{"StopSignal":"SIGINT"}`
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{Name: "c1", DockerConfig: DockerConfig{Config: &rawConfig}},
+			&Container{Name: "c2", StopSignal: "SIGQUIT", DockerConfig: DockerConfig{Config: &rawConfig}},
+		},
+	}
+	config, err := testTask.DockerConfig(testTask.Containers[0])
+	if assert.Nil(t, err) {
+		assert.Equal(t, "SIGINT", config.StopSignal, "The raw config's stop signal should be kept")
+	}
+	config, err = testTask.DockerConfig(testTask.Containers[1])
+	if assert.Nil(t, err) {
+		assert.Equal(t, "SIGQUIT", config.StopSignal, "The definition's stop signal should take precedence")
+	}
+}
+
+func TestDockerConfigUserAndWorkingDirectoryOverrideRawConfig(t *testing.T) {
+	testTask := &Task{
+		Containers: []*Container{
+			&Container{
+				Name:             "c1",
+				User:             "nginx",
+				WorkingDirectory: "/srv/app",
+				DockerConfig: DockerConfig{
+					Config: strptr(`{"User": "root", "WorkingDir": "/"}`),
+				},
+			},
+			&Container{
+				Name: "c2",
+				DockerConfig: DockerConfig{
+					Config: strptr(`{"User": "root", "WorkingDir": "/"}`),
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerConfig(testTask.Containers[0])
+	require.Nil(t, err)
+	assert.Equal(t, "nginx", config.User, "The user of the definition should take precedence")
+	assert.Equal(t, "/srv/app", config.WorkingDir, "The working directory of the definition should take precedence")
+
+	config, err = testTask.DockerConfig(testTask.Containers[1])
+	require.Nil(t, err)
+	assert.Equal(t, "root", config.User, "The user of the docker config should be kept")
+	assert.Equal(t, "/", config.WorkingDir, "The working directory of the docker config should be kept")
+}
+
+func TestDockerConfigInvalidUserAndWorkingDirectory(t *testing.T) {
+	testCases := []struct {
+		user             string
+		workingDirectory string
+		expectedError    string
+	}{
+		{"nginx:www-data:extra", "", "expected user or user:group"},
+		{":1000", "", "malformed user or group"},
+		{"1000:", "", "malformed user or group"},
+		{"ngi nx", "", "malformed user or group"},
+		{"-nginx", "", "malformed user or group"},
+		{"99999999999", "", "out of range"},
+		{"", "srv/app", "must be an absolute path"},
+		{"", "/srv/app\n", "contains control characters"},
+	}
+	for _, tc := range testCases {
+		testTask := &Task{
+			Containers: []*Container{
+				&Container{Name: "c1", User: tc.user, WorkingDirectory: tc.workingDirectory},
+			},
+		}
+		_, err := testTask.DockerConfig(testTask.Containers[0])
+		if err == nil {
+			t.Errorf("Expected error for user %q and working directory %q", tc.user, tc.workingDirectory)
+			continue
+		}
+		assert.Contains(t, err.Error(), tc.expectedError, "Wrong error for user %q and working directory %q", tc.user, tc.workingDirectory)
+	}
+}
+
 func TestGetCredentialsEndpointWhenCredentialsAreSet(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -578,10 +1547,11 @@ func TestTaskFromACS(t *testing.T) {
 	// Testing type conversions, bleh. At least the type conversion itself
 	// doesn't look this messy.
 	taskFromAcs := ecsacs.Task{
-		Arn:           strptr("myArn"),
-		DesiredStatus: strptr("RUNNING"),
-		Family:        strptr("myFamily"),
-		Version:       strptr("1"),
+		Arn:                     strptr("myArn"),
+		DesiredStatus:           strptr("RUNNING"),
+		FailOnEssentialExitCode: boolptr(true),
+		Family:                  strptr("myFamily"),
+		Version:                 strptr("1"),
 		Containers: []*ecsacs.Container{
 			&ecsacs.Container{
 				Name:        strptr("myName"),
@@ -600,7 +1570,25 @@ func TestTaskFromACS(t *testing.T) {
 						SourceVolume:  strptr("sourceVolume"),
 					},
 				},
-				Overrides: strptr(`{"command":["a","b","c"]}`),
+				Overrides:              strptr(`{"command":["a","b","c"]}`),
+				ReadonlyRootFilesystem: boolptr(true),
+				MemoryReservation:      intptr(50),
+				MemorySwap:             intptr(200),
+				User:                   strptr("1000:1000"),
+				WorkingDirectory:       strptr("/srv/app"),
+				Tmpfs: []*ecsacs.Tmpfs{
+					&ecsacs.Tmpfs{
+						ContainerPath: strptr("/run"),
+						MountOptions:  []*string{strptr("noexec")},
+						Size:          strptr("64m"),
+					},
+				},
+				DependsOn: []*ecsacs.ContainerDependency{
+					&ecsacs.ContainerDependency{
+						ContainerName: strptr("myName2"),
+						Condition:     strptr("HEALTHY"),
+					},
+				},
 				PortMappings: []*ecsacs.PortMapping{
 					&ecsacs.PortMapping{
 						HostPort:      intptr(800),
@@ -620,6 +1608,10 @@ func TestTaskFromACS(t *testing.T) {
 					Version:    strptr("version string"),
 				},
 			},
+			&ecsacs.Container{
+				Name:  strptr("myName2"),
+				Image: strptr("image2:tag"),
+			},
 		},
 		Volumes: []*ecsacs.Volume{
 			&ecsacs.Volume{
@@ -639,10 +1631,11 @@ func TestTaskFromACS(t *testing.T) {
 		},
 	}
 	expectedTask := &Task{
-		Arn:           "myArn",
-		DesiredStatus: TaskRunning,
-		Family:        "myFamily",
-		Version:       "1",
+		Arn:                     "myArn",
+		DesiredStatus:           TaskRunning,
+		FailOnEssentialExitCode: true,
+		Family:                  "myFamily",
+		Version:                 "1",
 		Containers: []*Container{
 			&Container{
 				Name:        "myName",
@@ -661,6 +1654,24 @@ func TestTaskFromACS(t *testing.T) {
 						SourceVolume:  "sourceVolume",
 					},
 				},
+				ReadonlyRootFilesystem: true,
+				MemoryReservation:      50,
+				MemorySwap:             200,
+				User:                   "1000:1000",
+				WorkingDirectory:       "/srv/app",
+				Tmpfs: []TmpfsMount{
+					TmpfsMount{
+						ContainerPath: "/run",
+						MountOptions:  []string{"noexec"},
+						Size:          "64m",
+					},
+				},
+				DependsOn: []DependsOn{
+					DependsOn{
+						Container: "myName2",
+						Condition: DependencyConditionHealthy,
+					},
+				},
 				Overrides: ContainerOverrides{
 					Command: &[]string{"a", "b", "c"},
 				},
@@ -683,6 +1694,10 @@ func TestTaskFromACS(t *testing.T) {
 					Version:    strptr("version string"),
 				},
 			},
+			&Container{
+				Name:  "myName2",
+				Image: "image2:tag",
+			},
 		},
 		Volumes: []TaskVolume{
 			TaskVolume{
@@ -786,6 +1801,285 @@ func TestTaskUpdateKnownStatusToPendingWithEssentialContainerStopped(t *testing.
 	assert.Equal(t, TaskCreated, testTask.GetKnownStatus(), "task status should be updated when essential containers are stopped while not all the other containers are running")
 }
 
+// TestTaskUpdateKnownStatusWaitsOnHealthCheck tests that the task status isn't
+// changed to running while an essential container's health check is starting
+func TestTaskUpdateKnownStatusWaitsOnHealthCheck(t *testing.T) {
+	testCases := []struct {
+		name            string
+		essentialHealth ContainerHealthStatus
+		otherHealth     ContainerHealthStatus
+		expectedStatus  TaskStatus
+	}{
+		{"no health checks", ContainerHealthUnknown, ContainerHealthUnknown, TaskRunning},
+		{"essential starting", ContainerHealthStarting, ContainerHealthUnknown, TaskStatusNone},
+		{"essential healthy", ContainerHealthy, ContainerHealthUnknown, TaskRunning},
+		{"essential unhealthy", ContainerUnhealthy, ContainerHealthUnknown, TaskRunning},
+		{"non-essential starting", ContainerHealthy, ContainerHealthStarting, TaskRunning},
+	}
+
+	for _, tc := range testCases {
+		testTask := &Task{
+			KnownStatus: TaskCreated,
+			Containers: []*Container{
+				&Container{
+					KnownStatus: ContainerRunning,
+					Essential:   true,
+					Health:      tc.essentialHealth,
+				},
+				&Container{
+					KnownStatus: ContainerRunning,
+					Health:      tc.otherHealth,
+				},
+			},
+		}
+
+		newStatus := testTask.updateTaskKnownStatus()
+		assert.Equal(t, tc.expectedStatus, newStatus, tc.name)
+	}
+}
+
+func TestTaskValidate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		taskCpu       uint
+		taskMemory    uint
+		containers    []*Container
+		expectedError bool
+	}{
+		{"unset limits", 0, 0, []*Container{{Cpu: 1024, Memory: 4096}}, false},
+		{"within limits", 512, 1024, []*Container{{Cpu: 256, Memory: 512}, {Cpu: 256, Memory: 512}}, false},
+		{"cpu exceeded", 512, 1024, []*Container{{Cpu: 256, Memory: 256}, {Cpu: 512, Memory: 256}}, true},
+		{"memory exceeded", 512, 1024, []*Container{{Cpu: 128, Memory: 1000}, {Cpu: 128, Memory: 100}}, true},
+		{"memory only limit", 0, 1024, []*Container{{Cpu: 4096, Memory: 512}}, false},
+		{"memory only limit exceeded", 0, 256, []*Container{{Cpu: 10, Memory: 512}}, true},
+		{"cpu only limit", 256, 0, []*Container{{Cpu: 256, Memory: 8192}}, false},
+		{"cpu only limit exceeded", 256, 0, []*Container{{Cpu: 257, Memory: 1}}, true},
+		{"internal containers not counted", 0, 256, []*Container{{Memory: 256}, {Memory: 256, IsInternal: true}}, false},
+		{"memory reservation counted", 0, 256, []*Container{{Memory: 128}, {MemoryReservation: 256}}, true},
+		{"memory reservation under limit", 0, 256, []*Container{{Memory: 128, MemoryReservation: 64}, {MemoryReservation: 128}}, false},
+	}
+
+	for _, tc := range testCases {
+		task := &Task{Cpu: tc.taskCpu, Memory: tc.taskMemory, Containers: tc.containers}
+		err := task.Validate()
+		if !tc.expectedError {
+			assert.NoError(t, err, tc.name)
+			continue
+		}
+		if assert.Error(t, err, tc.name) {
+			_, ok := err.(*TaskResourceError)
+			assert.True(t, ok, "%s: expected a *TaskResourceError, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidateDependsOn(t *testing.T) {
+	dependsOn := func(name string, condition string) []DependsOn {
+		return []DependsOn{{Container: name, Condition: condition}}
+	}
+	testCases := []struct {
+		name          string
+		containers    []*Container
+		expectedError string
+	}{
+		{"no dependencies", []*Container{{Name: "a"}, {Name: "b"}}, ""},
+		{"linear chain", []*Container{
+			{Name: "a", DependsOn: dependsOn("b", DependencyConditionStart)},
+			{Name: "b", DependsOn: dependsOn("c", DependencyConditionHealthy)},
+			{Name: "c", DependsOn: dependsOn("d", DependencyConditionSuccess)},
+			{Name: "d"},
+		}, ""},
+		{"shared dependency", []*Container{
+			{Name: "a", DependsOn: []DependsOn{{"b", DependencyConditionStart}, {"c", DependencyConditionStart}}},
+			{Name: "b", DependsOn: dependsOn("c", DependencyConditionStart)},
+			{Name: "c"},
+		}, ""},
+		{"unknown container", []*Container{{Name: "a", DependsOn: dependsOn("b", DependencyConditionStart)}}, "depends on unknown container b"},
+		{"invalid condition", []*Container{{Name: "a", DependsOn: dependsOn("b", "STOP")}, {Name: "b"}}, "invalid condition"},
+		{"self dependency", []*Container{{Name: "a", DependsOn: dependsOn("a", DependencyConditionStart)}}, "Dependency cycle between containers: a -> a"},
+		{"cycle", []*Container{
+			{Name: "a", DependsOn: dependsOn("b", DependencyConditionStart)},
+			{Name: "b", DependsOn: dependsOn("c", DependencyConditionHealthy)},
+			{Name: "c", DependsOn: dependsOn("b", DependencyConditionSuccess)},
+		}, "Dependency cycle between containers: b -> c -> b"},
+	}
+
+	for _, tc := range testCases {
+		task := &Task{Containers: tc.containers}
+		err := task.ValidateDependsOn()
+		if tc.expectedError == "" {
+			assert.NoError(t, err, tc.name)
+			continue
+		}
+		if assert.Error(t, err, tc.name) {
+			_, ok := err.(*TaskDependencyError)
+			assert.True(t, ok, "%s: expected a *TaskDependencyError, got %v", tc.name, err)
+			assert.Contains(t, err.Error(), tc.expectedError, tc.name)
+		}
+	}
+}
+
+func TestEssentialContainerExitReason(t *testing.T) {
+	intptr := func(i int) *int { return &i }
+	testCases := []struct {
+		name           string
+		failOnExitCode bool
+		exitCode       *int
+		essential      bool
+		expectedReason string
+	}{
+		{"zero exit", true, intptr(0), true, ""},
+		{"non-zero exit", true, intptr(2), true, "Essential container in task exited: app exited with code 2"},
+		{"non-zero exit of a non-essential container", true, intptr(2), false, ""},
+		{"no exit code", true, nil, true, ""},
+		{"failures not reported", false, intptr(2), true, ""},
+	}
+	for _, tc := range testCases {
+		task := &Task{
+			FailOnEssentialExitCode: tc.failOnExitCode,
+			Containers: []*Container{
+				{Name: "sidecar", Essential: false, KnownStatus: ContainerStopped, KnownExitCode: intptr(0)},
+				{Name: "app", Essential: tc.essential, KnownStatus: ContainerStopped, KnownExitCode: tc.exitCode},
+			},
+		}
+		assert.Equal(t, tc.expectedReason, task.EssentialContainerExitReason(), tc.name)
+	}
+}
+
+func TestTaskFromACSDependencyCycle(t *testing.T) {
+	taskFromAcs := ecsacs.Task{
+		Arn:           strptr("myArn"),
+		DesiredStatus: strptr("RUNNING"),
+		Containers: []*ecsacs.Container{
+			&ecsacs.Container{
+				Name: strptr("a"),
+				DependsOn: []*ecsacs.ContainerDependency{
+					&ecsacs.ContainerDependency{ContainerName: strptr("b"), Condition: strptr("START")},
+				},
+			},
+			&ecsacs.Container{
+				Name: strptr("b"),
+				DependsOn: []*ecsacs.ContainerDependency{
+					&ecsacs.ContainerDependency{ContainerName: strptr("a"), Condition: strptr("HEALTHY")},
+				},
+			},
+		},
+	}
+	_, err := TaskFromACS(&taskFromAcs, &ecsacs.PayloadMessage{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Dependency cycle between containers: a -> b -> a")
+	}
+}
+
+func TestTaskFromACSDockerVolume(t *testing.T) {
+	taskFromAcs := ecsacs.Task{
+		Arn:           strptr("arn:aws:ecs:us-west-2:123456789012:task/abc-123"),
+		DesiredStatus: strptr("RUNNING"),
+		Family:        strptr("myFamily"),
+		Version:       strptr("1"),
+		Containers: []*ecsacs.Container{
+			&ecsacs.Container{
+				Name:        strptr("c"),
+				MountPoints: []*ecsacs.MountPoint{&ecsacs.MountPoint{SourceVolume: strptr("shared"), ContainerPath: strptr("/data")}},
+			},
+		},
+		Volumes: []*ecsacs.Volume{
+			&ecsacs.Volume{
+				Name: strptr("shared"),
+				DockerVolumeConfiguration: &ecsacs.DockerVolumeConfiguration{
+					Driver:     strptr("local"),
+					DriverOpts: map[string]*string{"type": strptr("tmpfs")},
+					Labels:     map[string]*string{"team": strptr("storage")},
+				},
+			},
+		},
+	}
+	task, err := TaskFromACS(&taskFromAcs, &ecsacs.PayloadMessage{})
+	require.NoError(t, err)
+	task.PostUnmarshalTask(nil)
+
+	expected := &DockerVolume{
+		DockerName: "ecs-myFamily-1-shared-abc-123",
+		Driver:     "local",
+		DriverOpts: map[string]string{"type": "tmpfs"},
+		Labels:     map[string]string{"team": "storage"},
+	}
+	volume, ok := task.HostVolumeByName("shared")
+	require.True(t, ok)
+	assert.Equal(t, expected, volume)
+	assert.Equal(t, []*DockerVolume{expected}, task.DockerVolumes(task.Containers[0]))
+
+	hostConfig, hcerr := task.DockerHostConfig(task.Containers[0], dockerMap(task))
+	require.Nil(t, hcerr)
+	assert.Equal(t, []string{"ecs-myFamily-1-shared-abc-123:/data"}, hostConfig.Binds)
+}
+
+func TestTaskFromACSTaskResources(t *testing.T) {
+	cpu, memory, containerCPU := int64(512), int64(1024), int64(256)
+	taskFromAcs := ecsacs.Task{
+		Arn:           strptr("myArn"),
+		DesiredStatus: strptr("RUNNING"),
+		Cpu:           &cpu,
+		Memory:        &memory,
+		Containers: []*ecsacs.Container{
+			&ecsacs.Container{Name: strptr("a"), Cpu: &containerCPU},
+			&ecsacs.Container{Name: strptr("b"), Cpu: &containerCPU},
+		},
+	}
+	task, err := TaskFromACS(&taskFromAcs, &ecsacs.PayloadMessage{})
+	require.NoError(t, err)
+	assert.Equal(t, uint(512), task.Cpu)
+	assert.Equal(t, uint(1024), task.Memory)
+	assert.NoError(t, task.Validate())
+
+	containerCPU = 384
+	task, err = TaskFromACS(&taskFromAcs, &ecsacs.PayloadMessage{})
+	require.NoError(t, err)
+	assert.Error(t, task.Validate(), "Expected the containers to exceed the task cpu")
+}
+
+func TestWaitingOnDependencies(t *testing.T) {
+	exitCode := 1
+	testCases := []struct {
+		name            string
+		condition       string
+		knownStatus     ContainerStatus
+		health          ContainerHealthStatus
+		exitCode        *int
+		expectedWaiting bool
+	}{
+		{"start, not started", DependencyConditionStart, ContainerCreated, ContainerHealthUnknown, nil, false},
+		{"healthy, health check starting", DependencyConditionHealthy, ContainerRunning, ContainerHealthStarting, nil, true},
+		{"healthy, healthy", DependencyConditionHealthy, ContainerRunning, ContainerHealthy, nil, false},
+		{"healthy, unhealthy", DependencyConditionHealthy, ContainerRunning, ContainerUnhealthy, nil, false},
+		{"success, running", DependencyConditionSuccess, ContainerRunning, ContainerHealthUnknown, nil, true},
+		{"success, failed", DependencyConditionSuccess, ContainerStopped, ContainerHealthUnknown, &exitCode, false},
+	}
+
+	for _, tc := range testCases {
+		dependency := &Container{Name: "dependency", KnownStatus: tc.knownStatus, Health: tc.health, KnownExitCode: tc.exitCode}
+		dependent := &Container{
+			Name:          "dependent",
+			DesiredStatus: ContainerRunning,
+			DependsOn:     []DependsOn{{Container: "dependency", Condition: tc.condition}},
+		}
+		task := &Task{Containers: []*Container{dependency, dependent}}
+		assert.Equal(t, tc.expectedWaiting, task.WaitingOnDependencies(), tc.name)
+	}
+}
+
+func TestContainerHealthStatusJSON(t *testing.T) {
+	for _, health := range []ContainerHealthStatus{ContainerHealthUnknown, ContainerHealthStarting, ContainerHealthy, ContainerUnhealthy} {
+		container := &Container{Health: health}
+		data, err := json.Marshal(container)
+		assert.NoError(t, err)
+
+		var unmarshalled Container
+		assert.NoError(t, json.Unmarshal(data, &unmarshalled))
+		assert.Equal(t, health, unmarshalled.Health)
+	}
+}
+
 func assertSetStructFieldsEqual(t *testing.T, expected, actual interface{}) {
 	for i := 0; i < reflect.TypeOf(expected).NumField(); i++ {
 		expectedValue := reflect.ValueOf(expected).Field(i)
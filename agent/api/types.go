@@ -43,6 +43,21 @@ const (
 	ContainerZombie // Impossible status to use as a virtual 'max'
 )
 
+// ContainerHealthStatus is the health of a container as reported by its
+// Docker health check
+type ContainerHealthStatus int32
+
+const (
+	// ContainerHealthUnknown is used for containers without a health check,
+	// or whose health hasn't been determined
+	ContainerHealthUnknown ContainerHealthStatus = iota
+	// ContainerHealthStarting is used while the health check is in its
+	// initial grace period and hasn't reported a result yet
+	ContainerHealthStarting
+	ContainerHealthy
+	ContainerUnhealthy
+)
+
 type TransportProtocol int32
 
 const (
@@ -93,6 +108,31 @@ type Task struct {
 	Containers []*Container
 	Volumes    []TaskVolume `json:"volumes"`
 
+	// NetworkMode is the network mode of the task's containers: bridge, host,
+	// none or the name of a user-defined network. Docker's default is used if
+	// not set
+	NetworkMode string `json:"networkMode"`
+
+	// FailOnEssentialExitCode specifies whether the task reports a failure
+	// when it stops because an essential container exited with a non-zero
+	// code. Tasks whose essential containers exit with zero, such as batch
+	// jobs that completed, stop without a reason either way
+	FailOnEssentialExitCode bool `json:"failOnEssentialExitCode"`
+
+	// PidMode and IpcMode are the process and IPC namespaces of the task's
+	// containers: task to share one namespace between them, host to use the
+	// namespace of the instance or none to give each container its own, which
+	// is also the default
+	PidMode string `json:"pidMode"`
+	IpcMode string `json:"ipcMode"`
+
+	// Cpu is the number of cpu units the task's containers may reserve in
+	// total. Zero means the task has no cpu limit
+	Cpu uint `json:"cpu"`
+	// Memory is the amount of memory, in MiB, the task's containers may
+	// reserve in total. Zero means the task has no memory limit
+	Memory uint `json:"memory"`
+
 	DesiredStatus     TaskStatus
 	desiredStatusLock sync.RWMutex
 
@@ -128,6 +168,169 @@ type MountPoint struct {
 	ReadOnly      bool   `json:"readOnly"`
 }
 
+// TmpfsMount describes a tmpfs mount in the container. Size is a number with
+// an optional unit (b, k, m or g) and MountOptions are the options passed to
+// the mount, such as "noexec" or "mode=1777".
+type TmpfsMount struct {
+	ContainerPath string   `json:"containerPath"`
+	Size          string   `json:"size"`
+	MountOptions  []string `json:"mountOptions"`
+}
+
+// Secret is an environment variable of the container whose value is resolved
+// when the container is created, so that it isn't part of the task. ValueFrom
+// is the ARN of an SSM parameter or a Secrets Manager secret, or the name of
+// an SSM parameter in the region of the instance. The secretOptions of the
+// logConfiguration of a container, LogSecretOptions, are resolved the same
+// way and set the log driver option Name instead, for tokens such as the
+// splunk driver's
+type Secret struct {
+	Name      string `json:"name"`
+	ValueFrom string `json:"valueFrom"`
+}
+
+// EnvironmentFileTypeLocal is the type of environment files read from a path
+// on the instance
+const EnvironmentFileTypeLocal = "local"
+
+// EnvironmentFile is a file of environment variables for the container, with
+// a KEY=VALUE pair on each line. Value is the location of the file, which for
+// files of type EnvironmentFileTypeLocal is an absolute path on the instance.
+type EnvironmentFile struct {
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+const (
+	// NetworkModeBridge connects the container to the default docker bridge
+	NetworkModeBridge = "bridge"
+	// NetworkModeHost uses the network stack of the instance in the container
+	NetworkModeHost = "host"
+	// NetworkModeNone gives the container a network stack without interfaces
+	// other than loopback
+	NetworkModeNone = "none"
+	// NetworkModeContainerPrefix prefixes the name of another container of the
+	// task whose network stack the container joins
+	NetworkModeContainerPrefix = "container:"
+)
+
+const (
+	// NamespaceModeTask shares one PID or IPC namespace between the containers
+	// of the task
+	NamespaceModeTask = "task"
+	// NamespaceModeHost uses the PID or IPC namespace of the instance in the
+	// containers of the task
+	NamespaceModeHost = "host"
+	// NamespaceModeNone gives each container of the task its own PID or IPC
+	// namespace
+	NamespaceModeNone = "none"
+)
+
+const (
+	// RestartPolicyNo never restarts the container when it exits
+	RestartPolicyNo = "no"
+	// RestartPolicyAlways restarts the container whenever it exits
+	RestartPolicyAlways = "always"
+	// RestartPolicyUnlessStopped restarts the container whenever it exits,
+	// unless it was stopped
+	RestartPolicyUnlessStopped = "unless-stopped"
+	// RestartPolicyOnFailure restarts the container when it exits with a
+	// non-zero exit code, optionally followed by a colon and the maximum
+	// number of restarts
+	RestartPolicyOnFailure = "on-failure"
+)
+
+// ResourceTypeGPU is the type of resource requirements for a number of GPUs
+const ResourceTypeGPU = "GPU"
+
+// ResourceRequirement is an amount of a resource the container requires, such
+// as a number of GPUs. Value is the amount as a string.
+type ResourceRequirement struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Ulimit describes a resource limit of the container. Name is the name of the
+// limit without the RLIMIT_ prefix, such as "nofile".
+type Ulimit struct {
+	Name      string `json:"name"`
+	SoftLimit int64  `json:"softLimit"`
+	HardLimit int64  `json:"hardLimit"`
+}
+
+// HostEntry is an entry added to the /etc/hosts file of the container
+type HostEntry struct {
+	Hostname  string `json:"hostname"`
+	IPAddress string `json:"ipAddress"`
+}
+
+// LinuxParameters are the Linux specific options of the container. ShmSize is
+// the size of /dev/shm in MiB and Init runs an init process in the container
+// that forwards signals and reaps processes. BlkioWeight is the relative
+// weight (10 to 1000) of the container's block IO against other containers,
+// and DeviceReadBps and DeviceWriteBps limit its bandwidth to host devices.
+type LinuxParameters struct {
+	ShmSize        *int64              `json:"shmSize"`
+	Init           bool                `json:"init"`
+	Capabilities   *KernelCapabilities `json:"capabilities"`
+	Devices        []Device            `json:"devices"`
+	BlkioWeight    *int64              `json:"blkioWeight"`
+	DeviceReadBps  []BlkioDeviceLimit  `json:"deviceReadBps"`
+	DeviceWriteBps []BlkioDeviceLimit  `json:"deviceWriteBps"`
+	SystemControls []SystemControl     `json:"systemControls"`
+}
+
+// SystemControl sets the kernel parameter Namespace, such as
+// net.core.somaxconn, to Value in the namespaces of the container. Only
+// parameters of the network and IPC namespaces may be set.
+type SystemControl struct {
+	Namespace string `json:"namespace"`
+	Value     string `json:"value"`
+}
+
+// BlkioDeviceLimit limits the bandwidth of the container to the host device
+// at Path to Rate bytes per second.
+type BlkioDeviceLimit struct {
+	Path string `json:"path"`
+	Rate int64  `json:"rate"`
+}
+
+// Device is a device of the host exposed to the container. Permissions are
+// the cgroup permissions of the container on the device, a combination of r
+// (read), w (write) and m (mknod); all of them if not set. ContainerPath is
+// the same as HostPath if not set.
+type Device struct {
+	HostPath      string `json:"hostPath"`
+	ContainerPath string `json:"containerPath"`
+	Permissions   string `json:"permissions"`
+}
+
+// KernelCapabilities are the Linux capabilities added to and dropped from the
+// default capabilities docker gives the container, named without the CAP_
+// prefix, such as "NET_ADMIN".
+type KernelCapabilities struct {
+	Add  []string `json:"add"`
+	Drop []string `json:"drop"`
+}
+
+const (
+	// DependencyConditionStart requires the dependency to have been started
+	DependencyConditionStart = "START"
+	// DependencyConditionHealthy requires the health check of the dependency
+	// to report it as healthy
+	DependencyConditionHealthy = "HEALTHY"
+	// DependencyConditionSuccess requires the dependency to have exited with
+	// an exit code of zero
+	DependencyConditionSuccess = "SUCCESS"
+)
+
+// DependsOn declares that a container can't be started until the container
+// named by Container reaches Condition
+type DependsOn struct {
+	Container string `json:"containerName"`
+	Condition string `json:"condition"`
+}
+
 // HostVolume is an interface for something that may be used as the host half of a
 // docker volume mount
 type HostVolume interface {
@@ -153,6 +356,23 @@ func (e *EmptyHostVolume) SourcePath() string {
 	return e.HostPath
 }
 
+// DockerVolume is a HostVolume backed by a docker volume that the engine
+// creates before the first container mounting it is created and removes once
+// the containers mounting it are gone. DockerName is set when the task is
+// unmarshalled
+type DockerVolume struct {
+	DockerName string            `json:"dockerName"`
+	Driver     string            `json:"driver"`
+	DriverOpts map[string]string `json:"driverOpts"`
+	Labels     map[string]string `json:"labels"`
+}
+
+// SourcePath returns the name of the docker volume, which docker mounts in
+// place of a path on the host
+func (v *DockerVolume) SourcePath() string {
+	return v.DockerName
+}
+
 type ContainerStateChange struct {
 	TaskArn       string
 	ContainerName string
@@ -225,13 +445,34 @@ type Container struct {
 	Command                []string
 	Cpu                    uint
 	Memory                 uint
+	MemoryReservation      uint  `json:"memoryReservation"`
+	MemorySwap             int64 `json:"memorySwap"`
 	Links                  []string
-	VolumesFrom            []VolumeFrom  `json:"volumesFrom"`
-	MountPoints            []MountPoint  `json:"mountPoints"`
-	Ports                  []PortBinding `json:"portMappings"`
+	VolumesFrom            []VolumeFrom     `json:"volumesFrom"`
+	MountPoints            []MountPoint     `json:"mountPoints"`
+	Tmpfs                  []TmpfsMount     `json:"tmpfs"`
+	Ulimits                []Ulimit         `json:"ulimits"`
+	LinuxParameters        *LinuxParameters `json:"linuxParameters"`
+	DockerSecurityOptions  []string         `json:"dockerSecurityOptions"`
+	DNSServers             []string         `json:"dnsServers"`
+	DNSSearchDomains       []string         `json:"dnsSearchDomains"`
+	ExtraHosts             []HostEntry      `json:"extraHosts"`
+	ReadonlyRootFilesystem bool             `json:"readonlyRootFilesystem"`
+	NetworkMode            string           `json:"networkMode"`
+	RestartPolicy          string           `json:"restartPolicy"`
+	User                   string           `json:"user"`
+	WorkingDirectory       string           `json:"workingDirectory"`
+	DependsOn              []DependsOn      `json:"dependsOn"`
+	StopTimeout            uint             `json:"stopTimeout"`
+	StopSignal             string           `json:"stopSignal"`
+	Ports                  []PortBinding    `json:"portMappings"`
 	Essential              bool
 	EntryPoint             *[]string
 	Environment            map[string]string           `json:"environment"`
+	EnvironmentFiles       []EnvironmentFile           `json:"environmentFiles"`
+	Secrets                []Secret                    `json:"secrets"`
+	LogSecretOptions       []Secret                    `json:"logSecretOptions"`
+	ResourceRequirements   []ResourceRequirement       `json:"resourceRequirements"`
 	Overrides              ContainerOverrides          `json:"overrides"`
 	DockerConfig           DockerConfig                `json:"dockerConfig"`
 	RegistryAuthentication *RegistryAuthenticationData `json:"registryAuthentication"`
@@ -242,6 +483,15 @@ type Container struct {
 	KnownStatus     ContainerStatus
 	knownStatusLock sync.RWMutex
 
+	// Health is the health of the container as reported by its health check,
+	// if it has one
+	Health     ContainerHealthStatus `json:"health"`
+	healthLock sync.RWMutex
+
+	// StopOnUnhealthyDependency stops the container when a dependency it
+	// waited on to be healthy turns unhealthy
+	StopOnUnhealthyDependency bool `json:"stopOnUnhealthyDependency"`
+
 	// RunDependencies is a list of containers that must be run before
 	// this one is created
 	RunDependencies []string
@@ -258,6 +508,15 @@ type Container struct {
 	KnownExitCode     *int
 	KnownPortBindings []PortBinding
 
+	// CreatedAt and StartedAt are when docker created the container and last
+	// started it, as reported by inspecting the container
+	CreatedAt      time.Time `json:"createdAt"`
+	StartedAt      time.Time `json:"startedAt"`
+	timestampsLock sync.RWMutex
+
+	// GPUDevices are the paths of the GPU devices assigned to the container
+	GPUDevices []string `json:"gpuDevices"`
+
 	// Not upstream; todo move this out into a wrapper type
 	StatusLock sync.Mutex
 }
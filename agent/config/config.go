@@ -15,12 +15,13 @@ package config
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"reflect"
+	"runtime"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +30,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/cihub/seelog"
+	"github.com/docker/go-units"
 )
 
 const (
@@ -54,6 +56,16 @@ const (
 	// DefaultDockerStopTimeout specifies the value for container stop timeout duration
 	DefaultDockerStopTimeout = 30 * time.Second
 
+	// DefaultContainerCreateTimeout and DefaultContainerStartTimeout specify
+	// how long creating and starting a container may take before the Agent
+	// gives up on it
+	DefaultContainerCreateTimeout = 3 * time.Minute
+	DefaultContainerStartTimeout  = 1*time.Minute + 30*time.Second
+
+	// MaximumDockerStopTimeout specifies the maximum value for the container stop timeout,
+	// both for the configured default and for containers that set their own timeout
+	MaximumDockerStopTimeout = 30 * time.Minute
+
 	// DefaultImageCleanupTimeInterval specifies the default value for image cleanup duration. It is used to
 	// remove the images pulled by agent.
 	DefaultImageCleanupTimeInterval = 30 * time.Minute
@@ -66,6 +78,94 @@ const (
 	// has been pulled before it can be deleted.
 	DefaultImageDeletionAge = 1 * time.Hour
 
+	// DefaultACSReconnectJitterMin and DefaultACSReconnectJitterMax specify the default bounds
+	// of the jitter added to the backoff between reconnects to ACS, as a multiple of the backoff.
+	DefaultACSReconnectJitterMin = 0.0
+	DefaultACSReconnectJitterMax = 0.2
+
+	// DefaultACSHeartbeatTimeout and DefaultACSHeartbeatGraceFactor specify
+	// how long the connection to ACS may go without any message before it is
+	// reconnected: between 5 and 8 minutes
+	DefaultACSHeartbeatTimeout     = 5 * time.Minute
+	DefaultACSHeartbeatGraceFactor = 0.6
+
+	// MinimumACSHeartbeatTimeout keeps connections to ACS from being torn
+	// down between heartbeats
+	MinimumACSHeartbeatTimeout = 30 * time.Second
+
+	// DefaultContainerStopConcurrency specifies the default number of containers
+	// of a task that are stopped at the same time.
+	DefaultContainerStopConcurrency = 10
+
+	// DefaultImagePullMaxRetries specifies the default number of times a pull
+	// that failed with a transient error is retried.
+	DefaultImagePullMaxRetries = 3
+
+	// ContainerStateModeEvents, ContainerStateModePoll and ContainerStateModeHybrid
+	// are the ways the Agent can learn about container state changes: from the
+	// docker event stream, by periodically listing and inspecting containers,
+	// or from the event stream with periodic polling catching missed events
+	ContainerStateModeEvents = "events"
+	ContainerStateModePoll   = "poll"
+	ContainerStateModeHybrid = "hybrid"
+
+	// ImagePullBehaviorAlways, ImagePullBehaviorOnce and
+	// ImagePullBehaviorPreferCached are the ways the Agent can pull the images
+	// of containers: pulling every time, pulling only images that aren't
+	// present on the instance, or pulling every time but falling back to the
+	// image present on the instance when the pull fails
+	ImagePullBehaviorAlways       = "always"
+	ImagePullBehaviorOnce         = "once"
+	ImagePullBehaviorPreferCached = "prefer-cached"
+
+	// CPULimitModeShares and CPULimitModeQuota are the ways the Agent can
+	// apply the cpu units of containers: as relative shares of the cpu, or as
+	// hard caps enforced with a quota of cpu time
+	CPULimitModeShares = "shares"
+	CPULimitModeQuota  = "quota"
+
+	// DefaultPollingMetricsWaitDuration specifies the default time between
+	// polls of the stats of a container when metrics are polled
+	DefaultPollingMetricsWaitDuration = 10 * time.Second
+
+	// MinimumPollingMetricsWaitDuration and MaximumPollingMetricsWaitDuration
+	// bound the time between polls of the stats of a container. Polling more
+	// often saves little over streaming, and polling less often than metrics
+	// are published leaves publishes without samples
+	MinimumPollingMetricsWaitDuration = 5 * time.Second
+	MaximumPollingMetricsWaitDuration = 20 * time.Second
+
+	// MaximumMetricsDimensionLabels is the number of docker labels that may
+	// be published as dimensions of the metrics of containers, which keeps
+	// the number of distinct metrics bounded
+	MaximumMetricsDimensionLabels = 5
+
+	// DefaultStateSaveInterval specifies the default time the agent waits for
+	// further changes to its state before saving it
+	DefaultStateSaveInterval = 2 * time.Second
+
+	// MinimumStateSaveInterval and MaximumStateSaveInterval bound the time
+	// the agent waits for further changes to its state before saving it.
+	// Changes wait at most five intervals to be saved
+	MinimumStateSaveInterval = time.Second
+	MaximumStateSaveInterval = time.Minute
+
+	// MaximumInstanceAttributes is the number of custom attributes ECS allows
+	// a container instance to have
+	MaximumInstanceAttributes = 10
+
+	// maximumInstanceAttributeLength is the length ECS allows the names and
+	// values of attributes to have
+	maximumInstanceAttributeLength = 128
+
+	// reservedInstanceAttributePrefix starts the names of the attributes the
+	// Agent registers itself
+	reservedInstanceAttributePrefix = "ecs."
+
+	// reservedContainerLabelPrefix starts the names of the labels the Agent
+	// adds to containers itself
+	reservedContainerLabelPrefix = "com.amazonaws.ecs."
+
 	// minimumTaskCleanupWaitDuration specifies the minimum duration to wait before cleaning up
 	// a task's container. This is used to enforce sane values for the config.TaskCleanupWaitDuration field.
 	minimumTaskCleanupWaitDuration = 1 * time.Minute
@@ -73,6 +173,10 @@ const (
 	// minimumDockerStopTimeout specifies the minimum value for docker StopContainer API
 	minimumDockerStopTimeout = 1 * time.Second
 
+	// minimumContainerTransitionTimeout specifies the minimum value for the
+	// container create and start timeouts
+	minimumContainerTransitionTimeout = 1 * time.Second
+
 	// minimumImageCleanupInterval specifies the minimum time for agent to wait before performing
 	// image cleanup.
 	minimumImageCleanupInterval = 10 * time.Minute
@@ -80,6 +184,29 @@ const (
 	// minimumNumImagesToDeletePerCycle specifies the minimum number of images that to be deleted when
 	// performing image cleanup.
 	minimumNumImagesToDeletePerCycle = 1
+
+	// minimumContainerStopConcurrency specifies the minimum number of containers of a task
+	// that are stopped at the same time.
+	minimumContainerStopConcurrency = 1
+
+	// minimumImagePullMaxAttempts specifies the minimum number of times an image
+	// pull is attempted, which is once with no retries.
+	minimumImagePullMaxAttempts = 1
+)
+
+var (
+	// clusterNamePattern matches the names ECS accepts for clusters
+	clusterNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,255}$`)
+
+	// instanceAttributeNamePattern and instanceAttributeValuePattern match
+	// the characters ECS accepts in the names and values of attributes.
+	// Values may not start or end with a space
+	instanceAttributeNamePattern  = regexp.MustCompile(`^[a-zA-Z0-9_./\\-]+$`)
+	instanceAttributeValuePattern = regexp.MustCompile(`^([a-zA-Z0-9_./\\:@-]|[a-zA-Z0-9_./\\:@-][a-zA-Z0-9_./\\:@ -]*[a-zA-Z0-9_./\\:@-])$`)
+
+	// numCPU is a variable such that the cores of the instance can be
+	// controlled by unit tests
+	numCPU = runtime.NumCPU
 )
 
 // Merge merges two config files, preferring the ones on the left. Any nil or
@@ -115,11 +242,10 @@ func (cfg *Config) complete() bool {
 // missing:STRING and acts based on that string. Current options are: fatal,
 // warn. Fatal will result in an error being returned, warn will result in a
 // warning that the field is missing being logged.
-func (cfg *Config) checkMissingAndDepreciated() error {
+func (cfg *Config) checkMissingAndDepreciated() {
 	cfgElem := reflect.ValueOf(cfg).Elem()
 	cfgStructField := reflect.Indirect(reflect.ValueOf(cfg)).Type()
 
-	fatalFields := []string{}
 	for i := 0; i < cfgElem.NumField(); i++ {
 		cfgField := cfgElem.Field(i)
 		if utils.ZeroOrNil(cfgField.Interface()) {
@@ -132,7 +258,6 @@ func (cfg *Config) checkMissingAndDepreciated() error {
 				seelog.Warnf("Configuration key not set, key: %v", cfgStructField.Field(i).Name)
 			case "fatal":
 				seelog.Criticalf("Configuration key not set, key: %v", cfgStructField.Field(i).Name)
-				fatalFields = append(fatalFields, cfgStructField.Field(i).Name)
 			default:
 				seelog.Warnf("Unexpected `missing` tag value, tag %v", missingTag)
 			}
@@ -145,10 +270,21 @@ func (cfg *Config) checkMissingAndDepreciated() error {
 			seelog.Warnf("Use of deprecated configuration key, key: %v message: %v", cfgStructField.Field(i).Name, deprecatedTag)
 		}
 	}
-	if len(fatalFields) > 0 {
-		return errors.New("Missing required fields: " + strings.Join(fatalFields, ", "))
+}
+
+// missingFatalFields returns the names of the fields tagged `missing:"fatal"`
+// that aren't set
+func (cfg *Config) missingFatalFields() []string {
+	cfgElem := reflect.ValueOf(cfg).Elem()
+	cfgStructField := cfgElem.Type()
+
+	fatalFields := []string{}
+	for i := 0; i < cfgElem.NumField(); i++ {
+		if cfgStructField.Field(i).Tag.Get("missing") == "fatal" && utils.ZeroOrNil(cfgElem.Field(i).Interface()) {
+			fatalFields = append(fatalFields, cfgStructField.Field(i).Name)
+		}
 	}
-	return nil
+	return fatalFields
 }
 
 // trimWhitespace trims whitespace from all string config values with the
@@ -176,36 +312,6 @@ func (cfg *Config) trimWhitespace() {
 	}
 }
 
-func fileConfig() Config {
-	config_file := utils.DefaultIfBlank(os.Getenv("ECS_AGENT_CONFIG_FILE_PATH"), "/etc/ecs_container_agent/config.json")
-
-	file, err := os.Open(config_file)
-	if err != nil {
-		return Config{}
-	}
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
-		seelog.Errorf("Unable to read config file, err %v", err)
-		return Config{}
-	}
-	if strings.TrimSpace(string(data)) == "" {
-		// empty file, not an error
-		return Config{}
-	}
-
-	config := Config{}
-	err = json.Unmarshal(data, &config)
-	if err != nil {
-		seelog.Errorf("Error reading config json data, err %v", err)
-	}
-
-	// Handle any deprecated keys correctly here
-	if utils.ZeroOrNil(config.Cluster) && !utils.ZeroOrNil(config.ClusterArn) {
-		config.Cluster = config.ClusterArn
-	}
-	return config
-}
-
 // environmentConfig reads the given configs from the environment and attempts
 // to convert them to the given type
 func environmentConfig() Config {
@@ -218,6 +324,17 @@ func environmentConfig() Config {
 	engineAuthType := os.Getenv("ECS_ENGINE_AUTH_TYPE")
 	engineAuthData := os.Getenv("ECS_ENGINE_AUTH_DATA")
 
+	// Format: json object from registry hostname to credential helper, e.g.
+	// {"registry.example.com":"example-login"}
+	var engineAuthCredentialHelpers map[string]string
+	credentialHelpersEnv := os.Getenv("ECS_ENGINE_AUTH_CREDENTIAL_HELPERS")
+	if credentialHelpersEnv != "" {
+		err := json.Unmarshal([]byte(credentialHelpersEnv), &engineAuthCredentialHelpers)
+		if err != nil {
+			seelog.Warnf("Invalid format for \"ECS_ENGINE_AUTH_CREDENTIAL_HELPERS\", expected a json object. err %v", err)
+		}
+	}
+
 	var checkpoint bool
 	dataDir := os.Getenv("ECS_DATADIR")
 	if dataDir != "" {
@@ -258,6 +375,7 @@ func environmentConfig() Config {
 	disableMetrics := utils.ParseBool(os.Getenv("ECS_DISABLE_METRICS"), false)
 
 	reservedMemory := parseEnvVariableUint16("ECS_RESERVED_MEMORY")
+	reservedCPU := parseEnvVariableUint16("ECS_RESERVED_CPU")
 
 	var dockerStopTimeout time.Duration
 	parsedStopTimeout := parseEnvVariableDuration("ECS_CONTAINER_STOP_TIMEOUT")
@@ -280,6 +398,7 @@ func environmentConfig() Config {
 	}
 
 	privilegedDisabled := utils.ParseBool(os.Getenv("ECS_DISABLE_PRIVILEGED"), false)
+	strictPrivilegedChecking := utils.ParseBool(os.Getenv("ECS_STRICT_PRIVILEGED_CHECKING"), false)
 	seLinuxCapable := utils.ParseBool(os.Getenv("ECS_SELINUX_CAPABLE"), false)
 	appArmorCapable := utils.ParseBool(os.Getenv("ECS_APPARMOR_CAPABLE"), false)
 	taskIAMRoleEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_IAM_ROLE"), false)
@@ -296,6 +415,147 @@ func environmentConfig() Config {
 	if numImagesToDeletePerCycleEnvVal != "" && err != nil {
 		seelog.Warnf("Invalid format for \"ECS_NUM_IMAGES_DELETE_PER_CYCLE\", expected an integer. err %v", err)
 	}
+	var imageCleanupExclusionList []string
+	imageCleanupExclusionDecoder := json.NewDecoder(strings.NewReader(os.Getenv("ECS_IMAGE_CLEANUP_EXCLUSION")))
+	err = imageCleanupExclusionDecoder.Decode(&imageCleanupExclusionList)
+	if err != io.EOF && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_IMAGE_CLEANUP_EXCLUSION\" environment variable; expected a JSON array like [\"amazon/amazon-ecs-agent:latest\",\"busybox:*\"]. err %v", err)
+	}
+
+	dockerMinimumAPIVersion := dockerclient.DockerVersion(os.Getenv("ECS_DOCKER_MINIMUM_API_VERSION"))
+
+	acsReconnectJitterMin := parseEnvVariableFloat64("ECS_ACS_RECONNECT_JITTER_MIN")
+	acsReconnectJitterMax := parseEnvVariableFloat64("ECS_ACS_RECONNECT_JITTER_MAX")
+
+	acsHeartbeatTimeout := parseEnvVariableDuration("ECS_ACS_HEARTBEAT_TIMEOUT")
+	acsHeartbeatGraceFactor := parseEnvVariableFloat64("ECS_ACS_HEARTBEAT_GRACE_FACTOR")
+
+	var allowedSysctls []string
+	allowedSysctlsDecoder := json.NewDecoder(strings.NewReader(os.Getenv("ECS_ALLOWED_SYSCTLS")))
+	err = allowedSysctlsDecoder.Decode(&allowedSysctls)
+	if err != io.EOF && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_ALLOWED_SYSCTLS\" environment variable; expected a JSON array like [\"net.core.somaxconn\",\"net.ipv4.*\"]. err %v", err)
+	}
+
+	drainTimeout := parseEnvVariableDuration("ECS_DRAIN_TIMEOUT")
+
+	containerStopConcurrencyEnvVal := os.Getenv("ECS_CONTAINER_STOP_CONCURRENCY")
+	containerStopConcurrency, err := strconv.Atoi(containerStopConcurrencyEnvVal)
+	if containerStopConcurrencyEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_CONTAINER_STOP_CONCURRENCY\", expected an integer. err %v", err)
+	}
+
+	// The number of attempts is stored rather than the number of retries so
+	// that disabling retries isn't mistaken for an unset value
+	var imagePullMaxAttempts int
+	imagePullMaxRetriesEnvVal := os.Getenv("ECS_IMAGE_PULL_MAX_RETRIES")
+	imagePullMaxRetries, err := strconv.Atoi(imagePullMaxRetriesEnvVal)
+	if imagePullMaxRetriesEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_IMAGE_PULL_MAX_RETRIES\", expected an integer. err %v", err)
+	} else if imagePullMaxRetries < 0 {
+		seelog.Warnf("Invalid value for \"ECS_IMAGE_PULL_MAX_RETRIES\", expected a non-negative integer. Parsed value: %d", imagePullMaxRetries)
+	} else if imagePullMaxRetriesEnvVal != "" {
+		imagePullMaxAttempts = imagePullMaxRetries + 1
+	}
+
+	containerStateMode := os.Getenv("ECS_CONTAINER_STATE_MODE")
+
+	jsonFileMaxSize := os.Getenv("ECS_JSON_FILE_MAX_SIZE")
+	jsonFileMaxFiles := parseEnvVariableInt("ECS_JSON_FILE_MAX_FILES")
+	jsonFileMaxSizeLimit := os.Getenv("ECS_JSON_FILE_MAX_SIZE_LIMIT")
+	jsonFileMaxFilesLimit := parseEnvVariableInt("ECS_JSON_FILE_MAX_FILES_LIMIT")
+
+	containerShmSizeLimit := parseEnvVariableInt("ECS_CONTAINER_SHM_SIZE_LIMIT")
+
+	var allowedCapabilities []string
+	allowedCapabilitiesDecoder := json.NewDecoder(strings.NewReader(os.Getenv("ECS_ALLOWED_CAPABILITIES")))
+	err = allowedCapabilitiesDecoder.Decode(&allowedCapabilities)
+	if err != io.EOF && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_ALLOWED_CAPABILITIES\" environment variable; expected a JSON array like [\"NET_ADMIN\",\"SYS_PTRACE\"]. err %v", err)
+	}
+
+	strictDeviceChecking := utils.ParseBool(os.Getenv("ECS_STRICT_DEVICE_CHECKING"), false)
+
+	var bindMountAllowedPaths []string
+	bindMountAllowedPathsDecoder := json.NewDecoder(strings.NewReader(os.Getenv("ECS_BIND_MOUNT_ALLOWED_PATHS")))
+	err = bindMountAllowedPathsDecoder.Decode(&bindMountAllowedPaths)
+	if err != io.EOF && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_BIND_MOUNT_ALLOWED_PATHS\" environment variable; expected a JSON array like [\"/data\",\"/var/log\"]. err %v", err)
+	}
+
+	var bindMountDeniedPaths []string
+	bindMountDeniedPathsDecoder := json.NewDecoder(strings.NewReader(os.Getenv("ECS_BIND_MOUNT_DENIED_PATHS")))
+	err = bindMountDeniedPathsDecoder.Decode(&bindMountDeniedPaths)
+	if err != io.EOF && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_BIND_MOUNT_DENIED_PATHS\" environment variable; expected a JSON array like [\"/etc\",\"/var/run/docker.sock\"]. err %v", err)
+	}
+
+	bindMountsDisabled := utils.ParseBool(os.Getenv("ECS_DISABLE_BIND_MOUNTS"), false)
+
+	gpuSupportEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_GPU_SUPPORT"), false)
+
+	pollMetrics := utils.ParseBool(os.Getenv("ECS_POLL_METRICS"), false)
+	pollingMetricsWaitDuration := parseEnvVariableDuration("ECS_POLLING_METRICS_WAIT_DURATION")
+
+	var metricsDimensionLabels []string
+	metricsDimensionLabelsDecoder := json.NewDecoder(strings.NewReader(os.Getenv("ECS_METRICS_DIMENSION_LABELS")))
+	err = metricsDimensionLabelsDecoder.Decode(&metricsDimensionLabels)
+	if err != io.EOF && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_METRICS_DIMENSION_LABELS\" environment variable; expected a JSON array like [\"app\",\"team\"]. err %v", err)
+	}
+
+	stateSaveInterval := parseEnvVariableDuration("ECS_STATE_SAVE_INTERVAL")
+
+	// The custom attributes are a json object of attribute names to values,
+	// such as {"stack":"prod","rack":"a1"}
+	var instanceAttributes map[string]string
+	instanceAttributesEnv := os.Getenv("ECS_INSTANCE_ATTRIBUTES")
+	if instanceAttributesEnv != "" {
+		err := json.Unmarshal([]byte(instanceAttributesEnv), &instanceAttributes)
+		if err != nil {
+			seelog.Warnf("Invalid format for \"ECS_INSTANCE_ATTRIBUTES\", expected a json object. err %v", err)
+		}
+	}
+
+	imageCleanupDiskThreshold := parseEnvVariableInt("ECS_IMAGE_CLEANUP_DISK_THRESHOLD")
+	imageCleanupDiskPath := os.Getenv("ECS_IMAGE_CLEANUP_DISK_PATH")
+
+	imagePullBehavior := os.Getenv("ECS_IMAGE_PULL_BEHAVIOR")
+
+	cpuLimitMode := os.Getenv("ECS_CPU_LIMIT_MODE")
+
+	containerMetadataEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_CONTAINER_METADATA"), false)
+	hostDataDir := os.Getenv("ECS_HOST_DATA_DIR")
+
+	maxConcurrentTaskLaunches := parseEnvVariableInt("ECS_MAX_CONCURRENT_TASK_LAUNCHES")
+
+	containerLogsEndpointEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_CONTAINER_LOGS_ENDPOINT"), false)
+	logLevelEndpointEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_LOG_LEVEL_ENDPOINT"), false)
+	localEnvironmentFilesEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_LOCAL_ENVIRONMENT_FILES"), false)
+
+	seccompUnconfinedAllowed := utils.ParseBool(os.Getenv("ECS_ALLOW_SECCOMP_UNCONFINED"), false)
+	seccompProfileDir := os.Getenv("ECS_SECCOMP_PROFILE_DIR")
+	strictAppArmorChecking := utils.ParseBool(os.Getenv("ECS_STRICT_APPARMOR_CHECKING"), false)
+
+	hostNamespacesDisabled := utils.ParseBool(os.Getenv("ECS_DISABLE_HOST_NAMESPACES"), false)
+
+	maxContainerRestarts := parseEnvVariableInt("ECS_MAX_CONTAINER_RESTARTS")
+
+	ecrImageAuthEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_ECR_IMAGE_AUTH"), false)
+
+	// The container labels are a json object of label names to values, such
+	// as {"team":"payments"}
+	var containerLabels map[string]string
+	containerLabelsEnv := os.Getenv("ECS_CONTAINER_LABELS")
+	if containerLabelsEnv != "" {
+		err := json.Unmarshal([]byte(containerLabelsEnv), &containerLabels)
+		if err != nil {
+			seelog.Warnf("Invalid format for \"ECS_CONTAINER_LABELS\", expected a json object. err %v", err)
+		}
+	}
+
+	containerCreateTimeout := parseEnvVariableDuration("ECS_CONTAINER_CREATE_TIMEOUT")
+	containerStartTimeout := parseEnvVariableDuration("ECS_CONTAINER_START_TIMEOUT")
 
 	return Config{
 		Cluster:                          clusterRef,
@@ -308,12 +568,15 @@ func environmentConfig() Config {
 		Checkpoint:                       checkpoint,
 		EngineAuthType:                   engineAuthType,
 		EngineAuthData:                   NewSensitiveRawMessage([]byte(engineAuthData)),
+		EngineAuthCredentialHelpers:      engineAuthCredentialHelpers,
 		UpdatesEnabled:                   updatesEnabled,
 		UpdateDownloadDir:                updateDownloadDir,
 		DisableMetrics:                   disableMetrics,
 		ReservedMemory:                   reservedMemory,
+		ReservedCPU:                      reservedCPU,
 		AvailableLoggingDrivers:          availableLoggingDrivers,
 		PrivilegedDisabled:               privilegedDisabled,
+		StrictPrivilegedChecking:         strictPrivilegedChecking,
 		SELinuxCapable:                   seLinuxCapable,
 		AppArmorCapable:                  appArmorCapable,
 		TaskCleanupWaitDuration:          taskCleanupWaitDuration,
@@ -326,6 +589,52 @@ func environmentConfig() Config {
 		MinimumImageDeletionAge:          minimumImageDeletionAge,
 		ImageCleanupInterval:             imageCleanupInterval,
 		NumImagesToDeletePerCycle:        numImagesToDeletePerCycle,
+		ImageCleanupExclusionList:        imageCleanupExclusionList,
+		DockerMinimumAPIVersion:          dockerMinimumAPIVersion,
+		ACSReconnectJitterMin:            acsReconnectJitterMin,
+		ACSReconnectJitterMax:            acsReconnectJitterMax,
+		DrainTimeout:                     drainTimeout,
+		ContainerStopConcurrency:         containerStopConcurrency,
+		ImagePullMaxAttempts:             imagePullMaxAttempts,
+		JSONFileMaxSize:                  jsonFileMaxSize,
+		JSONFileMaxFiles:                 jsonFileMaxFiles,
+		JSONFileMaxSizeLimit:             jsonFileMaxSizeLimit,
+		JSONFileMaxFilesLimit:            jsonFileMaxFilesLimit,
+		ContainerStateMode:               containerStateMode,
+		ContainerShmSizeLimit:            containerShmSizeLimit,
+		GPUSupportEnabled:                gpuSupportEnabled,
+		AllowedCapabilities:              allowedCapabilities,
+		StrictDeviceChecking:             strictDeviceChecking,
+		BindMountAllowedPaths:            bindMountAllowedPaths,
+		BindMountDeniedPaths:             bindMountDeniedPaths,
+		BindMountsDisabled:               bindMountsDisabled,
+		ContainerCreateTimeout:           containerCreateTimeout,
+		ContainerStartTimeout:            containerStartTimeout,
+		PollMetrics:                      pollMetrics,
+		PollingMetricsWaitDuration:       pollingMetricsWaitDuration,
+		MetricsDimensionLabels:           metricsDimensionLabels,
+		StateSaveInterval:                stateSaveInterval,
+		InstanceAttributes:               instanceAttributes,
+		ImageCleanupDiskThreshold:        imageCleanupDiskThreshold,
+		ImageCleanupDiskPath:             imageCleanupDiskPath,
+		ImagePullBehavior:                imagePullBehavior,
+		MaxConcurrentTaskLaunches:        maxConcurrentTaskLaunches,
+		ContainerLogsEndpointEnabled:     containerLogsEndpointEnabled,
+		LogLevelEndpointEnabled:          logLevelEndpointEnabled,
+		LocalEnvironmentFilesEnabled:     localEnvironmentFilesEnabled,
+		SeccompUnconfinedAllowed:         seccompUnconfinedAllowed,
+		SeccompProfileDir:                seccompProfileDir,
+		StrictAppArmorChecking:           strictAppArmorChecking,
+		HostNamespacesDisabled:           hostNamespacesDisabled,
+		MaxContainerRestarts:             maxContainerRestarts,
+		ECRImageAuthEnabled:              ecrImageAuthEnabled,
+		ContainerLabels:                  containerLabels,
+		CPULimitMode:                     cpuLimitMode,
+		ContainerMetadataEnabled:         containerMetadataEnabled,
+		HostDataDir:                      hostDataDir,
+		ACSHeartbeatTimeout:              acsHeartbeatTimeout,
+		ACSHeartbeatGraceFactor:          acsHeartbeatGraceFactor,
+		AllowedSysctls:                   allowedSysctls,
 	}
 }
 
@@ -343,6 +652,32 @@ func parseEnvVariableUint16(envVar string) uint16 {
 	return var16
 }
 
+func parseEnvVariableFloat64(envVar string) float64 {
+	envVal := os.Getenv(envVar)
+	var var64 float64
+	if envVal != "" {
+		var err error
+		var64, err = strconv.ParseFloat(envVal, 64)
+		if err != nil {
+			seelog.Warnf("Invalid format for \""+envVar+"\" environment variable; expected a number. err %v", err)
+		}
+	}
+	return var64
+}
+
+func parseEnvVariableInt(envVar string) int {
+	envVal := os.Getenv(envVar)
+	var varInt int
+	if envVal != "" {
+		var err error
+		varInt, err = strconv.Atoi(envVal)
+		if err != nil {
+			seelog.Warnf("Invalid format for \""+envVar+"\" environment variable; expected an integer. err %v", err)
+		}
+	}
+	return varInt
+}
+
 func parseEnvVariableDuration(envVar string) time.Duration {
 	var duration time.Duration
 	envVal := os.Getenv(envVar)
@@ -378,7 +713,9 @@ func NewConfig(ec2client ec2.EC2MetadataClient) (config *Config, err error) {
 	defer func() {
 		config.trimWhitespace()
 		config.Merge(DefaultConfig())
-		err = config.validateAndOverrideBounds()
+		if validateErr := config.validateAndOverrideBounds(); err == nil {
+			err = validateErr
+		}
 	}()
 
 	if config.complete() {
@@ -386,7 +723,8 @@ func NewConfig(ec2client ec2.EC2MetadataClient) (config *Config, err error) {
 		return config, nil
 	}
 
-	config.Merge(fileConfig())
+	fileCfg, err := fileConfig()
+	config.Merge(fileCfg)
 
 	if config.AWSRegion == "" {
 		// Get it from metadata only if we need to (network io)
@@ -399,25 +737,20 @@ func NewConfig(ec2client ec2.EC2MetadataClient) (config *Config, err error) {
 // validateAndOverrideBounds performs validation over members of the Config struct
 // and check the value against the minimum required value.
 func (config *Config) validateAndOverrideBounds() error {
-	err := config.checkMissingAndDepreciated()
-	if err != nil {
-		return err
-	}
+	config.checkMissingAndDepreciated()
 
-	if config.DockerStopTimeout < minimumDockerStopTimeout {
-		return fmt.Errorf("Invalid negative DockerStopTimeout: %v", config.DockerStopTimeout.String())
+	if config.DockerStopTimeout > MaximumDockerStopTimeout {
+		seelog.Warnf("Invalid value for docker stop timeout, will be overridden with the maximum value: %s. Parsed value: %v.", MaximumDockerStopTimeout.String(), config.DockerStopTimeout)
+		config.DockerStopTimeout = MaximumDockerStopTimeout
 	}
-	var badDrivers []string
-	for _, driver := range config.AvailableLoggingDrivers {
-		_, ok := dockerclient.LoggingDriverMinimumVersion[driver]
-		if !ok {
-			badDrivers = append(badDrivers, string(driver))
-		}
+	if config.ContainerCreateTimeout < minimumContainerTransitionTimeout {
+		seelog.Warnf("Invalid value for container create timeout, will be overridden with the default value: %s. Parsed value: %v, minimum value: %v.", DefaultContainerCreateTimeout.String(), config.ContainerCreateTimeout, minimumContainerTransitionTimeout)
+		config.ContainerCreateTimeout = DefaultContainerCreateTimeout
 	}
-	if len(badDrivers) > 0 {
-		return errors.New("Invalid logging drivers: " + strings.Join(badDrivers, ", "))
+	if config.ContainerStartTimeout < minimumContainerTransitionTimeout {
+		seelog.Warnf("Invalid value for container start timeout, will be overridden with the default value: %s. Parsed value: %v, minimum value: %v.", DefaultContainerStartTimeout.String(), config.ContainerStartTimeout, minimumContainerTransitionTimeout)
+		config.ContainerStartTimeout = DefaultContainerStartTimeout
 	}
-
 	// If a value has been set for taskCleanupWaitDuration and the value is less than the minimum allowed cleanup duration,
 	// print a warning and override it
 	if config.TaskCleanupWaitDuration < minimumTaskCleanupWaitDuration {
@@ -435,11 +768,266 @@ func (config *Config) validateAndOverrideBounds() error {
 		config.NumImagesToDeletePerCycle = DefaultNumImagesToDeletePerCycle
 	}
 
+	if config.ContainerStopConcurrency < minimumContainerStopConcurrency {
+		seelog.Warnf("Invalid value for container stop concurrency, will be overridden with the default value: %d. Parsed value: %d, minimum value: %d.", DefaultContainerStopConcurrency, config.ContainerStopConcurrency, minimumContainerStopConcurrency)
+		config.ContainerStopConcurrency = DefaultContainerStopConcurrency
+	}
+
+	if config.ImagePullMaxAttempts < minimumImagePullMaxAttempts {
+		seelog.Warnf("Invalid value for image pull max attempts, will be overridden with the default value: %d. Parsed value: %d, minimum value: %d.", DefaultImagePullMaxRetries+1, config.ImagePullMaxAttempts, minimumImagePullMaxAttempts)
+		config.ImagePullMaxAttempts = DefaultImagePullMaxRetries + 1
+	}
+
+	// The cpu of the instance is registered with 1024 units per core, and some
+	// of it must be left for tasks
+	if instanceCPU := numCPU() * 1024; int(config.ReservedCPU) >= instanceCPU {
+		seelog.Warnf("Invalid value for reserved cpu, will be overridden with the default value: 0. Parsed value: %d, cpu of the instance: %d.", config.ReservedCPU, instanceCPU)
+		config.ReservedCPU = 0
+	}
+
+	config.validateJSONFileLogRotation()
+
+	switch config.ContainerStateMode {
+	case ContainerStateModeEvents, ContainerStateModePoll, ContainerStateModeHybrid:
+	default:
+		seelog.Warnf("Invalid value for container state mode, will be overridden with the default value: %s. Parsed value: %s, expected one of: %s, %s, %s.", ContainerStateModeEvents, config.ContainerStateMode, ContainerStateModeEvents, ContainerStateModePoll, ContainerStateModeHybrid)
+		config.ContainerStateMode = ContainerStateModeEvents
+	}
+
+	switch config.ImagePullBehavior {
+	case ImagePullBehaviorAlways, ImagePullBehaviorOnce, ImagePullBehaviorPreferCached:
+	default:
+		seelog.Warnf("Invalid value for image pull behavior, will be overridden with the default value: %s. Parsed value: %s, expected one of: %s, %s, %s.", ImagePullBehaviorAlways, config.ImagePullBehavior, ImagePullBehaviorAlways, ImagePullBehaviorOnce, ImagePullBehaviorPreferCached)
+		config.ImagePullBehavior = ImagePullBehaviorAlways
+	}
+
+	if config.ContainerMetadataEnabled && config.DataDir == "" {
+		seelog.Warnf("Container metadata requires a data directory to write the metadata files to, will be disabled")
+		config.ContainerMetadataEnabled = false
+	}
+
+	switch config.CPULimitMode {
+	case CPULimitModeShares, CPULimitModeQuota:
+	default:
+		seelog.Warnf("Invalid value for cpu limit mode, will be overridden with the default value: %s. Parsed value: %s, expected one of: %s, %s.", CPULimitModeShares, config.CPULimitMode, CPULimitModeShares, CPULimitModeQuota)
+		config.CPULimitMode = CPULimitModeShares
+	}
+
+	if config.MaxConcurrentTaskLaunches < 0 {
+		seelog.Warnf("Invalid value for max concurrent task launches, will be overridden to not limit task launches. Parsed value: %d.", config.MaxConcurrentTaskLaunches)
+		config.MaxConcurrentTaskLaunches = 0
+	}
+
+	if config.MaxContainerRestarts < 0 {
+		seelog.Warnf("Invalid value for max container restarts, will be overridden to not restart containers. Parsed value: %d.", config.MaxContainerRestarts)
+		config.MaxContainerRestarts = 0
+	}
+
+	if config.PollingMetricsWaitDuration < MinimumPollingMetricsWaitDuration {
+		seelog.Warnf("Invalid value for polling metrics wait duration, will be overridden with the minimum value: %s. Parsed value: %v.", MinimumPollingMetricsWaitDuration.String(), config.PollingMetricsWaitDuration)
+		config.PollingMetricsWaitDuration = MinimumPollingMetricsWaitDuration
+	}
+	if config.PollingMetricsWaitDuration > MaximumPollingMetricsWaitDuration {
+		seelog.Warnf("Invalid value for polling metrics wait duration, will be overridden with the maximum value: %s. Parsed value: %v.", MaximumPollingMetricsWaitDuration.String(), config.PollingMetricsWaitDuration)
+		config.PollingMetricsWaitDuration = MaximumPollingMetricsWaitDuration
+	}
+	if config.StateSaveInterval < MinimumStateSaveInterval {
+		seelog.Warnf("Invalid value for state save interval, will be overridden with the minimum value: %s. Parsed value: %v.", MinimumStateSaveInterval.String(), config.StateSaveInterval)
+		config.StateSaveInterval = MinimumStateSaveInterval
+	}
+	if config.StateSaveInterval > MaximumStateSaveInterval {
+		seelog.Warnf("Invalid value for state save interval, will be overridden with the maximum value: %s. Parsed value: %v.", MaximumStateSaveInterval.String(), config.StateSaveInterval)
+		config.StateSaveInterval = MaximumStateSaveInterval
+	}
+	if len(config.MetricsDimensionLabels) > MaximumMetricsDimensionLabels {
+		seelog.Warnf("Too many metrics dimension labels, only the first %d will be published. Parsed value: %v.", MaximumMetricsDimensionLabels, config.MetricsDimensionLabels)
+		config.MetricsDimensionLabels = config.MetricsDimensionLabels[:MaximumMetricsDimensionLabels]
+	}
+
+	if config.ContainerShmSizeLimit < 0 {
+		seelog.Warnf("Invalid value for container shared memory size limit, will be ignored. Parsed value: %d", config.ContainerShmSizeLimit)
+		config.ContainerShmSizeLimit = 0
+	}
+
+	if config.ImageCleanupDiskThreshold < 0 || config.ImageCleanupDiskThreshold >= 100 {
+		seelog.Warnf("Invalid value for image cleanup disk threshold, disk space will not be monitored. Parsed value: %d, expected a percentage between 0 and 99.", config.ImageCleanupDiskThreshold)
+		config.ImageCleanupDiskThreshold = 0
+	}
+
+	if config.ACSReconnectJitterMin == 0 && config.ACSReconnectJitterMax == 0 {
+		config.ACSReconnectJitterMin = DefaultACSReconnectJitterMin
+		config.ACSReconnectJitterMax = DefaultACSReconnectJitterMax
+	} else if config.ACSReconnectJitterMin < 0 || config.ACSReconnectJitterMax < config.ACSReconnectJitterMin || config.ACSReconnectJitterMax > 1 {
+		seelog.Warnf("Invalid values for ACS reconnect jitter, will be overridden with the default values: %v-%v. Parsed values: %v-%v, expected values between 0 and 1 with the minimum not above the maximum.", DefaultACSReconnectJitterMin, DefaultACSReconnectJitterMax, config.ACSReconnectJitterMin, config.ACSReconnectJitterMax)
+		config.ACSReconnectJitterMin = DefaultACSReconnectJitterMin
+		config.ACSReconnectJitterMax = DefaultACSReconnectJitterMax
+	}
+
+	if config.ACSHeartbeatTimeout == 0 {
+		config.ACSHeartbeatTimeout = DefaultACSHeartbeatTimeout
+	} else if config.ACSHeartbeatTimeout < MinimumACSHeartbeatTimeout {
+		seelog.Warnf("Invalid value for ACS heartbeat timeout, will be overridden with the minimum value: %s. Parsed value: %v.", MinimumACSHeartbeatTimeout.String(), config.ACSHeartbeatTimeout)
+		config.ACSHeartbeatTimeout = MinimumACSHeartbeatTimeout
+	}
+	if config.ACSHeartbeatGraceFactor == 0 {
+		config.ACSHeartbeatGraceFactor = DefaultACSHeartbeatGraceFactor
+	} else if config.ACSHeartbeatGraceFactor < 0 || config.ACSHeartbeatGraceFactor > 1 {
+		seelog.Warnf("Invalid value for ACS heartbeat grace factor, will be overridden with the default value: %v. Parsed value: %v, expected a value between 0 and 1.", DefaultACSHeartbeatGraceFactor, config.ACSHeartbeatGraceFactor)
+		config.ACSHeartbeatGraceFactor = DefaultACSHeartbeatGraceFactor
+	}
+
 	config.platformOverrides()
 
+	return config.Validate()
+}
+
+// Validate checks the fields of the config that can't be overridden with
+// sane values, and the fields that depend on each other. It returns an
+// InvalidConfigError listing every problem found, so that they can all be
+// fixed at once, or nil if the config is valid
+func (config *Config) Validate() error {
+	var problems []string
+	if missing := config.missingFatalFields(); len(missing) > 0 {
+		problems = append(problems, "Missing required fields: "+strings.Join(missing, ", "))
+	}
+	if config.Cluster != "" && !clusterNamePattern.MatchString(config.Cluster) && !strings.HasPrefix(config.Cluster, "arn:") {
+		problems = append(problems, fmt.Sprintf("Invalid Cluster %q: expected a cluster ARN or up to 255 letters, numbers, hyphens and underscores", config.Cluster))
+	}
+
+	for _, ports := range []struct {
+		name  string
+		ports []uint16
+	}{
+		{"ReservedPorts", config.ReservedPorts},
+		{"ReservedPortsUDP", config.ReservedPortsUDP},
+	} {
+		for _, port := range ports.ports {
+			if port == 0 {
+				problems = append(problems, fmt.Sprintf("Invalid %s: ports must be between 1 and 65535", ports.name))
+				break
+			}
+		}
+	}
+
+	if config.DockerStopTimeout < minimumDockerStopTimeout {
+		problems = append(problems, fmt.Sprintf("Invalid negative DockerStopTimeout: %v", config.DockerStopTimeout.String()))
+	}
+	if config.DrainTimeout < 0 {
+		problems = append(problems, fmt.Sprintf("Invalid negative DrainTimeout: %v", config.DrainTimeout.String()))
+	}
+
+	var badDrivers []string
+	for _, driver := range config.AvailableLoggingDrivers {
+		_, ok := dockerclient.LoggingDriverMinimumVersion[driver]
+		if !ok {
+			badDrivers = append(badDrivers, string(driver))
+		}
+	}
+	if len(badDrivers) > 0 {
+		problems = append(problems, "Invalid logging drivers: "+strings.Join(badDrivers, ", "))
+	}
+
+	if config.DockerMinimumAPIVersion != "" {
+		supported := false
+		for _, version := range dockerclient.SupportedVersions() {
+			if version == config.DockerMinimumAPIVersion {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			problems = append(problems, fmt.Sprintf("Invalid DockerMinimumAPIVersion: %s", config.DockerMinimumAPIVersion))
+		}
+	}
+
+	problems = append(problems, config.instanceAttributeProblems()...)
+
+	var reservedLabels []string
+	for name := range config.ContainerLabels {
+		if strings.HasPrefix(name, reservedContainerLabelPrefix) {
+			reservedLabels = append(reservedLabels, name)
+		}
+	}
+	if len(reservedLabels) > 0 {
+		sort.Strings(reservedLabels)
+		problems = append(problems, fmt.Sprintf("Invalid ContainerLabels %s: names starting with %q are reserved", strings.Join(reservedLabels, ", "), reservedContainerLabelPrefix))
+	}
+
+	if config.BindMountsDisabled && len(config.BindMountAllowedPaths) > 0 {
+		problems = append(problems, "BindMountsDisabled and BindMountAllowedPaths can't both be set: no host paths may be bind mounted when bind mounts are disabled")
+	}
+
+	if len(problems) > 0 {
+		return InvalidConfigError{problems}
+	}
 	return nil
 }
 
+// instanceAttributeProblems checks the custom attributes against the limits
+// ECS imposes on the attributes of container instances
+func (config *Config) instanceAttributeProblems() []string {
+	var problems []string
+	if len(config.InstanceAttributes) > MaximumInstanceAttributes {
+		problems = append(problems, fmt.Sprintf("Too many InstanceAttributes: %d, at most %d are allowed", len(config.InstanceAttributes), MaximumInstanceAttributes))
+	}
+	names := make([]string, 0, len(config.InstanceAttributes))
+	for name := range config.InstanceAttributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := config.InstanceAttributes[name]
+		switch {
+		case strings.HasPrefix(name, reservedInstanceAttributePrefix):
+			problems = append(problems, fmt.Sprintf("Invalid InstanceAttributes name %q: names starting with %q are reserved", name, reservedInstanceAttributePrefix))
+		case len(name) > maximumInstanceAttributeLength || !instanceAttributeNamePattern.MatchString(name):
+			problems = append(problems, fmt.Sprintf("Invalid InstanceAttributes name %q: expected up to %d letters, numbers, hyphens, underscores, periods and slashes", name, maximumInstanceAttributeLength))
+		}
+		if value != "" && (len(value) > maximumInstanceAttributeLength || !instanceAttributeValuePattern.MatchString(value)) {
+			problems = append(problems, fmt.Sprintf("Invalid InstanceAttributes value %q of %s: expected up to %d letters, numbers, hyphens, underscores, periods, slashes, colons, at signs and spaces, not starting or ending with a space", value, name, maximumInstanceAttributeLength))
+		}
+	}
+	return problems
+}
+
+// validateJSONFileLogRotation discards invalid json-file log rotation options
+// and lowers defaults that are above their limits to the limits
+func (config *Config) validateJSONFileLogRotation() {
+	if config.JSONFileMaxSize != "" {
+		if size, err := units.RAMInBytes(config.JSONFileMaxSize); err != nil || size <= 0 {
+			seelog.Warnf("Invalid value for json-file max size, will be ignored. Parsed value: %s", config.JSONFileMaxSize)
+			config.JSONFileMaxSize = ""
+		}
+	}
+	if config.JSONFileMaxSizeLimit != "" {
+		if size, err := units.RAMInBytes(config.JSONFileMaxSizeLimit); err != nil || size <= 0 {
+			seelog.Warnf("Invalid value for json-file max size limit, will be ignored. Parsed value: %s", config.JSONFileMaxSizeLimit)
+			config.JSONFileMaxSizeLimit = ""
+		}
+	}
+	if config.JSONFileMaxFiles < 0 {
+		seelog.Warnf("Invalid value for json-file max files, will be ignored. Parsed value: %d", config.JSONFileMaxFiles)
+		config.JSONFileMaxFiles = 0
+	}
+	if config.JSONFileMaxFilesLimit < 0 {
+		seelog.Warnf("Invalid value for json-file max files limit, will be ignored. Parsed value: %d", config.JSONFileMaxFilesLimit)
+		config.JSONFileMaxFilesLimit = 0
+	}
+
+	if config.JSONFileMaxSize != "" && config.JSONFileMaxSizeLimit != "" {
+		size, _ := units.RAMInBytes(config.JSONFileMaxSize)
+		limit, _ := units.RAMInBytes(config.JSONFileMaxSizeLimit)
+		if size > limit {
+			seelog.Warnf("Invalid value for json-file max size, will be overridden with the limit: %s. Parsed value: %s", config.JSONFileMaxSizeLimit, config.JSONFileMaxSize)
+			config.JSONFileMaxSize = config.JSONFileMaxSizeLimit
+		}
+	}
+	if config.JSONFileMaxFilesLimit > 0 && config.JSONFileMaxFiles > config.JSONFileMaxFilesLimit {
+		seelog.Warnf("Invalid value for json-file max files, will be overridden with the limit: %d. Parsed value: %d", config.JSONFileMaxFilesLimit, config.JSONFileMaxFiles)
+		config.JSONFileMaxFiles = config.JSONFileMaxFilesLimit
+	}
+}
+
 // String returns a lossy string representation of the config suitable for human readable display.
 // Consequently, it *should not* return any sensitive information.
 func (config *Config) String() string {
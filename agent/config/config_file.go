@@ -0,0 +1,135 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+	"github.com/cihub/seelog"
+)
+
+const defaultConfigFile = "/etc/ecs_container_agent/config.json"
+
+// configFilePath returns the path of the config file. ECS_AGENT_CONFIG_FILE_PATH
+// is the name the path was first read from
+func configFilePath() string {
+	path := os.Getenv("ECS_AGENT_CONFIG_FILE")
+	if path == "" {
+		path = os.Getenv("ECS_AGENT_CONFIG_FILE_PATH")
+	}
+	return utils.DefaultIfBlank(path, defaultConfigFile)
+}
+
+// AgentPaths returns the paths on the instance holding the config and data of
+// the agent, which may hold credentials and must never be read on behalf of
+// containers
+func (config *Config) AgentPaths() []string {
+	paths := []string{agentConfigDir(), filepath.Dir(configFilePath())}
+	for _, dir := range []string{config.DataDir, config.HostDataDir} {
+		if dir != "" {
+			paths = append(paths, dir)
+		}
+	}
+	return paths
+}
+
+// fileConfig reads the config file, if there is one. The keys of the file are
+// the names of the fields of Config. Unknown keys are ignored with a warning,
+// and an error is returned for values of the wrong type, along with the rest
+// of the config
+func fileConfig() (Config, error) {
+	path := configFilePath()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			seelog.Errorf("Unable to read config file, err %v", err)
+		}
+		return Config{}, nil
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		// empty file, not an error
+		return Config{}, nil
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			return Config{}, fmt.Errorf("Unable to parse config file %s, YAML isn't supported, only JSON: %v", path, err)
+		}
+		return Config{}, fmt.Errorf("Unable to parse config file %s: %v", path, err)
+	}
+
+	config, err := configFromFields(fields)
+	if err != nil {
+		err = fmt.Errorf("Invalid config file %s: %v", path, err)
+	}
+
+	// Handle any deprecated keys correctly here
+	if utils.ZeroOrNil(config.Cluster) && !utils.ZeroOrNil(config.ClusterArn) {
+		config.Cluster = config.ClusterArn
+	}
+	return config, err
+}
+
+// configFromFields sets the fields of a config to the json values of the
+// fields by name. Durations may be given as strings, such as "30s", as well as
+// in nanoseconds
+func configFromFields(fields map[string]json.RawMessage) (Config, error) {
+	config := Config{}
+	configValue := reflect.ValueOf(&config).Elem()
+	configType := configValue.Type()
+	durationType := reflect.TypeOf(time.Duration(0))
+
+	var invalid []string
+	for key, value := range fields {
+		structField, ok := configType.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, key)
+		})
+		if !ok {
+			seelog.Warnf("Unknown key in config file, ignoring, key: %s", key)
+			continue
+		}
+		field := configValue.FieldByIndex(structField.Index)
+		var err error
+		if field.Type() == durationType && strings.HasPrefix(strings.TrimSpace(string(value)), "\"") {
+			var duration string
+			var parsed time.Duration
+			err = json.Unmarshal(value, &duration)
+			if err == nil {
+				parsed, err = time.ParseDuration(duration)
+				field.SetInt(int64(parsed))
+			}
+		} else {
+			err = json.Unmarshal(value, field.Addr().Interface())
+		}
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s must be of type %s", structField.Name, structField.Type))
+		}
+	}
+	if len(invalid) > 0 {
+		return config, errors.New(strings.Join(invalid, ", "))
+	}
+	return config, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfigFile writes a config file with the given name to a temporary
+// directory and points ECS_AGENT_CONFIG_FILE at it. The returned func removes
+// the directory
+func writeConfigFile(t *testing.T, name string, contents string) func() {
+	dir, err := ioutil.TempDir("", "ecs-agent-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("ECS_AGENT_CONFIG_FILE", path)
+	return func() { os.RemoveAll(dir) }
+}
+
+const jsonConfigFile = `{
+	"Cluster": "fileCluster",
+	"ReservedMemory": 64,
+	"TaskCleanupWaitDuration": "2h",
+	"ImageCleanupInterval": 3600000000000,
+	"ReservedPorts": [22, 80],
+	"EngineAuthCredentialHelpers": {"registry.example.com": "helper"}
+}`
+
+func TestFileConfig(t *testing.T) {
+	os.Clearenv()
+	defer writeConfigFile(t, "config.json", jsonConfigFile)()
+
+	cfg, err := fileConfig()
+	if assert.Nil(t, err) {
+		assert.Equal(t, "fileCluster", cfg.Cluster)
+		assert.Equal(t, uint16(64), cfg.ReservedMemory)
+		assert.Equal(t, 2*time.Hour, cfg.TaskCleanupWaitDuration)
+		assert.Equal(t, time.Hour, cfg.ImageCleanupInterval)
+		assert.Equal(t, []uint16{22, 80}, cfg.ReservedPorts)
+		assert.Equal(t, map[string]string{"registry.example.com": "helper"}, cfg.EngineAuthCredentialHelpers)
+	}
+}
+
+func TestFileConfigUnknownKey(t *testing.T) {
+	os.Clearenv()
+	defer writeConfigFile(t, "config.json", `{"Cluster": "fileCluster", "NotAConfigKey": true}`)()
+
+	cfg, err := fileConfig()
+	assert.Nil(t, err, "Unknown keys should be ignored")
+	assert.Equal(t, "fileCluster", cfg.Cluster)
+}
+
+func TestFileConfigTypeMismatch(t *testing.T) {
+	os.Clearenv()
+	defer writeConfigFile(t, "config.json", `{"Cluster": "fileCluster", "ReservedMemory": "lots"}`)()
+
+	cfg, err := fileConfig()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "ReservedMemory must be of type uint16")
+	}
+	assert.Equal(t, "fileCluster", cfg.Cluster)
+}
+
+func TestFileConfigInvalidDuration(t *testing.T) {
+	os.Clearenv()
+	defer writeConfigFile(t, "config.json", `{"TaskCleanupWaitDuration": "soon"}`)()
+
+	_, err := fileConfig()
+	assert.NotNil(t, err)
+}
+
+func TestFileConfigNotAnObject(t *testing.T) {
+	os.Clearenv()
+	defer writeConfigFile(t, "config.json", `["Cluster"]`)()
+
+	_, err := fileConfig()
+	assert.NotNil(t, err)
+}
+
+func TestFileConfigYAML(t *testing.T) {
+	os.Clearenv()
+	defer writeConfigFile(t, "config.yaml", "Cluster: fileCluster\n")()
+
+	_, err := fileConfig()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "YAML isn't supported")
+	}
+}
+
+func TestFileConfigMissing(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ECS_AGENT_CONFIG_FILE", "/does/not/exist/config.json")
+
+	cfg, err := fileConfig()
+	assert.Nil(t, err, "A missing config file should not be an error")
+	assert.Equal(t, Config{}, cfg)
+}
+
+func TestFileConfigPathFallback(t *testing.T) {
+	os.Clearenv()
+	removeFile := writeConfigFile(t, "config.json", `{"Cluster": "fileCluster"}`)
+	defer removeFile()
+	os.Setenv("ECS_AGENT_CONFIG_FILE_PATH", os.Getenv("ECS_AGENT_CONFIG_FILE"))
+	os.Unsetenv("ECS_AGENT_CONFIG_FILE")
+
+	cfg, err := fileConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "fileCluster", cfg.Cluster)
+}
+
+func TestNewConfigFileOnly(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("AWS_DEFAULT_REGION", "us-west-2")
+	defer writeConfigFile(t, "config.json", jsonConfigFile)()
+
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "fileCluster", cfg.Cluster)
+		assert.Equal(t, uint16(64), cfg.ReservedMemory)
+	}
+}
+
+func TestNewConfigEnvironmentOnly(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("AWS_DEFAULT_REGION", "us-west-2")
+	os.Setenv("ECS_AGENT_CONFIG_FILE", "/does/not/exist/config.json")
+	os.Setenv("ECS_CLUSTER", "envCluster")
+	os.Setenv("ECS_RESERVED_MEMORY", "32")
+
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "envCluster", cfg.Cluster)
+		assert.Equal(t, uint16(32), cfg.ReservedMemory)
+	}
+}
+
+func TestNewConfigEnvironmentOverridesFile(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("AWS_DEFAULT_REGION", "us-west-2")
+	os.Setenv("ECS_CLUSTER", "envCluster")
+	defer writeConfigFile(t, "config.json", jsonConfigFile)()
+
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "envCluster", cfg.Cluster, "The environment should override the file")
+		assert.Equal(t, uint16(64), cfg.ReservedMemory, "Keys only in the file should be merged in")
+		assert.Equal(t, 2*time.Hour, cfg.TaskCleanupWaitDuration)
+	}
+}
+
+func TestNewConfigFileTypeMismatch(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("AWS_DEFAULT_REGION", "us-west-2")
+	defer writeConfigFile(t, "config.json", `{"Cluster": "fileCluster", "ReservedMemory": "lots"}`)()
+
+	_, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NotNil(t, err, "A value of the wrong type in the config file should be an error")
+}
@@ -17,6 +17,9 @@ import (
 	"errors"
 	"os"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -81,11 +84,13 @@ func TestEnvironmentConfig(t *testing.T) {
 	os.Setenv("ECS_CLUSTER", "myCluster")
 	os.Setenv("ECS_RESERVED_PORTS_UDP", "[42,99]")
 	os.Setenv("ECS_RESERVED_MEMORY", "20")
+	os.Setenv("ECS_RESERVED_CPU", "256")
 	os.Setenv("ECS_CONTAINER_STOP_TIMEOUT", "60s")
 	os.Setenv("ECS_AVAILABLE_LOGGING_DRIVERS", "[\""+string(dockerclient.SyslogDriver)+"\"]")
 	os.Setenv("ECS_SELINUX_CAPABLE", "true")
 	os.Setenv("ECS_APPARMOR_CAPABLE", "true")
 	os.Setenv("ECS_DISABLE_PRIVILEGED", "true")
+	os.Setenv("ECS_STRICT_PRIVILEGED_CHECKING", "true")
 	os.Setenv("ECS_ENGINE_TASK_CLEANUP_WAIT_DURATION", "90s")
 	os.Setenv("ECS_ENABLE_TASK_IAM_ROLE", "true")
 	os.Setenv("ECS_ENABLE_TASK_IAM_ROLE_NETWORK_HOST", "true")
@@ -93,6 +98,55 @@ func TestEnvironmentConfig(t *testing.T) {
 	os.Setenv("ECS_IMAGE_CLEANUP_INTERVAL", "2h")
 	os.Setenv("ECS_IMAGE_MINIMUM_CLEANUP_AGE", "30m")
 	os.Setenv("ECS_NUM_IMAGES_DELETE_PER_CYCLE", "2")
+	os.Setenv("ECS_DOCKER_MINIMUM_API_VERSION", "1.22")
+	os.Setenv("ECS_ACS_RECONNECT_JITTER_MIN", "0.1")
+	os.Setenv("ECS_ACS_RECONNECT_JITTER_MAX", "0.5")
+	os.Setenv("ECS_DRAIN_TIMEOUT", "10m")
+	os.Setenv("ECS_CONTAINER_STOP_CONCURRENCY", "3")
+	os.Setenv("ECS_IMAGE_PULL_MAX_RETRIES", "5")
+	os.Setenv("ECS_JSON_FILE_MAX_SIZE", "10m")
+	os.Setenv("ECS_JSON_FILE_MAX_FILES", "3")
+	os.Setenv("ECS_JSON_FILE_MAX_SIZE_LIMIT", "100m")
+	os.Setenv("ECS_JSON_FILE_MAX_FILES_LIMIT", "10")
+	os.Setenv("ECS_CONTAINER_STATE_MODE", "hybrid")
+	os.Setenv("ECS_CONTAINER_SHM_SIZE_LIMIT", "512")
+	os.Setenv("ECS_ENABLE_GPU_SUPPORT", "true")
+	os.Setenv("ECS_ALLOWED_CAPABILITIES", `["NET_ADMIN","SYS_PTRACE"]`)
+	os.Setenv("ECS_ALLOWED_SYSCTLS", `["net.core.somaxconn","net.ipv4.*"]`)
+	os.Setenv("ECS_STRICT_DEVICE_CHECKING", "true")
+	os.Setenv("ECS_BIND_MOUNT_ALLOWED_PATHS", `["/data","/var/log"]`)
+	os.Setenv("ECS_BIND_MOUNT_DENIED_PATHS", `["/data/secrets"]`)
+	os.Setenv("ECS_DISABLE_BIND_MOUNTS", "true")
+	// Disabling bind mounts while allowing paths is invalid, so the config
+	// would fail to load in the tests that follow
+	defer os.Unsetenv("ECS_DISABLE_BIND_MOUNTS")
+	os.Setenv("ECS_CONTAINER_CREATE_TIMEOUT", "10m")
+	os.Setenv("ECS_CONTAINER_START_TIMEOUT", "2m")
+	os.Setenv("ECS_IMAGE_CLEANUP_EXCLUSION", `["base:1.0","tools:*"]`)
+	os.Setenv("ECS_POLL_METRICS", "true")
+	os.Setenv("ECS_POLLING_METRICS_WAIT_DURATION", "15s")
+	os.Setenv("ECS_METRICS_DIMENSION_LABELS", "[\"app\",\"team\"]")
+	os.Setenv("ECS_STATE_SAVE_INTERVAL", "5s")
+	os.Setenv("ECS_INSTANCE_ATTRIBUTES", `{"stack":"prod","rack":"a1"}`)
+	os.Setenv("ECS_IMAGE_CLEANUP_DISK_THRESHOLD", "15")
+	os.Setenv("ECS_IMAGE_CLEANUP_DISK_PATH", "/mnt/docker")
+	os.Setenv("ECS_IMAGE_PULL_BEHAVIOR", "prefer-cached")
+	os.Setenv("ECS_MAX_CONCURRENT_TASK_LAUNCHES", "4")
+	os.Setenv("ECS_ENABLE_CONTAINER_LOGS_ENDPOINT", "true")
+	os.Setenv("ECS_ENABLE_LOG_LEVEL_ENDPOINT", "true")
+	os.Setenv("ECS_ENABLE_LOCAL_ENVIRONMENT_FILES", "true")
+	os.Setenv("ECS_ALLOW_SECCOMP_UNCONFINED", "true")
+	os.Setenv("ECS_SECCOMP_PROFILE_DIR", "/opt/seccomp")
+	os.Setenv("ECS_STRICT_APPARMOR_CHECKING", "true")
+	os.Setenv("ECS_DISABLE_HOST_NAMESPACES", "true")
+	os.Setenv("ECS_MAX_CONTAINER_RESTARTS", "3")
+	os.Setenv("ECS_ENABLE_ECR_IMAGE_AUTH", "true")
+	os.Setenv("ECS_CONTAINER_LABELS", `{"team":"payments"}`)
+	os.Setenv("ECS_CPU_LIMIT_MODE", "quota")
+	os.Setenv("ECS_ENABLE_CONTAINER_METADATA", "true")
+	os.Setenv("ECS_HOST_DATA_DIR", "/srv/ecs")
+	os.Setenv("ECS_ACS_HEARTBEAT_TIMEOUT", "2m")
+	os.Setenv("ECS_ACS_HEARTBEAT_GRACE_FACTOR", "0.25")
 
 	conf := environmentConfig()
 	if conf.Cluster != "myCluster" {
@@ -107,6 +161,9 @@ func TestEnvironmentConfig(t *testing.T) {
 	if conf.ReservedMemory != 20 {
 		t.Error("Wrong value for ReservedMemory", conf.ReservedMemory)
 	}
+	if conf.ReservedCPU != 256 {
+		t.Error("Wrong value for ReservedCPU", conf.ReservedCPU)
+	}
 	expectedDuration, _ := time.ParseDuration("60s")
 	if conf.DockerStopTimeout != expectedDuration {
 		t.Error("Wrong value for DockerStopTimeout", conf.DockerStopTimeout)
@@ -118,6 +175,9 @@ func TestEnvironmentConfig(t *testing.T) {
 	if !conf.PrivilegedDisabled {
 		t.Error("Wrong value for PrivilegedDisabled")
 	}
+	if !conf.StrictPrivilegedChecking {
+		t.Error("Wrong value for StrictPrivilegedChecking")
+	}
 	if !conf.SELinuxCapable {
 		t.Error("Wrong value for SELinuxCapable")
 	}
@@ -145,6 +205,129 @@ func TestEnvironmentConfig(t *testing.T) {
 	if conf.NumImagesToDeletePerCycle != 2 {
 		t.Error("Wrong value for NumImagesToDeletePerCycle")
 	}
+	if conf.DockerMinimumAPIVersion != dockerclient.Version_1_22 {
+		t.Error("Wrong value for DockerMinimumAPIVersion", conf.DockerMinimumAPIVersion)
+	}
+	if conf.ACSReconnectJitterMin != 0.1 || conf.ACSReconnectJitterMax != 0.5 {
+		t.Errorf("Wrong value for ACS reconnect jitter: %v-%v", conf.ACSReconnectJitterMin, conf.ACSReconnectJitterMax)
+	}
+	if !reflect.DeepEqual(conf.ImageCleanupExclusionList, []string{"base:1.0", "tools:*"}) {
+		t.Error("Wrong value for ImageCleanupExclusionList", conf.ImageCleanupExclusionList)
+	}
+	if conf.DrainTimeout != 10*time.Minute {
+		t.Error("Wrong value for DrainTimeout", conf.DrainTimeout)
+	}
+	if conf.ContainerStopConcurrency != 3 {
+		t.Error("Wrong value for ContainerStopConcurrency", conf.ContainerStopConcurrency)
+	}
+	if conf.ImagePullMaxAttempts != 6 {
+		t.Error("Wrong value for ImagePullMaxAttempts", conf.ImagePullMaxAttempts)
+	}
+	if conf.JSONFileMaxSize != "10m" || conf.JSONFileMaxFiles != 3 {
+		t.Errorf("Wrong value for json-file log rotation: %s, %d", conf.JSONFileMaxSize, conf.JSONFileMaxFiles)
+	}
+	if conf.JSONFileMaxSizeLimit != "100m" || conf.JSONFileMaxFilesLimit != 10 {
+		t.Errorf("Wrong value for json-file log rotation limits: %s, %d", conf.JSONFileMaxSizeLimit, conf.JSONFileMaxFilesLimit)
+	}
+	if conf.ContainerStateMode != ContainerStateModeHybrid {
+		t.Error("Wrong value for ContainerStateMode", conf.ContainerStateMode)
+	}
+	if conf.ContainerShmSizeLimit != 512 {
+		t.Error("Wrong value for ContainerShmSizeLimit", conf.ContainerShmSizeLimit)
+	}
+	if !conf.GPUSupportEnabled {
+		t.Error("Wrong value for GPUSupportEnabled")
+	}
+	if !reflect.DeepEqual(conf.AllowedCapabilities, []string{"NET_ADMIN", "SYS_PTRACE"}) {
+		t.Error("Wrong value for AllowedCapabilities", conf.AllowedCapabilities)
+	}
+	if !conf.StrictDeviceChecking {
+		t.Error("Wrong value for StrictDeviceChecking")
+	}
+	if !reflect.DeepEqual(conf.BindMountAllowedPaths, []string{"/data", "/var/log"}) {
+		t.Error("Wrong value for BindMountAllowedPaths", conf.BindMountAllowedPaths)
+	}
+	if !reflect.DeepEqual(conf.BindMountDeniedPaths, []string{"/data/secrets"}) {
+		t.Error("Wrong value for BindMountDeniedPaths", conf.BindMountDeniedPaths)
+	}
+	if !conf.BindMountsDisabled {
+		t.Error("Wrong value for BindMountsDisabled")
+	}
+	if conf.ContainerCreateTimeout != 10*time.Minute || conf.ContainerStartTimeout != 2*time.Minute {
+		t.Errorf("Wrong value for container create and start timeouts: %v, %v", conf.ContainerCreateTimeout, conf.ContainerStartTimeout)
+	}
+	if !conf.PollMetrics || conf.PollingMetricsWaitDuration != 15*time.Second {
+		t.Errorf("Wrong value for metrics polling: %v, %v", conf.PollMetrics, conf.PollingMetricsWaitDuration)
+	}
+	if !reflect.DeepEqual(conf.MetricsDimensionLabels, []string{"app", "team"}) {
+		t.Error("Wrong value for MetricsDimensionLabels", conf.MetricsDimensionLabels)
+	}
+	if conf.StateSaveInterval != 5*time.Second {
+		t.Error("Wrong value for StateSaveInterval", conf.StateSaveInterval)
+	}
+	if !reflect.DeepEqual(conf.InstanceAttributes, map[string]string{"stack": "prod", "rack": "a1"}) {
+		t.Error("Wrong value for InstanceAttributes", conf.InstanceAttributes)
+	}
+	if conf.ImageCleanupDiskThreshold != 15 {
+		t.Error("Wrong value for ImageCleanupDiskThreshold", conf.ImageCleanupDiskThreshold)
+	}
+	if conf.ImageCleanupDiskPath != "/mnt/docker" {
+		t.Error("Wrong value for ImageCleanupDiskPath", conf.ImageCleanupDiskPath)
+	}
+	if conf.ImagePullBehavior != ImagePullBehaviorPreferCached {
+		t.Error("Wrong value for ImagePullBehavior", conf.ImagePullBehavior)
+	}
+	if conf.MaxConcurrentTaskLaunches != 4 {
+		t.Error("Wrong value for MaxConcurrentTaskLaunches", conf.MaxConcurrentTaskLaunches)
+	}
+	if !conf.ContainerLogsEndpointEnabled {
+		t.Error("Wrong value for ContainerLogsEndpointEnabled")
+	}
+	if !conf.LogLevelEndpointEnabled {
+		t.Error("Wrong value for LogLevelEndpointEnabled")
+	}
+	if !conf.LocalEnvironmentFilesEnabled {
+		t.Error("Wrong value for LocalEnvironmentFilesEnabled")
+	}
+	if !conf.SeccompUnconfinedAllowed {
+		t.Error("Wrong value for SeccompUnconfinedAllowed")
+	}
+	if conf.SeccompProfileDir != "/opt/seccomp" {
+		t.Error("Wrong value for SeccompProfileDir", conf.SeccompProfileDir)
+	}
+	if !conf.StrictAppArmorChecking {
+		t.Error("Wrong value for StrictAppArmorChecking")
+	}
+	if !conf.HostNamespacesDisabled {
+		t.Error("Wrong value for HostNamespacesDisabled")
+	}
+	if conf.MaxContainerRestarts != 3 {
+		t.Error("Wrong value for MaxContainerRestarts", conf.MaxContainerRestarts)
+	}
+	if !conf.ECRImageAuthEnabled {
+		t.Error("Wrong value for ECRImageAuthEnabled")
+	}
+	if !reflect.DeepEqual(conf.ContainerLabels, map[string]string{"team": "payments"}) {
+		t.Error("Wrong value for ContainerLabels", conf.ContainerLabels)
+	}
+	if conf.CPULimitMode != CPULimitModeQuota {
+		t.Error("Wrong value for CPULimitMode", conf.CPULimitMode)
+	}
+	if !conf.ContainerMetadataEnabled {
+		t.Error("Wrong value for ContainerMetadataEnabled")
+	}
+	if conf.HostDataDir != "/srv/ecs" {
+		t.Error("Wrong value for HostDataDir", conf.HostDataDir)
+	}
+	if conf.ACSHeartbeatTimeout != 2*time.Minute {
+		t.Error("Wrong value for ACSHeartbeatTimeout", conf.ACSHeartbeatTimeout)
+	}
+	if conf.ACSHeartbeatGraceFactor != 0.25 {
+		t.Error("Wrong value for ACSHeartbeatGraceFactor", conf.ACSHeartbeatGraceFactor)
+	}
+	if !reflect.DeepEqual(conf.AllowedSysctls, []string{"net.core.somaxconn", "net.ipv4.*"}) {
+		t.Error("Wrong value for AllowedSysctls", conf.AllowedSysctls)
+	}
 }
 
 func TestTrimWhitespace(t *testing.T) {
@@ -204,6 +387,143 @@ func TestInvalidLoggingDriver(t *testing.T) {
 	}
 }
 
+func TestInvalidDockerMinimumAPIVersion(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.DockerMinimumAPIVersion = "1.0"
+
+	err := conf.validateAndOverrideBounds()
+	if err == nil {
+		t.Error("Should be error with unsupported DockerMinimumAPIVersion")
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	conf := DefaultConfig()
+	conf.Cluster = "my cluster"
+	conf.ReservedPorts = []uint16{22, 0}
+	conf.DockerStopTimeout = -1 * time.Second
+	conf.DrainTimeout = -1 * time.Minute
+	conf.AvailableLoggingDrivers = []dockerclient.LoggingDriver{"invalid-logging-driver"}
+	conf.BindMountsDisabled = true
+	conf.BindMountAllowedPaths = []string{"/data"}
+
+	err := conf.validateAndOverrideBounds()
+	invalidErr, ok := err.(InvalidConfigError)
+	if !ok {
+		t.Fatalf("Expected an InvalidConfigError, got: %v", err)
+	}
+	expected := []string{
+		"Missing required fields: AWSRegion",
+		"Invalid Cluster \"my cluster\": expected a cluster ARN or up to 255 letters, numbers, hyphens and underscores",
+		"Invalid ReservedPorts: ports must be between 1 and 65535",
+		"Invalid negative DockerStopTimeout: -1s",
+		"Invalid negative DrainTimeout: -1m0s",
+		"Invalid logging drivers: invalid-logging-driver",
+		"BindMountsDisabled and BindMountAllowedPaths can't both be set: no host paths may be bind mounted when bind mounts are disabled",
+	}
+	if !reflect.DeepEqual(invalidErr.Problems, expected) {
+		t.Errorf("Wrong problems, expected %v, got %v", expected, invalidErr.Problems)
+	}
+}
+
+func TestInvalidFormatInstanceAttributes(t *testing.T) {
+	os.Setenv("ECS_INSTANCE_ATTRIBUTES", `["stack"]`)
+	defer os.Unsetenv("ECS_INSTANCE_ATTRIBUTES")
+
+	conf := environmentConfig()
+	if len(conf.InstanceAttributes) != 0 {
+		t.Error("Wrong value for InstanceAttributes", conf.InstanceAttributes)
+	}
+}
+
+func TestInvalidFormatContainerLabels(t *testing.T) {
+	os.Setenv("ECS_CONTAINER_LABELS", `["team"]`)
+	defer os.Unsetenv("ECS_CONTAINER_LABELS")
+
+	conf := environmentConfig()
+	if len(conf.ContainerLabels) != 0 {
+		t.Error("Wrong value for ContainerLabels", conf.ContainerLabels)
+	}
+}
+
+func TestReservedContainerLabels(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.ContainerLabels = map[string]string{
+		"team":                       "payments",
+		"com.amazonaws.ecs.task-arn": "arn",
+		"com.amazonaws.ecs.cluster":  "cluster",
+	}
+	err := conf.Validate()
+	expected := `Invalid ContainerLabels com.amazonaws.ecs.cluster, com.amazonaws.ecs.task-arn: names starting with "com.amazonaws.ecs." are reserved`
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Error("Expected an error for reserved labels, got:", err)
+	}
+}
+
+func TestValidateInstanceAttributes(t *testing.T) {
+	longName := strings.Repeat("a", 129)
+	testCases := []struct {
+		attributes map[string]string
+		problems   []string
+	}{
+		{
+			attributes: map[string]string{"stack": "prod", "rack/row": "a1:b2", "zone.id": "us west@1", "gpu": ""},
+		},
+		{
+			attributes: map[string]string{"ecs.os-type": "linux"},
+			problems:   []string{`Invalid InstanceAttributes name "ecs.os-type": names starting with "ecs." are reserved`},
+		},
+		{
+			attributes: map[string]string{longName: "a", "has space": "a"},
+			problems: []string{
+				`Invalid InstanceAttributes name "` + longName + `": expected up to 128 letters, numbers, hyphens, underscores, periods and slashes`,
+				`Invalid InstanceAttributes name "has space": expected up to 128 letters, numbers, hyphens, underscores, periods and slashes`,
+			},
+		},
+		{
+			attributes: map[string]string{"stack": " prod", "team": "a*b"},
+			problems: []string{
+				`Invalid InstanceAttributes value " prod" of stack: expected up to 128 letters, numbers, hyphens, underscores, periods, slashes, colons, at signs and spaces, not starting or ending with a space`,
+				`Invalid InstanceAttributes value "a*b" of team: expected up to 128 letters, numbers, hyphens, underscores, periods, slashes, colons, at signs and spaces, not starting or ending with a space`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		conf := Config{InstanceAttributes: tc.attributes}
+		if problems := conf.instanceAttributeProblems(); !reflect.DeepEqual(problems, tc.problems) {
+			t.Errorf("Wrong problems for %v, expected %v, got %v", tc.attributes, tc.problems, problems)
+		}
+	}
+
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.InstanceAttributes = make(map[string]string)
+	for i := 0; i <= MaximumInstanceAttributes; i++ {
+		conf.InstanceAttributes["attribute"+strconv.Itoa(i)] = "value"
+	}
+	err := conf.Validate()
+	if err == nil || !strings.Contains(err.Error(), "Too many InstanceAttributes: 11, at most 10 are allowed") {
+		t.Error("Expected an error for too many attributes, got:", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, cluster := range []string{"", "my-cluster_1", "arn:aws:ecs:us-west-2:123456789012:cluster/my-cluster"} {
+		conf := DefaultConfig()
+		conf.AWSRegion = "us-west-2"
+		conf.Cluster = cluster
+		conf.ReservedPorts = []uint16{22, 65535}
+		conf.BindMountsDisabled = true
+		conf.BindMountDeniedPaths = []string{"/etc"}
+
+		if err := conf.Validate(); err != nil {
+			t.Errorf("Expected no error for cluster %q, got: %v", cluster, err)
+		}
+	}
+}
+
 func TestInvalidFormatDockerStopTimeout(t *testing.T) {
 	os.Setenv("ECS_CONTAINER_STOP_TIMEOUT", "invalid")
 	conf := environmentConfig()
@@ -230,6 +550,20 @@ func TestInvalideDockerStopTimeout(t *testing.T) {
 	}
 }
 
+func TestDockerStopTimeoutClampedToMaximum(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.DockerStopTimeout = 24 * time.Hour
+
+	err := conf.validateAndOverrideBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DockerStopTimeout != MaximumDockerStopTimeout {
+		t.Error("Wrong value for DockerStopTimeout", conf.DockerStopTimeout)
+	}
+}
+
 func TestInvalidFormatParseEnvVariableUint16(t *testing.T) {
 	os.Setenv("FOO", "foo")
 	var16 := parseEnvVariableUint16("FOO")
@@ -318,6 +652,23 @@ func TestReservedMemory(t *testing.T) {
 	}
 }
 
+func TestReservedCPUOverReservation(t *testing.T) {
+	defer func() { numCPU = runtime.NumCPU }()
+	numCPU = func() int { return 2 }
+
+	for reserved, expected := range map[string]uint16{"2047": 2047, "2048": 0, "4096": 0} {
+		os.Setenv("ECS_RESERVED_CPU", reserved)
+		cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.ReservedCPU != expected {
+			t.Errorf("Wrong value for ReservedCPU of %s. Expected %d, got %d", reserved, expected, cfg.ReservedCPU)
+		}
+	}
+	os.Unsetenv("ECS_RESERVED_CPU")
+}
+
 func TestTaskIAMRoleEnabled(t *testing.T) {
 	os.Setenv("ECS_ENABLE_TASK_IAM_ROLE", "true")
 	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
@@ -390,3 +741,340 @@ func TestImageCleanupMinimumNumImagesToDeletePerCycle(t *testing.T) {
 		t.Errorf("Wrong value for NumImagesToDeletePerCycle: %v", cfg.NumImagesToDeletePerCycle)
 	}
 }
+
+func TestMinimumContainerStopConcurrency(t *testing.T) {
+	os.Setenv("ECS_CONTAINER_STOP_CONCURRENCY", "0")
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ContainerStopConcurrency != DefaultContainerStopConcurrency {
+		t.Errorf("Wrong value for ContainerStopConcurrency: %v", cfg.ContainerStopConcurrency)
+	}
+}
+
+func TestImagePullRetriesDisabled(t *testing.T) {
+	os.Setenv("ECS_IMAGE_PULL_MAX_RETRIES", "0")
+	defer os.Unsetenv("ECS_IMAGE_PULL_MAX_RETRIES")
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ImagePullMaxAttempts != 1 {
+		t.Errorf("Wrong value for ImagePullMaxAttempts: %v", cfg.ImagePullMaxAttempts)
+	}
+}
+
+func TestInvalidImagePullMaxRetries(t *testing.T) {
+	os.Setenv("ECS_IMAGE_PULL_MAX_RETRIES", "-1")
+	defer os.Unsetenv("ECS_IMAGE_PULL_MAX_RETRIES")
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ImagePullMaxAttempts != DefaultImagePullMaxRetries+1 {
+		t.Errorf("Wrong value for ImagePullMaxAttempts: %v", cfg.ImagePullMaxAttempts)
+	}
+}
+
+func TestJSONFileLogRotationAboveLimit(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.JSONFileMaxSize = "1g"
+	conf.JSONFileMaxFiles = 20
+	conf.JSONFileMaxSizeLimit = "100m"
+	conf.JSONFileMaxFilesLimit = 10
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.JSONFileMaxSize != "100m" || conf.JSONFileMaxFiles != 10 {
+		t.Errorf("Expected json-file log rotation to be lowered to the limits, got: %s, %d", conf.JSONFileMaxSize, conf.JSONFileMaxFiles)
+	}
+}
+
+func TestInvalidJSONFileLogRotation(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.JSONFileMaxSize = "lots"
+	conf.JSONFileMaxFiles = -1
+	conf.JSONFileMaxSizeLimit = "-5m"
+	conf.JSONFileMaxFilesLimit = -1
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.JSONFileMaxSize != "" || conf.JSONFileMaxFiles != 0 || conf.JSONFileMaxSizeLimit != "" || conf.JSONFileMaxFilesLimit != 0 {
+		t.Errorf("Expected invalid json-file log rotation to be ignored, got: %s, %d, %s, %d", conf.JSONFileMaxSize, conf.JSONFileMaxFiles, conf.JSONFileMaxSizeLimit, conf.JSONFileMaxFilesLimit)
+	}
+}
+
+func TestInvalidContainerStateMode(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.ContainerStateMode = "inotify"
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.ContainerStateMode != ContainerStateModeEvents {
+		t.Errorf("Expected the default container state mode, got: %s", conf.ContainerStateMode)
+	}
+}
+
+func TestContainerMetadataWithoutDataDir(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.ContainerMetadataEnabled = true
+	conf.DataDir = ""
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.ContainerMetadataEnabled {
+		t.Error("Expected container metadata to be disabled without a data directory")
+	}
+}
+
+func TestInvalidCPULimitMode(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.CPULimitMode = "percent"
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.CPULimitMode != CPULimitModeShares {
+		t.Errorf("Expected the default cpu limit mode, got: %s", conf.CPULimitMode)
+	}
+}
+
+func TestInvalidImagePullBehavior(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.ImagePullBehavior = "never"
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.ImagePullBehavior != ImagePullBehaviorAlways {
+		t.Errorf("Expected the default image pull behavior, got: %s", conf.ImagePullBehavior)
+	}
+}
+
+func TestInvalidMaxConcurrentTaskLaunches(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.MaxConcurrentTaskLaunches = -1
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.MaxConcurrentTaskLaunches != 0 {
+		t.Errorf("Expected task launches not to be limited, got: %d", conf.MaxConcurrentTaskLaunches)
+	}
+}
+
+func TestInvalidMaxContainerRestarts(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.MaxContainerRestarts = -1
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.MaxContainerRestarts != 0 {
+		t.Errorf("Expected containers not to be restarted, got: %d", conf.MaxContainerRestarts)
+	}
+}
+
+func TestInvalidContainerShmSizeLimit(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.ContainerShmSizeLimit = -1
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.ContainerShmSizeLimit != 0 {
+		t.Errorf("Expected an invalid shared memory size limit to be ignored, got: %d", conf.ContainerShmSizeLimit)
+	}
+}
+
+func TestInvalidImageCleanupDiskThreshold(t *testing.T) {
+	for _, threshold := range []int{-1, 100, 150} {
+		conf := DefaultConfig()
+		conf.AWSRegion = "us-west-2"
+		conf.ImageCleanupDiskThreshold = threshold
+		if err := conf.validateAndOverrideBounds(); err != nil {
+			t.Fatal(err)
+		}
+
+		if conf.ImageCleanupDiskThreshold != 0 {
+			t.Errorf("Expected an invalid disk threshold of %d to be ignored, got: %d", threshold, conf.ImageCleanupDiskThreshold)
+		}
+	}
+}
+
+func TestInvalidContainerTransitionTimeouts(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.ContainerCreateTimeout = -1 * time.Second
+	conf.ContainerStartTimeout = time.Millisecond
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.ContainerCreateTimeout != DefaultContainerCreateTimeout || conf.ContainerStartTimeout != DefaultContainerStartTimeout {
+		t.Errorf("Expected the default container create and start timeouts, got: %v, %v", conf.ContainerCreateTimeout, conf.ContainerStartTimeout)
+	}
+}
+
+func TestPollingMetricsWaitDurationClamped(t *testing.T) {
+	testCases := []struct {
+		duration time.Duration
+		expected time.Duration
+	}{
+		{0, MinimumPollingMetricsWaitDuration},
+		{time.Second, MinimumPollingMetricsWaitDuration},
+		{15 * time.Second, 15 * time.Second},
+		{time.Minute, MaximumPollingMetricsWaitDuration},
+	}
+	for _, tc := range testCases {
+		conf := DefaultConfig()
+		conf.AWSRegion = "us-west-2"
+		conf.PollMetrics = true
+		conf.PollingMetricsWaitDuration = tc.duration
+		if err := conf.validateAndOverrideBounds(); err != nil {
+			t.Fatal(err)
+		}
+		if conf.PollingMetricsWaitDuration != tc.expected {
+			t.Errorf("Expected polling metrics wait duration %v for %v, got: %v", tc.expected, tc.duration, conf.PollingMetricsWaitDuration)
+		}
+	}
+}
+
+func TestStateSaveIntervalClamped(t *testing.T) {
+	testCases := []struct {
+		interval time.Duration
+		expected time.Duration
+	}{
+		{0, MinimumStateSaveInterval},
+		{time.Millisecond, MinimumStateSaveInterval},
+		{DefaultStateSaveInterval, DefaultStateSaveInterval},
+		{time.Hour, MaximumStateSaveInterval},
+	}
+	for _, tc := range testCases {
+		conf := DefaultConfig()
+		conf.AWSRegion = "us-west-2"
+		conf.StateSaveInterval = tc.interval
+		if err := conf.validateAndOverrideBounds(); err != nil {
+			t.Fatal(err)
+		}
+		if conf.StateSaveInterval != tc.expected {
+			t.Errorf("Expected state save interval %v for %v, got: %v", tc.expected, tc.interval, conf.StateSaveInterval)
+		}
+	}
+}
+
+func TestMetricsDimensionLabelsCapped(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+	conf.MetricsDimensionLabels = []string{"a", "b", "c", "d", "e", "f", "g"}
+	if err := conf.validateAndOverrideBounds(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(conf.MetricsDimensionLabels, []string{"a", "b", "c", "d", "e"}) {
+		t.Error("Expected the first metrics dimension labels to be kept, got:", conf.MetricsDimensionLabels)
+	}
+}
+
+func TestACSReconnectJitterDefault(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+
+	err := conf.validateAndOverrideBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.ACSReconnectJitterMin != DefaultACSReconnectJitterMin || conf.ACSReconnectJitterMax != DefaultACSReconnectJitterMax {
+		t.Errorf("Wrong value for ACS reconnect jitter: %v-%v", conf.ACSReconnectJitterMin, conf.ACSReconnectJitterMax)
+	}
+}
+
+func TestInvalidACSReconnectJitter(t *testing.T) {
+	testCases := []struct {
+		min float64
+		max float64
+	}{
+		{-0.1, 0.2},
+		{0.5, 0.2},
+		{0.1, 1.5},
+	}
+	for _, tc := range testCases {
+		conf := DefaultConfig()
+		conf.AWSRegion = "us-west-2"
+		conf.ACSReconnectJitterMin = tc.min
+		conf.ACSReconnectJitterMax = tc.max
+
+		err := conf.validateAndOverrideBounds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.ACSReconnectJitterMin != DefaultACSReconnectJitterMin || conf.ACSReconnectJitterMax != DefaultACSReconnectJitterMax {
+			t.Errorf("Expected jitter %v-%v to be overridden, got: %v-%v", tc.min, tc.max, conf.ACSReconnectJitterMin, conf.ACSReconnectJitterMax)
+		}
+	}
+}
+
+func TestACSHeartbeatDefault(t *testing.T) {
+	conf := DefaultConfig()
+	conf.AWSRegion = "us-west-2"
+
+	err := conf.validateAndOverrideBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.ACSHeartbeatTimeout != DefaultACSHeartbeatTimeout || conf.ACSHeartbeatGraceFactor != DefaultACSHeartbeatGraceFactor {
+		t.Errorf("Wrong value for ACS heartbeat: %v, grace factor %v", conf.ACSHeartbeatTimeout, conf.ACSHeartbeatGraceFactor)
+	}
+}
+
+func TestInvalidACSHeartbeat(t *testing.T) {
+	testCases := []struct {
+		timeout             time.Duration
+		graceFactor         float64
+		expectedTimeout     time.Duration
+		expectedGraceFactor float64
+	}{
+		{time.Second, 0.5, MinimumACSHeartbeatTimeout, 0.5},
+		{-time.Minute, 0.5, MinimumACSHeartbeatTimeout, 0.5},
+		{time.Minute, -0.1, time.Minute, DefaultACSHeartbeatGraceFactor},
+		{time.Minute, 1.5, time.Minute, DefaultACSHeartbeatGraceFactor},
+	}
+	for _, tc := range testCases {
+		conf := DefaultConfig()
+		conf.AWSRegion = "us-west-2"
+		conf.ACSHeartbeatTimeout = tc.timeout
+		conf.ACSHeartbeatGraceFactor = tc.graceFactor
+
+		err := conf.validateAndOverrideBounds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.ACSHeartbeatTimeout != tc.expectedTimeout || conf.ACSHeartbeatGraceFactor != tc.expectedGraceFactor {
+			t.Errorf("Expected heartbeat %v, grace factor %v to be overridden with %v, %v, got: %v, %v", tc.timeout, tc.graceFactor, tc.expectedTimeout, tc.expectedGraceFactor, conf.ACSHeartbeatTimeout, conf.ACSHeartbeatGraceFactor)
+		}
+	}
+}
+
+func TestInvalidFormatParseEnvVariableFloat64(t *testing.T) {
+	os.Setenv("FOO", "foo")
+	defer os.Unsetenv("FOO")
+	if parseEnvVariableFloat64("FOO") != 0 {
+		t.Error("Expected 0 for invalid format")
+	}
+}
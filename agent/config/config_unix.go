@@ -21,6 +21,12 @@ const (
 	defaultCredentialsAuditLogFile = "/log/audit.log"
 )
 
+// agentConfigDir returns the directory on the instance holding the config of
+// the agent, such as ecs.config
+func agentConfigDir() string {
+	return "/etc/ecs"
+}
+
 // DefaultConfig returns the default configuration for Linux
 func DefaultConfig() Config {
 	return Config{
@@ -33,12 +39,24 @@ func DefaultConfig() Config {
 		AvailableLoggingDrivers:     []dockerclient.LoggingDriver{dockerclient.JsonFileDriver},
 		TaskCleanupWaitDuration:     DefaultTaskCleanupWaitDuration,
 		DockerStopTimeout:           DefaultDockerStopTimeout,
+		ContainerCreateTimeout:      DefaultContainerCreateTimeout,
+		ContainerStartTimeout:       DefaultContainerStartTimeout,
 		CredentialsAuditLogFile:     defaultCredentialsAuditLogFile,
 		CredentialsAuditLogDisabled: false,
 		ImageCleanupDisabled:        false,
 		MinimumImageDeletionAge:     DefaultImageDeletionAge,
 		ImageCleanupInterval:        DefaultImageCleanupTimeInterval,
 		NumImagesToDeletePerCycle:   DefaultNumImagesToDeletePerCycle,
+		ContainerStopConcurrency:    DefaultContainerStopConcurrency,
+		ImagePullMaxAttempts:        DefaultImagePullMaxRetries + 1,
+		ContainerStateMode:          ContainerStateModeEvents,
+		PollingMetricsWaitDuration:  DefaultPollingMetricsWaitDuration,
+		StateSaveInterval:           DefaultStateSaveInterval,
+		ImageCleanupDiskPath:        "/var/lib/docker",
+		ImagePullBehavior:           ImagePullBehaviorAlways,
+		SeccompProfileDir:           "/etc/ecs/seccomp",
+		CPULimitMode:                CPULimitModeShares,
+		HostDataDir:                 "/var/lib/ecs",
 	}
 }
 
@@ -40,10 +40,16 @@ const (
 	netBIOSPort = 139
 )
 
+// agentConfigDir returns the directory on the instance holding the config and
+// data of the agent
+func agentConfigDir() string {
+	programData := utils.DefaultIfBlank(os.Getenv("ProgramData"), `C:\ProgramData`)
+	return filepath.Join(programData, "Amazon", "ECS")
+}
+
 // DefaultConfig returns the default configuration for Windows
 func DefaultConfig() Config {
-	programData := utils.DefaultIfBlank(os.Getenv("ProgramData"), `C:\ProgramData`)
-	ecsRoot := filepath.Join(programData, "Amazon", "ECS")
+	ecsRoot := agentConfigDir()
 	return Config{
 		DockerEndpoint: "npipe:////./pipe/docker_engine",
 		ReservedPorts: []uint16{
@@ -66,12 +72,23 @@ func DefaultConfig() Config {
 		AvailableLoggingDrivers:     []dockerclient.LoggingDriver{dockerclient.JsonFileDriver},
 		TaskCleanupWaitDuration:     DefaultTaskCleanupWaitDuration,
 		DockerStopTimeout:           DefaultDockerStopTimeout,
+		ContainerCreateTimeout:      DefaultContainerCreateTimeout,
+		ContainerStartTimeout:       DefaultContainerStartTimeout,
 		CredentialsAuditLogFile:     filepath.Join(ecsRoot, defaultCredentialsAuditLogFile),
 		CredentialsAuditLogDisabled: false,
 		ImageCleanupDisabled:        false,
 		MinimumImageDeletionAge:     DefaultImageDeletionAge,
 		ImageCleanupInterval:        DefaultImageCleanupTimeInterval,
 		NumImagesToDeletePerCycle:   DefaultNumImagesToDeletePerCycle,
+		ContainerStopConcurrency:    DefaultContainerStopConcurrency,
+		ImagePullMaxAttempts:        DefaultImagePullMaxRetries + 1,
+		ContainerStateMode:          ContainerStateModeEvents,
+		PollingMetricsWaitDuration:  DefaultPollingMetricsWaitDuration,
+		StateSaveInterval:           DefaultStateSaveInterval,
+		ImageCleanupDiskPath:        `C:\ProgramData\docker`,
+		ImagePullBehavior:           ImagePullBehaviorAlways,
+		CPULimitMode:                CPULimitModeShares,
+		HostDataDir:                 ecsRoot,
 	}
 }
 
@@ -29,7 +29,11 @@ https://github.com/aws/amazon-ecs-agent#environment-variables.
 Config file:
 
 The config file will be loaded from the path stored in the environment key
-ECS_AGENT_CONFIG_FILE_PATH. It must be a JSON file of the format described
-by the "Config" struct below.
+ECS_AGENT_CONFIG_FILE, or ECS_AGENT_CONFIG_FILE_PATH if that isn't set. It
+must be a JSON file whose keys are the names of the fields of the "Config"
+struct below; YAML files aren't supported. Durations may be written as strings
+such as "30s". Unknown keys are ignored with a warning, and values of the wrong
+type are an error. Values set in the environment override the values in the
+file.
 */
 package config
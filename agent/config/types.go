@@ -15,6 +15,7 @@ package config
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
@@ -64,6 +65,11 @@ type Config struct {
 	// EngineAuthData contains authentication data. Please see the documentation
 	// for EngineAuthType for more information.
 	EngineAuthData *SensitiveRawMessage
+	// EngineAuthCredentialHelpers maps registry hostnames to the docker
+	// credential helper used to get auth for them, named without the
+	// "docker-credential-" prefix. Registries without a helper use
+	// EngineAuthData
+	EngineAuthCredentialHelpers map[string]string
 
 	// UpdatesEnabled specifies whether updates should be applied to this agent.
 	// Default true
@@ -81,6 +87,10 @@ type Config struct {
 	// other than containers managed by ECS
 	ReservedMemory uint16
 
+	// ReservedCPU specifies the cpu units, 1024 per core, to reserve for things
+	// other than containers managed by ECS
+	ReservedCPU uint16
+
 	// DockerStopTimeout specifies the amount time before a SIGKILL is issued to
 	// containers managed by ECS
 	DockerStopTimeout time.Duration
@@ -93,6 +103,11 @@ type Config struct {
 	// tasks with privileged containers
 	PrivilegedDisabled bool
 
+	// StrictPrivilegedChecking specifies whether, when privileged containers
+	// are disabled, containers adding capabilities that are about as broad as
+	// running privileged, such as SYS_ADMIN, fail to be created too
+	StrictPrivilegedChecking bool
+
 	// SELinxuCapable specifies whether the Agent is capable of using SELinux
 	// security options
 	SELinuxCapable bool
@@ -134,6 +149,216 @@ type Config struct {
 	// NumImagesToDeletePerCycle specifies the num of image to delete every time
 	// when Agent performs cleanup
 	NumImagesToDeletePerCycle int
+
+	// DockerMinimumAPIVersion specifies the lowest Docker API version the
+	// Agent will use. If no version at or above it is available, the Agent
+	// will fail to start. If not set, any supported version may be used
+	DockerMinimumAPIVersion dockerclient.DockerVersion
+
+	// ACSReconnectJitterMin and ACSReconnectJitterMax bound the random jitter
+	// added to the backoff between reconnects to ACS, as a multiple of the
+	// backoff. Widening the range spreads out reconnects across a fleet
+	ACSReconnectJitterMin float64
+	ACSReconnectJitterMax float64
+
+	// ImageCleanupExclusionList specifies images that are never removed by
+	// automated image cleanup, either as "repository:tag" or as "repository:*"
+	// to match every tag of the repository
+	ImageCleanupExclusionList []string
+
+	// DrainTimeout specifies how long the Agent waits for running tasks to
+	// stop after receiving SIGTERM, without accepting new tasks. If not set,
+	// the Agent exits without waiting
+	DrainTimeout time.Duration
+
+	// ContainerStopConcurrency specifies how many containers of a task are
+	// stopped at the same time when the task stops
+	ContainerStopConcurrency int
+
+	// ImagePullMaxAttempts specifies how many times the Agent tries to pull an
+	// image when pulls fail with transient errors, such as registry throttling.
+	// It is set from ECS_IMAGE_PULL_MAX_RETRIES as one more than the number
+	// of retries
+	ImagePullMaxAttempts int
+
+	// JSONFileMaxSize and JSONFileMaxFiles are the max-size and max-file
+	// options the Agent sets for containers logging with the json-file driver
+	// that don't set them, so that logs are rotated. The size is a number
+	// with an optional unit, such as "10m". Unset values are left to docker
+	JSONFileMaxSize  string
+	JSONFileMaxFiles int
+
+	// JSONFileMaxSizeLimit and JSONFileMaxFilesLimit are the largest max-size
+	// and max-file options containers logging with the json-file driver may
+	// set. Containers asking for more fail to be created. Unset values are not
+	// limited
+	JSONFileMaxSizeLimit  string
+	JSONFileMaxFilesLimit int
+
+	// ContainerStateMode specifies how the Agent learns about container state
+	// changes; one of ContainerStateModeEvents, ContainerStateModePoll or
+	// ContainerStateModeHybrid
+	ContainerStateMode string
+
+	// ContainerCreateTimeout and ContainerStartTimeout specify how long
+	// creating and starting a container may take. Containers that time out
+	// are stopped. Creating is allowed to take longer by default, as docker
+	// may extract image layers while creating
+	ContainerCreateTimeout time.Duration
+	ContainerStartTimeout  time.Duration
+
+	// GPUSupportEnabled specifies whether the NVIDIA GPUs of the instance are
+	// assigned to containers that require GPUs
+	GPUSupportEnabled bool
+
+	// ContainerShmSizeLimit is the largest shared memory size, in MiB, that
+	// containers may set in their linux parameters. Containers asking for more
+	// fail to be created. If not set, the size is not limited
+	ContainerShmSizeLimit int
+
+	// AllowedCapabilities are the Linux capabilities containers may add in
+	// their linux parameters. Containers adding other capabilities fail to be
+	// created. If not set, any capability may be added
+	AllowedCapabilities []string
+
+	// StrictDeviceChecking specifies whether containers exposing host devices
+	// that don't exist on the instance fail to be created, rather than leaving
+	// it to docker to report them when the container starts
+	StrictDeviceChecking bool
+
+	// BindMountAllowedPaths are the host paths, including the paths below
+	// them, containers may bind mount. If not set, any host path may be bind
+	// mounted unless it's denied
+	BindMountAllowedPaths []string
+
+	// BindMountDeniedPaths are the host paths, including the paths below them,
+	// containers may not bind mount, even if they're allowed
+	BindMountDeniedPaths []string
+
+	// BindMountsDisabled specifies whether containers bind mounting any host
+	// path fail to be created. Docker volumes and the empty volumes of tasks
+	// are not host paths
+	BindMountsDisabled bool
+
+	// PollMetrics specifies whether the stats engine asks docker for the
+	// stats of containers every PollingMetricsWaitDuration rather than
+	// streaming them, which docker samples every second
+	PollMetrics bool
+
+	// PollingMetricsWaitDuration is how long the stats engine waits between
+	// asking docker for the stats of a container when PollMetrics is set
+	PollingMetricsWaitDuration time.Duration
+
+	// MetricsDimensionLabels are the docker labels of containers whose
+	// values are published with the metrics of the containers as dimensions.
+	// At most MaximumMetricsDimensionLabels labels are published
+	MetricsDimensionLabels []string
+
+	// StateSaveInterval is how long the agent waits for further changes to
+	// its state before saving it, so changes made in quick succession are
+	// saved together
+	StateSaveInterval time.Duration
+
+	// InstanceAttributes are custom attributes, by name, registered with the
+	// container instance along with the capabilities the Agent detects, for
+	// use in task placement constraints
+	InstanceAttributes map[string]string
+
+	// ImageCleanupDiskThreshold is the percentage of free disk space below
+	// which the Agent cleans up aggressively, removing stopped tasks before
+	// their cleanup wait duration passes and every unused image however
+	// recently it was pulled. If not set, disk space is not monitored
+	ImageCleanupDiskThreshold int
+
+	// ImageCleanupDiskPath is a path on the filesystem docker stores images
+	// and containers in, whose free space is monitored
+	ImageCleanupDiskPath string
+
+	// ImagePullBehavior specifies when the images of containers are pulled;
+	// one of ImagePullBehaviorAlways, ImagePullBehaviorOnce or
+	// ImagePullBehaviorPreferCached
+	ImagePullBehavior string
+
+	// MaxConcurrentTaskLaunches is the largest number of tasks that are
+	// launched at the same time. Other tasks wait to be launched, but tasks
+	// are stopped without waiting. If not set, launches are not limited
+	MaxConcurrentTaskLaunches int
+
+	// ContainerLogsEndpointEnabled specifies whether the logs of
+	// containers can be read through the introspection API. It's disabled by
+	// default, as the logs may hold sensitive data
+	ContainerLogsEndpointEnabled bool
+
+	// LogLevelEndpointEnabled specifies whether the log level of the agent
+	// can be read and changed through the introspection API. It's disabled by
+	// default, as the API can be reached from containers
+	LogLevelEndpointEnabled bool
+
+	// LocalEnvironmentFilesEnabled specifies whether containers may read
+	// environment files from the instance. It's disabled by default, as the
+	// files are read by the agent, which can read files containers can't
+	LocalEnvironmentFilesEnabled bool
+
+	// SeccompUnconfinedAllowed specifies whether containers may run without
+	// a seccomp profile. Containers asking to be unconfined fail to be
+	// created otherwise
+	SeccompUnconfinedAllowed bool
+
+	// SeccompProfileDir is the directory holding the seccomp profiles
+	// containers may name, each in a <name>.json file
+	SeccompProfileDir string
+
+	// StrictAppArmorChecking specifies whether containers using an AppArmor
+	// profile that isn't loaded on the instance fail to be created, rather
+	// than leaving it to docker to report it when the container starts
+	StrictAppArmorChecking bool
+
+	// HostNamespacesDisabled specifies whether tasks are prevented from
+	// sharing the PID or IPC namespace of the instance. Containers of tasks
+	// using the host pidMode or ipcMode fail to be created if set
+	HostNamespacesDisabled bool
+
+	// MaxContainerRestarts is the number of times the agent restarts a
+	// non-essential container that exited with a non-zero code, waiting
+	// exponentially longer before each restart. If not set, crashed
+	// containers aren't restarted
+	MaxContainerRestarts int
+
+	// ECRImageAuthEnabled specifies whether images in ECR repositories that
+	// come without registry authentication are pulled with an ECR token
+	// obtained with the instance's credentials
+	ECRImageAuthEnabled bool
+
+	// ContainerLabels are docker labels added to every container the agent
+	// creates. They override labels of the same name in the task definition,
+	// but may not start with the prefix of the labels the agent adds itself
+	ContainerLabels map[string]string
+
+	// CPULimitMode specifies how the cpu units of containers are applied; one
+	// of CPULimitModeShares or CPULimitModeQuota
+	CPULimitMode string
+
+	// ContainerMetadataEnabled specifies whether a file with the metadata of
+	// each container is written into the container
+	ContainerMetadataEnabled bool
+
+	// HostDataDir is the directory on the instance that holds DataDir, in
+	// its data subdirectory, when the Agent runs in a container
+	HostDataDir string
+
+	// ACSHeartbeatTimeout is how long the connection to ACS may go without
+	// any message, heartbeats included, before it is considered stale and
+	// reconnected. ACSHeartbeatGraceFactor adds a random grace period of up
+	// to this multiple of the timeout, so that instances don't all reconnect
+	// at once
+	ACSHeartbeatTimeout     time.Duration
+	ACSHeartbeatGraceFactor float64
+
+	// AllowedSysctls are the kernel parameters containers may set in the
+	// system controls of their linux parameters. Entries ending in ".*" allow
+	// every parameter with that prefix. Containers setting other parameters
+	// fail to be created. If not set, any namespaced parameter may be set
+	AllowedSysctls []string
 }
 
 // SensitiveRawMessage is a struct to store some data that should not be logged
@@ -171,3 +396,12 @@ func (data *SensitiveRawMessage) UnmarshalJSON(jsonData []byte) error {
 	data.contents = json.RawMessage(jsonData)
 	return nil
 }
+
+// InvalidConfigError lists the problems found when validating a config
+type InvalidConfigError struct {
+	Problems []string
+}
+
+func (err InvalidConfigError) Error() string {
+	return "Invalid config: " + strings.Join(err.Problems, "; ")
+}
@@ -16,6 +16,7 @@ package credentials
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/aws-sdk-go/aws"
@@ -38,6 +39,11 @@ const (
 
 	v1CredentialsEndpointRelativeURIFormat = "%s?" + CredentialsIDQueryParameterName + "=%s"
 	v2CredentialsEndpointRelativeURIFormat = "%s/%s"
+
+	// RotationWindow is how long before the expiration of a set of
+	// credentials they are considered due for rotation. Callers of the
+	// credentials endpoint are asked not to cache credentials past this point
+	RotationWindow = 5 * time.Minute
 )
 
 // IAMRoleCredentials is used to save credentials sent by ACS
@@ -59,6 +65,17 @@ type TaskIAMRoleCredentials struct {
 	IAMRoleCredentials IAMRoleCredentials
 }
 
+// RotationTime returns the time at which the credentials are due for rotation,
+// which is RotationWindow before their expiration. An error is returned if the
+// expiration is not an RFC3339 timestamp
+func (roleCredentials *IAMRoleCredentials) RotationTime() (time.Time, error) {
+	expiration, err := time.Parse(time.RFC3339, roleCredentials.Expiration)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return expiration.Add(-RotationWindow), nil
+}
+
 // GenerateCredentialsEndpointRelativeURI generates the relative URI for the
 // credentials endpoint, for a given task id.
 func (roleCredentials *IAMRoleCredentials) GenerateCredentialsEndpointRelativeURI() string {
@@ -110,14 +127,10 @@ func (manager *credentialsManager) SetTaskCredentials(taskCredentials TaskIAMRol
 		return fmt.Errorf("task ARN is empty")
 	}
 
-	// Check if credentials exists for the given credentials id
-	taskCredentialsInMap, ok := manager.idToTaskCredentials[credentials.CredentialsID]
-	if !ok {
-		// No existing credentials, create a new one
-		taskCredentialsInMap = &TaskIAMRoleCredentials{}
-	}
-	*taskCredentialsInMap = taskCredentials
-	manager.idToTaskCredentials[credentials.CredentialsID] = taskCredentialsInMap
+	// Always store a new object rather than updating the existing one in
+	// place, so that a concurrent reader never sees partially updated
+	// credentials
+	manager.idToTaskCredentials[credentials.CredentialsID] = &taskCredentials
 
 	return nil
 }
@@ -128,7 +141,12 @@ func (manager *credentialsManager) GetTaskCredentials(id string) (*TaskIAMRoleCr
 	defer manager.taskCredentialsLock.RUnlock()
 
 	taskCredentials, ok := manager.idToTaskCredentials[id]
-	return taskCredentials, ok
+	if !ok || taskCredentials == nil {
+		return taskCredentials, ok
+	}
+	// Return a copy so that callers can't modify the stored credentials
+	taskCredentialsCopy := *taskCredentials
+	return &taskCredentialsCopy, ok
 }
 
 // RemoveCredentials removes credentials from the credentials manager
@@ -16,7 +16,9 @@ package credentials
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/aws-sdk-go/aws"
@@ -179,3 +181,78 @@ func TestRemoveExistingCredentials(t *testing.T) {
 		t.Error("Expected GetTaskCredentials to return false for removed credentials")
 	}
 }
+
+func credentialsExpiringAt(id string, expiration time.Time) TaskIAMRoleCredentials {
+	return TaskIAMRoleCredentials{
+		ARN: "t1",
+		IAMRoleCredentials: IAMRoleCredentials{
+			RoleArn:         "r1",
+			AccessKeyID:     "akid-" + id,
+			SecretAccessKey: "skid-" + id,
+			SessionToken:    "stkn-" + id,
+			Expiration:      expiration.Format(time.RFC3339),
+			CredentialsID:   id,
+		},
+	}
+}
+
+// TestGetTaskCredentialsDuringRotation tests that credentials read while they
+// are being rotated are never empty or a mix of the old and new credentials
+func TestGetTaskCredentialsDuringRotation(t *testing.T) {
+	manager := NewManager()
+	err := manager.SetTaskCredentials(credentialsExpiringAt("0", time.Now().Add(time.Hour)))
+	assert.NoError(t, err, "Error adding credentials")
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				creds, ok := manager.GetTaskCredentials("0")
+				if !ok || creds == nil {
+					t.Error("Expected credentials to be present during rotation")
+					return
+				}
+				roleCredentials := creds.IAMRoleCredentials
+				suffix := roleCredentials.AccessKeyID[len("akid-"):]
+				if roleCredentials.SecretAccessKey != "skid-"+suffix || roleCredentials.SessionToken != "stkn-"+suffix {
+					t.Errorf("Inconsistent credentials read during rotation: %+v", roleCredentials)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		creds := credentialsExpiringAt("0", time.Now().Add(time.Hour))
+		creds.IAMRoleCredentials.AccessKeyID = fmt.Sprintf("akid-%d", i)
+		creds.IAMRoleCredentials.SecretAccessKey = fmt.Sprintf("skid-%d", i)
+		creds.IAMRoleCredentials.SessionToken = fmt.Sprintf("stkn-%d", i)
+		err := manager.SetTaskCredentials(creds)
+		assert.NoError(t, err, "Error rotating credentials")
+	}
+	close(done)
+	wg.Wait()
+}
+
+// TestGetTaskCredentialsReturnsCopy tests that modifying the credentials
+// returned by the manager does not modify the stored credentials
+func TestGetTaskCredentialsReturnsCopy(t *testing.T) {
+	manager := NewManager()
+	credentials := credentialsExpiringAt("cid1", time.Now().Add(time.Hour))
+	err := manager.SetTaskCredentials(credentials)
+	assert.NoError(t, err, "Error adding credentials")
+
+	credentialsFromManager, _ := manager.GetTaskCredentials("cid1")
+	credentialsFromManager.IAMRoleCredentials.SessionToken = "modified"
+
+	credentialsFromManager, _ = manager.GetTaskCredentials("cid1")
+	assert.Equal(t, credentials, *credentialsFromManager, "Stored credentials were modified")
+}
@@ -0,0 +1,72 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// appArmorProfilesPath lists the AppArmor profiles loaded in the kernel, one
+// "<name> (<mode>)" line each
+const appArmorProfilesPath = "/sys/kernel/security/apparmor/profiles"
+
+// loadedAppArmorProfiles returns the names of the AppArmor profiles listed in
+// the file at path
+func loadedAppArmorProfiles(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	profiles := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if mode := strings.LastIndex(line, " ("); mode > 0 {
+			line = line[:mode]
+		}
+		if line != "" {
+			profiles[line] = true
+		}
+	}
+	return profiles, scanner.Err()
+}
+
+// checkAppArmorProfile returns an error if strict AppArmor checking is enabled
+// and the AppArmor profile of the container isn't loaded on the instance
+func (engine *DockerTaskEngine) checkAppArmorProfile(hostConfig *docker.HostConfig) engineError {
+	if !engine.cfg.StrictAppArmorChecking {
+		return nil
+	}
+	for _, option := range hostConfig.SecurityOpt {
+		profile, ok := api.AppArmorProfile(option)
+		if !ok || profile == api.AppArmorUnconfined {
+			continue
+		}
+		profiles, err := loadedAppArmorProfiles(engine.appArmorProfilesPath)
+		if err != nil {
+			return AppArmorProfileNotFoundError{"Unable to list the AppArmor profiles of this instance: " + err.Error()}
+		}
+		if !profiles[profile] {
+			return AppArmorProfileNotFoundError{"AppArmor profile " + profile + " is not loaded on this instance"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAppArmorProfiles = `docker-default (enforce)
+/usr/sbin/nginx (enforce)
+docker-nginx (complain)
+`
+
+func writeAppArmorProfiles(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "apparmor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "profiles")
+	if err := ioutil.WriteFile(path, []byte(testAppArmorProfiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadedAppArmorProfiles(t *testing.T) {
+	path, cleanup := writeAppArmorProfiles(t)
+	defer cleanup()
+
+	profiles, err := loadedAppArmorProfiles(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"docker-default": true, "/usr/sbin/nginx": true, "docker-nginx": true}, profiles)
+}
+
+func TestCheckAppArmorProfile(t *testing.T) {
+	path, cleanup := writeAppArmorProfiles(t)
+	defer cleanup()
+
+	testCases := []struct {
+		name    string
+		strict  bool
+		path    string
+		option  string
+		allowed bool
+	}{
+		{"known profile", true, path, "apparmor=docker-nginx", true},
+		{"known path profile", true, path, "apparmor:/usr/sbin/nginx", true},
+		{"unconfined", true, path, "apparmor=unconfined", true},
+		{"unknown profile", true, path, "apparmor=docker-redis", false},
+		{"unknown profile when not strict", false, path, "apparmor=docker-redis", true},
+		{"apparmor not enabled", true, filepath.Join(path, "missing"), "apparmor=docker-nginx", false},
+		{"other option", true, path, "seccomp=unconfined", true},
+	}
+	for _, tc := range testCases {
+		cfg := defaultConfig
+		cfg.StrictAppArmorChecking = tc.strict
+		taskEngine := &DockerTaskEngine{cfg: &cfg, appArmorProfilesPath: tc.path}
+
+		err := taskEngine.checkAppArmorProfile(&docker.HostConfig{SecurityOpt: []string{tc.option}})
+		if tc.allowed {
+			assert.Nil(t, err, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Equal(t, "AppArmorProfileNotFoundError", err.ErrorName(), tc.name)
+		}
+	}
+}
+
+func TestCreateContainerUnknownAppArmorProfile(t *testing.T) {
+	path, cleanup := writeAppArmorProfiles(t)
+	defer cleanup()
+	cfg := defaultConfig
+	cfg.StrictAppArmorChecking = true
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.appArmorProfilesPath = path
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.DockerSecurityOptions = []string{"apparmor:docker-redis"}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for an unknown AppArmor profile")
+	}
+	assert.Equal(t, "AppArmorProfileNotFoundError", metadata.Error.ErrorName())
+	assert.Contains(t, metadata.Error.Error(), "docker-redis")
+}
@@ -0,0 +1,94 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+// checkBindMounts returns an error if the container bind mounts a host path
+// that isn't allowed on the instance. Binds of docker volumes and of the empty
+// volumes of the task, whose host paths are chosen by docker, aren't checked
+func (engine *DockerTaskEngine) checkBindMounts(task *api.Task, binds []string) engineError {
+	cfg := engine.cfg
+	if !cfg.BindMountsDisabled && len(cfg.BindMountAllowedPaths) == 0 && len(cfg.BindMountDeniedPaths) == 0 {
+		return nil
+	}
+
+	emptyVolumePaths := make(map[string]bool)
+	for _, volume := range task.Volumes {
+		if emptyVolume, ok := volume.Volume.(*api.EmptyHostVolume); ok && emptyVolume.HostPath != "" {
+			emptyVolumePaths[emptyVolume.HostPath] = true
+		}
+	}
+
+	for _, bind := range binds {
+		source := bindMountSource(bind)
+		if source == "" || !filepath.IsAbs(source) || emptyVolumePaths[source] {
+			continue
+		}
+		if restriction := engine.hostPathRestriction(source); restriction != "" {
+			return BindMountNotAllowedError{"Bind mounting host path " + source + " is " + restriction + " on this instance"}
+		}
+	}
+	return nil
+}
+
+// hostPathRestriction returns "not allowed" or "denied" if the bind mount
+// policy of the instance keeps containers from using the host path, or an
+// empty string if they may use it
+func (engine *DockerTaskEngine) hostPathRestriction(path string) string {
+	cfg := engine.cfg
+	if cfg.BindMountsDisabled {
+		return "not allowed"
+	}
+	if pathUnderAny(path, cfg.BindMountDeniedPaths) {
+		return "denied"
+	}
+	if len(cfg.BindMountAllowedPaths) > 0 && !pathUnderAny(path, cfg.BindMountAllowedPaths) {
+		return "not allowed"
+	}
+	return ""
+}
+
+// bindMountSource returns the source of a docker bind, which is either a host
+// path or the name of a docker volume, or an empty string if the bind has no
+// source. Windows host paths start with a drive letter followed by a colon
+func bindMountSource(bind string) string {
+	start := 0
+	if len(bind) > 1 && bind[1] == ':' {
+		start = 2
+	}
+	separator := strings.Index(bind[start:], ":")
+	if separator < 0 {
+		return ""
+	}
+	return bind[:start+separator]
+}
+
+// pathUnderAny returns true if the path is one of the prefixes or below one of
+// them
+func pathUnderAny(path string, prefixes []string) bool {
+	path = filepath.Clean(path)
+	for _, prefix := range prefixes {
+		prefix = filepath.Clean(prefix)
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+// +build !windows,!integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBindMounts(t *testing.T) {
+	task := &api.Task{
+		Arn: "arn",
+		Volumes: []api.TaskVolume{
+			{Name: "empty", Volume: &api.EmptyHostVolume{HostPath: "/var/lib/docker/volumes/empty"}},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		allowed  []string
+		denied   []string
+		disabled bool
+		binds    []string
+		err      string
+	}{
+		{
+			name:  "not configured",
+			binds: []string{"/etc:/etc"},
+		},
+		{
+			name:    "allowed",
+			allowed: []string{"/data", "/var/log/"},
+			binds:   []string{"/data:/data", "/data/app:/app:ro", "/var/log/app:/log"},
+		},
+		{
+			name:    "outside allowed paths",
+			allowed: []string{"/data"},
+			binds:   []string{"/data:/data", "/etc:/etc"},
+			err:     "Bind mounting host path /etc is not allowed on this instance",
+		},
+		{
+			name:    "sibling of allowed path",
+			allowed: []string{"/data"},
+			binds:   []string{"/data2:/data"},
+			err:     "Bind mounting host path /data2 is not allowed on this instance",
+		},
+		{
+			name:    "escaping allowed path",
+			allowed: []string{"/data"},
+			binds:   []string{"/data/../etc:/etc"},
+			err:     "Bind mounting host path /data/../etc is not allowed on this instance",
+		},
+		{
+			name:   "denied",
+			denied: []string{"/var/run/docker.sock"},
+			binds:  []string{"/data:/data", "/var/run/docker.sock:/var/run/docker.sock"},
+			err:    "Bind mounting host path /var/run/docker.sock is denied on this instance",
+		},
+		{
+			name:    "denied below allowed path",
+			allowed: []string{"/data"},
+			denied:  []string{"/data/secrets"},
+			binds:   []string{"/data/secrets/key:/key"},
+			err:     "Bind mounting host path /data/secrets/key is denied on this instance",
+		},
+		{
+			name:     "strict mode",
+			disabled: true,
+			allowed:  []string{"/data"},
+			binds:    []string{"/data:/data"},
+			err:      "Bind mounting host path /data is not allowed on this instance",
+		},
+		{
+			name:     "strict mode docker and empty volumes",
+			disabled: true,
+			binds:    []string{"ecs-family-1-shared-abc:/shared", "/var/lib/docker/volumes/empty:/empty"},
+		},
+	}
+	for _, tc := range testCases {
+		cfg := defaultConfig
+		cfg.BindMountAllowedPaths = tc.allowed
+		cfg.BindMountDeniedPaths = tc.denied
+		cfg.BindMountsDisabled = tc.disabled
+		ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+		taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+		err := taskEngine.checkBindMounts(task, tc.binds)
+		if tc.err == "" {
+			assert.Nil(t, err, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Equal(t, "BindMountNotAllowedError", err.ErrorName(), tc.name)
+			assert.Equal(t, tc.err, err.Error(), tc.name)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestCreateContainerBindMountNotAllowed(t *testing.T) {
+	cfg := defaultConfig
+	cfg.BindMountAllowedPaths = []string{"/data"}
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	container := &api.Container{Name: "c", Image: "image", MountPoints: []api.MountPoint{{SourceVolume: "etc", ContainerPath: "/etc"}}}
+	task := &api.Task{
+		Arn:        "arn",
+		Containers: []*api.Container{container},
+		Volumes:    []api.TaskVolume{{Name: "etc", Volume: &api.FSHostVolume{FSSourcePath: "/etc"}}},
+	}
+	taskEngine.state.AddTask(task)
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(task, container)
+	if assert.NotNil(t, metadata.Error) {
+		assert.Equal(t, "BindMountNotAllowedError", metadata.Error.ErrorName())
+	}
+	problems := taskEngine.ValidateTask(task)
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, "BindMountNotAllowedError", problems[0].Name)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// containerMetadataFileName is the name of the metadata file in the
+	// metadata directory of a container
+	containerMetadataFileName = "ecs-container-metadata.json"
+	// containerMetadataFileEnv is the environment variable containers find
+	// the path of their metadata file in
+	containerMetadataFileEnv = "ECS_CONTAINER_METADATA_FILE"
+
+	// containerMetadataStatusPending marks metadata written before the
+	// container runs, and containerMetadataStatusReady the complete metadata
+	// of a running container
+	containerMetadataStatusPending = "PENDING"
+	containerMetadataStatusReady   = "READY"
+)
+
+// containerMetadata is the content of the metadata file of a container
+type containerMetadata struct {
+	Cluster             string
+	TaskARN             string
+	ContainerName       string
+	DockerContainerName string
+	ContainerID         string                     `json:",omitempty"`
+	ImageName           string
+	ImageID             string                     `json:",omitempty"`
+	PortMappings        containerMetadataPorts     `json:",omitempty"`
+	Networks            []containerMetadataNetwork `json:",omitempty"`
+	MetadataFileStatus  string
+}
+
+// containerMetadataPort is a port of the container bound to the instance
+type containerMetadataPort struct {
+	ContainerPort uint16
+	HostPort      uint16
+	BindIP        string
+	Protocol      string
+}
+
+// containerMetadataPorts sorts ports by container port and protocol
+type containerMetadataPorts []containerMetadataPort
+
+func (ports containerMetadataPorts) Len() int      { return len(ports) }
+func (ports containerMetadataPorts) Swap(i, j int) { ports[i], ports[j] = ports[j], ports[i] }
+func (ports containerMetadataPorts) Less(i, j int) bool {
+	if ports[i].ContainerPort != ports[j].ContainerPort {
+		return ports[i].ContainerPort < ports[j].ContainerPort
+	}
+	return ports[i].Protocol < ports[j].Protocol
+}
+
+// containerMetadataNetwork is a network the container is attached to
+type containerMetadataNetwork struct {
+	NetworkMode   string
+	IPv4Addresses []string
+}
+
+// containerMetadataManager writes the metadata files of containers. Each
+// container's file is in a directory of its own under the Agent's data
+// directory, which is bind mounted from the instance into the container read
+// only. Mounting the directory rather than the file lets the file be replaced
+// as the container's metadata changes, and works for containers with a read
+// only root filesystem too
+type containerMetadataManager struct {
+	enabled bool
+	cluster string
+	// dataDir holds the metadata directories of tasks, and hostDir is the
+	// same directory on the instance
+	dataDir string
+	hostDir string
+}
+
+func newContainerMetadataManager(cfg *config.Config) *containerMetadataManager {
+	return &containerMetadataManager{
+		enabled: cfg.ContainerMetadataEnabled,
+		cluster: cfg.Cluster,
+		dataDir: filepath.Join(cfg.DataDir, "metadata"),
+		hostDir: filepath.Join(cfg.HostDataDir, "data", "metadata"),
+	}
+}
+
+// create writes the pending metadata of a container about to be created, and
+// mounts the metadata into the container. The container is created without
+// its metadata if writing it fails
+func (manager *containerMetadataManager) create(task *api.Task, container *api.Container, dockerName string, config *docker.Config, hostConfig *docker.HostConfig) {
+	if !manager.enabled {
+		return
+	}
+	metadata := manager.metadata(task, container, dockerName)
+	if err := manager.write(task, container, metadata); err != nil {
+		seelog.Warnf("Unable to write the metadata of container %s of task %s, it will be created without it: %v", container.Name, task.Arn, err)
+		return
+	}
+	relativeDir := filepath.Join(taskIDFromArn(task.Arn), container.Name)
+	hostConfig.Binds = append(hostConfig.Binds, filepath.Join(manager.hostDir, relativeDir)+":"+containerMetadataDir+":ro")
+	config.Env = append(config.Env, containerMetadataFileEnv+"="+containerMetadataDir+containerMetadataPathSeparator+containerMetadataFileName)
+}
+
+// created adds the docker id of a created container to its metadata
+func (manager *containerMetadataManager) created(task *api.Task, container *api.Container, dockerName string, dockerID string) {
+	if !manager.enabled {
+		return
+	}
+	metadata := manager.metadata(task, container, dockerName)
+	metadata.ContainerID = dockerID
+	if err := manager.write(task, container, metadata); err != nil {
+		seelog.Warnf("Unable to update the metadata of container %s of task %s: %v", container.Name, task.Arn, err)
+	}
+}
+
+// started completes the metadata of a running container with its image,
+// ports and networks, as inspected from docker
+func (manager *containerMetadataManager) started(task *api.Task, container *api.Container, dockerContainer *docker.Container) {
+	if !manager.enabled {
+		return
+	}
+	metadata := manager.metadata(task, container, strings.TrimPrefix(dockerContainer.Name, "/"))
+	metadata.ContainerID = dockerContainer.ID
+	metadata.ImageID = dockerContainer.Image
+	metadata.MetadataFileStatus = containerMetadataStatusReady
+	if dockerContainer.NetworkSettings != nil {
+		bindings, err := api.PortBindingFromDockerPortBinding(dockerContainer.NetworkSettings.Ports)
+		if err != nil {
+			seelog.Warnf("Unable to read the ports of container %s of task %s: %v", container.Name, task.Arn, err)
+		}
+		for _, binding := range bindings {
+			metadata.PortMappings = append(metadata.PortMappings, containerMetadataPort{
+				ContainerPort: binding.ContainerPort,
+				HostPort:      binding.HostPort,
+				BindIP:        binding.BindIp,
+				Protocol:      binding.Protocol.String(),
+			})
+		}
+		sort.Sort(metadata.PortMappings)
+		networkNames := make([]string, 0, len(dockerContainer.NetworkSettings.Networks))
+		for name := range dockerContainer.NetworkSettings.Networks {
+			networkNames = append(networkNames, name)
+		}
+		sort.Strings(networkNames)
+		for _, name := range networkNames {
+			network := containerMetadataNetwork{NetworkMode: name}
+			if address := dockerContainer.NetworkSettings.Networks[name].IPAddress; address != "" {
+				network.IPv4Addresses = []string{address}
+			}
+			metadata.Networks = append(metadata.Networks, network)
+		}
+	}
+	if err := manager.write(task, container, metadata); err != nil {
+		seelog.Warnf("Unable to update the metadata of container %s of task %s: %v", container.Name, task.Arn, err)
+	}
+}
+
+// releaseTask removes the metadata of the task's containers
+func (manager *containerMetadataManager) releaseTask(task *api.Task) {
+	if !manager.enabled {
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(manager.dataDir, taskIDFromArn(task.Arn))); err != nil {
+		seelog.Warnf("Unable to remove the container metadata of task %s: %v", task.Arn, err)
+	}
+}
+
+func (manager *containerMetadataManager) metadata(task *api.Task, container *api.Container, dockerName string) containerMetadata {
+	return containerMetadata{
+		Cluster:             manager.cluster,
+		TaskARN:             task.Arn,
+		ContainerName:       container.Name,
+		DockerContainerName: dockerName,
+		ImageName:           container.Image,
+		MetadataFileStatus:  containerMetadataStatusPending,
+	}
+}
+
+// write replaces the metadata file of the container, so that the container
+// never reads a partially written file
+func (manager *containerMetadataManager) write(task *api.Task, container *api.Container, metadata containerMetadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(manager.dataDir, taskIDFromArn(task.Arn), container.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, containerMetadataFileName)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, containerMetadataFileName))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// taskIDFromArn returns the id at the end of the task's arn
+func taskIDFromArn(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
@@ -0,0 +1,160 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func readContainerMetadata(t *testing.T, path string) containerMetadata {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("Unable to read container metadata:", err)
+	}
+	var metadata containerMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal("Unable to decode container metadata:", err)
+	}
+	return metadata
+}
+
+func TestContainerMetadataTransitions(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "container-metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	cfg := defaultConfig
+	cfg.Cluster = "prod"
+	cfg.DataDir = dataDir
+	cfg.HostDataDir = "/var/lib/ecs"
+	cfg.ContainerMetadataEnabled = true
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	taskID := "12345678-90ab-cdef-1234-56780abcdef1"
+	metadataPath := filepath.Join(dataDir, "metadata", taskID, "sleep5", containerMetadataFileName)
+
+	var dockerName string
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			dockerName = name
+			hostDir := filepath.Join("/var/lib/ecs", "data", "metadata", taskID, "sleep5")
+			assert.Contains(t, hostConfig.Binds, hostDir+":"+containerMetadataDir+":ro")
+			assert.Contains(t, config.Env, containerMetadataFileEnv+"="+containerMetadataDir+containerMetadataPathSeparator+containerMetadataFileName)
+
+			metadata := readContainerMetadata(t, metadataPath)
+			assert.Equal(t, containerMetadata{
+				Cluster:             "prod",
+				TaskARN:             sleepTask.Arn,
+				ContainerName:       "sleep5",
+				DockerContainerName: name,
+				ImageName:           sleepContainer.Image,
+				MetadataFileStatus:  containerMetadataStatusPending,
+			}, metadata)
+		}).Return(DockerContainerMetadata{DockerID: "dockerid"})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Fatal("Unexpected error", metadata.Error)
+	}
+
+	// Once created, the metadata names the container's docker id
+	created := readContainerMetadata(t, metadataPath)
+	assert.Equal(t, "dockerid", created.ContainerID)
+	assert.Equal(t, containerMetadataStatusPending, created.MetadataFileStatus)
+
+	client.EXPECT().StartContainer("dockerid", gomock.Any()).Return(DockerContainerMetadata{DockerID: "dockerid"})
+	client.EXPECT().InspectContainer("dockerid", gomock.Any()).Return(&docker.Container{
+		ID:    "dockerid",
+		Name:  "/" + dockerName,
+		Image: "sha256:imageid",
+		NetworkSettings: &docker.NetworkSettings{
+			Ports: map[docker.Port][]docker.PortBinding{
+				"8080/tcp": {{HostIP: "0.0.0.0", HostPort: "32768"}},
+				"53/udp":   {{HostIP: "0.0.0.0", HostPort: "53"}},
+			},
+			Networks: map[string]docker.ContainerNetwork{
+				"bridge": {IPAddress: "172.17.0.2"},
+			},
+		},
+	}, nil)
+
+	metadata = taskEngine.startContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Fatal("Unexpected error", metadata.Error)
+	}
+
+	running := readContainerMetadata(t, metadataPath)
+	assert.Equal(t, containerMetadata{
+		Cluster:             "prod",
+		TaskARN:             sleepTask.Arn,
+		ContainerName:       "sleep5",
+		DockerContainerName: dockerName,
+		ContainerID:         "dockerid",
+		ImageName:           sleepContainer.Image,
+		ImageID:             "sha256:imageid",
+		PortMappings: containerMetadataPorts{
+			{ContainerPort: 53, HostPort: 53, BindIP: "0.0.0.0", Protocol: "udp"},
+			{ContainerPort: 8080, HostPort: 32768, BindIP: "0.0.0.0", Protocol: "tcp"},
+		},
+		Networks: []containerMetadataNetwork{
+			{NetworkMode: "bridge", IPv4Addresses: []string{"172.17.0.2"}},
+		},
+		MetadataFileStatus: containerMetadataStatusReady,
+	}, running)
+
+	taskEngine.containerMetadata.releaseTask(sleepTask)
+	if _, err := os.Stat(filepath.Join(dataDir, "metadata", taskID)); !os.IsNotExist(err) {
+		t.Error("Expected the task's metadata to be removed, got:", err)
+	}
+}
+
+func TestContainerMetadataDisabled(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Empty(t, hostConfig.Binds)
+			for _, env := range config.Env {
+				assert.NotContains(t, env, containerMetadataFileEnv)
+			}
+		}).Return(DockerContainerMetadata{DockerID: "dockerid"})
+	// No inspect is expected once the container starts
+	client.EXPECT().StartContainer("dockerid", gomock.Any()).Return(DockerContainerMetadata{DockerID: "dockerid"})
+
+	taskEngine.createContainer(sleepTask, sleepContainer)
+	taskEngine.startContainer(sleepTask, sleepContainer)
+}
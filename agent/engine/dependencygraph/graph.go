@@ -63,6 +63,15 @@ func linksToContainerNames(links []string) []string {
 	return names
 }
 
+// networkContainerNames returns the container whose network stack the
+// container joins, which like a linked container must be running first
+func networkContainerNames(container *api.Container) []string {
+	if name := container.NetworkContainer(); name != "" {
+		return []string{name}
+	}
+	return nil
+}
+
 // DependenciesCanBeResolved verifies that it's possible to start a `target`
 // given a group of already handled containers, `by`. Essentially, it asks "is
 // `target` resolved by `by`". It assumes that everything in `by` has reached
@@ -82,7 +91,8 @@ func dependenciesCanBeResolved(target *api.Container, by []*api.Container) bool
 	}
 
 	return verifyStatusResolveable(target, nameMap, neededVolumeContainers, volumeCanResolve) &&
-		verifyStatusResolveable(target, nameMap, linksToContainerNames(target.Links), linkCanResolve)
+		verifyStatusResolveable(target, nameMap, linksToContainerNames(target.Links), linkCanResolve) &&
+		verifyStatusResolveable(target, nameMap, networkContainerNames(target), linkCanResolve)
 }
 
 // DependenciesAreResolved validates that the `target` container can be started
@@ -100,7 +110,100 @@ func DependenciesAreResolved(target *api.Container, by []*api.Container) bool {
 
 	return verifyStatusResolveable(target, nameMap, neededVolumeContainers, volumeIsResolved) &&
 		verifyStatusResolveable(target, nameMap, linksToContainerNames(target.Links), linkIsResolved) &&
-		verifyStatusResolveable(target, nameMap, target.RunDependencies, onRunIsResolved)
+		verifyStatusResolveable(target, nameMap, networkContainerNames(target), linkIsResolved) &&
+		verifyStatusResolveable(target, nameMap, target.RunDependencies, onRunIsResolved) &&
+		verifyDependsOnResolved(target, nameMap)
+}
+
+// verifyDependsOnResolved validates that every container `target` depends on
+// has reached the condition `target` waits for. Like other dependencies, these
+// only hold `target` back while it is being created or run
+func verifyDependsOnResolved(target *api.Container, existingContainers map[string]*api.Container) bool {
+	targetGoal := target.GetDesiredStatus()
+	if targetGoal != api.ContainerRunning && targetGoal != api.ContainerCreated {
+		return true
+	}
+
+	for _, dependency := range target.DependsOn {
+		dependencyContainer, exists := existingContainers[dependency.Container]
+		if !exists {
+			return false
+		}
+		if !dependency.ResolvedBy(dependencyContainer) {
+			return false
+		}
+	}
+	return true
+}
+
+// StopDependenciesAreResolved validates that the `target` container can be
+// stopped given the current known state of the containers in `by`. Containers
+// that depend on `target` to run and are being stopped as well must stop
+// first, so that they are never left running with a broken dependency
+func StopDependenciesAreResolved(target *api.Container, by []*api.Container) bool {
+	nameMap := make(map[string]*api.Container)
+	for _, cont := range by {
+		nameMap[cont.Name] = cont
+	}
+	for _, cont := range stopDependents(target, nameMap) {
+		if cont.DesiredTerminal() && cont.GetKnownStatus() == api.ContainerRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// startDependencies returns the names of the containers `target` depends on
+// to run. Containers it only takes volumes from aren't included; their volumes
+// outlive them
+func startDependencies(target *api.Container) []string {
+	names := linksToContainerNames(target.Links)
+	names = append(names, networkContainerNames(target)...)
+	names = append(names, target.RunDependencies...)
+	for _, dependency := range target.DependsOn {
+		names = append(names, dependency.Container)
+	}
+	return names
+}
+
+// stopDependents returns the containers that must stop before `target`,
+// which are those depending on it to run. Dependencies should never be
+// circular, but a container that `target` depends on in turn isn't waited for,
+// so that such containers still stop
+func stopDependents(target *api.Container, containers map[string]*api.Container) []*api.Container {
+	var dependents []*api.Container
+	for _, cont := range containers {
+		if cont == target {
+			continue
+		}
+		for _, name := range startDependencies(cont) {
+			if name == target.Name {
+				if !dependsOn(target, cont.Name, containers, make(map[string]bool)) {
+					dependents = append(dependents, cont)
+				}
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// dependsOn returns true if `target` depends on the named container to run,
+// directly or through other containers
+func dependsOn(target *api.Container, name string, containers map[string]*api.Container, visited map[string]bool) bool {
+	if visited[target.Name] {
+		return false
+	}
+	visited[target.Name] = true
+	for _, dependency := range startDependencies(target) {
+		if dependency == name {
+			return true
+		}
+		if cont, ok := containers[dependency]; ok && dependsOn(cont, name, containers, visited) {
+			return true
+		}
+	}
+	return false
 }
 
 // verifyStatusResolveable validates that `target` can be resolved given that
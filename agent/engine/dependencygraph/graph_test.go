@@ -172,6 +172,29 @@ func TestDependenciesAreResolved(t *testing.T) {
 	}
 }
 
+func TestNetworkContainerDependencies(t *testing.T) {
+	pause := runningContainer("pause", []string{}, []string{})
+	app := runningContainer("app", []string{}, []string{})
+	app.NetworkMode = api.NetworkModeContainerPrefix + "pause"
+	task := &api.Task{Containers: []*api.Container{app, pause}}
+
+	if !ValidDependencies(task) {
+		t.Error("Joining the network stack of a container of the task should resolve")
+	}
+	if DependenciesAreResolved(app, task.Containers) {
+		t.Error("Shouldn't be resolved; pause isn't running")
+	}
+	pause.KnownStatus = api.ContainerRunning
+	if !DependenciesAreResolved(app, task.Containers) {
+		t.Error("Should be resolved; pause is running")
+	}
+
+	task = &api.Task{Containers: []*api.Container{app}}
+	if ValidDependencies(task) {
+		t.Error("Joining the network stack of a nonexistent container shouldn't resolve")
+	}
+}
+
 func TestRunningependsOnDependencies(t *testing.T) {
 	c1 := &api.Container{
 		Name:        "a",
@@ -202,3 +225,146 @@ func TestRunningependsOnDependencies(t *testing.T) {
 		t.Error("Dependencies should be resolved")
 	}
 }
+
+func TestStopDependenciesAreResolved(t *testing.T) {
+	stoppingContainer := func(name string, links []string) *api.Container {
+		return &api.Container{
+			Name:          name,
+			Links:         links,
+			DesiredStatus: api.ContainerStopped,
+			KnownStatus:   api.ContainerRunning,
+		}
+	}
+	db := stoppingContainer("db", []string{})
+	php := stoppingContainer("php", []string{"db:database"})
+	webserver := stoppingContainer("webserver", []string{"php"})
+	task := &api.Task{
+		Containers: []*api.Container{db, php, webserver},
+	}
+
+	if StopDependenciesAreResolved(db, task.Containers) {
+		t.Error("db shouldn't stop; php links to it and is running")
+	}
+	if StopDependenciesAreResolved(php, task.Containers) {
+		t.Error("php shouldn't stop; webserver links to it and is running")
+	}
+	if !StopDependenciesAreResolved(webserver, task.Containers) {
+		t.Error("webserver should stop; nothing links to it")
+	}
+
+	webserver.KnownStatus = api.ContainerStopped
+	if !StopDependenciesAreResolved(php, task.Containers) {
+		t.Error("php should stop; webserver is stopped")
+	}
+
+	php.DesiredStatus = api.ContainerRunning
+	if !StopDependenciesAreResolved(db, task.Containers) {
+		t.Error("db should stop; php isn't being stopped")
+	}
+}
+
+func TestDependsOnLinearChain(t *testing.T) {
+	dependsOn := func(name string, condition string) []api.DependsOn {
+		return []api.DependsOn{{Container: name, Condition: condition}}
+	}
+	setup := runningContainer("setup", []string{}, []string{})
+	db := runningContainer("db", []string{}, []string{})
+	db.DependsOn = dependsOn("setup", api.DependencyConditionSuccess)
+	app := runningContainer("app", []string{}, []string{})
+	app.DependsOn = dependsOn("db", api.DependencyConditionHealthy)
+	proxy := runningContainer("proxy", []string{}, []string{})
+	proxy.DependsOn = dependsOn("app", api.DependencyConditionStart)
+	containers := []*api.Container{setup, db, app, proxy}
+
+	if !DependenciesAreResolved(setup, containers) {
+		t.Error("setup has no dependencies and should resolve")
+	}
+	if DependenciesAreResolved(db, containers) {
+		t.Error("db shouldn't resolve; setup hasn't run")
+	}
+
+	setup.KnownStatus = api.ContainerRunning
+	if DependenciesAreResolved(db, containers) {
+		t.Error("db shouldn't resolve; setup is still running")
+	}
+	exitCode := 1
+	setup.KnownStatus = api.ContainerStopped
+	setup.KnownExitCode = &exitCode
+	if DependenciesAreResolved(db, containers) {
+		t.Error("db shouldn't resolve; setup failed")
+	}
+	exitCode = 0
+	if !DependenciesAreResolved(db, containers) {
+		t.Error("db should resolve; setup succeeded")
+	}
+
+	db.KnownStatus = api.ContainerRunning
+	db.SetHealthStatus(api.ContainerHealthStarting)
+	if DependenciesAreResolved(app, containers) {
+		t.Error("app shouldn't resolve; db's health check hasn't passed")
+	}
+	db.SetHealthStatus(api.ContainerHealthy)
+	if !DependenciesAreResolved(app, containers) {
+		t.Error("app should resolve; db is healthy")
+	}
+
+	if DependenciesAreResolved(proxy, containers) {
+		t.Error("proxy shouldn't resolve; app hasn't started")
+	}
+	app.KnownStatus = api.ContainerRunning
+	if !DependenciesAreResolved(proxy, containers) {
+		t.Error("proxy should resolve; app has started")
+	}
+
+	proxy.KnownStatus = api.ContainerRunning
+	proxy.DesiredStatus = api.ContainerStopped
+	app.KnownStatus = api.ContainerStopped
+	if !DependenciesAreResolved(proxy, containers) {
+		t.Error("proxy should be able to stop regardless of its dependencies")
+	}
+}
+
+func TestStopDependenciesDependsOn(t *testing.T) {
+	stoppingContainer := func(name string, dependencies ...string) *api.Container {
+		cont := &api.Container{
+			Name:          name,
+			DesiredStatus: api.ContainerStopped,
+			KnownStatus:   api.ContainerRunning,
+		}
+		for _, dependency := range dependencies {
+			cont.DependsOn = append(cont.DependsOn, api.DependsOn{Container: dependency, Condition: api.DependencyConditionStart})
+		}
+		return cont
+	}
+	db := stoppingContainer("db")
+	app := stoppingContainer("app", "db")
+	sidecar := stoppingContainer("sidecar")
+	sidecar.RunDependencies = []string{"app"}
+	containers := []*api.Container{db, app, sidecar}
+
+	if StopDependenciesAreResolved(db, containers) {
+		t.Error("db shouldn't stop; app depends on it and is running")
+	}
+	if StopDependenciesAreResolved(app, containers) {
+		t.Error("app shouldn't stop; sidecar depends on it and is running")
+	}
+	if !StopDependenciesAreResolved(sidecar, containers) {
+		t.Error("sidecar should stop; nothing depends on it")
+	}
+
+	sidecar.KnownStatus = api.ContainerStopped
+	app.KnownStatus = api.ContainerStopped
+	if !StopDependenciesAreResolved(db, containers) {
+		t.Error("db should stop; app is stopped")
+	}
+}
+
+func TestStopDependenciesCycle(t *testing.T) {
+	a := &api.Container{Name: "a", Links: []string{"b"}, DesiredStatus: api.ContainerStopped, KnownStatus: api.ContainerRunning}
+	b := &api.Container{Name: "b", Links: []string{"a"}, DesiredStatus: api.ContainerStopped, KnownStatus: api.ContainerRunning}
+	containers := []*api.Container{a, b}
+
+	if !StopDependenciesAreResolved(a, containers) || !StopDependenciesAreResolved(b, containers) {
+		t.Error("Containers depending on each other should stop regardless")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cihub/seelog"
+	"golang.org/x/net/context"
+)
+
+// diskPressureCheckInterval is how often the free disk space is checked
+const diskPressureCheckInterval = time.Minute
+
+// diskPressureMonitor tracks whether the disk docker stores images and
+// containers on is low on free space
+type diskPressureMonitor struct {
+	path             string
+	thresholdPercent int
+	// diskUsage returns the free and total bytes of the filesystem holding a
+	// path; it's replaced in tests
+	diskUsage func(path string) (uint64, uint64, error)
+
+	lock   sync.Mutex
+	active bool
+}
+
+func newDiskPressureMonitor(path string, thresholdPercent int) *diskPressureMonitor {
+	return &diskPressureMonitor{
+		path:             path,
+		thresholdPercent: thresholdPercent,
+		diskUsage:        getDiskUsage,
+	}
+}
+
+// check returns whether the disk is below the threshold of free space, and
+// whether it just went below it
+func (monitor *diskPressureMonitor) check() (bool, bool) {
+	free, total, err := monitor.diskUsage(monitor.path)
+	if err != nil {
+		seelog.Warnf("Unable to get the disk usage of %s: %v", monitor.path, err)
+		return false, false
+	}
+	if total == 0 {
+		return false, false
+	}
+	freePercent := float64(free) * 100 / float64(total)
+
+	monitor.lock.Lock()
+	defer monitor.lock.Unlock()
+	if freePercent >= float64(monitor.thresholdPercent) {
+		if monitor.active {
+			seelog.Infof("Free disk space of %s is back to %.1f%%, stopping aggressive cleanup", monitor.path, freePercent)
+			monitor.active = false
+		}
+		return false, false
+	}
+	activated := !monitor.active
+	if activated {
+		seelog.Warnf("Free disk space of %s is down to %.1f%%, below the threshold of %d%%; starting aggressive cleanup of stopped tasks and unused images", monitor.path, freePercent, monitor.thresholdPercent)
+		monitor.active = true
+	}
+	return true, activated
+}
+
+// monitorDiskPressure checks the free disk space periodically until the
+// context is canceled, cleaning up aggressively while it's below the threshold
+func (engine *DockerTaskEngine) monitorDiskPressure(ctx context.Context) {
+	for {
+		engine.checkDiskPressure()
+		select {
+		case <-engine.time().After(diskPressureCheckInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkDiskPressure cleans up the stopped tasks waiting for their cleanup
+// wait duration to pass, and every unused image however recently it was
+// pulled, if the disk is below the threshold of free space
+func (engine *DockerTaskEngine) checkDiskPressure() {
+	underPressure, activated := engine.diskPressure.check()
+	if !underPressure {
+		return
+	}
+	if activated {
+		engine.metrics.recordDiskPressure()
+	}
+	engine.reclaimStoppedTasks()
+	engine.imageManager.RemoveAllUnusedImages()
+}
@@ -0,0 +1,98 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDiskUsage reports the free percentages in turn, out of 100 bytes
+type fakeDiskUsage struct {
+	freePercents []uint64
+	err          error
+}
+
+func (usage *fakeDiskUsage) diskUsage(path string) (uint64, uint64, error) {
+	if usage.err != nil {
+		return 0, 0, usage.err
+	}
+	free := usage.freePercents[0]
+	usage.freePercents = usage.freePercents[1:]
+	return free, 100, nil
+}
+
+func TestDiskPressureMonitorCheck(t *testing.T) {
+	usage := &fakeDiskUsage{freePercents: []uint64{50, 9, 5, 10, 30, 1}}
+	monitor := newDiskPressureMonitor("/var/lib/docker", 10)
+	monitor.diskUsage = usage.diskUsage
+
+	for _, expected := range []struct {
+		underPressure bool
+		activated     bool
+	}{
+		{false, false}, // 50% free
+		{true, true},   // 9% free crosses the threshold
+		{true, false},  // 5% free stays below it
+		{false, false}, // 10% free is back at the threshold
+		{false, false}, // 30% free
+		{true, true},   // 1% free crosses it again
+	} {
+		underPressure, activated := monitor.check()
+		assert.Equal(t, expected.underPressure, underPressure)
+		assert.Equal(t, expected.activated, activated)
+	}
+}
+
+func TestDiskPressureMonitorCheckError(t *testing.T) {
+	monitor := newDiskPressureMonitor("/var/lib/docker", 10)
+	monitor.diskUsage = (&fakeDiskUsage{err: errors.New("no such file or directory")}).diskUsage
+
+	underPressure, _ := monitor.check()
+	assert.False(t, underPressure, "Disk usage that can't be read should not trigger cleanup")
+}
+
+func TestCheckDiskPressure(t *testing.T) {
+	ctrl, client, mockTime, privateTaskEngine, _, imageManager := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.diskPressure = newDiskPressureMonitor("/var/lib/docker", 10)
+	taskEngine.diskPressure.diskUsage = (&fakeDiskUsage{freePercents: []uint64{50, 5, 5, 50}}).diskUsage
+	stopped := stoppedManagedTask(taskEngine, "stopped", time.Now())
+	stopped._time = mockTime
+	mockTime.EXPECT().After(gomock.Any()).Return(make(chan time.Time))
+
+	// Nothing is cleaned up while there's enough free space
+	taskEngine.checkDiskPressure()
+	assert.Len(t, stopped.cleanupNow, 0)
+	assert.Equal(t, uint64(0), taskEngine.EngineMetrics().DiskPressureActivations)
+
+	// Below the threshold, stopped tasks and unused images are cleaned up on
+	// every check, but the activation is counted once
+	imageManager.EXPECT().RemoveAllUnusedImages().Times(2)
+	client.EXPECT().RemoveContainer("stopped-name", removeContainerTimeout).Return(nil)
+	imageManager.EXPECT().RemoveContainerReferenceFromImageState(gomock.Any()).Return(nil)
+	taskEngine.checkDiskPressure()
+	stopped.cleanupTask(3 * time.Hour)
+	taskEngine.checkDiskPressure()
+	assert.Equal(t, uint64(1), taskEngine.EngineMetrics().DiskPressureActivations)
+
+	taskEngine.checkDiskPressure()
+	assert.Equal(t, uint64(1), taskEngine.EngineMetrics().DiskPressureActivations)
+}
@@ -16,7 +16,9 @@ package engine
 import (
 	"archive/tar"
 	"bufio"
+	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -30,6 +32,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	"github.com/aws/amazon-ecs-agent/agent/engine/emptyvolume"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 	"github.com/cihub/seelog"
 
@@ -45,18 +48,26 @@ const (
 	// ListContainersTimeout is the timeout for the ListContainers API.
 	ListContainersTimeout   = 10 * time.Minute
 	pullImageTimeout        = 2 * time.Hour
-	createContainerTimeout  = 3 * time.Minute
-	startContainerTimeout   = 1*time.Minute + 30*time.Second
 	stopContainerTimeout    = 30 * time.Second
 	removeContainerTimeout  = 5 * time.Minute
 	inspectContainerTimeout = 30 * time.Second
 	removeImageTimeout      = 3 * time.Minute
+	createVolumeTimeout     = 3 * time.Minute
+	removeVolumeTimeout     = 3 * time.Minute
 
 	// dockerPullBeginTimeout is the timeout from when a 'pull' is called to when
 	// we expect to see output on the pull progress stream. This is to work
 	// around a docker bug which sometimes results in pulls not progressing.
 	dockerPullBeginTimeout = 5 * time.Minute
 
+	// pullRetryMinDelay, pullRetryMaxDelay, pullRetryJitter and
+	// pullRetryMultiplier define the backoff between attempts to pull an image
+	// that failed with a transient error
+	pullRetryMinDelay   = 2 * time.Second
+	pullRetryMaxDelay   = 30 * time.Second
+	pullRetryJitter     = 0.2
+	pullRetryMultiplier = 2
+
 	// pullStatusSuppressDelay controls the time where pull status progress bar
 	// output will be suppressed in debug mode
 	pullStatusSuppressDelay = 2 * time.Second
@@ -73,23 +84,43 @@ type DockerClient interface {
 	// WithVersion returns a new DockerClient for which all operations will use the given remote api version.
 	// A default version will be used for a client not produced via this method.
 	WithVersion(dockerclient.DockerVersion) DockerClient
+	// APIVersion returns the remote api version operations of the client use,
+	// which for a client not produced via WithVersion is the version
+	// negotiated with the daemon
+	APIVersion() dockerclient.DockerVersion
 	ContainerEvents(ctx context.Context) (<-chan DockerContainerChangeEvent, error)
 
 	PullImage(image string, authData *api.RegistryAuthenticationData) DockerContainerMetadata
 
-	CreateContainer(*docker.Config, *docker.HostConfig, string, time.Duration) DockerContainerMetadata
+	CreateContainer(*docker.Config, *dockeriface.HostConfig, string, time.Duration) DockerContainerMetadata
 	StartContainer(string, time.Duration) DockerContainerMetadata
-	StopContainer(string, time.Duration) DockerContainerMetadata
+	// StopContainer stops a container, giving it stopTimeout to exit before
+	// it's killed. The call times out after timeout beyond stopTimeout
+	StopContainer(dockerID string, stopTimeout time.Duration, timeout time.Duration) DockerContainerMetadata
 	DescribeContainer(string) (api.ContainerStatus, DockerContainerMetadata)
 	RemoveContainer(string, time.Duration) error
 
 	InspectContainer(string, time.Duration) (*docker.Container, error)
 	ListContainers(bool, time.Duration) ListContainersResponse
 	Stats(string, context.Context) (<-chan *docker.Stats, error)
+	// ContainerLogs writes the last tail lines of the stdout and stderr logs
+	// of a container to output, multiplexed as docker sends them. If follow
+	// is set, new logs keep being written until the context is done
+	ContainerLogs(ctx context.Context, dockerID string, tail int, follow bool, output io.Writer) error
 
 	Version() (string, error)
+	// Ping checks that the docker daemon is reachable
+	Ping() error
+	// Info returns a summary of the docker daemon's information and version
+	Info() (DockerInfo, error)
 	InspectImage(string) (*docker.Image, error)
 	RemoveImage(string, time.Duration) error
+
+	// CreateVolume creates a docker volume. Creating a volume that already
+	// exists with the same driver succeeds
+	CreateVolume(name string, driver string, driverOpts map[string]string, labels map[string]string, timeout time.Duration) error
+	// RemoveVolume removes a docker volume
+	RemoveVolume(name string, timeout time.Duration) error
 }
 
 // DockerGoClient wraps the underlying go-dockerclient library.
@@ -113,6 +144,9 @@ type dockerGoClient struct {
 	auth             dockerauth.DockerAuthProvider
 	ecrClientFactory ecr.ECRFactory
 	config           *config.Config
+	// images caches the results of inspecting images. It's shared by the
+	// clients of every docker version
+	images *imageInspectCache
 
 	_time     ttime.Time
 	_timeOnce sync.Once
@@ -120,10 +154,12 @@ type dockerGoClient struct {
 
 func (dg *dockerGoClient) WithVersion(version dockerclient.DockerVersion) DockerClient {
 	return &dockerGoClient{
-		clientFactory: dg.clientFactory,
-		version:       version,
-		auth:          dg.auth,
-		config:        dg.config,
+		clientFactory:    dg.clientFactory,
+		version:          version,
+		auth:             dg.auth,
+		ecrClientFactory: dg.ecrClientFactory,
+		config:           dg.config,
+		images:           dg.images,
 	}
 }
 
@@ -148,9 +184,10 @@ func NewDockerGoClient(clientFactory dockerclient.Factory, acceptInsecureCert bo
 
 	return &dockerGoClient{
 		clientFactory:    clientFactory,
-		auth:             dockerauth.NewDockerAuthProvider(cfg.EngineAuthType, cfg.EngineAuthData.Contents()),
+		auth:             dockerauth.NewCredentialHelperAuthProvider(cfg.EngineAuthCredentialHelpers, dockerauth.NewDockerAuthProvider(cfg.EngineAuthType, cfg.EngineAuthData.Contents())),
 		ecrClientFactory: ecr.NewECRFactory(acceptInsecureCert),
 		config:           cfg,
+		images:           newImageInspectCache(),
 	}, nil
 }
 
@@ -161,6 +198,14 @@ func (dg *dockerGoClient) dockerClient() (dockeriface.Client, error) {
 	return dg.clientFactory.GetClient(dg.version)
 }
 
+// clientVersion returns the version of the client returned by dockerClient
+func (dg *dockerGoClient) clientVersion() dockerclient.DockerVersion {
+	if dg.version == "" {
+		return dg.clientFactory.GetDefaultVersion()
+	}
+	return dg.version
+}
+
 func (dg *dockerGoClient) time() ttime.Time {
 	dg._timeOnce.Do(func() {
 		if dg._time == nil {
@@ -174,7 +219,12 @@ func (dg *dockerGoClient) PullImage(image string, authData *api.RegistryAuthenti
 	timeout := dg.time().After(pullImageTimeout)
 
 	response := make(chan DockerContainerMetadata, 1)
-	go func() { response <- dg.pullImage(image, authData) }()
+	go func() {
+		metadata := dg.pullImageWithRetries(image, authData)
+		// The pull may have added or updated the image, even if it failed
+		dg.images.invalidate(image)
+		response <- metadata
+	}()
 	select {
 	case resp := <-response:
 		return resp
@@ -183,6 +233,63 @@ func (dg *dockerGoClient) PullImage(image string, authData *api.RegistryAuthenti
 	}
 }
 
+// permanentPullErrors are parts of the errors returned by docker for pulls
+// that will keep failing however often they're retried
+var permanentPullErrors = []string{
+	"not found",
+	"does not exist",
+	"unauthorized",
+	"authentication required",
+	"access denied",
+	"denied:",
+	"no basic auth credentials",
+	"invalid reference format",
+}
+
+// isTransientPullError returns true if err is a failure of the pull itself
+// that may succeed if retried, such as the registry throttling requests or
+// the connection to it being reset
+func isTransientPullError(err engineError) bool {
+	pullErr, ok := err.(CannotXContainerError)
+	if !ok || pullErr.transition != "Pull" {
+		return false
+	}
+	msg := strings.ToLower(pullErr.msg)
+	for _, permanent := range permanentPullErrors {
+		if strings.Contains(msg, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// pullImageWithRetries pulls the image, retrying with exponential backoff
+// while the pull fails with a transient error, up to the configured number of
+// attempts
+func (dg *dockerGoClient) pullImageWithRetries(image string, authData *api.RegistryAuthenticationData) DockerContainerMetadata {
+	maxAttempts := config.DefaultImagePullMaxRetries + 1
+	if dg.config != nil && dg.config.ImagePullMaxAttempts > 0 {
+		maxAttempts = dg.config.ImagePullMaxAttempts
+	}
+	backoff := utils.NewSimpleBackoff(pullRetryMinDelay, pullRetryMaxDelay, pullRetryJitter, pullRetryMultiplier)
+
+	for attempt := 1; ; attempt++ {
+		metadata := dg.pullImage(image, authData)
+		if metadata.Error == nil || !isTransientPullError(metadata.Error) {
+			return metadata
+		}
+		if attempt >= maxAttempts {
+			if attempt > 1 {
+				metadata.Error = CannotXContainerError{"Pull", fmt.Sprintf("Gave up after %d attempts: %s", attempt, metadata.Error.Error())}
+			}
+			return metadata
+		}
+		delay := backoff.Duration()
+		seelog.Warnf("Error pulling image %s, attempt %d of %d, retrying in %v: %v", image, attempt, maxAttempts, delay, metadata.Error)
+		dg.time().Sleep(delay)
+	}
+}
+
 func (dg *dockerGoClient) pullImage(image string, authData *api.RegistryAuthenticationData) DockerContainerMetadata {
 	log.Debug("Pulling image", "image", image)
 	client, err := dg.dockerClient()
@@ -202,7 +309,7 @@ func (dg *dockerGoClient) pullImage(image string, authData *api.RegistryAuthenti
 
 	authConfig, err := dg.getAuthdata(image, authData)
 	if err != nil {
-		return DockerContainerMetadata{Error: CannotXContainerError{"Pull", err.Error()}}
+		return DockerContainerMetadata{Error: CannotPullContainerAuthError{err}}
 	}
 
 	pullDebugOut, pullWriter := io.Pipe()
@@ -316,19 +423,23 @@ func (dg *dockerGoClient) createScratchImageIfNotExists() error {
 	return err
 }
 
+// InspectImage returns the image, reusing the result of inspecting it within
+// the last imageInspectCacheTTL if the image hasn't been pulled or removed
+// since
 func (dg *dockerGoClient) InspectImage(image string) (*docker.Image, error) {
 	client, err := dg.dockerClient()
 	if err != nil {
 		return nil, err
 	}
-	return client.InspectImage(image)
+	return dg.images.inspect(image, dg.time().Now(), client.InspectImage)
 }
 
 func (dg *dockerGoClient) getAuthdata(image string, authData *api.RegistryAuthenticationData) (docker.AuthConfiguration, error) {
-	if authData == nil || authData.Type != "ecr" {
+	ecrAuthData, isECR := dg.ecrAuthData(image, authData)
+	if !isECR {
 		return dg.auth.GetAuthconfig(image)
 	}
-	provider := dockerauth.NewECRAuthProvider(authData.ECRAuthData, dg.ecrClientFactory)
+	provider := dockerauth.NewECRAuthProvider(ecrAuthData, dg.ecrClientFactory)
 	authConfig, err := provider.GetAuthconfig(image)
 	if err != nil {
 		return authConfig, CannotXContainerError{"PullECR", err.Error()}
@@ -336,9 +447,38 @@ func (dg *dockerGoClient) getAuthdata(image string, authData *api.RegistryAuthen
 	return authConfig, nil
 }
 
-func (dg *dockerGoClient) CreateContainer(config *docker.Config, hostConfig *docker.HostConfig, name string, timeout time.Duration) DockerContainerMetadata {
+// ecrAuthData returns the ECR registry to get a token for to pull the image,
+// or false if the image isn't pulled with an ECR token. The registry id and
+// region missing from the task's auth data are derived from the image, so that
+// registries of other accounts and regions are reached. Images in ECR without
+// auth data are pulled with the instance's credentials, if enabled
+func (dg *dockerGoClient) ecrAuthData(image string, authData *api.RegistryAuthenticationData) (*api.ECRAuthData, bool) {
+	imageAuthData, isECRImage := dockerauth.ECRAuthDataFromImage(image)
+	if authData == nil || authData.Type != "ecr" {
+		if isECRImage && dg.config.ECRImageAuthEnabled {
+			return imageAuthData, true
+		}
+		return nil, false
+	}
+	if !isECRImage {
+		return authData.ECRAuthData, true
+	}
+	if authData.ECRAuthData == nil {
+		return imageAuthData, true
+	}
+	resolved := *authData.ECRAuthData
+	if resolved.RegistryId == "" {
+		resolved.RegistryId = imageAuthData.RegistryId
+	}
+	if resolved.Region == "" {
+		resolved.Region = imageAuthData.Region
+	}
+	return &resolved, true
+}
+
+func (dg *dockerGoClient) CreateContainer(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) DockerContainerMetadata {
 	// Create a context that times out after the 'timeout' duration
-	// This is defined by the const 'createContainerTimeout'. Injecting the 'timeout'
+	// This is configured by 'ContainerCreateTimeout'. Injecting the 'timeout'
 	// makes it easier to write tests.
 	// Eventually, the context should be initialized from a parent root context
 	// instead of TODO.
@@ -367,19 +507,18 @@ func (dg *dockerGoClient) CreateContainer(config *docker.Config, hostConfig *doc
 	}
 }
 
-func (dg *dockerGoClient) createContainer(ctx context.Context, config *docker.Config, hostConfig *docker.HostConfig, name string) DockerContainerMetadata {
+func (dg *dockerGoClient) createContainer(ctx context.Context, config *docker.Config, hostConfig *dockeriface.HostConfig, name string) DockerContainerMetadata {
 	client, err := dg.dockerClient()
 	if err != nil {
 		return DockerContainerMetadata{Error: CannotGetDockerClientError{version: dg.version, err: err}}
 	}
 
 	containerOptions := docker.CreateContainerOptions{
-		Config:     config,
-		HostConfig: hostConfig,
-		Name:       name,
-		Context:    ctx,
+		Config:  config,
+		Name:    name,
+		Context: ctx,
 	}
-	dockerContainer, err := client.CreateContainer(containerOptions)
+	dockerContainer, err := client.CreateContainerWithHostConfig(containerOptions, hostConfig)
 	if err != nil {
 		return DockerContainerMetadata{Error: CannotXContainerError{"Create", err.Error()}}
 	}
@@ -388,7 +527,7 @@ func (dg *dockerGoClient) createContainer(ctx context.Context, config *docker.Co
 
 func (dg *dockerGoClient) StartContainer(id string, timeout time.Duration) DockerContainerMetadata {
 	// Create a context that times out after the 'timeout' duration
-	// This is defined by the const 'startContainerTimeout'. Injecting the 'timeout'
+	// This is configured by 'ContainerStartTimeout'. Injecting the 'timeout'
 	// makes it easier to write tests.
 	// Eventually, the context should be initialized from a parent root context
 	// instead of TODO.
@@ -428,8 +567,10 @@ func (dg *dockerGoClient) startContainer(ctx context.Context, id string) DockerC
 	return metadata
 }
 
+// dockerStateToState returns the status of a docker container. A container
+// docker is restarting is still running as far as its task is concerned
 func dockerStateToState(state docker.State) api.ContainerStatus {
-	if state.Running {
+	if state.Running || state.Restarting {
 		return api.ContainerRunning
 	}
 	return api.ContainerStopped
@@ -440,7 +581,7 @@ func (dg *dockerGoClient) DescribeContainer(dockerID string) (api.ContainerStatu
 	if err != nil {
 		return api.ContainerStatusNone, DockerContainerMetadata{Error: CannotXContainerError{"Describe", err.Error()}}
 	}
-	return dockerStateToState(dockerContainer.State), metadataFromContainer(dockerContainer)
+	return dockerStateToState(dockerContainer.State), dg.metadataWithHealth(dockerContainer)
 }
 
 func (dg *dockerGoClient) InspectContainer(dockerID string, timeout time.Duration) (*docker.Container, error) {
@@ -486,12 +627,12 @@ func (dg *dockerGoClient) inspectContainer(dockerID string, ctx context.Context)
 	return client.InspectContainerWithContext(dockerID, ctx)
 }
 
-func (dg *dockerGoClient) StopContainer(dockerID string, timeout time.Duration) DockerContainerMetadata {
-	timeout = timeout + dg.config.DockerStopTimeout
+func (dg *dockerGoClient) StopContainer(dockerID string, stopTimeout time.Duration, timeout time.Duration) DockerContainerMetadata {
+	timeout = timeout + stopTimeout
 
 	// Create a context that times out after the 'timeout' duration
 	// This is defined by the const 'stopContainerTimeout' and the
-	// 'DockerStopTimeout' in the config. Injecting the 'timeout'
+	// stop timeout of the container. Injecting the 'timeout'
 	// makes it easier to write tests.
 	// Eventually, the context should be initialized from a parent root context
 	// instead of TODO.
@@ -501,7 +642,7 @@ func (dg *dockerGoClient) StopContainer(dockerID string, timeout time.Duration)
 	// Buffered channel so in the case of timeout it takes one write, never gets
 	// read, and can still be GC'd
 	response := make(chan DockerContainerMetadata, 1)
-	go func() { response <- dg.stopContainer(ctx, dockerID) }()
+	go func() { response <- dg.stopContainer(ctx, dockerID, stopTimeout) }()
 	select {
 	case resp := <-response:
 		return resp
@@ -516,13 +657,13 @@ func (dg *dockerGoClient) StopContainer(dockerID string, timeout time.Duration)
 	}
 }
 
-func (dg *dockerGoClient) stopContainer(ctx context.Context, dockerID string) DockerContainerMetadata {
+func (dg *dockerGoClient) stopContainer(ctx context.Context, dockerID string, stopTimeout time.Duration) DockerContainerMetadata {
 	client, err := dg.dockerClient()
 	if err != nil {
 		return DockerContainerMetadata{Error: CannotGetDockerClientError{version: dg.version, err: err}}
 	}
 
-	err = client.StopContainerWithContext(dockerID, uint(dg.config.DockerStopTimeout/time.Second), ctx)
+	err = client.StopContainerWithContext(dockerID, uint(stopTimeout/time.Second), ctx)
 	metadata := dg.containerMetadata(dockerID)
 	if err != nil {
 		log.Debug("Error stopping container", "err", err, "id", dockerID)
@@ -595,6 +736,8 @@ func metadataFromContainer(dockerContainer *docker.Container) DockerContainerMet
 		DockerID:     dockerContainer.ID,
 		PortBindings: bindings,
 		Volumes:      dockerContainer.Volumes,
+		CreatedAt:    dockerContainer.Created,
+		StartedAt:    dockerContainer.State.StartedAt,
 	}
 	// Workaround for https://github.com/docker/docker/issues/27601
 	// See https://github.com/docker/docker/blob/v1.12.2/daemon/inspect_unix.go#L38-L43
@@ -615,10 +758,64 @@ func metadataFromContainer(dockerContainer *docker.Container) DockerContainerMet
 	if dockerContainer.State.OOMKilled {
 		metadata.Error = OutOfMemoryError{}
 	}
+	if dockerContainer.State.Running && hasHealthCheck(dockerContainer.Config) {
+		// The vendored go-dockerclient doesn't decode the health of inspected
+		// containers; metadataWithHealth looks it up when it's needed
+		metadata.Health = api.ContainerHealthStarting
+	}
 
 	return metadata
 }
 
+// metadataWithHealth returns the metadata of an inspected container along with
+// the health docker last reported for it, if it's running with a health check.
+// Containers whose health can't be looked up are reported as starting
+func (dg *dockerGoClient) metadataWithHealth(dockerContainer *docker.Container) DockerContainerMetadata {
+	metadata := metadataFromContainer(dockerContainer)
+	if metadata.Health != api.ContainerHealthStarting {
+		return metadata
+	}
+	client, err := dg.dockerClient()
+	if err != nil {
+		return metadata
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), inspectContainerTimeout)
+	defer cancel()
+	health, err := client.InspectContainerHealth(dockerContainer.ID, ctx)
+	if err != nil {
+		seelog.Warnf("Unable to get the health of container %s: %v", dockerContainer.ID, err)
+		return metadata
+	}
+	if status, ok := healthStatusFromEvent(health.Status); ok {
+		metadata.Health = status
+	}
+	return metadata
+}
+
+// hasHealthCheck returns true if the container config, including anything
+// inherited from the image, defines a health check
+func hasHealthCheck(config *docker.Config) bool {
+	if config == nil || config.Healthcheck == nil || len(config.Healthcheck.Test) == 0 {
+		return false
+	}
+	return config.Healthcheck.Test[0] != "NONE"
+}
+
+// healthStatusEventPrefix prefixes the status of events emitted by docker when
+// a container's health check result changes, e.g. "health_status: healthy"
+const healthStatusEventPrefix = "health_status:"
+
+// healthStatusFromEvent returns the health reported by a health status event
+func healthStatusFromEvent(status string) (api.ContainerHealthStatus, bool) {
+	switch strings.TrimSpace(strings.TrimPrefix(status, healthStatusEventPrefix)) {
+	case "healthy":
+		return api.ContainerHealthy, true
+	case "unhealthy":
+		return api.ContainerUnhealthy, true
+	}
+	return api.ContainerHealthUnknown, false
+}
+
 // Listen to the docker event stream for container changes and pass them up
 func (dg *dockerGoClient) ContainerEvents(ctx context.Context) (<-chan DockerContainerChangeEvent, error) {
 	client, err := dg.dockerClient()
@@ -640,6 +837,19 @@ func (dg *dockerGoClient) ContainerEvents(ctx context.Context) (<-chan DockerCon
 	changedContainers := make(chan DockerContainerChangeEvent)
 
 	go func() {
+		// The docker client closes the listener once it gives up reconnecting
+		// to the daemon, such as when the daemon has been restarted. The
+		// returned channel is closed in turn so that the stream is reopened
+		// with a new client
+		defer func() {
+			if ctx.Err() != nil {
+				return
+			}
+			seelog.Warn("Docker event stream disconnected")
+			dg.clientFactory.InvalidateClient(dg.clientVersion())
+			close(changedContainers)
+		}()
+
 		for event := range events {
 			// currently only container events type needs to be handled
 			if event.Type != "container" || event.ID == "" {
@@ -710,24 +920,59 @@ func (dg *dockerGoClient) ContainerEvents(ctx context.Context) (<-chan DockerCon
 				if strings.HasPrefix(event.Status, "exec_create:") || strings.HasPrefix(event.Status, "exec_start:") {
 					continue
 				}
+				if strings.HasPrefix(event.Status, healthStatusEventPrefix) {
+					health, ok := healthStatusFromEvent(event.Status)
+					if !ok {
+						continue
+					}
+					metadata := dg.containerMetadata(containerID)
+					if metadata.Health != api.ContainerHealthStarting {
+						// The container is no longer running, or couldn't be
+						// inspected; its other events will be handled instead
+						continue
+					}
+					metadata.Health = health
+					changedContainers <- DockerContainerChangeEvent{
+						Status:                  api.ContainerRunning,
+						DockerContainerMetadata: metadata,
+					}
+					continue
+				}
 
 				// Because docker emits new events even when you use an old event api
 				// version, it's not that big a deal
 				seelog.Debugf("Unknown status event from docker: %s", event.Status)
 			}
 
-			metadata := dg.containerMetadata(containerID)
-
-			changedContainers <- DockerContainerChangeEvent{
-				Status:                  status,
-				DockerContainerMetadata: metadata,
-			}
+			changedContainers <- dg.containerChangeEvent(containerID, status)
 		}
 	}()
 
 	return changedContainers, nil
 }
 
+// containerChangeEvent inspects the container of a docker event. Docker marks
+// a container that exited and that it restarts because of its restart policy
+// as restarting before emitting the die event, so such a container is reported
+// as still running rather than stopped and its task keeps running
+func (dg *dockerGoClient) containerChangeEvent(id string, status api.ContainerStatus) DockerContainerChangeEvent {
+	dockerContainer, err := dg.InspectContainer(id, inspectContainerTimeout)
+	if err != nil {
+		return DockerContainerChangeEvent{
+			Status:                  status,
+			DockerContainerMetadata: DockerContainerMetadata{DockerID: id, Error: CannotXContainerError{"Inspect", err.Error()}},
+		}
+	}
+	if status == api.ContainerStopped && dockerStateToState(dockerContainer.State) == api.ContainerRunning {
+		seelog.Infof("Container %s exited and is being restarted by docker", id)
+		status = api.ContainerRunning
+	}
+	return DockerContainerChangeEvent{
+		Status:                  status,
+		DockerContainerMetadata: dg.metadataWithHealth(dockerContainer),
+	}
+}
+
 // ListContainers returns a slice of container IDs.
 func (dg *dockerGoClient) ListContainers(all bool, timeout time.Duration) ListContainersResponse {
 	// Create a context that times out after the 'timeout' duration
@@ -784,6 +1029,10 @@ func (dg *dockerGoClient) SupportedVersions() []dockerclient.DockerVersion {
 	return dg.clientFactory.FindAvailableVersions()
 }
 
+func (dg *dockerGoClient) APIVersion() dockerclient.DockerVersion {
+	return dg.clientVersion()
+}
+
 func (dg *dockerGoClient) Version() (string, error) {
 	client, err := dg.dockerClient()
 	if err != nil {
@@ -796,6 +1045,48 @@ func (dg *dockerGoClient) Version() (string, error) {
 	return "DockerVersion: " + info.Get("Version"), nil
 }
 
+// Ping checks that the docker daemon is reachable
+func (dg *dockerGoClient) Ping() error {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return err
+	}
+	return client.Ping()
+}
+
+// Info returns the fields of the docker daemon's info and version that are
+// useful when debugging the agent. Fields that may hold credentials, such as
+// proxy urls, are left out
+func (dg *dockerGoClient) Info() (DockerInfo, error) {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return DockerInfo{}, err
+	}
+	info, err := client.Info()
+	if err != nil {
+		return DockerInfo{}, err
+	}
+	version, err := client.Version()
+	if err != nil {
+		return DockerInfo{}, err
+	}
+
+	return DockerInfo{
+		ServerVersion:     version.Get("Version"),
+		ServerAPIVersion:  version.Get("ApiVersion"),
+		APIVersion:        dg.clientVersion(),
+		StorageDriver:     info.Driver,
+		LoggingDriver:     info.LoggingDriver,
+		CgroupDriver:      info.CgroupDriver,
+		KernelVersion:     info.KernelVersion,
+		OperatingSystem:   info.OperatingSystem,
+		Containers:        info.Containers,
+		ContainersRunning: info.ContainersRunning,
+		Images:            info.Images,
+		LoggingDrivers:    info.Plugins.Log,
+	}, nil
+}
+
 // Stats returns a channel of *docker.Stats entries for the container.
 func (dg *dockerGoClient) Stats(id string, ctx context.Context) (<-chan *docker.Stats, error) {
 	client, err := dg.dockerClient()
@@ -822,12 +1113,35 @@ func (dg *dockerGoClient) Stats(id string, ctx context.Context) (<-chan *docker.
 	return stats, nil
 }
 
+func (dg *dockerGoClient) ContainerLogs(ctx context.Context, dockerID string, tail int, follow bool, output io.Writer) error {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return err
+	}
+	return client.Logs(docker.LogsOptions{
+		Context:      ctx,
+		Container:    dockerID,
+		OutputStream: output,
+		Tail:         strconv.Itoa(tail),
+		Follow:       follow,
+		Stdout:       true,
+		Stderr:       true,
+		// The stream is passed on as is, with stdout and stderr multiplexed
+		RawTerminal: true,
+	})
+}
+
 func (dg *dockerGoClient) RemoveImage(imageName string, imageRemovalTimeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), imageRemovalTimeout)
 	defer cancel()
 
 	response := make(chan error, 1)
-	go func() { response <- dg.removeImage(imageName) }()
+	go func() {
+		err := dg.removeImage(imageName)
+		// Images may be removed by id, which the cache doesn't know
+		dg.images.invalidateAll()
+		response <- err
+	}()
 	select {
 	case resp := <-response:
 		return resp
@@ -843,3 +1157,54 @@ func (dg *dockerGoClient) removeImage(imageName string) error {
 	}
 	return client.RemoveImage(imageName)
 }
+
+func (dg *dockerGoClient) CreateVolume(name string, driver string, driverOpts map[string]string, labels map[string]string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	response := make(chan error, 1)
+	go func() { response <- dg.createVolume(name, driver, driverOpts, labels, ctx) }()
+	select {
+	case resp := <-response:
+		return resp
+	case <-ctx.Done():
+		return &DockerTimeoutError{timeout, "creating volume"}
+	}
+}
+
+func (dg *dockerGoClient) createVolume(name string, driver string, driverOpts map[string]string, labels map[string]string, ctx context.Context) error {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.CreateVolume(docker.CreateVolumeOptions{
+		Name:       name,
+		Driver:     driver,
+		DriverOpts: driverOpts,
+		Labels:     labels,
+		Context:    ctx,
+	})
+	return err
+}
+
+func (dg *dockerGoClient) RemoveVolume(name string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	response := make(chan error, 1)
+	go func() { response <- dg.removeVolume(name) }()
+	select {
+	case resp := <-response:
+		return resp
+	case <-ctx.Done():
+		return &DockerTimeoutError{timeout, "removing volume"}
+	}
+}
+
+func (dg *dockerGoClient) removeVolume(name string) error {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return err
+	}
+	return client.RemoveVolume(name)
+}
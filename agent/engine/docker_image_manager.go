@@ -25,6 +25,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine/image"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
 	"golang.org/x/net/context"
 )
 
@@ -40,6 +41,7 @@ type ImageManager interface {
 	AddAllImageStates(imageStates []*image.ImageState)
 	GetImageStateFromImageName(containerImageName string) *image.ImageState
 	StartImageCleanupProcess(ctx context.Context)
+	RemoveAllUnusedImages()
 	SetSaver(stateManager statemanager.Saver)
 }
 
@@ -56,6 +58,10 @@ type dockerImageManager struct {
 	minimumAgeBeforeDeletion         time.Duration
 	numImagesToDelete                int
 	imageCleanupTimeInterval         time.Duration
+	imageCleanupExclusionList        []string
+	// cleanupLock is held while removing images, so that the periodic
+	// cleanup and the cleanup of all unused images don't run together
+	cleanupLock sync.Mutex
 }
 
 // ImageStatesForDeletion is used for implementing the sort interface
@@ -66,9 +72,10 @@ func NewImageManager(cfg *config.Config, client DockerClient, state *dockerstate
 	return &dockerImageManager{
 		client: client,
 		state:  state,
-		minimumAgeBeforeDeletion: cfg.MinimumImageDeletionAge,
-		numImagesToDelete:        cfg.NumImagesToDeletePerCycle,
-		imageCleanupTimeInterval: cfg.ImageCleanupInterval,
+		minimumAgeBeforeDeletion:  cfg.MinimumImageDeletionAge,
+		numImagesToDelete:         cfg.NumImagesToDeletePerCycle,
+		imageCleanupTimeInterval:  cfg.ImageCleanupInterval,
+		imageCleanupExclusionList: cfg.ImageCleanupExclusionList,
 	}
 }
 
@@ -205,14 +212,20 @@ func (imageManager *dockerImageManager) removeImageState(imageStateToBeRemoved *
 	}
 }
 
-func (imageManager *dockerImageManager) getCandidateImagesForDeletion() []*image.ImageState {
+// getCandidateImagesForDeletion returns the unused images that aren't
+// excluded, and that were pulled long enough ago unless ignoreAge is set
+func (imageManager *dockerImageManager) getCandidateImagesForDeletion(ignoreAge bool) []*image.ImageState {
 	if len(imageManager.imageStatesConsideredForDeletion) < 1 {
 		// no image states present in image manager
 		return nil
 	}
 	var imagesForDeletion []*image.ImageState
 	for _, imageState := range imageManager.imageStatesConsideredForDeletion {
-		if imageManager.isImageOldEnough(imageState) && imageState.HasNoAssociatedContainers() {
+		if imageManager.isImageExcluded(imageState) {
+			seelog.Debugf("Image excluded from cleanup: %+v", imageState)
+			continue
+		}
+		if (ignoreAge || imageManager.isImageOldEnough(imageState)) && imageState.HasNoAssociatedContainers() {
 			seelog.Infof("Candidate image for deletion: %+v", imageState)
 			imagesForDeletion = append(imagesForDeletion, imageState)
 		}
@@ -220,6 +233,40 @@ func (imageManager *dockerImageManager) getCandidateImagesForDeletion() []*image
 	return imagesForDeletion
 }
 
+// isImageExcluded returns true if any name of the image matches an entry in
+// the image cleanup exclusion list
+func (imageManager *dockerImageManager) isImageExcluded(imageState *image.ImageState) bool {
+	for _, name := range imageState.Image.Names {
+		for _, excluded := range imageManager.imageCleanupExclusionList {
+			if imageNameMatches(excluded, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imageNameMatches returns true if the image name matches the pattern, which
+// is either "repository:tag" or "repository:*" to match any tag. Names and
+// patterns without a tag refer to the "latest" tag
+func imageNameMatches(pattern string, name string) bool {
+	patternRepository, patternTag := docker.ParseRepositoryTag(pattern)
+	repository, tag := docker.ParseRepositoryTag(name)
+	if patternRepository != repository {
+		return false
+	}
+	if patternTag == "*" {
+		return true
+	}
+	if patternTag == "" {
+		patternTag = "latest"
+	}
+	if tag == "" {
+		tag = "latest"
+	}
+	return patternTag == tag
+}
+
 func (imageManager *dockerImageManager) isImageOldEnough(imageState *image.ImageState) bool {
 	ageOfImage := time.Now().Sub(imageState.PulledAt)
 	return ageOfImage > imageManager.minimumAgeBeforeDeletion
@@ -277,12 +324,27 @@ func (imageManager *dockerImageManager) performPeriodicImageCleanup(ctx context.
 }
 
 func (imageManager *dockerImageManager) removeUnusedImages() {
+	imageManager.removeImages(imageManager.numImagesToDelete, false)
+}
+
+// RemoveAllUnusedImages removes every unused image that isn't excluded,
+// however recently it was pulled, to free disk space
+func (imageManager *dockerImageManager) RemoveAllUnusedImages() {
+	seelog.Info("Removing all unused images to free disk space")
+	imageManager.removeImages(-1, true)
+}
+
+// removeImages removes up to count unused images, or all of them if count is
+// negative, least recently used first
+func (imageManager *dockerImageManager) removeImages(count int, ignoreAge bool) {
+	imageManager.cleanupLock.Lock()
+	defer imageManager.cleanupLock.Unlock()
 	imageManager.imageStatesConsideredForDeletion = make(map[string]*image.ImageState)
 	for _, imageState := range imageManager.getAllImageStates() {
 		imageManager.imageStatesConsideredForDeletion[imageState.Image.ImageID] = imageState
 	}
-	for i := 0; i < imageManager.numImagesToDelete; i++ {
-		err := imageManager.removeLeastRecentlyUsedImage()
+	for i := 0; count < 0 || i < count; i++ {
+		err := imageManager.removeLeastRecentlyUsedImage(ignoreAge)
 		if err != nil {
 			seelog.Infof("End of eligible images for deletion")
 			break
@@ -290,13 +352,13 @@ func (imageManager *dockerImageManager) removeUnusedImages() {
 	}
 }
 
-func (imageManager *dockerImageManager) removeLeastRecentlyUsedImage() error {
+func (imageManager *dockerImageManager) removeLeastRecentlyUsedImage(ignoreAge bool) error {
 	seelog.Debug("Attempting to obtain ImagePullDeleteLock for removing images")
 	ImagePullDeleteLock.Lock()
 	seelog.Debug("Obtained ImagePullDeleteLock for removing images")
 	defer seelog.Debug("Released ImagePullDeleteLock after removing images")
 	defer ImagePullDeleteLock.Unlock()
-	leastRecentlyUsedImage := imageManager.getUnusedImageForDeletion()
+	leastRecentlyUsedImage := imageManager.getUnusedImageForDeletion(ignoreAge)
 	if leastRecentlyUsedImage == nil {
 		return fmt.Errorf("No more eligible images for deletion")
 	}
@@ -304,10 +366,10 @@ func (imageManager *dockerImageManager) removeLeastRecentlyUsedImage() error {
 	return nil
 }
 
-func (imageManager *dockerImageManager) getUnusedImageForDeletion() *image.ImageState {
+func (imageManager *dockerImageManager) getUnusedImageForDeletion(ignoreAge bool) *image.ImageState {
 	imageManager.updateLock.RLock()
 	defer imageManager.updateLock.RUnlock()
-	candidateImageStatesForDeletion := imageManager.getCandidateImagesForDeletion()
+	candidateImageStatesForDeletion := imageManager.getCandidateImagesForDeletion(ignoreAge)
 	if len(candidateImageStatesForDeletion) < 1 {
 		seelog.Infof("No eligible images for deletion for this cleanup cycle")
 		return nil
@@ -386,7 +386,7 @@ func TestGetCandidateImagesForDeletionImageNoImageState(t *testing.T) {
 		imageCleanupTimeInterval: config.DefaultImageCleanupTimeInterval,
 	}
 
-	imageStates := imageManager.getCandidateImagesForDeletion()
+	imageStates := imageManager.getCandidateImagesForDeletion(false)
 
 	if imageStates != nil {
 		t.Error("Expected no image state to be returned for deletion")
@@ -412,7 +412,7 @@ func TestGetCandidateImagesForDeletionImageJustPulled(t *testing.T) {
 		PulledAt: time.Now(),
 	}
 	imageManager.addImageState(sourceImageState)
-	imageStates := imageManager.getCandidateImagesForDeletion()
+	imageStates := imageManager.getCandidateImagesForDeletion(false)
 	if len(imageStates) > 0 {
 		t.Error("Expected no image state to be returned for deletion")
 	}
@@ -453,7 +453,7 @@ func TestGetCandidateImagesForDeletionImageHasContainerReference(t *testing.T) {
 	if err != nil {
 		t.Error("Error in adding container to an existing image state")
 	}
-	imageStates := imageManager.getCandidateImagesForDeletion()
+	imageStates := imageManager.getCandidateImagesForDeletion(false)
 	if len(imageStates) > 0 {
 		t.Error("Expected no image state to be returned for deletion")
 	}
@@ -508,7 +508,7 @@ func TestGetCandidateImagesForDeletionImageHasMoreContainerReferences(t *testing
 	if err != nil {
 		t.Error("Error removing container reference from image state")
 	}
-	imageStates := imageManager.getCandidateImagesForDeletion()
+	imageStates := imageManager.getCandidateImagesForDeletion(false)
 	if len(imageStates) > 0 {
 		t.Error("Expected no image state to be returned for deletion")
 	}
@@ -689,6 +689,135 @@ func TestImageCleanupHappyPath(t *testing.T) {
 	}
 }
 
+func TestImageCleanupExcludedImage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+
+	imageManager := &dockerImageManager{
+		client: client,
+		state:  dockerstate.NewDockerTaskEngineState(),
+		minimumAgeBeforeDeletion:  1 * time.Millisecond,
+		numImagesToDelete:         config.DefaultNumImagesToDeletePerCycle,
+		imageCleanupTimeInterval:  config.DefaultImageCleanupTimeInterval,
+		imageCleanupExclusionList: []string{"base:1.0", "registry.example.com:5000/tools:*"},
+	}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	// The excluded images are the oldest, but must never be removed
+	pinnedImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:pinned", Names: []string{"base:1.0"}},
+		PulledAt:   time.Now().AddDate(0, -3, 0),
+		LastUsedAt: time.Now().AddDate(0, -3, 0),
+	}
+	wildcardImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:wildcard", Names: []string{"registry.example.com:5000/tools:2"}},
+		PulledAt:   time.Now().AddDate(0, -3, 0),
+		LastUsedAt: time.Now().AddDate(0, -3, 0),
+	}
+	unpinnedImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:unpinned", Names: []string{"base:2.0"}},
+		PulledAt:   time.Now().AddDate(0, -2, 0),
+		LastUsedAt: time.Now().AddDate(0, -2, 0),
+	}
+	imageManager.addImageState(pinnedImageState)
+	imageManager.addImageState(wildcardImageState)
+	imageManager.addImageState(unpinnedImageState)
+
+	client.EXPECT().RemoveImage("base:2.0", removeImageTimeout).Return(nil)
+	imageManager.removeUnusedImages()
+
+	if len(imageManager.imageStates) != 2 {
+		t.Fatalf("Expected only the unpinned image to be removed, got: %d image states", len(imageManager.imageStates))
+	}
+	for _, imageState := range imageManager.imageStates {
+		if imageState == unpinnedImageState {
+			t.Error("Expected unpinned image state to be removed")
+		}
+	}
+}
+
+func TestRemoveAllUnusedImages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+
+	imageManager := &dockerImageManager{
+		client: client,
+		state:  dockerstate.NewDockerTaskEngineState(),
+		minimumAgeBeforeDeletion:  time.Hour,
+		numImagesToDelete:         1,
+		imageCleanupTimeInterval:  config.DefaultImageCleanupTimeInterval,
+		imageCleanupExclusionList: []string{"base:1.0"},
+	}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	// Every unused image is removed, however recently it was pulled and
+	// however many are removed per cycle, but not the excluded or used ones
+	oldImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:old", Names: []string{"old:1.0"}},
+		PulledAt:   time.Now().AddDate(0, -2, 0),
+		LastUsedAt: time.Now().AddDate(0, -2, 0),
+	}
+	newImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:new", Names: []string{"new:1.0"}},
+		PulledAt:   time.Now(),
+		LastUsedAt: time.Now(),
+	}
+	pinnedImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:pinned", Names: []string{"base:1.0"}},
+		PulledAt:   time.Now().AddDate(0, -3, 0),
+		LastUsedAt: time.Now().AddDate(0, -3, 0),
+	}
+	usedImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:used", Names: []string{"used:1.0"}},
+		Containers: []*api.Container{{Name: "running"}},
+		PulledAt:   time.Now().AddDate(0, -3, 0),
+		LastUsedAt: time.Now().AddDate(0, -3, 0),
+	}
+	for _, imageState := range []*image.ImageState{oldImageState, newImageState, pinnedImageState, usedImageState} {
+		imageManager.addImageState(imageState)
+	}
+
+	gomock.InOrder(
+		client.EXPECT().RemoveImage("old:1.0", removeImageTimeout).Return(nil),
+		client.EXPECT().RemoveImage("new:1.0", removeImageTimeout).Return(nil),
+	)
+	imageManager.RemoveAllUnusedImages()
+
+	if len(imageManager.imageStates) != 2 {
+		t.Fatalf("Expected only the excluded and used images to be kept, got: %d image states", len(imageManager.imageStates))
+	}
+	for _, imageState := range imageManager.imageStates {
+		if imageState == oldImageState || imageState == newImageState {
+			t.Errorf("Expected unused image state %s to be removed", imageState.Image.ImageID)
+		}
+	}
+}
+
+func TestImageNameMatches(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		name    string
+		matches bool
+	}{
+		{"busybox:1.0", "busybox:1.0", true},
+		{"busybox:1.0", "busybox:1.1", false},
+		{"busybox", "busybox:latest", true},
+		{"busybox:latest", "busybox", true},
+		{"busybox:*", "busybox:1.0", true},
+		{"busybox:*", "busybox", true},
+		{"busybox:*", "busybox2:1.0", false},
+		{"registry.example.com:5000/busybox:*", "registry.example.com:5000/busybox:1.0", true},
+		{"registry.example.com:5000/busybox:1.0", "registry.example.com:5000/busybox", false},
+	}
+	for _, tc := range testCases {
+		if imageNameMatches(tc.pattern, tc.name) != tc.matches {
+			t.Errorf("Expected match of %s against %s to be %v", tc.name, tc.pattern, tc.matches)
+		}
+	}
+}
+
 func TestImageCleanupCannotRemoveImage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -889,7 +1018,7 @@ func TestRemoveLeastRecentlyUsedImageNoImage(t *testing.T) {
 	client := NewMockDockerClient(ctrl)
 	imageManager := &dockerImageManager{client: client, state: dockerstate.NewDockerTaskEngineState()}
 	imageManager.SetSaver(statemanager.NewNoopStateManager())
-	err := imageManager.removeLeastRecentlyUsedImage()
+	err := imageManager.removeLeastRecentlyUsedImage(false)
 	if err == nil {
 		t.Error("Expected Error for no LRU image to remove")
 	}
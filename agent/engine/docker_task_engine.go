@@ -15,7 +15,14 @@
 package engine
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,13 +32,17 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+	"github.com/aws/amazon-ecs-agent/agent/secrets"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
 )
 
 const (
@@ -45,6 +56,19 @@ const (
 	labelPrefix                  = "com.amazonaws.ecs."
 )
 
+// initMinimumVersion is the first docker remote api version that can run an
+// init process in containers
+const initMinimumVersion = dockerclient.Version_1_25
+
+const (
+	// cpuQuotaPeriod is the period, in microseconds, over which the cpu quota
+	// of containers is enforced
+	cpuQuotaPeriod = 100000
+	// minimumCPUQuota is the smallest cpu quota, in microseconds, docker
+	// accepts
+	minimumCPUQuota = 1000
+)
+
 // DockerTaskEngine is an abstraction over the DockerGoClient so that
 // it does not have to know about tasks, only containers
 // The DockerTaskEngine interacts with docker to implement a task
@@ -88,6 +112,43 @@ type DockerTaskEngine struct {
 	_time              ttime.Time
 	_timeOnce          sync.Once
 	imageManager       ImageManager
+
+	// _daemonLoggingDriver is the default logging driver of the docker daemon,
+	// retrieved the first time it's needed
+	_daemonLoggingDriver    string
+	daemonLoggingDriverLock sync.Mutex
+
+	// _loggingDrivers are the available logging drivers the docker daemon
+	// has, detected the first time they're needed
+	_loggingDrivers        []dockerclient.LoggingDriver
+	loggingDriversDetected bool
+	loggingDriversLock     sync.Mutex
+
+	// draining is set once the engine stops accepting new tasks before the
+	// agent exits; drainDeadline is when it stops waiting for tasks to stop
+	draining      bool
+	drainDeadline time.Time
+	drainLock     sync.RWMutex
+
+	// gpus tracks the GPUs of the instance assigned to tasks
+	gpus *gpuManager
+	// hostResources tracks the cpu and memory of the instance reserved by
+	// tasks
+	hostResources *hostResourceManager
+	// volumes tracks the containers referencing the docker volumes of tasks
+	volumes *volumeManager
+	// secrets resolves the secrets of containers when they're created
+	secrets *secretsResolver
+	// metrics counts the outcome of container transitions
+	metrics *engineMetrics
+	// diskPressure tracks whether the disk is low on free space
+	diskPressure *diskPressureMonitor
+	// launchLimiter limits how many tasks are launched at the same time
+	launchLimiter *launchLimiter
+	// containerMetadata writes the metadata files of containers
+	containerMetadata *containerMetadataManager
+	// appArmorProfilesPath lists the AppArmor profiles loaded on the instance
+	appArmorProfilesPath string
 }
 
 // NewDockerTaskEngine returns a created, but uninitialized, DockerTaskEngine.
@@ -111,6 +172,20 @@ func NewDockerTaskEngine(cfg *config.Config, client DockerClient, credentialsMan
 
 		containerChangeEventStream: containerChangeEventStream,
 		imageManager:               imageManager,
+		volumes:                    newVolumeManager(client),
+		secrets:                    newSecretsResolver(secrets.NewFetcher(cfg.AWSRegion), credentialsManager),
+		metrics:                    newEngineMetrics(),
+		hostResources:              newHostResourceManager(discoverHostResources(cfg)),
+		diskPressure:               newDiskPressureMonitor(cfg.ImageCleanupDiskPath, cfg.ImageCleanupDiskThreshold),
+		launchLimiter:              newLaunchLimiter(cfg.MaxConcurrentTaskLaunches),
+		containerMetadata:          newContainerMetadataManager(cfg),
+		appArmorProfilesPath:       appArmorProfilesPath,
+	}
+
+	if cfg.GPUSupportEnabled {
+		dockerTaskEngine.gpus = newGPUManager(discoverGPUs())
+	} else {
+		dockerTaskEngine.gpus = newGPUManager(nil, nil)
 	}
 
 	return dockerTaskEngine
@@ -138,16 +213,27 @@ func (engine *DockerTaskEngine) Init() error {
 	// TODO, pass in a a context from main from background so that other things can stop us, not just the tests
 	ctx, cancel := context.WithCancel(context.TODO())
 	engine.stopEngine = cancel
+	mode := engine.cfg.ContainerStateMode
 	// Open the event stream before we sync state so that e.g. if a container
 	// goes from running to stopped after we sync with it as "running" we still
 	// have the "went to stopped" event pending so we can be up to date.
-	err := engine.openEventstream(ctx)
-	if err != nil {
-		return err
+	if mode != config.ContainerStateModePoll {
+		err := engine.openEventstream(ctx)
+		if err != nil {
+			return err
+		}
 	}
 	engine.synchronizeState()
 	// Now catch up and start processing new events per normal
-	go engine.handleDockerEvents(ctx)
+	if mode != config.ContainerStateModePoll {
+		go engine.handleDockerEvents(ctx)
+	}
+	if mode == config.ContainerStateModePoll || mode == config.ContainerStateModeHybrid {
+		go engine.pollContainerStates(ctx)
+	}
+	if engine.cfg.ImageCleanupDiskThreshold > 0 {
+		go engine.monitorDiskPressure(ctx)
+	}
 	engine.initialized = true
 	return nil
 }
@@ -213,12 +299,19 @@ func (engine *DockerTaskEngine) synchronizeState() {
 
 	tasks := engine.state.AllTasks()
 	for _, task := range tasks {
+		if !task.GetKnownStatus().Terminal() {
+			engine.gpus.restore(task)
+			engine.hostResources.restore(task)
+		}
 		conts, ok := engine.state.ContainerMapByArn(task.Arn)
 		if !ok {
 			engine.startTask(task)
 			continue
 		}
 		for _, cont := range conts {
+			// Containers that haven't been removed yet still reference their
+			// volumes
+			engine.volumes.restore(task, cont.Container)
 			if cont.DockerId == "" {
 				log.Debug("Found container potentially created while we were down", "name", cont.DockerName)
 				// Figure out the dockerid
@@ -243,6 +336,7 @@ func (engine *DockerTaskEngine) synchronizeState() {
 					}
 				} else {
 					engine.imageManager.RecordContainerReference(cont.Container)
+					updateContainerTimestamps(cont.Container, metadata)
 				}
 				if currentState > cont.Container.GetKnownStatus() {
 					cont.Container.SetKnownStatus(currentState)
@@ -284,18 +378,24 @@ func (engine *DockerTaskEngine) CheckTaskState(task *api.Task) {
 	}
 }
 
-// sweepTask deletes all the containers associated with a task
+// sweepTask deletes all the containers associated with a task and its docker
+// volumes
 func (engine *DockerTaskEngine) sweepTask(task *api.Task) {
 	for _, cont := range task.Containers {
 		err := engine.removeContainer(task, cont)
 		if err != nil {
 			log.Debug("Unable to remove old container", "err", err, "task", task, "cont", cont)
+		} else {
+			engine.volumes.release(task, cont)
 		}
 		err = engine.imageManager.RemoveContainerReferenceFromImageState(cont)
 		if err != nil {
 			seelog.Errorf("Error removing container reference from image state: %v", err)
 		}
 	}
+	engine.volumes.releaseTask(task)
+	engine.secrets.releaseTask(task)
+	engine.containerMetadata.releaseTask(task)
 	engine.saver.Save()
 }
 
@@ -308,6 +408,9 @@ func (engine *DockerTaskEngine) emitTaskEvent(task *api.Task, reason string) {
 		log.Debug("Already sent task event; no need to re-send", "task", task.Arn, "event", taskKnownStatus.String())
 		return
 	}
+	if reason == "" && taskKnownStatus == api.TaskStopped {
+		reason = task.EssentialContainerExitReason()
+	}
 	event := api.TaskStateChange{
 		TaskArn:    task.Arn,
 		Status:     taskKnownStatus,
@@ -357,8 +460,8 @@ func (engine *DockerTaskEngine) emitContainerEvent(task *api.Task, cont *api.Con
 		return
 	}
 
-	if reason == "" && cont.ApplyingError != nil {
-		reason = cont.ApplyingError.Error()
+	if reason == "" {
+		reason = containerStateChangeReason(cont, contKnownStatus)
 	}
 	event := api.ContainerStateChange{
 		TaskArn:       task.Arn,
@@ -374,6 +477,20 @@ func (engine *DockerTaskEngine) emitContainerEvent(task *api.Task, cont *api.Con
 	log.Debug("Container change event passed on", "event", event)
 }
 
+// containerStateChangeReason returns the reason reported for a container
+// reaching status: the error applying the container, such as a failed pull or
+// the container being killed for its memory usage, or else the exit code of a
+// container that stopped with a non-zero code
+func containerStateChangeReason(cont *api.Container, status api.ContainerStatus) string {
+	if cont.ApplyingError != nil {
+		return cont.ApplyingError.Error()
+	}
+	if status.Terminal() && cont.KnownExitCode != nil && *cont.KnownExitCode != 0 {
+		return "Container exited with code " + strconv.Itoa(*cont.KnownExitCode)
+	}
+	return ""
+}
+
 // openEventstream opens, but does not consume, the docker event stream
 func (engine *DockerTaskEngine) openEventstream(ctx context.Context) error {
 	events, err := engine.client.ContainerEvents(ctx)
@@ -391,11 +508,15 @@ func (engine *DockerTaskEngine) handleDockerEvents(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case event := <-engine.events:
-			ok := engine.handleDockerEvent(event)
+		case event, ok := <-engine.events:
 			if !ok {
-				break
+				// The stream was disconnected, such as by the daemon restarting
+				if !engine.reconnectEventstream(ctx) {
+					return
+				}
+				continue
 			}
+			engine.handleDockerEvent(event)
 		}
 	}
 }
@@ -440,6 +561,15 @@ func (engine *DockerTaskEngine) AddTask(task *api.Task) error {
 
 	existingTask, exists := engine.state.TaskByArn(task.Arn)
 	if !exists {
+		if engine.isDraining() && task.GetDesiredStatus() != api.TaskStopped {
+			return TaskEngineDrainingError{task.Arn}
+		}
+		if task.GetDesiredStatus() != api.TaskStopped {
+			engine.reserveHostResources(task)
+		}
+		if task.GetDesiredStatus() != api.TaskStopped {
+			engine.assignGPUs(task)
+		}
 		engine.state.AddTask(task)
 		engine.startTask(task)
 	} else {
@@ -449,6 +579,58 @@ func (engine *DockerTaskEngine) AddTask(task *api.Task) error {
 	return nil
 }
 
+// reserveHostResources reserves the cpu, memory and static host ports of the
+// task on the instance. If the task doesn't fit in what's left it is stopped
+// before any of its containers are pulled or created. Tasks are only added
+// with the processTasks lock held, so the resources and ports of every task
+// accepted before are accounted for
+func (engine *DockerTaskEngine) reserveHostResources(task *api.Task) {
+	if problems := engine.hostPortProblems(task); len(problems) > 0 {
+		logger.Warn("Host ports of task are unavailable, stopping it", logger.Fields{"task": task.Arn, "reason": problems[0].Reason})
+		for _, problem := range problems {
+			if container, ok := task.ContainerByName(problem.Container); ok {
+				container.ApplyingError = &api.DefaultNamedError{Name: problem.Name, Err: problem.Reason}
+			}
+		}
+		stopTask(task)
+		return
+	}
+	err := engine.hostResources.reserve(task)
+	if err == nil {
+		return
+	}
+	logger.Warn("Task doesn't fit in the resources left on the instance, stopping it", logger.Fields{"task": task.Arn, "err": err})
+	for _, container := range task.Containers {
+		container.ApplyingError = api.NewNamedError(err)
+	}
+	stopTask(task)
+}
+
+// stopTask sets the desired status of the task and its containers to stopped
+func stopTask(task *api.Task) {
+	for _, container := range task.Containers {
+		container.SetDesiredStatus(api.ContainerStopped)
+	}
+	task.SetDesiredStatus(api.TaskStopped)
+}
+
+// assignGPUs assigns GPUs to the containers of the task that require them. If
+// there aren't enough free GPUs the task is stopped before any of its
+// containers are pulled or created
+func (engine *DockerTaskEngine) assignGPUs(task *api.Task) {
+	err := engine.gpus.assign(task)
+	if err == nil {
+		return
+	}
+	logger.Warn("Unable to assign GPUs to task, stopping it", logger.Fields{"task": task.Arn, "err": err})
+	for _, container := range task.Containers {
+		if count, _ := container.GPUCount(); count != 0 {
+			container.ApplyingError = api.NewNamedError(err)
+		}
+	}
+	stopTask(task)
+}
+
 type transitionApplyFunc (func(*api.Task, *api.Container) DockerContainerMetadata)
 
 func tryApplyTransition(task *api.Task, container *api.Container, to api.ContainerStatus, f transitionApplyFunc) DockerContainerMetadata {
@@ -482,7 +664,7 @@ func (engine *DockerTaskEngine) pullContainer(task *api.Task, container *api.Con
 		return DockerContainerMetadata{Error: TaskStoppedBeforePullBeginError{task.Arn}}
 	}
 
-	metadata := engine.client.PullImage(container.Image, container.RegistryAuthentication)
+	metadata := engine.pullImage(container)
 	err := engine.imageManager.RecordContainerReference(container)
 	if err != nil {
 		seelog.Errorf("Error adding container reference to image state: %v", err)
@@ -493,8 +675,41 @@ func (engine *DockerTaskEngine) pullContainer(task *api.Task, container *api.Con
 	return metadata
 }
 
+// pullImage pulls the image of the container as the image pull behavior
+// allows: images present on the instance aren't pulled again with
+// ImagePullBehaviorOnce, and failing to pull an image present on the instance
+// isn't an error with ImagePullBehaviorPreferCached
+func (engine *DockerTaskEngine) pullImage(container *api.Container) DockerContainerMetadata {
+	if engine.cfg.ImagePullBehavior == config.ImagePullBehaviorOnce && engine.imagePresent(container.Image) {
+		seelog.Infof("Image %s is present on the instance, skip pulling it for container: %v", container.Image, container)
+		return DockerContainerMetadata{}
+	}
+	metadata := engine.client.PullImage(container.Image, container.RegistryAuthentication)
+	if metadata.Error != nil && engine.cfg.ImagePullBehavior == config.ImagePullBehaviorPreferCached && engine.imagePresent(container.Image) {
+		seelog.Warnf("Error pulling image %s, using the image present on the instance for container %v: %v", container.Image, container, metadata.Error)
+		return DockerContainerMetadata{}
+	}
+	return metadata
+}
+
+func (engine *DockerTaskEngine) imagePresent(image string) bool {
+	_, err := engine.client.InspectImage(image)
+	return err == nil
+}
+
 func (engine *DockerTaskEngine) createContainer(task *api.Task, container *api.Container) DockerContainerMetadata {
 	log.Info("Creating container", "task", task, "container", container)
+	// Catch containers reserving more than the task allows before docker
+	// fails part way through launching the task
+	if err := task.Validate(); err != nil {
+		return DockerContainerMetadata{Error: api.NewNamedError(err)}
+	}
+	// Docker only reports a port that's already allocated when the container
+	// starts, with an error that doesn't say what holds the port
+	if conflicts := hostPortConflicts(container, engine.reservedHostPorts(container, true)); len(conflicts) > 0 {
+		return DockerContainerMetadata{Error: conflicts[0]}
+	}
+
 	client := engine.client
 	if container.DockerConfig.Version != nil {
 		client = client.WithVersion(dockerclient.DockerVersion(*container.DockerConfig.Version))
@@ -514,10 +729,28 @@ func (engine *DockerTaskEngine) createContainer(task *api.Task, container *api.C
 		return DockerContainerMetadata{Error: api.NamedError(hcerr)}
 	}
 
+	if errs := engine.checkHostConfig(task, container, client, hostConfig); len(errs) > 0 {
+		return DockerContainerMetadata{Error: errs[0]}
+	}
+	hostConfig.Devices = append(hostConfig.Devices, engine.gpus.dockerDevices(container)...)
+	if engine.cfg.CPULimitMode == config.CPULimitModeQuota && hostConfig.CPUQuota == 0 {
+		hostConfig.CPUPeriod, hostConfig.CPUQuota = dockerCPUQuota(container.Cpu)
+	}
+
 	config, err := task.DockerConfig(container)
 	if err != nil {
 		return DockerContainerMetadata{Error: api.NamedError(err)}
 	}
+	if err := applyEnvironmentFiles(container, config); err != nil {
+		return DockerContainerMetadata{Error: err}
+	}
+	if err := engine.secrets.apply(task, container, config, &hostConfig.LogConfig); err != nil {
+		return DockerContainerMetadata{Error: err}
+	}
+
+	for name, value := range engine.cfg.ContainerLabels {
+		config.Labels[name] = value
+	}
 
 	// Augment labels with some metadata from the agent. Explicitly do this last
 	// such that it will always override duplicates in the provided raw config
@@ -547,14 +780,263 @@ func (engine *DockerTaskEngine) createContainer(task *api.Task, container *api.C
 	seelog.Infof("Created container name mapping for task %s - %s -> %s", task, container, containerName)
 	engine.saver.ForceSave()
 
-	metadata := client.CreateContainer(config, hostConfig, containerName, createContainerTimeout)
+	// The container references its volumes from when it's added to the state,
+	// as that's what they're restored from
+	if err := engine.volumes.acquire(task, container); err != nil {
+		return DockerContainerMetadata{Error: err}
+	}
+
+	engine.containerMetadata.create(task, container, containerName, config, hostConfig.HostConfig)
+
+	metadata := client.CreateContainer(config, hostConfig, containerName, engine.cfg.ContainerCreateTimeout)
+	if metadata.Error != nil && metadata.Error.ErrorName() == dockerTimeoutErrorName {
+		// Docker may still create the container after the agent has given up
+		// on it, which would then linger until the task is cleaned up
+		logger.Warn("Creating container timed out, removing it", logger.Fields{"task": task.Arn, "container": container.Name, "dockerName": containerName, "err": metadata.Error})
+		if err := client.RemoveContainer(containerName, removeContainerTimeout); err != nil {
+			seelog.Infof("Unable to remove container %s of task %s after creating it timed out: %v", container, task, err)
+		}
+	}
 	if metadata.DockerID != "" {
 		engine.state.AddContainer(&api.DockerContainer{DockerId: metadata.DockerID, DockerName: containerName, Container: container}, task)
+		engine.containerMetadata.created(task, container, containerName, metadata.DockerID)
 	}
-	seelog.Infof("Created docker container for task %s: %s -> %s", task, container, metadata.DockerID)
+	logger.Info("Created docker container", logger.Fields{"task": task.Arn, "container": container.Name, "dockerID": metadata.DockerID})
 	return metadata
 }
 
+// loggingDriverAvailable returns true if the logging driver is one of the
+// drivers configured as available on the instance
+func (engine *DockerTaskEngine) loggingDriverAvailable(driver string) bool {
+	for _, availableDriver := range engine.cfg.AvailableLoggingDrivers {
+		if string(availableDriver) == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHostConfig applies the configuration of the instance to the host config
+// of a container and returns every problem that would fail creating it,
+// including with the host files the container uses. Both createContainer and
+// ValidateTask run these checks
+func (engine *DockerTaskEngine) checkHostConfig(task *api.Task, container *api.Container, client DockerClient, hostConfig *dockeriface.HostConfig) []engineError {
+	var errs []engineError
+	// The task definition's logConfiguration is part of the host config
+	if hostConfig.LogConfig.Type != "" && !engine.loggingDriverAvailable(hostConfig.LogConfig.Type) {
+		errs = append(errs, UnavailableLoggingDriverError{hostConfig.LogConfig.Type})
+	}
+	if err := engine.applyJSONFileLogRotation(&hostConfig.LogConfig); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkShmSize(hostConfig.ShmSize); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkPrivileged(hostConfig.HostConfig); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkHostNamespaces(hostConfig.HostConfig); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkCapabilities(hostConfig.CapAdd); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkSysctls(hostConfig.Sysctls); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkDevices(hostConfig.Devices); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.applySeccompProfile(hostConfig.HostConfig); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkAppArmorProfile(hostConfig.HostConfig); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkBindMounts(task, hostConfig.Binds); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engine.checkEnvironmentFiles(container); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkInitSupported(client, hostConfig.Init); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// checkShmSize returns an error if the shared memory size of the container is
+// above the limit configured on the instance
+func (engine *DockerTaskEngine) checkShmSize(shmSize int64) engineError {
+	limit := int64(engine.cfg.ContainerShmSizeLimit) * 1024 * 1024
+	if limit > 0 && shmSize > limit {
+		return ShmSizeLimitError{"Shared memory size of " + strconv.FormatInt(shmSize, 10) + " bytes is above the limit of " + strconv.Itoa(engine.cfg.ContainerShmSizeLimit) + " MiB"}
+	}
+	return nil
+}
+
+// dockerCPUQuota returns the cpu period and quota, in microseconds, that cap a
+// container at its cpu units, 1024 of which are a whole cpu. Containers
+// without cpu units aren't capped
+func dockerCPUQuota(cpu uint) (int64, int64) {
+	if cpu == 0 {
+		return 0, 0
+	}
+	quota := (int64(cpu)*cpuQuotaPeriod + 512) / 1024
+	if quota < minimumCPUQuota {
+		quota = minimumCPUQuota
+	}
+	return cpuQuotaPeriod, quota
+}
+
+// checkCapabilities returns an error if the container adds a capability that
+// isn't allowed on the instance
+func (engine *DockerTaskEngine) checkCapabilities(capAdd []string) engineError {
+	allowed := engine.cfg.AllowedCapabilities
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, capability := range capAdd {
+		isAllowed := false
+		for _, allowedCapability := range allowed {
+			if capability == allowedCapability {
+				isAllowed = true
+				break
+			}
+		}
+		if !isAllowed {
+			return CapabilityNotAllowedError{"Adding capability " + capability + " is not allowed on this instance"}
+		}
+	}
+	return nil
+}
+
+// checkSysctls returns an error if the container sets a kernel parameter that
+// isn't allowed on the instance
+func (engine *DockerTaskEngine) checkSysctls(sysctls map[string]string) engineError {
+	allowed := engine.cfg.AllowedSysctls
+	if len(allowed) == 0 {
+		return nil
+	}
+	for name := range sysctls {
+		isAllowed := false
+		for _, allowedSysctl := range allowed {
+			if name == allowedSysctl || (strings.HasSuffix(allowedSysctl, ".*") && strings.HasPrefix(name, strings.TrimSuffix(allowedSysctl, "*"))) {
+				isAllowed = true
+				break
+			}
+		}
+		if !isAllowed {
+			return SysctlNotAllowedError{"Setting kernel parameter " + name + " is not allowed on this instance"}
+		}
+	}
+	return nil
+}
+
+// privilegedCapabilities are the capabilities that give a container about as
+// much access to the instance as running privileged
+var privilegedCapabilities = []string{"ALL", "SYS_ADMIN", "SYS_MODULE", "SYS_RAWIO", "SYS_PTRACE", "DAC_READ_SEARCH"}
+
+// checkPrivileged returns an error if privileged containers are disabled on the
+// instance and the container runs privileged or, if strict privileged checking
+// is enabled, adds a capability that's about as broad
+func (engine *DockerTaskEngine) checkPrivileged(hostConfig *docker.HostConfig) engineError {
+	if !engine.cfg.PrivilegedDisabled {
+		return nil
+	}
+	if hostConfig.Privileged {
+		return PrivilegedNotAllowedError{"Privileged containers are not allowed on this instance"}
+	}
+	if !engine.cfg.StrictPrivilegedChecking {
+		return nil
+	}
+	for _, capability := range hostConfig.CapAdd {
+		// Docker accepts capabilities in any case, with or without the CAP_
+		// prefix
+		name := strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+		for _, privileged := range privilegedCapabilities {
+			if name == privileged {
+				return PrivilegedNotAllowedError{"Adding capability " + capability + " is not allowed on this instance, as privileged containers are not allowed"}
+			}
+		}
+	}
+	return nil
+}
+
+// checkHostNamespaces returns an error if host namespaces are disabled on the
+// instance and the container shares the PID or IPC namespace of the instance
+func (engine *DockerTaskEngine) checkHostNamespaces(hostConfig *docker.HostConfig) engineError {
+	if !engine.cfg.HostNamespacesDisabled {
+		return nil
+	}
+	if hostConfig.PidMode == api.NamespaceModeHost {
+		return HostNamespaceNotAllowedError{"Sharing the PID namespace of the instance is not allowed on this instance"}
+	}
+	if hostConfig.IpcMode == api.NamespaceModeHost {
+		return HostNamespaceNotAllowedError{"Sharing the IPC namespace of the instance is not allowed on this instance"}
+	}
+	return nil
+}
+
+// checkDevices returns an error if strict device checking is enabled and a
+// device exposed to the container doesn't exist on the instance
+func (engine *DockerTaskEngine) checkDevices(devices []docker.Device) engineError {
+	if !engine.cfg.StrictDeviceChecking {
+		return nil
+	}
+	for _, device := range devices {
+		if _, err := os.Stat(device.PathOnHost); err != nil {
+			return DeviceNotFoundError{"Device " + device.PathOnHost + " is not available on this instance: " + err.Error()}
+		}
+	}
+	return nil
+}
+
+// applySeccompProfile returns an error if the container runs without a seccomp
+// profile and that isn't allowed on the instance. A profile named by the
+// container is read from the seccomp profile directory of the instance and
+// passed on to docker inline
+func (engine *DockerTaskEngine) applySeccompProfile(hostConfig *docker.HostConfig) engineError {
+	for i, option := range hostConfig.SecurityOpt {
+		profile, ok := api.SeccompProfile(option)
+		if !ok || api.InlineSeccompProfile(profile) {
+			continue
+		}
+		if profile == api.SeccompUnconfined {
+			if !engine.cfg.SeccompUnconfinedAllowed {
+				return SecurityOptionNotAllowedError{"Running containers without a seccomp profile is not allowed on this instance"}
+			}
+			continue
+		}
+		if engine.cfg.SeccompProfileDir == "" {
+			return CannotLoadSeccompProfileError{"Seccomp profile " + profile + " is not available: no seccomp profile directory is set on this instance"}
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(engine.cfg.SeccompProfileDir, profile+".json"))
+		if err != nil {
+			return CannotLoadSeccompProfileError{"Seccomp profile " + profile + " is not available on this instance: " + err.Error()}
+		}
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(contents, &parsed); err != nil {
+			return CannotLoadSeccompProfileError{"Seccomp profile " + profile + " is not valid JSON: " + err.Error()}
+		}
+		hostConfig.SecurityOpt[i] = "seccomp=" + string(contents)
+	}
+	return nil
+}
+
+// checkInitSupported returns an error if the container runs an init process
+// and the remote api version of the client doesn't support it
+func checkInitSupported(client DockerClient, init bool) engineError {
+	if !init {
+		return nil
+	}
+	version := client.APIVersion()
+	if !version.AtLeast(initMinimumVersion) {
+		return UnsupportedDockerVersionError{"Running an init process in the container requires docker remote api version " + string(initMinimumVersion) + " or later, but the agent is using version " + string(version)}
+	}
+	return nil
+}
+
 func (engine *DockerTaskEngine) startContainer(task *api.Task, container *api.Container) DockerContainerMetadata {
 	log.Info("Starting container", "task", task, "container", container)
 	client := engine.client
@@ -571,7 +1053,17 @@ func (engine *DockerTaskEngine) startContainer(task *api.Task, container *api.Co
 	if !ok {
 		return DockerContainerMetadata{Error: CannotXContainerError{"Start", "Container not recorded as created"}}
 	}
-	return client.StartContainer(dockerContainer.DockerId, startContainerTimeout)
+	metadata := client.StartContainer(dockerContainer.DockerId, engine.cfg.ContainerStartTimeout)
+	if metadata.Error == nil && engine.containerMetadata.enabled {
+		// The networks of the container are only known once it's running
+		inspected, err := client.InspectContainer(dockerContainer.DockerId, inspectContainerTimeout)
+		if err != nil {
+			seelog.Warnf("Unable to inspect container %s of task %s for its metadata: %v", container.Name, task.Arn, err)
+		} else {
+			engine.containerMetadata.started(task, container, inspected)
+		}
+	}
+	return metadata
 }
 
 func (engine *DockerTaskEngine) stopContainer(task *api.Task, container *api.Container) DockerContainerMetadata {
@@ -586,7 +1078,31 @@ func (engine *DockerTaskEngine) stopContainer(task *api.Task, container *api.Con
 		return DockerContainerMetadata{Error: CannotXContainerError{"Stop", "Container not recorded as created"}}
 	}
 
-	return engine.client.StopContainer(dockerContainer.DockerId, stopContainerTimeout)
+	return engine.client.StopContainer(dockerContainer.DockerId, engine.containerStopTimeout(container), stopContainerTimeout)
+}
+
+// containerStopTimeout returns how long docker waits for the container to exit
+// before killing it, which is the container's own stop timeout if it set one
+// or the configured default otherwise
+func (engine *DockerTaskEngine) containerStopTimeout(container *api.Container) time.Duration {
+	stopTimeout := engine.cfg.DockerStopTimeout
+	if container.StopTimeout > 0 {
+		stopTimeout = time.Duration(container.StopTimeout) * time.Second
+	}
+	if stopTimeout > config.MaximumDockerStopTimeout {
+		seelog.Warnf("Stop timeout of %v for container %s exceeds the maximum, using %v instead", stopTimeout, container.Name, config.MaximumDockerStopTimeout)
+		stopTimeout = config.MaximumDockerStopTimeout
+	}
+	return stopTimeout
+}
+
+// containerStopConcurrency returns the number of containers of a task that
+// may be stopped at the same time
+func (engine *DockerTaskEngine) containerStopConcurrency() int {
+	if engine.cfg == nil || engine.cfg.ContainerStopConcurrency < 1 {
+		return config.DefaultContainerStopConcurrency
+	}
+	return engine.cfg.ContainerStopConcurrency
 }
 
 func (engine *DockerTaskEngine) removeContainer(task *api.Task, container *api.Container) error {
@@ -649,8 +1165,12 @@ func (engine *DockerTaskEngine) applyContainerState(task *api.Task, container *a
 	}
 
 	metadata := tryApplyTransition(task, container, nextState, transitionFunction)
+	engine.metrics.recordTransition(nextState, metadata.Error)
 	if metadata.Error != nil {
 		clog.Info("Error transitioning container", "state", nextState.String())
+		if isOutOfDiskSpace(metadata.Error) {
+			engine.reclaimStoppedTasks()
+		}
 	} else {
 		clog.Debug("Transitioned container", "state", nextState.String())
 		engine.saver.Save()
@@ -658,6 +1178,28 @@ func (engine *DockerTaskEngine) applyContainerState(task *api.Task, container *a
 	return metadata
 }
 
+// isOutOfDiskSpace returns whether docker failed for lack of disk space
+func isOutOfDiskSpace(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "no space left on device")
+}
+
+// reclaimStoppedTasks cleans up the stopped tasks that are waiting for their
+// cleanup wait duration to pass, removing their containers to free disk space
+func (engine *DockerTaskEngine) reclaimStoppedTasks() {
+	engine.processTasks.RLock()
+	defer engine.processTasks.RUnlock()
+	for _, mtask := range engine.managedTasks {
+		if !mtask.GetKnownStatus().Terminal() {
+			continue
+		}
+		select {
+		case mtask.cleanupNow <- struct{}{}:
+		default:
+			// Already signaled
+		}
+	}
+}
+
 func (engine *DockerTaskEngine) transitionContainer(task *api.Task, container *api.Container, to api.ContainerStatus) {
 	// Let docker events operate async so that we can continue to handle ACS / other requests
 	// This is safe because 'applyContainerState' will not mutate the task
@@ -687,21 +1229,21 @@ func (engine *DockerTaskEngine) State() *dockerstate.DockerTaskEngineState {
 // Capabilities returns the supported capabilities of this agent / docker-client pair.
 // Currently, the following capabilities are possible:
 //
-//    com.amazonaws.ecs.capability.privileged-container
-//    com.amazonaws.ecs.capability.docker-remote-api.1.17
-//    com.amazonaws.ecs.capability.docker-remote-api.1.18
-//    com.amazonaws.ecs.capability.docker-remote-api.1.19
-//    com.amazonaws.ecs.capability.docker-remote-api.1.20
-//    com.amazonaws.ecs.capability.logging-driver.json-file
-//    com.amazonaws.ecs.capability.logging-driver.syslog
-//    com.amazonaws.ecs.capability.logging-driver.fluentd
-//    com.amazonaws.ecs.capability.logging-driver.journald
-//    com.amazonaws.ecs.capability.logging-driver.gelf
-//    com.amazonaws.ecs.capability.selinux
-//    com.amazonaws.ecs.capability.apparmor
-//    com.amazonaws.ecs.capability.ecr-auth
-//    com.amazonaws.ecs.capability.task-iam-role
-//    com.amazonaws.ecs.capability.task-iam-role-network-host
+//	com.amazonaws.ecs.capability.privileged-container
+//	com.amazonaws.ecs.capability.docker-remote-api.1.17
+//	com.amazonaws.ecs.capability.docker-remote-api.1.18
+//	com.amazonaws.ecs.capability.docker-remote-api.1.19
+//	com.amazonaws.ecs.capability.docker-remote-api.1.20
+//	com.amazonaws.ecs.capability.logging-driver.json-file
+//	com.amazonaws.ecs.capability.logging-driver.syslog
+//	com.amazonaws.ecs.capability.logging-driver.fluentd
+//	com.amazonaws.ecs.capability.logging-driver.journald
+//	com.amazonaws.ecs.capability.logging-driver.gelf
+//	com.amazonaws.ecs.capability.selinux
+//	com.amazonaws.ecs.capability.apparmor
+//	com.amazonaws.ecs.capability.ecr-auth
+//	com.amazonaws.ecs.capability.task-iam-role
+//	com.amazonaws.ecs.capability.task-iam-role-network-host
 func (engine *DockerTaskEngine) Capabilities() []string {
 	capabilities := []string{}
 	if !engine.cfg.PrivilegedDisabled {
@@ -713,7 +1255,7 @@ func (engine *DockerTaskEngine) Capabilities() []string {
 		versions[version] = true
 	}
 
-	for _, loggingDriver := range engine.cfg.AvailableLoggingDrivers {
+	for _, loggingDriver := range engine.availableLoggingDrivers() {
 		requiredVersion := dockerclient.LoggingDriverMinimumVersion[loggingDriver]
 		if _, ok := versions[requiredVersion]; ok {
 			capabilities = append(capabilities, capabilityPrefix+"logging-driver."+string(loggingDriver))
@@ -758,3 +1300,19 @@ func (engine *DockerTaskEngine) Capabilities() []string {
 func (engine *DockerTaskEngine) Version() (string, error) {
 	return engine.client.Version()
 }
+
+// PingDocker checks that the underlying docker daemon is reachable
+func (engine *DockerTaskEngine) PingDocker() error {
+	return engine.client.Ping()
+}
+
+// DockerInfo returns a summary of the underlying docker daemon's information
+func (engine *DockerTaskEngine) DockerInfo() (DockerInfo, error) {
+	return engine.client.Info()
+}
+
+// ContainerLogs writes the last tail lines of the logs of a container to
+// output as the underlying docker daemon multiplexes them
+func (engine *DockerTaskEngine) ContainerLogs(ctx context.Context, dockerID string, tail int, follow bool, output io.Writer) error {
+	return engine.client.ContainerLogs(ctx, dockerID, tail, follow, output)
+}
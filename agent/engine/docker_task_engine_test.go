@@ -15,6 +15,10 @@
 package engine
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"testing"
@@ -25,6 +29,8 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/credentials/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
@@ -117,7 +123,7 @@ func TestBatchContainerHappyPath(t *testing.T) {
 				}()
 			}).Return(DockerContainerMetadata{DockerID: "containerId"})
 
-		client.EXPECT().StartContainer("containerId", startContainerTimeout).Do(
+		client.EXPECT().StartContainer("containerId", defaultConfig.ContainerStartTimeout).Do(
 			func(id string, timeout time.Duration) {
 				eventsReported.Add(1)
 				go func() {
@@ -246,7 +252,7 @@ func TestRemoveEvents(t *testing.T) {
 				}()
 			}).Return(DockerContainerMetadata{DockerID: "containerId"})
 
-		client.EXPECT().StartContainer("containerId", startContainerTimeout).Do(
+		client.EXPECT().StartContainer("containerId", defaultConfig.ContainerStartTimeout).Do(
 			func(id string, timeout time.Duration) {
 				eventsReported.Add(1)
 				go func() {
@@ -379,7 +385,7 @@ func TestStartTimeoutThenStart(t *testing.T) {
 				go func() { eventStream <- dockerEvent(api.ContainerCreated) }()
 			}).Return(DockerContainerMetadata{DockerID: "containerId"})
 
-		client.EXPECT().StartContainer("containerId", startContainerTimeout).Return(DockerContainerMetadata{
+		client.EXPECT().StartContainer("containerId", defaultConfig.ContainerStartTimeout).Return(DockerContainerMetadata{
 			Error: &DockerTimeoutError{},
 		})
 	}
@@ -413,7 +419,7 @@ func TestStartTimeoutThenStart(t *testing.T) {
 	}
 
 	// Expect it to try to stop it once now
-	client.EXPECT().StopContainer("containerId", gomock.Any()).Return(DockerContainerMetadata{
+	client.EXPECT().StopContainer("containerId", gomock.Any(), gomock.Any()).Return(DockerContainerMetadata{
 		Error: CannotXContainerError{
 			transition: "start",
 			msg:        "Cannot start",
@@ -477,7 +483,7 @@ func TestSteadyStatePoll(t *testing.T) {
 				}()
 			}).Return(DockerContainerMetadata{DockerID: "containerId"})
 
-		client.EXPECT().StartContainer("containerId", startContainerTimeout).Do(
+		client.EXPECT().StartContainer("containerId", defaultConfig.ContainerStartTimeout).Do(
 			func(id string, timeout time.Duration) {
 				go func() {
 					wait.Add(1)
@@ -526,7 +532,7 @@ func TestSteadyStatePoll(t *testing.T) {
 				DockerID: "containerId",
 			}).AnyTimes(),
 		// the engine *may* call StopContainer even though it's already stopped
-		client.EXPECT().StopContainer("containerId", stopContainerTimeout).AnyTimes(),
+		client.EXPECT().StopContainer("containerId", defaultConfig.DockerStopTimeout, stopContainerTimeout).AnyTimes(),
 	)
 	// trigger steady state verification
 	for i := 0; i < 10; i++ {
@@ -638,6 +644,827 @@ func TestCreateContainerForceSave(t *testing.T) {
 	}
 }
 
+func TestCreateContainerExceedingTaskLimits(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepTask.Memory = 1
+	sleepContainer.Memory = 2
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for a container exceeding the task memory limit")
+	}
+	if metadata.Error.ErrorName() != "TaskResourceError" {
+		t.Errorf("Unexpected error %v", metadata.Error)
+	}
+}
+
+func TestCreateContainerLoggingDrivers(t *testing.T) {
+	drivers := []dockerclient.LoggingDriver{
+		dockerclient.JsonFileDriver,
+		dockerclient.SyslogDriver,
+		dockerclient.JournaldDriver,
+		dockerclient.GelfDriver,
+		dockerclient.FluentdDriver,
+		dockerclient.AwslogsDriver,
+		dockerclient.SplunklogsDriver,
+	}
+	cfg := config.DefaultConfig()
+	cfg.AvailableLoggingDrivers = drivers
+
+	for _, driver := range drivers {
+		ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+		taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+		sleepTask := testdata.LoadTask("sleep5")
+		sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+		sleepContainer.DockerConfig.HostConfig = aws.String(`{"LogConfig":{"Type":"` + string(driver) + `","Config":{"key":"value"}}}`)
+
+		client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+			func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+				if hostConfig.LogConfig.Type != string(driver) {
+					t.Errorf("Expected logging driver %s, got: %s", driver, hostConfig.LogConfig.Type)
+				}
+				if hostConfig.LogConfig.Config["key"] != "value" {
+					t.Errorf("Expected logging driver options to be passed through for %s, got: %v", driver, hostConfig.LogConfig.Config)
+				}
+			})
+
+		metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+		if metadata.Error != nil {
+			t.Errorf("Unexpected error for logging driver %s: %v", driver, metadata.Error)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestCreateContainerUnavailableLoggingDriver(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AvailableLoggingDrivers = []dockerclient.LoggingDriver{dockerclient.JsonFileDriver}
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.DockerConfig.HostConfig = aws.String(`{"LogConfig":{"Type":"awslogs"}}`)
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for an unavailable logging driver")
+	}
+	if metadata.Error.ErrorName() != "UnavailableLoggingDriverError" {
+		t.Errorf("Unexpected error %v", metadata.Error)
+	}
+}
+
+func TestCreateContainerTmpfs(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.Tmpfs = []api.TmpfsMount{
+		api.TmpfsMount{ContainerPath: "/run", Size: "64m", MountOptions: []string{"noexec"}},
+	}
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, map[string]string{"/run": "noexec,size=64m"}, hostConfig.Tmpfs, "Wrong tmpfs mounts")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerInvalidTmpfs(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.Tmpfs = []api.TmpfsMount{
+		api.TmpfsMount{ContainerPath: "/run", Size: "lots"},
+	}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for an invalid tmpfs size")
+	}
+	assert.Equal(t, "HostConfigError", metadata.Error.ErrorName())
+	assert.Contains(t, metadata.Error.Error(), "Invalid tmpfs size")
+}
+
+func TestCreateContainerUlimits(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.Ulimits = []api.Ulimit{
+		api.Ulimit{Name: "nofile", SoftLimit: 1024, HardLimit: 4096},
+	}
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, []docker.ULimit{docker.ULimit{Name: "nofile", Soft: 1024, Hard: 4096}}, hostConfig.Ulimits, "Wrong ulimits")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerInvalidUlimits(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.Ulimits = []api.Ulimit{
+		api.Ulimit{Name: "nofile", SoftLimit: 4096, HardLimit: 1024},
+	}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for a soft limit above the hard limit")
+	}
+	assert.Equal(t, "HostConfigError", metadata.Error.ErrorName())
+	assert.Contains(t, metadata.Error.Error(), "soft limit 4096 is above hard limit 1024")
+}
+
+func TestCreateContainerNameResolution(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.DNSServers = []string{"10.0.0.2"}
+	sleepContainer.DNSSearchDomains = []string{"example.com"}
+	sleepContainer.ExtraHosts = []api.HostEntry{api.HostEntry{Hostname: "db", IPAddress: "10.0.0.5"}}
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, []string{"10.0.0.2"}, hostConfig.DNS, "Wrong DNS servers")
+			assert.Equal(t, []string{"example.com"}, hostConfig.DNSSearch, "Wrong DNS search domains")
+			assert.Equal(t, []string{"db:10.0.0.5"}, hostConfig.ExtraHosts, "Wrong extra hosts")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerInvalidDNSServer(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.DNSServers = []string{"dns.example.com"}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for a DNS server that isn't an IP address")
+	}
+	assert.Equal(t, "HostConfigError", metadata.Error.ErrorName())
+	assert.Contains(t, metadata.Error.Error(), "Invalid DNS server")
+}
+
+func TestCreateContainerReadonlyRootFilesystem(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.ReadonlyRootFilesystem = true
+	sleepContainer.Tmpfs = []api.TmpfsMount{api.TmpfsMount{ContainerPath: "/tmp"}}
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.True(t, hostConfig.ReadonlyRootfs, "Expected a read only root filesystem")
+			assert.Equal(t, map[string]string{"/tmp": ""}, hostConfig.Tmpfs, "Wrong tmpfs mounts")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerCapabilities(t *testing.T) {
+	cfg := defaultConfig
+	cfg.AllowedCapabilities = []string{"NET_ADMIN"}
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.LinuxParameters = &api.LinuxParameters{Capabilities: &api.KernelCapabilities{
+		Add:  []string{"NET_ADMIN"},
+		Drop: []string{"MKNOD", "SYS_CHROOT"},
+	}}
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, []string{"NET_ADMIN"}, hostConfig.CapAdd, "Wrong added capabilities")
+			assert.Equal(t, []string{"MKNOD", "SYS_CHROOT"}, hostConfig.CapDrop, "Wrong dropped capabilities")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerCapabilityNotAllowed(t *testing.T) {
+	cfg := defaultConfig
+	cfg.AllowedCapabilities = []string{"NET_ADMIN"}
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.LinuxParameters = &api.LinuxParameters{Capabilities: &api.KernelCapabilities{
+		Add: []string{"NET_ADMIN", "SYS_ADMIN"},
+	}}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for a capability that isn't allowed")
+	}
+	assert.Equal(t, "CapabilityNotAllowedError", metadata.Error.ErrorName())
+	assert.Contains(t, metadata.Error.Error(), "SYS_ADMIN")
+}
+
+func TestCreateContainerSysctls(t *testing.T) {
+	cfg := defaultConfig
+	cfg.AllowedSysctls = []string{"net.core.somaxconn", "net.ipv4.*"}
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.LinuxParameters = &api.LinuxParameters{SystemControls: []api.SystemControl{
+		{Namespace: "net.core.somaxconn", Value: "1024"},
+		{Namespace: "net.ipv4.tcp_keepalive_time", Value: "300"},
+	}}
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, map[string]string{
+				"net.core.somaxconn":          "1024",
+				"net.ipv4.tcp_keepalive_time": "300",
+			}, hostConfig.Sysctls, "Wrong sysctls")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerSysctlNotAllowed(t *testing.T) {
+	cfg := defaultConfig
+	cfg.AllowedSysctls = []string{"net.core.somaxconn", "net.ipv4.*"}
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	for _, name := range []string{"net.core.rmem_max", "net.ipv4", "kernel.shmmax"} {
+		sleepTask := testdata.LoadTask("sleep5")
+		sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+		sleepContainer.LinuxParameters = &api.LinuxParameters{SystemControls: []api.SystemControl{
+			{Namespace: "net.core.somaxconn", Value: "1024"},
+			{Namespace: name, Value: "1"},
+		}}
+
+		// No calls to the client are expected
+		metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+		if metadata.Error == nil {
+			t.Errorf("Expected an error for %s, which isn't allowed", name)
+			continue
+		}
+		assert.Equal(t, "SysctlNotAllowedError", metadata.Error.ErrorName())
+		assert.Contains(t, metadata.Error.Error(), name)
+	}
+}
+
+func TestApplySeccompProfile(t *testing.T) {
+	profileDir, err := ioutil.TempDir("", "seccomp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(profileDir)
+	restricted := `{"defaultAction":"SCMP_ACT_ERRNO"}`
+	ioutil.WriteFile(filepath.Join(profileDir, "restricted.json"), []byte(restricted), 0644)
+	ioutil.WriteFile(filepath.Join(profileDir, "broken.json"), []byte(`{"defaultAction":`), 0644)
+
+	testCases := []struct {
+		name              string
+		unconfinedAllowed bool
+		option            string
+		expectedOption    string
+		expectedErrorName string
+	}{
+		{"named profile", false, "seccomp=restricted", "seccomp=" + restricted, ""},
+		{"named profile with colon", false, "seccomp:restricted", "seccomp=" + restricted, ""},
+		{"missing named profile", false, "seccomp=missing", "", "CannotLoadSeccompProfileError"},
+		{"invalid named profile", false, "seccomp=broken", "", "CannotLoadSeccompProfileError"},
+		{"inline profile", false, "seccomp=" + restricted, "seccomp=" + restricted, ""},
+		{"unconfined not allowed", false, "seccomp=unconfined", "", "SecurityOptionNotAllowedError"},
+		{"unconfined allowed", true, "seccomp=unconfined", "seccomp=unconfined", ""},
+		{"other option", false, "label=disable", "label=disable", ""},
+	}
+	for _, tc := range testCases {
+		cfg := defaultConfig
+		cfg.SeccompProfileDir = profileDir
+		cfg.SeccompUnconfinedAllowed = tc.unconfinedAllowed
+		taskEngine := &DockerTaskEngine{cfg: &cfg}
+
+		hostConfig := &docker.HostConfig{SecurityOpt: []string{tc.option}}
+		err := taskEngine.applySeccompProfile(hostConfig)
+		if tc.expectedErrorName != "" {
+			if assert.NotNil(t, err, tc.name) {
+				assert.Equal(t, tc.expectedErrorName, err.ErrorName(), tc.name)
+			}
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, []string{tc.expectedOption}, hostConfig.SecurityOpt, tc.name)
+	}
+}
+
+func TestCreateContainerSeccompUnconfinedNotAllowed(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.DockerSecurityOptions = []string{"seccomp:unconfined"}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for running unconfined")
+	}
+	assert.Equal(t, "SecurityOptionNotAllowedError", metadata.Error.ErrorName())
+}
+
+func TestCheckPrivileged(t *testing.T) {
+	testCases := []struct {
+		name       string
+		disabled   bool
+		strict     bool
+		hostConfig docker.HostConfig
+		allowed    bool
+	}{
+		{"privileged permitted", false, false, docker.HostConfig{Privileged: true}, true},
+		{"privileged permitted with strict checking", false, true, docker.HostConfig{Privileged: true, CapAdd: []string{"SYS_ADMIN"}}, true},
+		{"privileged blocked", true, false, docker.HostConfig{Privileged: true}, false},
+		{"unprivileged", true, true, docker.HostConfig{CapAdd: []string{"NET_ADMIN"}}, true},
+		{"escalation without strict checking", true, false, docker.HostConfig{CapAdd: []string{"SYS_ADMIN"}}, true},
+		{"escalation blocked", true, true, docker.HostConfig{CapAdd: []string{"NET_ADMIN", "SYS_ADMIN"}}, false},
+		{"escalation blocked with prefix", true, true, docker.HostConfig{CapAdd: []string{"cap_sys_module"}}, false},
+		{"all capabilities blocked", true, true, docker.HostConfig{CapAdd: []string{"ALL"}}, false},
+	}
+	for _, tc := range testCases {
+		cfg := defaultConfig
+		cfg.PrivilegedDisabled = tc.disabled
+		cfg.StrictPrivilegedChecking = tc.strict
+		ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+		taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+		err := taskEngine.checkPrivileged(&tc.hostConfig)
+		if tc.allowed {
+			assert.Nil(t, err, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Equal(t, "PrivilegedNotAllowedError", err.ErrorName(), tc.name)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestCreateContainerPrivileged(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	hostConfig := `{"Privileged":true}`
+	sleepContainer.DockerConfig.HostConfig = &hostConfig
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.True(t, hostConfig.Privileged, "Expected the container to be privileged")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerPrivilegedNotAllowed(t *testing.T) {
+	cfg := defaultConfig
+	cfg.PrivilegedDisabled = true
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	hostConfig := `{"Privileged":true}`
+	sleepContainer.DockerConfig.HostConfig = &hostConfig
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for a privileged container")
+	}
+	assert.Equal(t, "PrivilegedNotAllowedError", metadata.Error.ErrorName())
+
+	problems := taskEngine.ValidateTask(sleepTask)
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, TaskProblem{Container: "sleep5", Name: "PrivilegedNotAllowedError", Reason: "Privileged containers are not allowed on this instance"}, problems[0])
+	}
+}
+
+func TestCreateContainerLabels(t *testing.T) {
+	cfg := defaultConfig
+	cfg.Cluster = "prod"
+	cfg.ContainerLabels = map[string]string{"team": "payments", "stack": "blue"}
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.DockerConfig.Config = aws.String(`{"Labels":{"stack":"green","com.amazonaws.ecs.cluster":"other","app":"sleep"}}`)
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, map[string]string{
+				"app":   "sleep",
+				"team":  "payments",
+				"stack": "blue",
+
+				"com.amazonaws.ecs.task-arn":                sleepTask.Arn,
+				"com.amazonaws.ecs.container-name":          "sleep5",
+				"com.amazonaws.ecs.task-definition-family":  sleepTask.Family,
+				"com.amazonaws.ecs.task-definition-version": sleepTask.Version,
+				"com.amazonaws.ecs.cluster":                 "prod",
+			}, config.Labels)
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestDockerCPUQuota(t *testing.T) {
+	testCases := []struct {
+		cpu    uint
+		period int64
+		quota  int64
+	}{
+		{0, 0, 0},
+		{1, 100000, 1000},
+		{10, 100000, 1000},
+		{11, 100000, 1074},
+		{256, 100000, 25000},
+		{300, 100000, 29297},
+		{1024, 100000, 100000},
+		{1536, 100000, 150000},
+		{4096, 100000, 400000},
+	}
+	for _, tc := range testCases {
+		period, quota := dockerCPUQuota(tc.cpu)
+		assert.Equal(t, tc.period, period, "Wrong period for %d cpu units", tc.cpu)
+		assert.Equal(t, tc.quota, quota, "Wrong quota for %d cpu units", tc.cpu)
+	}
+}
+
+func TestCreateContainerCPUQuota(t *testing.T) {
+	for _, mode := range []string{config.CPULimitModeShares, config.CPULimitModeQuota} {
+		cfg := defaultConfig
+		cfg.CPULimitMode = mode
+		ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+		taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+		sleepTask := testdata.LoadTask("sleep5")
+		sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+		sleepContainer.Cpu = 512
+
+		client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+			func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+				assert.Equal(t, int64(512), config.CPUShares, mode)
+				if mode == "quota" {
+					assert.Equal(t, int64(100000), hostConfig.CPUPeriod, mode)
+					assert.Equal(t, int64(50000), hostConfig.CPUQuota, mode)
+				} else {
+					assert.Zero(t, hostConfig.CPUPeriod, mode)
+					assert.Zero(t, hostConfig.CPUQuota, mode)
+				}
+			})
+
+		metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+		assert.Nil(t, metadata.Error, mode)
+		ctrl.Finish()
+	}
+}
+
+func TestCreateContainerHostNamespaces(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepTask.PidMode = api.NamespaceModeHost
+	sleepTask.IpcMode = api.NamespaceModeHost
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, "host", hostConfig.PidMode)
+			assert.Equal(t, "host", hostConfig.IpcMode)
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerHostNamespacesNotAllowed(t *testing.T) {
+	for _, namespace := range []string{"PID", "IPC"} {
+		cfg := defaultConfig
+		cfg.HostNamespacesDisabled = true
+		ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+		taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+		sleepTask := testdata.LoadTask("sleep5")
+		if namespace == "PID" {
+			sleepTask.PidMode = api.NamespaceModeHost
+		} else {
+			sleepTask.IpcMode = api.NamespaceModeHost
+		}
+		sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+
+		// No calls to the client are expected
+		metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+		if assert.NotNil(t, metadata.Error, namespace) {
+			assert.Equal(t, "HostNamespaceNotAllowedError", metadata.Error.ErrorName(), namespace)
+		}
+
+		problems := taskEngine.ValidateTask(sleepTask)
+		if assert.Len(t, problems, 1, namespace) {
+			assert.Equal(t, "HostNamespaceNotAllowedError", problems[0].Name, namespace)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestCreateContainerDevices(t *testing.T) {
+	cfg := defaultConfig
+	cfg.StrictDeviceChecking = true
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.LinuxParameters = &api.LinuxParameters{Devices: []api.Device{
+		api.Device{HostPath: "/dev/null", ContainerPath: "/dev/sink", Permissions: "rw"},
+	}}
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			expected := []docker.Device{docker.Device{PathOnHost: "/dev/null", PathInContainer: "/dev/sink", CgroupPermissions: "rw"}}
+			assert.Equal(t, expected, hostConfig.Devices, "Wrong devices")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerDeviceNotFound(t *testing.T) {
+	cfg := defaultConfig
+	cfg.StrictDeviceChecking = true
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.LinuxParameters = &api.LinuxParameters{Devices: []api.Device{
+		api.Device{HostPath: "/dev/does-not-exist"},
+	}}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for a device that doesn't exist")
+	}
+	assert.Equal(t, "DeviceNotFoundError", metadata.Error.ErrorName())
+	assert.Contains(t, metadata.Error.Error(), "/dev/does-not-exist")
+}
+
+func TestCreateContainerShmSize(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ContainerShmSizeLimit = 512
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	shmSize := int64(512)
+	sleepContainer.LinuxParameters = &api.LinuxParameters{ShmSize: &shmSize}
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, int64(512*1024*1024), hostConfig.ShmSize, "Wrong shared memory size")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerShmSizeAboveLimit(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ContainerShmSizeLimit = 512
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	shmSize := int64(1024)
+	sleepContainer.LinuxParameters = &api.LinuxParameters{ShmSize: &shmSize}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for a shared memory size above the limit")
+	}
+	assert.Equal(t, "ShmSizeLimitError", metadata.Error.ErrorName())
+}
+
+func TestCreateContainerInvalidShmSize(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	shmSize := int64(-1)
+	sleepContainer.LinuxParameters = &api.LinuxParameters{ShmSize: &shmSize}
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for a negative shared memory size")
+	}
+	assert.Equal(t, "HostConfigError", metadata.Error.ErrorName())
+}
+
+func TestCreateContainerInit(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.LinuxParameters = &api.LinuxParameters{Init: true}
+
+	client.EXPECT().APIVersion().Return(dockerclient.Version_1_25)
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.True(t, hostConfig.Init, "Expected an init process")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerInitUnsupported(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.LinuxParameters = &api.LinuxParameters{Init: true}
+
+	// The container isn't created with a version that can't run an init process
+	client.EXPECT().APIVersion().Return(dockerclient.Version_1_24)
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil {
+		t.Fatal("Expected an error for an init process with an unsupported version")
+	}
+	assert.Equal(t, "UnsupportedDockerVersionError", metadata.Error.ErrorName())
+	assert.Contains(t, metadata.Error.Error(), "1.25")
+}
+
+// goClientTaskEngine returns a task engine using a docker client backed by
+// the returned mock of the docker api, such that timeouts of the client apply
+func goClientTaskEngine(t *testing.T, cfg config.Config) (*mock_dockeriface.MockClient, *DockerTaskEngine, func()) {
+	mockDocker, client, _, done := dockerClientSetupWithConfig(t, cfg)
+	containerChangeEventStream := eventstream.NewEventStream("TESTTASKENGINE", context.Background())
+	taskEngine := NewDockerTaskEngine(&cfg, client, nil, containerChangeEventStream, nil, dockerstate.NewDockerTaskEngineState())
+	return mockDocker, taskEngine, done
+}
+
+func TestCreateContainerTimeoutRemovesContainer(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ContainerCreateTimeout = xContainerShortTimeout
+	mockDocker, taskEngine, done := goClientTaskEngine(t, cfg)
+	defer done()
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+
+	// Creating the container blocks past the deadline until released
+	release := make(chan struct{})
+	defer close(release)
+	createdName := make(chan string, 1)
+	mockDocker.EXPECT().CreateContainerWithHostConfig(gomock.Any(), gomock.Any()).Do(func(opts docker.CreateContainerOptions, hostConfig *dockeriface.HostConfig) {
+		createdName <- opts.Name
+		<-release
+	}).Return(nil, errors.New("create canceled"))
+	removed := make(chan string, 1)
+	mockDocker.EXPECT().RemoveContainer(gomock.Any()).Do(func(opts docker.RemoveContainerOptions) {
+		removed <- opts.ID
+	}).Return(nil)
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil || metadata.Error.ErrorName() != "DockerTimeoutError" {
+		t.Fatalf("Expected DockerTimeoutError, got: %v", metadata.Error)
+	}
+	assert.Equal(t, <-createdName, <-removed, "Expected the container that timed out to be removed")
+}
+
+func TestStartContainerTimeoutUsesConfig(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ContainerStartTimeout = xContainerShortTimeout
+	mockDocker, taskEngine, done := goClientTaskEngine(t, cfg)
+	defer done()
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	taskEngine.state.AddTask(sleepTask)
+	taskEngine.state.AddContainer(&api.DockerContainer{DockerId: "id", DockerName: "name", Container: sleepContainer}, sleepTask)
+
+	// Starting the container blocks past the deadline until released
+	release := make(chan struct{})
+	defer close(release)
+	mockDocker.EXPECT().StartContainerWithContext("id", nil, gomock.Any()).Do(func(id string, hostConfig *docker.HostConfig, ctx context.Context) {
+		<-release
+	}).Return(errors.New("start canceled"))
+	mockDocker.EXPECT().InspectContainerWithContext("id", gomock.Any()).Return(nil, errors.New("inspect canceled")).AnyTimes()
+
+	metadata := taskEngine.startContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil || metadata.Error.ErrorName() != "DockerTimeoutError" {
+		t.Fatalf("Expected DockerTimeoutError, got: %v", metadata.Error)
+	}
+	assert.Contains(t, metadata.Error.Error(), "started")
+}
+
 func TestCreateContainerMergesLabels(t *testing.T) {
 	ctrl, client, _, taskEngine, _, _ := mocks(t, &defaultConfig)
 	defer ctrl.Finish()
@@ -722,7 +1549,7 @@ func TestTaskTransitionWhenStopContainerTimesout(t *testing.T) {
 		// StartContainer returns timeout error. This should cause the engine
 		// to transition the task to STOPPED and to stop all containers of
 		// the task
-		client.EXPECT().StartContainer("containerId", startContainerTimeout).Do(
+		client.EXPECT().StartContainer("containerId", defaultConfig.ContainerStartTimeout).Do(
 			func(id string, timeout time.Duration) {
 				go func() {
 					eventStream <- dockerEvent(api.ContainerRunning)
@@ -731,13 +1558,13 @@ func TestTaskTransitionWhenStopContainerTimesout(t *testing.T) {
 
 		gomock.InOrder(
 			// StopContainer times out as well
-			client.EXPECT().StopContainer("containerId", gomock.Any()).Return(containerStopTimeoutError),
+			client.EXPECT().StopContainer("containerId", gomock.Any(), gomock.Any()).Return(containerStopTimeoutError),
 			// Since task is not in steady state, progressContainers causes
 			// another invocation of StopContainer. Return a timeout error
 			// for that as well
 			// TODO change AnyTimes() to MinTimes(1) after updating gomock
-			client.EXPECT().StopContainer("containerId", gomock.Any()).Do(
-				func(id string, timeout time.Duration) {
+			client.EXPECT().StopContainer("containerId", gomock.Any(), gomock.Any()).Do(
+				func(id string, stopTimeout, timeout time.Duration) {
 					go func() {
 						dockerEventSent <- 1
 						eventStream <- dockerEvent(api.ContainerStopped)
@@ -829,6 +1656,8 @@ func TestCapabilities(t *testing.T) {
 		dockerclient.Version_1_17,
 		dockerclient.Version_1_18,
 	})
+	// The daemon doesn't list its logging drivers
+	client.EXPECT().Info().Return(DockerInfo{}, nil)
 
 	capabilities := taskEngine.Capabilities()
 
@@ -989,3 +1818,72 @@ func TestGetTaskByArn(t *testing.T) {
 		t.Fatal("Task with invalid arn found in the task engine")
 	}
 }
+
+func TestContainerStopTimeout(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	testCases := []struct {
+		stopTimeout uint
+		expected    time.Duration
+	}{
+		{0, defaultConfig.DockerStopTimeout},
+		{120, 120 * time.Second},
+		{uint(2 * config.MaximumDockerStopTimeout / time.Second), config.MaximumDockerStopTimeout},
+	}
+	for _, tc := range testCases {
+		container := &api.Container{Name: "c", StopTimeout: tc.stopTimeout}
+		if timeout := taskEngine.containerStopTimeout(container); timeout != tc.expected {
+			t.Errorf("Expected stop timeout %v for %d seconds, got %v", tc.expected, tc.stopTimeout, timeout)
+		}
+	}
+}
+
+func TestPullContainerImagePullBehavior(t *testing.T) {
+	pullErr := CannotXContainerError{"Pull", "registry unavailable"}
+	testCases := []struct {
+		name          string
+		behavior      string
+		imagePresent  bool
+		expectPull    bool
+		pullError     engineError
+		expectedError bool
+	}{
+		{"always pulls present images", config.ImagePullBehaviorAlways, true, true, nil, false},
+		{"always fails when the pull fails", config.ImagePullBehaviorAlways, true, true, pullErr, true},
+		{"once skips present images", config.ImagePullBehaviorOnce, true, false, nil, false},
+		{"once pulls missing images", config.ImagePullBehaviorOnce, false, true, nil, false},
+		{"once fails when the pull fails", config.ImagePullBehaviorOnce, false, true, pullErr, true},
+		{"prefer-cached pulls present images", config.ImagePullBehaviorPreferCached, true, true, nil, false},
+		{"prefer-cached uses present images when the pull fails", config.ImagePullBehaviorPreferCached, true, true, pullErr, false},
+		{"prefer-cached fails when the pull of a missing image fails", config.ImagePullBehaviorPreferCached, false, true, pullErr, true},
+	}
+	for _, tc := range testCases {
+		cfg := defaultConfig
+		cfg.ImagePullBehavior = tc.behavior
+		ctrl, client, _, privateTaskEngine, _, imageManager := mocks(t, &cfg)
+		taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+		task := &api.Task{Arn: "myArn", DesiredStatus: api.TaskRunning}
+		container := &api.Container{Name: "c1", Image: "image"}
+		task.Containers = []*api.Container{container}
+
+		var inspectErr error
+		if !tc.imagePresent {
+			inspectErr = errors.New("no such image")
+		}
+		if tc.behavior == config.ImagePullBehaviorOnce || tc.pullError != nil && tc.behavior == config.ImagePullBehaviorPreferCached {
+			client.EXPECT().InspectImage("image").Return(&docker.Image{}, inspectErr)
+		}
+		if tc.expectPull {
+			client.EXPECT().PullImage("image", nil).Return(DockerContainerMetadata{Error: tc.pullError})
+		}
+		imageManager.EXPECT().RecordContainerReference(container).Return(nil)
+		imageManager.EXPECT().GetImageStateFromImageName("image").Return(nil)
+
+		metadata := taskEngine.pullContainer(task, container)
+		assert.Equal(t, tc.expectedError, metadata.Error != nil, tc.name)
+		ctrl.Finish()
+	}
+}
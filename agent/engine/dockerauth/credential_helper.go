@@ -0,0 +1,183 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/context"
+)
+
+const (
+	// credentialHelperPrefix is the prefix of the name of credential helper
+	// binaries, which are configured by the rest of their name
+	credentialHelperPrefix = "docker-credential-"
+
+	// credentialHelperTimeout is how long a credential helper may run
+	credentialHelperTimeout = 30 * time.Second
+
+	// credentialHelperCacheDuration is how long credentials are cached when
+	// the helper doesn't say when they expire
+	credentialHelperCacheDuration = 15 * time.Minute
+
+	// credentialHelperRefreshWindow is how long before they expire cached
+	// credentials are refreshed
+	credentialHelperRefreshWindow = time.Minute
+)
+
+// credentialHelperOutput is the output of the 'get' command of a credential
+// helper. ExpiresAt is not part of the docker credential helper protocol; it
+// may be set by helpers that hand out short lived tokens, as an RFC 3339
+// timestamp
+type credentialHelperOutput struct {
+	ServerURL string
+	Username  string
+	Secret    string
+	ExpiresAt string
+}
+
+type cachedCredentials struct {
+	authConfig docker.AuthConfiguration
+	expiresAt  time.Time
+}
+
+type credentialHelperAuthProvider struct {
+	helpers  map[string]string
+	fallback DockerAuthProvider
+
+	// runHelper runs the 'get' command of the helper for the registry and
+	// returns its output
+	runHelper func(helper string, registry string) ([]byte, error)
+	time      ttime.Time
+
+	cache     map[string]cachedCredentials
+	cacheLock sync.Mutex
+}
+
+// NewCredentialHelperAuthProvider returns a DockerAuthProvider that gets the
+// auth for the registries in helpers, keyed by registry hostname, from the
+// docker credential helper named for each (e.g. "ecr-login" runs
+// docker-credential-ecr-login). Auth for other registries is retrieved from
+// fallback. Credentials are cached until shortly before they expire
+func NewCredentialHelperAuthProvider(helpers map[string]string, fallback DockerAuthProvider) DockerAuthProvider {
+	if len(helpers) == 0 {
+		return fallback
+	}
+	normalized := make(map[string]string)
+	for registry, helper := range helpers {
+		normalized[strings.TrimSuffix(stripRegistrySchema(registry), "/")] = helper
+	}
+	return &credentialHelperAuthProvider{
+		helpers:   normalized,
+		fallback:  fallback,
+		runHelper: runCredentialHelper,
+		time:      &ttime.DefaultTime{},
+		cache:     make(map[string]cachedCredentials),
+	}
+}
+
+// GetAuthconfig retrieves the correct auth configuration for the given repository
+func (authProvider *credentialHelperAuthProvider) GetAuthconfig(image string) (docker.AuthConfiguration, error) {
+	repository, _ := docker.ParseRepositoryTag(image)
+	registry, _ := splitReposName(repository)
+	helper, ok := authProvider.helpers[registry]
+	if !ok {
+		return authProvider.fallback.GetAuthconfig(image)
+	}
+
+	authProvider.cacheLock.Lock()
+	defer authProvider.cacheLock.Unlock()
+
+	now := authProvider.time.Now()
+	cached, ok := authProvider.cache[registry]
+	if ok && now.Before(cached.expiresAt.Add(-credentialHelperRefreshWindow)) {
+		return cached.authConfig, nil
+	}
+
+	fresh, err := authProvider.getCredentials(helper, registry, now)
+	if err != nil {
+		if ok && now.Before(cached.expiresAt) {
+			seelog.Warnf("Unable to refresh credentials for registry %s, using cached credentials: %v", registry, err)
+			return cached.authConfig, nil
+		}
+		return docker.AuthConfiguration{}, err
+	}
+	authProvider.cache[registry] = fresh
+	return fresh.authConfig, nil
+}
+
+func (authProvider *credentialHelperAuthProvider) getCredentials(helper string, registry string, now time.Time) (cachedCredentials, error) {
+	seelog.Debugf("Getting credentials for registry %s from %s%s", registry, credentialHelperPrefix, helper)
+	out, err := authProvider.runHelper(helper, registry)
+	if err != nil {
+		return cachedCredentials{}, fmt.Errorf("credential helper %s%s failed for registry %s: %v", credentialHelperPrefix, helper, registry, err)
+	}
+
+	var output credentialHelperOutput
+	err = json.Unmarshal(out, &output)
+	if err != nil {
+		return cachedCredentials{}, fmt.Errorf("invalid output from credential helper %s%s: %v", credentialHelperPrefix, helper, err)
+	}
+
+	expiresAt := now.Add(credentialHelperCacheDuration)
+	if output.ExpiresAt != "" {
+		expiresAt, err = time.Parse(time.RFC3339, output.ExpiresAt)
+		if err != nil {
+			return cachedCredentials{}, fmt.Errorf("invalid expiration from credential helper %s%s: %v", credentialHelperPrefix, helper, err)
+		}
+	}
+
+	serverAddress := output.ServerURL
+	if serverAddress == "" {
+		serverAddress = registry
+	}
+	return cachedCredentials{
+		authConfig: docker.AuthConfiguration{
+			Username:      output.Username,
+			Password:      output.Secret,
+			ServerAddress: serverAddress,
+		},
+		expiresAt: expiresAt,
+	}, nil
+}
+
+// runCredentialHelper runs 'docker-credential-<helper> get', which reads the
+// registry from stdin and writes the credentials for it to stdout
+func runCredentialHelper(helper string, registry string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, credentialHelperPrefix+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String() + " " + stdout.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
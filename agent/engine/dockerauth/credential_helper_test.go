@@ -0,0 +1,178 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+)
+
+type fakeHelper struct {
+	outputs []string
+	err     error
+	calls   []string
+}
+
+func (helper *fakeHelper) run(name string, registry string) ([]byte, error) {
+	helper.calls = append(helper.calls, name+" "+registry)
+	if helper.err != nil {
+		return nil, helper.err
+	}
+	out := helper.outputs[0]
+	helper.outputs = helper.outputs[1:]
+	return []byte(out), nil
+}
+
+func credentialHelperProvider(t *testing.T, helper *fakeHelper) (*credentialHelperAuthProvider, *mock_ttime.MockTime, func()) {
+	ctrl := gomock.NewController(t)
+	mockTime := mock_ttime.NewMockTime(ctrl)
+	fallback := NewDockerAuthProvider("docker", []byte(`{"other.registry.example.com":{"username":"fallback","password":"pass"}}`))
+	provider := NewCredentialHelperAuthProvider(map[string]string{"https://registry.example.com": "example-login"}, fallback).(*credentialHelperAuthProvider)
+	provider.runHelper = helper.run
+	provider.time = mockTime
+	return provider, mockTime, ctrl.Finish
+}
+
+func TestCredentialHelperAuth(t *testing.T) {
+	helper := &fakeHelper{outputs: []string{
+		`{"ServerURL":"https://registry.example.com","Username":"user","Secret":"token1"}`,
+	}}
+	provider, mockTime, done := credentialHelperProvider(t, helper)
+	defer done()
+
+	now := time.Now()
+	mockTime.EXPECT().Now().Return(now)
+	authConfig, err := provider.GetAuthconfig("registry.example.com/myimage:tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := docker.AuthConfiguration{Username: "user", Password: "token1", ServerAddress: "https://registry.example.com"}
+	if authConfig != expected {
+		t.Errorf("Expected %v, got: %v", expected, authConfig)
+	}
+	if len(helper.calls) != 1 || helper.calls[0] != "example-login registry.example.com" {
+		t.Errorf("Unexpected helper calls: %v", helper.calls)
+	}
+
+	// Cached until shortly before the default cache duration ends
+	mockTime.EXPECT().Now().Return(now.Add(credentialHelperCacheDuration - credentialHelperRefreshWindow - time.Second))
+	authConfig, err = provider.GetAuthconfig("registry.example.com/otherimage")
+	if err != nil || authConfig != expected {
+		t.Errorf("Expected cached auth, got: %v, %v", authConfig, err)
+	}
+	if len(helper.calls) != 1 {
+		t.Errorf("Expected cached credentials to be used, helper called: %v", helper.calls)
+	}
+}
+
+func TestCredentialHelperAuthRefreshedBeforeExpiry(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	expiresAt := now.Add(5 * time.Minute)
+	helper := &fakeHelper{outputs: []string{
+		`{"Username":"user","Secret":"token1","ExpiresAt":"` + expiresAt.Format(time.RFC3339) + `"}`,
+		`{"Username":"user","Secret":"token2"}`,
+	}}
+	provider, mockTime, done := credentialHelperProvider(t, helper)
+	defer done()
+
+	gomock.InOrder(
+		mockTime.EXPECT().Now().Return(now),
+		mockTime.EXPECT().Now().Return(expiresAt.Add(-2*credentialHelperRefreshWindow)),
+		mockTime.EXPECT().Now().Return(expiresAt.Add(-credentialHelperRefreshWindow/2)),
+	)
+	for _, expectedPassword := range []string{"token1", "token1", "token2"} {
+		authConfig, err := provider.GetAuthconfig("registry.example.com/myimage")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authConfig.Password != expectedPassword {
+			t.Errorf("Expected password %s, got: %s", expectedPassword, authConfig.Password)
+		}
+		if authConfig.ServerAddress != "registry.example.com" {
+			t.Errorf("Expected server address to default to the registry, got: %s", authConfig.ServerAddress)
+		}
+	}
+	if len(helper.calls) != 2 {
+		t.Errorf("Expected credentials to be refreshed once, helper called: %v", helper.calls)
+	}
+}
+
+func TestCredentialHelperAuthRefreshFailureUsesCachedCredentials(t *testing.T) {
+	helper := &fakeHelper{outputs: []string{`{"Username":"user","Secret":"token1"}`}}
+	provider, mockTime, done := credentialHelperProvider(t, helper)
+	defer done()
+
+	now := time.Now()
+	mockTime.EXPECT().Now().Return(now)
+	provider.GetAuthconfig("registry.example.com/myimage")
+
+	helper.err = errors.New("helper failed")
+	mockTime.EXPECT().Now().Return(now.Add(credentialHelperCacheDuration - time.Second))
+	authConfig, err := provider.GetAuthconfig("registry.example.com/myimage")
+	if err != nil || authConfig.Password != "token1" {
+		t.Errorf("Expected cached credentials while they're valid, got: %v, %v", authConfig, err)
+	}
+
+	mockTime.EXPECT().Now().Return(now.Add(credentialHelperCacheDuration))
+	_, err = provider.GetAuthconfig("registry.example.com/myimage")
+	if err == nil {
+		t.Error("Expected error once cached credentials expired")
+	}
+}
+
+func TestCredentialHelperAuthErrors(t *testing.T) {
+	for _, helper := range []*fakeHelper{
+		{err: errors.New("credentials not found in native keychain")},
+		{outputs: []string{"not json"}},
+		{outputs: []string{`{"Username":"user","Secret":"token","ExpiresAt":"tomorrow"}`}},
+	} {
+		provider, mockTime, done := credentialHelperProvider(t, helper)
+		mockTime.EXPECT().Now().Return(time.Now())
+		_, err := provider.GetAuthconfig("registry.example.com/myimage")
+		if err == nil {
+			t.Error("Expected error from credential helper")
+		}
+		done()
+	}
+}
+
+func TestCredentialHelperAuthFallback(t *testing.T) {
+	helper := &fakeHelper{}
+	provider, _, done := credentialHelperProvider(t, helper)
+	defer done()
+
+	authConfig, err := provider.GetAuthconfig("other.registry.example.com/myimage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authConfig.Username != "fallback" {
+		t.Errorf("Expected auth from fallback provider, got: %v", authConfig)
+	}
+	if len(helper.calls) != 0 {
+		t.Errorf("Expected helper to not be called, got: %v", helper.calls)
+	}
+}
+
+func TestNoCredentialHelpers(t *testing.T) {
+	fallback := NewDockerAuthProvider("", nil)
+	if provider := NewCredentialHelperAuthProvider(nil, fallback); provider != fallback {
+		t.Error("Expected fallback provider when no credential helpers are configured")
+	}
+}
@@ -54,5 +54,21 @@ The auth type "dockercfg" is intended to allow easy use of an existing
 the "AuthData" to be a string containing the contents of that file. The contents
 of your ".dockercfg" will generally be a string of the following form:
 	'{"http://myregistry.com/v1/":{"auth":"dXNlcjpzd29yZGZpc2g=","email":"email"}'
+
+Credential Helpers
+
+Auth for some registries may instead come from docker credential helpers, by
+setting "ECS_ENGINE_AUTH_CREDENTIAL_HELPERS", or the key
+"EngineAuthCredentialHelpers" in the JSON configuration file, to an object from
+registry hostname to helper name:
+	{
+		"my.registry.example.com": "example-login"
+	}
+
+The agent runs "docker-credential-example-login get" with the registry on
+stdin before pulling from it, and expects the usual helper output of
+"ServerURL", "Username" and "Secret" on stdout. The credentials are cached for
+15 minutes, or until the time given by an optional "ExpiresAt" RFC 3339
+timestamp in the output, and are refreshed shortly before they expire.
 */
 package dockerauth
@@ -16,6 +16,7 @@ package dockerauth
 import (
 	"encoding/base64"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
@@ -33,6 +34,23 @@ type ecrAuthProvider struct {
 
 const proxyEndpointScheme = "https://"
 
+// ecrImagePattern matches the images of ECR repositories, capturing the id of
+// the registry and the region it's in
+var ecrImagePattern = regexp.MustCompile(`^([0-9]{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?/`)
+
+// ECRAuthDataFromImage returns the registry id and region of the ECR
+// repository of the image, or false if the image isn't in an ECR repository
+func ECRAuthDataFromImage(image string) (*api.ECRAuthData, bool) {
+	matches := ecrImagePattern.FindStringSubmatch(image)
+	if matches == nil {
+		return nil, false
+	}
+	return &api.ECRAuthData{
+		RegistryId: matches[1],
+		Region:     matches[2],
+	}, true
+}
+
 // NewECRAuthProvider returns a DockerAuthProvider that can handle retrieve
 // credentials for pulling from Amazon EC2 Container Registry
 func NewECRAuthProvider(authData *api.ECRAuthData, clientFactory ecr.ECRFactory) DockerAuthProvider {
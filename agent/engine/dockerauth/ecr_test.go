@@ -245,3 +245,32 @@ func TestGetAuthConfigNoAuthData(t *testing.T) {
 		t.Fatalf("Expected Authconfig to be empty, but was %v", authconfig)
 	}
 }
+
+func TestECRAuthDataFromImage(t *testing.T) {
+	testCases := []struct {
+		image      string
+		registryId string
+		region     string
+		isECR      bool
+	}{
+		{"123456789012.dkr.ecr.us-west-2.amazonaws.com/myimage:tag", "123456789012", "us-west-2", true},
+		{"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn/team/myimage", "123456789012", "cn-north-1", true},
+		{"busybox:latest", "", "", false},
+		{"registry.example.com/myimage", "", "", false},
+		{"12345.dkr.ecr.us-west-2.amazonaws.com/myimage", "", "", false},
+		{"123456789012.dkr.ecr.us-west-2.amazonaws.com.example.com/myimage", "", "", false},
+	}
+	for _, tc := range testCases {
+		authData, ok := ECRAuthDataFromImage(tc.image)
+		if ok != tc.isECR {
+			t.Errorf("Expected %s to be an ECR image: %v", tc.image, tc.isECR)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if authData.RegistryId != tc.registryId || authData.Region != tc.region {
+			t.Errorf("Wrong registry for %s: %s in %s", tc.image, authData.RegistryId, authData.Region)
+		}
+	}
+}
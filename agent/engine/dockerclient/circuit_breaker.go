@@ -0,0 +1,207 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerclient
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	log "github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultCircuitBreakerThreshold is the number of consecutive failures of
+	// an operation after which its calls are short-circuited
+	DefaultCircuitBreakerThreshold = 5
+	// DefaultCircuitBreakerCoolDown is how long calls of an operation are
+	// short-circuited before one is let through to test whether the daemon
+	// has recovered
+	DefaultCircuitBreakerCoolDown = 15 * time.Second
+)
+
+// WithCircuitBreaker makes the clients returned by the factory short-circuit
+// the idempotent reads of an operation, such as inspecting containers, with a
+// *CircuitOpenError for coolDown once threshold consecutive calls of it failed.
+// After the cool-down a single call is let through: calls are made again if it
+// succeeds, or short-circuited for another cool-down if it fails. Other calls
+// always reach the daemon
+func WithCircuitBreaker(threshold int, coolDown time.Duration) FactoryOption {
+	return func(f *factory) {
+		f.breaker = newCircuitBreaker(threshold, coolDown)
+	}
+}
+
+// circuit is the state of the calls of one operation. It is closed while
+// failures is below the threshold, and open or half-open otherwise
+type circuit struct {
+	failures int
+	// openUntil is when calls may be tried again once the circuit is open
+	openUntil time.Time
+	// trial is set while the call testing a half-open circuit is in flight
+	trial bool
+}
+
+// circuitBreaker keeps track of the circuits of the operations of all the
+// clients of a factory, so that they survive reconnecting to the daemon
+type circuitBreaker struct {
+	threshold int
+	coolDown  time.Duration
+	// now is a variable such that time can be controlled by unit tests
+	now func() time.Time
+
+	lock     sync.Mutex
+	circuits map[string]*circuit
+}
+
+func newCircuitBreaker(threshold int, coolDown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		coolDown:  coolDown,
+		now:       time.Now,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// call calls fn unless the circuit of the operation is open, and records how
+// the call went
+func (breaker *circuitBreaker) call(operation string, fn func() error) error {
+	trial, err := breaker.allow(operation)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	breaker.record(operation, trial, err)
+	return err
+}
+
+// allow returns an error if calls of the operation are short-circuited, and
+// whether the call allowed is the trial of a half-open circuit
+func (breaker *circuitBreaker) allow(operation string) (bool, error) {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+
+	c, ok := breaker.circuits[operation]
+	if !ok || c.failures < breaker.threshold {
+		return false, nil
+	}
+	if c.trial || breaker.now().Before(c.openUntil) {
+		return false, &CircuitOpenError{Operation: operation, Until: c.openUntil}
+	}
+	c.trial = true
+	return true, nil
+}
+
+func (breaker *circuitBreaker) record(operation string, trial bool, err error) {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+
+	c, ok := breaker.circuits[operation]
+	if !ok {
+		c = &circuit{}
+		breaker.circuits[operation] = c
+	}
+	if trial {
+		c.trial = false
+	}
+	switch {
+	case err == context.Canceled:
+		// The caller gave up, which says nothing about the daemon
+	case isDaemonFailure(err):
+		c.failures++
+		if trial || c.failures == breaker.threshold {
+			c.openUntil = breaker.now().Add(breaker.coolDown)
+			log.Warnf("Docker calls to %s failed %d times in a row, short-circuiting them for %s: %v", operation, c.failures, breaker.coolDown, err)
+		}
+	default:
+		if c.failures >= breaker.threshold {
+			log.Infof("Docker calls to %s succeeded again, no longer short-circuiting them", operation)
+		}
+		c.failures = 0
+	}
+}
+
+// isDaemonFailure returns whether err means the daemon couldn't serve the
+// call, rather than answering it, such as with what was asked for not existing
+func isDaemonFailure(err error) bool {
+	if err == nil || err == docker.ErrNoSuchImage {
+		return false
+	}
+	switch err := err.(type) {
+	case *docker.NoSuchContainer:
+		return false
+	case *docker.Error:
+		return err.Status >= 500
+	}
+	return true
+}
+
+// circuitBreakerClient calls the idempotent reads of the client through the
+// circuit breaker
+type circuitBreakerClient struct {
+	dockeriface.Client
+	breaker *circuitBreaker
+}
+
+func (client *circuitBreakerClient) InspectContainer(id string) (*docker.Container, error) {
+	var container *docker.Container
+	err := client.breaker.call("InspectContainer", func() error {
+		var err error
+		container, err = client.Client.InspectContainer(id)
+		return err
+	})
+	return container, err
+}
+
+func (client *circuitBreakerClient) InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error) {
+	var container *docker.Container
+	err := client.breaker.call("InspectContainer", func() error {
+		var err error
+		container, err = client.Client.InspectContainerWithContext(id, ctx)
+		return err
+	})
+	return container, err
+}
+
+func (client *circuitBreakerClient) InspectContainerHealth(id string, ctx context.Context) (*dockeriface.Health, error) {
+	var health *dockeriface.Health
+	err := client.breaker.call("InspectContainer", func() error {
+		var err error
+		health, err = client.Client.InspectContainerHealth(id, ctx)
+		return err
+	})
+	return health, err
+}
+
+func (client *circuitBreakerClient) InspectImage(name string) (*docker.Image, error) {
+	var image *docker.Image
+	err := client.breaker.call("InspectImage", func() error {
+		var err error
+		image, err = client.Client.InspectImage(name)
+		return err
+	})
+	return image, err
+}
+
+func (client *circuitBreakerClient) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	var containers []docker.APIContainers
+	err := client.breaker.call("ListContainers", func() error {
+		var err error
+		containers, err = client.Client.ListContainers(opts)
+		return err
+	})
+	return containers, err
+}
@@ -0,0 +1,202 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface/mocks"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"golang.org/x/net/context"
+)
+
+// fakeClient answers inspections with err, counting the calls that reach it.
+// Calls of the operations that aren't faked panic
+type fakeClient struct {
+	dockeriface.Client
+	calls int
+	err   error
+}
+
+func (client *fakeClient) InspectContainer(id string) (*docker.Container, error) {
+	client.calls++
+	if client.err != nil {
+		return nil, client.err
+	}
+	return &docker.Container{ID: id}, nil
+}
+
+func (client *fakeClient) InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error) {
+	return client.InspectContainer(id)
+}
+
+func (client *fakeClient) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	client.calls++
+	return nil, client.err
+}
+
+// breakerClient returns a client calling fake through a circuit breaker of
+// threshold 3 and a minute of cool-down, and a function advancing its clock
+func breakerClient(fake *fakeClient) (dockeriface.Client, func(time.Duration)) {
+	breaker := newCircuitBreaker(3, time.Minute)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	return &circuitBreakerClient{Client: fake, breaker: breaker}, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	fake := &fakeClient{err: docker.ErrConnectionRefused}
+	client, _ := breakerClient(fake)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.InspectContainer("id"); err != docker.ErrConnectionRefused {
+			t.Errorf("Expected the error of the daemon before the circuit opens, got: %v", err)
+		}
+	}
+	_, err := client.InspectContainerWithContext("id", context.Background())
+	if _, ok := err.(*CircuitOpenError); !ok {
+		t.Errorf("Expected CircuitOpenError, got: %v", err)
+	}
+	if fake.calls != 3 {
+		t.Errorf("Expected calls to be short-circuited once the circuit opens, got %d calls", fake.calls)
+	}
+
+	// Circuits are kept by operation
+	if _, err := client.ListContainers(docker.ListContainersOptions{}); err != docker.ErrConnectionRefused {
+		t.Errorf("Expected listing containers not to be short-circuited, got: %v", err)
+	}
+}
+
+func TestCircuitBreakerStaysClosed(t *testing.T) {
+	fake := &fakeClient{}
+	client, _ := breakerClient(fake)
+
+	failures := []error{
+		docker.ErrConnectionRefused,
+		docker.ErrConnectionRefused,
+		nil, // A success resets the count of failures
+		docker.ErrConnectionRefused,
+		&docker.NoSuchContainer{ID: "id"},
+		&docker.Error{Status: 409},
+		context.Canceled,
+		docker.ErrConnectionRefused,
+	}
+	for _, failure := range failures {
+		fake.err = failure
+		client.InspectContainer("id")
+	}
+	fake.err = nil
+	if _, err := client.InspectContainer("id"); err != nil {
+		t.Errorf("Expected the circuit to stay closed, got: %v", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	fake := &fakeClient{err: &docker.Error{Status: 500}}
+	client, advance := breakerClient(fake)
+	for i := 0; i < 3; i++ {
+		client.InspectContainer("id")
+	}
+
+	// A failed trial opens the circuit for another cool-down
+	advance(time.Minute)
+	if _, err := client.InspectContainer("id"); err != fake.err {
+		t.Errorf("Expected a trial call once the cool-down is over, got: %v", err)
+	}
+	if _, err := client.InspectContainer("id"); err == nil || err == fake.err {
+		t.Errorf("Expected the circuit to open again, got: %v", err)
+	}
+	advance(time.Minute - time.Second)
+	if _, err := client.InspectContainer("id"); err == nil || err == fake.err {
+		t.Errorf("Expected the circuit to stay open for the cool-down, got: %v", err)
+	}
+	if fake.calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", fake.calls)
+	}
+
+	// A successful trial closes it
+	advance(time.Second)
+	fake.err = nil
+	if _, err := client.InspectContainer("id"); err != nil {
+		t.Errorf("Expected the trial to succeed, got: %v", err)
+	}
+	fake.err = errors.New("timeout")
+	for i := 0; i < 3; i++ {
+		if _, err := client.InspectContainer("id"); err != fake.err {
+			t.Errorf("Expected the circuit to be closed, got: %v", err)
+		}
+	}
+}
+
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	breaker := newCircuitBreaker(1, time.Minute)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	breaker.call("InspectImage", func() error { return docker.ErrConnectionRefused })
+	now = now.Add(time.Minute)
+
+	trialCalled := false
+	breaker.call("InspectImage", func() error {
+		trialCalled = true
+		// Calls made while the trial is in flight are short-circuited
+		err := breaker.call("InspectImage", func() error {
+			t.Error("Unexpected call while the trial is in flight")
+			return nil
+		})
+		if _, ok := err.(*CircuitOpenError); !ok {
+			t.Errorf("Expected CircuitOpenError, got: %v", err)
+		}
+		return nil
+	})
+	if !trialCalled {
+		t.Error("Expected a trial call")
+	}
+}
+
+func TestFactoryWithCircuitBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	mockClient.EXPECT().Ping()
+	mockClient.EXPECT().InspectImage("image").Return(nil, docker.ErrConnectionRefused)
+	mockClient.EXPECT().StopContainer("id", uint(10)).Return(docker.ErrConnectionRefused).Times(2)
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		return mockClient, nil
+	}
+
+	factory := NewFactory("endpoint", WithCircuitBreaker(1, time.Minute))
+	client, err := factory.GetClient(Version_1_18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.InspectImage("image")
+	if _, err := client.InspectImage("image"); !isCircuitOpen(err, "InspectImage") {
+		t.Errorf("Expected inspecting images to be short-circuited, got: %v", err)
+	}
+
+	// Operations that aren't idempotent reads aren't short-circuited
+	client.StopContainer("id", 10)
+	client.StopContainer("id", 10)
+}
+
+func isCircuitOpen(err error, operation string) bool {
+	openErr, ok := err.(*CircuitOpenError)
+	return ok && openErr.Operation == operation
+}
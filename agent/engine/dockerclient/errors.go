@@ -0,0 +1,136 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// ClientTimeoutError is returned when the caller's context is done before a
+// client could be connected and pinged. It is distinct from errors returned
+// by the daemon (e.g. docker.ErrConnectionRefused), which mean the daemon was
+// reached and answered, so callers may choose to retry these differently.
+type ClientTimeoutError struct {
+	Version DockerVersion
+	// Operation is the step that timed out, either "connect" or "ping"
+	Operation string
+	Err       error
+}
+
+func (err *ClientTimeoutError) Error() string {
+	return "Timed out waiting to " + err.Operation + " to docker (v" + string(err.Version) + "): " + err.Err.Error()
+}
+
+func (err *ClientTimeoutError) Unwrap() error {
+	return err.Err
+}
+
+func (err *ClientTimeoutError) ErrorName() string {
+	return "ClientTimeoutError"
+}
+
+// CircuitOpenError is returned instead of calling the daemon while calls of the
+// operation are short-circuited because they kept failing
+type CircuitOpenError struct {
+	Operation string
+	// Until is when a call of the operation will be tried again
+	Until time.Time
+}
+
+func (err *CircuitOpenError) Error() string {
+	return "Not calling docker to " + err.Operation + " until " + err.Until.Format(time.RFC3339) + ": recent calls kept failing"
+}
+
+func (err *CircuitOpenError) ErrorName() string {
+	return "CircuitOpenError"
+}
+
+// ClientErrorCategory classifies why a client could not be acquired
+type ClientErrorCategory int
+
+const (
+	// ClientErrorUnknown is used for failures that fit no other category
+	ClientErrorUnknown ClientErrorCategory = iota
+	// ClientErrorVersionUnsupported means the daemon rejected the version. This
+	// is permanent for a given daemon, so retrying the same version won't help
+	ClientErrorVersionUnsupported
+	// ClientErrorDaemonUnreachable means the daemon could not be reached, such
+	// as when it is restarting. This is usually transient
+	ClientErrorDaemonUnreachable
+)
+
+func (category ClientErrorCategory) String() string {
+	switch category {
+	case ClientErrorVersionUnsupported:
+		return "version unsupported"
+	case ClientErrorDaemonUnreachable:
+		return "daemon unreachable"
+	}
+	return "unknown"
+}
+
+// ClientError is returned when a client for a version could not be connected
+// or pinged. It wraps the underlying error from go-dockerclient.
+type ClientError struct {
+	Version  DockerVersion
+	Category ClientErrorCategory
+	Err      error
+}
+
+func (err *ClientError) Error() string {
+	return "Could not get docker client (v" + string(err.Version) + ", " + err.Category.String() + "): " + err.Err.Error()
+}
+
+func (err *ClientError) Unwrap() error {
+	return err.Err
+}
+
+func (err *ClientError) ErrorName() string {
+	return "ClientError"
+}
+
+// Retriable returns true if the failure is likely to be transient
+func (err *ClientError) Retriable() bool {
+	return err.Category != ClientErrorVersionUnsupported
+}
+
+// newClientError wraps err in a *ClientError, categorizing it
+func newClientError(version DockerVersion, err error) *ClientError {
+	return &ClientError{
+		Version:  version,
+		Category: categorizeClientError(err),
+		Err:      err,
+	}
+}
+
+func categorizeClientError(err error) ClientErrorCategory {
+	if err == docker.ErrConnectionRefused {
+		return ClientErrorDaemonUnreachable
+	}
+	switch typedErr := err.(type) {
+	case *docker.Error:
+		// Daemons older than 1.13 return 404 for a version newer than they
+		// support, and newer daemons return 400
+		if typedErr.Status == http.StatusBadRequest || typedErr.Status == http.StatusNotFound {
+			return ClientErrorVersionUnsupported
+		}
+	case net.Error:
+		return ClientErrorDaemonUnreachable
+	}
+	return ClientErrorUnknown
+}
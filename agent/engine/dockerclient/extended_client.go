@@ -0,0 +1,196 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	docker "github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// extendedClient is a go-dockerclient client that makes the calls whose
+// requests or responses have fields the vendored go-dockerclient doesn't have
+// itself, over the same connection to the daemon
+type extendedClient struct {
+	*docker.Client
+	version    string
+	httpClient *http.Client
+	// baseURL is the url the paths of the remote api are relative to
+	baseURL string
+}
+
+// newExtendedClient returns a client extending the go-dockerclient client,
+// which uses the remote api version
+func newExtendedClient(client *docker.Client, version string) (*extendedClient, error) {
+	endpoint, err := url.Parse(client.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+	extended := &extendedClient{Client: client, version: version}
+	switch endpoint.Scheme {
+	case "unix", "npipe":
+		// The client dials the socket, or the named pipe on Windows, whatever
+		// the address of the request
+		socket := endpoint.Path
+		extended.httpClient = &http.Client{Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return client.Dialer.Dial("unix", socket)
+			},
+		}}
+		extended.baseURL = "http://unix.sock"
+	case "https":
+		extended.httpClient = client.HTTPClient
+		extended.baseURL = "https://" + endpoint.Host
+	default:
+		extended.httpClient = client.HTTPClient
+		extended.baseURL = "http://" + endpoint.Host
+	}
+	return extended, nil
+}
+
+// CreateContainerWithHostConfig creates a container as go-dockerclient's
+// CreateContainer does, with hostConfig in place of the host config of opts
+func (client *extendedClient) CreateContainerWithHostConfig(opts docker.CreateContainerOptions, hostConfig *dockeriface.HostConfig) (*docker.Container, error) {
+	path := "/containers/create"
+	if opts.Name != "" {
+		path += "?name=" + url.QueryEscape(opts.Name)
+	}
+	request := struct {
+		*docker.Config
+		HostConfig       *dockeriface.HostConfig  `json:"HostConfig,omitempty"`
+		NetworkingConfig *docker.NetworkingConfig `json:"NetworkingConfig,omitempty"`
+	}{opts.Config, hostConfig, opts.NetworkingConfig}
+
+	resp, err := client.do(opts.Context, "POST", path, request)
+	if e, ok := err.(*docker.Error); ok {
+		switch e.Status {
+		case http.StatusNotFound:
+			return nil, docker.ErrNoSuchImage
+		case http.StatusConflict:
+			return nil, docker.ErrContainerAlreadyExists
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var container docker.Container
+	if err := json.NewDecoder(resp.Body).Decode(&container); err != nil {
+		return nil, err
+	}
+	container.Name = opts.Name
+	return &container, nil
+}
+
+// Info returns the system-wide information of the daemon as go-dockerclient's
+// Info does, along with the fields it doesn't decode
+func (client *extendedClient) Info() (*dockeriface.Info, error) {
+	resp, err := client.do(context.Background(), "GET", "/info", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	info := &dockeriface.Info{DockerInfo: &docker.DockerInfo{}}
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// InspectContainerHealth returns the health of a container with a health
+// check, from the state docker reports when inspecting it
+func (client *extendedClient) InspectContainerHealth(id string, ctx context.Context) (*dockeriface.Health, error) {
+	resp, err := client.do(ctx, "GET", "/containers/"+id+"/json", nil)
+	if e, ok := err.(*docker.Error); ok && e.Status == http.StatusNotFound {
+		return nil, &docker.NoSuchContainer{ID: id}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var container struct {
+		State struct {
+			Health *dockeriface.Health
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&container); err != nil {
+		return nil, err
+	}
+	if container.State.Health == nil {
+		return nil, fmt.Errorf("container %s has no health", id)
+	}
+	return container.State.Health, nil
+}
+
+// do sends a request to the remote api, with data encoded as json as its body
+// if set. Responses with an error status are returned as a *docker.Error
+func (client *extendedClient) do(ctx context.Context, method, path string, data interface{}) (*http.Response, error) {
+	var body bytes.Buffer
+	if data != nil {
+		if err := json.NewEncoder(&body).Encode(data); err != nil {
+			return nil, err
+		}
+	}
+	if client.version != "" {
+		path = "/v" + client.version + path
+	}
+	req, err := http.NewRequest(method, client.baseURL+path, &body)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	resp, err := ctxhttp.Do(ctx, client.httpClient, req)
+	if err != nil {
+		if strings.Contains(err.Error(), "connection refused") {
+			return nil, docker.ErrConnectionRefused
+		}
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
+	return resp, nil
+}
+
+// apiError returns the error of a response with an error status, with the
+// message docker sent in its body
+func apiError(resp *http.Response) *docker.Error {
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &docker.Error{Status: resp.StatusCode, Message: "Unable to read the response: " + err.Error()}
+	}
+	var message struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &message) == nil && message.Message != "" {
+		return &docker.Error{Status: resp.StatusCode, Message: message.Message}
+	}
+	return &docker.Error{Status: resp.StatusCode, Message: string(data)}
+}
@@ -0,0 +1,155 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// extendedTestClient returns an extended client of the given version talking
+// to a server handling requests with handler, and a function stopping it
+func extendedTestClient(t *testing.T, version string, handler http.HandlerFunc) (*extendedClient, func()) {
+	server := httptest.NewServer(handler)
+	client, err := docker.NewVersionedClient(server.URL, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extended, err := newExtendedClient(client, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return extended, server.Close
+}
+
+func TestCreateContainerWithHostConfig(t *testing.T) {
+	client, done := extendedTestClient(t, "1.25", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/v1.25/containers/create", r.URL.Path)
+		assert.Equal(t, "name", r.URL.Query().Get("name"))
+		var request struct {
+			Image      string
+			HostConfig map[string]interface{}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		assert.Equal(t, "image", request.Image)
+		assert.Equal(t, true, request.HostConfig["Init"], "Expected an init process")
+		assert.Equal(t, "host", request.HostConfig["NetworkMode"])
+		// docker expects the rates of block limits as numbers
+		assert.Equal(t, []interface{}{map[string]interface{}{"Path": "/dev/xvda", "Rate": float64(1048576)}}, request.HostConfig["BlkioDeviceReadBps"])
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"id","Warnings":[]}`))
+	})
+	defer done()
+
+	hostConfig := &dockeriface.HostConfig{
+		HostConfig:         &docker.HostConfig{NetworkMode: "host"},
+		Init:               true,
+		BlkioDeviceReadBps: []dockeriface.BlockLimit{{Path: "/dev/xvda", Rate: 1048576}},
+	}
+	container, err := client.CreateContainerWithHostConfig(docker.CreateContainerOptions{
+		Name:   "name",
+		Config: &docker.Config{Image: "image"},
+	}, hostConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "id", container.ID)
+	assert.Equal(t, "name", container.Name)
+}
+
+func TestCreateContainerWithHostConfigErrors(t *testing.T) {
+	testCases := []struct {
+		status   int
+		expected error
+	}{
+		{http.StatusNotFound, docker.ErrNoSuchImage},
+		{http.StatusConflict, docker.ErrContainerAlreadyExists},
+	}
+	for _, tc := range testCases {
+		client, done := extendedTestClient(t, "1.25", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		})
+		_, err := client.CreateContainerWithHostConfig(docker.CreateContainerOptions{Config: &docker.Config{}}, nil)
+		assert.Equal(t, tc.expected, err)
+		done()
+	}
+
+	client, done := extendedTestClient(t, "1.25", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"invalid host config"}`))
+	})
+	defer done()
+	_, err := client.CreateContainerWithHostConfig(docker.CreateContainerOptions{Config: &docker.Config{}}, nil)
+	if assert.IsType(t, &docker.Error{}, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*docker.Error).Status)
+		assert.Equal(t, "invalid host config", err.(*docker.Error).Message)
+	}
+}
+
+func TestInfoLogPlugins(t *testing.T) {
+	client, done := extendedTestClient(t, "1.25", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/v1.25/info", r.URL.Path)
+		w.Write([]byte(`{"Driver":"overlay","LoggingDriver":"json-file","Plugins":{"Volume":["local"],"Log":["awslogs","json-file"]}}`))
+	})
+	defer done()
+
+	info, err := client.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "overlay", info.Driver)
+	assert.Equal(t, "json-file", info.LoggingDriver)
+	assert.Equal(t, []string{"local"}, info.Plugins.Volume)
+	assert.Equal(t, []string{"awslogs", "json-file"}, info.Plugins.Log)
+}
+
+func TestInspectContainerHealth(t *testing.T) {
+	client, done := extendedTestClient(t, "1.25", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		switch r.URL.Path {
+		case "/v1.25/containers/healthy/json":
+			w.Write([]byte(`{"Id":"healthy","State":{"Running":true,"Health":{"Status":"unhealthy","FailingStreak":2}}}`))
+		case "/v1.25/containers/nohealthcheck/json":
+			w.Write([]byte(`{"Id":"nohealthcheck","State":{"Running":true}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer done()
+
+	health, err := client.InspectContainerHealth("healthy", context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Equal(t, 2, health.FailingStreak)
+
+	_, err = client.InspectContainerHealth("nohealthcheck", context.TODO())
+	assert.Error(t, err, "Expected an error for a container without a health check")
+
+	_, err = client.InspectContainerHealth("missing", context.TODO())
+	assert.IsType(t, &docker.NoSuchContainer{}, err)
+}
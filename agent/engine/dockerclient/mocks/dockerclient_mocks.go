@@ -20,6 +20,8 @@ import (
 	dockerclient "github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
 	dockeriface "github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	gomock "github.com/golang/mock/gomock"
+	context "golang.org/x/net/context"
+	time "time"
 )
 
 // Mock of Factory interface
@@ -53,6 +55,16 @@ func (_mr *_MockFactoryRecorder) FindAvailableVersions() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindAvailableVersions")
 }
 
+func (_m *MockFactory) FindAvailableVersionsWithContext(_param0 context.Context, _param1 time.Duration) []dockerclient.DockerVersion {
+	ret := _m.ctrl.Call(_m, "FindAvailableVersionsWithContext", _param0, _param1)
+	ret0, _ := ret[0].([]dockerclient.DockerVersion)
+	return ret0
+}
+
+func (_mr *_MockFactoryRecorder) FindAvailableVersionsWithContext(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindAvailableVersionsWithContext", arg0, arg1)
+}
+
 func (_m *MockFactory) GetClient(_param0 dockerclient.DockerVersion) (dockeriface.Client, error) {
 	ret := _m.ctrl.Call(_m, "GetClient", _param0)
 	ret0, _ := ret[0].(dockeriface.Client)
@@ -64,6 +76,17 @@ func (_mr *_MockFactoryRecorder) GetClient(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetClient", arg0)
 }
 
+func (_m *MockFactory) GetClientWithContext(_param0 context.Context, _param1 dockerclient.DockerVersion) (dockeriface.Client, error) {
+	ret := _m.ctrl.Call(_m, "GetClientWithContext", _param0, _param1)
+	ret0, _ := ret[0].(dockeriface.Client)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockFactoryRecorder) GetClientWithContext(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetClientWithContext", arg0, arg1)
+}
+
 func (_m *MockFactory) GetDefaultClient() (dockeriface.Client, error) {
 	ret := _m.ctrl.Call(_m, "GetDefaultClient")
 	ret0, _ := ret[0].(dockeriface.Client)
@@ -74,3 +97,32 @@ func (_m *MockFactory) GetDefaultClient() (dockeriface.Client, error) {
 func (_mr *_MockFactoryRecorder) GetDefaultClient() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetDefaultClient")
 }
+
+func (_m *MockFactory) GetDefaultClientWithContext(_param0 context.Context) (dockeriface.Client, error) {
+	ret := _m.ctrl.Call(_m, "GetDefaultClientWithContext", _param0)
+	ret0, _ := ret[0].(dockeriface.Client)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockFactoryRecorder) GetDefaultClientWithContext(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetDefaultClientWithContext", arg0)
+}
+
+func (_m *MockFactory) GetDefaultVersion() dockerclient.DockerVersion {
+	ret := _m.ctrl.Call(_m, "GetDefaultVersion")
+	ret0, _ := ret[0].(dockerclient.DockerVersion)
+	return ret0
+}
+
+func (_mr *_MockFactoryRecorder) GetDefaultVersion() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetDefaultVersion")
+}
+
+func (_m *MockFactory) InvalidateClient(_param0 dockerclient.DockerVersion) {
+	_m.ctrl.Call(_m, "InvalidateClient", _param0)
+}
+
+func (_mr *_MockFactoryRecorder) InvalidateClient(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "InvalidateClient", arg0)
+}
@@ -14,12 +14,14 @@
 package dockerclient
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	log "github.com/cihub/seelog"
 	docker "github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/context"
 )
 
 type DockerVersion string
@@ -33,12 +35,53 @@ const (
 	Version_1_22 DockerVersion = "1.22"
 	Version_1_23 DockerVersion = "1.23"
 	Version_1_24 DockerVersion = "1.24"
+	Version_1_25 DockerVersion = "1.25"
+	Version_1_26 DockerVersion = "1.26"
+	Version_1_27 DockerVersion = "1.27"
+	Version_1_28 DockerVersion = "1.28"
+	Version_1_29 DockerVersion = "1.29"
+	Version_1_30 DockerVersion = "1.30"
 
-	defaultVersion = Version_1_24
+	defaultVersion = Version_1_30
 )
 
+// AtLeast returns true if the version is the same as or later than other.
+// Versions that can't be parsed are not at least any version
+func (version DockerVersion) AtLeast(other DockerVersion) bool {
+	var major, minor, otherMajor, otherMinor int
+	if _, err := fmt.Sscanf(string(version), "%d.%d", &major, &minor); err != nil {
+		return false
+	}
+	if _, err := fmt.Sscanf(string(other), "%d.%d", &otherMajor, &otherMinor); err != nil {
+		return false
+	}
+	return major > otherMajor || (major == otherMajor && minor >= otherMinor)
+}
+
+// defaultVersionProbeTimeout bounds each attempt to connect with
+// defaultVersion before it has been negotiated, and each probe of the fallback
+// search, so that a daemon that doesn't answer doesn't stall an attempt. It is
+// a variable such that it can be shortened for unit tests
+var defaultVersionProbeTimeout = 30 * time.Second
+
+// maxConnectionAttempts is how many times connecting to the daemon is attempted
+// before giving up
+const maxConnectionAttempts = 10
+
+// connectionRetryBackoff is multiplied by the number of the failed attempt to
+// get how long to wait before the next one. It is a variable such that it can
+// be shortened for unit tests
+var connectionRetryBackoff = 5 * time.Second
+
 var supportedVersions []DockerVersion
 
+// SupportedVersions returns the versions the agent supports, in ascending order
+func SupportedVersions() []DockerVersion {
+	versions := make([]DockerVersion, len(supportedVersions))
+	copy(versions, supportedVersions)
+	return versions
+}
+
 func init() {
 	supportedVersions = []DockerVersion{
 		Version_1_17,
@@ -49,6 +92,12 @@ func init() {
 		Version_1_22,
 		Version_1_23,
 		Version_1_24,
+		Version_1_25,
+		Version_1_26,
+		Version_1_27,
+		Version_1_28,
+		Version_1_29,
+		Version_1_30,
 	}
 }
 
@@ -56,18 +105,67 @@ type Factory interface {
 	// GetDefaultClient returns a versioned client for the default version
 	GetDefaultClient() (dockeriface.Client, error)
 
+	// GetDefaultClientWithContext returns a versioned client for the default
+	// version, giving up once the context is done
+	GetDefaultClientWithContext(ctx context.Context) (dockeriface.Client, error)
+
+	// GetDefaultVersion returns the version backing GetDefaultClient. This is
+	// the highest version found by FindAvailableVersions, or the compile-time
+	// default if no versions have been found yet
+	GetDefaultVersion() DockerVersion
+
 	// GetClient returns a client with the specified version
 	GetClient(version DockerVersion) (dockeriface.Client, error)
 
+	// GetClientWithContext returns a client with the specified version,
+	// giving up once the context is done. If the context expires while
+	// connecting to or pinging the daemon, a *ClientTimeoutError is returned
+	GetClientWithContext(ctx context.Context, version DockerVersion) (dockeriface.Client, error)
+
+	// InvalidateClient removes the cached client for the version, if any, so
+	// that the next call for that version dials the daemon again
+	InvalidateClient(version DockerVersion)
+
 	// FindAvailableVersions tests each supported version and returns a slice
 	// of available versions
 	FindAvailableVersions() []DockerVersion
+
+	// FindAvailableVersionsWithContext tests each supported version, bounding
+	// each test by perVersionTimeout, and returns a slice of available versions
+	FindAvailableVersionsWithContext(ctx context.Context, perVersionTimeout time.Duration) []DockerVersion
 }
 
 type factory struct {
 	endpoint string
-	lock     sync.Mutex
-	clients  map[DockerVersion]dockeriface.Client
+	// lock guards clients
+	lock    sync.Mutex
+	clients map[DockerVersion]dockeriface.Client
+
+	// negotiatedVersion is the highest version found by
+	// FindAvailableVersions
+	negotiatedVersion     DockerVersion
+	negotiatedVersionLock sync.RWMutex
+
+	// pingCachedClients is set by WithCachedClientPing
+	pingCachedClients bool
+	// versionChangeHandler is set by WithVersionChangeHandler
+	versionChangeHandler func(oldVersion, newVersion DockerVersion)
+	// minimumVersion is set by WithMinimumVersion
+	minimumVersion DockerVersion
+	// breaker is set by WithCircuitBreaker
+	breaker *circuitBreaker
+}
+
+// FactoryOption configures optional behavior of a factory created by NewFactory
+type FactoryOption func(*factory)
+
+// WithCachedClientPing makes the factory ping a cached client before returning
+// it, and dial a new one if the ping fails. This replaces connections that
+// went stale when the daemon restarted, at the cost of an extra ping per call
+func WithCachedClientPing() FactoryOption {
+	return func(f *factory) {
+		f.pingCachedClients = true
+	}
 }
 
 // newVersionedClient is a variable such that the implementation can be
@@ -77,93 +175,387 @@ var newVersionedClient = func(endpoint, version string) (dockeriface.Client, err
 	cl, err := docker.NewVersionedClient(endpoint, version)
 	if err != nil {
 		log.Errorf("Error connecting to client version %s at %s: %s", version, endpoint, err.Error())
+		return nil, err
 	}
-	return cl, err
+	return newExtendedClient(cl, version)
 }
 
-func NewFactory(endpoint string) Factory {
+// WithVersionChangeHandler sets a handler that is called when
+// FindAvailableVersions detects a different highest version than the previous
+// detection did, such as after the daemon was downgraded. The handler is not
+// called for the first detection
+func WithVersionChangeHandler(handler func(oldVersion, newVersion DockerVersion)) FactoryOption {
+	return func(f *factory) {
+		f.versionChangeHandler = handler
+	}
+}
+
+// WithMinimumVersion sets the lowest version the factory will use. Versions
+// below it are not considered by FindAvailableVersions, and GetDefaultClient
+// returns an error rather than falling back below it. An empty version sets
+// no minimum
+func WithMinimumVersion(version DockerVersion) FactoryOption {
+	return func(f *factory) {
+		f.minimumVersion = version
+	}
+}
+
+func NewFactory(endpoint string, options ...FactoryOption) Factory {
 	log.Debugf("Constructing new factory with endpoint %s", endpoint)
 
-	return &factory{
+	f := &factory{
 		endpoint: endpoint,
 		clients:  make(map[DockerVersion]dockeriface.Client),
 	}
+	for _, option := range options {
+		option(f)
+	}
+	return f
 }
 
 func (f *factory) GetDefaultClient() (dockeriface.Client, error) {
+	return f.GetDefaultClientWithContext(context.Background())
+}
+
+func (f *factory) GetDefaultClientWithContext(ctx context.Context) (dockeriface.Client, error) {
+	version, negotiated := f.getNegotiatedVersion()
+	if negotiated {
+		log.Debugf("Getting default client (%s) from factory", version)
+		return f.GetClientWithContext(ctx, version)
+	}
+
+	if len(f.candidateVersions()) == 0 {
+		return nil, &ClientError{
+			Version:  f.minimumVersion,
+			Category: ClientErrorVersionUnsupported,
+			Err:      fmt.Errorf("no supported Docker API version meets the minimum version %s", f.minimumVersion),
+		}
+	}
+
 	log.Debugf("Getting default client (%s) from factory", defaultVersion)
+	client, err := f.getClient(ctx, defaultVersion, defaultVersionProbeTimeout)
+	if err == nil || ctx.Err() != nil {
+		return client, err
+	}
 
-	return f.GetClient(defaultVersion)
+	// The daemon may be older than defaultVersion; fall back to the highest
+	// version it does support
+	log.Warnf("Unable to get default client (%s), looking for an older version: %v", defaultVersion, err)
+	if len(f.FindAvailableVersionsWithContext(ctx, defaultVersionProbeTimeout)) == 0 {
+		if f.minimumVersion != "" {
+			return nil, &ClientError{
+				Version:  f.minimumVersion,
+				Category: ClientErrorVersionUnsupported,
+				Err:      fmt.Errorf("no Docker API version at or above the minimum version %s is available: %v", f.minimumVersion, err),
+			}
+		}
+		return nil, err
+	}
+	version, _ = f.getNegotiatedVersion()
+	log.Infof("Falling back to docker client version %s", version)
+	return f.GetClientWithContext(ctx, version)
+}
+
+func (f *factory) GetDefaultVersion() DockerVersion {
+	version, negotiated := f.getNegotiatedVersion()
+	if !negotiated {
+		return defaultVersion
+	}
+	return version
+}
+
+// getNegotiatedVersion returns the version recorded by FindAvailableVersions
+// and whether one has been recorded
+func (f *factory) getNegotiatedVersion() (DockerVersion, bool) {
+	f.negotiatedVersionLock.RLock()
+	defer f.negotiatedVersionLock.RUnlock()
+
+	return f.negotiatedVersion, f.negotiatedVersion != ""
 }
 
 func (f *factory) GetClient(version DockerVersion) (dockeriface.Client, error) {
-	log.Debugf("Getting specific client (%s) from factory", version)
+	return f.GetClientWithContext(context.Background(), version)
+}
 
-	client, ok := f.clients[version]
-	if ok {
-		log.Debugf("Returning cached client (%s) before lock", version)
-		return client, nil
-	}
+func (f *factory) GetClientWithContext(ctx context.Context, version DockerVersion) (dockeriface.Client, error) {
+	return f.getClient(ctx, version, 0)
+}
 
-	f.lock.Lock()
-	defer f.lock.Unlock()
+// getClient returns the client for the version, connecting to the daemon if
+// none is cached. Failed connection attempts are retried with a growing
+// backoff. A non-zero attemptTimeout bounds each attempt rather than all of
+// them, and an attempt that times out is retried like any other
+func (f *factory) getClient(ctx context.Context, version DockerVersion, attemptTimeout time.Duration) (dockeriface.Client, error) {
+	log.Debugf("Getting specific client (%s) from factory", version)
 
-	// double-check now that we're in a lock
-	client, ok = f.clients[version]
+	client, ok := f.getCachedClient(version)
 	if ok {
-		log.Debugf("Returning cached client (%s) after lock", version)
-		return client, nil
+		if !f.pingCachedClients {
+			log.Debugf("Returning cached client (%s)", version)
+			return client, nil
+		}
+		attemptCtx, cancel := attemptContext(ctx, attemptTimeout)
+		err := pingWithContext(attemptCtx, client, version)
+		cancel()
+		if err == nil {
+			log.Debugf("Returning cached client (%s)", version)
+			return client, nil
+		}
+		if _, ok := err.(*ClientTimeoutError); ok {
+			return nil, err
+		}
+		log.Warnf("Cached client (%s) failed to ping, reconnecting: %v", version, err)
+		f.removeCachedClient(version, client)
 	}
 
+	// The lock is not held while connecting so that probes of different
+	// versions can proceed in parallel
 	attempt := 0
 attemptConnection:
 	attempt++
 
 	log.Debugf("Attempt %d to connect to client (%s)", attempt, version)
 
-	client, err := newVersionedClient(f.endpoint, string(version))
+	attemptCtx, cancel := attemptContext(ctx, attemptTimeout)
+	client, err := connectWithContext(attemptCtx, f.endpoint, version)
+	cancel()
 	if err != nil {
-		log.Debugf("Error acquiring client (version=%s, attempt=%d): %s", version, attempt, err.Error())
+		log.Debugf("Error connecting to client (version=%s, attempt=%d): %s", version, attempt, err.Error())
 
-		if attempt < 10 {
-			dur := time.Second * time.Duration(5*attempt)
-			log.Debugf("Attempt %d; waiting %s and retrying", attempt, dur)
-			time.Sleep(dur)
+		if _, ok := err.(*ClientTimeoutError); ok {
+			// Only retry if the attempt timed out rather than the caller's
+			// context being done
+			if ctx.Err() == nil && attempt < maxConnectionAttempts && waitToRetry(ctx, attempt) {
+				goto attemptConnection
+			}
+			return nil, err
+		}
+		clientErr := newClientError(version, err)
+		if clientErr.Retriable() && attempt < maxConnectionAttempts && waitToRetry(ctx, attempt) {
 			goto attemptConnection
 		}
 
-		return nil, err
+		return nil, clientErr
 	}
 
-	err = client.Ping()
-	if err != nil {
-		log.Debugf("Error pinging client (version=%s, atetmpt-%d): %s", version, attempt, err.Error())
+	log.Debugf("Returning new client (%s)", version)
+	if f.breaker != nil {
+		client = &circuitBreakerClient{Client: client, breaker: f.breaker}
+	}
+	return f.cacheClient(version, client), nil
+}
 
-		if attempt < 10 {
-			dur := time.Second * time.Duration(5*attempt)
-			log.Debugf("Attempt %d; waiting %s and retrying", attempt, dur)
-			time.Sleep(dur)
-			goto attemptConnection
-		}
+// attemptContext returns ctx bounded by timeout, if it's non-zero
+func attemptContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
 
+// connectWithContext connects to the daemon with a client of the version and
+// pings it, returning a *ClientTimeoutError if the context is done first
+func connectWithContext(ctx context.Context, endpoint string, version DockerVersion) (dockeriface.Client, error) {
+	client, err := newVersionedClientWithContext(ctx, endpoint, version)
+	if err != nil {
 		return nil, err
 	}
+	if err := pingWithContext(ctx, client, version); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// getCachedClient returns the cached client for the version, if any
+func (f *factory) getCachedClient(version DockerVersion) (dockeriface.Client, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	client, ok := f.clients[version]
+	return client, ok
+}
+
+func (f *factory) InvalidateClient(version DockerVersion) {
+	log.Debugf("Invalidating cached client (%s)", version)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.clients, version)
+}
+
+// removeCachedClient removes the cached client for the version only if it is
+// still the given client, so that a client cached by another caller in the
+// meantime is kept
+func (f *factory) removeCachedClient(version DockerVersion, client dockeriface.Client) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if cached, ok := f.clients[version]; ok && cached == client {
+		delete(f.clients, version)
+	}
+}
 
+// cacheClient caches the client for the version and returns it. If another
+// caller cached a client for the version first, that client is returned
+// instead so that all callers share the same one
+func (f *factory) cacheClient(version DockerVersion, client dockeriface.Client) dockeriface.Client {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if cached, ok := f.clients[version]; ok {
+		return cached
+	}
 	f.clients[version] = client
-	log.Debugf("Returning new client (%s)", version)
-	return client, nil
+	return client
+}
+
+// newVersionedClientWithContext calls newVersionedClient, returning a
+// *ClientTimeoutError if the context is done before it returns
+func newVersionedClientWithContext(ctx context.Context, endpoint string, version DockerVersion) (dockeriface.Client, error) {
+	if ctx.Done() == nil {
+		// The context can never be done; no need to wait on it
+		return newVersionedClient(endpoint, string(version))
+	}
+	type clientResponse struct {
+		client dockeriface.Client
+		err    error
+	}
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan clientResponse, 1)
+	// Read newVersionedClient here rather than in the goroutine, which may
+	// outlive this call
+	connect := newVersionedClient
+	go func() {
+		client, err := connect(endpoint, string(version))
+		response <- clientResponse{client, err}
+	}()
+	select {
+	case resp := <-response:
+		return resp.client, resp.err
+	case <-ctx.Done():
+		return nil, &ClientTimeoutError{Version: version, Operation: "connect", Err: ctx.Err()}
+	}
+}
+
+// pingWithContext pings the daemon with the given client, returning a
+// *ClientTimeoutError if the context is done before the ping returns
+func pingWithContext(ctx context.Context, client dockeriface.Client, version DockerVersion) error {
+	if ctx.Done() == nil {
+		return client.Ping()
+	}
+	response := make(chan error, 1)
+	go func() { response <- client.Ping() }()
+	select {
+	case err := <-response:
+		return err
+	case <-ctx.Done():
+		return &ClientTimeoutError{Version: version, Operation: "ping", Err: ctx.Err()}
+	}
+}
+
+// waitToRetry sleeps before the next connection attempt. It returns false if
+// the context is done before the wait is over
+func waitToRetry(ctx context.Context, attempt int) bool {
+	dur := connectionRetryBackoff * time.Duration(attempt)
+	log.Debugf("Attempt %d; waiting %s and retrying", attempt, dur)
+	select {
+	case <-time.After(dur):
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 func (f *factory) FindAvailableVersions() []DockerVersion {
+	return f.FindAvailableVersionsWithContext(context.Background(), 0)
+}
+
+// FindAvailableVersionsWithContext tests all supported versions in parallel.
+// Each test is bounded by perVersionTimeout when it is positive, and all of
+// them give up once ctx is done. The returned versions are in the same order
+// as supportedVersions. Versions below the factory's minimum are not tested
+func (f *factory) FindAvailableVersionsWithContext(ctx context.Context, perVersionTimeout time.Duration) []DockerVersion {
+	candidates := f.candidateVersions()
+	available := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, version := range candidates {
+		wg.Add(1)
+		go func(i int, version DockerVersion) {
+			defer wg.Done()
+			available[i] = f.probeVersion(ctx, version, perVersionTimeout)
+		}(i, version)
+	}
+	wg.Wait()
+
 	var availableVersions []DockerVersion
-	for _, version := range supportedVersions {
-		_, err := f.GetClient(version)
-		if err == nil {
+	for i, version := range candidates {
+		if available[i] {
 			availableVersions = append(availableVersions, version)
-		} else {
-			log.Debugf("Failed to ping with Docker version %s: %v", version, err)
 		}
 	}
 	log.Infof("Detected Docker versions %v", availableVersions)
+
+	if len(availableVersions) > 0 {
+		// supportedVersions is in ascending order, so the last one is the highest
+		f.setNegotiatedVersion(availableVersions[len(availableVersions)-1])
+	}
 	return availableVersions
 }
+
+// candidateVersions returns the supported versions at or above the factory's
+// minimum version, in ascending order. If the minimum isn't a supported
+// version, no versions are returned
+func (f *factory) candidateVersions() []DockerVersion {
+	if f.minimumVersion == "" {
+		return supportedVersions
+	}
+	for i, version := range supportedVersions {
+		if version == f.minimumVersion {
+			return supportedVersions[i:]
+		}
+	}
+	return nil
+}
+
+// setNegotiatedVersion records the version and calls the version change
+// handler, if any, when it differs from the previously recorded version
+func (f *factory) setNegotiatedVersion(version DockerVersion) {
+	f.negotiatedVersionLock.Lock()
+	oldVersion := f.negotiatedVersion
+	f.negotiatedVersion = version
+	f.negotiatedVersionLock.Unlock()
+
+	if oldVersion == "" || oldVersion == version {
+		return
+	}
+	log.Infof("Negotiated Docker version changed from %s to %s", oldVersion, version)
+	if f.versionChangeHandler != nil {
+		f.versionChangeHandler(oldVersion, version)
+	}
+}
+
+// probeVersion reports whether a client for the version can be acquired. A
+// panic while probing is logged and reported as the version being unavailable
+func (f *factory) probeVersion(ctx context.Context, version DockerVersion, timeout time.Duration) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Panic while probing Docker version %s: %v", version, r)
+			ok = false
+		}
+	}()
+
+	probeCtx, cancel := ctx, func() {}
+	if timeout > 0 {
+		probeCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	_, err := f.GetClientWithContext(probeCtx, version)
+	if err != nil {
+		log.Debugf("Failed to ping with Docker version %s: %v", version, err)
+		return false
+	}
+	return true
+}
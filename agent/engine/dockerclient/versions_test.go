@@ -16,13 +16,40 @@ package dockerclient
 
 import (
 	"fmt"
+	"net"
+	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface/mocks"
+	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
+	"golang.org/x/net/context"
 )
 
+func TestDockerVersionAtLeast(t *testing.T) {
+	testCases := []struct {
+		version  DockerVersion
+		other    DockerVersion
+		expected bool
+	}{
+		{Version_1_25, Version_1_25, true},
+		{Version_1_30, Version_1_25, true},
+		{Version_1_24, Version_1_25, false},
+		{"2.0", Version_1_30, true},
+		{"1.9", Version_1_17, false},
+		{"", Version_1_17, false},
+		{Version_1_17, "bogus", false},
+	}
+	for _, tc := range testCases {
+		if actual := tc.version.AtLeast(tc.other); actual != tc.expected {
+			t.Errorf("Expected %q.AtLeast(%q) to be %v", tc.version, tc.other, tc.expected)
+		}
+	}
+}
+
 func TestGetDefaultClientSuccess(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -160,60 +187,703 @@ func TestFindAvailableVersiosn(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockClient117 := mock_dockeriface.NewMockClient(ctrl)
-	mockClient118 := mock_dockeriface.NewMockClient(ctrl)
-	mockClient119 := mock_dockeriface.NewMockClient(ctrl)
-	mockClient120 := mock_dockeriface.NewMockClient(ctrl)
-	mockClient121 := mock_dockeriface.NewMockClient(ctrl)
-	mockClient122 := mock_dockeriface.NewMockClient(ctrl)
-	mockClient123 := mock_dockeriface.NewMockClient(ctrl)
-
 	expectedEndpoint := "expectedEndpoint"
 
+	// The daemon supports every version up to 1.29, and rejects 1.30 as too new
+	mockClients := make(map[DockerVersion]*mock_dockeriface.MockClient)
+	var expectedVersions []DockerVersion
+	for _, version := range SupportedVersions() {
+		mockClient := mock_dockeriface.NewMockClient(ctrl)
+		if version == Version_1_30 {
+			mockClient.EXPECT().Ping().Return(&docker.Error{Status: http.StatusBadRequest, Message: "client version 1.30 is too new"})
+		} else {
+			mockClient.EXPECT().Ping()
+			expectedVersions = append(expectedVersions, version)
+		}
+		mockClients[version] = mockClient
+	}
+
+	// Versions are probed in parallel, so errors are collected to be reported
+	// by the test goroutine
+	var lock sync.Mutex
+	var probeErrors []string
 	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		lock.Lock()
+		defer lock.Unlock()
 		if endpoint != expectedEndpoint {
-			t.Errorf("Expected endpoint %s but was %s", expectedEndpoint, endpoint)
+			probeErrors = append(probeErrors, fmt.Sprintf("Expected endpoint %s but was %s", expectedEndpoint, endpoint))
 		}
+		mockClient, ok := mockClients[DockerVersion(version)]
+		if !ok {
+			probeErrors = append(probeErrors, "Unrecognized version "+version)
+			return nil, fmt.Errorf("This should not happen, update the test")
+		}
+		return mockClient, nil
+	}
 
-		switch DockerVersion(version) {
-		case Version_1_17:
+	factory := NewFactory(expectedEndpoint)
+	versions := factory.FindAvailableVersions()
+	lock.Lock()
+	for _, probeError := range probeErrors {
+		t.Error(probeError)
+	}
+	lock.Unlock()
+	if len(versions) != len(expectedVersions) {
+		t.Fatalf("Expected %d versions but got %d", len(expectedVersions), len(versions))
+	}
+	for i := 0; i < len(versions); i++ {
+		if versions[i] != expectedVersions[i] {
+			t.Errorf("Expected version %s but got version %s", expectedVersions[i], versions[i])
+		}
+	}
+}
+
+func TestGetClientWithContextPingTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		return mockClient, nil
+	}
+
+	// Simulate a half-open daemon socket by never returning from Ping
+	unblockPing := make(chan struct{})
+	defer close(unblockPing)
+	mockClient.EXPECT().Ping().Do(func() { <-unblockPing })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	factory := NewFactory("")
+	client, err := factory.GetClientWithContext(ctx, Version_1_20)
+	if client != nil {
+		t.Error("client should be nil")
+	}
+	timeoutErr, ok := err.(*ClientTimeoutError)
+	if !ok {
+		t.Fatalf("Expected a ClientTimeoutError, got %v", err)
+	}
+	if timeoutErr.Operation != "ping" {
+		t.Errorf("Expected the ping to time out, but %s timed out", timeoutErr.Operation)
+	}
+	if timeoutErr.Version != Version_1_20 {
+		t.Errorf("Expected version %s but was %s", Version_1_20, timeoutErr.Version)
+	}
+}
+
+func TestGetClientWithContextConnectionRefused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		return mockClient, nil
+	}
+
+	mockClient.EXPECT().Ping().Return(docker.ErrConnectionRefused)
+
+	// The context expires while waiting to retry, so the daemon's error is
+	// what gets returned rather than a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	factory := NewFactory("")
+	client, err := factory.GetClientWithContext(ctx, Version_1_20)
+	if client != nil {
+		t.Error("client should be nil")
+	}
+	clientErr, ok := err.(*ClientError)
+	if !ok {
+		t.Fatalf("Expected a *ClientError, got %v", err)
+	}
+	if clientErr.Err != docker.ErrConnectionRefused {
+		t.Errorf("Expected connection refused error, got %v", clientErr.Err)
+	}
+	if clientErr.Category != ClientErrorDaemonUnreachable {
+		t.Errorf("Expected category %s, got %s", ClientErrorDaemonUnreachable, clientErr.Category)
+	}
+}
+
+func TestGetDefaultClientWithContextCancelled(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		<-unblock
+		return nil, fmt.Errorf("Test error!")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	factory := NewFactory("")
+	_, err := factory.GetDefaultClientWithContext(ctx)
+	timeoutErr, ok := err.(*ClientTimeoutError)
+	if !ok {
+		t.Fatalf("Expected a ClientTimeoutError, got %v", err)
+	}
+	if timeoutErr.Operation != "connect" {
+		t.Errorf("Expected the connect to time out, but %s timed out", timeoutErr.Operation)
+	}
+}
+
+func TestFindAvailableVersionsWithContextBoundsEachProbe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient117 := mock_dockeriface.NewMockClient(ctrl)
+	mockClientHung := mock_dockeriface.NewMockClient(ctrl)
+
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		if DockerVersion(version) == Version_1_17 {
 			return mockClient117, nil
-		case Version_1_18:
-			return mockClient118, nil
-		case Version_1_19:
-			return mockClient119, nil
-		case Version_1_20:
-			return mockClient120, nil
-		case Version_1_21:
-			return mockClient121, nil
-		case Version_1_22:
-			return mockClient122, nil
-		case Version_1_23:
-			return mockClient123, nil
-		default:
-			t.Fatal("Unrecognized version")
-		}
-		return nil, fmt.Errorf("This should not happen, update the test")
+		}
+		return mockClientHung, nil
 	}
 
+	unblockPing := make(chan struct{})
+	defer close(unblockPing)
 	mockClient117.EXPECT().Ping()
-	mockClient118.EXPECT().Ping().Return(fmt.Errorf("Test error!"))
-	mockClient119.EXPECT().Ping()
-	mockClient120.EXPECT().Ping()
-	mockClient121.EXPECT().Ping()
-	mockClient122.EXPECT().Ping()
-	mockClient123.EXPECT().Ping()
+	mockClientHung.EXPECT().Ping().Do(func() { <-unblockPing }).Times(len(supportedVersions) - 1)
 
-	expectedVersions := []DockerVersion{Version_1_17, Version_1_19, Version_1_20, Version_1_21, Version_1_22, Version_1_23}
+	perVersionTimeout := 10 * time.Millisecond
+	factory := NewFactory("")
+	start := time.Now()
+	versions := factory.FindAvailableVersionsWithContext(context.Background(), perVersionTimeout)
+	elapsed := time.Since(start)
 
-	factory := NewFactory(expectedEndpoint)
+	if len(versions) != 1 || versions[0] != Version_1_17 {
+		t.Errorf("Expected only version %s, got %v", Version_1_17, versions)
+	}
+	// Generous upper bound; without per-version timeouts this would never return
+	if maxElapsed := time.Duration(len(supportedVersions)) * perVersionTimeout * 10; elapsed > maxElapsed {
+		t.Errorf("Detection took %s, expected less than %s", elapsed, maxElapsed)
+	}
+}
+
+func TestGetDefaultVersionBeforeDetection(t *testing.T) {
+	factory := NewFactory("")
+	if version := factory.GetDefaultVersion(); version != defaultVersion {
+		t.Errorf("Expected version %s before detection but was %s", defaultVersion, version)
+	}
+}
+
+func TestGetDefaultVersionAfterDetection(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	// Only versions up to 1.21 are available
+	available := map[DockerVersion]bool{
+		Version_1_17: true,
+		Version_1_18: true,
+		Version_1_19: true,
+		Version_1_20: true,
+		Version_1_21: true,
+	}
+	var requestedVersions []string
+	var requestedVersionsLock sync.Mutex
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		requestedVersionsLock.Lock()
+		requestedVersions = append(requestedVersions, version)
+		requestedVersionsLock.Unlock()
+		if !available[DockerVersion(version)] {
+			return nil, fmt.Errorf("Test error!")
+		}
+		return mockClient, nil
+	}
+	mockClient.EXPECT().Ping().Times(len(available))
+
+	factory := NewFactory("")
+	versions := factory.FindAvailableVersionsWithContext(context.Background(), 10*time.Millisecond)
+	if len(versions) != len(available) {
+		t.Errorf("Expected %d versions but got %v", len(available), versions)
+	}
+	if version := factory.GetDefaultVersion(); version != Version_1_21 {
+		t.Errorf("Expected negotiated version %s but was %s", Version_1_21, version)
+	}
+
+	requestedVersions = nil
+	client, err := factory.GetDefaultClient()
+	if err != nil {
+		t.Fatal("err should be nil")
+	}
+	if client != mockClient {
+		t.Error("Client returned by GetDefaultClient differs from mockClient")
+	}
+	if len(requestedVersions) != 0 {
+		t.Errorf("Expected the cached %s client to be used, but created %v", Version_1_21, requestedVersions)
+	}
+}
+
+func TestDockerVersionConstants(t *testing.T) {
+	testCases := []struct {
+		version  DockerVersion
+		expected string
+	}{
+		{Version_1_17, "1.17"},
+		{Version_1_18, "1.18"},
+		{Version_1_19, "1.19"},
+		{Version_1_20, "1.20"},
+		{Version_1_21, "1.21"},
+		{Version_1_22, "1.22"},
+		{Version_1_23, "1.23"},
+		{Version_1_24, "1.24"},
+		{Version_1_25, "1.25"},
+		{Version_1_26, "1.26"},
+		{Version_1_27, "1.27"},
+		{Version_1_28, "1.28"},
+		{Version_1_29, "1.29"},
+		{Version_1_30, "1.30"},
+	}
+
+	if len(supportedVersions) != len(testCases) {
+		t.Fatalf("Expected %d supported versions but got %v", len(testCases), supportedVersions)
+	}
+	for i, tc := range testCases {
+		if string(tc.version) != tc.expected {
+			t.Errorf("Expected version %s but was %s", tc.expected, tc.version)
+		}
+		// supportedVersions must be in ascending order, as the highest
+		// available version is taken from the end of it
+		if supportedVersions[i] != tc.version {
+			t.Errorf("Expected supportedVersions[%d] to be %s but was %s", i, tc.version, supportedVersions[i])
+		}
+	}
+	if defaultVersion != supportedVersions[len(supportedVersions)-1] {
+		t.Errorf("Expected default version %s to be the highest supported version", defaultVersion)
+	}
+}
+
+func TestGetDefaultClientFallsBackToOlderVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldTimeout := defaultVersionProbeTimeout
+	defaultVersionProbeTimeout = 10 * time.Millisecond
+	defer func() { defaultVersionProbeTimeout = oldTimeout }()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	// The daemon only supports versions up to 1.24
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		if DockerVersion(version) > Version_1_24 {
+			return nil, &docker.Error{Status: http.StatusBadRequest, Message: "client is newer than server"}
+		}
+		return mockClient, nil
+	}
+	mockClient.EXPECT().Ping().AnyTimes()
+
+	factory := NewFactory("")
+	client, err := factory.GetDefaultClient()
+	if err != nil {
+		t.Fatalf("Expected fallback to an older version, got error: %v", err)
+	}
+	if client != mockClient {
+		t.Error("Client returned by GetDefaultClient differs from mockClient")
+	}
+	if version := factory.GetDefaultVersion(); version != Version_1_24 {
+		t.Errorf("Expected default version %s after fallback but was %s", Version_1_24, version)
+	}
+}
+
+func TestGetDefaultClientRetriesTimedOutAttempt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldTimeout, oldBackoff := defaultVersionProbeTimeout, connectionRetryBackoff
+	defaultVersionProbeTimeout, connectionRetryBackoff = 10*time.Millisecond, time.Millisecond
+	defer func() { defaultVersionProbeTimeout, connectionRetryBackoff = oldTimeout, oldBackoff }()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		return mockClient, nil
+	}
+	// The first ping hangs past the probe timeout; the timeout bounds only
+	// that attempt, and the next one succeeds
+	unblockPing := make(chan struct{})
+	defer close(unblockPing)
+	gomock.InOrder(
+		mockClient.EXPECT().Ping().Do(func() { <-unblockPing }),
+		mockClient.EXPECT().Ping(),
+	)
+
+	factory := NewFactory("")
+	client, err := factory.GetDefaultClient()
+	if err != nil {
+		t.Fatalf("Expected the timed out attempt to be retried, got error: %v", err)
+	}
+	if client != mockClient {
+		t.Error("Client returned by GetDefaultClient differs from mockClient")
+	}
+	if version := factory.GetDefaultVersion(); version != defaultVersion {
+		t.Errorf("Expected default version %s but was %s", defaultVersion, version)
+	}
+}
+
+func TestGetClientRetryBudget(t *testing.T) {
+	oldBackoff := connectionRetryBackoff
+	connectionRetryBackoff = time.Millisecond
+	defer func() { connectionRetryBackoff = oldBackoff }()
+
+	attempts := 0
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		attempts++
+		return nil, docker.ErrConnectionRefused
+	}
+
+	// Each attempt is bounded by the timeout, not all of them together
+	factory := NewFactory("").(*factory)
+	_, err := factory.getClient(context.Background(), defaultVersion, 10*time.Millisecond)
+	if err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if attempts != maxConnectionAttempts {
+		t.Errorf("Expected %d attempts to connect but got %d", maxConnectionAttempts, attempts)
+	}
+}
+
+func TestGetClientUnavailableHighVersion(t *testing.T) {
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		return nil, fmt.Errorf("client is newer than server")
+	}
+
+	factory := NewFactory("")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	client, err := factory.GetClientWithContext(ctx, Version_1_30)
+	if err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if client != nil {
+		t.Error("client should be nil")
+	}
+}
+
+func TestFindAvailableVersionsProbesConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	mockClient.EXPECT().Ping().Times(len(supportedVersions))
+
+	connectDelay := 50 * time.Millisecond
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		time.Sleep(connectDelay)
+		return mockClient, nil
+	}
+
+	factory := NewFactory("")
+	start := time.Now()
+	versions := factory.FindAvailableVersions()
+	elapsed := time.Since(start)
+
+	if len(versions) != len(supportedVersions) {
+		t.Fatalf("Expected %d versions but got %v", len(supportedVersions), versions)
+	}
+	for i, version := range versions {
+		if version != supportedVersions[i] {
+			t.Errorf("Expected version %s at index %d but got %s", supportedVersions[i], i, version)
+		}
+	}
+	// Probing serially would take connectDelay for every supported version
+	if maxElapsed := time.Duration(len(supportedVersions)) * connectDelay / 2; elapsed > maxElapsed {
+		t.Errorf("Detection took %s, expected less than %s", elapsed, maxElapsed)
+	}
+}
+
+func TestFindAvailableVersionsRecoversFromPanic(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	mockClient.EXPECT().Ping().Times(len(supportedVersions) - 1)
+
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		if DockerVersion(version) == Version_1_20 {
+			panic("Test panic!")
+		}
+		return mockClient, nil
+	}
+
+	factory := NewFactory("")
 	versions := factory.FindAvailableVersions()
+	if len(versions) != len(supportedVersions)-1 {
+		t.Fatalf("Expected %d versions but got %v", len(supportedVersions)-1, versions)
+	}
+	for _, version := range versions {
+		if version == Version_1_20 {
+			t.Errorf("Expected version %s to be unavailable", Version_1_20)
+		}
+	}
+}
+
+func BenchmarkFindAvailableVersions(b *testing.B) {
+	ctrl := gomock.NewController(b)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	mockClient.EXPECT().Ping().AnyTimes()
+
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		time.Sleep(time.Millisecond)
+		return mockClient, nil
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// A new factory each time so that no clients are cached
+		NewFactory("").FindAvailableVersions()
+	}
+}
+
+func TestInvalidateClientRedials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	mockClient.EXPECT().Ping().Times(2)
+
+	dials := 0
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		dials++
+		return mockClient, nil
+	}
+
+	factory := NewFactory("")
+	for i := 0; i < 2; i++ {
+		if _, err := factory.GetClient(Version_1_18); err != nil {
+			t.Fatal("err should be nil")
+		}
+	}
+	if dials != 1 {
+		t.Fatalf("Expected the client to be cached after 1 dial, got %d", dials)
+	}
+
+	factory.InvalidateClient(Version_1_18)
+	client, err := factory.GetClient(Version_1_18)
+	if err != nil {
+		t.Fatal("err should be nil")
+	}
+	if client != mockClient {
+		t.Error("Client returned by GetClient differs from mockClient")
+	}
+	if dials != 2 {
+		t.Errorf("Expected GetClient to dial again after invalidation, got %d dials", dials)
+	}
+}
+
+func TestGetClientWithCachedClientPingRedialsStaleClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	staleClient := mock_dockeriface.NewMockClient(ctrl)
+	freshClient := mock_dockeriface.NewMockClient(ctrl)
+
+	clients := []dockeriface.Client{staleClient, freshClient}
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		client := clients[0]
+		clients = clients[1:]
+		return client, nil
+	}
+
+	gomock.InOrder(
+		staleClient.EXPECT().Ping(),
+		staleClient.EXPECT().Ping().Return(fmt.Errorf("Test error!")),
+		freshClient.EXPECT().Ping(),
+	)
+
+	factory := NewFactory("", WithCachedClientPing())
+	client, err := factory.GetClient(Version_1_18)
+	if err != nil {
+		t.Fatal("err should be nil")
+	}
+	if client != staleClient {
+		t.Error("Expected the first dialed client")
+	}
+
+	// The daemon restarted; the cached client no longer pings
+	client, err = factory.GetClient(Version_1_18)
+	if err != nil {
+		t.Fatal("err should be nil")
+	}
+	if client != freshClient {
+		t.Error("Expected a newly dialed client after the cached one failed to ping")
+	}
+}
+
+func TestVersionChangeHandlerCalledOnlyOnChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	mockClient.EXPECT().Ping().AnyTimes()
+
+	maxVersion := Version_1_24
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		if DockerVersion(version) > maxVersion {
+			return nil, fmt.Errorf("client is newer than server")
+		}
+		return mockClient, nil
+	}
+
+	type change struct {
+		oldVersion DockerVersion
+		newVersion DockerVersion
+	}
+	var changes []change
+	factory := NewFactory("", WithVersionChangeHandler(func(oldVersion, newVersion DockerVersion) {
+		changes = append(changes, change{oldVersion, newVersion})
+	}))
+
+	testCases := []struct {
+		name            string
+		maxVersion      DockerVersion
+		expectedChanges []change
+	}{
+		{"first detection", Version_1_24, nil},
+		{"same version", Version_1_24, nil},
+		{"downgrade", Version_1_22, []change{{Version_1_24, Version_1_22}}},
+	}
+	for _, tc := range testCases {
+		maxVersion = tc.maxVersion
+		changes = nil
+		// Each detection starts from scratch as clients would be after a
+		// daemon restart
+		for _, version := range supportedVersions {
+			factory.InvalidateClient(version)
+		}
+		factory.FindAvailableVersionsWithContext(context.Background(), 10*time.Millisecond)
+		if len(changes) != len(tc.expectedChanges) {
+			t.Errorf("%s: expected changes %v but got %v", tc.name, tc.expectedChanges, changes)
+			continue
+		}
+		for i := range changes {
+			if changes[i] != tc.expectedChanges[i] {
+				t.Errorf("%s: expected change %v but got %v", tc.name, tc.expectedChanges[i], changes[i])
+			}
+		}
+	}
+}
+
+func TestMinimumVersionPermitsSubset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	// The daemon supports up to 1.24, and only 1.22 and up are permitted
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		if DockerVersion(version) < Version_1_22 {
+			t.Errorf("Unexpected probe of version %s below the minimum", version)
+		}
+		if DockerVersion(version) > Version_1_24 {
+			return nil, fmt.Errorf("client is newer than server")
+		}
+		return mockClient, nil
+	}
+	mockClient.EXPECT().Ping().Times(3)
+
+	factory := NewFactory("", WithMinimumVersion(Version_1_22))
+	versions := factory.FindAvailableVersionsWithContext(context.Background(), 10*time.Millisecond)
+	expectedVersions := []DockerVersion{Version_1_22, Version_1_23, Version_1_24}
 	if len(versions) != len(expectedVersions) {
-		t.Errorf("Expected %d versions but got %d", len(expectedVersions), len(versions))
+		t.Fatalf("Expected versions %v but got %v", expectedVersions, versions)
 	}
-	for i := 0; i < len(versions); i++ {
+	for i := range versions {
 		if versions[i] != expectedVersions[i] {
 			t.Errorf("Expected version %s but got version %s", expectedVersions[i], versions[i])
 		}
 	}
 }
+
+func TestMinimumVersionExcludesAllAvailable(t *testing.T) {
+	oldTimeout := defaultVersionProbeTimeout
+	defaultVersionProbeTimeout = 10 * time.Millisecond
+	defer func() { defaultVersionProbeTimeout = oldTimeout }()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_dockeriface.NewMockClient(ctrl)
+	// The daemon supports up to 1.24 but 1.25 is required
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		if DockerVersion(version) > Version_1_24 {
+			return nil, &docker.Error{Status: http.StatusBadRequest, Message: "client is newer than server"}
+		}
+		return mockClient, nil
+	}
+
+	factory := NewFactory("", WithMinimumVersion(Version_1_25))
+	client, err := factory.GetDefaultClient()
+	if err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if client != nil {
+		t.Error("client should be nil")
+	}
+	if versions := factory.FindAvailableVersionsWithContext(context.Background(), 10*time.Millisecond); len(versions) != 0 {
+		t.Errorf("Expected no versions but got %v", versions)
+	}
+}
+
+func TestMinimumVersionUnsupported(t *testing.T) {
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		t.Errorf("Unexpected attempt to connect with version %s", version)
+		return nil, fmt.Errorf("Test error!")
+	}
+
+	factory := NewFactory("", WithMinimumVersion(DockerVersion("9.99")))
+	if _, err := factory.GetDefaultClient(); err == nil {
+		t.Error("err should not be nil")
+	}
+}
+
+func TestGetClientErrorCategories(t *testing.T) {
+	testCases := []struct {
+		name             string
+		pingErr          error
+		expectedCategory ClientErrorCategory
+	}{
+		{"newer than old daemon", &docker.Error{Status: 404, Message: "client is newer than server"}, ClientErrorVersionUnsupported},
+		{"newer than new daemon", &docker.Error{Status: 400, Message: "client version 1.30 is too new"}, ClientErrorVersionUnsupported},
+		{"connection refused", docker.ErrConnectionRefused, ClientErrorDaemonUnreachable},
+		{"network error", &net.OpError{Op: "dial", Err: fmt.Errorf("no such file")}, ClientErrorDaemonUnreachable},
+		{"server error", &docker.Error{Status: 500, Message: "Test error!"}, ClientErrorUnknown},
+	}
+
+	for _, tc := range testCases {
+		ctrl := gomock.NewController(t)
+		mockClient := mock_dockeriface.NewMockClient(ctrl)
+		attempts := 0
+		newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+			attempts++
+			return mockClient, nil
+		}
+		mockClient.EXPECT().Ping().Return(tc.pingErr).AnyTimes()
+
+		// Long enough for the first attempt and the start of a retry wait,
+		// which the context then cuts short
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := NewFactory("").GetClientWithContext(ctx, Version_1_30)
+		cancel()
+
+		clientErr, ok := err.(*ClientError)
+		if !ok {
+			t.Errorf("%s: expected a *ClientError, got %v", tc.name, err)
+		} else {
+			if clientErr.Category != tc.expectedCategory {
+				t.Errorf("%s: expected category %s, got %s", tc.name, tc.expectedCategory, clientErr.Category)
+			}
+			if clientErr.Version != Version_1_30 {
+				t.Errorf("%s: expected version %s, got %s", tc.name, Version_1_30, clientErr.Version)
+			}
+			if clientErr.Unwrap() != tc.pingErr {
+				t.Errorf("%s: expected wrapped error %v, got %v", tc.name, tc.pingErr, clientErr.Unwrap())
+			}
+		}
+		// Unsupported versions are not retried
+		if tc.expectedCategory == ClientErrorVersionUnsupported && attempts != 1 {
+			t.Errorf("%s: expected 1 attempt, got %d", tc.name, attempts)
+		}
+		ctrl.Finish()
+	}
+}
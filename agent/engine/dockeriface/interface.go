@@ -21,15 +21,28 @@ import (
 )
 
 // Client is an interface specifying the subset of
-// github.com/fsouza/go-dockerclient.Client that the agent uses.
+// github.com/fsouza/go-dockerclient.Client that the agent uses, along with the
+// calls whose requests or responses have fields the vendored go-dockerclient
+// doesn't have.
 type Client interface {
 	AddEventListener(listener chan<- *docker.APIEvents) error
-	CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error)
+	// CreateContainerWithHostConfig creates a container as
+	// go-dockerclient's CreateContainer does, with hostConfig in place of
+	// the host config of opts
+	CreateContainerWithHostConfig(opts docker.CreateContainerOptions, hostConfig *HostConfig) (*docker.Container, error)
+	CreateVolume(opts docker.CreateVolumeOptions) (*docker.Volume, error)
 	ImportImage(opts docker.ImportImageOptions) error
+	// Info returns the system-wide information of the daemon as
+	// go-dockerclient's Info does, along with the fields it doesn't decode
+	Info() (*Info, error)
 	InspectContainer(id string) (*docker.Container, error)
 	InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error)
+	// InspectContainerHealth returns the health of a container with a
+	// health check, which go-dockerclient's InspectContainer doesn't decode
+	InspectContainerHealth(id string, ctx context.Context) (*Health, error)
 	InspectImage(name string) (*docker.Image, error)
 	ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error)
+	Logs(opts docker.LogsOptions) error
 	Ping() error
 	PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
 	RemoveContainer(opts docker.RemoveContainerOptions) error
@@ -41,4 +54,5 @@ type Client interface {
 	Stats(opts docker.StatsOptions) error
 	Version() (*docker.Env, error)
 	RemoveImage(imageName string) error
+	RemoveVolume(name string) error
 }
@@ -17,6 +17,7 @@
 package mock_dockeriface
 
 import (
+	dockeriface "github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	go_dockerclient "github.com/fsouza/go-dockerclient"
 	gomock "github.com/golang/mock/gomock"
 	context "golang.org/x/net/context"
@@ -53,15 +54,26 @@ func (_mr *_MockClientRecorder) AddEventListener(arg0 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddEventListener", arg0)
 }
 
-func (_m *MockClient) CreateContainer(_param0 go_dockerclient.CreateContainerOptions) (*go_dockerclient.Container, error) {
-	ret := _m.ctrl.Call(_m, "CreateContainer", _param0)
+func (_m *MockClient) CreateContainerWithHostConfig(_param0 go_dockerclient.CreateContainerOptions, _param1 *dockeriface.HostConfig) (*go_dockerclient.Container, error) {
+	ret := _m.ctrl.Call(_m, "CreateContainerWithHostConfig", _param0, _param1)
 	ret0, _ := ret[0].(*go_dockerclient.Container)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockClientRecorder) CreateContainer(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateContainer", arg0)
+func (_mr *_MockClientRecorder) CreateContainerWithHostConfig(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateContainerWithHostConfig", arg0, arg1)
+}
+
+func (_m *MockClient) CreateVolume(_param0 go_dockerclient.CreateVolumeOptions) (*go_dockerclient.Volume, error) {
+	ret := _m.ctrl.Call(_m, "CreateVolume", _param0)
+	ret0, _ := ret[0].(*go_dockerclient.Volume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) CreateVolume(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateVolume", arg0)
 }
 
 func (_m *MockClient) ImportImage(_param0 go_dockerclient.ImportImageOptions) error {
@@ -74,6 +86,17 @@ func (_mr *_MockClientRecorder) ImportImage(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "ImportImage", arg0)
 }
 
+func (_m *MockClient) Info() (*dockeriface.Info, error) {
+	ret := _m.ctrl.Call(_m, "Info")
+	ret0, _ := ret[0].(*dockeriface.Info)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) Info() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Info")
+}
+
 func (_m *MockClient) InspectContainer(_param0 string) (*go_dockerclient.Container, error) {
 	ret := _m.ctrl.Call(_m, "InspectContainer", _param0)
 	ret0, _ := ret[0].(*go_dockerclient.Container)
@@ -85,6 +108,17 @@ func (_mr *_MockClientRecorder) InspectContainer(arg0 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "InspectContainer", arg0)
 }
 
+func (_m *MockClient) InspectContainerHealth(_param0 string, _param1 context.Context) (*dockeriface.Health, error) {
+	ret := _m.ctrl.Call(_m, "InspectContainerHealth", _param0, _param1)
+	ret0, _ := ret[0].(*dockeriface.Health)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) InspectContainerHealth(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "InspectContainerHealth", arg0, arg1)
+}
+
 func (_m *MockClient) InspectContainerWithContext(_param0 string, _param1 context.Context) (*go_dockerclient.Container, error) {
 	ret := _m.ctrl.Call(_m, "InspectContainerWithContext", _param0, _param1)
 	ret0, _ := ret[0].(*go_dockerclient.Container)
@@ -118,6 +152,16 @@ func (_mr *_MockClientRecorder) ListContainers(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListContainers", arg0)
 }
 
+func (_m *MockClient) Logs(_param0 go_dockerclient.LogsOptions) error {
+	ret := _m.ctrl.Call(_m, "Logs", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockClientRecorder) Logs(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Logs", arg0)
+}
+
 func (_m *MockClient) Ping() error {
 	ret := _m.ctrl.Call(_m, "Ping")
 	ret0, _ := ret[0].(error)
@@ -168,6 +212,16 @@ func (_mr *_MockClientRecorder) RemoveImage(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveImage", arg0)
 }
 
+func (_m *MockClient) RemoveVolume(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "RemoveVolume", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockClientRecorder) RemoveVolume(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveVolume", arg0)
+}
+
 func (_m *MockClient) StartContainer(_param0 string, _param1 *go_dockerclient.HostConfig) error {
 	ret := _m.ctrl.Call(_m, "StartContainer", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -0,0 +1,61 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockeriface
+
+import "github.com/fsouza/go-dockerclient"
+
+// HostConfig is the host config of a container as it's sent to docker: the
+// fields of the vendored go-dockerclient HostConfig, along with the fields it
+// doesn't have
+type HostConfig struct {
+	*docker.HostConfig
+	// Init runs an init process in the container that forwards signals and
+	// reaps processes
+	Init bool `json:"Init,omitempty"`
+	// BlkioDeviceReadBps and BlkioDeviceWriteBps limit the read and write
+	// bandwidth of the container to host devices
+	BlkioDeviceReadBps  []BlockLimit `json:"BlkioDeviceReadBps,omitempty"`
+	BlkioDeviceWriteBps []BlockLimit `json:"BlkioDeviceWriteBps,omitempty"`
+}
+
+// BlockLimit limits the bandwidth of a container to the host device at Path
+// to Rate bytes per second. The vendored go-dockerclient BlockLimit has the
+// rate as a string, which docker rejects
+type BlockLimit struct {
+	Path string `json:"Path,omitempty"`
+	Rate int64  `json:"Rate,omitempty"`
+}
+
+// Info is the system-wide information of the docker daemon: the fields of the
+// vendored go-dockerclient DockerInfo, along with the fields it doesn't have
+type Info struct {
+	*docker.DockerInfo
+	Plugins PluginsInfo
+}
+
+// PluginsInfo lists the plugins of the docker daemon
+type PluginsInfo struct {
+	docker.PluginsInfo
+	// Log lists the logging drivers of the daemon, including logging
+	// plugins
+	Log []string
+}
+
+// Health is the health of a container with a health check, as docker reports
+// it in the state of inspected containers
+type Health struct {
+	// Status is one of "starting", "healthy" or "unhealthy"
+	Status        string
+	FailingStreak int
+}
@@ -0,0 +1,126 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"time"
+
+	"github.com/cihub/seelog"
+)
+
+// drainPollInterval is how often the engine checks whether all tasks have
+// stopped while draining
+var drainPollInterval = time.Second
+
+// DrainStatus describes the progress of draining the task engine
+type DrainStatus struct {
+	// Draining is true once the engine has stopped accepting new tasks
+	Draining bool
+	// RemainingTime is the time left before the agent stops waiting for
+	// tasks to stop
+	RemainingTime time.Duration
+	// ActiveTasks is the number of tasks that haven't stopped yet
+	ActiveTasks int
+}
+
+// Drain stops the engine from accepting new tasks and waits up to timeout for
+// the tasks it's managing to stop. Tasks are not stopped by the engine; they
+// are expected to be stopped through ACS, for example by the scheduler moving
+// them to another instance. The engine waits at least as long as the longest
+// stop timeout of the containers of those tasks, so that a task being stopped
+// isn't cut short. It returns true if all tasks stopped in time
+func (engine *DockerTaskEngine) Drain(timeout time.Duration) bool {
+	if stopTimeout := engine.longestStopTimeout(); stopTimeout > timeout {
+		seelog.Infof("Extending drain timeout from %v to the longest container stop timeout of %v", timeout, stopTimeout)
+		timeout = stopTimeout
+	}
+
+	engine.drainLock.Lock()
+	if !engine.draining {
+		engine.draining = true
+		engine.drainDeadline = engine.time().Now().Add(timeout)
+	}
+	engine.drainLock.Unlock()
+	seelog.Infof("Draining task engine; waiting up to %v for %d tasks to stop", timeout, engine.activeTaskCount())
+
+	for {
+		status := engine.DrainStatus()
+		if status.ActiveTasks == 0 {
+			seelog.Info("All tasks stopped, done draining task engine")
+			return true
+		}
+		if status.RemainingTime <= 0 {
+			seelog.Warnf("Timed out draining task engine with %d tasks still running", status.ActiveTasks)
+			return false
+		}
+		wait := drainPollInterval
+		if status.RemainingTime < wait {
+			wait = status.RemainingTime
+		}
+		engine.time().Sleep(wait)
+	}
+}
+
+// DrainStatus returns the progress of draining the task engine
+func (engine *DockerTaskEngine) DrainStatus() DrainStatus {
+	engine.drainLock.RLock()
+	defer engine.drainLock.RUnlock()
+
+	status := DrainStatus{
+		Draining:    engine.draining,
+		ActiveTasks: engine.activeTaskCount(),
+	}
+	if engine.draining {
+		status.RemainingTime = engine.drainDeadline.Sub(engine.time().Now())
+		if status.RemainingTime < 0 {
+			status.RemainingTime = 0
+		}
+	}
+	return status
+}
+
+// isDraining returns true if the engine has stopped accepting new tasks
+func (engine *DockerTaskEngine) isDraining() bool {
+	engine.drainLock.RLock()
+	defer engine.drainLock.RUnlock()
+	return engine.draining
+}
+
+// longestStopTimeout returns the longest stop timeout of the containers of
+// tasks that haven't stopped
+func (engine *DockerTaskEngine) longestStopTimeout() time.Duration {
+	var longest time.Duration
+	for _, task := range engine.state.AllTasks() {
+		if task.GetKnownStatus().Terminal() {
+			continue
+		}
+		for _, container := range task.Containers {
+			if stopTimeout := engine.containerStopTimeout(container); stopTimeout > longest {
+				longest = stopTimeout
+			}
+		}
+	}
+	return longest
+}
+
+// activeTaskCount returns the number of tasks that haven't stopped
+func (engine *DockerTaskEngine) activeTaskCount() int {
+	count := 0
+	for _, task := range engine.state.AllTasks() {
+		if !task.GetKnownStatus().Terminal() {
+			count++
+		}
+	}
+	return count
+}
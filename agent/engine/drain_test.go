@@ -0,0 +1,136 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+)
+
+func TestAddTaskWhileDraining(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine._time = &ttime.DefaultTime{}
+
+	if !taskEngine.Drain(0) {
+		t.Error("Expected drain to complete without any tasks")
+	}
+
+	// No calls to the client are expected
+	sleepTask := testdata.LoadTask("sleep5")
+	err := taskEngine.AddTask(sleepTask)
+	if _, ok := err.(TaskEngineDrainingError); !ok {
+		t.Fatalf("Expected TaskEngineDrainingError, got: %v", err)
+	}
+	if _, ok := taskEngine.state.TaskByArn(sleepTask.Arn); ok {
+		t.Error("Expected task to not be added while draining")
+	}
+}
+
+func TestDrainTimesOutWithRunningTasks(t *testing.T) {
+	cfg := defaultConfig
+	cfg.DockerStopTimeout = 10 * time.Millisecond
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine._time = &ttime.DefaultTime{}
+	defer func(interval time.Duration) { drainPollInterval = interval }(drainPollInterval)
+	drainPollInterval = 10 * time.Millisecond
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepTask.SetKnownStatus(api.TaskRunning)
+	taskEngine.state.AddTask(sleepTask)
+
+	timeout := 100 * time.Millisecond
+	start := time.Now()
+	if taskEngine.Drain(timeout) {
+		t.Error("Expected drain to time out with a running task")
+	}
+	elapsed := time.Since(start)
+	if elapsed < timeout || elapsed > 10*timeout {
+		t.Errorf("Expected drain to wait about %v, waited %v", timeout, elapsed)
+	}
+
+	status := taskEngine.DrainStatus()
+	if !status.Draining || status.RemainingTime != 0 || status.ActiveTasks != 1 {
+		t.Errorf("Unexpected drain status: %+v", status)
+	}
+}
+
+func TestDrainCompletesWhenTasksStop(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine._time = &ttime.DefaultTime{}
+	defer func(interval time.Duration) { drainPollInterval = interval }(drainPollInterval)
+	drainPollInterval = 10 * time.Millisecond
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepTask.SetKnownStatus(api.TaskRunning)
+	taskEngine.state.AddTask(sleepTask)
+
+	drained := make(chan bool)
+	go func() {
+		drained <- taskEngine.Drain(time.Minute)
+	}()
+
+	// Wait for the engine to start draining before stopping the task
+	for !taskEngine.DrainStatus().Draining {
+		time.Sleep(time.Millisecond)
+	}
+	status := taskEngine.DrainStatus()
+	if status.ActiveTasks != 1 || status.RemainingTime <= 0 || status.RemainingTime > time.Minute {
+		t.Errorf("Unexpected drain status: %+v", status)
+	}
+	sleepTask.SetKnownStatus(api.TaskStopped)
+
+	select {
+	case ok := <-drained:
+		if !ok {
+			t.Error("Expected drain to complete once tasks stopped")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for drain to complete")
+	}
+}
+
+func TestDrainWaitsForLongestStopTimeout(t *testing.T) {
+	cfg := defaultConfig
+	cfg.DockerStopTimeout = 10 * time.Millisecond
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine._time = &ttime.DefaultTime{}
+	defer func(interval time.Duration) { drainPollInterval = interval }(drainPollInterval)
+	drainPollInterval = 10 * time.Millisecond
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepTask.Containers[0].StopTimeout = 1
+	sleepTask.SetKnownStatus(api.TaskRunning)
+	taskEngine.state.AddTask(sleepTask)
+
+	start := time.Now()
+	if taskEngine.Drain(10 * time.Millisecond) {
+		t.Error("Expected drain to time out with a running task")
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected drain to wait for the container stop timeout, waited %v", elapsed)
+	}
+}
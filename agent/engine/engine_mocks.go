@@ -17,10 +17,12 @@
 package engine
 
 import (
+	io "io"
 	time "time"
 
 	api "github.com/aws/amazon-ecs-agent/agent/api"
 	dockerclient "github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+	dockeriface "github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
 	image "github.com/aws/amazon-ecs-agent/agent/engine/image"
 	statemanager "github.com/aws/amazon-ecs-agent/agent/statemanager"
 	go_dockerclient "github.com/fsouza/go-dockerclient"
@@ -189,6 +191,16 @@ func (_m *MockDockerClient) EXPECT() *_MockDockerClientRecorder {
 	return _m.recorder
 }
 
+func (_m *MockDockerClient) APIVersion() dockerclient.DockerVersion {
+	ret := _m.ctrl.Call(_m, "APIVersion")
+	ret0, _ := ret[0].(dockerclient.DockerVersion)
+	return ret0
+}
+
+func (_mr *_MockDockerClientRecorder) APIVersion() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "APIVersion")
+}
+
 func (_m *MockDockerClient) ContainerEvents(_param0 context.Context) (<-chan DockerContainerChangeEvent, error) {
 	ret := _m.ctrl.Call(_m, "ContainerEvents", _param0)
 	ret0, _ := ret[0].(<-chan DockerContainerChangeEvent)
@@ -200,7 +212,17 @@ func (_mr *_MockDockerClientRecorder) ContainerEvents(arg0 interface{}) *gomock.
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "ContainerEvents", arg0)
 }
 
-func (_m *MockDockerClient) CreateContainer(_param0 *go_dockerclient.Config, _param1 *go_dockerclient.HostConfig, _param2 string, _param3 time.Duration) DockerContainerMetadata {
+func (_m *MockDockerClient) ContainerLogs(_param0 context.Context, _param1 string, _param2 int, _param3 bool, _param4 io.Writer) error {
+	ret := _m.ctrl.Call(_m, "ContainerLogs", _param0, _param1, _param2, _param3, _param4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerClientRecorder) ContainerLogs(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ContainerLogs", arg0, arg1, arg2, arg3, arg4)
+}
+
+func (_m *MockDockerClient) CreateContainer(_param0 *go_dockerclient.Config, _param1 *dockeriface.HostConfig, _param2 string, _param3 time.Duration) DockerContainerMetadata {
 	ret := _m.ctrl.Call(_m, "CreateContainer", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].(DockerContainerMetadata)
 	return ret0
@@ -210,6 +232,16 @@ func (_mr *_MockDockerClientRecorder) CreateContainer(arg0, arg1, arg2, arg3 int
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateContainer", arg0, arg1, arg2, arg3)
 }
 
+func (_m *MockDockerClient) CreateVolume(_param0 string, _param1 string, _param2 map[string]string, _param3 map[string]string, _param4 time.Duration) error {
+	ret := _m.ctrl.Call(_m, "CreateVolume", _param0, _param1, _param2, _param3, _param4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerClientRecorder) CreateVolume(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateVolume", arg0, arg1, arg2, arg3, arg4)
+}
+
 func (_m *MockDockerClient) DescribeContainer(_param0 string) (api.ContainerStatus, DockerContainerMetadata) {
 	ret := _m.ctrl.Call(_m, "DescribeContainer", _param0)
 	ret0, _ := ret[0].(api.ContainerStatus)
@@ -221,6 +253,27 @@ func (_mr *_MockDockerClientRecorder) DescribeContainer(arg0 interface{}) *gomoc
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DescribeContainer", arg0)
 }
 
+func (_m *MockDockerClient) Ping() error {
+	ret := _m.ctrl.Call(_m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerClientRecorder) Ping() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Ping")
+}
+
+func (_m *MockDockerClient) Info() (DockerInfo, error) {
+	ret := _m.ctrl.Call(_m, "Info")
+	ret0, _ := ret[0].(DockerInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDockerClientRecorder) Info() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Info")
+}
+
 func (_m *MockDockerClient) InspectContainer(_param0 string, _param1 time.Duration) (*go_dockerclient.Container, error) {
 	ret := _m.ctrl.Call(_m, "InspectContainer", _param0, _param1)
 	ret0, _ := ret[0].(*go_dockerclient.Container)
@@ -283,6 +336,16 @@ func (_mr *_MockDockerClientRecorder) RemoveImage(arg0, arg1 interface{}) *gomoc
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveImage", arg0, arg1)
 }
 
+func (_m *MockDockerClient) RemoveVolume(_param0 string, _param1 time.Duration) error {
+	ret := _m.ctrl.Call(_m, "RemoveVolume", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerClientRecorder) RemoveVolume(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveVolume", arg0, arg1)
+}
+
 func (_m *MockDockerClient) StartContainer(_param0 string, _param1 time.Duration) DockerContainerMetadata {
 	ret := _m.ctrl.Call(_m, "StartContainer", _param0, _param1)
 	ret0, _ := ret[0].(DockerContainerMetadata)
@@ -304,14 +367,14 @@ func (_mr *_MockDockerClientRecorder) Stats(arg0, arg1 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Stats", arg0, arg1)
 }
 
-func (_m *MockDockerClient) StopContainer(_param0 string, _param1 time.Duration) DockerContainerMetadata {
-	ret := _m.ctrl.Call(_m, "StopContainer", _param0, _param1)
+func (_m *MockDockerClient) StopContainer(_param0 string, _param1 time.Duration, _param2 time.Duration) DockerContainerMetadata {
+	ret := _m.ctrl.Call(_m, "StopContainer", _param0, _param1, _param2)
 	ret0, _ := ret[0].(DockerContainerMetadata)
 	return ret0
 }
 
-func (_mr *_MockDockerClientRecorder) StopContainer(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "StopContainer", arg0, arg1)
+func (_mr *_MockDockerClientRecorder) StopContainer(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StopContainer", arg0, arg1, arg2)
 }
 
 func (_m *MockDockerClient) SupportedVersions() []dockerclient.DockerVersion {
@@ -394,6 +457,14 @@ func (_mr *_MockImageManagerRecorder) RecordContainerReference(arg0 interface{})
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RecordContainerReference", arg0)
 }
 
+func (_m *MockImageManager) RemoveAllUnusedImages() {
+	_m.ctrl.Call(_m, "RemoveAllUnusedImages")
+}
+
+func (_mr *_MockImageManagerRecorder) RemoveAllUnusedImages() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveAllUnusedImages")
+}
+
 func (_m *MockImageManager) RemoveContainerReferenceFromImageState(_param0 *api.Container) error {
 	ret := _m.ctrl.Call(_m, "RemoveContainerReferenceFromImageState", _param0)
 	ret0, _ := ret[0].(error)
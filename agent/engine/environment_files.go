@@ -0,0 +1,151 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// checkEnvironmentFiles returns an error if an environment file of the
+// container isn't a local file the container may use. Local files must be
+// enabled on the instance, and as they're read by the agent, paths are
+// resolved before being checked against the bind mount policy and the files
+// of the agent itself are always denied. The files themselves aren't read
+func (engine *DockerTaskEngine) checkEnvironmentFiles(container *api.Container) engineError {
+	for _, envFile := range container.EnvironmentFiles {
+		if envFile.Type != api.EnvironmentFileTypeLocal {
+			return EnvironmentFileError{"Unsupported type " + strconv.Quote(envFile.Type) + " of environment file " + envFile.Value + ", expected " + api.EnvironmentFileTypeLocal}
+		}
+		if !engine.cfg.LocalEnvironmentFilesEnabled {
+			return EnvironmentFileError{"Reading environment file " + envFile.Value + " is not allowed: local environment files aren't enabled on this instance"}
+		}
+		if !filepath.IsAbs(envFile.Value) {
+			return EnvironmentFileError{"Invalid environment file " + envFile.Value + ": must be an absolute path"}
+		}
+		path, err := resolvePath(envFile.Value)
+		if err != nil {
+			return EnvironmentFileError{"Unable to resolve environment file " + envFile.Value + ": " + err.Error()}
+		}
+		if pathUnderAny(path, resolvePaths(engine.cfg.AgentPaths())) {
+			return EnvironmentFileError{"Reading environment file " + envFile.Value + " is denied: it holds the config or data of the agent"}
+		}
+		if restriction := engine.hostPathRestriction(path); restriction != "" {
+			return EnvironmentFileError{"Reading environment file " + envFile.Value + " is " + restriction + " on this instance"}
+		}
+	}
+	return nil
+}
+
+// resolvePath returns the path with any symbolic links in it followed. Paths
+// that don't exist can't be read, and are returned as they are
+func resolvePath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		return filepath.Clean(path), nil
+	}
+	return resolved, err
+}
+
+// resolvePaths returns each of the paths both as it is and resolved, if it
+// resolves to a different path
+func resolvePaths(paths []string) []string {
+	var all []string
+	for _, path := range paths {
+		all = append(all, path)
+		if resolved, err := resolvePath(path); err == nil && resolved != filepath.Clean(path) {
+			all = append(all, resolved)
+		}
+	}
+	return all
+}
+
+// applyEnvironmentFiles adds the variables of the environment files of the
+// container to its docker config. Variables set inline, or in the raw docker
+// config, take precedence over variables from files. Files are read in order,
+// with variables in later files overriding the same variables in earlier ones.
+// The files must have been checked with checkEnvironmentFiles
+func applyEnvironmentFiles(container *api.Container, config *docker.Config) engineError {
+	if len(container.EnvironmentFiles) == 0 {
+		return nil
+	}
+
+	variables := make(map[string]string)
+	for _, envFile := range container.EnvironmentFiles {
+		fileVariables, err := readEnvironmentFile(envFile)
+		if err != nil {
+			return err
+		}
+		for key, value := range fileVariables {
+			variables[key] = value
+		}
+	}
+
+	set := make(map[string]struct{})
+	for _, env := range config.Env {
+		set[strings.SplitN(env, "=", 2)[0]] = struct{}{}
+	}
+	keys := make([]string, 0, len(variables))
+	for key := range variables {
+		if _, ok := set[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		config.Env = append(config.Env, key+"="+variables[key])
+	}
+	return nil
+}
+
+func readEnvironmentFile(envFile api.EnvironmentFile) (map[string]string, engineError) {
+	data, err := ioutil.ReadFile(envFile.Value)
+	if err != nil {
+		return nil, EnvironmentFileError{"Unable to read environment file: " + err.Error()}
+	}
+	return parseEnvironmentFile(envFile.Value, data)
+}
+
+// parseEnvironmentFile parses the KEY=VALUE lines of an environment file.
+// Blank lines and lines starting with # are ignored, and values are used as
+// they are, without removing quotes
+func parseEnvironmentFile(name string, data []byte) (map[string]string, engineError) {
+	variables := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimLeft(strings.TrimSuffix(scanner.Text(), "\r"), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, EnvironmentFileError{"Malformed line " + strconv.Itoa(lineNumber) + " of environment file " + name + ": expected KEY=VALUE"}
+		}
+		variables[key] = parts[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, EnvironmentFileError{"Unable to read environment file " + name + ": " + err.Error()}
+	}
+	return variables, nil
+}
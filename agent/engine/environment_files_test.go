@@ -0,0 +1,264 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeEnvironmentFiles writes each of the contents to an environment file in
+// a temporary directory, which is removed by the returned function
+func writeEnvironmentFiles(t *testing.T, contents ...string) ([]api.EnvironmentFile, func()) {
+	dir, err := ioutil.TempDir("", "environment-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envFiles []api.EnvironmentFile
+	for i, content := range contents {
+		path := filepath.Join(dir, strconv.Itoa(i)+".env")
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		envFiles = append(envFiles, api.EnvironmentFile{Value: path, Type: api.EnvironmentFileTypeLocal})
+	}
+	return envFiles, func() { os.RemoveAll(dir) }
+}
+
+func TestApplyEnvironmentFilesPrecedence(t *testing.T) {
+	envFiles, cleanup := writeEnvironmentFiles(t,
+		"INLINE=from file\nFIRST=1\nSHARED=first\n",
+		"SHARED=second\nSECOND=2\n",
+	)
+	defer cleanup()
+
+	container := &api.Container{Name: "c", EnvironmentFiles: envFiles}
+	config := &docker.Config{Env: []string{"INLINE=inline"}}
+	if err := applyEnvironmentFiles(container, config); err != nil {
+		t.Fatal(err)
+	}
+
+	env := config.Env
+	sort.Strings(env)
+	assert.Equal(t, []string{"FIRST=1", "INLINE=inline", "SECOND=2", "SHARED=second"}, env)
+}
+
+func TestParseEnvironmentFileComments(t *testing.T) {
+	content := "# database settings\n\nDB_HOST=db.local\r\n  # indented comment\n\tDB_PORT=5432\nURL=http://host/?a=b#c\nEMPTY=\nQUOTED=\"kept\"\n"
+	variables, err := parseEnvironmentFile("test.env", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{
+		"DB_HOST": "db.local",
+		"DB_PORT": "5432",
+		"URL":     "http://host/?a=b#c",
+		"EMPTY":   "",
+		"QUOTED":  `"kept"`,
+	}, variables)
+}
+
+func TestParseEnvironmentFileMalformed(t *testing.T) {
+	for _, content := range []string{"KEY=value\nNOVALUE\n", "KEY=value\n=value\n", "KEY=value\nTWO WORDS=value\n"} {
+		_, err := parseEnvironmentFile("test.env", []byte(content))
+		if err == nil {
+			t.Errorf("Expected an error for %q", content)
+			continue
+		}
+		assert.Equal(t, "EnvironmentFileError", err.ErrorName())
+		assert.Contains(t, err.Error(), "Malformed line 2 of environment file test.env")
+	}
+}
+
+func TestCheckEnvironmentFiles(t *testing.T) {
+	local := func(path string) api.EnvironmentFile {
+		return api.EnvironmentFile{Value: path, Type: api.EnvironmentFileTypeLocal}
+	}
+	testCases := []struct {
+		name          string
+		envFile       api.EnvironmentFile
+		disabled      bool
+		allowedPaths  []string
+		deniedPaths   []string
+		expectedError string
+	}{
+		{"unsupported type", api.EnvironmentFile{Value: "arn:aws:s3:::bucket/app.env", Type: "s3"}, false, nil, nil, "Unsupported type"},
+		{"relative path", local("app.env"), false, nil, nil, "must be an absolute path"},
+		{"no policy", local("/etc/shadow"), false, nil, nil, ""},
+		{"bind mounts disabled", local("/opt/app/app.env"), true, nil, nil, "is not allowed on this instance"},
+		{"denied path", local("/etc/shadow"), false, nil, []string{"/etc"}, "is denied on this instance"},
+		{"outside allowed paths", local("/etc/shadow"), false, []string{"/opt/app"}, nil, "is not allowed on this instance"},
+		{"escaping allowed paths", local("/opt/app/../../etc/shadow"), false, []string{"/opt/app"}, nil, "is not allowed on this instance"},
+		{"under allowed paths", local("/opt/app/app.env"), false, []string{"/opt/app"}, nil, ""},
+		{"agent config", local("/etc/ecs/ecs.config"), false, nil, nil, "holds the config or data of the agent"},
+		{"agent config allowed", local("/etc/ecs/ecs.config"), false, []string{"/etc"}, nil, "holds the config or data of the agent"},
+		{"agent data", local("/data/ecs_agent_data.json"), false, nil, nil, "holds the config or data of the agent"},
+	}
+	for _, tc := range testCases {
+		cfg := defaultConfig
+		cfg.BindMountsDisabled = tc.disabled
+		cfg.BindMountAllowedPaths = tc.allowedPaths
+		cfg.BindMountDeniedPaths = tc.deniedPaths
+		cfg.LocalEnvironmentFilesEnabled = true
+		taskEngine := &DockerTaskEngine{cfg: &cfg}
+
+		// The files don't exist, as they aren't read when checked
+		err := taskEngine.checkEnvironmentFiles(&api.Container{Name: "c", EnvironmentFiles: []api.EnvironmentFile{tc.envFile}})
+		if tc.expectedError == "" {
+			assert.Nil(t, err, tc.name)
+			continue
+		}
+		if assert.NotNil(t, err, tc.name) {
+			assert.Equal(t, "EnvironmentFileError", err.ErrorName(), tc.name)
+			assert.Contains(t, err.Error(), tc.expectedError, tc.name)
+		}
+	}
+}
+
+func TestCheckEnvironmentFilesNotEnabled(t *testing.T) {
+	cfg := defaultConfig
+	taskEngine := &DockerTaskEngine{cfg: &cfg}
+
+	err := taskEngine.checkEnvironmentFiles(&api.Container{Name: "c", EnvironmentFiles: []api.EnvironmentFile{
+		{Value: "/opt/app/app.env", Type: api.EnvironmentFileTypeLocal},
+	}})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "EnvironmentFileError", err.ErrorName())
+		assert.Contains(t, err.Error(), "local environment files aren't enabled")
+	}
+}
+
+func TestCheckEnvironmentFilesSymlink(t *testing.T) {
+	envFiles, cleanup := writeEnvironmentFiles(t, "LEVEL=debug\n")
+	defer cleanup()
+	dir := filepath.Dir(envFiles[0].Value)
+	denied := filepath.Join(dir, "denied")
+	allowed := filepath.Join(dir, "allowed")
+	for _, subdir := range []string{denied, allowed} {
+		if err := os.Mkdir(subdir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Rename(envFiles[0].Value, filepath.Join(denied, "app.env")); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(allowed, "app.env")
+	if err := os.Symlink(filepath.Join(denied, "app.env"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultConfig
+	cfg.LocalEnvironmentFilesEnabled = true
+	cfg.BindMountAllowedPaths = []string{allowed}
+	taskEngine := &DockerTaskEngine{cfg: &cfg}
+
+	// The link is allowed, but the file it points to isn't
+	err := taskEngine.checkEnvironmentFiles(&api.Container{Name: "c", EnvironmentFiles: []api.EnvironmentFile{
+		{Value: link, Type: api.EnvironmentFileTypeLocal},
+	}})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "is not allowed on this instance")
+	}
+}
+
+func TestApplyEnvironmentFilesMissing(t *testing.T) {
+	container := &api.Container{Name: "c", EnvironmentFiles: []api.EnvironmentFile{
+		{Value: "/nonexistent/app.env", Type: api.EnvironmentFileTypeLocal},
+	}}
+	err := applyEnvironmentFiles(container, &docker.Config{})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "Unable to read environment file")
+	}
+}
+
+func TestCreateContainerDeniedEnvironmentFile(t *testing.T) {
+	cfg := defaultConfig
+	envFiles, cleanup := writeEnvironmentFiles(t, "LEVEL=debug\n")
+	defer cleanup()
+	cfg.BindMountDeniedPaths = []string{filepath.Dir(envFiles[0].Value)}
+	cfg.LocalEnvironmentFilesEnabled = true
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.EnvironmentFiles = envFiles
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil || metadata.Error.ErrorName() != "EnvironmentFileError" {
+		t.Errorf("Expected EnvironmentFileError, got: %v", metadata.Error)
+	}
+}
+
+func TestCreateContainerEnvironmentFiles(t *testing.T) {
+	cfg := defaultConfig
+	cfg.LocalEnvironmentFilesEnabled = true
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	envFiles, cleanup := writeEnvironmentFiles(t, "# settings\nLEVEL=debug\nMODE=file\n")
+	defer cleanup()
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.Environment = map[string]string{"MODE": "inline"}
+	sleepContainer.EnvironmentFiles = envFiles
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			env := config.Env
+			sort.Strings(env)
+			assert.Equal(t, []string{"LEVEL=debug", "MODE=inline"}, env, "Wrong environment")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+}
+
+func TestCreateContainerMalformedEnvironmentFile(t *testing.T) {
+	cfg := defaultConfig
+	cfg.LocalEnvironmentFilesEnabled = true
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	envFiles, cleanup := writeEnvironmentFiles(t, "LEVEL debug\n")
+	defer cleanup()
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.EnvironmentFiles = envFiles
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil || metadata.Error.ErrorName() != "EnvironmentFileError" {
+		t.Errorf("Expected EnvironmentFileError, got: %v", metadata.Error)
+	}
+}
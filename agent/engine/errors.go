@@ -61,6 +61,33 @@ func (err ContainerVanishedError) Error() string { return "No container matching
 // ErrorName returns the name of the error
 func (err ContainerVanishedError) ErrorName() string { return "ContainerVanishedError" }
 
+// UnavailableLoggingDriverError is a type for errors caused by a container
+// requesting a logging driver that isn't available on the instance
+type UnavailableLoggingDriverError struct {
+	driver string
+}
+
+func (err UnavailableLoggingDriverError) Error() string {
+	return "Logging driver " + err.driver + " is not available; set ECS_AVAILABLE_LOGGING_DRIVERS to allow it"
+}
+
+// ErrorName returns the name of the error
+func (err UnavailableLoggingDriverError) ErrorName() string { return "UnavailableLoggingDriverError" }
+
+// TaskEngineDrainingError is a type for errors caused by adding a new task
+// while the task engine is draining. The task may be added again once the
+// agent has restarted
+type TaskEngineDrainingError struct {
+	taskArn string
+}
+
+func (err TaskEngineDrainingError) Error() string {
+	return "Task engine is draining, not accepting new task " + err.taskArn
+}
+
+// ErrorName returns the name of the error
+func (err TaskEngineDrainingError) ErrorName() string { return "TaskEngineDrainingError" }
+
 // CannotXContainerError is a type for errors involving containers
 type CannotXContainerError struct {
 	transition string
@@ -74,6 +101,19 @@ func (err CannotXContainerError) ErrorName() string {
 	return "Cannot" + err.transition + "ContainerError"
 }
 
+// CannotPullContainerAuthError is a type for errors caused by failing to get
+// the credentials needed to pull a container's image
+type CannotPullContainerAuthError struct {
+	err error
+}
+
+func (err CannotPullContainerAuthError) Error() string {
+	return "Unable to get credentials to pull image: " + err.err.Error()
+}
+
+// ErrorName returns the name of the error
+func (err CannotPullContainerAuthError) ErrorName() string { return "CannotPullContainerAuthError" }
+
 // OutOfMemoryError is a type for errors caused by running out of memory
 type OutOfMemoryError struct{}
 
@@ -137,3 +177,226 @@ func (err TaskStoppedBeforePullBeginError) Error() string {
 func (TaskStoppedBeforePullBeginError) ErrorName() string {
 	return "TaskStoppedBeforePullBeginError"
 }
+
+// HostPortConflictError is a type for errors caused by a container mapping a
+// host port that is reserved on the instance or mapped by another container
+// of the task
+type HostPortConflictError struct {
+	msg string
+}
+
+func (err HostPortConflictError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err HostPortConflictError) ErrorName() string { return "HostPortConflictError" }
+
+// JSONFileLogOptionError is a type for errors caused by a container setting
+// json-file log rotation options that are invalid or above the limits set on
+// the instance
+type JSONFileLogOptionError struct {
+	msg string
+}
+
+func (err JSONFileLogOptionError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err JSONFileLogOptionError) ErrorName() string { return "JSONFileLogOptionError" }
+
+// ShmSizeLimitError is a type for errors caused by a container setting a
+// shared memory size above the limit set on the instance
+type ShmSizeLimitError struct {
+	msg string
+}
+
+func (err ShmSizeLimitError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err ShmSizeLimitError) ErrorName() string { return "ShmSizeLimitError" }
+
+// UnsupportedDockerVersionError is a type for errors caused by a container
+// using a feature the remote api version used with the docker daemon doesn't
+// support
+type UnsupportedDockerVersionError struct {
+	msg string
+}
+
+func (err UnsupportedDockerVersionError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err UnsupportedDockerVersionError) ErrorName() string { return "UnsupportedDockerVersionError" }
+
+// EnvironmentFileError is a type for errors caused by an environment file of a
+// container that can't be read or is malformed
+type EnvironmentFileError struct {
+	msg string
+}
+
+func (err EnvironmentFileError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err EnvironmentFileError) ErrorName() string { return "EnvironmentFileError" }
+
+// GPUAssignmentError is a type for errors caused by a task requiring GPUs that
+// can't be assigned to it
+type GPUAssignmentError struct {
+	msg string
+}
+
+func (err GPUAssignmentError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err GPUAssignmentError) ErrorName() string { return "GPUAssignmentError" }
+
+// HostResourceError is a type for errors caused by a task requiring more cpu
+// or memory than is left on the instance
+type HostResourceError struct {
+	msg string
+}
+
+func (err HostResourceError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err HostResourceError) ErrorName() string { return "HostResourceError" }
+
+// CapabilityNotAllowedError is a type for errors caused by a container adding a
+// capability that isn't allowed on the instance
+type CapabilityNotAllowedError struct {
+	msg string
+}
+
+func (err CapabilityNotAllowedError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err CapabilityNotAllowedError) ErrorName() string { return "CapabilityNotAllowedError" }
+
+// SysctlNotAllowedError is a type for errors caused by a container setting a
+// kernel parameter that isn't allowed on the instance
+type SysctlNotAllowedError struct {
+	msg string
+}
+
+func (err SysctlNotAllowedError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err SysctlNotAllowedError) ErrorName() string { return "SysctlNotAllowedError" }
+
+// SecurityOptionNotAllowedError is a type for errors caused by a container
+// setting a security option that isn't allowed on the instance
+type SecurityOptionNotAllowedError struct {
+	msg string
+}
+
+func (err SecurityOptionNotAllowedError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err SecurityOptionNotAllowedError) ErrorName() string { return "SecurityOptionNotAllowedError" }
+
+// CannotLoadSeccompProfileError is a type for errors caused by a container
+// naming a seccomp profile that can't be read from the instance
+type CannotLoadSeccompProfileError struct {
+	msg string
+}
+
+func (err CannotLoadSeccompProfileError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err CannotLoadSeccompProfileError) ErrorName() string { return "CannotLoadSeccompProfileError" }
+
+// AppArmorProfileNotFoundError is a type for errors caused by a container
+// using an AppArmor profile that isn't loaded on the instance
+type AppArmorProfileNotFoundError struct {
+	msg string
+}
+
+func (err AppArmorProfileNotFoundError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err AppArmorProfileNotFoundError) ErrorName() string { return "AppArmorProfileNotFoundError" }
+
+// DeviceNotFoundError is a type for errors caused by a container exposing a
+// host device that doesn't exist on the instance
+type DeviceNotFoundError struct {
+	msg string
+}
+
+func (err DeviceNotFoundError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err DeviceNotFoundError) ErrorName() string { return "DeviceNotFoundError" }
+
+// CannotCreateVolumeError is a type for errors caused by failing to create the
+// docker volume a container mounts
+type CannotCreateVolumeError struct {
+	msg string
+}
+
+func (err CannotCreateVolumeError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err CannotCreateVolumeError) ErrorName() string { return "CannotCreateVolumeError" }
+
+// BindMountNotAllowedError is a type for errors caused by a container bind
+// mounting a host path that isn't allowed on the instance
+type BindMountNotAllowedError struct {
+	msg string
+}
+
+func (err BindMountNotAllowedError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err BindMountNotAllowedError) ErrorName() string { return "BindMountNotAllowedError" }
+
+// PrivilegedNotAllowedError is a type for errors caused by a container running
+// privileged on an instance where privileged containers are disabled
+type PrivilegedNotAllowedError struct {
+	msg string
+}
+
+func (err PrivilegedNotAllowedError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err PrivilegedNotAllowedError) ErrorName() string { return "PrivilegedNotAllowedError" }
+
+// HostNamespaceNotAllowedError is a type for errors caused by a container
+// sharing the PID or IPC namespace of an instance where that's disabled
+type HostNamespaceNotAllowedError struct {
+	msg string
+}
+
+func (err HostNamespaceNotAllowedError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err HostNamespaceNotAllowedError) ErrorName() string { return "HostNamespaceNotAllowedError" }
+
+// ContainerRestartLimitError is a type for errors caused by a crashed
+// container having been restarted too often to restart it again
+type ContainerRestartLimitError struct {
+	msg string
+}
+
+func (err ContainerRestartLimitError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err ContainerRestartLimitError) ErrorName() string { return "ContainerRestartLimitError" }
+
+// UnhealthyDependencyError is a type for errors caused by a container being
+// stopped because a container it depends on to be healthy turned unhealthy
+type UnhealthyDependencyError struct {
+	msg string
+}
+
+func (err UnhealthyDependencyError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err UnhealthyDependencyError) ErrorName() string { return "UnhealthyDependencyError" }
+
+// CannotResolveSecretError is a type for errors caused by failing to fetch the
+// value of a secret of a container
+type CannotResolveSecretError struct {
+	msg string
+}
+
+func (err CannotResolveSecretError) Error() string { return err.msg }
+
+// ErrorName returns the name of the error
+func (err CannotResolveSecretError) ErrorName() string { return "CannotResolveSecretError" }
@@ -0,0 +1,185 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// gpuDeviceGlob matches the device files of NVIDIA GPUs
+	gpuDeviceGlob = "/dev/nvidia[0-9]*"
+	// gpuDevicePermissions are the cgroup permissions containers get for
+	// their GPU devices
+	gpuDevicePermissions = "rwm"
+)
+
+// gpuControlDevices are the NVIDIA devices containers using GPUs need in
+// addition to their GPUs
+var gpuControlDevices = []string{"/dev/nvidiactl", "/dev/nvidia-uvm", "/dev/nvidia-uvm-tools"}
+
+// gpuManager keeps track of which GPUs of the instance are assigned to which
+// task, so that each GPU is only used by one task at a time
+type gpuManager struct {
+	// devices are the paths of the GPU devices of the instance
+	devices []string
+	// controlDevices are the paths of the control devices that exist on the
+	// instance
+	controlDevices []string
+	// assigned maps the GPU devices that are in use to the task using them
+	assigned map[string]string
+	lock     sync.Mutex
+}
+
+func newGPUManager(devices []string, controlDevices []string) *gpuManager {
+	sorted := append([]string(nil), devices...)
+	sort.Strings(sorted)
+	return &gpuManager{
+		devices:        sorted,
+		controlDevices: controlDevices,
+		assigned:       make(map[string]string),
+	}
+}
+
+// discoverGPUs returns the GPU devices and control devices of the instance
+func discoverGPUs() ([]string, []string) {
+	devices, err := filepath.Glob(gpuDeviceGlob)
+	if err != nil {
+		seelog.Warnf("Unable to find GPU devices: %v", err)
+	}
+	var controlDevices []string
+	for _, device := range gpuControlDevices {
+		if _, err := os.Stat(device); err == nil {
+			controlDevices = append(controlDevices, device)
+		}
+	}
+	seelog.Infof("Found %d GPU devices", len(devices))
+	return devices, controlDevices
+}
+
+// taskGPUCount returns the number of GPUs the containers of the task require
+func taskGPUCount(task *api.Task) (int, engineError) {
+	total := 0
+	for _, container := range task.Containers {
+		count, err := container.GPUCount()
+		if err != nil {
+			return 0, GPUAssignmentError{err.Error()}
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// assign assigns free GPUs to the containers of the task that require them,
+// or returns an error if there aren't enough free GPUs. Tasks that already
+// have GPUs assigned keep them
+func (manager *gpuManager) assign(task *api.Task) engineError {
+	count, err := taskGPUCount(task)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	for _, container := range task.Containers {
+		if len(container.GPUDevices) > 0 {
+			return nil
+		}
+	}
+	free := manager.free()
+	if len(free) < count {
+		return GPUAssignmentError{"Task " + task.Arn + " requires " + strconv.Itoa(count) + " GPUs, but only " + strconv.Itoa(len(free)) + " of the " + strconv.Itoa(len(manager.devices)) + " GPUs of the instance are free"}
+	}
+	for _, container := range task.Containers {
+		containerCount, _ := container.GPUCount()
+		container.GPUDevices = free[:containerCount]
+		free = free[containerCount:]
+		for _, device := range container.GPUDevices {
+			manager.assigned[device] = task.Arn
+		}
+	}
+	return nil
+}
+
+// check returns an error if the task requires more GPUs than are free,
+// without assigning them
+func (manager *gpuManager) check(task *api.Task) engineError {
+	count, err := taskGPUCount(task)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	if free := len(manager.free()); free < count {
+		return GPUAssignmentError{"Task " + task.Arn + " requires " + strconv.Itoa(count) + " GPUs, but only " + strconv.Itoa(free) + " of the " + strconv.Itoa(len(manager.devices)) + " GPUs of the instance are free"}
+	}
+	return nil
+}
+
+// restore records the GPUs assigned to the containers of a task that was
+// loaded from saved state as in use
+func (manager *gpuManager) restore(task *api.Task) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	for _, container := range task.Containers {
+		for _, device := range container.GPUDevices {
+			manager.assigned[device] = task.Arn
+		}
+	}
+}
+
+// release frees the GPUs assigned to the task
+func (manager *gpuManager) release(taskArn string) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	for device, arn := range manager.assigned {
+		if arn == taskArn {
+			delete(manager.assigned, device)
+		}
+	}
+}
+
+// free returns the GPU devices not assigned to any task. It must be called
+// with the lock held
+func (manager *gpuManager) free() []string {
+	var free []string
+	for _, device := range manager.devices {
+		if _, ok := manager.assigned[device]; !ok {
+			free = append(free, device)
+		}
+	}
+	return free
+}
+
+// dockerDevices returns the devices to add to the host config of the
+// container for the GPUs assigned to it
+func (manager *gpuManager) dockerDevices(container *api.Container) []docker.Device {
+	if len(container.GPUDevices) == 0 {
+		return nil
+	}
+	var devices []docker.Device
+	for _, path := range append(append([]string(nil), container.GPUDevices...), manager.controlDevices...) {
+		devices = append(devices, docker.Device{PathOnHost: path, PathInContainer: path, CgroupPermissions: gpuDevicePermissions})
+	}
+	return devices
+}
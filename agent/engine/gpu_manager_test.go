@@ -0,0 +1,173 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+)
+
+func gpuTask(arn string, gpus ...string) *api.Task {
+	task := &api.Task{Arn: arn}
+	for i, count := range gpus {
+		container := &api.Container{Name: "c" + string('0'+rune(i))}
+		if count != "" {
+			container.ResourceRequirements = []api.ResourceRequirement{{Type: api.ResourceTypeGPU, Value: count}}
+		}
+		task.Containers = append(task.Containers, container)
+	}
+	return task
+}
+
+func TestGPUManagerAssignAndRelease(t *testing.T) {
+	manager := newGPUManager([]string{"/dev/nvidia2", "/dev/nvidia0", "/dev/nvidia1"}, []string{"/dev/nvidiactl"})
+
+	first := gpuTask("first", "1", "", "1")
+	if err := manager.assign(first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(first.Containers[0].GPUDevices, []string{"/dev/nvidia0"}) || len(first.Containers[1].GPUDevices) != 0 || !reflect.DeepEqual(first.Containers[2].GPUDevices, []string{"/dev/nvidia1"}) {
+		t.Errorf("Unexpected GPU assignment: %v %v %v", first.Containers[0].GPUDevices, first.Containers[1].GPUDevices, first.Containers[2].GPUDevices)
+	}
+
+	second := gpuTask("second", "1")
+	if err := manager.assign(second); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(second.Containers[0].GPUDevices, []string{"/dev/nvidia2"}) {
+		t.Errorf("Expected the remaining GPU to be assigned, got: %v", second.Containers[0].GPUDevices)
+	}
+
+	manager.release("first")
+	third := gpuTask("third", "2")
+	if err := manager.assign(third); err != nil {
+		t.Fatalf("Expected the released GPUs to be assigned, got: %v", err)
+	}
+	if !reflect.DeepEqual(third.Containers[0].GPUDevices, []string{"/dev/nvidia0", "/dev/nvidia1"}) {
+		t.Errorf("Expected the released GPUs to be assigned, got: %v", third.Containers[0].GPUDevices)
+	}
+}
+
+func TestGPUManagerOversubscription(t *testing.T) {
+	manager := newGPUManager([]string{"/dev/nvidia0", "/dev/nvidia1"}, nil)
+	if err := manager.assign(gpuTask("first", "1")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	task := gpuTask("second", "1", "1")
+	if err := manager.check(task); err == nil || err.ErrorName() != "GPUAssignmentError" {
+		t.Errorf("Expected GPUAssignmentError, got: %v", err)
+	}
+	err := manager.assign(task)
+	if err == nil || err.ErrorName() != "GPUAssignmentError" {
+		t.Fatalf("Expected GPUAssignmentError, got: %v", err)
+	}
+	for _, container := range task.Containers {
+		if len(container.GPUDevices) != 0 {
+			t.Errorf("Expected no GPUs to be assigned to container %s, got: %v", container.Name, container.GPUDevices)
+		}
+	}
+
+	// The rejected task doesn't hold the free GPU
+	if err := manager.assign(gpuTask("third", "1")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestGPUManagerInvalidRequirement(t *testing.T) {
+	manager := newGPUManager([]string{"/dev/nvidia0"}, nil)
+	for _, value := range []string{"0", "-1", "one"} {
+		if err := manager.assign(gpuTask("arn", value)); err == nil || err.ErrorName() != "GPUAssignmentError" {
+			t.Errorf("%s: expected GPUAssignmentError, got: %v", value, err)
+		}
+	}
+}
+
+func TestGPUManagerRestore(t *testing.T) {
+	manager := newGPUManager([]string{"/dev/nvidia0", "/dev/nvidia1"}, nil)
+	restored := gpuTask("restored", "1")
+	restored.Containers[0].GPUDevices = []string{"/dev/nvidia0"}
+	manager.restore(restored)
+
+	task := gpuTask("arn", "1")
+	if err := manager.assign(task); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(task.Containers[0].GPUDevices, []string{"/dev/nvidia1"}) {
+		t.Errorf("Expected the GPU that isn't restored to be assigned, got: %v", task.Containers[0].GPUDevices)
+	}
+	if err := manager.check(gpuTask("other", "1")); err == nil {
+		t.Error("Expected no GPUs to be free")
+	}
+}
+
+func TestAssignGPUsInsufficientGPUs(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.gpus = newGPUManager([]string{"/dev/nvidia0"}, nil)
+
+	task := gpuTask("arn", "2", "")
+	task.SetDesiredStatus(api.TaskRunning)
+	taskEngine.assignGPUs(task)
+
+	if task.GetDesiredStatus() != api.TaskStopped {
+		t.Errorf("Expected the task to be stopped, got: %s", task.GetDesiredStatus())
+	}
+	if err := task.Containers[0].ApplyingError; err == nil || err.Name != "GPUAssignmentError" {
+		t.Errorf("Expected GPUAssignmentError, got: %v", err)
+	}
+	if task.Containers[1].ApplyingError != nil {
+		t.Errorf("Unexpected error for the container without GPUs: %v", task.Containers[1].ApplyingError)
+	}
+}
+
+func TestCreateContainerGPUDevices(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.gpus = newGPUManager([]string{"/dev/nvidia0"}, []string{"/dev/nvidiactl"})
+
+	task := gpuTask("arn", "1")
+	task.Family = "family"
+	task.Version = "1"
+	task.Containers[0].Image = "image"
+	if err := taskEngine.gpus.assign(task); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	taskEngine.state.AddTask(task)
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			expected := []docker.Device{
+				{PathOnHost: "/dev/nvidia0", PathInContainer: "/dev/nvidia0", CgroupPermissions: "rwm"},
+				{PathOnHost: "/dev/nvidiactl", PathInContainer: "/dev/nvidiactl", CgroupPermissions: "rwm"},
+			}
+			if !reflect.DeepEqual(hostConfig.Devices, expected) {
+				t.Errorf("Expected devices %v, got: %v", expected, hostConfig.Devices)
+			}
+		}).Return(DockerContainerMetadata{DockerID: "id"})
+
+	metadata := taskEngine.createContainer(task, task.Containers[0])
+	if metadata.Error != nil {
+		t.Errorf("Unexpected error: %v", metadata.Error)
+	}
+}
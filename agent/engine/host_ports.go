@@ -0,0 +1,84 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+// hostPort returns the key identifying a static host port; the same port
+// number may be used once for tcp and once for udp
+func hostPort(port uint16, protocol api.TransportProtocol) string {
+	return fmt.Sprintf("%d/%s", port, protocol.String())
+}
+
+// reservedHostPorts returns the static host ports that may not be mapped by
+// container, along with a description of what holds each of them. These are
+// the ports reserved in the config and the ports mapped by other containers
+// managed by the engine that have been created and haven't stopped, or that
+// belong to tasks that are still to be launched. Containers that map host
+// port 0 get a port picked by docker and never hold a static port. Unless
+// includeStopping is set, the ports of tasks ECS has asked to stop are left
+// out, as ECS has already freed them for tasks that are yet to start
+func (engine *DockerTaskEngine) reservedHostPorts(container *api.Container, includeStopping bool) map[string]string {
+	reserved := make(map[string]string)
+	for _, port := range engine.cfg.ReservedPorts {
+		reserved[hostPort(port, api.TransportProtocolTCP)] = "reserved on the instance"
+	}
+	for _, port := range engine.cfg.ReservedPortsUDP {
+		reserved[hostPort(port, api.TransportProtocolUDP)] = "reserved on the instance"
+	}
+
+	for _, task := range engine.state.AllTasks() {
+		if task.GetKnownStatus().Terminal() {
+			continue
+		}
+		if !includeStopping && task.GetDesiredStatus() == api.TaskStopped {
+			continue
+		}
+		for _, other := range task.Containers {
+			if other == container || other.KnownTerminal() {
+				continue
+			}
+			if other.GetKnownStatus() < api.ContainerCreated && task.GetDesiredStatus().Terminal() {
+				continue
+			}
+			for _, binding := range other.Ports {
+				if binding.HostPort == 0 {
+					continue
+				}
+				reserved[hostPort(binding.HostPort, binding.Protocol)] = "mapped by container " + other.Name + " of task " + task.Arn
+			}
+		}
+	}
+	return reserved
+}
+
+// hostPortConflicts returns an error for each static host port of container
+// that is in reserved, which maps host ports to what holds them
+func hostPortConflicts(container *api.Container, reserved map[string]string) []HostPortConflictError {
+	var conflicts []HostPortConflictError
+	for _, binding := range container.Ports {
+		if binding.HostPort == 0 {
+			continue
+		}
+		port := hostPort(binding.HostPort, binding.Protocol)
+		if holder, ok := reserved[port]; ok {
+			conflicts = append(conflicts, HostPortConflictError{"Host port " + port + " is " + holder})
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,98 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+func portTask(arn string, status api.ContainerStatus, ports ...api.PortBinding) *api.Task {
+	container := &api.Container{Name: "c", Image: "image", Ports: ports}
+	container.SetKnownStatus(status)
+	task := &api.Task{Arn: arn, Containers: []*api.Container{container}}
+	task.SetKnownStatus(status.TaskStatus())
+	return task
+}
+
+func TestHostPortConflicts(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ReservedPorts = []uint16{22}
+	cfg.ReservedPortsUDP = []uint16{53}
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	taskEngine.state.AddTask(portTask("running", api.ContainerRunning,
+		api.PortBinding{ContainerPort: 80, HostPort: 8080, Protocol: api.TransportProtocolTCP},
+		api.PortBinding{ContainerPort: 80, HostPort: 9090, Protocol: api.TransportProtocolUDP},
+		api.PortBinding{ContainerPort: 443},
+	))
+	taskEngine.state.AddTask(portTask("stopped", api.ContainerStopped,
+		api.PortBinding{ContainerPort: 80, HostPort: 7070},
+	))
+	taskEngine.state.AddTask(portTask("pending", api.ContainerPulled,
+		api.PortBinding{ContainerPort: 80, HostPort: 6060},
+	))
+	stopping := portTask("stopping", api.ContainerPulled,
+		api.PortBinding{ContainerPort: 80, HostPort: 5555},
+	)
+	stopping.SetDesiredStatus(api.TaskStopped)
+	taskEngine.state.AddTask(stopping)
+
+	testCases := []struct {
+		name     string
+		binding  api.PortBinding
+		conflict bool
+	}{
+		{"same tcp port as running task", api.PortBinding{HostPort: 8080, Protocol: api.TransportProtocolTCP}, true},
+		{"udp port mapped by running task as tcp", api.PortBinding{HostPort: 8080, Protocol: api.TransportProtocolUDP}, false},
+		{"same udp port as running task", api.PortBinding{HostPort: 9090, Protocol: api.TransportProtocolUDP}, true},
+		{"tcp port mapped by running task as udp", api.PortBinding{HostPort: 9090, Protocol: api.TransportProtocolTCP}, false},
+		{"dynamic port", api.PortBinding{HostPort: 0}, false},
+		{"port of stopped task", api.PortBinding{HostPort: 7070}, false},
+		{"port of task that hasn't created its container", api.PortBinding{HostPort: 6060}, true},
+		{"port of stopping task that hasn't created its container", api.PortBinding{HostPort: 5555}, false},
+		{"unused port", api.PortBinding{HostPort: 5050}, false},
+		{"reserved tcp port", api.PortBinding{HostPort: 22, Protocol: api.TransportProtocolTCP}, true},
+		{"reserved tcp port as udp", api.PortBinding{HostPort: 22, Protocol: api.TransportProtocolUDP}, false},
+		{"reserved udp port", api.PortBinding{HostPort: 53, Protocol: api.TransportProtocolUDP}, true},
+		{"reserved udp port as tcp", api.PortBinding{HostPort: 53, Protocol: api.TransportProtocolTCP}, false},
+	}
+	for _, tc := range testCases {
+		container := &api.Container{Name: "new", Ports: []api.PortBinding{tc.binding}}
+		conflicts := hostPortConflicts(container, taskEngine.reservedHostPorts(container, true))
+		if conflict := len(conflicts) > 0; conflict != tc.conflict {
+			t.Errorf("%s: expected conflict %v, got: %v", tc.name, tc.conflict, conflicts)
+		}
+	}
+}
+
+func TestCreateContainerHostPortConflict(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	taskEngine.state.AddTask(portTask("running", api.ContainerRunning, api.PortBinding{ContainerPort: 80, HostPort: 8080}))
+	task := portTask("new", api.ContainerStatusNone, api.PortBinding{ContainerPort: 80, HostPort: 8080})
+	taskEngine.state.AddTask(task)
+
+	// The client mock fails the test if the container is created
+	metadata := taskEngine.createContainer(task, task.Containers[0])
+	if metadata.Error == nil || metadata.Error.ErrorName() != "HostPortConflictError" {
+		t.Errorf("Expected HostPortConflictError, got: %v", metadata.Error)
+	}
+}
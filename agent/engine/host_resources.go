@@ -0,0 +1,161 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/cihub/seelog"
+	"github.com/docker/docker/pkg/system"
+)
+
+// taskResources are the cpu units and MiB of memory a task reserves on the
+// instance
+type taskResources struct {
+	cpu    int64
+	memory int64
+}
+
+// hostResourceManager keeps track of the cpu and memory of the instance
+// reserved by the tasks the engine has accepted, so that tasks that don't fit
+// are rejected before any of their containers are pulled or created
+type hostResourceManager struct {
+	// cpu and memory are the cpu units and MiB of memory of the instance
+	// that tasks may reserve. Zero means the amount is unknown and isn't
+	// checked
+	cpu    int64
+	memory int64
+	// reserved maps the arns of the tasks holding resources to the tasks
+	reserved map[string]*api.Task
+	lock     sync.Mutex
+}
+
+func newHostResourceManager(cpu, memory int64) *hostResourceManager {
+	return &hostResourceManager{
+		cpu:      cpu,
+		memory:   memory,
+		reserved: make(map[string]*api.Task),
+	}
+}
+
+// discoverHostResources returns the cpu units and MiB of memory of the
+// instance available to tasks, as registered with ECS: what the instance has
+// less what is reserved for things other than tasks
+func discoverHostResources(cfg *config.Config) (int64, int64) {
+	cpu := int64(runtime.NumCPU()*1024) - int64(cfg.ReservedCPU)
+	if cpu <= 0 {
+		seelog.Warnf("Reserved cpu %d leaves no cpu units of the instance to tasks, tasks' cpu won't be checked against it", cfg.ReservedCPU)
+		cpu = 0
+	}
+	memInfo, err := system.ReadMemInfo()
+	if err != nil {
+		seelog.Warnf("Unable to get memory info, tasks' memory won't be checked against it: %v", err)
+		return cpu, 0
+	}
+	memory := memInfo.MemTotal/1024/1024 - int64(cfg.ReservedMemory)
+	if memory <= 0 {
+		return cpu, 0
+	}
+	return cpu, memory
+}
+
+// resourcesOf returns the resources the task reserves: its task level limits
+// if set, or what its containers reserve otherwise
+func resourcesOf(task *api.Task) taskResources {
+	cpu, memory := task.ContainersResources()
+	resources := taskResources{cpu: int64(cpu), memory: int64(memory)}
+	if task.Cpu > 0 {
+		resources.cpu = int64(task.Cpu)
+	}
+	if task.Memory > 0 {
+		resources.memory = int64(task.Memory)
+	}
+	return resources
+}
+
+// reserve records the resources of the task as held, or returns an error if
+// there isn't enough cpu or memory left for them. Tasks that already hold
+// their resources keep them
+func (manager *hostResourceManager) reserve(task *api.Task) engineError {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	if _, ok := manager.reserved[task.Arn]; ok {
+		return nil
+	}
+	if err := manager.fits(task.Arn, resourcesOf(task)); err != nil {
+		return err
+	}
+	manager.reserved[task.Arn] = task
+	return nil
+}
+
+// check returns an error if there isn't enough cpu or memory left for the
+// task, without reserving them
+func (manager *hostResourceManager) check(task *api.Task) engineError {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	return manager.fits(task.Arn, resourcesOf(task))
+}
+
+// restore records the resources of a task that was loaded from saved state as
+// held, even if they exceed what's left
+func (manager *hostResourceManager) restore(task *api.Task) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	manager.reserved[task.Arn] = task
+}
+
+// release frees the resources held by the task
+func (manager *hostResourceManager) release(taskArn string) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	delete(manager.reserved, taskArn)
+}
+
+// fits returns an error if resources exceed what isn't held by tasks other
+// than taskArn. Tasks ECS has asked to stop are left out even though they
+// hold their resources until they stop: ECS has already freed their capacity
+// for other tasks, which wait for them to stop through their start sequence
+// number. It must be called with the lock held
+func (manager *hostResourceManager) fits(taskArn string, resources taskResources) engineError {
+	var held taskResources
+	for arn, task := range manager.reserved {
+		if arn == taskArn || task.GetDesiredStatus() == api.TaskStopped {
+			continue
+		}
+		reserved := resourcesOf(task)
+		held.cpu += reserved.cpu
+		held.memory += reserved.memory
+	}
+	if manager.cpu > 0 && resources.cpu > manager.cpu-held.cpu {
+		return HostResourceError{"Task " + taskArn + " requires " + strconv.FormatInt(resources.cpu, 10) + " cpu units, but only " + strconv.FormatInt(remaining(manager.cpu, held.cpu), 10) + " of the " + strconv.FormatInt(manager.cpu, 10) + " cpu units of the instance are free"}
+	}
+	if manager.memory > 0 && resources.memory > manager.memory-held.memory {
+		return HostResourceError{"Task " + taskArn + " requires " + strconv.FormatInt(resources.memory, 10) + " MiB of memory, but only " + strconv.FormatInt(remaining(manager.memory, held.memory), 10) + " of the " + strconv.FormatInt(manager.memory, 10) + " MiB of memory of the instance are free"}
+	}
+	return nil
+}
+
+// remaining returns how much of total isn't held; restored tasks may hold
+// more than the total
+func remaining(total, held int64) int64 {
+	if held > total {
+		return 0
+	}
+	return total - held
+}
@@ -0,0 +1,303 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"math"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/docker/docker/pkg/system"
+)
+
+func resourceTask(arn string, cpu, memory uint) *api.Task {
+	return &api.Task{
+		Arn: arn,
+		Containers: []*api.Container{
+			{Name: "c0", Cpu: cpu, Memory: memory},
+		},
+	}
+}
+
+func TestResourcesOf(t *testing.T) {
+	task := &api.Task{
+		Containers: []*api.Container{
+			{Name: "limit", Cpu: 256, Memory: 512, MemoryReservation: 128},
+			{Name: "reservation", Cpu: 128, MemoryReservation: 256},
+			{Name: "internal", Cpu: 1024, Memory: 1024, IsInternal: true},
+		},
+	}
+	if resources := resourcesOf(task); resources != (taskResources{cpu: 384, memory: 768}) {
+		t.Errorf("Expected the resources of the containers, got: %+v", resources)
+	}
+
+	task.Cpu = 1024
+	task.Memory = 2048
+	if resources := resourcesOf(task); resources != (taskResources{cpu: 1024, memory: 2048}) {
+		t.Errorf("Expected the resources of the task, got: %+v", resources)
+	}
+}
+
+func TestDiscoverHostResourcesReserved(t *testing.T) {
+	memInfo, err := system.ReadMemInfo()
+	if err != nil {
+		t.Skip("Unable to get memory info:", err)
+	}
+	totalCPU := int64(runtime.NumCPU() * 1024)
+	totalMemory := memInfo.MemTotal / 1024 / 1024
+
+	cpu, memory := discoverHostResources(&config.Config{ReservedCPU: 256, ReservedMemory: 128})
+	if cpu != totalCPU-256 {
+		t.Errorf("Expected the reserved cpu to be left out, got %d of %d", cpu, totalCPU)
+	}
+	if memory != totalMemory-128 {
+		t.Errorf("Expected the reserved memory to be left out, got %d of %d", memory, totalMemory)
+	}
+
+	// Tasks can't use the reserved resources
+	manager := newHostResourceManager(cpu, memory)
+	if err := manager.check(resourceTask("arn", uint(totalCPU), 0)); err == nil {
+		t.Error("Expected the reserved cpu not to be available to tasks")
+	}
+	if err := manager.check(resourceTask("arn", uint(cpu), uint(memory))); err != nil {
+		t.Errorf("Expected the unreserved resources to be available to tasks, got: %v", err)
+	}
+
+	if totalCPU <= math.MaxUint16 {
+		cpu, _ = discoverHostResources(&config.Config{ReservedCPU: uint16(totalCPU)})
+		if cpu != 0 {
+			t.Errorf("Expected cpu not to be checked when all of it is reserved, got: %d", cpu)
+		}
+	}
+}
+
+func TestHostResourceManagerReserveAndRelease(t *testing.T) {
+	manager := newHostResourceManager(2048, 1024)
+
+	if err := manager.reserve(resourceTask("first", 1024, 512)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := manager.reserve(resourceTask("second", 1024, 512)); err != nil {
+		t.Fatalf("Expected the task filling the instance to fit, got: %v", err)
+	}
+	// Reserving again doesn't count the task twice
+	if err := manager.reserve(resourceTask("second", 1024, 512)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	manager.release("first")
+	if err := manager.reserve(resourceTask("third", 1024, 512)); err != nil {
+		t.Errorf("Expected the released resources to be reserved, got: %v", err)
+	}
+}
+
+func TestHostResourceManagerNoFit(t *testing.T) {
+	manager := newHostResourceManager(2048, 1024)
+	if err := manager.reserve(resourceTask("first", 1024, 512)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		task *api.Task
+	}{
+		{"cpu", resourceTask("cpu", 1025, 0)},
+		{"memory", resourceTask("memory", 0, 513)},
+		{"task cpu", &api.Task{Arn: "taskCpu", Cpu: 2048}},
+		{"task memory", &api.Task{Arn: "taskMemory", Memory: 1024}},
+	}
+	for _, tc := range testCases {
+		if err := manager.check(tc.task); err == nil || err.ErrorName() != "HostResourceError" {
+			t.Errorf("%s: expected HostResourceError, got: %v", tc.name, err)
+		}
+		if err := manager.reserve(tc.task); err == nil || err.ErrorName() != "HostResourceError" {
+			t.Errorf("%s: expected HostResourceError, got: %v", tc.name, err)
+		}
+	}
+
+	// The rejected tasks don't hold any resources
+	if err := manager.reserve(resourceTask("second", 1024, 512)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestHostResourceManagerUnknownCapacity(t *testing.T) {
+	manager := newHostResourceManager(1024, 0)
+	if err := manager.reserve(resourceTask("arn", 512, 1<<20)); err != nil {
+		t.Errorf("Expected memory not to be checked, got: %v", err)
+	}
+}
+
+func TestHostResourceManagerRestore(t *testing.T) {
+	manager := newHostResourceManager(1024, 1024)
+	manager.restore(resourceTask("restored", 2048, 512))
+
+	if err := manager.check(resourceTask("arn", 1, 0)); err == nil {
+		t.Error("Expected no cpu to be free")
+	}
+	manager.release("restored")
+	if err := manager.check(resourceTask("arn", 1024, 1024)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestHostResourceManagerConcurrentReserve(t *testing.T) {
+	manager := newHostResourceManager(10*1024, 0)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var reserved []string
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(arn string) {
+			defer wg.Done()
+			if err := manager.reserve(resourceTask(arn, 1024, 0)); err == nil {
+				lock.Lock()
+				reserved = append(reserved, arn)
+				lock.Unlock()
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+	if len(reserved) != 10 {
+		t.Fatalf("Expected 10 tasks to fit, got: %d", len(reserved))
+	}
+
+	for _, arn := range reserved {
+		wg.Add(1)
+		go func(arn string) {
+			defer wg.Done()
+			manager.release(arn)
+		}(arn)
+	}
+	wg.Wait()
+	if err := manager.check(resourceTask("arn", 10*1024, 0)); err != nil {
+		t.Errorf("Expected all resources to be free, got: %v", err)
+	}
+}
+
+func TestReserveHostResourcesInsufficientResources(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.hostResources = newHostResourceManager(1024, 1024)
+
+	task := resourceTask("arn", 512, 2048)
+	task.SetDesiredStatus(api.TaskRunning)
+	taskEngine.reserveHostResources(task)
+
+	if task.GetDesiredStatus() != api.TaskStopped {
+		t.Errorf("Expected the task to be stopped, got: %s", task.GetDesiredStatus())
+	}
+	if err := task.Containers[0].ApplyingError; err == nil || err.Name != "HostResourceError" {
+		t.Errorf("Expected HostResourceError, got: %v", err)
+	}
+	if err := taskEngine.hostResources.check(resourceTask("other", 1024, 1024)); err != nil {
+		t.Errorf("Expected the rejected task not to hold resources, got: %v", err)
+	}
+}
+
+func TestReserveHostResourcesHostPortOfPendingTask(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.hostResources = newHostResourceManager(4096, 4096)
+
+	// A task that was accepted but hasn't created its container yet holds
+	// its host port
+	pending := portTask("pending", api.ContainerStatusNone, api.PortBinding{ContainerPort: 80, HostPort: 8080})
+	pending.SetDesiredStatus(api.TaskRunning)
+	taskEngine.reserveHostResources(pending)
+	if pending.GetDesiredStatus() != api.TaskRunning {
+		t.Fatalf("Expected the task to fit, got: %v", pending.Containers[0].ApplyingError)
+	}
+	taskEngine.state.AddTask(pending)
+
+	task := portTask("new", api.ContainerStatusNone, api.PortBinding{ContainerPort: 80, HostPort: 8080})
+	task.SetDesiredStatus(api.TaskRunning)
+	taskEngine.reserveHostResources(task)
+	if task.GetDesiredStatus() != api.TaskStopped {
+		t.Errorf("Expected the task to be stopped, got: %s", task.GetDesiredStatus())
+	}
+	if err := task.Containers[0].ApplyingError; err == nil || err.Name != "HostPortConflictError" {
+		t.Errorf("Expected HostPortConflictError, got: %v", err)
+	}
+
+	// Once the pending task stops, the port is free again
+	pending.SetDesiredStatus(api.TaskStopped)
+	other := portTask("other", api.ContainerStatusNone, api.PortBinding{ContainerPort: 80, HostPort: 8080})
+	other.SetDesiredStatus(api.TaskRunning)
+	taskEngine.reserveHostResources(other)
+	if other.GetDesiredStatus() != api.TaskRunning {
+		t.Errorf("Expected the task to fit, got: %v", other.Containers[0].ApplyingError)
+	}
+}
+
+func TestHostResourceManagerStoppingTask(t *testing.T) {
+	manager := newHostResourceManager(1024, 1024)
+	running := resourceTask("running", 1024, 1024)
+	if err := manager.reserve(running); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := manager.check(resourceTask("arn", 1, 0)); err == nil {
+		t.Fatal("Expected no cpu to be free")
+	}
+
+	// ECS frees the resources of a task as soon as it asks for it to stop
+	running.SetDesiredStatus(api.TaskStopped)
+	if err := manager.reserve(resourceTask("replacement", 1024, 1024)); err != nil {
+		t.Errorf("Expected the resources of the stopping task to be free, got: %v", err)
+	}
+}
+
+func TestReserveHostResourcesReplacesTaskOnFullInstance(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.hostResources = newHostResourceManager(1024, 1024)
+
+	running := resourceTask("running", 1024, 1024)
+	running.Containers[0].Ports = []api.PortBinding{{ContainerPort: 80, HostPort: 8080}}
+	running.Containers[0].SetKnownStatus(api.ContainerRunning)
+	running.SetKnownStatus(api.TaskRunning)
+	running.SetDesiredStatus(api.TaskRunning)
+	taskEngine.reserveHostResources(running)
+	if running.GetDesiredStatus() != api.TaskRunning {
+		t.Fatalf("Expected the task to fit, got: %v", running.Containers[0].ApplyingError)
+	}
+	taskEngine.state.AddTask(running)
+
+	// ECS stops the running task and sends its replacement, which waits for
+	// it to stop before starting
+	running.SetDesiredStatus(api.TaskStopped)
+	replacement := resourceTask("replacement", 1024, 1024)
+	replacement.Containers[0].Ports = []api.PortBinding{{ContainerPort: 80, HostPort: 8080}}
+	replacement.StartSequenceNumber = 2
+	replacement.SetDesiredStatus(api.TaskRunning)
+	taskEngine.reserveHostResources(replacement)
+	if replacement.GetDesiredStatus() != api.TaskRunning {
+		t.Errorf("Expected the replacement to fit, got: %v", replacement.Containers[0].ApplyingError)
+	}
+
+	// The port is still held when the replacement's containers are created
+	// if the stopping task hasn't stopped yet
+	if conflicts := hostPortConflicts(replacement.Containers[0], taskEngine.reservedHostPorts(replacement.Containers[0], true)); len(conflicts) == 0 {
+		t.Error("Expected the port of the stopping task's running container to be held")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"sync"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// imageInspectCacheTTL is how long the result of inspecting an image is
+// reused. Images removed outside of the agent are noticed once it expires
+const imageInspectCacheTTL = 10 * time.Second
+
+// imageInspectCache caches the results of inspecting images by image
+// reference, so that launching many containers of the same image inspects it
+// once. Lookups of an image that is being inspected wait for that inspection
+// instead of inspecting it again. Images that don't exist are cached too, but
+// other errors aren't
+type imageInspectCache struct {
+	lock    sync.Mutex
+	entries map[string]*imageInspection
+}
+
+// imageInspection is the result of inspecting an image. done is closed once
+// image and err are set
+type imageInspection struct {
+	done    chan struct{}
+	image   *docker.Image
+	err     error
+	expires time.Time
+}
+
+func newImageInspectCache() *imageInspectCache {
+	return &imageInspectCache{entries: make(map[string]*imageInspection)}
+}
+
+// inspect returns the cached result of inspecting image, or inspects it with
+// inspectImage if there's none that's current
+func (cache *imageInspectCache) inspect(image string, now time.Time, inspectImage func(string) (*docker.Image, error)) (*docker.Image, error) {
+	cache.lock.Lock()
+	entry, ok := cache.entries[image]
+	if ok && (!entry.finished() || now.Before(entry.expires)) {
+		cache.lock.Unlock()
+		<-entry.done
+		return entry.image, entry.err
+	}
+	entry = &imageInspection{done: make(chan struct{})}
+	cache.entries[image] = entry
+	cache.lock.Unlock()
+
+	inspected, err := inspectImage(image)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	entry.image, entry.err = inspected, err
+	entry.expires = now.Add(imageInspectCacheTTL)
+	close(entry.done)
+	if err != nil && err != docker.ErrNoSuchImage && cache.entries[image] == entry {
+		delete(cache.entries, image)
+	}
+	return inspected, err
+}
+
+// invalidate drops the cached result of inspecting image. Inspections in
+// flight are still returned to the lookups waiting for them, but not cached
+func (cache *imageInspectCache) invalidate(image string) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	delete(cache.entries, image)
+}
+
+// invalidateAll drops the cached results of inspecting every image, such as
+// when an image is removed by id
+func (cache *imageInspectCache) invalidateAll() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.entries = make(map[string]*imageInspection)
+}
+
+func (entry *imageInspection) finished() bool {
+	select {
+	case <-entry.done:
+		return true
+	default:
+		return false
+	}
+}
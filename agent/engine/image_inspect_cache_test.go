@@ -0,0 +1,163 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingInspector returns an inspect function for imageInspectCache that
+// counts its calls and returns result
+func countingInspector(calls *int, image *docker.Image, err error) func(string) (*docker.Image, error) {
+	return func(string) (*docker.Image, error) {
+		*calls++
+		return image, err
+	}
+}
+
+func TestImageInspectCacheReusesResult(t *testing.T) {
+	cache := newImageInspectCache()
+	now := time.Now()
+	calls := 0
+	inspect := countingInspector(&calls, &docker.Image{ID: "id"}, nil)
+
+	image, err := cache.inspect("image", now, inspect)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", image.ID)
+	image, err = cache.inspect("image", now.Add(imageInspectCacheTTL-time.Second), inspect)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", image.ID)
+	assert.Equal(t, 1, calls, "Expected the result to be reused")
+
+	cache.inspect("image", now.Add(imageInspectCacheTTL), inspect)
+	assert.Equal(t, 2, calls, "Expected the image to be inspected again once the result expires")
+	cache.inspect("other", now, inspect)
+	assert.Equal(t, 3, calls, "Expected results to be cached by image")
+}
+
+func TestImageInspectCacheErrors(t *testing.T) {
+	cache := newImageInspectCache()
+	now := time.Now()
+
+	calls := 0
+	missing := countingInspector(&calls, nil, docker.ErrNoSuchImage)
+	cache.inspect("missing", now, missing)
+	_, err := cache.inspect("missing", now, missing)
+	assert.Equal(t, docker.ErrNoSuchImage, err)
+	assert.Equal(t, 1, calls, "Expected images that don't exist to be cached")
+
+	calls = 0
+	failing := countingInspector(&calls, nil, errors.New("connection refused"))
+	cache.inspect("failing", now, failing)
+	cache.inspect("failing", now, failing)
+	assert.Equal(t, 2, calls, "Expected errors inspecting images not to be cached")
+}
+
+func TestImageInspectCacheInvalidate(t *testing.T) {
+	cache := newImageInspectCache()
+	now := time.Now()
+	calls := 0
+	inspect := countingInspector(&calls, &docker.Image{}, nil)
+
+	cache.inspect("image", now, inspect)
+	cache.inspect("other", now, inspect)
+	cache.invalidate("image")
+	cache.inspect("image", now, inspect)
+	cache.inspect("other", now, inspect)
+	assert.Equal(t, 3, calls, "Expected only the invalidated image to be inspected again")
+
+	cache.invalidateAll()
+	cache.inspect("image", now, inspect)
+	cache.inspect("other", now, inspect)
+	assert.Equal(t, 5, calls, "Expected every image to be inspected again")
+}
+
+func TestImageInspectCacheInvalidateInFlight(t *testing.T) {
+	cache := newImageInspectCache()
+	now := time.Now()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go cache.inspect("image", now, func(string) (*docker.Image, error) {
+		close(started)
+		<-release
+		return nil, docker.ErrNoSuchImage
+	})
+
+	// The image gets pulled while it's being inspected
+	<-started
+	cache.invalidate("image")
+	close(release)
+
+	image, err := cache.inspect("image", now, func(string) (*docker.Image, error) {
+		return &docker.Image{ID: "pulled"}, nil
+	})
+	assert.NoError(t, err, "Expected the inspection started before the pull not to be cached")
+	assert.Equal(t, "pulled", image.ID)
+}
+
+func TestInspectImageConcurrentLookups(t *testing.T) {
+	mockDocker, client, testTime, done := dockerClientSetup(t)
+	defer done()
+	testTime.EXPECT().Now().Return(time.Now()).AnyTimes()
+
+	release := make(chan struct{})
+	mockDocker.EXPECT().InspectImage("image").Do(func(image string) {
+		<-release
+	}).Return(&docker.Image{ID: "id"}, nil).Times(1)
+
+	const lookups = 10
+	var wg sync.WaitGroup
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			image, err := client.InspectImage("image")
+			assert.NoError(t, err)
+			assert.Equal(t, "id", image.ID)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	versioned := client.WithVersion(dockerclient.Version_1_20).(*dockerGoClient)
+	assert.True(t, versioned.images == client.images, "Expected clients of every version to share the cache")
+}
+
+func TestRemoveImageInvalidatesInspectCache(t *testing.T) {
+	mockDocker, client, testTime, done := dockerClientSetup(t)
+	defer done()
+	testTime.EXPECT().Now().Return(time.Now()).AnyTimes()
+
+	gomock.InOrder(
+		mockDocker.EXPECT().InspectImage("image").Return(&docker.Image{ID: "id"}, nil),
+		mockDocker.EXPECT().RemoveImage("id").Return(nil),
+		mockDocker.EXPECT().InspectImage("image").Return(nil, docker.ErrNoSuchImage),
+	)
+
+	_, err := client.InspectImage("image")
+	assert.NoError(t, err)
+	assert.NoError(t, client.RemoveImage("id", time.Second))
+	_, err = client.InspectImage("image")
+	assert.Equal(t, docker.ErrNoSuchImage, err, "Expected the image to be inspected again once removed")
+}
@@ -0,0 +1,64 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+// launchLimiter limits how many tasks are launched at the same time, so that
+// a burst of tasks doesn't pull and create all of their containers at once.
+// A task holds a slot from when it starts progressing until it's running or
+// stopping; stopping tasks never wait for a slot
+type launchLimiter struct {
+	// slots holds a value for each task being launched. It's nil when
+	// launches are not limited
+	slots chan struct{}
+}
+
+func newLaunchLimiter(maxLaunches int) *launchLimiter {
+	limiter := &launchLimiter{}
+	if maxLaunches > 0 {
+		limiter.slots = make(chan struct{}, maxLaunches)
+	}
+	return limiter
+}
+
+// acquire returns a channel that is written true once a slot is held by the
+// caller, which must then release it
+func (limiter *launchLimiter) acquire() <-chan bool {
+	acquired := make(chan bool, 1)
+	if limiter.slots == nil {
+		acquired <- true
+		return acquired
+	}
+	go func() {
+		limiter.slots <- struct{}{}
+		acquired <- true
+	}()
+	return acquired
+}
+
+// abandon releases the slot acquired through the channel once it's held, for
+// callers that stopped waiting for it
+func (limiter *launchLimiter) abandon(acquired <-chan bool) {
+	go func() {
+		<-acquired
+		limiter.release()
+	}()
+}
+
+// release frees a slot held by the caller
+func (limiter *launchLimiter) release() {
+	if limiter.slots == nil {
+		return
+	}
+	<-limiter.slots
+}
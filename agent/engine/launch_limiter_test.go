@@ -0,0 +1,115 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func acquired(c <-chan bool) bool {
+	select {
+	case <-c:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func TestLaunchLimiterRespectsCap(t *testing.T) {
+	limiter := newLaunchLimiter(2)
+	assert.True(t, acquired(limiter.acquire()))
+	assert.True(t, acquired(limiter.acquire()))
+
+	third := limiter.acquire()
+	assert.False(t, acquired(third), "Third launch should wait for a slot")
+	limiter.release()
+	assert.True(t, acquired(third), "Third launch should get the released slot")
+}
+
+func TestLaunchLimiterUnlimited(t *testing.T) {
+	limiter := newLaunchLimiter(0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, acquired(limiter.acquire()))
+	}
+	limiter.release()
+}
+
+func TestLaunchLimiterAbandon(t *testing.T) {
+	limiter := newLaunchLimiter(1)
+	assert.True(t, acquired(limiter.acquire()))
+
+	limiter.abandon(limiter.acquire())
+	limiter.release()
+
+	next := limiter.acquire()
+	assert.True(t, acquired(next), "Abandoned slot should have been released")
+}
+
+func TestWaitForLaunchSlot(t *testing.T) {
+	cfg := defaultConfig
+	cfg.MaxConcurrentTaskLaunches = 1
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	launching := taskEngine.newManagedTask(&api.Task{Arn: "launching", DesiredStatus: api.TaskRunning})
+	launching.waitForLaunchSlot()
+	assert.True(t, launching.launchSlotHeld)
+
+	queued := taskEngine.newManagedTask(&api.Task{Arn: "queued", DesiredStatus: api.TaskRunning})
+	queuedDone := make(chan struct{})
+	go func() {
+		queued.waitForLaunchSlot()
+		close(queuedDone)
+	}()
+	select {
+	case <-queuedDone:
+		t.Fatal("Launch should have been queued beyond the cap")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Stopping doesn't wait for the launches
+	stopping := taskEngine.newManagedTask(&api.Task{Arn: "stopping", DesiredStatus: api.TaskStopped})
+	stopping.waitForLaunchSlot()
+	assert.False(t, stopping.launchSlotHeld)
+
+	// Nor does a queued task that's told to stop
+	queued.acsMessages <- acsTransition{desiredStatus: api.TaskStopped}
+	select {
+	case <-queuedDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the queued task to stop waiting")
+	}
+	assert.False(t, queued.launchSlotHeld)
+
+	launching.releaseLaunchSlot()
+	assert.False(t, launching.launchSlotHeld)
+	next := taskEngine.newManagedTask(&api.Task{Arn: "next", DesiredStatus: api.TaskRunning})
+	nextDone := make(chan struct{})
+	go func() {
+		next.waitForLaunchSlot()
+		close(nextDone)
+	}()
+	select {
+	case <-nextDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the released launch slot")
+	}
+	assert.True(t, next.launchSlotHeld)
+}
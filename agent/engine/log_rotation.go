@@ -0,0 +1,103 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"strconv"
+
+	"github.com/cihub/seelog"
+	"github.com/docker/go-units"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	jsonFileLoggingDriver = "json-file"
+	logOptMaxSize         = "max-size"
+	logOptMaxFile         = "max-file"
+)
+
+// applyJSONFileLogRotation sets the configured max-size and max-file options
+// of containers logging with the json-file driver that don't set them, and
+// returns an error if the container sets them above the configured limits.
+// Containers that don't set a logging driver use the default driver of the
+// daemon. Containers logging with other drivers are left as they are
+func (engine *DockerTaskEngine) applyJSONFileLogRotation(logConfig *docker.LogConfig) engineError {
+	cfg := engine.cfg
+	if cfg.JSONFileMaxSize == "" && cfg.JSONFileMaxFiles == 0 && cfg.JSONFileMaxSizeLimit == "" && cfg.JSONFileMaxFilesLimit == 0 {
+		return nil
+	}
+
+	driver := logConfig.Type
+	if driver == "" {
+		driver = engine.daemonLoggingDriver()
+	}
+	if driver != jsonFileLoggingDriver {
+		return nil
+	}
+
+	if maxSize, ok := logConfig.Config[logOptMaxSize]; ok && cfg.JSONFileMaxSizeLimit != "" {
+		size, err := units.RAMInBytes(maxSize)
+		if err != nil {
+			return JSONFileLogOptionError{"Invalid json-file log option " + logOptMaxSize + ": " + maxSize}
+		}
+		// The limit is validated when the config is loaded
+		limit, _ := units.RAMInBytes(cfg.JSONFileMaxSizeLimit)
+		if size <= 0 || size > limit {
+			return JSONFileLogOptionError{"json-file log option " + logOptMaxSize + " " + maxSize + " is above the limit of " + cfg.JSONFileMaxSizeLimit}
+		}
+	}
+	if maxFile, ok := logConfig.Config[logOptMaxFile]; ok && cfg.JSONFileMaxFilesLimit > 0 {
+		files, err := strconv.Atoi(maxFile)
+		if err != nil {
+			return JSONFileLogOptionError{"Invalid json-file log option " + logOptMaxFile + ": " + maxFile}
+		}
+		if files > cfg.JSONFileMaxFilesLimit {
+			return JSONFileLogOptionError{"json-file log option " + logOptMaxFile + " " + maxFile + " is above the limit of " + strconv.Itoa(cfg.JSONFileMaxFilesLimit)}
+		}
+	}
+
+	options := make(map[string]string)
+	for key, value := range logConfig.Config {
+		options[key] = value
+	}
+	if _, ok := options[logOptMaxSize]; !ok && cfg.JSONFileMaxSize != "" {
+		options[logOptMaxSize] = cfg.JSONFileMaxSize
+	}
+	if _, ok := options[logOptMaxFile]; !ok && cfg.JSONFileMaxFiles > 0 {
+		options[logOptMaxFile] = strconv.Itoa(cfg.JSONFileMaxFiles)
+	}
+	// Options only apply to the driver they're set with, so the default driver
+	// is set explicitly
+	logConfig.Type = jsonFileLoggingDriver
+	logConfig.Config = options
+	return nil
+}
+
+// daemonLoggingDriver returns the default logging driver of the docker daemon,
+// or an empty string if it can't be retrieved. It is only retrieved once
+func (engine *DockerTaskEngine) daemonLoggingDriver() string {
+	engine.daemonLoggingDriverLock.Lock()
+	defer engine.daemonLoggingDriverLock.Unlock()
+	if engine._daemonLoggingDriver != "" {
+		return engine._daemonLoggingDriver
+	}
+
+	info, err := engine.client.Info()
+	if err != nil {
+		seelog.Warnf("Unable to get the default logging driver of the docker daemon, json-file log rotation will not be applied: %v", err)
+		return ""
+	}
+	engine._daemonLoggingDriver = info.LoggingDriver
+	return engine._daemonLoggingDriver
+}
@@ -0,0 +1,189 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+)
+
+func logRotationConfig() config.Config {
+	cfg := defaultConfig
+	cfg.JSONFileMaxSize = "10m"
+	cfg.JSONFileMaxFiles = 3
+	cfg.JSONFileMaxSizeLimit = "100m"
+	cfg.JSONFileMaxFilesLimit = 5
+	return cfg
+}
+
+func TestApplyJSONFileLogRotation(t *testing.T) {
+	cfg := logRotationConfig()
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	// The default driver of the daemon is only retrieved once
+	client.EXPECT().Info().Return(DockerInfo{LoggingDriver: "json-file"}, nil)
+
+	testCases := []struct {
+		name      string
+		logConfig docker.LogConfig
+		expected  docker.LogConfig
+		err       bool
+	}{
+		{
+			name:      "defaults injected",
+			logConfig: docker.LogConfig{Type: "json-file"},
+			expected:  docker.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "10m", "max-file": "3"}},
+		},
+		{
+			name:      "defaults injected for default driver",
+			logConfig: docker.LogConfig{},
+			expected:  docker.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "10m", "max-file": "3"}},
+		},
+		{
+			name:      "override within limit",
+			logConfig: docker.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "100m", "max-file": "5", "labels": "app"}},
+			expected:  docker.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "100m", "max-file": "5", "labels": "app"}},
+		},
+		{
+			name:      "partial override",
+			logConfig: docker.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "1k"}},
+			expected:  docker.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "1k", "max-file": "3"}},
+		},
+		{
+			name:      "max-size above limit",
+			logConfig: docker.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "1g"}},
+			err:       true,
+		},
+		{
+			name:      "max-file above limit",
+			logConfig: docker.LogConfig{Type: "json-file", Config: map[string]string{"max-file": "6"}},
+			err:       true,
+		},
+		{
+			name:      "invalid max-size",
+			logConfig: docker.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "big"}},
+			err:       true,
+		},
+		{
+			name:      "other driver",
+			logConfig: docker.LogConfig{Type: "syslog", Config: map[string]string{"max-size": "1g"}},
+			expected:  docker.LogConfig{Type: "syslog", Config: map[string]string{"max-size": "1g"}},
+		},
+	}
+	for _, tc := range testCases {
+		logConfig := tc.logConfig
+		err := taskEngine.applyJSONFileLogRotation(&logConfig)
+		if tc.err {
+			if err == nil || err.ErrorName() != "JSONFileLogOptionError" {
+				t.Errorf("%s: expected JSONFileLogOptionError, got: %v", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(logConfig, tc.expected) {
+			t.Errorf("%s: expected %v, got: %v", tc.name, tc.expected, logConfig)
+		}
+	}
+}
+
+func TestApplyJSONFileLogRotationOtherDefaultDriver(t *testing.T) {
+	cfg := logRotationConfig()
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	gomock.InOrder(
+		client.EXPECT().Info().Return(DockerInfo{}, errors.New("daemon unavailable")),
+		client.EXPECT().Info().Return(DockerInfo{LoggingDriver: "journald"}, nil),
+	)
+
+	for i := 0; i < 3; i++ {
+		logConfig := docker.LogConfig{}
+		if err := taskEngine.applyJSONFileLogRotation(&logConfig); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(logConfig, docker.LogConfig{}) {
+			t.Errorf("Expected the log config to be unchanged, got: %v", logConfig)
+		}
+	}
+}
+
+func TestApplyJSONFileLogRotationNotConfigured(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	// The client mock fails the test if the default driver is retrieved
+	logConfig := docker.LogConfig{}
+	if err := taskEngine.applyJSONFileLogRotation(&logConfig); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(logConfig, docker.LogConfig{}) {
+		t.Errorf("Expected the log config to be unchanged, got: %v", logConfig)
+	}
+}
+
+func TestCreateContainerJSONFileLogRotation(t *testing.T) {
+	cfg := logRotationConfig()
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	hostConfig := `{"LogConfig":{"Type":"json-file","Config":{"max-file":"2"}}}`
+	container := &api.Container{Name: "c", Image: "image", DockerConfig: api.DockerConfig{HostConfig: &hostConfig}}
+	task := &api.Task{Arn: "arn", Family: "family", Version: "1", Containers: []*api.Container{container}}
+	taskEngine.state.AddTask(task)
+
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			expected := map[string]string{"max-size": "10m", "max-file": "2"}
+			if !reflect.DeepEqual(hostConfig.LogConfig.Config, expected) {
+				t.Errorf("Expected log options %v, got: %v", expected, hostConfig.LogConfig.Config)
+			}
+		}).Return(DockerContainerMetadata{DockerID: "id"})
+
+	metadata := taskEngine.createContainer(task, container)
+	if metadata.Error != nil {
+		t.Errorf("Unexpected error: %v", metadata.Error)
+	}
+}
+
+func TestCreateContainerJSONFileLogRotationAboveLimit(t *testing.T) {
+	cfg := logRotationConfig()
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	hostConfig := `{"LogConfig":{"Type":"json-file","Config":{"max-size":"1g"}}}`
+	container := &api.Container{Name: "c", Image: "image", DockerConfig: api.DockerConfig{HostConfig: &hostConfig}}
+	task := &api.Task{Arn: "arn", Containers: []*api.Container{container}}
+	taskEngine.state.AddTask(task)
+
+	// The client mock fails the test if the container is created
+	metadata := taskEngine.createContainer(task, container)
+	if metadata.Error == nil || metadata.Error.ErrorName() != "JSONFileLogOptionError" {
+		t.Errorf("Expected JSONFileLogOptionError, got: %v", metadata.Error)
+	}
+}
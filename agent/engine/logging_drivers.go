@@ -0,0 +1,83 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+	"github.com/cihub/seelog"
+)
+
+// availableLoggingDrivers returns the logging drivers configured as available
+// that the docker daemon has. They're detected the first time they're needed
+func (engine *DockerTaskEngine) availableLoggingDrivers() []dockerclient.LoggingDriver {
+	engine.loggingDriversLock.Lock()
+	defer engine.loggingDriversLock.Unlock()
+	if !engine.loggingDriversDetected {
+		engine._loggingDrivers, engine.loggingDriversDetected = engine.detectLoggingDrivers()
+	}
+	return engine._loggingDrivers
+}
+
+// redetectLoggingDrivers detects the logging drivers again, if they were
+// detected before, since the daemon may have been reconfigured or had logging
+// plugins installed while the agent was disconnected from it
+func (engine *DockerTaskEngine) redetectLoggingDrivers() {
+	engine.loggingDriversLock.Lock()
+	defer engine.loggingDriversLock.Unlock()
+	if !engine.loggingDriversDetected {
+		return
+	}
+	drivers, detected := engine.detectLoggingDrivers()
+	if !detected {
+		return
+	}
+	if !reflect.DeepEqual(drivers, engine._loggingDrivers) {
+		seelog.Infof("Available logging drivers changed from %v to %v", engine._loggingDrivers, drivers)
+	}
+	engine._loggingDrivers = drivers
+}
+
+// detectLoggingDrivers returns the logging drivers configured as available
+// that the daemon lists, and whether they could be detected. Daemons that
+// don't list their logging drivers are assumed to have all of them
+func (engine *DockerTaskEngine) detectLoggingDrivers() ([]dockerclient.LoggingDriver, bool) {
+	configured := engine.cfg.AvailableLoggingDrivers
+	if len(configured) == 0 {
+		return configured, true
+	}
+	info, err := engine.client.Info()
+	if err != nil {
+		seelog.Warnf("Unable to get the logging drivers of the docker daemon, assuming the configured drivers are available: %v", err)
+		return configured, false
+	}
+	if len(info.LoggingDrivers) == 0 {
+		return configured, true
+	}
+
+	daemonDrivers := make(map[string]bool)
+	for _, driver := range info.LoggingDrivers {
+		daemonDrivers[driver] = true
+	}
+	drivers := []dockerclient.LoggingDriver{}
+	for _, driver := range configured {
+		if !daemonDrivers[string(driver)] {
+			seelog.Warnf("Logging driver %s is configured as available, but the docker daemon doesn't have it", driver)
+			continue
+		}
+		drivers = append(drivers, driver)
+	}
+	return drivers, true
+}
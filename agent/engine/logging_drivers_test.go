@@ -0,0 +1,128 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailableLoggingDrivers(t *testing.T) {
+	configured := []dockerclient.LoggingDriver{
+		dockerclient.JsonFileDriver,
+		dockerclient.SyslogDriver,
+		dockerclient.AwslogsDriver,
+		dockerclient.SplunklogsDriver,
+	}
+	testCases := []struct {
+		name     string
+		plugins  []string
+		expected []dockerclient.LoggingDriver
+	}{
+		{
+			name:     "daemon without log plugins listed",
+			expected: configured,
+		},
+		{
+			name:     "daemon with every driver",
+			plugins:  []string{"awslogs", "fluentd", "gcplogs", "gelf", "journald", "json-file", "logentries", "splunk", "syslog"},
+			expected: configured,
+		},
+		{
+			name:     "daemon missing configured drivers",
+			plugins:  []string{"json-file", "journald", "splunk", "vieux/sshfs:latest"},
+			expected: []dockerclient.LoggingDriver{dockerclient.JsonFileDriver, dockerclient.SplunklogsDriver},
+		},
+		{
+			name:     "daemon with none of the configured drivers",
+			plugins:  []string{"journald"},
+			expected: []dockerclient.LoggingDriver{},
+		},
+	}
+	for _, tc := range testCases {
+		cfg := defaultConfig
+		cfg.AvailableLoggingDrivers = configured
+		ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+		taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+		// The drivers are only detected once
+		client.EXPECT().Info().Return(DockerInfo{LoggingDriver: "json-file", LoggingDrivers: tc.plugins}, nil)
+		assert.Equal(t, tc.expected, taskEngine.availableLoggingDrivers(), tc.name)
+		assert.Equal(t, tc.expected, taskEngine.availableLoggingDrivers(), tc.name)
+		ctrl.Finish()
+	}
+}
+
+func TestAvailableLoggingDriversInfoError(t *testing.T) {
+	cfg := defaultConfig
+	cfg.AvailableLoggingDrivers = []dockerclient.LoggingDriver{dockerclient.JsonFileDriver, dockerclient.SyslogDriver}
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	// The configured drivers are assumed available until the daemon answers
+	gomock.InOrder(
+		client.EXPECT().Info().Return(DockerInfo{}, errors.New("daemon unavailable")),
+		client.EXPECT().Info().Return(DockerInfo{LoggingDrivers: []string{"json-file"}}, nil),
+	)
+	assert.Equal(t, cfg.AvailableLoggingDrivers, taskEngine.availableLoggingDrivers())
+	assert.Equal(t, []dockerclient.LoggingDriver{dockerclient.JsonFileDriver}, taskEngine.availableLoggingDrivers())
+}
+
+func TestRedetectLoggingDrivers(t *testing.T) {
+	cfg := defaultConfig
+	cfg.AvailableLoggingDrivers = []dockerclient.LoggingDriver{dockerclient.JsonFileDriver, dockerclient.SplunklogsDriver}
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	// Nothing is detected again until the drivers have been needed
+	taskEngine.redetectLoggingDrivers()
+
+	gomock.InOrder(
+		client.EXPECT().Info().Return(DockerInfo{LoggingDrivers: []string{"json-file"}}, nil),
+		// The splunk plugin was installed while the daemon restarted
+		client.EXPECT().Info().Return(DockerInfo{LoggingDrivers: []string{"json-file", "splunk"}}, nil),
+		// Failing to detect the drivers again keeps the last ones detected
+		client.EXPECT().Info().Return(DockerInfo{}, errors.New("daemon unavailable")),
+	)
+	assert.Equal(t, []dockerclient.LoggingDriver{dockerclient.JsonFileDriver}, taskEngine.availableLoggingDrivers())
+	taskEngine.redetectLoggingDrivers()
+	assert.Equal(t, cfg.AvailableLoggingDrivers, taskEngine.availableLoggingDrivers())
+	taskEngine.redetectLoggingDrivers()
+	assert.Equal(t, cfg.AvailableLoggingDrivers, taskEngine.availableLoggingDrivers())
+}
+
+func TestCapabilitiesDetectedLoggingDrivers(t *testing.T) {
+	cfg := defaultConfig
+	cfg.AvailableLoggingDrivers = []dockerclient.LoggingDriver{dockerclient.JsonFileDriver, dockerclient.AwslogsDriver, dockerclient.SplunklogsDriver}
+	ctrl, client, _, taskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+
+	client.EXPECT().SupportedVersions().Return([]dockerclient.DockerVersion{dockerclient.Version_1_18, dockerclient.Version_1_21, dockerclient.Version_1_22})
+	client.EXPECT().Info().Return(DockerInfo{LoggingDrivers: []string{"awslogs", "json-file", "syslog"}}, nil)
+
+	capabilities := make(map[string]bool)
+	for _, capability := range taskEngine.Capabilities() {
+		capabilities[capability] = true
+	}
+	assert.True(t, capabilities[capabilityPrefix+"logging-driver.json-file"])
+	assert.True(t, capabilities[capabilityPrefix+"logging-driver.awslogs"])
+	assert.False(t, capabilities[capabilityPrefix+"logging-driver.splunk"], "Drivers the daemon doesn't have should not be advertised")
+}
@@ -0,0 +1,126 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+// DockerAPIs are the docker APIs whose errors the engine counts, named after
+// the container transition that calls them
+var DockerAPIs = []string{"pull", "create", "start", "stop"}
+
+var transitionDockerAPIs = map[api.ContainerStatus]string{
+	api.ContainerPulled:  "pull",
+	api.ContainerCreated: "create",
+	api.ContainerRunning: "start",
+	api.ContainerStopped: "stop",
+}
+
+// EngineMetrics are counters of the work the engine asked of docker since the
+// agent started
+type EngineMetrics struct {
+	ImagePulls        uint64
+	ImagePullFailures uint64
+	// DockerAPIErrors is the number of failed calls by docker API, for each of
+	// DockerAPIs
+	DockerAPIErrors map[string]uint64
+	// DiskPressureActivations is the number of times free disk space went
+	// below the threshold and aggressive cleanup started
+	DiskPressureActivations uint64
+}
+
+// engineMetrics counts the outcome of the container transitions of the engine
+type engineMetrics struct {
+	imagePulls        uint64
+	imagePullFailures uint64
+	dockerAPIErrors   map[string]uint64
+	diskPressure      uint64
+	lock              sync.Mutex
+}
+
+func newEngineMetrics() *engineMetrics {
+	metrics := &engineMetrics{dockerAPIErrors: make(map[string]uint64)}
+	for _, dockerAPI := range DockerAPIs {
+		metrics.dockerAPIErrors[dockerAPI] = 0
+	}
+	return metrics
+}
+
+// recordTransition counts a container transition and its error, if any.
+// Errors found by the checks of the engine before calling docker, and pulls
+// skipped because the task stopped, aren't counted
+func (metrics *engineMetrics) recordTransition(to api.ContainerStatus, err engineError) {
+	if _, ok := err.(TaskStoppedBeforePullBeginError); ok {
+		return
+	}
+	dockerAPI, ok := transitionDockerAPIs[to]
+	if !ok {
+		return
+	}
+
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	if to == api.ContainerPulled {
+		metrics.imagePulls++
+		if err != nil {
+			metrics.imagePullFailures++
+		}
+	}
+	if isDockerAPIError(err) {
+		metrics.dockerAPIErrors[dockerAPI]++
+	}
+}
+
+// recordDiskPressure counts aggressive cleanup starting for lack of disk
+// space
+func (metrics *engineMetrics) recordDiskPressure() {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.diskPressure++
+}
+
+func (metrics *engineMetrics) snapshot() EngineMetrics {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+
+	dockerAPIErrors := make(map[string]uint64, len(metrics.dockerAPIErrors))
+	for dockerAPI, count := range metrics.dockerAPIErrors {
+		dockerAPIErrors[dockerAPI] = count
+	}
+	return EngineMetrics{
+		ImagePulls:              metrics.imagePulls,
+		ImagePullFailures:       metrics.imagePullFailures,
+		DockerAPIErrors:         dockerAPIErrors,
+		DiskPressureActivations: metrics.diskPressure,
+	}
+}
+
+// isDockerAPIError returns true if the error was returned by docker, or if
+// docker didn't answer in time
+func isDockerAPIError(err engineError) bool {
+	switch err.(type) {
+	case CannotXContainerError, CannotPullContainerAuthError, CannotCreateVolumeError, *DockerTimeoutError:
+		return true
+	}
+	return false
+}
+
+// EngineMetrics returns the counters of the work the engine asked of docker
+// since the agent started
+func (engine *DockerTaskEngine) EngineMetrics() EngineMetrics {
+	return engine.metrics.snapshot()
+}
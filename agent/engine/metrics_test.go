@@ -0,0 +1,65 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineMetricsRecordTransition(t *testing.T) {
+	metrics := newEngineMetrics()
+
+	metrics.recordTransition(api.ContainerPulled, nil)
+	metrics.recordTransition(api.ContainerPulled, CannotXContainerError{"Pull", "not found"})
+	metrics.recordTransition(api.ContainerPulled, CannotPullContainerAuthError{errors.New("denied")})
+	// Pulls skipped because the task stopped aren't pulls
+	metrics.recordTransition(api.ContainerPulled, TaskStoppedBeforePullBeginError{"arn"})
+	metrics.recordTransition(api.ContainerCreated, &DockerTimeoutError{pullImageTimeout, "created"})
+	// Errors found before calling docker aren't docker errors
+	metrics.recordTransition(api.ContainerCreated, ShmSizeLimitError{"too big"})
+	metrics.recordTransition(api.ContainerRunning, nil)
+	metrics.recordTransition(api.ContainerStopped, CannotXContainerError{"Stop", "no such container"})
+
+	snapshot := metrics.snapshot()
+	assert.Equal(t, uint64(3), snapshot.ImagePulls)
+	assert.Equal(t, uint64(2), snapshot.ImagePullFailures)
+	assert.Equal(t, map[string]uint64{"pull": 2, "create": 1, "start": 0, "stop": 1}, snapshot.DockerAPIErrors)
+
+	// Snapshots aren't changed by later transitions
+	metrics.recordTransition(api.ContainerRunning, CannotXContainerError{"Start", "port is allocated"})
+	assert.Equal(t, uint64(0), snapshot.DockerAPIErrors["start"])
+}
+
+func TestApplyContainerStateRecordsMetrics(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, imageManager := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	task := &api.Task{Arn: "arn", Containers: []*api.Container{{Name: "c", Image: "image"}}}
+
+	client.EXPECT().PullImage("image", nil).Return(DockerContainerMetadata{Error: CannotXContainerError{"Pull", "not found"}})
+	imageManager.EXPECT().RecordContainerReference(gomock.Any()).Return(nil)
+	imageManager.EXPECT().GetImageStateFromImageName("image").Return(nil)
+
+	taskEngine.applyContainerState(task, task.Containers[0], api.ContainerPulled)
+	metrics := taskEngine.EngineMetrics()
+	assert.Equal(t, uint64(1), metrics.ImagePulls)
+	assert.Equal(t, uint64(1), metrics.ImagePullFailures)
+	assert.Equal(t, uint64(1), metrics.DockerAPIErrors["pull"])
+}
@@ -0,0 +1,116 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+	"github.com/cihub/seelog"
+	"golang.org/x/net/context"
+)
+
+const (
+	eventStreamReconnectMinDelay   = time.Second
+	eventStreamReconnectMaxDelay   = 30 * time.Second
+	eventStreamReconnectJitter     = 0.2
+	eventStreamReconnectMultiplier = 2
+
+	// containerStatePollInterval is how often the state of containers is
+	// polled when not relying on the event stream alone
+	containerStatePollInterval = 10 * time.Second
+)
+
+// reconnectEventstream reopens the docker event stream after it was
+// disconnected, retrying until it is reopened or the engine is stopped, and
+// then reconciles the containers of the engine with docker. It returns false
+// if the engine was stopped first
+func (engine *DockerTaskEngine) reconnectEventstream(ctx context.Context) bool {
+	backoff := utils.NewSimpleBackoff(eventStreamReconnectMinDelay, eventStreamReconnectMaxDelay, eventStreamReconnectJitter, eventStreamReconnectMultiplier)
+	for {
+		err := engine.openEventstream(ctx)
+		if err == nil {
+			break
+		}
+		seelog.Warnf("Unable to reopen the docker event stream: %v", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-engine.time().After(backoff.Duration()):
+		}
+	}
+	seelog.Info("Reopened the docker event stream")
+	engine.redetectLoggingDrivers()
+	engine.reconcileContainers()
+	return true
+}
+
+// pollContainerStates reconciles the containers of the engine with docker
+// every containerStatePollInterval until the engine is stopped
+func (engine *DockerTaskEngine) pollContainerStates(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-engine.time().After(containerStatePollInterval):
+			engine.reconcileContainers()
+		}
+	}
+}
+
+// reconcileContainers brings the known status of the containers of the engine
+// up to date after docker events may have been missed. Containers docker lists
+// as running that are known to be running are left as they are; the others are
+// described and the result is handled as a docker event. Containers that can't
+// be described are also left as they are rather than assumed to have stopped,
+// since the daemon may still be starting up
+func (engine *DockerTaskEngine) reconcileContainers() {
+	running := make(map[string]bool)
+	response := engine.client.ListContainers(false, ListContainersTimeout)
+	if response.Error != nil {
+		seelog.Warnf("Unable to list running containers, describing each known container: %v", response.Error)
+	}
+	for _, dockerID := range response.DockerIDs {
+		running[dockerID] = true
+	}
+
+	for _, task := range engine.state.AllTasks() {
+		if task.GetKnownStatus().Terminal() {
+			continue
+		}
+		containers, ok := engine.state.ContainerMapByArn(task.Arn)
+		if !ok {
+			continue
+		}
+		for _, container := range containers {
+			if container.DockerId == "" || container.Container.KnownTerminal() {
+				continue
+			}
+			if running[container.DockerId] && container.Container.GetKnownStatus() == api.ContainerRunning {
+				continue
+			}
+			status, metadata := engine.client.DescribeContainer(container.DockerId)
+			if metadata.Error != nil {
+				seelog.Warnf("Unable to describe container %s of task %s while reconciling: %v", container.DockerId, task.Arn, metadata.Error)
+				continue
+			}
+			if status.Terminal() && engine.cfg.ContainerStateMode != config.ContainerStateModePoll {
+				seelog.Warnf("Container %s of task %s stopped without a docker event being handled", container.DockerId, task.Arn)
+			}
+			engine.handleDockerEvent(DockerContainerChangeEvent{Status: status, DockerContainerMetadata: metadata})
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
+	"github.com/golang/mock/gomock"
+	"golang.org/x/net/context"
+)
+
+// addReconcileTask adds a running task to the engine with a running container
+// and a container that was created but is not yet known to be running
+func addReconcileTask(taskEngine *DockerTaskEngine) (*api.Task, *managedTask) {
+	running := &api.Container{Name: "running"}
+	running.SetKnownStatus(api.ContainerRunning)
+	created := &api.Container{Name: "created"}
+	created.SetKnownStatus(api.ContainerCreated)
+	task := &api.Task{Arn: "arn", Containers: []*api.Container{running, created}}
+	task.SetKnownStatus(api.TaskRunning)
+
+	taskEngine.state.AddTask(task)
+	taskEngine.state.AddContainer(&api.DockerContainer{DockerId: "running-id", DockerName: "running-name", Container: running}, task)
+	taskEngine.state.AddContainer(&api.DockerContainer{DockerId: "created-id", DockerName: "created-name", Container: created}, task)
+
+	mtask := &managedTask{Task: task, engine: taskEngine, dockerMessages: make(chan dockerContainerChange, 10)}
+	taskEngine.managedTasks[task.Arn] = mtask
+	return task, mtask
+}
+
+func TestEventStreamDisconnectReconcilesRunningContainers(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	task, mtask := addReconcileTask(taskEngine)
+
+	disconnected := make(chan DockerContainerChangeEvent)
+	close(disconnected)
+	taskEngine.events = disconnected
+	eventStream := make(chan DockerContainerChangeEvent)
+
+	// The container docker lists as running that is known to be running isn't
+	// described; the mock fails the test if it is
+	gomock.InOrder(
+		client.EXPECT().ContainerEvents(gomock.Any()).Return(eventStream, nil),
+		client.EXPECT().ListContainers(false, ListContainersTimeout).Return(ListContainersResponse{DockerIDs: []string{"running-id", "created-id"}}),
+		client.EXPECT().DescribeContainer("created-id").Return(api.ContainerRunning, DockerContainerMetadata{DockerID: "created-id"}),
+	)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go taskEngine.handleDockerEvents(ctx)
+
+	change := <-mtask.dockerMessages
+	if change.container.Name != "created" || change.event.Status != api.ContainerRunning {
+		t.Errorf("Expected the created container to be running, got: %s %s", change.container.Name, change.event.Status)
+	}
+
+	// Events are read from the reopened stream
+	eventStream <- DockerContainerChangeEvent{Status: api.ContainerStopped, DockerContainerMetadata: DockerContainerMetadata{DockerID: "running-id"}}
+	change = <-mtask.dockerMessages
+	if change.container.Name != "running" || change.event.Status != api.ContainerStopped {
+		t.Errorf("Expected an event for the running container, got: %s %s", change.container.Name, change.event.Status)
+	}
+
+	if status := task.Containers[0].GetKnownStatus(); status != api.ContainerRunning {
+		t.Errorf("Expected the running container to still be known as running, got: %s", status)
+	}
+}
+
+func TestEventStreamReconnectRetries(t *testing.T) {
+	ctrl, client, mockTime, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	task, mtask := addReconcileTask(taskEngine)
+
+	eventStream := make(chan DockerContainerChangeEvent)
+	retry := make(chan time.Time, 1)
+	retry <- time.Now()
+
+	// Containers that can't be described, such as while the daemon is still
+	// starting, aren't assumed to have stopped
+	gomock.InOrder(
+		client.EXPECT().ContainerEvents(gomock.Any()).Return(nil, errors.New("daemon unavailable")),
+		mockTime.EXPECT().After(gomock.Any()).Return(retry),
+		client.EXPECT().ContainerEvents(gomock.Any()).Return(eventStream, nil),
+		client.EXPECT().ListContainers(false, ListContainersTimeout).Return(ListContainersResponse{Error: errors.New("daemon unavailable")}),
+	)
+	client.EXPECT().DescribeContainer("running-id").Return(api.ContainerStatusNone, DockerContainerMetadata{Error: CannotXContainerError{"Describe", "daemon unavailable"}})
+	client.EXPECT().DescribeContainer("created-id").Return(api.ContainerStatusNone, DockerContainerMetadata{Error: CannotXContainerError{"Describe", "daemon unavailable"}})
+
+	if !taskEngine.reconnectEventstream(context.TODO()) {
+		t.Fatal("Expected the event stream to be reopened")
+	}
+
+	select {
+	case change := <-mtask.dockerMessages:
+		t.Errorf("Unexpected event for container %s: %s", change.container.Name, change.event.Status)
+	default:
+	}
+	if status := task.Containers[0].GetKnownStatus(); status != api.ContainerRunning {
+		t.Errorf("Expected the running container to still be known as running, got: %s", status)
+	}
+}
+
+func TestEventStreamReconnectStopsWithEngine(t *testing.T) {
+	ctrl, client, mockTime, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	client.EXPECT().ContainerEvents(gomock.Any()).Return(nil, errors.New("daemon unavailable"))
+	mockTime.EXPECT().After(gomock.Any()).Do(func(interface{}) { cancel() }).Return(make(chan time.Time))
+
+	if taskEngine.reconnectEventstream(ctx) {
+		t.Error("Expected reconnecting to stop with the engine")
+	}
+}
+
+func TestContainerStateModeEvents(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ContainerStateMode = config.ContainerStateModeEvents
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	// Containers aren't polled; the mocks fail the test if they are
+	eventStream := make(chan DockerContainerChangeEvent)
+	client.EXPECT().ContainerEvents(gomock.Any()).Return(eventStream, nil)
+
+	err := taskEngine.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskEngine.stopEngine()
+	_, mtask := addReconcileTask(taskEngine)
+
+	eventStream <- DockerContainerChangeEvent{Status: api.ContainerStopped, DockerContainerMetadata: DockerContainerMetadata{DockerID: "running-id"}}
+	change := <-mtask.dockerMessages
+	if change.container.Name != "running" || change.event.Status != api.ContainerStopped {
+		t.Errorf("Expected an event for the running container, got: %s %s", change.container.Name, change.event.Status)
+	}
+}
+
+// expectContainerStatePoll sets the first poll to happen when the returned
+// channel is written to, and later polls to never happen
+func expectContainerStatePoll(mockTime *mock_ttime.MockTime) chan time.Time {
+	poll := make(chan time.Time)
+	gomock.InOrder(
+		mockTime.EXPECT().After(containerStatePollInterval).Return(poll),
+		mockTime.EXPECT().After(containerStatePollInterval).Return(make(chan time.Time)).AnyTimes(),
+	)
+	return poll
+}
+
+// containerChanges reads the next n docker messages of the task by container
+func containerChanges(mtask *managedTask, n int) map[string]api.ContainerStatus {
+	changes := make(map[string]api.ContainerStatus)
+	for i := 0; i < n; i++ {
+		change := <-mtask.dockerMessages
+		changes[change.container.Name] = change.event.Status
+	}
+	return changes
+}
+
+func TestContainerStateModePoll(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ContainerStateMode = config.ContainerStateModePoll
+	ctrl, client, mockTime, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	// The event stream isn't opened; the mock fails the test if it is
+	poll := expectContainerStatePoll(mockTime)
+	client.EXPECT().ListContainers(false, ListContainersTimeout).Return(ListContainersResponse{DockerIDs: []string{}})
+	client.EXPECT().DescribeContainer("running-id").Return(api.ContainerStopped, DockerContainerMetadata{DockerID: "running-id"})
+	client.EXPECT().DescribeContainer("created-id").Return(api.ContainerCreated, DockerContainerMetadata{DockerID: "created-id"})
+
+	err := taskEngine.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskEngine.stopEngine()
+	_, mtask := addReconcileTask(taskEngine)
+	poll <- time.Now()
+
+	changes := containerChanges(mtask, 2)
+	if changes["running"] != api.ContainerStopped || changes["created"] != api.ContainerCreated {
+		t.Errorf("Unexpected container changes: %v", changes)
+	}
+}
+
+func TestContainerStateModeHybrid(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ContainerStateMode = config.ContainerStateModeHybrid
+	ctrl, client, mockTime, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	eventStream := make(chan DockerContainerChangeEvent)
+	client.EXPECT().ContainerEvents(gomock.Any()).Return(eventStream, nil)
+	poll := expectContainerStatePoll(mockTime)
+
+	err := taskEngine.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskEngine.stopEngine()
+	_, mtask := addReconcileTask(taskEngine)
+
+	// Events are handled as they happen
+	eventStream <- DockerContainerChangeEvent{Status: api.ContainerRunning, DockerContainerMetadata: DockerContainerMetadata{DockerID: "created-id"}}
+	change := <-mtask.dockerMessages
+	if change.container.Name != "created" || change.event.Status != api.ContainerRunning {
+		t.Errorf("Expected an event for the created container, got: %s %s", change.container.Name, change.event.Status)
+	}
+
+	// A poll catches the stop of a container whose event was missed
+	client.EXPECT().ListContainers(false, ListContainersTimeout).Return(ListContainersResponse{DockerIDs: []string{"created-id"}})
+	client.EXPECT().DescribeContainer("running-id").Return(api.ContainerStopped, DockerContainerMetadata{DockerID: "running-id"})
+	client.EXPECT().DescribeContainer("created-id").Return(api.ContainerRunning, DockerContainerMetadata{DockerID: "created-id"})
+	poll <- time.Now()
+
+	changes := containerChanges(mtask, 2)
+	if changes["running"] != api.ContainerStopped || changes["created"] != api.ContainerRunning {
+		t.Errorf("Unexpected container changes: %v", changes)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+)
+
+const (
+	containerRestartBackoffMin      = 10 * time.Second
+	containerRestartBackoffMax      = 5 * time.Minute
+	containerRestartBackoffJitter   = 0.2
+	containerRestartBackoffMultiple = 2
+)
+
+// restartSupervisor decides when the crashed non-essential containers of a
+// task are restarted by the agent. Each container is restarted at most
+// maxRestarts times, waiting exponentially longer before each restart
+type restartSupervisor struct {
+	maxRestarts int
+	// crashes counts the crashes of each container, by container name
+	crashes map[string]int
+	// backoffs holds the backoff of each container that has crashed, by
+	// container name
+	backoffs map[string]utils.Backoff
+	// pending holds the names of the crashed containers waiting to be
+	// restarted
+	pending map[string]bool
+	// restarting holds the names of the containers that have been restarted,
+	// but haven't run or stopped again yet
+	restarting map[string]bool
+}
+
+func newRestartSupervisor(maxRestarts int) *restartSupervisor {
+	return &restartSupervisor{
+		maxRestarts: maxRestarts,
+		crashes:     make(map[string]int),
+		backoffs:    make(map[string]utils.Backoff),
+		pending:     make(map[string]bool),
+		restarting:  make(map[string]bool),
+	}
+}
+
+// enabled returns true if crashed containers are restarted at all
+func (supervisor *restartSupervisor) enabled() bool {
+	return supervisor.maxRestarts > 0
+}
+
+// crashed records a crash of the named container, returning how long to wait
+// before restarting it, or false if it has been restarted too often already.
+// The container is pending until it's restarted or its restart is abandoned
+func (supervisor *restartSupervisor) crashed(name string) (time.Duration, bool) {
+	supervisor.crashes[name]++
+	if supervisor.crashes[name] > supervisor.maxRestarts {
+		return 0, false
+	}
+	backoff, ok := supervisor.backoffs[name]
+	if !ok {
+		backoff = utils.NewSimpleBackoff(containerRestartBackoffMin, containerRestartBackoffMax, containerRestartBackoffJitter, containerRestartBackoffMultiple)
+		supervisor.backoffs[name] = backoff
+	}
+	supervisor.pending[name] = true
+	return backoff.Duration(), true
+}
+
+// restart moves the named container from pending to restarting, returning
+// false if it isn't pending anymore
+func (supervisor *restartSupervisor) restart(name string) bool {
+	if !supervisor.pending[name] {
+		return false
+	}
+	delete(supervisor.pending, name)
+	supervisor.restarting[name] = true
+	return true
+}
+
+// abandon returns the names of the pending containers, which are no longer
+// restarted
+func (supervisor *restartSupervisor) abandon() []string {
+	names := make([]string, 0, len(supervisor.pending))
+	for name := range supervisor.pending {
+		names = append(names, name)
+		delete(supervisor.pending, name)
+	}
+	return names
+}
+
+// done records that the named container has run or stopped again
+func (supervisor *restartSupervisor) done(name string) {
+	delete(supervisor.restarting, name)
+}
+
+// anyRestarting returns true if a restarted container has yet to run
+func (supervisor *restartSupervisor) anyRestarting() bool {
+	return len(supervisor.restarting) > 0
+}
@@ -0,0 +1,70 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartSupervisorBackoff(t *testing.T) {
+	supervisor := newRestartSupervisor(7)
+	expected := []time.Duration{
+		10 * time.Second,
+		20 * time.Second,
+		40 * time.Second,
+		80 * time.Second,
+		160 * time.Second,
+		5 * time.Minute,
+		5 * time.Minute,
+	}
+	for i, minDelay := range expected {
+		delay, ok := supervisor.crashed("sidecar")
+		if !assert.True(t, ok, "Crash %d should be restarted", i+1) {
+			continue
+		}
+		maxDelay := minDelay + time.Duration(float64(minDelay)*containerRestartBackoffJitter)
+		assert.True(t, delay >= minDelay && delay <= maxDelay, "Crash %d: expected a delay between %s and %s, got %s", i+1, minDelay, maxDelay, delay)
+		assert.True(t, supervisor.restart("sidecar"))
+		supervisor.done("sidecar")
+	}
+
+	_, ok := supervisor.crashed("sidecar")
+	assert.False(t, ok, "Container should not be restarted past the limit")
+
+	// Other containers have restarts of their own
+	delay, ok := supervisor.crashed("other")
+	assert.True(t, ok)
+	assert.True(t, delay < 20*time.Second)
+}
+
+func TestRestartSupervisorAbandon(t *testing.T) {
+	supervisor := newRestartSupervisor(3)
+	_, ok := supervisor.crashed("sidecar")
+	assert.True(t, ok)
+
+	assert.Equal(t, []string{"sidecar"}, supervisor.abandon())
+	assert.False(t, supervisor.restart("sidecar"), "Abandoned restarts should not happen")
+	assert.False(t, supervisor.anyRestarting())
+}
+
+func TestRestartSupervisorDisabled(t *testing.T) {
+	supervisor := newRestartSupervisor(0)
+	assert.False(t, supervisor.enabled())
+	_, ok := supervisor.crashed("sidecar")
+	assert.False(t, ok)
+}
@@ -0,0 +1,174 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/secrets"
+	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// secretsResolver sets the secrets of containers as environment variables,
+// and their log secret options as options of their log driver, when the
+// containers are created. The values are cached while the task is launched,
+// so that containers referencing the same secret don't fetch it again, and
+// are dropped once every container of the task with secrets has been
+// resolved. The values are never logged, nor saved with the state
+type secretsResolver struct {
+	fetcher            secrets.Fetcher
+	credentialsManager credentials.Manager
+
+	// lock only guards tasks; secrets are fetched with the lock of the task
+	// held instead, so that fetching them doesn't hold up other tasks
+	lock  sync.Mutex
+	tasks map[string]*taskSecrets
+}
+
+// taskSecrets are the values of the secrets of a task by valueFrom, and the
+// names of the containers of the task whose secrets were resolved
+type taskSecrets struct {
+	lock     sync.Mutex
+	values   map[string]string
+	resolved map[string]bool
+}
+
+func newTaskSecrets() *taskSecrets {
+	return &taskSecrets{
+		values:   make(map[string]string),
+		resolved: make(map[string]bool),
+	}
+}
+
+func newSecretsResolver(fetcher secrets.Fetcher, credentialsManager credentials.Manager) *secretsResolver {
+	return &secretsResolver{
+		fetcher:            fetcher,
+		credentialsManager: credentialsManager,
+		tasks:              make(map[string]*taskSecrets),
+	}
+}
+
+// taskSecrets returns the secrets of the task, adding them if there are none
+func (resolver *secretsResolver) taskSecrets(task *api.Task) *taskSecrets {
+	resolver.lock.Lock()
+	defer resolver.lock.Unlock()
+	taskSecrets, ok := resolver.tasks[task.Arn]
+	if !ok {
+		taskSecrets = newTaskSecrets()
+		resolver.tasks[task.Arn] = taskSecrets
+	}
+	return taskSecrets
+}
+
+// hasSecrets returns true if the container has secrets or log secret options
+func hasSecrets(container *api.Container) bool {
+	return len(container.Secrets) > 0 || len(container.LogSecretOptions) > 0
+}
+
+// apply adds the secrets of the container to its docker config, replacing
+// variables of the same names, and its log secret options to the options of
+// its log driver, replacing options of the same names. The container fails to
+// be created if any of them can't be resolved
+func (resolver *secretsResolver) apply(task *api.Task, container *api.Container, config *docker.Config, logConfig *docker.LogConfig) engineError {
+	if !hasSecrets(container) {
+		return nil
+	}
+	if len(container.LogSecretOptions) > 0 && logConfig.Type == "" {
+		return CannotResolveSecretError{"Container " + container.Name + " has log secret options but no log driver"}
+	}
+	taskSecrets := resolver.taskSecrets(task)
+	taskSecrets.lock.Lock()
+	defer taskSecrets.lock.Unlock()
+
+	var roleCredentials *credentials.IAMRoleCredentials
+	if credentialsID := task.GetCredentialsId(); credentialsID != "" && resolver.credentialsManager != nil {
+		if taskCredentials, ok := resolver.credentialsManager.GetTaskCredentials(credentialsID); ok {
+			roleCredentials = &taskCredentials.IAMRoleCredentials
+		}
+	}
+
+	variables, err := resolver.resolve(container, "secret", container.Secrets, taskSecrets.values, roleCredentials)
+	if err != nil {
+		return err
+	}
+	options, err := resolver.resolve(container, "log secret option", container.LogSecretOptions, taskSecrets.values, roleCredentials)
+	if err != nil {
+		return err
+	}
+	seelog.Infof("Resolved %d secrets and %d log secret options of container %s of task %s", len(container.Secrets), len(container.LogSecretOptions), container.Name, task.Arn)
+
+	env := make([]string, 0, len(config.Env)+len(container.Secrets))
+	for _, variable := range config.Env {
+		if _, ok := variables[strings.SplitN(variable, "=", 2)[0]]; !ok {
+			env = append(env, variable)
+		}
+	}
+	// The secrets are added in the order the container lists them
+	for _, secret := range container.Secrets {
+		if value, ok := variables[secret.Name]; ok {
+			env = append(env, secret.Name+"="+value)
+			delete(variables, secret.Name)
+		}
+	}
+	config.Env = env
+
+	if len(options) > 0 && logConfig.Config == nil {
+		logConfig.Config = make(map[string]string)
+	}
+	for name, value := range options {
+		logConfig.Config[name] = value
+	}
+
+	taskSecrets.resolved[container.Name] = true
+	for _, taskContainer := range task.Containers {
+		if hasSecrets(taskContainer) && !taskSecrets.resolved[taskContainer.Name] {
+			return nil
+		}
+	}
+	resolver.releaseTask(task)
+	return nil
+}
+
+// resolve returns the values of secrets by name, fetching those that aren't in
+// values yet. It must be called with the lock of the task's secrets held
+func (resolver *secretsResolver) resolve(container *api.Container, kind string, secrets []api.Secret, values map[string]string, roleCredentials *credentials.IAMRoleCredentials) (map[string]string, engineError) {
+	resolved := make(map[string]string)
+	for _, secret := range secrets {
+		if secret.Name == "" {
+			return nil, CannotResolveSecretError{"A " + kind + " of container " + container.Name + " has no name"}
+		}
+		value, ok := values[secret.ValueFrom]
+		if !ok {
+			var err error
+			value, err = resolver.fetcher.Fetch(secret.ValueFrom, roleCredentials)
+			if err != nil {
+				return nil, CannotResolveSecretError{"Unable to resolve " + kind + " " + secret.Name + " from " + secret.ValueFrom + ": " + err.Error()}
+			}
+			values[secret.ValueFrom] = value
+		}
+		resolved[secret.Name] = value
+	}
+	return resolved, nil
+}
+
+// releaseTask drops the values of the secrets of a task
+func (resolver *secretsResolver) releaseTask(task *api.Task) {
+	resolver.lock.Lock()
+	defer resolver.lock.Unlock()
+	delete(resolver.tasks, task.Arn)
+}
@@ -0,0 +1,307 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
+	"github.com/aws/amazon-ecs-agent/agent/secrets/mocks"
+	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	dbPasswordValueFrom  = "arn:aws:ssm:us-west-2:123456789012:parameter/db/password"
+	apiKeyValueFrom      = "arn:aws:secretsmanager:us-west-2:123456789012:secret:api-key-AbCdEf"
+	splunkTokenValueFrom = "arn:aws:ssm:us-west-2:123456789012:parameter/splunk/token"
+)
+
+func TestSecretsResolverApply(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	resolver := newSecretsResolver(fetcher, credentials.NewManager())
+
+	task := &api.Task{Arn: "task", Containers: []*api.Container{
+		{Name: "web", Secrets: []api.Secret{{Name: "DB_PASSWORD", ValueFrom: dbPasswordValueFrom}, {Name: "API_KEY", ValueFrom: apiKeyValueFrom}}},
+		{Name: "worker", Secrets: []api.Secret{{Name: "PASSWORD", ValueFrom: dbPasswordValueFrom}}},
+		{Name: "sidecar"},
+	}}
+	// Each secret is fetched once for the task
+	fetcher.EXPECT().Fetch(dbPasswordValueFrom, nil).Return("hunter2", nil)
+	fetcher.EXPECT().Fetch(apiKeyValueFrom, nil).Return("key", nil)
+
+	config := &docker.Config{Env: []string{"DB_PASSWORD=placeholder", "MODE=web"}}
+	assert.Nil(t, resolver.apply(task, task.Containers[0], config, &docker.LogConfig{}))
+	assert.Equal(t, []string{"MODE=web", "DB_PASSWORD=hunter2", "API_KEY=key"}, config.Env)
+	assert.NotEmpty(t, resolver.tasks, "Values should be kept until every container is resolved")
+
+	config = &docker.Config{}
+	assert.Nil(t, resolver.apply(task, task.Containers[1], config, &docker.LogConfig{}))
+	assert.Equal(t, []string{"PASSWORD=hunter2"}, config.Env)
+	assert.Empty(t, resolver.tasks, "Values should be dropped once every container is resolved")
+
+	config = &docker.Config{Env: []string{"MODE=sidecar"}}
+	assert.Nil(t, resolver.apply(task, task.Containers[2], config, &docker.LogConfig{}))
+	assert.Equal(t, []string{"MODE=sidecar"}, config.Env)
+}
+
+func TestSecretsResolverApplyLogSecretOptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	resolver := newSecretsResolver(fetcher, credentials.NewManager())
+
+	task := &api.Task{Arn: "task", Containers: []*api.Container{
+		{Name: "web", Secrets: []api.Secret{{Name: "API_KEY", ValueFrom: apiKeyValueFrom}}, LogSecretOptions: []api.Secret{{Name: "splunk-token", ValueFrom: splunkTokenValueFrom}}},
+		{Name: "worker", LogSecretOptions: []api.Secret{{Name: "splunk-token", ValueFrom: splunkTokenValueFrom}}},
+	}}
+	fetcher.EXPECT().Fetch(apiKeyValueFrom, nil).Return("key", nil)
+	fetcher.EXPECT().Fetch(splunkTokenValueFrom, nil).Return("token", nil)
+
+	config := &docker.Config{}
+	logConfig := &docker.LogConfig{Type: "splunk", Config: map[string]string{"splunk-url": "https://splunk:8088", "splunk-token": "placeholder"}}
+	assert.Nil(t, resolver.apply(task, task.Containers[0], config, logConfig))
+	assert.Equal(t, []string{"API_KEY=key"}, config.Env)
+	assert.Equal(t, map[string]string{"splunk-url": "https://splunk:8088", "splunk-token": "token"}, logConfig.Config)
+	assert.NotEmpty(t, resolver.tasks, "Values should be kept until every container is resolved")
+
+	logConfig = &docker.LogConfig{Type: "splunk"}
+	assert.Nil(t, resolver.apply(task, task.Containers[1], &docker.Config{}, logConfig))
+	assert.Equal(t, map[string]string{"splunk-token": "token"}, logConfig.Config)
+	assert.Empty(t, resolver.tasks, "Values should be dropped once every container is resolved")
+}
+
+func TestSecretsResolverLogSecretOptionsWithoutLogDriver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// No secrets are expected to be fetched
+	resolver := newSecretsResolver(mock_secrets.NewMockFetcher(ctrl), credentials.NewManager())
+
+	task := &api.Task{Arn: "task", Containers: []*api.Container{
+		{Name: "web", LogSecretOptions: []api.Secret{{Name: "splunk-token", ValueFrom: splunkTokenValueFrom}}},
+	}}
+	err := resolver.apply(task, task.Containers[0], &docker.Config{}, &docker.LogConfig{})
+	if err == nil || err.ErrorName() != "CannotResolveSecretError" {
+		t.Errorf("Expected CannotResolveSecretError, got: %v", err)
+	}
+}
+
+func TestSecretsResolverTaskRoleCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	credentialsManager := credentials.NewManager()
+	resolver := newSecretsResolver(fetcher, credentialsManager)
+
+	roleCredentials := credentials.IAMRoleCredentials{CredentialsID: "credentials", AccessKeyID: "id", SecretAccessKey: "secret"}
+	credentialsManager.SetTaskCredentials(credentials.TaskIAMRoleCredentials{ARN: "task", IAMRoleCredentials: roleCredentials})
+	task := &api.Task{Arn: "task", Containers: []*api.Container{
+		{Name: "web", Secrets: []api.Secret{{Name: "DB_PASSWORD", ValueFrom: dbPasswordValueFrom}}},
+	}}
+	task.SetCredentialsId("credentials")
+	fetcher.EXPECT().Fetch(dbPasswordValueFrom, &roleCredentials).Return("hunter2", nil)
+
+	assert.Nil(t, resolver.apply(task, task.Containers[0], &docker.Config{}, &docker.LogConfig{}))
+}
+
+func TestSecretsResolverReleaseTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	resolver := newSecretsResolver(fetcher, credentials.NewManager())
+
+	task := &api.Task{Arn: "task", Containers: []*api.Container{
+		{Name: "web", Secrets: []api.Secret{{Name: "DB_PASSWORD", ValueFrom: dbPasswordValueFrom}}},
+		{Name: "worker", Secrets: []api.Secret{{Name: "DB_PASSWORD", ValueFrom: dbPasswordValueFrom}}},
+	}}
+	fetcher.EXPECT().Fetch(dbPasswordValueFrom, nil).Return("hunter2", nil)
+	assert.Nil(t, resolver.apply(task, task.Containers[0], &docker.Config{}, &docker.LogConfig{}))
+
+	// The worker never got created
+	resolver.releaseTask(task)
+	assert.Empty(t, resolver.tasks)
+}
+
+func TestSecretsResolverFetchesTasksConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	resolver := newSecretsResolver(fetcher, credentials.NewManager())
+
+	slowTask := &api.Task{Arn: "slow", Containers: []*api.Container{
+		{Name: "web", Secrets: []api.Secret{{Name: "DB_PASSWORD", ValueFrom: dbPasswordValueFrom}}},
+	}}
+	task := &api.Task{Arn: "task", Containers: []*api.Container{
+		{Name: "web", Secrets: []api.Secret{{Name: "API_KEY", ValueFrom: apiKeyValueFrom}}},
+	}}
+	fetching := make(chan struct{})
+	fetched := make(chan struct{})
+	fetcher.EXPECT().Fetch(dbPasswordValueFrom, nil).Do(func(valueFrom string, roleCredentials *credentials.IAMRoleCredentials) {
+		close(fetching)
+		<-fetched
+	}).Return("hunter2", nil)
+	fetcher.EXPECT().Fetch(apiKeyValueFrom, nil).Return("key", nil)
+
+	slowDone := make(chan engineError)
+	go func() {
+		slowDone <- resolver.apply(slowTask, slowTask.Containers[0], &docker.Config{}, &docker.LogConfig{})
+	}()
+	<-fetching
+
+	// The secrets of other tasks are resolved while the slow fetch is in flight
+	done := make(chan engineError)
+	go func() {
+		done <- resolver.apply(task, task.Containers[0], &docker.Config{}, &docker.LogConfig{})
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Error("Timed out resolving secrets while another task's secrets were fetched")
+	}
+	close(fetched)
+	assert.Nil(t, <-slowDone)
+}
+
+func TestCreateContainerSecrets(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	taskEngine.secrets.fetcher = fetcher
+
+	// The values of secrets should never be logged
+	var logs bytes.Buffer
+	logger, err := seelog.LoggerFromWriterWithMinLevel(&logs, seelog.TraceLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seelog.ReplaceLogger(logger)
+	defer seelog.ReplaceLogger(seelog.Disabled)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.Environment = map[string]string{"MODE": "inline"}
+	sleepContainer.Secrets = []api.Secret{{Name: "DB_PASSWORD", ValueFrom: dbPasswordValueFrom}}
+
+	fetcher.EXPECT().Fetch(dbPasswordValueFrom, nil).Return("hunter2", nil)
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Contains(t, config.Env, "DB_PASSWORD=hunter2")
+			assert.Contains(t, config.Env, "MODE=inline")
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+	logger.Flush()
+	assert.NotContains(t, logs.String(), "hunter2", "The value of a secret was logged")
+}
+
+func TestCreateContainerMissingSecret(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	taskEngine.secrets.fetcher = fetcher
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.Secrets = []api.Secret{{Name: "DB_PASSWORD", ValueFrom: dbPasswordValueFrom}}
+
+	fetcher.EXPECT().Fetch(dbPasswordValueFrom, nil).Return("", errors.New("ParameterNotFound"))
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil || metadata.Error.ErrorName() != "CannotResolveSecretError" {
+		t.Errorf("Expected CannotResolveSecretError, got: %v", metadata.Error)
+	}
+}
+
+func TestCreateContainerLogSecretOptions(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AvailableLoggingDrivers = []dockerclient.LoggingDriver{dockerclient.SplunklogsDriver}
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	taskEngine.secrets.fetcher = fetcher
+
+	// The values of log secret options should never be logged
+	var logs bytes.Buffer
+	logger, err := seelog.LoggerFromWriterWithMinLevel(&logs, seelog.TraceLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seelog.ReplaceLogger(logger)
+	defer seelog.ReplaceLogger(seelog.Disabled)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	rawHostConfig := `{"LogConfig":{"Type":"splunk","Config":{"splunk-url":"https://splunk:8088"}}}`
+	sleepContainer.DockerConfig.HostConfig = &rawHostConfig
+	sleepContainer.LogSecretOptions = []api.Secret{{Name: "splunk-token", ValueFrom: splunkTokenValueFrom}}
+
+	fetcher.EXPECT().Fetch(splunkTokenValueFrom, nil).Return("s3cr3t-t0ken", nil)
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+			assert.Equal(t, "splunk", hostConfig.LogConfig.Type)
+			assert.Equal(t, map[string]string{"splunk-url": "https://splunk:8088", "splunk-token": "s3cr3t-t0ken"}, hostConfig.LogConfig.Config)
+		})
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error != nil {
+		t.Error("Unexpected error", metadata.Error)
+	}
+	logger.Flush()
+	assert.NotContains(t, logs.String(), "s3cr3t-t0ken", "The value of a log secret option was logged")
+}
+
+func TestCreateContainerMissingLogSecretOption(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AvailableLoggingDrivers = []dockerclient.LoggingDriver{dockerclient.FluentdDriver}
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	fetcher := mock_secrets.NewMockFetcher(ctrl)
+	taskEngine.secrets.fetcher = fetcher
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	rawHostConfig := `{"LogConfig":{"Type":"fluentd"}}`
+	sleepContainer.DockerConfig.HostConfig = &rawHostConfig
+	sleepContainer.LogSecretOptions = []api.Secret{{Name: "fluentd-address", ValueFrom: splunkTokenValueFrom}}
+
+	fetcher.EXPECT().Fetch(splunkTokenValueFrom, nil).Return("", errors.New("ParameterNotFound"))
+
+	// No calls to the client are expected
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	if metadata.Error == nil || metadata.Error.ErrorName() != "CannotResolveSecretError" {
+		t.Errorf("Expected CannotResolveSecretError, got: %v", metadata.Error)
+	}
+}
@@ -14,11 +14,11 @@
 package engine
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
-	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dependencygraph"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 	"github.com/cihub/seelog"
@@ -26,6 +26,10 @@ import (
 
 const (
 	steadyStateTaskVerifyInterval = 10 * time.Minute
+
+	// maxEssentialContainerStopAttempts is the number of times stopping an
+	// essential container is attempted before assuming it's stopped anyways
+	maxEssentialContainerStopAttempts = 3
 )
 
 type acsTaskUpdate struct {
@@ -79,6 +83,23 @@ type managedTask struct {
 	// thing managing the container.
 	unexpectedStart sync.Once
 
+	// essentialStopAttempts counts the failed attempts to stop each essential
+	// container of the task, by container name
+	essentialStopAttempts map[string]int
+
+	// cleanupNow is signaled to clean up the task once it has stopped without
+	// waiting for the rest of the cleanup wait duration
+	cleanupNow chan struct{}
+
+	// launchSlotHeld is set while the task holds a slot of the engine's
+	// launch limiter
+	launchSlotHeld bool
+
+	// restarts decides when crashed non-essential containers are restarted.
+	// Containers are sent on containerRestarts once it's time to restart them
+	restarts          *restartSupervisor
+	containerRestarts chan *api.Container
+
 	_time     ttime.Time
 	_timeOnce sync.Once
 }
@@ -88,10 +109,13 @@ type managedTask struct {
 // already held.
 func (engine *DockerTaskEngine) newManagedTask(task *api.Task) *managedTask {
 	t := &managedTask{
-		Task:           task,
-		acsMessages:    make(chan acsTransition),
-		dockerMessages: make(chan dockerContainerChange),
-		engine:         engine,
+		Task:              task,
+		acsMessages:       make(chan acsTransition),
+		cleanupNow:        make(chan struct{}, 1),
+		dockerMessages:    make(chan dockerContainerChange),
+		engine:            engine,
+		restarts:          newRestartSupervisor(engine.cfg.MaxContainerRestarts),
+		containerRestarts: make(chan *api.Container, len(task.Containers)),
 	}
 	engine.managedTasks[task.Arn] = t
 	return t
@@ -124,7 +148,12 @@ func (mtask *managedTask) overseeTask() {
 		}
 		llog.Debug("Wait over; ready to move towards status: " + mtask.GetDesiredStatus().String())
 	}
+	mtask.waitForLaunchSlot()
 	for {
+		if mtask.GetKnownStatus() >= api.TaskRunning || mtask.GetDesiredStatus().Terminal() {
+			// The task is done launching, one way or another
+			mtask.releaseLaunchSlot()
+		}
 		// If it's steadyState, just spin until we need to do work
 		for mtask.steadyState() {
 			llog.Debug("Task at steady state", "state", mtask.KnownStatus.String())
@@ -142,6 +171,9 @@ func (mtask *managedTask) overseeTask() {
 			}
 		}
 
+		if mtask.GetDesiredStatus().Terminal() {
+			mtask.abandonContainerRestarts()
+		}
 		if !mtask.KnownStatus.Terminal() {
 			// If we aren't terminal and we aren't steady state, we should be able to move some containers along
 			llog.Debug("Task not steady state or terminal; progressing it")
@@ -163,6 +195,9 @@ func (mtask *managedTask) overseeTask() {
 	// We only break out of the above if this task is known to be stopped. Do
 	// onetime cleanup here, including removing the task after a timeout
 	llog.Debug("Task has reached stopped. We're just waiting and removing containers now")
+	mtask.releaseLaunchSlot()
+	mtask.engine.gpus.release(mtask.Arn)
+	mtask.engine.hostResources.release(mtask.Arn)
 	taskCredentialsID := mtask.GetCredentialsId()
 	if taskCredentialsID != "" {
 		mtask.engine.credentialsManager.RemoveCredentials(taskCredentialsID)
@@ -174,6 +209,34 @@ func (mtask *managedTask) overseeTask() {
 	mtask.cleanupTask(mtask.engine.cfg.TaskCleanupWaitDuration)
 }
 
+// waitForLaunchSlot waits for the engine's launch limiter to allow launching
+// the task, handling events while waiting. Tasks that are running or stopping
+// don't need a slot, and tasks told to stop while waiting give up theirs
+func (mtask *managedTask) waitForLaunchSlot() {
+	if mtask.GetKnownStatus() >= api.TaskRunning || mtask.GetDesiredStatus().Terminal() {
+		return
+	}
+	llog := log.New("task", mtask)
+	llog.Debug("Waiting for a launch slot")
+	acquired := mtask.engine.launchLimiter.acquire()
+	for !mtask.waitEvent(acquired) {
+		if mtask.GetDesiredStatus().Terminal() {
+			llog.Debug("Task stopping; no longer waiting for a launch slot")
+			mtask.engine.launchLimiter.abandon(acquired)
+			return
+		}
+	}
+	mtask.launchSlotHeld = true
+}
+
+func (mtask *managedTask) releaseLaunchSlot() {
+	if !mtask.launchSlotHeld {
+		return
+	}
+	mtask.engine.launchLimiter.release()
+	mtask.launchSlotHeld = false
+}
+
 func (mtask *managedTask) emitCurrentStatus() {
 	for _, container := range mtask.Containers {
 		mtask.engine.emitContainerEvent(mtask.Task, container, "")
@@ -218,6 +281,28 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 	event := containerChange.event
 	llog.Debug("Handling container change", "change", containerChange)
 
+	previousHealth := container.GetHealthStatus()
+	if mtask.updateContainerHealth(container, event.Health) {
+		llog.Info("Container health changed", "container", container.Name, "health", event.Health.String())
+		if previousHealth == api.ContainerHealthy && event.Health == api.ContainerUnhealthy {
+			mtask.stopUnhealthyDependents(container)
+		}
+		if event.Status <= container.GetKnownStatus() {
+			// The status itself is redundant, but the health change should
+			// still be passed on
+			mtask.emitContainerHealthChange(event)
+			return
+		}
+	}
+
+	// A container that exits before it's inspected after starting is reported
+	// as running along with its exit code. It has already stopped, and the
+	// exit code must be kept even if its stop event is missed
+	if event.Status == api.ContainerRunning && event.ExitCode != nil {
+		llog.Info("Container exited before it was seen running", "container", container.Name, "exitCode", *event.ExitCode)
+		event.Status = api.ContainerStopped
+	}
+
 	// Cases: If this is a forward transition (else) update the container to be known to be at that status.
 	// If this is a backwards transition stopped->running, the first time set it
 	// to be known running so it will be stopped. Subsequently ignore these backward transitions
@@ -242,7 +327,9 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 	container.SetKnownStatus(event.Status)
 
 	if event.Error != nil {
-		if container.ApplyingError == nil {
+		// Being killed for its memory usage is why the container stopped,
+		// whatever went wrong before
+		if container.ApplyingError == nil || event.Error.ErrorName() == (OutOfMemoryError{}).ErrorName() {
 			container.ApplyingError = api.NewNamedError(event.Error)
 		}
 		if event.Status == api.ContainerStopped {
@@ -258,6 +345,13 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 				container.SetKnownStatus(currentKnownStatus)
 				return
 			}
+			// An essential container that may still be running is retried a
+			// few times, so the task isn't reported as stopped while it is
+			if container.Essential && event.ExitCode == nil && mtask.retryEssentialStop(container) {
+				llog.Warn("Error for 'docker stop' of essential container; retrying", "container", container, "err", event.Error)
+				container.SetKnownStatus(currentKnownStatus)
+				return
+			}
 			// If we were trying to transition to stopped and had an error, we
 			// clearly can't just continue trying to transition it to stopped
 			// again and again... In this case, assume it's stopped (or close
@@ -296,6 +390,15 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 	if event.Volumes != nil {
 		mtask.UpdateMountPoints(container, event.Volumes)
 	}
+	updateContainerTimestamps(container, event.DockerContainerMetadata)
+
+	if event.Status >= api.ContainerRunning {
+		mtask.restarts.done(container.Name)
+	}
+	if event.Status == api.ContainerStopped && mtask.restartCrashedContainer(container) {
+		// The container isn't reported as stopped while it's being restarted
+		return
+	}
 
 	mtask.engine.emitContainerEvent(mtask.Task, container, "")
 	if mtask.UpdateStatus() {
@@ -305,9 +408,162 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 	}
 }
 
+// restartCrashedContainer schedules the restart of a non-essential container
+// that exited with a non-zero code while the task is running, returning true
+// if it's restarted. Once it has been restarted too often, it's left stopped
+// with the reason why
+func (mtask *managedTask) restartCrashedContainer(container *api.Container) bool {
+	if !mtask.restarts.enabled() || container.Essential || container.IsInternal {
+		return false
+	}
+	if container.DesiredTerminal() || mtask.GetDesiredStatus().Terminal() {
+		return false
+	}
+	// Docker restarts containers with a restart policy by itself
+	if container.RestartPolicy != "" && container.RestartPolicy != api.RestartPolicyNo {
+		return false
+	}
+	exitCode := container.KnownExitCode
+	if exitCode == nil || *exitCode == 0 {
+		return false
+	}
+	delay, ok := mtask.restarts.crashed(container.Name)
+	if !ok {
+		seelog.Warnf("Container %s of task %s exited with code %d; not restarting it again", container.Name, mtask.Arn, *exitCode)
+		container.ApplyingError = api.NewNamedError(ContainerRestartLimitError{
+			fmt.Sprintf("Container exited with code %d after being restarted %d times", *exitCode, mtask.restarts.maxRestarts),
+		})
+		return false
+	}
+	seelog.Infof("Container %s of task %s exited with code %d; restarting it in %s", container.Name, mtask.Arn, *exitCode, delay)
+	timer := mtask.time().After(delay)
+	go func() {
+		<-timer
+		// The new container gets a name of its own, so the crashed one is
+		// removed rather than left behind
+		if err := mtask.engine.removeContainer(mtask.Task, container); err != nil {
+			seelog.Warnf("Unable to remove crashed container %s of task %s: %v", container.Name, mtask.Arn, err)
+		}
+		mtask.containerRestarts <- container
+	}()
+	return true
+}
+
+// restartContainer moves a crashed container back to pulled, so that it's
+// created and started again
+func (mtask *managedTask) restartContainer(container *api.Container) {
+	if !mtask.restarts.restart(container.Name) {
+		return
+	}
+	seelog.Infof("Restarting container %s of task %s", container.Name, mtask.Arn)
+	container.KnownExitCode = nil
+	container.ApplyingError = nil
+	container.SetHealthStatus(api.ContainerHealthUnknown)
+	container.SetKnownStatus(api.ContainerPulled)
+}
+
+// abandonContainerRestarts reports the containers waiting to be restarted as
+// stopped, as the task is stopping
+func (mtask *managedTask) abandonContainerRestarts() {
+	for _, name := range mtask.restarts.abandon() {
+		container, ok := mtask.ContainerByName(name)
+		if ok {
+			mtask.engine.emitContainerEvent(mtask.Task, container, "")
+		}
+	}
+}
+
+// updateContainerTimestamps records when the container was created and
+// started, if the metadata knows
+func updateContainerTimestamps(container *api.Container, metadata DockerContainerMetadata) {
+	if !metadata.CreatedAt.IsZero() {
+		container.SetCreatedAt(metadata.CreatedAt)
+	}
+	if !metadata.StartedAt.IsZero() {
+		container.SetStartedAt(metadata.StartedAt)
+	}
+}
+
+// retryEssentialStop records a failed attempt to stop an essential container,
+// returning true if it should be attempted again
+func (mtask *managedTask) retryEssentialStop(container *api.Container) bool {
+	if mtask.essentialStopAttempts == nil {
+		mtask.essentialStopAttempts = make(map[string]int)
+	}
+	mtask.essentialStopAttempts[container.Name]++
+	return mtask.essentialStopAttempts[container.Name] < maxEssentialContainerStopAttempts
+}
+
+// updateContainerHealth records the health reported by a container change,
+// returning true if the container's health changed
+func (mtask *managedTask) updateContainerHealth(container *api.Container, health api.ContainerHealthStatus) bool {
+	currentHealth := container.GetHealthStatus()
+	switch health {
+	case api.ContainerHealthUnknown:
+		return false
+	case api.ContainerHealthStarting:
+		// Every inspection of a running container with a health check reports
+		// it as starting; only use that before a result has been reported
+		if currentHealth != api.ContainerHealthUnknown {
+			return false
+		}
+	}
+	if currentHealth == health {
+		return false
+	}
+	container.SetHealthStatus(health)
+	return true
+}
+
+// stopUnhealthyDependents stops the containers that waited on the container to
+// be healthy and asked to be stopped once it isn't. Only the change from
+// healthy to unhealthy stops them, so a dependency whose health flips back and
+// forth doesn't stop them again
+func (mtask *managedTask) stopUnhealthyDependents(dependency *api.Container) {
+	for _, container := range mtask.Containers {
+		if !container.StopOnUnhealthyDependency || container.DesiredTerminal() {
+			continue
+		}
+		for _, dependsOn := range container.DependsOn {
+			if dependsOn.Container != dependency.Name || dependsOn.Condition != api.DependencyConditionHealthy {
+				continue
+			}
+			seelog.Infof("Stopping container %s of task %s as its dependency %s is unhealthy", container.Name, mtask.Arn, dependency.Name)
+			container.ApplyingError = api.NewNamedError(UnhealthyDependencyError{"Dependency " + dependency.Name + " became unhealthy"})
+			container.SetDesiredStatus(api.ContainerStopped)
+			break
+		}
+	}
+}
+
+// containersStopping returns true if a running container is being stopped
+// while the task keeps running
+func (mtask *managedTask) containersStopping() bool {
+	for _, container := range mtask.Containers {
+		if container.DesiredTerminal() && container.GetKnownStatus() == api.ContainerRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// emitContainerHealthChange passes on a container change that only changed the
+// container's health, and updates the task in case it was waiting on the
+// container's health check
+func (mtask *managedTask) emitContainerHealthChange(event DockerContainerChangeEvent) {
+	err := mtask.engine.containerChangeEventStream.WriteToEventStream(event)
+	if err != nil {
+		seelog.Warnf("Failed to write container change event to event stream, err %v", err)
+	}
+	if mtask.UpdateStatus() {
+		log.Debug("Container health change also resulted in task change", "task", mtask.Task)
+		mtask.engine.emitTaskEvent(mtask.Task, "")
+	}
+}
+
 func (mtask *managedTask) steadyState() bool {
 	taskKnownStatus := mtask.GetKnownStatus()
-	return taskKnownStatus == api.TaskRunning && taskKnownStatus >= mtask.GetDesiredStatus()
+	return taskKnownStatus == api.TaskRunning && taskKnownStatus >= mtask.GetDesiredStatus() && !mtask.restarts.anyRestarting() && !mtask.containersStopping()
 }
 
 // waitEvent waits for any event to occur. If the event is the passed in
@@ -324,6 +580,9 @@ func (mtask *managedTask) waitEvent(stopWaiting <-chan bool) bool {
 		log.Debug("Got container event for task", "task", mtask.Task)
 		mtask.handleContainerChange(dockerChange)
 		return false
+	case container := <-mtask.containerRestarts:
+		mtask.restartContainer(container)
+		return false
 	case b := <-stopWaiting:
 		log.Debug("No longer waiting", "task", mtask.Task)
 		return b
@@ -361,6 +620,10 @@ func (mtask *managedTask) containerNextState(container *api.Container) (api.Cont
 			// If it's not currently running we do not need to do anything to make it become stopped.
 			return nextState, false, true
 		}
+		if !dependencygraph.StopDependenciesAreResolved(container, mtask.Containers) {
+			clog.Debug("Can't stop container yet; containers depending on it are still running")
+			return api.ContainerStatusNone, false, false
+		}
 	} else {
 		nextState = containerKnownStatus + 1
 	}
@@ -383,6 +646,10 @@ func (mtask *managedTask) progressContainers() {
 	// Map of containerName -> applyingTransition
 	transitionsMap := make(map[string]api.ContainerStatus)
 
+	// Stops hold a slot for as long as they run, which bounds the number of
+	// containers being stopped at the same time
+	stopSlots := make(chan struct{}, mtask.engine.containerStopConcurrency())
+
 	anyCanTransition := false
 	for _, cont := range mtask.Containers {
 		nextState, shouldCallTransitionFunc, canTransition := mtask.containerNextState(cont)
@@ -398,6 +665,10 @@ func (mtask *managedTask) progressContainers() {
 		}
 		transitionsMap[cont.Name] = nextState
 		go func(container *api.Container, nextStatus api.ContainerStatus) {
+			if nextStatus == api.ContainerStopped {
+				stopSlots <- struct{}{}
+				defer func() { <-stopSlots }()
+			}
 			mtask.engine.transitionContainer(mtask.Task, container, nextStatus)
 			transitionChange <- true
 			transitionChangeContainer <- container.Name
@@ -405,6 +676,13 @@ func (mtask *managedTask) progressContainers() {
 	}
 
 	if !anyCanTransition {
+		if !mtask.GetDesiredStatus().Terminal() && (mtask.WaitingOnHealthCheck() || mtask.WaitingOnDependencies()) {
+			// The containers are running; the task will progress once their
+			// health checks report a result or their dependencies exit
+			log.Debug("Waiting on container health checks or dependencies", "task", mtask.Task)
+			mtask.waitEvent(nil)
+			return
+		}
 		log.Crit("Task in a bad state; it's not steadystate but no containers want to transition", "task", mtask.Task)
 		if mtask.GetDesiredStatus().Terminal() {
 			// Ack, really bad. We want it to stop but the containers don't think
@@ -463,18 +741,33 @@ func (mtask *managedTask) time() ttime.Time {
 	return mtask._time
 }
 
-func (mtask *managedTask) cleanupTask(taskStoppedDuration time.Duration) {
-	cleanupTimeDuration := mtask.GetKnownStatusTime().Add(taskStoppedDuration).Sub(ttime.Now())
-	// There is a potential deadlock here if cleanupTime is negative. Ignore the computed
-	// value in this case in favor of the default config value.
-	if cleanupTimeDuration < 0 {
-		log.Debug("Task Cleanup Duration is too short. Resetting to " + config.DefaultTaskCleanupWaitDuration.String())
-		cleanupTimeDuration = config.DefaultTaskCleanupWaitDuration
+// cleanupDelay returns how long to wait before cleaning up a task that
+// stopped at stoppedTime, so that its containers are kept for taskStoppedDuration
+// after it stopped. The time the task stopped is saved with the state, so the
+// wait is not restarted when the agent restarts
+func cleanupDelay(stoppedTime time.Time, taskStoppedDuration time.Duration, now time.Time) time.Duration {
+	if stoppedTime.IsZero() {
+		// The state doesn't say when the task stopped
+		return taskStoppedDuration
 	}
+	delay := stoppedTime.Add(taskStoppedDuration).Sub(now)
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+func (mtask *managedTask) cleanupTask(taskStoppedDuration time.Duration) {
+	cleanupTimeDuration := cleanupDelay(mtask.GetKnownStatusTime(), taskStoppedDuration, ttime.Now())
+	log.Debug("Waiting to clean up task", "task", mtask.Task, "wait", cleanupTimeDuration.String())
 	cleanupTime := mtask.time().After(cleanupTimeDuration)
 	cleanupTimeBool := make(chan bool)
 	go func() {
-		<-cleanupTime
+		select {
+		case <-cleanupTime:
+		case <-mtask.cleanupNow:
+			log.Info("Cleaning up task before its cleanup wait duration to reclaim disk space", "task", mtask.Task)
+		}
 		cleanupTimeBool <- true
 		close(cleanupTimeBool)
 	}()
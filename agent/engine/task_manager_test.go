@@ -0,0 +1,809 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestUpdateContainerHealth(t *testing.T) {
+	testCases := []struct {
+		name            string
+		currentHealth   api.ContainerHealthStatus
+		reportedHealth  api.ContainerHealthStatus
+		expectedChanged bool
+		expectedHealth  api.ContainerHealthStatus
+	}{
+		{"nothing reported", api.ContainerHealthUnknown, api.ContainerHealthUnknown, false, api.ContainerHealthUnknown},
+		{"grace period starts", api.ContainerHealthUnknown, api.ContainerHealthStarting, true, api.ContainerHealthStarting},
+		{"grace period ends", api.ContainerHealthStarting, api.ContainerHealthy, true, api.ContainerHealthy},
+		{"becomes unhealthy", api.ContainerHealthy, api.ContainerUnhealthy, true, api.ContainerUnhealthy},
+		{"recovers", api.ContainerUnhealthy, api.ContainerHealthy, true, api.ContainerHealthy},
+		{"unchanged", api.ContainerHealthy, api.ContainerHealthy, false, api.ContainerHealthy},
+		{"inspected after result", api.ContainerUnhealthy, api.ContainerHealthStarting, false, api.ContainerUnhealthy},
+	}
+
+	mtask := &managedTask{Task: &api.Task{}}
+	for _, tc := range testCases {
+		container := &api.Container{Health: tc.currentHealth}
+		changed := mtask.updateContainerHealth(container, tc.reportedHealth)
+		assert.Equal(t, tc.expectedChanged, changed, tc.name)
+		assert.Equal(t, tc.expectedHealth, container.GetHealthStatus(), tc.name)
+	}
+}
+
+// stoppingManagedTask creates a managed task whose containers are all running
+// and desired to stop. The engine's task and container events are discarded
+// until the returned function is called
+func stoppingManagedTask(client DockerClient, stopConcurrency int, containers ...*api.Container) (*managedTask, func()) {
+	task := &api.Task{Arn: "stoppingTask", Containers: containers}
+	task.SetKnownStatus(api.TaskRunning)
+	task.SetDesiredStatus(api.TaskStopped)
+	state := dockerstate.NewDockerTaskEngineState()
+	state.AddTask(task)
+	for _, container := range containers {
+		container.SetKnownStatus(api.ContainerRunning)
+		container.SetDesiredStatus(api.ContainerStopped)
+		state.AddContainer(&api.DockerContainer{DockerId: "id-" + container.Name, DockerName: container.Name, Container: container}, task)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	containerChangeEventStream := eventstream.NewEventStream("stoppingTask", ctx)
+	containerChangeEventStream.StartListening()
+	cfg := defaultConfig
+	cfg.ContainerStopConcurrency = stopConcurrency
+	engine := NewDockerTaskEngine(&cfg, client, nil, containerChangeEventStream, nil, state)
+	go func() {
+		for {
+			select {
+			case <-engine.containerEvents:
+			case <-engine.taskEvents:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return engine.newManagedTask(task), cancel
+}
+
+func TestProgressContainersBoundsStopConcurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+
+	var containers []*api.Container
+	for _, name := range []string{"c1", "c2", "c3", "c4", "c5", "c6"} {
+		containers = append(containers, &api.Container{Name: name})
+	}
+	mtask, done := stoppingManagedTask(client, 2, containers...)
+	defer done()
+
+	var lock sync.Mutex
+	stopping := 0
+	maxStopping := 0
+	client.EXPECT().StopContainer(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(id string, stopTimeout, timeout time.Duration) {
+		lock.Lock()
+		stopping++
+		if stopping > maxStopping {
+			maxStopping = stopping
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		stopping--
+		lock.Unlock()
+	}).Return(DockerContainerMetadata{}).Times(len(containers))
+
+	mtask.progressContainers()
+
+	assert.Equal(t, api.TaskStopped, mtask.GetKnownStatus())
+	assert.Equal(t, 2, maxStopping, "Incorrect number of containers stopped at the same time")
+}
+
+func TestProgressContainersStopsLinkedContainersFirst(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+
+	db := &api.Container{Name: "db"}
+	php := &api.Container{Name: "php", Links: []string{"db"}}
+	webserver := &api.Container{Name: "webserver", Links: []string{"php:app"}}
+	sidecar := &api.Container{Name: "sidecar"}
+	mtask, done := stoppingManagedTask(client, 10, db, php, webserver, sidecar)
+	defer done()
+
+	var lock sync.Mutex
+	var stopped []string
+	client.EXPECT().StopContainer(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(id string, stopTimeout, timeout time.Duration) {
+		lock.Lock()
+		defer lock.Unlock()
+		stopped = append(stopped, id)
+	}).Return(DockerContainerMetadata{}).Times(4)
+
+	for i := 0; i < 4 && !mtask.GetKnownStatus().Terminal(); i++ {
+		mtask.progressContainers()
+	}
+
+	assert.Equal(t, api.TaskStopped, mtask.GetKnownStatus())
+	order := make(map[string]int)
+	for i, id := range stopped {
+		order[id] = i
+	}
+	assert.True(t, order["id-webserver"] < order["id-php"], "webserver should stop before php: %v", stopped)
+	assert.True(t, order["id-php"] < order["id-db"], "php should stop before db: %v", stopped)
+}
+
+func TestProgressContainersStopsInDependencyOrder(t *testing.T) {
+	testCases := []struct {
+		name       string
+		containers []*api.Container
+		order      [][]string
+	}{
+		{
+			name: "diamond",
+			containers: []*api.Container{
+				{Name: "db"},
+				{Name: "api", DependsOn: []api.DependsOn{{Container: "db", Condition: api.DependencyConditionHealthy}}},
+				{Name: "worker", Links: []string{"db"}},
+				{Name: "proxy", Links: []string{"api", "worker"}},
+				{Name: "logs"},
+			},
+			order: [][]string{{"logs", "proxy"}, {"api", "worker"}, {"db"}},
+		},
+		{
+			name: "volumes",
+			containers: []*api.Container{
+				{Name: "data"},
+				{Name: "app", VolumesFrom: []api.VolumeFrom{{SourceContainer: "data"}}},
+			},
+			order: [][]string{{"app", "data"}},
+		},
+		{
+			name: "cycle",
+			containers: []*api.Container{
+				{Name: "a", Links: []string{"b"}},
+				{Name: "b", Links: []string{"a"}},
+				{Name: "c", Links: []string{"a"}},
+			},
+			// a and b don't wait for each other, but a still waits for c
+			order: [][]string{{"b", "c"}, {"a"}},
+		},
+	}
+	for _, tc := range testCases {
+		ctrl := gomock.NewController(t)
+		client := NewMockDockerClient(ctrl)
+		mtask, done := stoppingManagedTask(client, 10, tc.containers...)
+
+		// Each call of progressContainers stops the containers that can be
+		// stopped, and waits for them to stop
+		var lock sync.Mutex
+		var order [][]string
+		client.EXPECT().StopContainer(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(id string, stopTimeout, timeout time.Duration) {
+			lock.Lock()
+			defer lock.Unlock()
+			order[len(order)-1] = append(order[len(order)-1], strings.TrimPrefix(id, "id-"))
+		}).Return(DockerContainerMetadata{}).Times(len(tc.containers))
+		for i := 0; i < len(tc.containers) && !mtask.GetKnownStatus().Terminal(); i++ {
+			order = append(order, nil)
+			mtask.progressContainers()
+			sort.Strings(order[i])
+		}
+
+		assert.Equal(t, api.TaskStopped, mtask.GetKnownStatus(), tc.name)
+		assert.Equal(t, tc.order, order, "Wrong stop order for %s", tc.name)
+		done()
+		ctrl.Finish()
+	}
+}
+
+func TestEssentialContainerStopFailureIsRetried(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+
+	essential := &api.Container{Name: "essential", Essential: true}
+	nonEssential := &api.Container{Name: "nonessential"}
+	mtask, done := stoppingManagedTask(client, 10, essential, nonEssential)
+	defer done()
+
+	stopError := DockerContainerMetadata{Error: CannotXContainerError{"Stop", "error"}}
+	// A non-essential container that fails to stop is assumed to be stopped
+	client.EXPECT().StopContainer("id-nonessential", gomock.Any(), gomock.Any()).Return(stopError)
+	gomock.InOrder(
+		client.EXPECT().StopContainer("id-essential", gomock.Any(), gomock.Any()).Return(stopError),
+		client.EXPECT().StopContainer("id-essential", gomock.Any(), gomock.Any()).Return(DockerContainerMetadata{}),
+	)
+
+	mtask.progressContainers()
+	assert.Equal(t, api.ContainerStopped, nonEssential.GetKnownStatus())
+	assert.Equal(t, api.ContainerRunning, essential.GetKnownStatus())
+	assert.Equal(t, api.TaskRunning, mtask.GetKnownStatus(), "Task shouldn't stop before its essential container")
+
+	mtask.progressContainers()
+	assert.Equal(t, api.ContainerStopped, essential.GetKnownStatus())
+	assert.Equal(t, api.TaskStopped, mtask.GetKnownStatus())
+}
+
+func TestEssentialContainerStopFailureGivesUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+
+	essential := &api.Container{Name: "essential", Essential: true}
+	mtask, done := stoppingManagedTask(client, 10, essential)
+	defer done()
+
+	client.EXPECT().StopContainer("id-essential", gomock.Any(), gomock.Any()).Return(DockerContainerMetadata{
+		Error: CannotXContainerError{"Stop", "error"},
+	}).Times(maxEssentialContainerStopAttempts)
+
+	for i := 0; i < maxEssentialContainerStopAttempts; i++ {
+		mtask.progressContainers()
+	}
+	assert.Equal(t, api.ContainerStopped, essential.GetKnownStatus())
+	assert.Equal(t, api.TaskStopped, mtask.GetKnownStatus())
+}
+
+func TestDependsOnHealthyWaitsForHealthCheck(t *testing.T) {
+	db := &api.Container{Name: "db", DesiredStatus: api.ContainerRunning, KnownStatus: api.ContainerRunning, Health: api.ContainerHealthStarting}
+	app := &api.Container{
+		Name:          "app",
+		DesiredStatus: api.ContainerRunning,
+		DependsOn:     []api.DependsOn{{Container: "db", Condition: api.DependencyConditionHealthy}},
+	}
+	task := &api.Task{Arn: "dependsOnTask", DesiredStatus: api.TaskRunning, Containers: []*api.Container{db, app}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	containerChangeEventStream := eventstream.NewEventStream("dependsOnTask", ctx)
+	containerChangeEventStream.StartListening()
+	engine := NewDockerTaskEngine(&defaultConfig, nil, nil, containerChangeEventStream, nil, dockerstate.NewDockerTaskEngineState())
+	mtask := engine.newManagedTask(task)
+
+	_, _, canTransition := mtask.containerNextState(app)
+	assert.False(t, canTransition, "app shouldn't progress before db is healthy")
+	assert.True(t, mtask.WaitingOnDependencies(), "Task should wait on db's health check")
+
+	// While waiting, the task manager picks up db becoming healthy
+	go func() {
+		mtask.dockerMessages <- dockerContainerChange{
+			container: db,
+			event:     DockerContainerChangeEvent{Status: api.ContainerRunning, DockerContainerMetadata: DockerContainerMetadata{Health: api.ContainerHealthy}},
+		}
+	}()
+	progressed := make(chan struct{})
+	go func() {
+		mtask.progressContainers()
+		close(progressed)
+	}()
+	select {
+	case <-progressed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the task to progress")
+	}
+
+	assert.Equal(t, api.ContainerHealthy, db.GetHealthStatus())
+	assert.Equal(t, api.TaskRunning, mtask.GetDesiredStatus(), "Task shouldn't be stopped while waiting on a dependency")
+	nextState, _, canTransition := mtask.containerNextState(app)
+	assert.True(t, canTransition, "app should progress once db is healthy")
+	assert.Equal(t, api.ContainerPulled, nextState)
+}
+
+func TestUnhealthyDependencyStopsDependents(t *testing.T) {
+	dependsOn := func(condition string) []api.DependsOn {
+		return []api.DependsOn{{Container: "db", Condition: condition}}
+	}
+	running := func(name string) *api.Container {
+		return &api.Container{Name: name, DesiredStatus: api.ContainerRunning, KnownStatus: api.ContainerRunning}
+	}
+	db := running("db")
+	db.Health = api.ContainerHealthStarting
+	app := running("app")
+	app.DependsOn = dependsOn(api.DependencyConditionHealthy)
+	app.StopOnUnhealthyDependency = true
+	worker := running("worker")
+	worker.DependsOn = dependsOn(api.DependencyConditionHealthy)
+	proxy := running("proxy")
+	proxy.DependsOn = dependsOn(api.DependencyConditionStart)
+	proxy.StopOnUnhealthyDependency = true
+	task := &api.Task{Arn: "unhealthyDependencyTask", DesiredStatus: api.TaskRunning, KnownStatus: api.TaskRunning, Containers: []*api.Container{db, app, worker, proxy}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	containerChangeEventStream := eventstream.NewEventStream("unhealthyDependencyTask", ctx)
+	containerChangeEventStream.StartListening()
+	engine := NewDockerTaskEngine(&defaultConfig, nil, nil, containerChangeEventStream, nil, dockerstate.NewDockerTaskEngineState())
+	engine.taskEvents = make(chan api.TaskStateChange, 10)
+	mtask := engine.newManagedTask(task)
+
+	healthChange := func(health api.ContainerHealthStatus) {
+		mtask.handleContainerChange(dockerContainerChange{
+			container: db,
+			event:     DockerContainerChangeEvent{Status: api.ContainerRunning, DockerContainerMetadata: DockerContainerMetadata{Health: health}},
+		})
+	}
+
+	// A dependency that was never healthy doesn't stop its dependents
+	healthChange(api.ContainerUnhealthy)
+	assert.Equal(t, api.ContainerRunning, app.GetDesiredStatus(), "app shouldn't stop before db was healthy")
+	assert.True(t, mtask.steadyState())
+
+	healthChange(api.ContainerHealthy)
+	healthChange(api.ContainerUnhealthy)
+	assert.Equal(t, api.ContainerStopped, app.GetDesiredStatus(), "app should stop once db turns unhealthy")
+	if assert.NotNil(t, app.ApplyingError) {
+		assert.Equal(t, "UnhealthyDependencyError", app.ApplyingError.ErrorName())
+	}
+	assert.Equal(t, api.ContainerRunning, worker.GetDesiredStatus(), "worker didn't ask to be stopped")
+	assert.Equal(t, api.ContainerRunning, proxy.GetDesiredStatus(), "proxy doesn't wait on db's health")
+	assert.Equal(t, api.TaskRunning, mtask.GetDesiredStatus())
+	assert.False(t, mtask.steadyState(), "The task should progress to stop app")
+	nextState, _, canTransition := mtask.containerNextState(app)
+	assert.True(t, canTransition)
+	assert.Equal(t, api.ContainerStopped, nextState)
+
+	// Further unhealthy reports aren't a change of health and stop nothing
+	worker.StopOnUnhealthyDependency = true
+	healthChange(api.ContainerUnhealthy)
+	assert.Equal(t, api.ContainerRunning, worker.GetDesiredStatus())
+}
+
+// runningManagedTask creates a managed task for a task with the container,
+// which is known to be at status. The engine's container events are returned
+// rather than sent upstream
+func runningManagedTask(container *api.Container, status api.ContainerStatus) (*managedTask, <-chan api.ContainerStateChange, func()) {
+	task := &api.Task{Arn: "runningTask", Containers: []*api.Container{container}}
+	task.SetKnownStatus(api.TaskRunning)
+	task.SetDesiredStatus(api.TaskRunning)
+	container.SetKnownStatus(status)
+	container.SetDesiredStatus(api.ContainerRunning)
+	state := dockerstate.NewDockerTaskEngineState()
+	state.AddTask(task)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	containerChangeEventStream := eventstream.NewEventStream("runningTask", ctx)
+	containerChangeEventStream.StartListening()
+	engine := NewDockerTaskEngine(&defaultConfig, nil, nil, containerChangeEventStream, nil, state)
+	containerEvents := make(chan api.ContainerStateChange, 10)
+	engine.containerEvents = containerEvents
+	engine.taskEvents = make(chan api.TaskStateChange, 10)
+	return engine.newManagedTask(task), containerEvents, cancel
+}
+
+func TestContainerStopReasonNonZeroExit(t *testing.T) {
+	for _, exitCode := range []int{0, 2} {
+		container := &api.Container{Name: "c"}
+		mtask, containerEvents, done := runningManagedTask(container, api.ContainerRunning)
+		defer done()
+
+		code := exitCode
+		mtask.handleContainerChange(dockerContainerChange{
+			container: container,
+			event: DockerContainerChangeEvent{
+				Status:                  api.ContainerStopped,
+				DockerContainerMetadata: DockerContainerMetadata{ExitCode: &code},
+			},
+		})
+
+		change := <-containerEvents
+		assert.Equal(t, api.ContainerStopped, change.Status)
+		if assert.NotNil(t, change.ExitCode) {
+			assert.Equal(t, exitCode, *change.ExitCode)
+		}
+		if exitCode == 0 {
+			assert.Empty(t, change.Reason)
+		} else {
+			assert.Equal(t, "Container exited with code 2", change.Reason)
+		}
+	}
+}
+
+func TestContainerStopReasonOutOfMemory(t *testing.T) {
+	container := &api.Container{Name: "c"}
+	mtask, containerEvents, done := runningManagedTask(container, api.ContainerRunning)
+	defer done()
+
+	metadata := metadataFromContainer(&docker.Container{
+		ID:    "id",
+		State: docker.State{OOMKilled: true, ExitCode: 137, FinishedAt: time.Now()},
+	})
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event:     DockerContainerChangeEvent{Status: api.ContainerStopped, DockerContainerMetadata: metadata},
+	})
+
+	change := <-containerEvents
+	assert.Equal(t, api.ContainerStopped, change.Status)
+	assert.Contains(t, change.Reason, OutOfMemoryError{}.Error())
+	if assert.NotNil(t, change.ExitCode) {
+		assert.Equal(t, 137, *change.ExitCode)
+	}
+}
+
+func TestContainerStopReasonPullFailure(t *testing.T) {
+	container := &api.Container{Name: "c"}
+	mtask, containerEvents, done := runningManagedTask(container, api.ContainerStatusNone)
+	defer done()
+
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: DockerContainerChangeEvent{
+			Status:                  api.ContainerPulled,
+			DockerContainerMetadata: DockerContainerMetadata{Error: CannotXContainerError{"Pull", "repository does not exist"}},
+		},
+	})
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event:     DockerContainerChangeEvent{Status: api.ContainerStopped},
+	})
+
+	// Pulled isn't reported upstream
+	change := <-containerEvents
+	assert.Equal(t, api.ContainerStopped, change.Status)
+	assert.Equal(t, "CannotPullContainerError: repository does not exist", change.Reason)
+}
+
+func TestContainerStopReasonStartTimeout(t *testing.T) {
+	container := &api.Container{Name: "c"}
+	mtask, containerEvents, done := runningManagedTask(container, api.ContainerCreated)
+	defer done()
+
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: DockerContainerChangeEvent{
+			Status:                  api.ContainerRunning,
+			DockerContainerMetadata: DockerContainerMetadata{Error: &DockerTimeoutError{time.Minute, "started"}},
+		},
+	})
+	assert.Equal(t, api.ContainerCreated, container.GetKnownStatus())
+	assert.Equal(t, api.ContainerStopped, container.GetDesiredStatus(), "Expected the container that timed out to be stopped")
+
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event:     DockerContainerChangeEvent{Status: api.ContainerStopped},
+	})
+
+	change := <-containerEvents
+	assert.Equal(t, api.ContainerStopped, change.Status)
+	assert.Equal(t, "DockerTimeoutError: Could not transition to started; timed out after waiting 1m0s", change.Reason)
+}
+
+func TestContainerStopReasonOutOfMemoryAfterError(t *testing.T) {
+	container := &api.Container{Name: "c"}
+	mtask, containerEvents, done := runningManagedTask(container, api.ContainerRunning)
+	defer done()
+	// The image failed to pull, but was already on the instance
+	container.ApplyingError = api.NewNamedError(CannotXContainerError{"Pull", "registry unavailable"})
+
+	metadata := metadataFromContainer(&docker.Container{
+		ID:    "id",
+		State: docker.State{OOMKilled: true, ExitCode: 137, FinishedAt: time.Now()},
+	})
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event:     DockerContainerChangeEvent{Status: api.ContainerStopped, DockerContainerMetadata: metadata},
+	})
+
+	change := <-containerEvents
+	assert.Equal(t, "OutOfMemoryError: Container killed due to memory usage", change.Reason)
+}
+
+func TestContainerExitedBeforeSeenRunning(t *testing.T) {
+	container := &api.Container{Name: "c"}
+	mtask, containerEvents, done := runningManagedTask(container, api.ContainerCreated)
+	defer done()
+
+	// The container exited before it was inspected after starting
+	metadata := metadataFromContainer(&docker.Container{
+		ID:    "id",
+		State: docker.State{ExitCode: 1, FinishedAt: time.Now()},
+	})
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event:     DockerContainerChangeEvent{Status: api.ContainerRunning, DockerContainerMetadata: metadata},
+	})
+
+	assert.Equal(t, api.ContainerStopped, container.GetKnownStatus())
+	change := <-containerEvents
+	assert.Equal(t, api.ContainerStopped, change.Status)
+	assert.Equal(t, "Container exited with code 1", change.Reason)
+	if assert.NotNil(t, change.ExitCode) {
+		assert.Equal(t, 1, *change.ExitCode)
+	}
+}
+
+// restartingManagedTask returns a running task whose crashed non-essential
+// containers are restarted up to maxRestarts times
+func restartingManagedTask(client DockerClient, maxRestarts int, containers ...*api.Container) (*managedTask, <-chan api.ContainerStateChange, func()) {
+	task := &api.Task{Arn: "restartingTask", Containers: containers}
+	task.SetKnownStatus(api.TaskRunning)
+	task.SetDesiredStatus(api.TaskRunning)
+	state := dockerstate.NewDockerTaskEngineState()
+	state.AddTask(task)
+	for _, container := range containers {
+		container.SetKnownStatus(api.ContainerRunning)
+		container.SetDesiredStatus(api.ContainerRunning)
+		container.SentStatus = api.ContainerRunning
+		state.AddContainer(&api.DockerContainer{DockerId: "id-" + container.Name, DockerName: container.Name, Container: container}, task)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	containerChangeEventStream := eventstream.NewEventStream("restartingTask", ctx)
+	containerChangeEventStream.StartListening()
+	cfg := defaultConfig
+	cfg.MaxContainerRestarts = maxRestarts
+	engine := NewDockerTaskEngine(&cfg, client, nil, containerChangeEventStream, nil, state)
+	containerEvents := make(chan api.ContainerStateChange, 10)
+	engine.containerEvents = containerEvents
+	engine.taskEvents = make(chan api.TaskStateChange, 10)
+	return engine.newManagedTask(task), containerEvents, cancel
+}
+
+func crashContainer(mtask *managedTask, container *api.Container, exitCode int) {
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: DockerContainerChangeEvent{
+			Status:                  api.ContainerStopped,
+			DockerContainerMetadata: DockerContainerMetadata{ExitCode: &exitCode},
+		},
+	})
+}
+
+func TestRestartCrashedContainerGivesUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+	mockTime := mock_ttime.NewMockTime(ctrl)
+
+	app := &api.Container{Name: "app", Essential: true}
+	sidecar := &api.Container{Name: "sidecar"}
+	mtask, containerEvents, done := restartingManagedTask(client, 1, app, sidecar)
+	defer done()
+	mtask._time = mockTime
+
+	timer := make(chan time.Time, 1)
+	mockTime.EXPECT().After(gomock.Any()).Return((<-chan time.Time)(timer))
+	client.EXPECT().RemoveContainer("sidecar", gomock.Any()).Return(nil)
+
+	crashContainer(mtask, sidecar, 1)
+	assert.Equal(t, api.ContainerStopped, sidecar.GetKnownStatus())
+	assert.Len(t, containerEvents, 0, "A container being restarted should not be reported as stopped")
+	assert.Equal(t, api.TaskRunning, mtask.GetDesiredStatus())
+
+	timer <- time.Now()
+	select {
+	case container := <-mtask.containerRestarts:
+		mtask.restartContainer(container)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the container to be restarted")
+	}
+	assert.Equal(t, api.ContainerPulled, sidecar.GetKnownStatus())
+	assert.Nil(t, sidecar.KnownExitCode)
+	assert.False(t, mtask.steadyState(), "The restarted container should be progressed")
+
+	mtask.handleContainerChange(dockerContainerChange{container: sidecar, event: DockerContainerChangeEvent{Status: api.ContainerRunning}})
+	assert.True(t, mtask.steadyState())
+
+	// The restart limit has been reached
+	crashContainer(mtask, sidecar, 2)
+	change := <-containerEvents
+	assert.Equal(t, "sidecar", change.ContainerName)
+	assert.Equal(t, api.ContainerStopped, change.Status)
+	assert.Equal(t, "ContainerRestartLimitError: Container exited with code 2 after being restarted 1 times", change.Reason)
+	assert.Equal(t, api.TaskRunning, mtask.GetDesiredStatus())
+}
+
+func TestEssentialContainerCrashIsNotRestarted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+	mockTime := mock_ttime.NewMockTime(ctrl)
+
+	app := &api.Container{Name: "app", Essential: true}
+	sidecar := &api.Container{Name: "sidecar"}
+	mtask, containerEvents, done := restartingManagedTask(client, 3, app, sidecar)
+	defer done()
+	mtask._time = mockTime
+
+	// The sidecar crashes first, then the essential container while the
+	// sidecar waits to be restarted
+	mockTime.EXPECT().After(gomock.Any()).Return(make(<-chan time.Time))
+	crashContainer(mtask, sidecar, 1)
+	crashContainer(mtask, app, 1)
+
+	change := <-containerEvents
+	assert.Equal(t, "app", change.ContainerName)
+	assert.Equal(t, api.ContainerStopped, change.Status)
+	assert.Equal(t, api.TaskStopped, mtask.GetDesiredStatus(), "The task should stop with its essential container")
+
+	// The sidecar is reported as stopped rather than restarted
+	mtask.abandonContainerRestarts()
+	change = <-containerEvents
+	assert.Equal(t, "sidecar", change.ContainerName)
+	assert.Equal(t, api.ContainerStopped, change.Status)
+	assert.Equal(t, "Container exited with code 1", change.Reason)
+}
+
+func TestContainerChangeRecordsTimestamps(t *testing.T) {
+	container := &api.Container{Name: "c"}
+	mtask, _, done := runningManagedTask(container, api.ContainerPulled)
+	defer done()
+
+	createdAt := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	startedAt := createdAt.Add(3 * time.Second)
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: DockerContainerChangeEvent{
+			Status:                  api.ContainerCreated,
+			DockerContainerMetadata: DockerContainerMetadata{CreatedAt: createdAt},
+		},
+	})
+	assert.Equal(t, createdAt, container.GetCreatedAt())
+	assert.True(t, container.GetStartedAt().IsZero())
+
+	mtask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: DockerContainerChangeEvent{
+			Status:                  api.ContainerRunning,
+			DockerContainerMetadata: DockerContainerMetadata{StartedAt: startedAt},
+		},
+	})
+	assert.Equal(t, createdAt, container.GetCreatedAt(), "Events without a creation time should keep the known one")
+	assert.Equal(t, startedAt, container.GetStartedAt())
+}
+
+func TestCleanupDelay(t *testing.T) {
+	now := time.Now()
+	wait := 3 * time.Hour
+	testCases := []struct {
+		name          string
+		stoppedTime   time.Time
+		expectedDelay time.Duration
+	}{
+		{"just stopped", now, wait},
+		{"stopped before the agent restarted", now.Add(-time.Hour), 2 * time.Hour},
+		{"wait passed while the agent was down", now.Add(-4 * time.Hour), 0},
+		{"stop time not saved", time.Time{}, wait},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expectedDelay, cleanupDelay(tc.stoppedTime, wait, now), tc.name)
+	}
+}
+
+// stoppedManagedTask creates a managed task that stopped at stoppedTime, with
+// a container in the engine's state
+func stoppedManagedTask(taskEngine *DockerTaskEngine, arn string, stoppedTime time.Time) *managedTask {
+	task := &api.Task{
+		Arn:             arn,
+		KnownStatus:     api.TaskStopped,
+		KnownStatusTime: stoppedTime,
+		Containers:      []*api.Container{{Name: "c1", KnownStatus: api.ContainerStopped}},
+	}
+	taskEngine.state.AddTask(task)
+	taskEngine.state.AddContainer(&api.DockerContainer{DockerId: arn + "-id", DockerName: arn + "-name", Container: task.Containers[0]}, task)
+	taskEngine.processTasks.Lock()
+	defer taskEngine.processTasks.Unlock()
+	return taskEngine.newManagedTask(task)
+}
+
+func TestCleanupTaskAfterWaitElapsed(t *testing.T) {
+	ctrl, client, mockTime, privateTaskEngine, _, imageManager := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	mtask := stoppedManagedTask(taskEngine, "stopped", time.Now().Add(-4*time.Hour))
+	mtask._time = mockTime
+
+	// The wait passed while the agent was down, so the task is cleaned up
+	// right away rather than waiting again
+	cleanup := make(chan time.Time, 1)
+	cleanup <- time.Now()
+	mockTime.EXPECT().After(time.Duration(0)).Return(cleanup)
+	client.EXPECT().RemoveContainer("stopped-name", removeContainerTimeout).Return(nil)
+	imageManager.EXPECT().RemoveContainerReferenceFromImageState(gomock.Any()).Return(nil)
+
+	mtask.cleanupTask(3 * time.Hour)
+	_, ok := taskEngine.state.TaskByArn("stopped")
+	assert.False(t, ok, "Task should have been removed from the state")
+}
+
+func TestReclaimStoppedTasks(t *testing.T) {
+	ctrl, client, mockTime, privateTaskEngine, _, imageManager := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	stopped := stoppedManagedTask(taskEngine, "stopped", time.Now())
+	stopped._time = mockTime
+	running := stoppedManagedTask(taskEngine, "running", time.Time{})
+	running.SetKnownStatus(api.TaskRunning)
+
+	// The cleanup wait never passes
+	mockTime.EXPECT().After(gomock.Any()).Return(make(chan time.Time))
+	client.EXPECT().RemoveContainer("stopped-name", removeContainerTimeout).Return(nil)
+	imageManager.EXPECT().RemoveContainerReferenceFromImageState(gomock.Any()).Return(nil)
+
+	taskEngine.reclaimStoppedTasks()
+	assert.Len(t, running.cleanupNow, 0, "Running tasks should not be cleaned up")
+
+	cleanedUp := make(chan struct{})
+	go func() {
+		stopped.cleanupTask(3 * time.Hour)
+		close(cleanedUp)
+	}()
+	select {
+	case <-cleanedUp:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the stopped task to be cleaned up")
+	}
+	_, ok := taskEngine.state.TaskByArn("stopped")
+	assert.False(t, ok, "Stopped task should have been removed from the state")
+	_, ok = taskEngine.state.TaskByArn("running")
+	assert.True(t, ok, "Running task should have been kept")
+}
+
+func TestApplyContainerStateOutOfDiskSpaceReclaimsStoppedTasks(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, imageManager := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	stopped := stoppedManagedTask(taskEngine, "stopped", time.Now())
+
+	task := &api.Task{Arn: "pulling", DesiredStatus: api.TaskRunning}
+	container := &api.Container{Name: "c1", Image: "image"}
+	task.Containers = []*api.Container{container}
+	client.EXPECT().PullImage("image", nil).Return(DockerContainerMetadata{
+		Error: CannotXContainerError{"Pull", "write /var/lib/docker/tmp/layer: no space left on device"},
+	})
+	imageManager.EXPECT().RecordContainerReference(container).Return(nil)
+	imageManager.EXPECT().GetImageStateFromImageName("image").Return(nil)
+
+	metadata := taskEngine.applyContainerState(task, container, api.ContainerPulled)
+	assert.NotNil(t, metadata.Error)
+	assert.Len(t, stopped.cleanupNow, 1, "Stopped task should have been signaled to clean up")
+}
+
+func TestEmitTaskEventEssentialContainerExitReason(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEvents, _ := taskEngine.TaskEvents()
+
+	exitCode := 1
+	task := &api.Task{
+		Arn:                     "myArn",
+		FailOnEssentialExitCode: true,
+		KnownStatus:             api.TaskStopped,
+		Containers: []*api.Container{
+			{Name: "app", Essential: true, KnownStatus: api.ContainerStopped, KnownExitCode: &exitCode},
+		},
+	}
+	go taskEngine.emitTaskEvent(task, "")
+	event := <-taskEvents
+	assert.Equal(t, api.TaskStopped, event.Status)
+	assert.Equal(t, "Essential container in task exited: app exited with code 1", event.Reason)
+
+	task.SentStatus = api.TaskStatusNone
+	go taskEngine.emitTaskEvent(task, "TaskStateError: Agent could not progress task's state to stopped")
+	event = <-taskEvents
+	assert.Equal(t, "TaskStateError: Agent could not progress task's state to stopped", event.Reason, "Explicit reasons should take precedence")
+}
@@ -14,7 +14,9 @@
 package engine
 
 import "fmt"
+import "time"
 import "github.com/aws/amazon-ecs-agent/agent/api"
+import "github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
 
 // ContainerNotFound is a type for a missing container
 type ContainerNotFound struct {
@@ -40,6 +42,13 @@ type DockerContainerMetadata struct {
 	PortBindings []api.PortBinding
 	Error        engineError
 	Volumes      map[string]string
+	// Health is ContainerHealthStarting when inspecting a running container
+	// that has a health check, or the result reported by a health check event
+	Health api.ContainerHealthStatus
+	// CreatedAt and StartedAt are when the container was created and last
+	// started, if known
+	CreatedAt time.Time
+	StartedAt time.Time
 }
 
 // ListContainersResponse encapsulates the response from the docker client for the
@@ -48,3 +57,31 @@ type ListContainersResponse struct {
 	DockerIDs []string
 	Error     error
 }
+
+// DockerInfo describes the docker daemon used by the agent
+type DockerInfo struct {
+	// ServerVersion is the version of the docker daemon
+	ServerVersion string
+	// ServerAPIVersion is the highest remote api version the daemon supports
+	ServerAPIVersion string
+	// APIVersion is the remote api version the agent uses to talk to the
+	// daemon
+	APIVersion dockerclient.DockerVersion
+	// StorageDriver, LoggingDriver and CgroupDriver are the drivers the
+	// daemon is configured with
+	StorageDriver string
+	LoggingDriver string
+	CgroupDriver  string
+	// KernelVersion and OperatingSystem describe the host running the daemon
+	KernelVersion   string
+	OperatingSystem string
+	// Containers is the number of containers known to the daemon, of which
+	// ContainersRunning are running
+	Containers        int
+	ContainersRunning int
+	// Images is the number of images known to the daemon
+	Images int
+	// LoggingDrivers are the logging drivers the daemon has, or empty if the
+	// daemon doesn't list them
+	LoggingDrivers []string
+}
@@ -0,0 +1,119 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+)
+
+// TaskProblem describes a reason the engine would fail to launch a task
+type TaskProblem struct {
+	// Container is the name of the container the problem was found in, or
+	// empty if the problem applies to the task as a whole
+	Container string
+	// Name is the name of the error the engine would report for the problem
+	Name string
+	// Reason describes the problem
+	Reason string
+}
+
+func newTaskProblem(container string, err error) TaskProblem {
+	namedErr := api.NewNamedError(err)
+	return TaskProblem{Container: container, Name: namedErr.Name, Reason: namedErr.Err}
+}
+
+// ValidateTask runs the checks the engine performs before creating the
+// containers of a task and returns every problem found, or an empty list if
+// the task could be launched. Nothing is pulled or created and the task is not
+// added to the engine, but the task is modified as it would be when added, so
+// callers should pass a task they own
+func (engine *DockerTaskEngine) ValidateTask(task *api.Task) []TaskProblem {
+	task.PostUnmarshalTask(engine.credentialsManager)
+
+	problems := []TaskProblem{}
+	if err := task.Validate(); err != nil {
+		problems = append(problems, newTaskProblem("", err))
+	}
+	if err := engine.gpus.check(task); err != nil {
+		problems = append(problems, newTaskProblem("", err))
+	}
+	if err := engine.hostResources.check(task); err != nil {
+		problems = append(problems, newTaskProblem("", err))
+	}
+	if err := task.ValidateDependsOn(); err != nil {
+		problems = append(problems, newTaskProblem("", err))
+	}
+
+	// Links and volumes from other containers are resolved through the docker
+	// names of the containers, which don't exist yet
+	containerMap := make(map[string]*api.DockerContainer)
+	for _, container := range task.Containers {
+		containerMap[container.Name] = &api.DockerContainer{DockerName: container.Name, Container: container}
+	}
+
+	// Empty volumes only get a host path once the internal container that
+	// holds them has been created
+	for _, volume := range task.Volumes {
+		if emptyVolume, ok := volume.Volume.(*api.EmptyHostVolume); ok && emptyVolume.HostPath == "" {
+			emptyVolume.HostPath = "/" + volume.Name
+		}
+	}
+
+	for _, container := range task.Containers {
+		hostConfig, hcerr := task.DockerHostConfig(container, containerMap)
+		if hcerr != nil {
+			// Creating the docker config would report the same volume problems
+			problems = append(problems, newTaskProblem(container.Name, hcerr))
+			continue
+		}
+		client := engine.client
+		if container.DockerConfig.Version != nil {
+			client = client.WithVersion(dockerclient.DockerVersion(*container.DockerConfig.Version))
+		}
+		for _, err := range engine.checkHostConfig(task, container, client, hostConfig) {
+			problems = append(problems, newTaskProblem(container.Name, err))
+		}
+		// Environment files are only checked against the policy of the
+		// instance; they aren't read until the container is created
+		if _, err := task.DockerConfig(container); err != nil {
+			problems = append(problems, newTaskProblem(container.Name, err))
+		}
+	}
+
+	return append(problems, engine.hostPortProblems(task)...)
+}
+
+// hostPortProblems returns a problem for each static host port of the task
+// that is reserved on the instance, mapped by a running container of a task
+// that isn't stopping or mapped by more than one container of the task
+func (engine *DockerTaskEngine) hostPortProblems(task *api.Task) []TaskProblem {
+	var problems []TaskProblem
+	mappedBy := make(map[string]string)
+	for _, container := range task.Containers {
+		reserved := engine.reservedHostPorts(container, false)
+		for port, holder := range mappedBy {
+			reserved[port] = holder
+		}
+		for _, conflict := range hostPortConflicts(container, reserved) {
+			problems = append(problems, newTaskProblem(container.Name, conflict))
+		}
+		for _, binding := range container.Ports {
+			if binding.HostPort != 0 {
+				mappedBy[hostPort(binding.HostPort, binding.Protocol)] = "already mapped by container " + container.Name
+			}
+		}
+	}
+	return problems
+}
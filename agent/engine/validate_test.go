@@ -0,0 +1,218 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerclient"
+)
+
+func validTask() *api.Task {
+	return &api.Task{
+		Arn:     "arn:aws:ecs:us-west-2:123456789012:task/validate",
+		Family:  "validate",
+		Version: "1",
+		Memory:  512,
+		Volumes: []api.TaskVolume{
+			{Name: "data", Volume: &api.EmptyHostVolume{}},
+		},
+		Containers: []*api.Container{
+			{
+				Name:        "web",
+				Image:       "nginx",
+				Memory:      256,
+				Essential:   true,
+				Links:       []string{"db:database"},
+				Ports:       []api.PortBinding{{ContainerPort: 80, HostPort: 8080}},
+				MountPoints: []api.MountPoint{{SourceVolume: "data", ContainerPath: "/data"}},
+				DependsOn:   []api.DependsOn{{Container: "db", Condition: api.DependencyConditionStart}},
+			},
+			{
+				Name:      "db",
+				Image:     "postgres",
+				Memory:    256,
+				Essential: true,
+				Ports:     []api.PortBinding{{ContainerPort: 5432}},
+			},
+		},
+	}
+}
+
+func TestValidateTaskValid(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	problems := taskEngine.ValidateTask(validTask())
+	if problems == nil || len(problems) != 0 {
+		t.Errorf("Expected an empty list of problems, got: %v", problems)
+	}
+	if len(taskEngine.state.AllTasks()) != 0 {
+		t.Error("Expected validated task to not be added to the engine")
+	}
+}
+
+func TestValidateTaskProblems(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	syslogConfig := `{"LogConfig":{"Type":"syslog"}}`
+	testCases := []struct {
+		name              string
+		modify            func(task *api.Task)
+		expectedContainer string
+		expectedName      string
+	}{
+		{
+			name:         "memory over task limit",
+			modify:       func(task *api.Task) { task.Containers[0].Memory = 512 },
+			expectedName: "TaskResourceError",
+		},
+		{
+			name:              "unknown volume",
+			modify:            func(task *api.Task) { task.Containers[0].MountPoints[0].SourceVolume = "missing" },
+			expectedContainer: "web",
+			expectedName:      "HostConfigError",
+		},
+		{
+			name:              "link to unknown container",
+			modify:            func(task *api.Task) { task.Containers[0].Links = []string{"cache"} },
+			expectedContainer: "web",
+			expectedName:      "HostConfigError",
+		},
+		{
+			name:              "duplicate host port",
+			modify:            func(task *api.Task) { task.Containers[1].Ports[0].HostPort = 8080 },
+			expectedContainer: "db",
+			expectedName:      "HostPortConflictError",
+		},
+		{
+			name:              "reserved host port",
+			modify:            func(task *api.Task) { task.Containers[0].Ports[0].HostPort = 22 },
+			expectedContainer: "web",
+			expectedName:      "HostPortConflictError",
+		},
+		{
+			name:              "unavailable logging driver",
+			modify:            func(task *api.Task) { task.Containers[1].DockerConfig.HostConfig = &syslogConfig },
+			expectedContainer: "db",
+			expectedName:      "UnavailableLoggingDriverError",
+		},
+		{
+			name:              "seccomp unconfined not allowed",
+			modify:            func(task *api.Task) { task.Containers[0].DockerSecurityOptions = []string{"seccomp:unconfined"} },
+			expectedContainer: "web",
+			expectedName:      "SecurityOptionNotAllowedError",
+		},
+		{
+			name:              "missing seccomp profile",
+			modify:            func(task *api.Task) { task.Containers[1].DockerSecurityOptions = []string{"seccomp:missing"} },
+			expectedContainer: "db",
+			expectedName:      "CannotLoadSeccompProfileError",
+		},
+		{
+			name: "dependency cycle",
+			modify: func(task *api.Task) {
+				task.Containers[1].DependsOn = []api.DependsOn{{Container: "web", Condition: api.DependencyConditionStart}}
+			},
+			expectedName: "TaskDependencyError",
+		},
+	}
+
+	for _, tc := range testCases {
+		task := validTask()
+		tc.modify(task)
+		problems := taskEngine.ValidateTask(task)
+		if len(problems) != 1 {
+			t.Errorf("%s: expected one problem, got: %v", tc.name, problems)
+			continue
+		}
+		if problems[0].Container != tc.expectedContainer || problems[0].Name != tc.expectedName || problems[0].Reason == "" {
+			t.Errorf("%s: expected %s in container %q, got: %+v", tc.name, tc.expectedName, tc.expectedContainer, problems[0])
+		}
+	}
+}
+
+func TestValidateTaskReportsEveryProblem(t *testing.T) {
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	task := validTask()
+	task.Containers[0].Links = []string{"cache"}
+	task.Containers[1].Ports[0].HostPort = 8080
+	problems := taskEngine.ValidateTask(task)
+	if len(problems) != 2 {
+		t.Errorf("Expected two problems, got: %v", problems)
+	}
+}
+
+func TestValidateTaskInitUnsupported(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	client.EXPECT().APIVersion().Return(dockerclient.Version_1_24)
+
+	task := validTask()
+	task.Containers[1].LinuxParameters = &api.LinuxParameters{Init: true}
+	problems := taskEngine.ValidateTask(task)
+	if len(problems) != 1 || problems[0].Container != "db" || problems[0].Name != "UnsupportedDockerVersionError" {
+		t.Errorf("Expected UnsupportedDockerVersionError in container db, got: %v", problems)
+	}
+}
+
+func TestValidateTaskUnknownAppArmorProfile(t *testing.T) {
+	path, cleanup := writeAppArmorProfiles(t)
+	defer cleanup()
+	cfg := defaultConfig
+	cfg.StrictAppArmorChecking = true
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.appArmorProfilesPath = path
+
+	task := validTask()
+	task.Containers[0].DockerSecurityOptions = []string{"apparmor:docker-redis"}
+	problems := taskEngine.ValidateTask(task)
+	if len(problems) != 1 || problems[0].Container != "web" || problems[0].Name != "AppArmorProfileNotFoundError" {
+		t.Errorf("Expected AppArmorProfileNotFoundError in container web, got: %v", problems)
+	}
+}
+
+func TestValidateTaskEnvironmentFiles(t *testing.T) {
+	cfg := defaultConfig
+	cfg.BindMountDeniedPaths = []string{"/etc"}
+	cfg.LocalEnvironmentFilesEnabled = true
+	ctrl, _, _, privateTaskEngine, _, _ := mocks(t, &cfg)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	// Environment files aren't read, so missing files aren't problems
+	task := validTask()
+	task.Containers[0].EnvironmentFiles = []api.EnvironmentFile{{Value: "/nonexistent/app.env", Type: api.EnvironmentFileTypeLocal}}
+	if problems := taskEngine.ValidateTask(task); len(problems) != 0 {
+		t.Errorf("Expected no problems, got: %v", problems)
+	}
+
+	task = validTask()
+	task.Containers[1].EnvironmentFiles = []api.EnvironmentFile{{Value: "/etc/shadow", Type: api.EnvironmentFileTypeLocal}}
+	problems := taskEngine.ValidateTask(task)
+	if len(problems) != 1 || problems[0].Container != "db" || problems[0].Name != "EnvironmentFileError" {
+		t.Errorf("Expected EnvironmentFileError in container db, got: %v", problems)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// volumeManager counts the containers referencing each docker volume of the
+// tasks, so that a volume is created before the first container mounting it
+// and removed once the last one is gone. Docker keeps a volume in use until
+// the containers mounting it are removed, not just stopped, so containers hold
+// their reference until they're removed
+type volumeManager struct {
+	client DockerClient
+	// references maps the docker name of each volume to the containers
+	// referencing it, keyed by task arn and container name
+	references map[string]map[string]struct{}
+	lock       sync.Mutex
+}
+
+func newVolumeManager(client DockerClient) *volumeManager {
+	return &volumeManager{
+		client:     client,
+		references: make(map[string]map[string]struct{}),
+	}
+}
+
+func volumeReference(task *api.Task, container *api.Container) string {
+	return task.Arn + "/" + container.Name
+}
+
+// acquire adds a reference from the container to each docker volume it mounts,
+// creating the volumes that aren't referenced yet
+func (manager *volumeManager) acquire(task *api.Task, container *api.Container) engineError {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	reference := volumeReference(task, container)
+	for _, volume := range task.DockerVolumes(container) {
+		references, ok := manager.references[volume.DockerName]
+		if !ok {
+			seelog.Infof("Creating volume %s for task %s", volume.DockerName, task.Arn)
+			err := manager.client.CreateVolume(volume.DockerName, volume.Driver, volume.DriverOpts, volume.Labels, createVolumeTimeout)
+			if err != nil {
+				return CannotCreateVolumeError{"Unable to create volume " + volume.DockerName + ": " + err.Error()}
+			}
+			references = make(map[string]struct{})
+			manager.references[volume.DockerName] = references
+		}
+		references[reference] = struct{}{}
+	}
+	return nil
+}
+
+// restore adds the references of a container that was created before the
+// agent restarted, without creating the volumes
+func (manager *volumeManager) restore(task *api.Task, container *api.Container) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	reference := volumeReference(task, container)
+	for _, volume := range task.DockerVolumes(container) {
+		references, ok := manager.references[volume.DockerName]
+		if !ok {
+			references = make(map[string]struct{})
+			manager.references[volume.DockerName] = references
+		}
+		references[reference] = struct{}{}
+	}
+}
+
+// release removes the reference from the container to each docker volume it
+// mounts, removing the volumes no longer referenced
+func (manager *volumeManager) release(task *api.Task, container *api.Container) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	reference := volumeReference(task, container)
+	for _, volume := range task.DockerVolumes(container) {
+		references, ok := manager.references[volume.DockerName]
+		if !ok {
+			continue
+		}
+		if _, ok := references[reference]; !ok {
+			continue
+		}
+		delete(references, reference)
+		if len(references) == 0 {
+			delete(manager.references, volume.DockerName)
+			manager.remove(task, volume)
+		}
+	}
+}
+
+// releaseTask removes the references from all the containers of the task and
+// the docker volumes of the task that are still referenced
+func (manager *volumeManager) releaseTask(task *api.Task) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	for _, taskVolume := range task.Volumes {
+		volume, ok := taskVolume.Volume.(*api.DockerVolume)
+		if !ok {
+			continue
+		}
+		if _, ok := manager.references[volume.DockerName]; !ok {
+			continue
+		}
+		delete(manager.references, volume.DockerName)
+		manager.remove(task, volume)
+	}
+}
+
+// remove removes the docker volume. It must be called with the lock held
+func (manager *volumeManager) remove(task *api.Task, volume *api.DockerVolume) {
+	seelog.Infof("Removing volume %s of task %s", volume.DockerName, task.Arn)
+	err := manager.client.RemoveVolume(volume.DockerName, removeVolumeTimeout)
+	if err != nil && err != docker.ErrNoSuchVolume {
+		seelog.Warnf("Unable to remove volume %s of task %s: %v", volume.DockerName, task.Arn, err)
+	}
+}
@@ -0,0 +1,224 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockeriface"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// volumeTask returns a task whose first two containers mount a shared docker
+// volume and whose last container mounts nothing
+func volumeTask() *api.Task {
+	mount := []api.MountPoint{{SourceVolume: "shared", ContainerPath: "/data"}}
+	task := &api.Task{
+		Arn:     "arn:aws:ecs:us-west-2:123456789012:task/abc",
+		Family:  "family",
+		Version: "1",
+		Containers: []*api.Container{
+			{Name: "first", Image: "image", MountPoints: mount},
+			{Name: "second", Image: "image", MountPoints: mount},
+			{Name: "other", Image: "image"},
+		},
+		Volumes: []api.TaskVolume{
+			{Name: "shared", Volume: &api.DockerVolume{Driver: "local", DriverOpts: map[string]string{"type": "tmpfs"}}},
+		},
+	}
+	task.PostUnmarshalTask(nil)
+	return task
+}
+
+const volumeTaskDockerName = "ecs-family-1-shared-abc"
+
+func TestVolumeManagerCreateOnceRemoveOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+	manager := newVolumeManager(client)
+	task := volumeTask()
+
+	// The mock fails the test if the volume is created or removed more than
+	// once
+	client.EXPECT().CreateVolume(volumeTaskDockerName, "local", map[string]string{"type": "tmpfs"}, nil, createVolumeTimeout).Return(nil)
+	for _, container := range task.Containers {
+		assert.Nil(t, manager.acquire(task, container))
+	}
+	// Acquiring again doesn't add a reference
+	assert.Nil(t, manager.acquire(task, task.Containers[0]))
+	assert.Len(t, manager.references[volumeTaskDockerName], 2)
+
+	manager.release(task, task.Containers[0])
+	manager.release(task, task.Containers[0])
+	manager.release(task, task.Containers[2])
+	assert.Len(t, manager.references[volumeTaskDockerName], 1)
+
+	client.EXPECT().RemoveVolume(volumeTaskDockerName, removeVolumeTimeout).Return(nil)
+	manager.release(task, task.Containers[1])
+	manager.releaseTask(task)
+	assert.Empty(t, manager.references)
+}
+
+func TestVolumeManagerCreateError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+	manager := newVolumeManager(client)
+	task := volumeTask()
+
+	// Creating the volume is retried by the next container
+	gomock.InOrder(
+		client.EXPECT().CreateVolume(volumeTaskDockerName, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("driver unavailable")),
+		client.EXPECT().CreateVolume(volumeTaskDockerName, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil),
+	)
+	err := manager.acquire(task, task.Containers[0])
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "CannotCreateVolumeError", err.ErrorName())
+		assert.Contains(t, err.Error(), "driver unavailable")
+	}
+	assert.Empty(t, manager.references)
+
+	// The container whose volume couldn't be created holds no reference
+	manager.release(task, task.Containers[0])
+	assert.Nil(t, manager.acquire(task, task.Containers[1]))
+	assert.Len(t, manager.references[volumeTaskDockerName], 1)
+}
+
+func TestVolumeManagerRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+	manager := newVolumeManager(client)
+	task := volumeTask()
+
+	// Restored volumes already exist, so they aren't created again
+	manager.restore(task, task.Containers[0])
+	manager.restore(task, task.Containers[1])
+	assert.Nil(t, manager.acquire(task, task.Containers[1]))
+	assert.Len(t, manager.references[volumeTaskDockerName], 2)
+
+	manager.release(task, task.Containers[0])
+	client.EXPECT().RemoveVolume(volumeTaskDockerName, removeVolumeTimeout).Return(nil)
+	manager.release(task, task.Containers[1])
+}
+
+func TestVolumeManagerReleaseTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := NewMockDockerClient(ctrl)
+	manager := newVolumeManager(client)
+	task := volumeTask()
+
+	client.EXPECT().CreateVolume(volumeTaskDockerName, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	assert.Nil(t, manager.acquire(task, task.Containers[0]))
+
+	// Volumes still referenced when the task is torn down are removed, and a
+	// volume that's already gone isn't an error
+	client.EXPECT().RemoveVolume(volumeTaskDockerName, removeVolumeTimeout).Return(docker.ErrNoSuchVolume)
+	manager.releaseTask(task)
+	manager.release(task, task.Containers[0])
+	assert.Empty(t, manager.references)
+}
+
+func TestCreateContainerCreatesVolume(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	task := volumeTask()
+	taskEngine.state.AddTask(task)
+
+	gomock.InOrder(
+		client.EXPECT().CreateVolume(volumeTaskDockerName, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil),
+		client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+			func(config *docker.Config, hostConfig *dockeriface.HostConfig, name string, timeout time.Duration) {
+				assert.Equal(t, []string{volumeTaskDockerName + ":/data"}, hostConfig.Binds)
+			}).Return(DockerContainerMetadata{DockerID: "first-id"}),
+		client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(DockerContainerMetadata{DockerID: "second-id"}),
+	)
+
+	for _, container := range task.Containers[:2] {
+		metadata := taskEngine.createContainer(task, container)
+		assert.Nil(t, metadata.Error)
+	}
+}
+
+func TestCreateContainerVolumeError(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, _ := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	task := volumeTask()
+	taskEngine.state.AddTask(task)
+
+	// The client mock fails the test if the container is created
+	client.EXPECT().CreateVolume(volumeTaskDockerName, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("driver unavailable"))
+
+	metadata := taskEngine.createContainer(task, task.Containers[0])
+	if assert.NotNil(t, metadata.Error) {
+		assert.Equal(t, "CannotCreateVolumeError", metadata.Error.ErrorName())
+	}
+}
+
+func TestSweepTaskRemovesVolume(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, imageManager := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	task := volumeTask()
+	taskEngine.state.AddTask(task)
+	for _, container := range task.Containers {
+		taskEngine.state.AddContainer(&api.DockerContainer{DockerId: container.Name + "-id", DockerName: container.Name + "-name", Container: container}, task)
+		taskEngine.volumes.restore(task, container)
+	}
+
+	// The volume is removed once, after the containers mounting it
+	imageManager.EXPECT().RemoveContainerReferenceFromImageState(gomock.Any()).Return(nil).AnyTimes()
+	gomock.InOrder(
+		client.EXPECT().RemoveContainer("first-name", removeContainerTimeout).Return(nil),
+		client.EXPECT().RemoveContainer("second-name", removeContainerTimeout).Return(nil),
+		client.EXPECT().RemoveVolume(volumeTaskDockerName, removeVolumeTimeout).Return(nil),
+		client.EXPECT().RemoveContainer("other-name", removeContainerTimeout).Return(nil),
+	)
+
+	taskEngine.sweepTask(task)
+}
+
+func TestSweepTaskRemovesVolumeOfUnremovedContainer(t *testing.T) {
+	ctrl, client, _, privateTaskEngine, _, imageManager := mocks(t, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	task := volumeTask()
+	taskEngine.state.AddTask(task)
+	for _, container := range task.Containers {
+		taskEngine.state.AddContainer(&api.DockerContainer{DockerId: container.Name + "-id", DockerName: container.Name + "-name", Container: container}, task)
+		taskEngine.volumes.restore(task, container)
+	}
+
+	// A container that couldn't be removed still holds its reference until
+	// the task is torn down
+	imageManager.EXPECT().RemoveContainerReferenceFromImageState(gomock.Any()).Return(nil).AnyTimes()
+	gomock.InOrder(
+		client.EXPECT().RemoveContainer("first-name", removeContainerTimeout).Return(errors.New("container in use")),
+		client.EXPECT().RemoveContainer("second-name", removeContainerTimeout).Return(nil),
+		client.EXPECT().RemoveContainer("other-name", removeContainerTimeout).Return(nil),
+		client.EXPECT().RemoveVolume(volumeTaskDockerName, removeVolumeTimeout).Return(nil),
+	)
+
+	taskEngine.sweepTask(task)
+}
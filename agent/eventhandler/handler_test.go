@@ -14,6 +14,7 @@
 package eventhandler
 
 import (
+	"container/list"
 	"errors"
 	"strconv"
 	"sync"
@@ -208,3 +209,49 @@ func TestShouldBeSent(t *testing.T) {
 		t.Error("Container should be sent if it's the first try")
 	}
 }
+
+func TestFlushSubmitsPendingEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	// Queue events as they would be while backing off after a failure,
+	// without a goroutine sending them
+	contEvent1 := contEvent("flush")
+	taskEvent1 := taskEvent("flush")
+	taskList := &eventList{List: list.New(), sending: true}
+	taskList.PushBack(newSendableContainerEvent(contEvent1))
+	taskList.PushBack(newSendableTaskEvent(taskEvent1))
+	testHandler := newTaskHandler()
+	testHandler.taskMap["flush"] = taskList
+
+	gomock.InOrder(
+		client.EXPECT().SubmitContainerStateChange(contEvent1).Return(nil),
+		client.EXPECT().SubmitTaskStateChange(taskEvent1).Return(nil),
+	)
+
+	err := testHandler.flush(client, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, taskList.Len(), "Expected all events to be submitted")
+}
+
+func TestFlushTimesOut(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	contEvent1 := contEvent("flushTimeout")
+	taskList := &eventList{List: list.New(), sending: true}
+	taskList.PushBack(newSendableContainerEvent(contEvent1))
+	testHandler := newTaskHandler()
+	testHandler.taskMap["flushTimeout"] = taskList
+
+	submitted := make(chan struct{})
+	client.EXPECT().SubmitContainerStateChange(contEvent1).Do(func(interface{}) {
+		<-submitted
+	}).Return(nil)
+
+	err := testHandler.flush(client, 10*time.Millisecond)
+	assert.Error(t, err, "Expected flush to time out")
+	close(submitted)
+}
@@ -15,6 +15,7 @@ package eventhandler
 
 import (
 	"container/list"
+	"fmt"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
@@ -94,8 +95,6 @@ func SubmitTaskEvents(events *eventList, client api.ECSClient) {
 			defer events.Unlock()
 			log.Debug("Aquired lock!")
 
-			var err error
-
 			if events.Len() == 0 {
 				log.Debug("No events left; not retrying more")
 
@@ -104,50 +103,13 @@ func SubmitTaskEvents(events *eventList, client api.ECSClient) {
 				return nil
 			}
 
-			eventToSubmit := events.Front()
-			event := eventToSubmit.Value.(*sendableEvent)
-			llog := log.New("event", event)
-
-			if event.containerShouldBeSent() {
-				llog.Info("Sending container change", "change", event)
-				err = client.SubmitContainerStateChange(event.containerChange)
-				if err == nil {
-					// submitted; ensure we don't retry it
-					event.containerSent = true
-					if event.containerChange.SentStatus != nil {
-						*event.containerChange.SentStatus = event.containerChange.Status
-					}
-					statesaver.Save()
-					llog.Debug("Submitted container state change")
-					backoff.Reset()
-					events.Remove(eventToSubmit)
-				} else {
-					llog.Error("Unretriable error submitting container state change", "err", err)
-				}
-			} else if event.taskShouldBeSent() {
-				llog.Info("Sending task change", "change", event)
-				err = client.SubmitTaskStateChange(event.taskChange)
-				if err == nil {
-					// submitted or can't be retried; ensure we don't retry it
-					event.taskSent = true
-					if event.taskChange.SentStatus != nil {
-						*event.taskChange.SentStatus = event.taskChange.Status
-					}
-					statesaver.Save()
-					llog.Debug("Submitted task state change")
-					backoff.Reset()
-					events.Remove(eventToSubmit)
-				} else {
-					llog.Error("Unretriable error submitting container state change", "err", err)
-				}
-			} else {
-				// Shouldn't be sent as either a task or container change event; must have been already sent
-				llog.Info("Not submitting redundant event; just removing")
-				events.Remove(eventToSubmit)
+			err := submitFirstEvent(events, client)
+			if err == nil {
+				backoff.Reset()
 			}
 
 			if events.Len() == 0 {
-				llog.Debug("Removed the last element, no longer sending")
+				log.Debug("Removed the last element, no longer sending")
 				events.sending = false
 				done = true
 				return nil
@@ -157,3 +119,97 @@ func SubmitTaskEvents(events *eventList, client api.ECSClient) {
 		})
 	}
 }
+
+// submitFirstEvent submits the first event of the list, removing it once it
+// is sent or if it doesn't need to be. The caller must hold the list's lock
+func submitFirstEvent(events *eventList, client api.ECSClient) error {
+	var err error
+	eventToSubmit := events.Front()
+	event := eventToSubmit.Value.(*sendableEvent)
+	llog := log.New("event", event)
+
+	if event.containerShouldBeSent() {
+		llog.Info("Sending container change", "change", event)
+		err = client.SubmitContainerStateChange(event.containerChange)
+		if err == nil {
+			// submitted; ensure we don't retry it
+			event.containerSent = true
+			if event.containerChange.SentStatus != nil {
+				*event.containerChange.SentStatus = event.containerChange.Status
+			}
+			statesaver.Save()
+			llog.Debug("Submitted container state change")
+			events.Remove(eventToSubmit)
+		} else {
+			llog.Error("Unretriable error submitting container state change", "err", err)
+		}
+	} else if event.taskShouldBeSent() {
+		llog.Info("Sending task change", "change", event)
+		err = client.SubmitTaskStateChange(event.taskChange)
+		if err == nil {
+			// submitted or can't be retried; ensure we don't retry it
+			event.taskSent = true
+			if event.taskChange.SentStatus != nil {
+				*event.taskChange.SentStatus = event.taskChange.Status
+			}
+			statesaver.Save()
+			llog.Debug("Submitted task state change")
+			events.Remove(eventToSubmit)
+		} else {
+			llog.Error("Unretriable error submitting container state change", "err", err)
+		}
+	} else {
+		// Shouldn't be sent as either a task or container change event; must have been already sent
+		llog.Info("Not submitting redundant event; just removing")
+		events.Remove(eventToSubmit)
+	}
+	return err
+}
+
+// Flush submits the pending state changes of every task right away, rather
+// than waiting for the backoff of earlier failed submissions. It is meant to
+// be called before the agent exits, and fails if a state change can't be
+// submitted or if the pending changes aren't all submitted within timeout
+func Flush(client api.ECSClient, timeout time.Duration) error {
+	return handler.flush(client, timeout)
+}
+
+func (handler *taskHandler) flush(client api.ECSClient, timeout time.Duration) error {
+	handler.RLock()
+	taskLists := make([]*eventList, 0, len(handler.taskMap))
+	for _, taskList := range handler.taskMap {
+		taskLists = append(taskLists, taskList)
+	}
+	handler.RUnlock()
+
+	flushed := make(chan error, 1)
+	go func() {
+		var flushErr error
+		for _, taskList := range taskLists {
+			if err := flushTaskEvents(taskList, client); err != nil && flushErr == nil {
+				flushErr = err
+			}
+		}
+		flushed <- flushErr
+	}()
+
+	select {
+	case err := <-flushed:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("Timed out flushing state changes after %v", timeout)
+	}
+}
+
+// flushTaskEvents submits the events of a task in order, stopping at the
+// first one that fails to be submitted
+func flushTaskEvents(events *eventList, client api.ECSClient) error {
+	events.Lock()
+	defer events.Unlock()
+	for events.Len() > 0 {
+		if err := submitFirstEvent(events, client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -120,13 +120,15 @@ func setupServer(credentialsManager credentials.Manager, auditLogger audit.Audit
 func credentialsV1V2RequestHandler(credentialsManager credentials.Manager, auditLogger audit.AuditLogger, idFunc func(*http.Request) string, apiVersion int) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		credentialsID := idFunc(r)
-		jsonResponse, arn, errorMessage, err := processCredentialsV1V2Request(credentialsManager, r, credentialsID, apiVersion)
+		jsonResponse, arn, rotationTime, errorMessage, err := processCredentialsV1V2Request(credentialsManager, r, credentialsID, apiVersion)
 		if err != nil {
 			jsonMsg, _ := json.Marshal(errorMessage)
 			writeCredentialsV1V2RequestResponse(w, r, errorMessage.httpErrorCode, audit.GetCredentialsEventType(), arn, auditLogger, jsonMsg)
 			return
 		}
 
+		setCredentialsCacheControl(w, rotationTime)
+
 		writeCredentialsV1V2RequestResponse(w, r, http.StatusOK, audit.GetCredentialsEventType(), arn, auditLogger, jsonResponse)
 	}
 }
@@ -152,8 +154,23 @@ func writeCredentialsV1V2RequestResponse(w http.ResponseWriter, r *http.Request,
 	writeJSONToResponse(w, httpStatusCode, message)
 }
 
-// processCredentialsV1V2Request returns the response json containing credentials for the credentials id in the request
-func processCredentialsV1V2Request(credentialsManager credentials.Manager, r *http.Request, credentialsID string, apiVersion int) ([]byte, string, *errorMessage, error) {
+// setCredentialsCacheControl asks callers not to cache the credentials past
+// the time they are due for rotation. No header is set if the rotation time is
+// unknown
+func setCredentialsCacheControl(w http.ResponseWriter, rotationTime time.Time) {
+	if rotationTime.IsZero() {
+		return
+	}
+	maxAge := int64(rotationTime.Sub(time.Now()) / time.Second)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", maxAge))
+}
+
+// processCredentialsV1V2Request returns the response json containing credentials for the credentials id in the request,
+// along with the time the credentials are due for rotation
+func processCredentialsV1V2Request(credentialsManager credentials.Manager, r *http.Request, credentialsID string, apiVersion int) ([]byte, string, time.Time, *errorMessage, error) {
 	errPrefix := fmt.Sprintf("CredentialsV%dRequest: ", apiVersion)
 	if credentialsID == "" {
 		errText := errPrefix + "No ID in the request"
@@ -163,7 +180,7 @@ func processCredentialsV1V2Request(credentialsManager credentials.Manager, r *ht
 			Message:       errText,
 			httpErrorCode: http.StatusBadRequest,
 		}
-		return nil, "", msg, errors.New(errText)
+		return nil, "", time.Time{}, msg, errors.New(errText)
 	}
 
 	credentials, ok := credentialsManager.GetTaskCredentials(credentialsID)
@@ -175,7 +192,7 @@ func processCredentialsV1V2Request(credentialsManager credentials.Manager, r *ht
 			Message:       errText,
 			httpErrorCode: http.StatusBadRequest,
 		}
-		return nil, "", msg, errors.New(errText)
+		return nil, "", time.Time{}, msg, errors.New(errText)
 	}
 
 	if credentials == nil {
@@ -187,7 +204,7 @@ func processCredentialsV1V2Request(credentialsManager credentials.Manager, r *ht
 			Message:       errText,
 			httpErrorCode: http.StatusServiceUnavailable,
 		}
-		return nil, "", msg, errors.New(errText)
+		return nil, "", time.Time{}, msg, errors.New(errText)
 	}
 
 	credentialsJSON, err := json.Marshal(credentials.IAMRoleCredentials)
@@ -199,11 +216,14 @@ func processCredentialsV1V2Request(credentialsManager credentials.Manager, r *ht
 			Message:       "Internal server error",
 			httpErrorCode: http.StatusInternalServerError,
 		}
-		return nil, "", msg, errors.New(errText)
+		return nil, "", time.Time{}, msg, errors.New(errText)
 	}
 
+	// The rotation time is left unset if the expiration can't be parsed
+	rotationTime, _ := credentials.IAMRoleCredentials.RotationTime()
+
 	//Success
-	return credentialsJSON, credentials.ARN, nil, nil
+	return credentialsJSON, credentials.ARN, rotationTime, nil, nil
 }
 
 func writeJSONToResponse(w http.ResponseWriter, httpStatusCode int, jsonMessage []byte) {
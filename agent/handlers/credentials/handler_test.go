@@ -21,7 +21,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	mock_credentials "github.com/aws/amazon-ecs-agent/agent/credentials/mocks"
@@ -166,6 +169,66 @@ func TestCredentialsV2RequestWhenCredentialsFound(t *testing.T) {
 	assert.Equal(t, secretAccessKey, credentials.SecretAccessKey, "Incorrect credentials received: secret access key")
 }
 
+// TestCredentialsRequestCacheControl tests that credentials are not cached past
+// the time they are due for rotation
+func TestCredentialsRequestCacheControl(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	credentialsManager := mock_credentials.NewMockManager(ctrl)
+	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	server := setupServer(credentialsManager, auditLog)
+
+	creds := &credentials.TaskIAMRoleCredentials{
+		IAMRoleCredentials: credentials.IAMRoleCredentials{
+			RoleArn:         roleArn,
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			Expiration:      time.Now().Add(time.Hour).Format(time.RFC3339),
+		},
+	}
+	credentialsManager.EXPECT().GetTaskCredentials(credentialsID).Return(creds, true)
+	auditLog.EXPECT().Log(gomock.Any(), gomock.Any(), gomock.Any())
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", credentials.V2CredentialsPath+"/"+credentialsID, nil)
+	server.Handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Incorrect return code")
+
+	cacheControl := recorder.Header().Get("Cache-Control")
+	if !strings.HasPrefix(cacheControl, "max-age=") {
+		t.Fatalf("Expected max-age Cache-Control header, got: %q", cacheControl)
+	}
+	maxAge, err := strconv.Atoi(strings.TrimPrefix(cacheControl, "max-age="))
+	assert.NoError(t, err, "Error parsing max-age")
+	expectedMaxAge := int((time.Hour - credentials.RotationWindow) / time.Second)
+	assert.True(t, maxAge <= expectedMaxAge && maxAge >= expectedMaxAge-5, "Incorrect max-age: %d", maxAge)
+}
+
+// TestCredentialsRequestNoCacheControlForUnknownExpiration tests that no
+// Cache-Control header is set when the expiration can't be parsed
+func TestCredentialsRequestNoCacheControlForUnknownExpiration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	credentialsManager := mock_credentials.NewMockManager(ctrl)
+	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	server := setupServer(credentialsManager, auditLog)
+
+	creds := &credentials.TaskIAMRoleCredentials{
+		IAMRoleCredentials: credentials.IAMRoleCredentials{
+			RoleArn:    roleArn,
+			Expiration: "soon",
+		},
+	}
+	credentialsManager.EXPECT().GetTaskCredentials(credentialsID).Return(creds, true)
+	auditLog.EXPECT().Log(gomock.Any(), gomock.Any(), gomock.Any())
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", credentials.V2CredentialsPath+"/"+credentialsID, nil)
+	server.Handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Incorrect return code")
+	assert.Empty(t, recorder.Header().Get("Cache-Control"), "Unexpected Cache-Control header")
+}
+
 func testErrorResponsesFromServer(t *testing.T, path string, expectedErrorMessage *errorMessage) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -0,0 +1,100 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+)
+
+// metricsContentType is the content type of the Prometheus text exposition
+// format
+const metricsContentType = "text/plain; version=0.0.4"
+
+// metricsContainerStatuses are the container statuses that containers are
+// counted by, so that every status is exposed even when no container has it
+var metricsContainerStatuses = []api.ContainerStatus{
+	api.ContainerStatusNone,
+	api.ContainerPulled,
+	api.ContainerCreated,
+	api.ContainerRunning,
+	api.ContainerStopped,
+}
+
+// metricSample is a value of a metric, with at most one label
+type metricSample struct {
+	label      string
+	labelValue string
+	value      uint64
+}
+
+// writeMetric writes a metric and its samples in the Prometheus text
+// exposition format
+func writeMetric(w io.Writer, name string, metricType string, help string, samples ...metricSample) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	for _, sample := range samples {
+		if sample.label == "" {
+			fmt.Fprintf(w, "%s %d\n", name, sample.value)
+		} else {
+			fmt.Fprintf(w, "%s{%s=%q} %d\n", name, sample.label, sample.labelValue, sample.value)
+		}
+	}
+}
+
+// Creates response for the '/metrics' API, which exposes the tasks and
+// containers known to the engine and the counters of the engine and the stats
+// engine in the Prometheus text exposition format.
+func metricsRequestHandlerMaker(taskEngine DockerStateResolver, engineMetricsResolver EngineMetricsResolver, statsMetricsResolver StatsMetricsResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var tasksRunning uint64
+		containers := make(map[api.ContainerStatus]uint64)
+		for _, task := range taskEngine.State().AllTasks() {
+			if task.GetKnownStatus() == api.TaskRunning {
+				tasksRunning++
+			}
+			for _, container := range task.Containers {
+				containers[container.GetKnownStatus()]++
+			}
+		}
+		containerSamples := make([]metricSample, 0, len(metricsContainerStatuses))
+		for _, status := range metricsContainerStatuses {
+			containerSamples = append(containerSamples, metricSample{"status", strings.ToLower(status.String()), containers[status]})
+		}
+
+		engineMetrics := engineMetricsResolver.EngineMetrics()
+		dockerAPIErrorSamples := make([]metricSample, 0, len(engine.DockerAPIs))
+		for _, dockerAPI := range engine.DockerAPIs {
+			dockerAPIErrorSamples = append(dockerAPIErrorSamples, metricSample{"api", dockerAPI, engineMetrics.DockerAPIErrors[dockerAPI]})
+		}
+
+		var response bytes.Buffer
+		writeMetric(&response, "ecs_agent_tasks_running", "gauge", "Number of tasks known to be running.", metricSample{value: tasksRunning})
+		writeMetric(&response, "ecs_agent_containers", "gauge", "Number of containers managed by the agent by known status.", containerSamples...)
+		writeMetric(&response, "ecs_agent_image_pulls_total", "counter", "Number of images pulled for containers since the agent started.", metricSample{value: engineMetrics.ImagePulls})
+		writeMetric(&response, "ecs_agent_image_pull_failures_total", "counter", "Number of image pulls that failed since the agent started.", metricSample{value: engineMetrics.ImagePullFailures})
+		writeMetric(&response, "ecs_agent_docker_api_errors_total", "counter", "Number of failed docker API calls by API since the agent started.", dockerAPIErrorSamples...)
+		writeMetric(&response, "ecs_agent_disk_pressure_activations_total", "counter", "Number of times free disk space went below the cleanup threshold since the agent started.", metricSample{value: engineMetrics.DiskPressureActivations})
+		writeMetric(&response, "ecs_agent_out_of_memory_kills_total", "counter", "Number of containers killed for their memory usage since the agent started.", metricSample{value: statsMetricsResolver.OutOfMemoryKills()})
+
+		w.Header().Set("Content-Type", metricsContentType)
+		w.Write(response.Bytes())
+	}
+}
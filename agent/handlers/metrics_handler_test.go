@@ -0,0 +1,125 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricSampleLine matches a sample of the Prometheus text exposition format
+// with at most one label
+var metricSampleLine = regexp.MustCompile(`^([a-z_]+)(?:\{([a-z_]+)="([^"]*)"\})? ([0-9]+)$`)
+
+// parseMetrics returns the values of the samples of each metric, keyed by
+// label value, and the type of each metric
+func parseMetrics(t *testing.T, body string) (map[string]map[string]uint64, map[string]string) {
+	samples := make(map[string]map[string]uint64)
+	types := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.Fields(line)
+			require.Len(t, fields, 4, "Bad type line: %s", line)
+			types[fields[2]] = fields[3]
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		match := metricSampleLine.FindStringSubmatch(line)
+		require.NotNil(t, match, "Bad sample line: %s", line)
+		value, err := strconv.ParseUint(match[4], 10, 64)
+		require.NoError(t, err)
+		if samples[match[1]] == nil {
+			samples[match[1]] = make(map[string]uint64)
+		}
+		_, duplicate := samples[match[1]][match[3]]
+		assert.False(t, duplicate, "Duplicate sample: %s", line)
+		samples[match[1]][match[3]] = value
+	}
+	return samples, types
+}
+
+func TestMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	running := &api.Task{Arn: "running", Containers: []*api.Container{{Name: "a"}, {Name: "b"}}}
+	running.SetKnownStatus(api.TaskRunning)
+	running.Containers[0].SetKnownStatus(api.ContainerRunning)
+	running.Containers[1].SetKnownStatus(api.ContainerRunning)
+	pending := &api.Task{Arn: "pending", Containers: []*api.Container{{Name: "a"}}}
+	pending.Containers[0].SetKnownStatus(api.ContainerPulled)
+	stopped := &api.Task{Arn: "stopped", Containers: []*api.Container{{Name: "a"}}}
+	stopped.SetKnownStatus(api.TaskStopped)
+	stopped.Containers[0].SetKnownStatus(api.ContainerStopped)
+	state := dockerstate.NewDockerTaskEngineState()
+	for _, task := range []*api.Task{running, pending, stopped} {
+		state.AddTask(task)
+	}
+
+	mockStateResolver := mock_handlers.NewMockDockerStateResolver(ctrl)
+	mockEngineMetricsResolver := mock_handlers.NewMockEngineMetricsResolver(ctrl)
+	mockStatsMetricsResolver := mock_handlers.NewMockStatsMetricsResolver(ctrl)
+	mockStateResolver.EXPECT().State().Return(state)
+	mockEngineMetricsResolver.EXPECT().EngineMetrics().Return(engine.EngineMetrics{
+		ImagePulls:              7,
+		ImagePullFailures:       2,
+		DockerAPIErrors:         map[string]uint64{"pull": 2, "start": 1},
+		DiskPressureActivations: 4,
+	})
+	mockStatsMetricsResolver.EXPECT().OutOfMemoryKills().Return(uint64(3))
+
+	requestHandler := metricsRequestHandlerMaker(mockStateResolver, mockEngineMetricsResolver, mockStatsMetricsResolver)
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	requestHandler(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, metricsContentType, recorder.Header().Get("Content-Type"))
+	samples, types := parseMetrics(t, recorder.Body.String())
+
+	assert.Equal(t, map[string]string{
+		"ecs_agent_tasks_running":                   "gauge",
+		"ecs_agent_containers":                      "gauge",
+		"ecs_agent_image_pulls_total":               "counter",
+		"ecs_agent_image_pull_failures_total":       "counter",
+		"ecs_agent_docker_api_errors_total":         "counter",
+		"ecs_agent_disk_pressure_activations_total": "counter",
+		"ecs_agent_out_of_memory_kills_total":       "counter",
+	}, types)
+	// Every label value is exposed, even when its count is zero, and no others
+	assert.Equal(t, map[string]uint64{"": 1}, samples["ecs_agent_tasks_running"])
+	assert.Equal(t, map[string]uint64{"none": 0, "pulled": 1, "created": 0, "running": 2, "stopped": 1}, samples["ecs_agent_containers"])
+	assert.Equal(t, map[string]uint64{"": 7}, samples["ecs_agent_image_pulls_total"])
+	assert.Equal(t, map[string]uint64{"": 2}, samples["ecs_agent_image_pull_failures_total"])
+	assert.Equal(t, map[string]uint64{"pull": 2, "create": 0, "start": 1, "stop": 0}, samples["ecs_agent_docker_api_errors_total"])
+	assert.Equal(t, map[string]uint64{"": 4}, samples["ecs_agent_disk_pressure_activations_total"])
+	assert.Equal(t, map[string]uint64{"": 3}, samples["ecs_agent_out_of_memory_kills_total"])
+}
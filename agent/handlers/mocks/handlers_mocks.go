@@ -12,13 +12,19 @@
 // permissions and limitations under the License.
 
 // Automatically generated by MockGen. DO NOT EDIT!
-// Source: github.com/aws/amazon-ecs-agent/agent/handlers (interfaces: DockerStateResolver)
+// Source: github.com/aws/amazon-ecs-agent/agent/handlers (interfaces: DockerStateResolver,DrainStatusResolver,TaskValidator,DockerInfoResolver,DockerPinger,ACSConnectionResolver,StateSaveResolver,ContainerStatsResolver,EngineMetricsResolver,StatsMetricsResolver,ContainerLogsResolver)
 
 package mock_handlers
 
 import (
+	io "io"
+
+	api "github.com/aws/amazon-ecs-agent/agent/api"
+	engine "github.com/aws/amazon-ecs-agent/agent/engine"
 	dockerstate "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	go_dockerclient "github.com/fsouza/go-dockerclient"
 	gomock "github.com/golang/mock/gomock"
+	context "golang.org/x/net/context"
 )
 
 // Mock of DockerStateResolver interface
@@ -51,3 +57,326 @@ func (_m *MockDockerStateResolver) State() *dockerstate.DockerTaskEngineState {
 func (_mr *_MockDockerStateResolverRecorder) State() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "State")
 }
+
+// Mock of DrainStatusResolver interface
+type MockDrainStatusResolver struct {
+	ctrl     *gomock.Controller
+	recorder *_MockDrainStatusResolverRecorder
+}
+
+// Recorder for MockDrainStatusResolver (not exported)
+type _MockDrainStatusResolverRecorder struct {
+	mock *MockDrainStatusResolver
+}
+
+func NewMockDrainStatusResolver(ctrl *gomock.Controller) *MockDrainStatusResolver {
+	mock := &MockDrainStatusResolver{ctrl: ctrl}
+	mock.recorder = &_MockDrainStatusResolverRecorder{mock}
+	return mock
+}
+
+func (_m *MockDrainStatusResolver) EXPECT() *_MockDrainStatusResolverRecorder {
+	return _m.recorder
+}
+
+func (_m *MockDrainStatusResolver) DrainStatus() engine.DrainStatus {
+	ret := _m.ctrl.Call(_m, "DrainStatus")
+	ret0, _ := ret[0].(engine.DrainStatus)
+	return ret0
+}
+
+func (_mr *_MockDrainStatusResolverRecorder) DrainStatus() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DrainStatus")
+}
+
+// Mock of TaskValidator interface
+type MockTaskValidator struct {
+	ctrl     *gomock.Controller
+	recorder *_MockTaskValidatorRecorder
+}
+
+// Recorder for MockTaskValidator (not exported)
+type _MockTaskValidatorRecorder struct {
+	mock *MockTaskValidator
+}
+
+func NewMockTaskValidator(ctrl *gomock.Controller) *MockTaskValidator {
+	mock := &MockTaskValidator{ctrl: ctrl}
+	mock.recorder = &_MockTaskValidatorRecorder{mock}
+	return mock
+}
+
+func (_m *MockTaskValidator) EXPECT() *_MockTaskValidatorRecorder {
+	return _m.recorder
+}
+
+func (_m *MockTaskValidator) ValidateTask(_param0 *api.Task) []engine.TaskProblem {
+	ret := _m.ctrl.Call(_m, "ValidateTask", _param0)
+	ret0, _ := ret[0].([]engine.TaskProblem)
+	return ret0
+}
+
+func (_mr *_MockTaskValidatorRecorder) ValidateTask(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ValidateTask", arg0)
+}
+
+// Mock of DockerInfoResolver interface
+type MockDockerInfoResolver struct {
+	ctrl     *gomock.Controller
+	recorder *_MockDockerInfoResolverRecorder
+}
+
+// Recorder for MockDockerInfoResolver (not exported)
+type _MockDockerInfoResolverRecorder struct {
+	mock *MockDockerInfoResolver
+}
+
+func NewMockDockerInfoResolver(ctrl *gomock.Controller) *MockDockerInfoResolver {
+	mock := &MockDockerInfoResolver{ctrl: ctrl}
+	mock.recorder = &_MockDockerInfoResolverRecorder{mock}
+	return mock
+}
+
+func (_m *MockDockerInfoResolver) EXPECT() *_MockDockerInfoResolverRecorder {
+	return _m.recorder
+}
+
+func (_m *MockDockerInfoResolver) DockerInfo() (engine.DockerInfo, error) {
+	ret := _m.ctrl.Call(_m, "DockerInfo")
+	ret0, _ := ret[0].(engine.DockerInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDockerInfoResolverRecorder) DockerInfo() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DockerInfo")
+}
+
+// Mock of DockerPinger interface
+type MockDockerPinger struct {
+	ctrl     *gomock.Controller
+	recorder *_MockDockerPingerRecorder
+}
+
+// Recorder for MockDockerPinger (not exported)
+type _MockDockerPingerRecorder struct {
+	mock *MockDockerPinger
+}
+
+func NewMockDockerPinger(ctrl *gomock.Controller) *MockDockerPinger {
+	mock := &MockDockerPinger{ctrl: ctrl}
+	mock.recorder = &_MockDockerPingerRecorder{mock}
+	return mock
+}
+
+func (_m *MockDockerPinger) EXPECT() *_MockDockerPingerRecorder {
+	return _m.recorder
+}
+
+func (_m *MockDockerPinger) PingDocker() error {
+	ret := _m.ctrl.Call(_m, "PingDocker")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerPingerRecorder) PingDocker() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "PingDocker")
+}
+
+// Mock of ACSConnectionResolver interface
+type MockACSConnectionResolver struct {
+	ctrl     *gomock.Controller
+	recorder *_MockACSConnectionResolverRecorder
+}
+
+// Recorder for MockACSConnectionResolver (not exported)
+type _MockACSConnectionResolverRecorder struct {
+	mock *MockACSConnectionResolver
+}
+
+func NewMockACSConnectionResolver(ctrl *gomock.Controller) *MockACSConnectionResolver {
+	mock := &MockACSConnectionResolver{ctrl: ctrl}
+	mock.recorder = &_MockACSConnectionResolverRecorder{mock}
+	return mock
+}
+
+func (_m *MockACSConnectionResolver) EXPECT() *_MockACSConnectionResolverRecorder {
+	return _m.recorder
+}
+
+func (_m *MockACSConnectionResolver) Connected() bool {
+	ret := _m.ctrl.Call(_m, "Connected")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+func (_mr *_MockACSConnectionResolverRecorder) Connected() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Connected")
+}
+
+// Mock of StateSaveResolver interface
+type MockStateSaveResolver struct {
+	ctrl     *gomock.Controller
+	recorder *_MockStateSaveResolverRecorder
+}
+
+// Recorder for MockStateSaveResolver (not exported)
+type _MockStateSaveResolverRecorder struct {
+	mock *MockStateSaveResolver
+}
+
+func NewMockStateSaveResolver(ctrl *gomock.Controller) *MockStateSaveResolver {
+	mock := &MockStateSaveResolver{ctrl: ctrl}
+	mock.recorder = &_MockStateSaveResolverRecorder{mock}
+	return mock
+}
+
+func (_m *MockStateSaveResolver) EXPECT() *_MockStateSaveResolverRecorder {
+	return _m.recorder
+}
+
+func (_m *MockStateSaveResolver) LastSaveError() error {
+	ret := _m.ctrl.Call(_m, "LastSaveError")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockStateSaveResolverRecorder) LastSaveError() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LastSaveError")
+}
+
+// Mock of ContainerStatsResolver interface
+type MockContainerStatsResolver struct {
+	ctrl     *gomock.Controller
+	recorder *_MockContainerStatsResolverRecorder
+}
+
+// Recorder for MockContainerStatsResolver (not exported)
+type _MockContainerStatsResolverRecorder struct {
+	mock *MockContainerStatsResolver
+}
+
+func NewMockContainerStatsResolver(ctrl *gomock.Controller) *MockContainerStatsResolver {
+	mock := &MockContainerStatsResolver{ctrl: ctrl}
+	mock.recorder = &_MockContainerStatsResolverRecorder{mock}
+	return mock
+}
+
+func (_m *MockContainerStatsResolver) EXPECT() *_MockContainerStatsResolverRecorder {
+	return _m.recorder
+}
+
+func (_m *MockContainerStatsResolver) ContainerDockerStats(_param0 string) (*go_dockerclient.Stats, bool) {
+	ret := _m.ctrl.Call(_m, "ContainerDockerStats", _param0)
+	ret0, _ := ret[0].(*go_dockerclient.Stats)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+func (_mr *_MockContainerStatsResolverRecorder) ContainerDockerStats(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ContainerDockerStats", arg0)
+}
+
+func (_m *MockContainerStatsResolver) TaskDockerStats(_param0 string) (map[string]*go_dockerclient.Stats, bool) {
+	ret := _m.ctrl.Call(_m, "TaskDockerStats", _param0)
+	ret0, _ := ret[0].(map[string]*go_dockerclient.Stats)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+func (_mr *_MockContainerStatsResolverRecorder) TaskDockerStats(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "TaskDockerStats", arg0)
+}
+
+// Mock of EngineMetricsResolver interface
+type MockEngineMetricsResolver struct {
+	ctrl     *gomock.Controller
+	recorder *_MockEngineMetricsResolverRecorder
+}
+
+// Recorder for MockEngineMetricsResolver (not exported)
+type _MockEngineMetricsResolverRecorder struct {
+	mock *MockEngineMetricsResolver
+}
+
+func NewMockEngineMetricsResolver(ctrl *gomock.Controller) *MockEngineMetricsResolver {
+	mock := &MockEngineMetricsResolver{ctrl: ctrl}
+	mock.recorder = &_MockEngineMetricsResolverRecorder{mock}
+	return mock
+}
+
+func (_m *MockEngineMetricsResolver) EXPECT() *_MockEngineMetricsResolverRecorder {
+	return _m.recorder
+}
+
+func (_m *MockEngineMetricsResolver) EngineMetrics() engine.EngineMetrics {
+	ret := _m.ctrl.Call(_m, "EngineMetrics")
+	ret0, _ := ret[0].(engine.EngineMetrics)
+	return ret0
+}
+
+func (_mr *_MockEngineMetricsResolverRecorder) EngineMetrics() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "EngineMetrics")
+}
+
+// Mock of StatsMetricsResolver interface
+type MockStatsMetricsResolver struct {
+	ctrl     *gomock.Controller
+	recorder *_MockStatsMetricsResolverRecorder
+}
+
+// Recorder for MockStatsMetricsResolver (not exported)
+type _MockStatsMetricsResolverRecorder struct {
+	mock *MockStatsMetricsResolver
+}
+
+func NewMockStatsMetricsResolver(ctrl *gomock.Controller) *MockStatsMetricsResolver {
+	mock := &MockStatsMetricsResolver{ctrl: ctrl}
+	mock.recorder = &_MockStatsMetricsResolverRecorder{mock}
+	return mock
+}
+
+func (_m *MockStatsMetricsResolver) EXPECT() *_MockStatsMetricsResolverRecorder {
+	return _m.recorder
+}
+
+func (_m *MockStatsMetricsResolver) OutOfMemoryKills() uint64 {
+	ret := _m.ctrl.Call(_m, "OutOfMemoryKills")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+func (_mr *_MockStatsMetricsResolverRecorder) OutOfMemoryKills() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "OutOfMemoryKills")
+}
+
+// Mock of ContainerLogsResolver interface
+type MockContainerLogsResolver struct {
+	ctrl     *gomock.Controller
+	recorder *_MockContainerLogsResolverRecorder
+}
+
+// Recorder for MockContainerLogsResolver (not exported)
+type _MockContainerLogsResolverRecorder struct {
+	mock *MockContainerLogsResolver
+}
+
+func NewMockContainerLogsResolver(ctrl *gomock.Controller) *MockContainerLogsResolver {
+	mock := &MockContainerLogsResolver{ctrl: ctrl}
+	mock.recorder = &_MockContainerLogsResolverRecorder{mock}
+	return mock
+}
+
+func (_m *MockContainerLogsResolver) EXPECT() *_MockContainerLogsResolverRecorder {
+	return _m.recorder
+}
+
+func (_m *MockContainerLogsResolver) ContainerLogs(_param0 context.Context, _param1 string, _param2 int, _param3 bool, _param4 io.Writer) error {
+	ret := _m.ctrl.Call(_m, "ContainerLogs", _param0, _param1, _param2, _param3, _param4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockContainerLogsResolverRecorder) ContainerLogs(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ContainerLogs", arg0, arg1, arg2, arg3, arg4)
+}
@@ -13,7 +13,16 @@
 
 package handlers
 
-import "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+import (
+	"io"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	docker "github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/context"
+)
 
 type MetadataResponse struct {
 	Cluster              string
@@ -34,12 +43,98 @@ type TasksResponse struct {
 	Tasks []*TaskResponse
 }
 
+type DrainResponse struct {
+	Draining         bool
+	RemainingSeconds int64
+	ActiveTasks      int
+}
+
+// HealthResponse is the response of the 'v1/health' API
+type HealthResponse struct {
+	Healthy bool
+	Docker  bool
+	ACS     bool
+	State   bool
+}
+
+// LogLevelResponse is the response of the 'v1/loglevel' API
+type LogLevelResponse struct {
+	Level string
+}
+
+type DockerInfoResponse struct {
+	ServerVersion     string
+	ServerAPIVersion  string
+	APIVersion        string
+	StorageDriver     string
+	LoggingDriver     string
+	CgroupDriver      string
+	KernelVersion     string
+	OperatingSystem   string
+	Containers        int
+	ContainersRunning int
+	Images            int
+}
+
+type ValidateTaskResponse struct {
+	Problems []TaskProblemResponse
+}
+
+type TaskProblemResponse struct {
+	Container string `json:",omitempty"`
+	Name      string
+	Reason    string
+}
+
 type ContainerResponse struct {
 	DockerId   string
 	DockerName string
 	Name       string
+	CreatedAt  *time.Time `json:",omitempty"`
+	StartedAt  *time.Time `json:",omitempty"`
 }
 
 type DockerStateResolver interface {
 	State() *dockerstate.DockerTaskEngineState
 }
+
+type DrainStatusResolver interface {
+	DrainStatus() engine.DrainStatus
+}
+
+type DockerInfoResolver interface {
+	DockerInfo() (engine.DockerInfo, error)
+}
+
+type DockerPinger interface {
+	PingDocker() error
+}
+
+type ACSConnectionResolver interface {
+	Connected() bool
+}
+
+type StateSaveResolver interface {
+	LastSaveError() error
+}
+
+type TaskValidator interface {
+	ValidateTask(task *api.Task) []engine.TaskProblem
+}
+
+type ContainerStatsResolver interface {
+	ContainerDockerStats(dockerID string) (*docker.Stats, bool)
+	TaskDockerStats(taskArn string) (map[string]*docker.Stats, bool)
+}
+
+type EngineMetricsResolver interface {
+	EngineMetrics() engine.EngineMetrics
+}
+
+type StatsMetricsResolver interface {
+	OutOfMemoryKills() uint64
+}
+
+type ContainerLogsResolver interface {
+	ContainerLogs(ctx context.Context, dockerID string, tail int, follow bool, output io.Writer) error
+}
@@ -15,9 +15,13 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,6 +32,8 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/version"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/net/context"
 )
 
 var log = logger.ForModule("Handlers")
@@ -35,8 +41,39 @@ var log = logger.ForModule("Handlers")
 const (
 	dockerIdQueryField = "dockerid"
 	taskArnQueryField  = "taskarn"
+	levelQueryField    = "level"
+	tailQueryField     = "tail"
+	followQueryField   = "follow"
+
+	// dockerInfoCacheDuration is how long the 'v1/info' API reuses the
+	// docker daemon's information before asking the daemon again
+	dockerInfoCacheDuration = 10 * time.Second
+
+	// dockerHealthCacheDuration is how long the 'v1/health' API reuses the
+	// result of pinging the docker daemon before pinging it again
+	dockerHealthCacheDuration = 10 * time.Second
+
+	// maxValidateRequestBytes limits the size of tasks accepted by the
+	// 'v1/validate' API
+	maxValidateRequestBytes = 1024 * 1024
+
+	// containerLogsPathPrefix and containerLogsPathSuffix surround the task
+	// arn and container name in the paths of the container logs API
+	containerLogsPathPrefix = "/v1/tasks/"
+	containerLogsPathSuffix = "/logs"
+
+	// defaultLogsTailLines is the number of log lines the container logs API
+	// returns if 'tail' isn't specified, and maxLogsTailLines the most it
+	// returns
+	defaultLogsTailLines = 100
+	maxLogsTailLines     = 10000
 )
 
+// dockerPingTimeout is how long the 'v1/health' API waits for the docker
+// daemon to answer a ping before reporting it as unhealthy. It is a variable
+// such that it can be shortened for unit tests
+var dockerPingTimeout = 2 * time.Second
+
 type rootResponse struct {
 	AvailableCommands []string
 }
@@ -62,13 +99,30 @@ func metadataV1RequestHandlerMaker(containerInstanceArn *string, cfg *config.Con
 	}
 }
 
+// newContainerResponse returns the response for a container, with when it was
+// created and started once that's known
+func newContainerResponse(container *api.DockerContainer, name string) ContainerResponse {
+	response := ContainerResponse{
+		DockerId:   container.DockerId,
+		DockerName: container.DockerName,
+		Name:       name,
+	}
+	if createdAt := container.Container.GetCreatedAt(); !createdAt.IsZero() {
+		response.CreatedAt = &createdAt
+	}
+	if startedAt := container.Container.GetStartedAt(); !startedAt.IsZero() {
+		response.StartedAt = &startedAt
+	}
+	return response
+}
+
 func newTaskResponse(task *api.Task, containerMap map[string]*api.DockerContainer) *TaskResponse {
 	containers := []ContainerResponse{}
 	for containerName, container := range containerMap {
 		if container.Container.IsInternal {
 			continue
 		}
-		containers = append(containers, ContainerResponse{container.DockerId, container.DockerName, containerName})
+		containers = append(containers, newContainerResponse(container, containerName))
 	}
 
 	knownStatus := task.GetKnownStatus()
@@ -150,6 +204,354 @@ func tasksV1RequestHandlerMaker(taskEngine DockerStateResolver) func(http.Respon
 	}
 }
 
+// Creates response for the 'v1/drain' API, which reports whether the agent is
+// waiting for tasks to stop before exiting.
+func drainV1RequestHandlerMaker(drainStatusResolver DrainStatusResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := drainStatusResolver.DrainStatus()
+		responseJSON, _ := json.Marshal(&DrainResponse{
+			Draining:         status.Draining,
+			RemainingSeconds: int64(status.RemainingTime / time.Second),
+			ActiveTasks:      status.ActiveTasks,
+		})
+		w.Write(responseJSON)
+	}
+}
+
+// Creates response for the 'v2/stats' API. Returns the most recent docker
+// stats, including network interface counters, for the container if 'dockerid'
+// is specified in the request, or for each container in the task, keyed by
+// docker id, if 'taskarn' is specified. Responds with 404 if no stats have
+// been collected for the container or task yet.
+func statsV2RequestHandlerMaker(statsResolver ContainerStatsResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dockerId, dockerIdExists := ValueFromRequest(r, dockerIdQueryField)
+		taskArn, taskArnExists := ValueFromRequest(r, taskArnQueryField)
+		if dockerIdExists == taskArnExists {
+			log.Info("Request must contain exactly one of ", dockerIdQueryField, " and ", taskArnQueryField)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var stats interface{}
+		var found bool
+		var resourceId string
+		if dockerIdExists {
+			stats, found = statsResolver.ContainerDockerStats(dockerId)
+			resourceId = dockerId
+		} else {
+			stats, found = statsResolver.TaskDockerStats(taskArn)
+			resourceId = taskArn
+		}
+		if !found {
+			log.Warn("Could not find stats for requested resource: " + resourceId)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("{}"))
+			return
+		}
+		responseJSON, _ := json.Marshal(stats)
+		w.Write(responseJSON)
+	}
+}
+
+// Creates response for the 'v1/info' API, which describes the docker daemon
+// used by the agent. The daemon is asked at most once every
+// dockerInfoCacheDuration.
+func infoV1RequestHandlerMaker(dockerInfoResolver DockerInfoResolver) func(http.ResponseWriter, *http.Request) {
+	var lock sync.Mutex
+	var responseJSON []byte
+	var updated time.Time
+	return func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if responseJSON == nil || time.Since(updated) > dockerInfoCacheDuration {
+			info, err := dockerInfoResolver.DockerInfo()
+			if err != nil {
+				log.Warn("Unable to get docker info", "err", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			responseJSON, _ = json.Marshal(&DockerInfoResponse{
+				ServerVersion:     info.ServerVersion,
+				ServerAPIVersion:  info.ServerAPIVersion,
+				APIVersion:        string(info.APIVersion),
+				StorageDriver:     info.StorageDriver,
+				LoggingDriver:     info.LoggingDriver,
+				CgroupDriver:      info.CgroupDriver,
+				KernelVersion:     info.KernelVersion,
+				OperatingSystem:   info.OperatingSystem,
+				Containers:        info.Containers,
+				ContainersRunning: info.ContainersRunning,
+				Images:            info.Images,
+			})
+			updated = time.Now()
+		}
+		w.Write(responseJSON)
+	}
+}
+
+// Creates response for the 'v1/validate' API. The request body is a task as
+// sent by the backend; the response lists the problems that would prevent the
+// agent from launching it, without pulling images or creating containers.
+func validateV1RequestHandlerMaker(taskValidator TaskValidator) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		task := &api.Task{}
+		err := json.NewDecoder(io.LimitReader(r.Body, maxValidateRequestBytes)).Decode(task)
+		if err != nil {
+			log.Info("Unable to decode task to validate", "err", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		problems := []TaskProblemResponse{}
+		for _, problem := range taskValidator.ValidateTask(task) {
+			problems = append(problems, TaskProblemResponse{
+				Container: problem.Container,
+				Name:      problem.Name,
+				Reason:    problem.Reason,
+			})
+		}
+		responseJSON, _ := json.Marshal(&ValidateTaskResponse{Problems: problems})
+		w.Write(responseJSON)
+	}
+}
+
+// Creates response for the 'v1/loglevel' API. Returns the log level of the
+// agent. POST requests change the log level to the one specified by 'level',
+// or set it again from ECS_LOGLEVEL if 'level' isn't specified, and respond
+// with 400 if the level is unknown.
+func logLevelV1RequestHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "POST":
+		var err error
+		if requestedLevel, exists := ValueFromRequest(r, levelQueryField); exists {
+			err = logger.ChangeLevel(requestedLevel)
+		} else {
+			err = logger.ReloadLevel()
+		}
+		if err != nil {
+			log.Info("Unable to change the log level", "err", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		log.Info("Changed the log level", "level", logger.Level())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	responseJSON, _ := json.Marshal(&LogLevelResponse{Level: logger.Level()})
+	w.Write(responseJSON)
+}
+
+// Creates response for the 'v1/health' API, which reports whether the docker
+// daemon is reachable, the agent is connected to ACS and state was last saved
+// successfully. The status is 200 only if all of them are healthy. The daemon
+// is pinged at most once every dockerHealthCacheDuration, by one request at a
+// time. Requests wait at most dockerPingTimeout from when the ping started, so
+// a daemon that doesn't answer is promptly reported as unhealthy.
+func healthV1RequestHandlerMaker(dockerPinger DockerPinger, acsConnectionResolver ACSConnectionResolver, stateSaveResolver StateSaveResolver) func(http.ResponseWriter, *http.Request) {
+	var lock sync.Mutex
+	var dockerErr error
+	var pinged time.Time
+	// pinging is closed when the ping started at pingStarted returns, and is
+	// nil when no ping is in flight
+	var pinging chan struct{}
+	var pingStarted time.Time
+	return func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		if pinging == nil && (pinged.IsZero() || time.Since(pinged) > dockerHealthCacheDuration) {
+			done := make(chan struct{})
+			pinging, pingStarted = done, time.Now()
+			go func() {
+				err := dockerPinger.PingDocker()
+				if err != nil {
+					log.Warn("Unable to ping docker", "err", err)
+				}
+				lock.Lock()
+				dockerErr, pinged, pinging = err, time.Now(), nil
+				lock.Unlock()
+				close(done)
+			}()
+		}
+		inFlight, deadline := pinging, pingStarted.Add(dockerPingTimeout)
+		lock.Unlock()
+
+		if inFlight != nil {
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			select {
+			case <-inFlight:
+			case <-ctx.Done():
+			}
+			cancel()
+		}
+
+		lock.Lock()
+		// A ping still in flight has timed out
+		dockerHealthy := pinging == nil && dockerErr == nil
+		lock.Unlock()
+
+		health := &HealthResponse{
+			Docker: dockerHealthy,
+			ACS:    acsConnectionResolver.Connected(),
+			State:  stateSaveResolver.LastSaveError() == nil,
+		}
+		health.Healthy = health.Docker && health.ACS && health.State
+		if !health.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		responseJSON, _ := json.Marshal(health)
+		w.Write(responseJSON)
+	}
+}
+
+// Creates response for the 'v1/tasks/{taskArn}/containers/{name}/logs' API.
+// Returns the last 'tail' lines, up to maxLogsTailLines, of the stdout and
+// stderr logs of the container. If 'follow' is true, logs keep being streamed
+// until the container stops or the client disconnects.
+func containerLogsV1RequestHandlerMaker(taskEngine DockerStateResolver, logsResolver ContainerLogsResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskArn, containerName, ok := parseContainerLogsPath(r.URL.Path)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		tail := defaultLogsTailLines
+		if value, exists := ValueFromRequest(r, tailQueryField); exists {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				log.Info("Invalid number of log lines requested", "tail", value)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if parsed > maxLogsTailLines {
+				parsed = maxLogsTailLines
+			}
+			tail = parsed
+		}
+		follow := false
+		if value, exists := ValueFromRequest(r, followQueryField); exists {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Info("Invalid follow requested", "follow", value)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			follow = parsed
+		}
+
+		containerMap, ok := taskEngine.State().ContainerMapByArn(taskArn)
+		var container *api.DockerContainer
+		if ok {
+			container, ok = containerMap[containerName]
+		}
+		if !ok || container.Container.IsInternal {
+			log.Warn("Could not find requested container", "task", taskArn, "container", containerName)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if follow {
+			followContainerLogs(w, logsResolver, container.DockerId, tail)
+			return
+		}
+		var logs bytes.Buffer
+		err := streamContainerLogs(context.Background(), logsResolver, container.DockerId, tail, false, &logs)
+		if err != nil {
+			log.Warn("Unable to get container logs", "task", taskArn, "container", containerName, "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(logs.Bytes())
+	}
+}
+
+// parseContainerLogsPath returns the task arn and container name of a path of
+// the container logs API. Task arns contain slashes, so the container name is
+// what follows the last '/containers/'
+func parseContainerLogsPath(path string) (string, string, bool) {
+	if !strings.HasPrefix(path, containerLogsPathPrefix) || !strings.HasSuffix(path, containerLogsPathSuffix) {
+		return "", "", false
+	}
+	path = strings.TrimSuffix(strings.TrimPrefix(path, containerLogsPathPrefix), containerLogsPathSuffix)
+	separator := strings.LastIndex(path, "/containers/")
+	if separator < 1 {
+		return "", "", false
+	}
+	taskArn := path[:separator]
+	containerName := path[separator+len("/containers/"):]
+	if containerName == "" || strings.Contains(containerName, "/") {
+		return "", "", false
+	}
+	return taskArn, containerName, true
+}
+
+// followContainerLogs streams the logs of the container to the client until
+// either of them is done. The connection is taken over from the server so
+// that the stream isn't cut short by the server's write timeout
+func followContainerLogs(w http.ResponseWriter, logsResolver ContainerLogsResolver, dockerID string, tail int) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		log.Warn("Unable to take over connection to follow container logs", "err", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// The client has nothing more to send, so reading only returns once
+		// it disconnects
+		io.Copy(ioutil.Discard, conn)
+		cancel()
+	}()
+
+	_, err = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Type: text/plain; charset=utf-8\r\nConnection: close\r\n\r\n")
+	if err == nil {
+		err = streamContainerLogs(ctx, logsResolver, dockerID, tail, true, conn)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Warn("Error following container logs", "container", dockerID, "err", err)
+	}
+}
+
+// streamContainerLogs writes the stdout and stderr logs of the container to
+// output in the order docker sent them, taking them out of the multiplexed
+// stream docker sends
+func streamContainerLogs(ctx context.Context, logsResolver ContainerLogsResolver, dockerID string, tail int, follow bool, output io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	reader, writer := io.Pipe()
+	logsErr := make(chan error, 1)
+	go func() {
+		err := logsResolver.ContainerLogs(ctx, dockerID, tail, follow, writer)
+		writer.CloseWithError(err)
+		logsErr <- err
+	}()
+
+	_, err := stdcopy.StdCopy(output, output, reader)
+	// Stop the logs if writing them failed
+	cancel()
+	reader.CloseWithError(err)
+	if resolverErr := <-logsErr; resolverErr != nil && err == nil {
+		return resolverErr
+	}
+	return err
+}
+
 var licenseProvider = utils.NewLicenseProvider()
 
 func licenseHandler(w http.ResponseWriter, h *http.Request) {
@@ -161,12 +563,24 @@ func licenseHandler(w http.ResponseWriter, h *http.Request) {
 	}
 }
 
-func setupServer(containerInstanceArn *string, taskEngine DockerStateResolver, cfg *config.Config) http.Server {
+func setupServer(containerInstanceArn *string, taskEngine DockerStateResolver, drainStatusResolver DrainStatusResolver, taskValidator TaskValidator, dockerInfoResolver DockerInfoResolver, dockerPinger DockerPinger, acsConnectionResolver ACSConnectionResolver, stateSaveResolver StateSaveResolver, statsResolver ContainerStatsResolver, engineMetricsResolver EngineMetricsResolver, statsMetricsResolver StatsMetricsResolver, logsResolver ContainerLogsResolver, cfg *config.Config) http.Server {
 	serverFunctions := map[string]func(w http.ResponseWriter, r *http.Request){
 		"/v1/metadata": metadataV1RequestHandlerMaker(containerInstanceArn, cfg),
 		"/v1/tasks":    tasksV1RequestHandlerMaker(taskEngine),
+		"/v1/drain":    drainV1RequestHandlerMaker(drainStatusResolver),
+		"/v1/info":     infoV1RequestHandlerMaker(dockerInfoResolver),
+		"/v1/health":   healthV1RequestHandlerMaker(dockerPinger, acsConnectionResolver, stateSaveResolver),
+		"/v1/validate": validateV1RequestHandlerMaker(taskValidator),
+		"/v2/stats":    statsV2RequestHandlerMaker(statsResolver),
+		"/metrics":     metricsRequestHandlerMaker(taskEngine, engineMetricsResolver, statsMetricsResolver),
 		"/license":     licenseHandler,
 	}
+	if cfg.ContainerLogsEndpointEnabled {
+		serverFunctions[containerLogsPathPrefix] = containerLogsV1RequestHandlerMaker(taskEngine, logsResolver)
+	}
+	if cfg.LogLevelEndpointEnabled {
+		serverFunctions["/v1/loglevel"] = logLevelV1RequestHandler
+	}
 
 	paths := make([]string, 0, len(serverFunctions))
 	for path := range serverFunctions {
@@ -202,12 +616,12 @@ func setupServer(containerInstanceArn *string, taskEngine DockerStateResolver, c
 
 // ServeHttp serves information about this agent / containerInstance and tasks
 // running on it.
-func ServeHttp(containerInstanceArn *string, taskEngine engine.TaskEngine, cfg *config.Config) {
+func ServeHttp(containerInstanceArn *string, taskEngine engine.TaskEngine, statsResolver ContainerStatsResolver, statsMetricsResolver StatsMetricsResolver, acsConnectionResolver ACSConnectionResolver, stateSaveResolver StateSaveResolver, cfg *config.Config) {
 	// Is this the right level to type assert, assuming we'd abstract multiple taskengines here?
 	// Revisit if we ever add another type..
 	dockerTaskEngine := taskEngine.(*engine.DockerTaskEngine)
 
-	server := setupServer(containerInstanceArn, dockerTaskEngine, cfg)
+	server := setupServer(containerInstanceArn, dockerTaskEngine, dockerTaskEngine, dockerTaskEngine, dockerTaskEngine, dockerTaskEngine, acsConnectionResolver, stateSaveResolver, statsResolver, dockerTaskEngine, statsMetricsResolver, dockerTaskEngine, cfg)
 	for {
 		once := sync.Once{}
 		utils.RetryWithBackoff(utils.NewSimpleBackoff(time.Second, time.Minute, 0.2, 2), func() error {
@@ -16,19 +16,28 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
 	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/mocks/http"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/utils/mocks"
+	"github.com/docker/docker/pkg/stdcopy"
+	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 const testContainerInstanceArn = "test_container_instance_arn"
@@ -78,6 +87,32 @@ func TestGetTaskByDockerID(t *testing.T) {
 	taskDiffHelper(t, []*api.Task{testTasks[1]}, TasksResponse{Tasks: []*TaskResponse{&taskResponse}})
 }
 
+func TestContainerResponseTimestamps(t *testing.T) {
+	container := &api.Container{Name: "web"}
+	dockerContainer := &api.DockerContainer{DockerId: "id", DockerName: "name", Container: container}
+
+	responseJSON, err := json.Marshal(newContainerResponse(dockerContainer, "web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{"DockerId":"id","DockerName":"name","Name":"web"}`, string(responseJSON), "Unknown timestamps should be left out")
+
+	createdAt := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	startedAt := createdAt.Add(3 * time.Second)
+	container.SetCreatedAt(createdAt)
+	container.SetStartedAt(startedAt)
+	response := newContainerResponse(dockerContainer, "web")
+	if assert.NotNil(t, response.CreatedAt) && assert.NotNil(t, response.StartedAt) {
+		assert.Equal(t, createdAt, *response.CreatedAt)
+		assert.Equal(t, startedAt, *response.StartedAt)
+	}
+	responseJSON, err = json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(responseJSON), `"CreatedAt":"2017-06-01T12:00:00Z","StartedAt":"2017-06-01T12:00:03Z"`)
+}
+
 func TestGetTaskByDockerID404(t *testing.T) {
 	recorder := performMockRequest(t, "/v1/tasks?dockerid=does-not-exist")
 
@@ -279,6 +314,438 @@ func stateSetupHelper(state *dockerstate.DockerTaskEngineState, tasks []*api.Tas
 	}
 }
 
+func TestGetDrainStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDrainStatusResolver := mock_handlers.NewMockDrainStatusResolver(ctrl)
+	mockDrainStatusResolver.EXPECT().DrainStatus().Return(engine.DrainStatus{
+		Draining:      true,
+		RemainingTime: 90 * time.Second,
+		ActiveTasks:   2,
+	})
+	requestHandler := drainV1RequestHandlerMaker(mockDrainStatusResolver)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/drain", nil)
+	requestHandler(recorder, req)
+
+	var drainResponse DrainResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &drainResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DrainResponse{Draining: true, RemainingSeconds: 90, ActiveTasks: 2}
+	if drainResponse != expected {
+		t.Errorf("Expected %v, got: %v", expected, drainResponse)
+	}
+}
+
+func TestGetContainerStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dockerStats := &docker.Stats{
+		Networks: map[string]docker.NetworkStats{
+			"eth0": docker.NetworkStats{RxBytes: 100, TxBytes: 200},
+		},
+	}
+	dockerStats.MemoryStats.Usage = 1024
+	dockerStats.MemoryStats.Limit = 4096
+	dockerStats.CPUStats.CPUUsage.TotalUsage = 300
+
+	mockStatsResolver := mock_handlers.NewMockContainerStatsResolver(ctrl)
+	mockStatsResolver.EXPECT().ContainerDockerStats("cid1").Return(dockerStats, true)
+	requestHandler := statsV2RequestHandlerMaker(mockStatsResolver)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v2/stats?dockerid=cid1", nil)
+	requestHandler(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	var statsResponse docker.Stats
+	err := json.Unmarshal(recorder.Body.Bytes(), &statsResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network := statsResponse.Networks["eth0"]; network.RxBytes != 100 || network.TxBytes != 200 {
+		t.Errorf("Unexpected network stats: %+v", network)
+	}
+	if statsResponse.MemoryStats.Usage != 1024 || statsResponse.MemoryStats.Limit != 4096 {
+		t.Errorf("Unexpected memory stats: %+v", statsResponse.MemoryStats)
+	}
+	if statsResponse.CPUStats.CPUUsage.TotalUsage != 300 {
+		t.Errorf("Unexpected cpu stats: %+v", statsResponse.CPUStats)
+	}
+}
+
+func TestGetTaskStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsResolver := mock_handlers.NewMockContainerStatsResolver(ctrl)
+	mockStatsResolver.EXPECT().TaskDockerStats("task1").Return(map[string]*docker.Stats{
+		"cid1": &docker.Stats{Networks: map[string]docker.NetworkStats{"eth0": docker.NetworkStats{RxPackets: 5}}},
+	}, true)
+	requestHandler := statsV2RequestHandlerMaker(mockStatsResolver)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v2/stats?taskarn=task1", nil)
+	requestHandler(recorder, req)
+
+	var statsResponse map[string]docker.Stats
+	err := json.Unmarshal(recorder.Body.Bytes(), &statsResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statsResponse["cid1"].Networks["eth0"].RxPackets != 5 {
+		t.Errorf("Unexpected stats: %+v", statsResponse)
+	}
+}
+
+func TestGetStatsNotCollected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsResolver := mock_handlers.NewMockContainerStatsResolver(ctrl)
+	mockStatsResolver.EXPECT().ContainerDockerStats("cid1").Return(nil, false)
+	requestHandler := statsV2RequestHandlerMaker(mockStatsResolver)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v2/stats?dockerid=cid1", nil)
+	requestHandler(recorder, req)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected %d for container without stats, got: %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestGetStatsBadRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := statsV2RequestHandlerMaker(mock_handlers.NewMockContainerStatsResolver(ctrl))
+	for _, path := range []string{"/v2/stats", "/v2/stats?taskarn=task1&dockerid=cid1"} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		requestHandler(recorder, req)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected %d for %s, got: %d", http.StatusBadRequest, path, recorder.Code)
+		}
+	}
+}
+
+func TestGetDockerInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDockerInfoResolver := mock_handlers.NewMockDockerInfoResolver(ctrl)
+	// Requests made within the cache duration are served from the cache
+	mockDockerInfoResolver.EXPECT().DockerInfo().Return(engine.DockerInfo{
+		ServerVersion:     "1.12.6",
+		ServerAPIVersion:  "1.24",
+		APIVersion:        "1.21",
+		StorageDriver:     "overlay",
+		Containers:        5,
+		ContainersRunning: 3,
+	}, nil).Times(1)
+	requestHandler := infoV1RequestHandlerMaker(mockDockerInfoResolver)
+
+	expected := DockerInfoResponse{
+		ServerVersion:     "1.12.6",
+		ServerAPIVersion:  "1.24",
+		APIVersion:        "1.21",
+		StorageDriver:     "overlay",
+		Containers:        5,
+		ContainersRunning: 3,
+	}
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/info", nil)
+		requestHandler(recorder, req)
+
+		var infoResponse DockerInfoResponse
+		err := json.Unmarshal(recorder.Body.Bytes(), &infoResponse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if infoResponse != expected {
+			t.Errorf("Expected %v, got: %v", expected, infoResponse)
+		}
+	}
+}
+
+func TestGetDockerInfoError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDockerInfoResolver := mock_handlers.NewMockDockerInfoResolver(ctrl)
+	// Errors aren't cached
+	mockDockerInfoResolver.EXPECT().DockerInfo().Return(engine.DockerInfo{}, errors.New("daemon unavailable")).Times(2)
+	requestHandler := infoV1RequestHandlerMaker(mockDockerInfoResolver)
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/info", nil)
+		requestHandler(recorder, req)
+		if recorder.Code != http.StatusInternalServerError {
+			t.Errorf("Expected %d, got: %d", http.StatusInternalServerError, recorder.Code)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	testCases := []struct {
+		name         string
+		dockerErr    error
+		acsConnected bool
+		saveErr      error
+		expected     HealthResponse
+	}{
+		{"healthy", nil, true, nil, HealthResponse{Healthy: true, Docker: true, ACS: true, State: true}},
+		{"docker unavailable", errors.New("daemon unavailable"), true, nil, HealthResponse{ACS: true, State: true}},
+		{"acs disconnected", nil, false, nil, HealthResponse{Docker: true, State: true}},
+		{"save failed", nil, true, errors.New("disk full"), HealthResponse{Docker: true, ACS: true}},
+		{"docker unavailable and acs disconnected", errors.New("daemon unavailable"), false, nil, HealthResponse{State: true}},
+		{"docker unavailable and save failed", errors.New("daemon unavailable"), true, errors.New("disk full"), HealthResponse{ACS: true}},
+		{"acs disconnected and save failed", nil, false, errors.New("disk full"), HealthResponse{Docker: true}},
+		{"all unhealthy", errors.New("daemon unavailable"), false, errors.New("disk full"), HealthResponse{}},
+	}
+	for _, tc := range testCases {
+		ctrl := gomock.NewController(t)
+		mockDockerPinger := mock_handlers.NewMockDockerPinger(ctrl)
+		mockACSConnectionResolver := mock_handlers.NewMockACSConnectionResolver(ctrl)
+		mockStateSaveResolver := mock_handlers.NewMockStateSaveResolver(ctrl)
+		mockDockerPinger.EXPECT().PingDocker().Return(tc.dockerErr)
+		mockACSConnectionResolver.EXPECT().Connected().Return(tc.acsConnected)
+		mockStateSaveResolver.EXPECT().LastSaveError().Return(tc.saveErr)
+		requestHandler := healthV1RequestHandlerMaker(mockDockerPinger, mockACSConnectionResolver, mockStateSaveResolver)
+
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/health", nil)
+		requestHandler(recorder, req)
+
+		expectedCode := http.StatusServiceUnavailable
+		if tc.expected.Healthy {
+			expectedCode = http.StatusOK
+		}
+		if recorder.Code != expectedCode {
+			t.Errorf("%s: expected %d, got: %d", tc.name, expectedCode, recorder.Code)
+		}
+		var healthResponse HealthResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &healthResponse); err != nil {
+			t.Fatal(err)
+		}
+		if healthResponse != tc.expected {
+			t.Errorf("%s: expected %v, got: %v", tc.name, tc.expected, healthResponse)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestHealthCachesDockerPing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDockerPinger := mock_handlers.NewMockDockerPinger(ctrl)
+	mockACSConnectionResolver := mock_handlers.NewMockACSConnectionResolver(ctrl)
+	mockStateSaveResolver := mock_handlers.NewMockStateSaveResolver(ctrl)
+	// Requests made within the cache duration reuse the result of the ping,
+	// including a failed one
+	mockDockerPinger.EXPECT().PingDocker().Return(errors.New("daemon unavailable")).Times(1)
+	mockACSConnectionResolver.EXPECT().Connected().Return(true).Times(2)
+	mockStateSaveResolver.EXPECT().LastSaveError().Return(nil).Times(2)
+	requestHandler := healthV1RequestHandlerMaker(mockDockerPinger, mockACSConnectionResolver, mockStateSaveResolver)
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/health", nil)
+		requestHandler(recorder, req)
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected %d, got: %d", http.StatusServiceUnavailable, recorder.Code)
+		}
+	}
+}
+
+func TestHealthDockerPingTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldTimeout := dockerPingTimeout
+	dockerPingTimeout = 10 * time.Millisecond
+	defer func() { dockerPingTimeout = oldTimeout }()
+
+	mockDockerPinger := mock_handlers.NewMockDockerPinger(ctrl)
+	mockACSConnectionResolver := mock_handlers.NewMockACSConnectionResolver(ctrl)
+	mockStateSaveResolver := mock_handlers.NewMockStateSaveResolver(ctrl)
+	// The daemon doesn't answer until unblocked. Requests made meanwhile
+	// don't ping it again
+	unblockPing := make(chan struct{})
+	mockDockerPinger.EXPECT().PingDocker().Do(func() { <-unblockPing }).Return(nil).Times(1)
+	mockACSConnectionResolver.EXPECT().Connected().Return(true).AnyTimes()
+	mockStateSaveResolver.EXPECT().LastSaveError().Return(nil).AnyTimes()
+	requestHandler := healthV1RequestHandlerMaker(mockDockerPinger, mockACSConnectionResolver, mockStateSaveResolver)
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/health", nil)
+		start := time.Now()
+		requestHandler(recorder, req)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected a prompt response, took %s", elapsed)
+		}
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected %d, got: %d", http.StatusServiceUnavailable, recorder.Code)
+		}
+		var healthResponse HealthResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &healthResponse); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, HealthResponse{ACS: true, State: true}, healthResponse)
+	}
+
+	// Once the daemon answers, its result is reported
+	close(unblockPing)
+	for i := 0; i < 100; i++ {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/health", nil)
+		requestHandler(recorder, req)
+		if recorder.Code == http.StatusOK {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected docker to be reported healthy once it answered the ping")
+}
+
+func TestValidateTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTaskValidator := mock_handlers.NewMockTaskValidator(ctrl)
+	mockTaskValidator.EXPECT().ValidateTask(gomock.Any()).Do(func(task *api.Task) {
+		if task.Arn != "task1" || len(task.Containers) != 1 || task.Containers[0].Name != "web" {
+			t.Errorf("Unexpected task to validate: %v", task)
+		}
+	}).Return([]engine.TaskProblem{
+		{Container: "web", Name: "HostConfigError", Reason: "Invalid volume referenced: data"},
+	})
+	requestHandler := validateV1RequestHandlerMaker(mockTaskValidator)
+
+	recorder := httptest.NewRecorder()
+	body := `{"arn":"task1","containers":[{"name":"web","image":"nginx"}]}`
+	req, _ := http.NewRequest("POST", "/v1/validate", strings.NewReader(body))
+	requestHandler(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	var validateResponse ValidateTaskResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &validateResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TaskProblemResponse{Container: "web", Name: "HostConfigError", Reason: "Invalid volume referenced: data"}
+	if len(validateResponse.Problems) != 1 || validateResponse.Problems[0] != expected {
+		t.Errorf("Expected %v, got: %v", expected, validateResponse.Problems)
+	}
+}
+
+func TestValidateTaskNoProblems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTaskValidator := mock_handlers.NewMockTaskValidator(ctrl)
+	mockTaskValidator.EXPECT().ValidateTask(gomock.Any()).Return([]engine.TaskProblem{})
+	requestHandler := validateV1RequestHandlerMaker(mockTaskValidator)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/validate", strings.NewReader(`{"arn":"task1"}`))
+	requestHandler(recorder, req)
+
+	if body := recorder.Body.String(); body != `{"Problems":[]}` {
+		t.Errorf("Expected an empty list of problems, got: %s", body)
+	}
+}
+
+func TestValidateTaskBadRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := validateV1RequestHandlerMaker(mock_handlers.NewMockTaskValidator(ctrl))
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/validate", strings.NewReader("not a task"))
+	requestHandler(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for an invalid body, got: %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/validate", nil)
+	requestHandler(recorder, req)
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected %d for GET, got: %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	defer logger.ChangeLevel(logger.Level())
+	logger.ChangeLevel("info")
+
+	testCases := []struct {
+		method        string
+		path          string
+		expectedCode  int
+		expectedLevel string
+	}{
+		{"GET", "/v1/loglevel", http.StatusOK, "info"},
+		{"POST", "/v1/loglevel?level=debug", http.StatusOK, "debug"},
+		{"POST", "/v1/loglevel?level=verbose", http.StatusBadRequest, "debug"},
+		{"POST", "/v1/loglevel", http.StatusOK, logger.DEFAULT_LOGLEVEL},
+		{"DELETE", "/v1/loglevel", http.StatusMethodNotAllowed, logger.DEFAULT_LOGLEVEL},
+	}
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		logLevelV1RequestHandler(recorder, req)
+
+		if recorder.Code != tc.expectedCode {
+			t.Errorf("%s %s: expected %d, got: %d", tc.method, tc.path, tc.expectedCode, recorder.Code)
+		}
+		if level := logger.Level(); level != tc.expectedLevel {
+			t.Errorf("%s %s: expected level %s, got: %s", tc.method, tc.path, tc.expectedLevel, level)
+		}
+		if recorder.Code == http.StatusOK {
+			var logLevelResponse LogLevelResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &logLevelResponse); err != nil || logLevelResponse.Level != tc.expectedLevel {
+				t.Errorf("%s %s: unexpected response: %s", tc.method, tc.path, recorder.Body.String())
+			}
+		}
+	}
+}
+
+func TestLogLevelEndpointEnabled(t *testing.T) {
+	defer logger.ChangeLevel(logger.Level())
+	logger.ChangeLevel("info")
+
+	for _, enabled := range []bool{false, true} {
+		ctrl := gomock.NewController(t)
+		server := setupServer(utils.Strptr(testContainerInstanceArn), mock_handlers.NewMockDockerStateResolver(ctrl), mock_handlers.NewMockDrainStatusResolver(ctrl), mock_handlers.NewMockTaskValidator(ctrl), mock_handlers.NewMockDockerInfoResolver(ctrl), mock_handlers.NewMockDockerPinger(ctrl), mock_handlers.NewMockACSConnectionResolver(ctrl), mock_handlers.NewMockStateSaveResolver(ctrl), mock_handlers.NewMockContainerStatsResolver(ctrl), mock_handlers.NewMockEngineMetricsResolver(ctrl), mock_handlers.NewMockStatsMetricsResolver(ctrl), mock_handlers.NewMockContainerLogsResolver(ctrl), &config.Config{LogLevelEndpointEnabled: enabled})
+
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/loglevel?level=debug", nil)
+		server.Handler.ServeHTTP(recorder, req)
+		changed := logger.Level() == "debug"
+		if changed != enabled {
+			t.Errorf("Enabled %v: unexpected log level %s, response: %s", enabled, logger.Level(), recorder.Body.String())
+		}
+		ctrl.Finish()
+	}
+}
+
 func performMockRequest(t *testing.T, path string) *httptest.ResponseRecorder {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -289,7 +756,17 @@ func performMockRequest(t *testing.T, path string) *httptest.ResponseRecorder {
 	stateSetupHelper(state, testTasks)
 
 	mockStateResolver.EXPECT().State().Return(state)
-	requestHandler := setupServer(utils.Strptr(testContainerInstanceArn), mockStateResolver, &config.Config{Cluster: testClusterArn})
+	mockDrainStatusResolver := mock_handlers.NewMockDrainStatusResolver(ctrl)
+	mockTaskValidator := mock_handlers.NewMockTaskValidator(ctrl)
+	mockDockerInfoResolver := mock_handlers.NewMockDockerInfoResolver(ctrl)
+	mockDockerPinger := mock_handlers.NewMockDockerPinger(ctrl)
+	mockACSConnectionResolver := mock_handlers.NewMockACSConnectionResolver(ctrl)
+	mockStateSaveResolver := mock_handlers.NewMockStateSaveResolver(ctrl)
+	mockStatsResolver := mock_handlers.NewMockContainerStatsResolver(ctrl)
+	mockEngineMetricsResolver := mock_handlers.NewMockEngineMetricsResolver(ctrl)
+	mockStatsMetricsResolver := mock_handlers.NewMockStatsMetricsResolver(ctrl)
+	mockLogsResolver := mock_handlers.NewMockContainerLogsResolver(ctrl)
+	requestHandler := setupServer(utils.Strptr(testContainerInstanceArn), mockStateResolver, mockDrainStatusResolver, mockTaskValidator, mockDockerInfoResolver, mockDockerPinger, mockACSConnectionResolver, mockStateSaveResolver, mockStatsResolver, mockEngineMetricsResolver, mockStatsMetricsResolver, mockLogsResolver, &config.Config{Cluster: testClusterArn})
 
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", path, nil)
@@ -297,3 +774,202 @@ func performMockRequest(t *testing.T, path string) *httptest.ResponseRecorder {
 
 	return recorder
 }
+
+type logFrame struct {
+	stream stdcopy.StdType
+	text   string
+}
+
+// fakeLogsResolver writes its frames multiplexed the way docker sends logs.
+// When following, it then waits for the request to be done
+type fakeLogsResolver struct {
+	frames   []logFrame
+	err      error
+	dockerID string
+	tail     int
+	follow   bool
+	stopped  chan struct{}
+}
+
+func (resolver *fakeLogsResolver) ContainerLogs(ctx context.Context, dockerID string, tail int, follow bool, output io.Writer) error {
+	resolver.dockerID = dockerID
+	resolver.tail = tail
+	resolver.follow = follow
+	for _, frame := range resolver.frames {
+		if _, err := stdcopy.NewStdWriter(output, frame.stream).Write([]byte(frame.text)); err != nil {
+			return err
+		}
+	}
+	if follow {
+		<-ctx.Done()
+		close(resolver.stopped)
+	}
+	return resolver.err
+}
+
+func containerLogsRequest(t *testing.T, path string, resolver ContainerLogsResolver) *httptest.ResponseRecorder {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := dockerstate.NewDockerTaskEngineState()
+	stateSetupHelper(state, testTasks)
+	mockStateResolver := mock_handlers.NewMockDockerStateResolver(ctrl)
+	mockStateResolver.EXPECT().State().Return(state).AnyTimes()
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	containerLogsV1RequestHandlerMaker(mockStateResolver, resolver)(recorder, req)
+	return recorder
+}
+
+func TestContainerLogs(t *testing.T) {
+	resolver := &fakeLogsResolver{frames: []logFrame{
+		{stdcopy.Stdout, "starting\n"},
+		{stdcopy.Stderr, "warning: low memory\n"},
+		{stdcopy.Stdout, "started\n"},
+	}}
+	recorder := containerLogsRequest(t, "/v1/tasks/task1/containers/two/logs?tail=20", resolver)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	if body := recorder.Body.String(); body != "starting\nwarning: low memory\nstarted\n" {
+		t.Errorf("Unexpected logs: %q", body)
+	}
+	if resolver.dockerID != "dockerid-task1-two" || resolver.tail != 20 || resolver.follow {
+		t.Errorf("Unexpected logs requested: %s, %d, %v", resolver.dockerID, resolver.tail, resolver.follow)
+	}
+}
+
+func TestContainerLogsTaskArnWithSlashes(t *testing.T) {
+	task := &api.Task{
+		Arn:        "arn:aws:ecs:us-west-2:123456789012:task/cluster/abc",
+		Containers: []*api.Container{{Name: "app"}},
+	}
+	state := dockerstate.NewDockerTaskEngineState()
+	stateSetupHelper(state, []*api.Task{task})
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStateResolver := mock_handlers.NewMockDockerStateResolver(ctrl)
+	mockStateResolver.EXPECT().State().Return(state)
+
+	resolver := &fakeLogsResolver{}
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/tasks/"+task.Arn+"/containers/app/logs", nil)
+	containerLogsV1RequestHandlerMaker(mockStateResolver, resolver)(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got: %d", http.StatusOK, recorder.Code)
+	}
+	if resolver.dockerID != "dockerid-"+task.Arn+"-app" {
+		t.Errorf("Unexpected container: %s", resolver.dockerID)
+	}
+}
+
+func TestContainerLogsTailLimit(t *testing.T) {
+	testCases := []struct {
+		query        string
+		expectedCode int
+		expectedTail int
+	}{
+		{"", http.StatusOK, defaultLogsTailLines},
+		{"?tail=0", http.StatusOK, 0},
+		{"?tail=50000", http.StatusOK, maxLogsTailLines},
+		{"?tail=-1", http.StatusBadRequest, 0},
+		{"?tail=all", http.StatusBadRequest, 0},
+		{"?follow=sometimes", http.StatusBadRequest, 0},
+	}
+	for _, tc := range testCases {
+		resolver := &fakeLogsResolver{}
+		recorder := containerLogsRequest(t, "/v1/tasks/task1/containers/one/logs"+tc.query, resolver)
+		if recorder.Code != tc.expectedCode {
+			t.Errorf("%s: expected %d, got: %d", tc.query, tc.expectedCode, recorder.Code)
+		}
+		if resolver.tail != tc.expectedTail {
+			t.Errorf("%s: expected %d lines, got: %d", tc.query, tc.expectedTail, resolver.tail)
+		}
+	}
+}
+
+func TestContainerLogsErrors(t *testing.T) {
+	testCases := []struct {
+		path         string
+		resolverErr  error
+		expectedCode int
+	}{
+		{"/v1/tasks/task1/containers/three/logs", nil, http.StatusNotFound},
+		{"/v1/tasks/task3/containers/one/logs", nil, http.StatusNotFound},
+		{"/v1/tasks/task1/containers//logs", nil, http.StatusNotFound},
+		{"/v1/tasks/task1/logs", nil, http.StatusNotFound},
+		{"/v1/tasks/task1/containers/one", nil, http.StatusNotFound},
+		{"/v1/tasks/task1/containers/one/logs", errors.New("no such container"), http.StatusInternalServerError},
+	}
+	for _, tc := range testCases {
+		recorder := containerLogsRequest(t, tc.path, &fakeLogsResolver{err: tc.resolverErr})
+		if recorder.Code != tc.expectedCode {
+			t.Errorf("%s: expected %d, got: %d", tc.path, tc.expectedCode, recorder.Code)
+		}
+	}
+}
+
+func TestContainerLogsFollowStopsWhenClientDisconnects(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	state := dockerstate.NewDockerTaskEngineState()
+	stateSetupHelper(state, testTasks)
+	mockStateResolver := mock_handlers.NewMockDockerStateResolver(ctrl)
+	mockStateResolver.EXPECT().State().Return(state)
+
+	resolver := &fakeLogsResolver{
+		frames:  []logFrame{{stdcopy.Stdout, "first\n"}, {stdcopy.Stderr, "second\n"}},
+		stopped: make(chan struct{}),
+	}
+	server := httptest.NewServer(http.HandlerFunc(containerLogsV1RequestHandlerMaker(mockStateResolver, resolver)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/tasks/task2/containers/foo/logs?follow=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+	logs := make([]byte, len("first\nsecond\n"))
+	if _, err := io.ReadFull(resp.Body, logs); err != nil || string(logs) != "first\nsecond\n" {
+		t.Fatalf("Unexpected logs: %q, %v", logs, err)
+	}
+	if !resolver.follow {
+		t.Error("Expected logs to be followed")
+	}
+
+	resp.Body.Close()
+	select {
+	case <-resolver.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for logs to stop being followed")
+	}
+}
+
+func TestContainerLogsEndpointEnabled(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		ctrl := gomock.NewController(t)
+		state := dockerstate.NewDockerTaskEngineState()
+		stateSetupHelper(state, testTasks)
+		mockStateResolver := mock_handlers.NewMockDockerStateResolver(ctrl)
+		mockLogsResolver := mock_handlers.NewMockContainerLogsResolver(ctrl)
+		if enabled {
+			mockStateResolver.EXPECT().State().Return(state)
+			mockLogsResolver.EXPECT().ContainerLogs(gomock.Any(), "dockerid-task1-one", defaultLogsTailLines, false, gomock.Any()).Return(nil)
+		}
+		server := setupServer(utils.Strptr(testContainerInstanceArn), mockStateResolver, mock_handlers.NewMockDrainStatusResolver(ctrl), mock_handlers.NewMockTaskValidator(ctrl), mock_handlers.NewMockDockerInfoResolver(ctrl), mock_handlers.NewMockDockerPinger(ctrl), mock_handlers.NewMockACSConnectionResolver(ctrl), mock_handlers.NewMockStateSaveResolver(ctrl), mock_handlers.NewMockContainerStatsResolver(ctrl), mock_handlers.NewMockEngineMetricsResolver(ctrl), mock_handlers.NewMockStatsMetricsResolver(ctrl), mockLogsResolver, &config.Config{ContainerLogsEndpointEnabled: enabled})
+
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/tasks/task1/containers/one/logs", nil)
+		server.Handler.ServeHTTP(recorder, req)
+		servedCommands := strings.Contains(recorder.Body.String(), "AvailableCommands")
+		if servedCommands == enabled {
+			t.Errorf("Enabled %v: unexpected response: %s", enabled, recorder.Body.String())
+		}
+		ctrl.Finish()
+	}
+}
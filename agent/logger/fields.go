@@ -0,0 +1,150 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+)
+
+const (
+	// LOGFORMAT_JSON logs one json object per line
+	LOGFORMAT_JSON = "json"
+	// LOGFORMAT_TEXT logs lines of text; it is the default
+	LOGFORMAT_TEXT = "text"
+
+	// jsonFormatterName is the name of the seelog formatter of the json format
+	jsonFormatterName = "EcsJSON"
+
+	// fieldsSeparator separates the message from its fields, encoded as a json
+	// object, in messages logged with fields in the json format
+	fieldsSeparator = "\x1e"
+)
+
+// Fields are key/value pairs logged along with a message. In the text format
+// they are appended to the message as key="value"; in the json format they
+// are logged as the "fields" object
+type Fields map[string]interface{}
+
+// jsonLine is a line logged in the json format
+type jsonLine struct {
+	Time    string          `json:"time"`
+	Level   string          `json:"level"`
+	Message string          `json:"msg"`
+	Fields  json.RawMessage `json:"fields,omitempty"`
+}
+
+// registerJSONFormatter registers the seelog formatter of the json format,
+// which must be done before a logger using it is created
+func registerJSONFormatter() {
+	err := log.RegisterCustomFormatter(jsonFormatterName, func(string) log.FormatterFunc {
+		return func(message string, level log.LogLevel, context log.LogContextInterface) interface{} {
+			return formatJSON(message, level, context.CallTime())
+		}
+	})
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// Debug logs a message with fields at the debug level
+func Debug(msg string, fields Fields) {
+	log.Debug(withFields(msg, fields))
+}
+
+// Info logs a message with fields at the info level
+func Info(msg string, fields Fields) {
+	log.Info(withFields(msg, fields))
+}
+
+// Warn logs a message with fields at the warn level
+func Warn(msg string, fields Fields) {
+	log.Warn(withFields(msg, fields))
+}
+
+// Error logs a message with fields at the error level
+func Error(msg string, fields Fields) {
+	log.Error(withFields(msg, fields))
+}
+
+// withFields returns the message to log for a message with fields in the
+// configured log format
+func withFields(msg string, fields Fields) string {
+	if len(fields) == 0 {
+		return msg
+	}
+	if format != LOGFORMAT_JSON {
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			msg += fmt.Sprintf(" %s=\"%+v\"", key, fields[key])
+		}
+		return msg
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		values[key] = fieldValue(value)
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return msg + " [malformed fields omitted]"
+	}
+	return msg + fieldsSeparator + string(encoded)
+}
+
+// fieldValue returns the value of a field as it is encoded in the json format.
+// Strings, numbers and booleans are kept as they are and other values are
+// formatted as strings, as they may not be json encodable
+func fieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
+
+// formatJSON returns the line logged for a message in the json format
+func formatJSON(message string, level log.LogLevel, callTime time.Time) string {
+	line := jsonLine{
+		Time:    callTime.UTC().Format(time.RFC3339),
+		Level:   level.String(),
+		Message: message,
+	}
+	if i := strings.Index(message, fieldsSeparator); i >= 0 {
+		line.Message = message[:i]
+		line.Fields = json.RawMessage(message[i+len(fieldsSeparator):])
+	}
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		// The fields were malformed; they are logged as part of the message
+		line.Message = message
+		line.Fields = nil
+		encoded, _ = json.Marshal(line)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,142 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/cihub/seelog"
+)
+
+// withFormat sets the log format for the duration of a test
+func withFormat(logFormat string) func() {
+	previous := format
+	format = logFormat
+	return func() { format = previous }
+}
+
+func TestWithFieldsText(t *testing.T) {
+	defer withFormat(LOGFORMAT_TEXT)()
+
+	formatted := withFields("simple message", Fields{"task": "arn", "count": 2})
+	expected := "simple message count=\"2\" task=\"arn\""
+	if formatted != expected {
+		t.Errorf("Formatted message %s does not match expected %s", formatted, expected)
+	}
+	if formatted := withFields("simple message", nil); formatted != "simple message" {
+		t.Errorf("Expected the message without fields to be unchanged, got: %s", formatted)
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	defer withFormat(LOGFORMAT_JSON)()
+
+	callTime := time.Date(2016, 11, 1, 12, 30, 0, 0, time.UTC)
+	message := withFields("simple message", Fields{
+		"task":  "arn",
+		"count": 2,
+		"err":   errors.New("failed"),
+		"state": struct{ hello string }{hello: "world"},
+	})
+	line := formatJSON(message, log.InfoLvl, callTime)
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+		t.Fatalf("Expected a json object, got: %s: %v", line, err)
+	}
+	expected := map[string]interface{}{
+		"time":  "2016-11-01T12:30:00Z",
+		"level": "info",
+		"msg":   "simple message",
+		"fields": map[string]interface{}{
+			"task":  "arn",
+			"count": float64(2),
+			"err":   "failed",
+			"state": "{hello:world}",
+		},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %v, got: %v", expected, decoded)
+	}
+}
+
+func TestFormatJSONWithoutFields(t *testing.T) {
+	line := formatJSON("message with \"quotes\"\nand lines", log.WarnLvl, time.Now())
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+		t.Fatalf("Expected a json object, got: %s: %v", line, err)
+	}
+	if decoded["msg"] != "message with \"quotes\"\nand lines" || decoded["level"] != "warn" {
+		t.Errorf("Unexpected json line: %s", line)
+	}
+	if _, ok := decoded["fields"]; ok {
+		t.Errorf("Expected no fields, got: %s", line)
+	}
+}
+
+func TestShimJSON(t *testing.T) {
+	defer withFormat(LOGFORMAT_JSON)()
+
+	shim := (&Shim{ctx: []interface{}{"module", "engine"}}).New("task", "arn").(*Shim)
+	line := formatJSON(shim.formatMessage("simple message", "count", 3), log.DebugLvl, time.Now())
+
+	var decoded struct {
+		Message string                 `json:"msg"`
+		Fields  map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+		t.Fatalf("Expected a json object, got: %s: %v", line, err)
+	}
+	expected := map[string]interface{}{"module": "engine", "task": "arn", "count": float64(3)}
+	if decoded.Message != "simple message" || !reflect.DeepEqual(decoded.Fields, expected) {
+		t.Errorf("Unexpected json line: %s", line)
+	}
+}
+
+func TestJSONLoggerOutput(t *testing.T) {
+	defer withFormat(LOGFORMAT_JSON)()
+
+	var out bytes.Buffer
+	jsonLogger, err := log.LoggerFromWriterWithMinLevelAndFormat(&out, log.DebugLvl, formatString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonLogger.Info(withFields("first", Fields{"id": "abc"}))
+	jsonLogger.Errorf("second %d", 2)
+	jsonLogger.Flush()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got: %q", out.String())
+	}
+	for _, line := range lines {
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Errorf("Expected a json object, got: %s: %v", line, err)
+			continue
+		}
+		for _, key := range []string{"time", "level", "msg"} {
+			if _, ok := decoded[key]; !ok {
+				t.Errorf("Expected %s in line: %s", key, line)
+			}
+		}
+	}
+}
@@ -14,6 +14,7 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"sync"
@@ -22,17 +23,23 @@ import (
 )
 
 const (
-	LOGLEVEL_ENV_VAR = "ECS_LOGLEVEL"
-	LOGFILE_ENV_VAR  = "ECS_LOGFILE"
+	LOGLEVEL_ENV_VAR  = "ECS_LOGLEVEL"
+	LOGFILE_ENV_VAR   = "ECS_LOGFILE"
+	LOGFORMAT_ENV_VAR = "ECS_LOG_FORMAT"
 
 	DEFAULT_LOGLEVEL = "info"
 )
 
 var logfile string
 var level string
+var format string
 var levels map[string]string
 var logger OldLogger
 
+// levelName is the log level as accepted by ECS_LOGLEVEL, such as "crit"
+var levelName string
+var levelLock sync.Mutex
+
 // Initialize this logger once
 var once sync.Once
 
@@ -50,12 +57,18 @@ func initLogger() {
 		"none":  "off",
 	}
 	level = DEFAULT_LOGLEVEL
+	levelName = DEFAULT_LOGLEVEL
 
 	logger = &Shim{}
+	registerJSONFormatter()
 
 	envLevel := os.Getenv(LOGLEVEL_ENV_VAR)
 
 	logfile = os.Getenv(LOGFILE_ENV_VAR)
+	format = LOGFORMAT_TEXT
+	if strings.ToLower(os.Getenv(LOGFORMAT_ENV_VAR)) == LOGFORMAT_JSON {
+		format = LOGFORMAT_JSON
+	}
 	SetLevel(envLevel)
 	reloadConfig()
 }
@@ -69,13 +82,43 @@ func reloadConfig() {
 	}
 }
 
-// SetLevel sets the log level for logging
+// SetLevel sets the log level for logging. Unknown levels are ignored
 func SetLevel(logLevel string) {
-	parsedLevel, ok := levels[strings.ToLower(logLevel)]
-	if ok {
-		level = parsedLevel
-		reloadConfig()
+	ChangeLevel(logLevel)
+}
+
+// ChangeLevel sets the log level for logging, returning an error if the level
+// is unknown
+func ChangeLevel(logLevel string) error {
+	name := strings.ToLower(logLevel)
+	parsedLevel, ok := levels[name]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", logLevel)
 	}
+	levelLock.Lock()
+	defer levelLock.Unlock()
+	level = parsedLevel
+	levelName = name
+	reloadConfig()
+	return nil
+}
+
+// ReloadLevel sets the log level for logging from ECS_LOGLEVEL, or to the
+// default level if it isn't set, so that it can be changed without
+// restarting the agent
+func ReloadLevel() error {
+	envLevel := os.Getenv(LOGLEVEL_ENV_VAR)
+	if envLevel == "" {
+		envLevel = DEFAULT_LOGLEVEL
+	}
+	return ChangeLevel(envLevel)
+}
+
+// Level returns the log level for logging, as accepted by ECS_LOGLEVEL
+func Level() string {
+	levelLock.Lock()
+	defer levelLock.Unlock()
+	return levelName
 }
 
 // ForModule returns an OldLogger instance.  OldLogger is deprecated and kept
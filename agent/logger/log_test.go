@@ -0,0 +1,104 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	log "github.com/cihub/seelog"
+)
+
+// captureLogs returns what is logged to the console while f runs
+func captureLogs(t *testing.T, f func()) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	f()
+	log.Flush()
+	os.Stdout = stdout
+	writer.Close()
+
+	out, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestChangeLevel(t *testing.T) {
+	defer ChangeLevel(Level())
+
+	out := captureLogs(t, func() {
+		if err := ChangeLevel("warn"); err != nil {
+			t.Fatal(err)
+		}
+		log.Info("hidden at warn")
+		log.Warn("shown at warn")
+	})
+	if strings.Contains(out, "hidden at warn") || !strings.Contains(out, "shown at warn") {
+		t.Errorf("Expected only warnings to be logged, got: %q", out)
+	}
+
+	out = captureLogs(t, func() {
+		if err := ChangeLevel("DEBUG"); err != nil {
+			t.Fatal(err)
+		}
+		log.Debug("shown at debug")
+	})
+	if !strings.Contains(out, "shown at debug") {
+		t.Errorf("Expected debug messages to be logged, got: %q", out)
+	}
+	if level := Level(); level != "debug" {
+		t.Errorf("Expected the level to be debug, got: %s", level)
+	}
+}
+
+func TestChangeLevelUnknown(t *testing.T) {
+	defer ChangeLevel(Level())
+	ChangeLevel("warn")
+
+	if err := ChangeLevel("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+	if level := Level(); level != "warn" {
+		t.Errorf("Expected the level to be unchanged, got: %s", level)
+	}
+}
+
+func TestReloadLevel(t *testing.T) {
+	defer ChangeLevel(Level())
+	defer os.Unsetenv(LOGLEVEL_ENV_VAR)
+
+	os.Setenv(LOGLEVEL_ENV_VAR, "crit")
+	if err := ReloadLevel(); err != nil {
+		t.Fatal(err)
+	}
+	if level := Level(); level != "crit" {
+		t.Errorf("Expected the level from %s, got: %s", LOGLEVEL_ENV_VAR, level)
+	}
+
+	os.Unsetenv(LOGLEVEL_ENV_VAR)
+	if err := ReloadLevel(); err != nil {
+		t.Fatal(err)
+	}
+	if level := Level(); level != DEFAULT_LOGLEVEL {
+		t.Errorf("Expected the default level, got: %s", level)
+	}
+}
@@ -25,9 +25,17 @@ func loggerConfig() string {
 	config += `
 		</outputs>
 		<formats>
-			<format id="main" format="%UTCDate(2006-01-02T15:04:05Z07:00) [%LEVEL] %Msg%n" />
+			<format id="main" format="` + formatString() + `" />
 		</formats>
 	</seelog>
 `
 	return config
 }
+
+// formatString returns the seelog format of the configured log format
+func formatString() string {
+	if format == LOGFORMAT_JSON {
+		return "%" + jsonFormatterName + "%n"
+	}
+	return "%UTCDate(2006-01-02T15:04:05Z07:00) [%LEVEL] %Msg%n"
+}
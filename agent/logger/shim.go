@@ -71,6 +71,13 @@ func (s *Shim) formatMessage(msg string, ctx ...interface{}) string {
 		return msg + " [malformed ctx omitted]"
 	}
 	fullCtx := append(s.ctx, ctx...)
+	if format == LOGFORMAT_JSON {
+		fields := make(Fields, len(fullCtx)/2)
+		for i := 0; i < len(fullCtx); i += 2 {
+			fields[fmt.Sprint(fullCtx[i])] = fullCtx[i+1]
+		}
+		return withFields(msg, fields)
+	}
 	var retval string
 	for i := 0; i < len(fullCtx); i += 2 {
 		retval += fmt.Sprintf(" %v=\"%+v\"", fullCtx[i], fullCtx[i+1])
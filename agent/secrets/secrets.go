@@ -0,0 +1,213 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package secrets fetches the values of the secrets containers reference from
+// SSM Parameter Store and Secrets Manager
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/httpclient"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	awscredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"github.com/aws/aws-sdk-go/private/signer/v4"
+)
+
+const roundtripTimeout = 5 * time.Second
+
+// Fetcher fetches the values of secrets. The values must never be logged
+type Fetcher interface {
+	// Fetch returns the value of the secret valueFrom refers to, which is the
+	// ARN of an SSM parameter or a Secrets Manager secret, or the name of an
+	// SSM parameter in the region of the instance. The role credentials of the
+	// task are used to fetch it, if the task has a role, and the credentials
+	// of the instance otherwise
+	Fetch(valueFrom string, roleCredentials *credentials.IAMRoleCredentials) (string, error)
+}
+
+// service describes the JSON RPC API of a service secrets are fetched from
+type service struct {
+	name         string
+	apiVersion   string
+	targetPrefix string
+}
+
+var (
+	ssmService            = service{name: "ssm", apiVersion: "2014-11-06", targetPrefix: "AmazonSSM"}
+	secretsManagerService = service{name: "secretsmanager", apiVersion: "2017-10-17", targetPrefix: "secretsmanager"}
+)
+
+// reference is where the value of a secret is fetched from
+type reference struct {
+	service service
+	region  string
+	// id is the name of the SSM parameter or the ARN of the secret
+	id string
+}
+
+// parseValueFrom returns where the value of a secret is fetched from
+func parseValueFrom(valueFrom string, defaultRegion string) (reference, error) {
+	if valueFrom == "" {
+		return reference{}, errors.New("no parameter or secret to fetch the value from")
+	}
+	if !strings.HasPrefix(valueFrom, "arn:") {
+		return reference{service: ssmService, region: defaultRegion, id: valueFrom}, nil
+	}
+
+	// arn:partition:service:region:account:resource
+	parts := strings.SplitN(valueFrom, ":", 6)
+	if len(parts) != 6 || parts[3] == "" {
+		return reference{}, fmt.Errorf("malformed ARN %s", valueFrom)
+	}
+	region, resource := parts[3], parts[5]
+	switch parts[2] {
+	case ssmService.name:
+		if !strings.HasPrefix(resource, "parameter/") {
+			return reference{}, fmt.Errorf("ARN %s is not an SSM parameter", valueFrom)
+		}
+		// The names of parameters in a hierarchy start with a slash, which
+		// the ARN doesn't repeat
+		name := strings.TrimPrefix(resource, "parameter")
+		if !strings.Contains(name[1:], "/") {
+			name = name[1:]
+		}
+		return reference{service: ssmService, region: region, id: name}, nil
+	case secretsManagerService.name:
+		if !strings.HasPrefix(resource, "secret:") {
+			return reference{}, fmt.Errorf("ARN %s is not a Secrets Manager secret", valueFrom)
+		}
+		return reference{service: secretsManagerService, region: region, id: valueFrom}, nil
+	}
+	return reference{}, fmt.Errorf("ARN %s is not an SSM parameter or a Secrets Manager secret", valueFrom)
+}
+
+type awsFetcher struct {
+	region     string
+	httpClient *http.Client
+	// endpoint overrides the endpoints of the services
+	endpoint string
+}
+
+// NewFetcher returns a Fetcher that fetches secrets from the AWS APIs.
+// Parameters referenced by name are fetched from the given region
+func NewFetcher(region string) Fetcher {
+	return &awsFetcher{
+		region:     region,
+		httpClient: httpclient.New(roundtripTimeout, false),
+	}
+}
+
+type getParameterInput struct {
+	_ struct{} `type:"structure"`
+
+	Name *string `type:"string" required:"true"`
+
+	WithDecryption *bool `type:"boolean"`
+}
+
+type getParameterOutput struct {
+	_ struct{} `type:"structure"`
+
+	Parameter *parameter `type:"structure"`
+}
+
+type parameter struct {
+	_ struct{} `type:"structure"`
+
+	Value *string `type:"string"`
+}
+
+type getSecretValueInput struct {
+	_ struct{} `type:"structure"`
+
+	SecretId *string `type:"string" required:"true"`
+}
+
+type getSecretValueOutput struct {
+	_ struct{} `type:"structure"`
+
+	SecretString *string `type:"string"`
+}
+
+func (fetcher *awsFetcher) Fetch(valueFrom string, roleCredentials *credentials.IAMRoleCredentials) (string, error) {
+	ref, err := parseValueFrom(valueFrom, fetcher.region)
+	if err != nil {
+		return "", err
+	}
+	sdkClient := fetcher.newClient(ref, roleCredentials)
+
+	switch ref.service {
+	case ssmService:
+		output := &getParameterOutput{}
+		input := &getParameterInput{Name: aws.String(ref.id), WithDecryption: aws.Bool(true)}
+		if err := sdkClient.NewRequest(&request.Operation{Name: "GetParameter", HTTPMethod: "POST", HTTPPath: "/"}, input, output).Send(); err != nil {
+			return "", err
+		}
+		if output.Parameter == nil || output.Parameter.Value == nil {
+			return "", fmt.Errorf("parameter %s has no value", ref.id)
+		}
+		return aws.StringValue(output.Parameter.Value), nil
+	default:
+		output := &getSecretValueOutput{}
+		input := &getSecretValueInput{SecretId: aws.String(ref.id)}
+		if err := sdkClient.NewRequest(&request.Operation{Name: "GetSecretValue", HTTPMethod: "POST", HTTPPath: "/"}, input, output).Send(); err != nil {
+			return "", err
+		}
+		if output.SecretString == nil {
+			return "", fmt.Errorf("secret %s has no string value", ref.id)
+		}
+		return aws.StringValue(output.SecretString), nil
+	}
+}
+
+// newClient returns a client of the service the secret is fetched from
+func (fetcher *awsFetcher) newClient(ref reference, roleCredentials *credentials.IAMRoleCredentials) *client.Client {
+	cfg := aws.NewConfig().WithRegion(ref.region).WithHTTPClient(fetcher.httpClient)
+	if fetcher.endpoint != "" {
+		cfg = cfg.WithEndpoint(fetcher.endpoint)
+	}
+	if roleCredentials != nil {
+		cfg = cfg.WithCredentials(awscredentials.NewStaticCredentials(roleCredentials.AccessKeyID, roleCredentials.SecretAccessKey, roleCredentials.SessionToken))
+	}
+	clientConfig := session.New(cfg).ClientConfig(ref.service.name)
+
+	sdkClient := client.New(
+		*clientConfig.Config,
+		metadata.ClientInfo{
+			ServiceName:   ref.service.name,
+			SigningRegion: clientConfig.SigningRegion,
+			Endpoint:      clientConfig.Endpoint,
+			APIVersion:    ref.service.apiVersion,
+			JSONVersion:   "1.1",
+			TargetPrefix:  ref.service.targetPrefix,
+		},
+		clientConfig.Handlers,
+	)
+	sdkClient.Handlers.Sign.PushBack(v4.Sign)
+	sdkClient.Handlers.Build.PushBack(jsonrpc.Build)
+	sdkClient.Handlers.Unmarshal.PushBack(jsonrpc.Unmarshal)
+	sdkClient.Handlers.UnmarshalMeta.PushBack(jsonrpc.UnmarshalMeta)
+	sdkClient.Handlers.UnmarshalError.PushBack(jsonrpc.UnmarshalError)
+	return sdkClient
+}
@@ -0,0 +1,135 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseValueFrom(t *testing.T) {
+	testCases := []struct {
+		valueFrom string
+		expected  reference
+	}{
+		{"db-password", reference{ssmService, "us-west-2", "db-password"}},
+		{"/prod/db/password", reference{ssmService, "us-west-2", "/prod/db/password"}},
+		{"arn:aws:ssm:us-east-1:123456789012:parameter/db-password", reference{ssmService, "us-east-1", "db-password"}},
+		{"arn:aws:ssm:us-east-1:123456789012:parameter/prod/db/password", reference{ssmService, "us-east-1", "/prod/db/password"}},
+		{
+			"arn:aws:secretsmanager:eu-west-1:123456789012:secret:db-password-AbCdEf",
+			reference{secretsManagerService, "eu-west-1", "arn:aws:secretsmanager:eu-west-1:123456789012:secret:db-password-AbCdEf"},
+		},
+	}
+	for _, tc := range testCases {
+		ref, err := parseValueFrom(tc.valueFrom, "us-west-2")
+		if assert.Nil(t, err, tc.valueFrom) {
+			assert.Equal(t, tc.expected, ref, tc.valueFrom)
+		}
+	}
+}
+
+func TestParseValueFromInvalid(t *testing.T) {
+	for _, valueFrom := range []string{
+		"",
+		"arn:aws:ssm",
+		"arn:aws:ssm::123456789012:parameter/db-password",
+		"arn:aws:ssm:us-east-1:123456789012:document/db-password",
+		"arn:aws:secretsmanager:us-east-1:123456789012:db-password",
+		"arn:aws:s3:::bucket/db-password",
+	} {
+		_, err := parseValueFrom(valueFrom, "us-west-2")
+		assert.NotNil(t, err, valueFrom)
+	}
+}
+
+// secretsServer returns a server answering the JSON RPC requests of the
+// services with the given responses, by X-Amz-Target, and the requests it
+// received
+func secretsServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]map[string]interface{}) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		request := make(map[string]interface{})
+		json.Unmarshal(body, &request)
+		request["target"] = r.Header.Get("X-Amz-Target")
+		request["authorization"] = r.Header.Get("Authorization")
+		requests = append(requests, request)
+
+		response, ok := responses[r.Header.Get("X-Amz-Target")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ParameterNotFound","message":"Parameter not found"}`))
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	return server, &requests
+}
+
+func TestFetch(t *testing.T) {
+	server, requests := secretsServer(t, map[string]string{
+		"AmazonSSM.GetParameter":        `{"Parameter":{"Name":"db-password","Value":"hunter2"}}`,
+		"secretsmanager.GetSecretValue": `{"Name":"api-key","SecretString":"s3cr3t"}`,
+	})
+	defer server.Close()
+	fetcher := &awsFetcher{region: "us-west-2", httpClient: http.DefaultClient, endpoint: server.URL}
+	roleCredentials := &credentials.IAMRoleCredentials{AccessKeyID: "TASKKEY", SecretAccessKey: "secret", SessionToken: "token"}
+
+	value, err := fetcher.Fetch("db-password", roleCredentials)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "hunter2", value)
+	}
+	secretArn := "arn:aws:secretsmanager:us-west-2:123456789012:secret:api-key-AbCdEf"
+	value, err = fetcher.Fetch(secretArn, roleCredentials)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "s3cr3t", value)
+	}
+
+	if assert.Len(t, *requests, 2) {
+		parameterRequest, secretRequest := (*requests)[0], (*requests)[1]
+		assert.Equal(t, "AmazonSSM.GetParameter", parameterRequest["target"])
+		assert.Equal(t, "db-password", parameterRequest["Name"])
+		assert.Equal(t, true, parameterRequest["WithDecryption"])
+		assert.Equal(t, "secretsmanager.GetSecretValue", secretRequest["target"])
+		assert.Equal(t, secretArn, secretRequest["SecretId"])
+		// The requests are signed with the credentials of the task role
+		assert.True(t, strings.Contains(parameterRequest["authorization"].(string), "Credential=TASKKEY/"))
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	server, _ := secretsServer(t, map[string]string{
+		"secretsmanager.GetSecretValue": `{"Name":"api-key","SecretBinary":"AAEC"}`,
+	})
+	defer server.Close()
+	fetcher := &awsFetcher{region: "us-west-2", httpClient: http.DefaultClient, endpoint: server.URL}
+	roleCredentials := &credentials.IAMRoleCredentials{AccessKeyID: "TASKKEY", SecretAccessKey: "secret"}
+
+	_, err := fetcher.Fetch("missing-parameter", roleCredentials)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "ParameterNotFound")
+	}
+	_, err = fetcher.Fetch("arn:aws:secretsmanager:us-west-2:123456789012:secret:api-key-AbCdEf", roleCredentials)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "has no string value")
+	}
+}
@@ -13,7 +13,8 @@
 
 // sighandlers handle signals and behave appropriately.
 // SIGTERM:
-//   Flush state to disk and exit
+//   Optionally drain the task engine, flush buffered telemetry and state
+//   changes to the backend, flush state to disk and exit
 // SIGUSR1:
 //   Print a dump of goroutines to the logger and DON'T exit
 package sighandlers
@@ -22,6 +23,7 @@ import (
 	"errors"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -34,24 +36,85 @@ import (
 
 var log = logger.ForModule("TerminationHandler")
 
-func StartTerminationHandler(saver statemanager.Saver, taskEngine engine.TaskEngine) {
+// drainer is implemented by task engines that can stop accepting new tasks
+// and wait for the running ones to stop
+type drainer interface {
+	Drain(timeout time.Duration) bool
+}
+
+// Flusher sends data the agent buffers to the backend, waiting up to timeout
+// for it to be sent
+type Flusher func(timeout time.Duration) error
+
+// StartTerminationHandler waits for a termination signal and exits once state
+// has been saved. If drainTimeout is positive, the task engine stops accepting
+// new tasks and waits up to drainTimeout for running tasks to stop first. The
+// flushers are then given a chance to send what they buffer to the backend
+func StartTerminationHandler(saver statemanager.Saver, taskEngine engine.TaskEngine, drainTimeout time.Duration, flushers []Flusher) {
 	signalChannel := make(chan os.Signal, 2)
 	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
 
+	os.Exit(handleTermination(signalChannel, saver, taskEngine, drainTimeout, flushers))
+}
+
+// handleTermination waits for a signal on signalChannel and returns the exit
+// code the agent should exit with
+func handleTermination(signalChannel <-chan os.Signal, saver statemanager.Saver, taskEngine engine.TaskEngine, drainTimeout time.Duration, flushers []Flusher) int {
 	sig := <-signalChannel
 	log.Debug("Received termination signal", "signal", sig.String())
 
+	if drainTimeout > 0 {
+		if drainableEngine, ok := taskEngine.(drainer); ok {
+			drainableEngine.Drain(drainTimeout)
+		}
+	}
+
+	FlushBeforeExit(flushers)
+
 	err := FinalSave(saver, taskEngine)
 	if err != nil {
 		log.Crit("Error saving state before final shutdown", "err", err)
 		// Terminal because it's a sigterm; the user doesn't want it to restart
-		os.Exit(exitcodes.ExitTerminal)
+		return exitcodes.ExitTerminal
 	}
-	os.Exit(exitcodes.ExitSuccess)
+	return exitcodes.ExitSuccess
 }
 
 const engineDisableTimeout = 5 * time.Second
 const finalSaveTimeout = 3 * time.Second
+const flushTimeout = 5 * time.Second
+
+// FlushBeforeExit should be called before exiting, whether on a termination
+// signal or on an error the agent can't recover from, so that telemetry and
+// state changes it buffers aren't lost. The flushers run concurrently and it
+// returns once all of them are done or flushTimeout has passed
+func FlushBeforeExit(flushers []Flusher) {
+	flush(flushers, flushTimeout)
+}
+
+func flush(flushers []Flusher, timeout time.Duration) {
+	var wait sync.WaitGroup
+	for _, flusher := range flushers {
+		wait.Add(1)
+		go func(flusher Flusher) {
+			defer wait.Done()
+			if err := flusher(timeout); err != nil {
+				log.Warn("Error flushing before shutdown", "err", err)
+			}
+		}(flusher)
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		wait.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(timeout):
+		log.Warn("Timed out flushing before shutdown", "timeout", timeout)
+	}
+}
 
 // FinalSave should be called immediately before exiting, and only before
 // exiting, in order to flush tasks to disk. It waits a short timeout for state
@@ -0,0 +1,140 @@
+// +build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sighandlers
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager/mocks"
+	"github.com/golang/mock/gomock"
+)
+
+// drainableTaskEngine records the timeout it was drained with
+type drainableTaskEngine struct {
+	*engine.MockTaskEngine
+	drainTimeout time.Duration
+}
+
+func (taskEngine *drainableTaskEngine) Drain(timeout time.Duration) bool {
+	taskEngine.drainTimeout = timeout
+	return true
+}
+
+func TestHandleTerminationDrainsTaskEngine(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	saver := mock_statemanager.NewMockStateManager(ctrl)
+	taskEngine := &drainableTaskEngine{MockTaskEngine: engine.NewMockTaskEngine(ctrl)}
+
+	gomock.InOrder(
+		taskEngine.EXPECT().Disable(),
+		saver.EXPECT().ForceSave().Return(nil),
+	)
+
+	signalChannel := make(chan os.Signal, 1)
+	signalChannel <- syscall.SIGTERM
+	exitCode := handleTermination(signalChannel, saver, taskEngine, time.Minute, nil)
+	if exitCode != exitcodes.ExitSuccess {
+		t.Errorf("Expected exit code %d, got: %d", exitcodes.ExitSuccess, exitCode)
+	}
+	if taskEngine.drainTimeout != time.Minute {
+		t.Errorf("Expected task engine to be drained for %v, got: %v", time.Minute, taskEngine.drainTimeout)
+	}
+}
+
+func TestHandleTerminationWithoutDrainTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	saver := mock_statemanager.NewMockStateManager(ctrl)
+	taskEngine := &drainableTaskEngine{MockTaskEngine: engine.NewMockTaskEngine(ctrl)}
+
+	taskEngine.EXPECT().Disable()
+	saver.EXPECT().ForceSave().Return(nil)
+
+	signalChannel := make(chan os.Signal, 1)
+	signalChannel <- syscall.SIGTERM
+	exitCode := handleTermination(signalChannel, saver, taskEngine, 0, nil)
+	if exitCode != exitcodes.ExitSuccess {
+		t.Errorf("Expected exit code %d, got: %d", exitcodes.ExitSuccess, exitCode)
+	}
+	if taskEngine.drainTimeout != 0 {
+		t.Errorf("Expected task engine to not be drained, got: %v", taskEngine.drainTimeout)
+	}
+}
+
+func TestHandleTerminationFlushesBeforeSaving(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	saver := mock_statemanager.NewMockStateManager(ctrl)
+	taskEngine := &drainableTaskEngine{MockTaskEngine: engine.NewMockTaskEngine(ctrl)}
+
+	flushTimeouts := make(chan time.Duration, 2)
+	flusher := func(timeout time.Duration) error {
+		flushTimeouts <- timeout
+		return nil
+	}
+	failingFlusher := func(timeout time.Duration) error {
+		flushTimeouts <- timeout
+		return errors.New("test error")
+	}
+	gomock.InOrder(
+		taskEngine.EXPECT().Disable().Do(func() {
+			if len(flushTimeouts) != 2 {
+				t.Error("Expected both flushers to be called before the final save")
+			}
+		}),
+		saver.EXPECT().ForceSave().Return(nil),
+	)
+
+	signalChannel := make(chan os.Signal, 1)
+	signalChannel <- syscall.SIGTERM
+	exitCode := handleTermination(signalChannel, saver, taskEngine, 0, []Flusher{flusher, failingFlusher})
+	if exitCode != exitcodes.ExitSuccess {
+		t.Errorf("Expected exit code %d, got: %d", exitcodes.ExitSuccess, exitCode)
+	}
+	for i := 0; i < 2; i++ {
+		if timeout := <-flushTimeouts; timeout != flushTimeout {
+			t.Errorf("Expected flusher to be given %v, got: %v", flushTimeout, timeout)
+		}
+	}
+}
+
+func TestFlushTimesOut(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	called := make(chan struct{}, 1)
+	blockingFlusher := func(timeout time.Duration) error {
+		called <- struct{}{}
+		<-blocked
+		return nil
+	}
+
+	start := time.Now()
+	flush([]Flusher{blockingFlusher}, 10*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected flush to give up after its timeout, took: %v", elapsed)
+	}
+	select {
+	case <-called:
+	default:
+		t.Error("Expected the flusher to be called")
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import "fmt"
+
+// A migration upgrades saved data from one version to the next. It's given the
+// json of each saveable by name, and may add, remove or rewrite saveables
+type migration func(data intermediateSaveableState) error
+
+// migrations upgrade saved data to EcsDataVersion. migrations[i] upgrades data
+// of version i+1 to version i+2, so a migration must be appended whenever
+// EcsDataVersion is incremented
+var migrations = []migration{
+	// 1 -> 2: 'ACSSeqNum' is zero when missing
+	noMigration,
+	// 2 -> 3: 'Protocol' is tcp when missing
+	noMigration,
+	// 3 -> 4: 'DockerConfig' is empty when missing
+	noMigration,
+	// 4 -> 5: 'ImageStates' are empty when missing
+	noMigration,
+}
+
+// noMigration is the migration to a version whose changes are backwards
+// compatible, so data of the previous version can be read as is
+func noMigration(data intermediateSaveableState) error {
+	return nil
+}
+
+// migrate upgrades data of a version to the newest version the migrations
+// lead to, applying them in order
+func migrate(data intermediateSaveableState, version int, migrations []migration) error {
+	// The first version is 1, but data without a version can be read as such
+	if version < 1 {
+		version = 1
+	}
+	for ; version <= len(migrations); version++ {
+		log.Info("Migrating state", "from", version, "to", version+1)
+		err := migrations[version-1](data)
+		if err != nil {
+			return fmt.Errorf("Could not migrate state from version %d to %d: %v", version, version+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationsLeadToEcsDataVersion(t *testing.T) {
+	assert.Equal(t, EcsDataVersion, len(migrations)+1, "A migration must be added for each data version")
+}
+
+// v1Data returns the saveables of the version 1 test data
+func v1Data(t *testing.T) intermediateSaveableState {
+	data, err := ioutil.ReadFile(filepath.Join(".", "testdata", "v1", "1", ecsDataFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var intermediate intermediateState
+	if err := json.Unmarshal(data, &intermediate); err != nil {
+		t.Fatal(err)
+	}
+	return intermediate.Data
+}
+
+// testMigrations renames the cluster saveable in version 2 and rewrites its
+// value in version 3, recording the migrations applied
+func testMigrations(applied *[]int) []migration {
+	return []migration{
+		func(data intermediateSaveableState) error {
+			*applied = append(*applied, 2)
+			data["ClusterName"] = data["Cluster"]
+			delete(data, "Cluster")
+			return nil
+		},
+		func(data intermediateSaveableState) error {
+			*applied = append(*applied, 3)
+			var cluster string
+			if err := json.Unmarshal(data["ClusterName"], &cluster); err != nil {
+				return err
+			}
+			data["ClusterName"], _ = json.Marshal("migrated-" + cluster)
+			return nil
+		},
+	}
+}
+
+func TestMigrateV1DataForward(t *testing.T) {
+	data := v1Data(t)
+	var applied []int
+
+	err := migrate(data, 1, testMigrations(&applied))
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 3}, applied)
+	_, ok := data["Cluster"]
+	assert.False(t, ok, "Expected the cluster to be renamed")
+	assert.Equal(t, `"migrated-test"`, string(data["ClusterName"]))
+	assert.Contains(t, data, "TaskEngine")
+}
+
+func TestMigrateSkipsAppliedMigrations(t *testing.T) {
+	data := intermediateSaveableState{"ClusterName": json.RawMessage(`"test"`)}
+	var applied []int
+
+	err := migrate(data, 2, testMigrations(&applied))
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3}, applied)
+	assert.Equal(t, `"migrated-test"`, string(data["ClusterName"]))
+
+	applied = nil
+	err = migrate(data, 3, testMigrations(&applied))
+	assert.Nil(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestMigrateError(t *testing.T) {
+	var applied []int
+	failing := []migration{
+		func(data intermediateSaveableState) error { return errors.New("bad data") },
+		func(data intermediateSaveableState) error {
+			applied = append(applied, 3)
+			return nil
+		},
+	}
+
+	err := migrate(intermediateSaveableState{}, 1, failing)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "Could not migrate state from version 1 to 2: bad data", err.Error())
+	}
+	assert.Empty(t, applied, "Expected migrations to stop at the first error")
+}
@@ -51,6 +51,16 @@ func (_mr *_MockStateManagerRecorder) ForceSave() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "ForceSave")
 }
 
+func (_m *MockStateManager) LastSaveError() error {
+	ret := _m.ctrl.Call(_m, "LastSaveError")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockStateManagerRecorder) LastSaveError() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LastSaveError")
+}
+
 func (_m *MockStateManager) Load() error {
 	ret := _m.ctrl.Call(_m, "Load")
 	ret0, _ := ret[0].(error)
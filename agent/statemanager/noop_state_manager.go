@@ -37,3 +37,8 @@ func (nsm *NoopStateManager) ForceSave() error {
 func (nsm *NoopStateManager) Load() error {
 	return nil
 }
+
+// LastSaveError returns nil, as saving never fails
+func (nsm *NoopStateManager) LastSaveError() error {
+	return nil
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/logger"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 )
 
 // EcsDataVersion is the current version of saved data. Any backwards or
@@ -42,13 +43,16 @@ import (
 // 3) Add 'Protocol' field to 'portMappings' and 'KnownPortBindings'
 // 4) Add 'DockerConfig' struct
 // 5) Add 'ImageStates' struct as part of ImageManager
+// Data of an older version is upgraded by the migrations in migrations.go,
+// which must be kept in step with this number.
 const EcsDataVersion = 5
 
 // Filename in the ECS_DATADIR
 const ecsDataFile = "ecs_agent_data.json"
 
-// How frequently to flush to disk
-const minSaveInterval = 10 * time.Second
+// saveMaxLatencyIntervals is how many save intervals a change may wait to be
+// saved while other changes keep delaying the save
+const saveMaxLatencyIntervals = 5
 
 var log = logger.ForModule("statemanager")
 
@@ -98,6 +102,9 @@ type platformDependencies interface{}
 type StateManager interface {
 	Saver
 	Load() error
+	// LastSaveError returns the error of the last attempt to save state, or
+	// nil if it succeeded or state hasn't been saved yet
+	LastSaveError() error
 }
 
 type basicStateManager struct {
@@ -105,19 +112,29 @@ type basicStateManager struct {
 
 	state *state // pointers to the data we should save / load into
 
-	saveTimesLock   sync.Mutex // guards save times
-	lastSave        time.Time  //the last time a save completed
-	nextPlannedSave time.Time  //the next time a save is planned
+	saveInterval   time.Duration // how long a save waits for further changes
+	saveMaxLatency time.Duration // the longest a change waits to be saved
+	time           ttime.Time
+
+	saveTimesLock      sync.Mutex  // guards save times and the pending save
+	lastSave           time.Time   // the last time a save completed
+	firstUnsavedChange time.Time   // the time of the first change the pending save is for
+	pendingSave        ttime.Timer // the pending save, or nil if there's none
+	pendingSaveID      int         // identifies the pending save, so one that's replaced doesn't run
 
 	savingLock sync.Mutex // guards marshal, write, move (on Linux), and load (on Windows)
 
+	lastSaveErrLock sync.RWMutex // guards lastSaveErr
+	lastSaveErr     error        // the error of the last save attempt
+
 	platformDependencies platformDependencies // platform-specific dependencies
 }
 
 // NewStateManager constructs a new StateManager which saves data at the
 // location specified in cfg and operates under the given options.
-// The returned StateManager will not save more often than every 10 seconds and
-// will not reliably return errors with Save, but will log them appropriately.
+// The returned StateManager coalesces the changes saved within the save interval
+// of cfg into a single save and will not reliably return errors with Save, but
+// will log them appropriately.
 func NewStateManager(cfg *config.Config, options ...Option) (StateManager, error) {
 	fi, err := os.Stat(cfg.DataDir)
 	if err != nil {
@@ -131,9 +148,16 @@ func NewStateManager(cfg *config.Config, options ...Option) (StateManager, error
 		Data:    make(saveableState),
 		Version: EcsDataVersion,
 	}
+	saveInterval := cfg.StateSaveInterval
+	if saveInterval <= 0 {
+		saveInterval = config.DefaultStateSaveInterval
+	}
 	manager := &basicStateManager{
-		statePath: cfg.DataDir,
-		state:     state,
+		statePath:      cfg.DataDir,
+		state:          state,
+		saveInterval:   saveInterval,
+		saveMaxLatency: saveMaxLatencyIntervals * saveInterval,
+		time:           &ttime.DefaultTime{},
 	}
 
 	for _, option := range options {
@@ -160,41 +184,66 @@ func AddSaveable(name string, saveable Saveable) Option {
 	})
 }
 
-// Save triggers a save to file, though respects a minimum save interval to wait
-// between saves.
+// Save triggers a save to file. If state was saved recently, the save waits
+// for the save interval to pass without further changes, so that changes made in
+// quick succession are saved together, but no change waits longer than the
+// maximum save latency.
 func (manager *basicStateManager) Save() error {
 	manager.saveTimesLock.Lock()
-	defer manager.saveTimesLock.Unlock()
-	if time.Since(manager.lastSave) >= minSaveInterval {
+	now := manager.time.Now()
+	if manager.pendingSave == nil && now.Sub(manager.lastSave) >= manager.saveMaxLatency {
 		// we can just save
-		err := manager.ForceSave()
-		manager.lastSave = time.Now()
-		manager.nextPlannedSave = time.Time{} // re-zero it; assume all pending desires to save are fulfilled
-		return err
-	} else if manager.nextPlannedSave.IsZero() {
-		// No save planned yet, we should plan one.
-		next := manager.lastSave.Add(minSaveInterval)
-		manager.nextPlannedSave = next
-		go func() {
-			time.Sleep(next.Sub(time.Now()))
-			manager.Save()
-		}()
+		manager.saveTimesLock.Unlock()
+		return manager.ForceSave()
+	}
+	defer manager.saveTimesLock.Unlock()
+
+	if manager.pendingSave == nil {
+		manager.firstUnsavedChange = now
+	} else {
+		manager.pendingSave.Stop()
+	}
+	delay := manager.saveInterval
+	if deadline := manager.firstUnsavedChange.Add(manager.saveMaxLatency); now.Add(delay).After(deadline) {
+		delay = deadline.Sub(now)
 	}
-	// else nextPlannedSave wasn't Zero so there's a save planned elsewhere that'll
-	// fulfill this
+	manager.pendingSaveID++
+	id := manager.pendingSaveID
+	manager.pendingSave = manager.time.AfterFunc(delay, func() {
+		manager.savePending(id)
+	})
 	return nil
 }
 
+// savePending saves state unless the pending save was replaced by a later one
+// or state was saved since
+func (manager *basicStateManager) savePending(id int) {
+	manager.saveTimesLock.Lock()
+	pending := manager.pendingSave != nil && manager.pendingSaveID == id
+	manager.saveTimesLock.Unlock()
+	if pending {
+		manager.ForceSave()
+	}
+}
+
 // ForceSave saves the given State to a file. It is an atomic operation on POSIX
 // systems (by Renaming over the target file).
 // This function logs errors at will and does not necessarily expect the caller
 // to handle the error because there's little a caller can do in general other
 // than just keep going.
-// In addition, the StateManager internally buffers save requests in order to
-// only save at most every STATE_SAVE_INTERVAL.
+// Any pending save is cancelled, as it's fulfilled by this one, so calling
+// ForceSave before shutting down flushes changes that were waiting to be saved.
 func (manager *basicStateManager) ForceSave() error {
 	manager.savingLock.Lock()
 	defer manager.savingLock.Unlock()
+	manager.saveTimesLock.Lock()
+	if manager.pendingSave != nil {
+		manager.pendingSave.Stop()
+		manager.pendingSave = nil
+	}
+	manager.lastSave = manager.time.Now()
+	manager.saveTimesLock.Unlock()
+
 	log.Info("Saving state!")
 	s := manager.state
 	s.Version = EcsDataVersion
@@ -202,9 +251,20 @@ func (manager *basicStateManager) ForceSave() error {
 	data, err := json.Marshal(s)
 	if err != nil {
 		log.Error("Error saving state; could not marshal data; this is odd", "err", err)
-		return err
+	} else {
+		err = manager.writeFile(data)
 	}
-	return manager.writeFile(data)
+	manager.lastSaveErrLock.Lock()
+	manager.lastSaveErr = err
+	manager.lastSaveErrLock.Unlock()
+	return err
+}
+
+// LastSaveError returns the error of the last attempt to save state
+func (manager *basicStateManager) LastSaveError() error {
+	manager.lastSaveErrLock.RLock()
+	defer manager.lastSaveErrLock.RUnlock()
+	return manager.lastSaveErr
 }
 
 // Load reads state off the disk from the well-known filepath and loads it into
@@ -213,15 +273,31 @@ func (manager *basicStateManager) Load() error {
 	s := manager.state
 	log.Info("Loading state!")
 	data, err := manager.readFile()
-	if err != nil {
-		log.Error("Error reading existing state file", "err", err)
-		return err
+	if err != nil || (data != nil && !readable(data)) {
+		// The state file exists but can't be read, in which case the state of
+		// the last successful save is kept as a backup. A missing state file
+		// isn't recovered from the backup; removing it resets the agent
+		if err != nil {
+			log.Error("Error reading existing state file", "err", err)
+		} else {
+			log.Crit("State file is corrupt")
+		}
+		backup, backupErr := manager.readBackupFile()
+		if backupErr != nil {
+			log.Error("Error reading backup state file", "err", backupErr)
+		} else if backup != nil {
+			log.Warn("Loading the backup of the last save")
+			data, err = backup, nil
+		}
+		if err != nil {
+			return err
+		}
 	}
 	if data == nil {
 		return nil
 	}
 	// Dry-run to make sure this is a version we can understand
-	err = manager.dryRun(data)
+	version, err := manager.dryRun(data)
 	if err != nil {
 		return err
 	}
@@ -238,6 +314,14 @@ func (manager *basicStateManager) Load() error {
 		log.Debug("Could not unmarshal into intermediate")
 		return err
 	}
+	if intermediate.Data == nil {
+		intermediate.Data = make(intermediateSaveableState)
+	}
+	err = migrate(intermediate.Data, version, migrations)
+	if err != nil {
+		log.Crit("Could not migrate existing state", "err", err)
+		return err
+	}
 
 	for key, rawJSON := range intermediate.Data {
 		actualPointer, ok := manager.state.Data[key]
@@ -256,17 +340,26 @@ func (manager *basicStateManager) Load() error {
 	return nil
 }
 
-func (manager *basicStateManager) dryRun(data []byte) error {
+// readable returns true if the data can be read as saved state
+func readable(data []byte) bool {
+	return json.Unmarshal(data, &versionOnlyState{}) == nil
+}
+
+// dryRun returns the version of the data, or an error if it's a version this
+// agent can't understand
+func (manager *basicStateManager) dryRun(data []byte) (int, error) {
 	// Dry-run to make sure this is a version we can understand
 	tmps := versionOnlyState{}
 	err := json.Unmarshal(data, &tmps)
 	if err != nil {
 		log.Crit("Could not unmarshal existing state; corrupted data?", "err", err, "data", data)
-		return err
+		return 0, err
 	}
 	if tmps.Version > EcsDataVersion {
+		// Data saved by a newer agent may not survive being read and saved
+		// again by this one, so it's left alone
 		strversion := strconv.Itoa(tmps.Version)
-		return errors.New("Unsupported data format: Version " + strversion + " not " + strconv.Itoa(EcsDataVersion))
+		return 0, errors.New("Unsupported data format: Version " + strversion + " was saved by a newer agent; this agent supports versions up to " + strconv.Itoa(EcsDataVersion))
 	}
-	return nil
+	return tmps.Version, nil
 }
@@ -0,0 +1,175 @@
+// +build !windows
+
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveTestManager returns a state manager saving cluster every second, using
+// mockTime, and a function returning whether it saved since last called
+func saveTestManager(t *testing.T, mockTime *mock_ttime.MockTime, cluster *string) (*basicStateManager, func() bool, func()) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.Nil(t, err)
+	cfg := &config.Config{DataDir: tmpDir, StateSaveInterval: time.Second}
+	manager, err := NewStateManager(cfg, AddSaveable("Cluster", cluster))
+	require.Nil(t, err)
+	basicManager := manager.(*basicStateManager)
+	basicManager.time = mockTime
+
+	dataFile := filepath.Join(tmpDir, ecsDataFile)
+	saved := func() bool {
+		_, err := os.Stat(dataFile)
+		os.Remove(dataFile)
+		return err == nil
+	}
+	return basicManager, saved, func() { os.RemoveAll(tmpDir) }
+}
+
+// expectSave expects a save to be planned after delay, returning the planned
+// save
+func expectSave(mockTime *mock_ttime.MockTime, timer *mock_ttime.MockTimer, delay time.Duration) *func() {
+	var save func()
+	mockTime.EXPECT().AfterFunc(delay, gomock.Any()).Do(func(d time.Duration, f func()) {
+		save = f
+	}).Return(timer)
+	return &save
+}
+
+func TestSaveCoalescesChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTime := mock_ttime.NewMockTime(ctrl)
+	timer := mock_ttime.NewMockTimer(ctrl)
+	var cluster string
+	manager, saved, cleanup := saveTestManager(t, mockTime, &cluster)
+	defer cleanup()
+
+	start := time.Now()
+	mockTime.EXPECT().Now().Return(start)
+	require.Nil(t, manager.ForceSave())
+	assert.True(t, saved())
+
+	// Each change within the save interval replaces the planned save
+	var saves []*func()
+	for i := 1; i <= 3; i++ {
+		cluster = "cluster" + strconv.Itoa(i)
+		mockTime.EXPECT().Now().Return(start.Add(time.Duration(i) * 100 * time.Millisecond))
+		if i > 1 {
+			timer.EXPECT().Stop()
+		}
+		saves = append(saves, expectSave(mockTime, timer, time.Second))
+		assert.Nil(t, manager.Save())
+	}
+	assert.False(t, saved(), "Expected no save before the save interval passes")
+
+	(*saves[0])()
+	(*saves[1])()
+	assert.False(t, saved(), "Expected replaced saves not to save")
+
+	mockTime.EXPECT().Now().Return(start.Add(1300 * time.Millisecond))
+	timer.EXPECT().Stop()
+	(*saves[2])()
+	assert.True(t, saved(), "Expected the changes to be saved together")
+}
+
+func TestSaveMaxLatency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTime := mock_ttime.NewMockTime(ctrl)
+	timer := mock_ttime.NewMockTimer(ctrl)
+	var cluster string
+	manager, _, cleanup := saveTestManager(t, mockTime, &cluster)
+	defer cleanup()
+
+	start := time.Now()
+	mockTime.EXPECT().Now().Return(start)
+	require.Nil(t, manager.ForceSave())
+
+	// Changes keep delaying the save until the first change has waited five
+	// save intervals
+	gomock.InOrder(
+		mockTime.EXPECT().Now().Return(start.Add(time.Second)),
+		mockTime.EXPECT().AfterFunc(time.Second, gomock.Any()).Return(timer),
+		mockTime.EXPECT().Now().Return(start.Add(4*time.Second)),
+		timer.EXPECT().Stop(),
+		mockTime.EXPECT().AfterFunc(time.Second, gomock.Any()).Return(timer),
+		mockTime.EXPECT().Now().Return(start.Add(5500*time.Millisecond)),
+		timer.EXPECT().Stop(),
+		mockTime.EXPECT().AfterFunc(500*time.Millisecond, gomock.Any()).Return(timer),
+	)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, manager.Save())
+	}
+}
+
+func TestSaveImmediatelyWhenIdle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTime := mock_ttime.NewMockTime(ctrl)
+	var cluster string
+	manager, saved, cleanup := saveTestManager(t, mockTime, &cluster)
+	defer cleanup()
+
+	// Without changes for five save intervals, there's nothing to coalesce
+	start := time.Now()
+	mockTime.EXPECT().Now().Return(start)
+	require.Nil(t, manager.ForceSave())
+	assert.True(t, saved())
+
+	mockTime.EXPECT().Now().Return(start.Add(5 * time.Second)).Times(2)
+	assert.Nil(t, manager.Save())
+	assert.True(t, saved())
+}
+
+func TestForceSaveFlushesPendingSave(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTime := mock_ttime.NewMockTime(ctrl)
+	timer := mock_ttime.NewMockTimer(ctrl)
+	var cluster string
+	manager, saved, cleanup := saveTestManager(t, mockTime, &cluster)
+	defer cleanup()
+
+	start := time.Now()
+	mockTime.EXPECT().Now().Return(start)
+	require.Nil(t, manager.ForceSave())
+	assert.True(t, saved())
+
+	mockTime.EXPECT().Now().Return(start.Add(100 * time.Millisecond))
+	pendingSave := expectSave(mockTime, timer, time.Second)
+	assert.Nil(t, manager.Save())
+
+	// Shutting down saves the pending changes right away
+	mockTime.EXPECT().Now().Return(start.Add(200 * time.Millisecond))
+	timer.EXPECT().Stop()
+	require.Nil(t, manager.ForceSave())
+	assert.True(t, saved(), "Expected the pending changes to be saved")
+
+	(*pendingSave)()
+	assert.False(t, saved(), "Expected the cancelled save not to save again")
+}
@@ -97,3 +97,22 @@ func TestLoadsV1DataCorrectly(t *testing.T) {
 		t.Fatal("Time was not correct")
 	}
 }
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	cleanup, err := setupWindowsTest(filepath.Join(".", "testdata", "future", "ecs_agent_data.json"))
+	assert.Nil(t, err, "Failed to set up test")
+	defer cleanup()
+	cfg := &config.Config{DataDir: filepath.Join(".", "testdata", "future")}
+
+	var cluster string
+	stateManager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &cluster))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stateManager.Load()
+	if assert.NotNil(t, err, "Expected state saved by a newer agent not to load") {
+		assert.Contains(t, err.Error(), "Version 1000 was saved by a newer agent")
+	}
+	assert.Empty(t, cluster, "Expected no state to be loaded")
+}
@@ -27,26 +27,39 @@ written out correctly relies on behaviors of Linux and the ext* family of
 filesystems.
 
 On each save, the agent creates a new temporary file where it
-writes out the json object.  Once the file is written, it gets renamed to the
-well-known name of the state file.  Under the assumption of Linux + ext*, this
-is an atomic operation; rename is changing the hard link of the well-known file
-to point to the inode of the temporary file.  The original file inode now has no
-links, and is considered free space once the opened file handles to that inode
-are closed.
+writes out the json object.  Once the file is written and synced to disk, the
+well-known name of the backup file is hard linked to the current state file and
+the temporary file gets renamed to the well-known name of the state file.  Under
+the assumption of Linux + ext*, the rename is an atomic operation; rename is
+changing the hard link of the well-known file to point to the inode of the
+temporary file.  The replaced file inode is now only linked by the backup file.
 
 On each load, the agent opens a well-known file name for the state file and
-reads it.
+reads it.  If the state file exists but can't be read, the backup file holds the
+state of the last successful save.  A missing state file means there is no state
+to load, such that removing it resets the agent.
 */
 
+// Filename of the backup of the state file in the ECS_DATADIR
+const ecsDataBackupFile = ecsDataFile + ".bak"
+
 func newPlatformDependencies() platformDependencies {
 	return nil
 }
 
 func (manager *basicStateManager) readFile() ([]byte, error) {
+	return readStateFile(filepath.Join(manager.statePath, ecsDataFile))
+}
+
+func (manager *basicStateManager) readBackupFile() ([]byte, error) {
+	return readStateFile(filepath.Join(manager.statePath, ecsDataBackupFile))
+}
+
+func readStateFile(path string) ([]byte, error) {
 	// Note that even if Save overwrites the file we're looking at here, we
 	// still hold the old inode and should read the old data so no locking is
 	// needed (given Linux and the ext* family of fs at least).
-	file, err := os.Open(filepath.Join(manager.statePath, ecsDataFile))
+	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Happens every first run; not a real error
@@ -54,6 +67,7 @@ func (manager *basicStateManager) readFile() ([]byte, error) {
 		}
 		return nil, err
 	}
+	defer file.Close()
 	return ioutil.ReadAll(file)
 }
 
@@ -65,14 +79,56 @@ func (manager *basicStateManager) writeFile(data []byte) error {
 		log.Error("Error saving state; could not create temp file to save state", "err", err)
 		return err
 	}
-	_, err = tmpfile.Write(data)
+	err = writeAndSync(tmpfile, data)
 	if err != nil {
 		log.Error("Error saving state; could not write to temp file to save state", "err", err)
+		os.Remove(tmpfile.Name())
 		return err
 	}
-	err = os.Rename(tmpfile.Name(), filepath.Join(manager.statePath, ecsDataFile))
+	dataFile := filepath.Join(manager.statePath, ecsDataFile)
+	backupFile := filepath.Join(manager.statePath, ecsDataBackupFile)
+	// Link the backup to the current state file rather than moving it, so that
+	// there is a state file at all times
+	err = os.Remove(backupFile)
+	if err == nil || os.IsNotExist(err) {
+		err = os.Link(dataFile, backupFile)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Error("Error saving state; could not back up data file", "err", err)
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	err = os.Rename(tmpfile.Name(), dataFile)
 	if err != nil {
 		log.Error("Error saving state; could not move to data file", "err", err)
+		return err
+	}
+	// Sync the directory so the renames survive a crash
+	err = syncDir(manager.statePath)
+	if err != nil {
+		log.Warn("Could not sync data directory after saving state", "err", err)
+	}
+	return nil
+}
+
+// writeAndSync writes the data to the file, syncs it to disk and closes it
+func writeAndSync(file *os.File, data []byte) error {
+	_, err := file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
 	}
 	return err
 }
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
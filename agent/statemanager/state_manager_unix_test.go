@@ -19,6 +19,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
 	"github.com/aws/amazon-ecs-agent/agent/config"
@@ -81,6 +82,56 @@ func TestStateManager(t *testing.T) {
 	assert.Equal(t, "test-arn", tasks[0].Arn, "Wrong arn")
 }
 
+func TestStateManagerRestoresContainerTimestamps(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	cfg := &config.Config{DataDir: tmpDir}
+
+	taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewDockerTaskEngineState())
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", taskEngine))
+	require.Nil(t, err)
+
+	createdAt := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	startedAt := createdAt.Add(3 * time.Second)
+	container := &api.Container{Name: "web"}
+	container.SetCreatedAt(createdAt)
+	container.SetStartedAt(startedAt)
+	testTask := &api.Task{Arn: "test-arn", Containers: []*api.Container{container}}
+	taskEngine.(*engine.DockerTaskEngine).State().AddTask(testTask)
+	require.Nil(t, manager.Save(), "Error saving state")
+
+	loadedTaskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewDockerTaskEngineState())
+	manager, err = statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", &loadedTaskEngine))
+	require.Nil(t, err)
+	require.Nil(t, manager.Load(), "Error loading state")
+
+	tasks, err := loadedTaskEngine.ListTasks()
+	require.Nil(t, err)
+	require.Len(t, tasks, 1)
+	require.Len(t, tasks[0].Containers, 1)
+	assert.True(t, createdAt.Equal(tasks[0].Containers[0].GetCreatedAt()), "Wrong creation time")
+	assert.True(t, startedAt.Equal(tasks[0].Containers[0].GetStartedAt()), "Wrong start time")
+}
+
+func TestStateManagerLastSaveError(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	manager, err := statemanager.NewStateManager(&config.Config{DataDir: tmpDir})
+	require.Nil(t, err)
+	assert.Nil(t, manager.LastSaveError(), "Expected no error before saving")
+
+	// Saving fails once the data directory is gone
+	require.Nil(t, os.RemoveAll(tmpDir))
+	assert.NotNil(t, manager.ForceSave())
+	assert.NotNil(t, manager.LastSaveError(), "Expected the failed save to be recorded")
+
+	require.Nil(t, os.Mkdir(tmpDir, 0700))
+	assert.Nil(t, manager.ForceSave())
+	assert.Nil(t, manager.LastSaveError(), "Expected the successful save to clear the error")
+}
+
 func assertFileMode(t *testing.T, path string) {
 	info, err := os.Stat(path)
 	assert.Nil(t, err)
@@ -88,3 +139,71 @@ func assertFileMode(t *testing.T, path string) {
 	mode := info.Mode()
 	assert.Equal(t, os.FileMode(0600), mode, "Wrong file mode")
 }
+
+// saveCluster saves each cluster name in turn, leaving the last as the state
+// file and the one before it as the backup
+func saveCluster(t *testing.T, cfg *config.Config, clusters ...string) {
+	var cluster string
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &cluster))
+	require.Nil(t, err)
+	for _, cluster = range clusters {
+		require.Nil(t, manager.ForceSave())
+	}
+}
+
+func loadCluster(t *testing.T, cfg *config.Config) (string, error) {
+	var cluster string
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &cluster))
+	require.Nil(t, err)
+	err = manager.Load()
+	return cluster, err
+}
+
+func TestStateManagerLoadsBackupOfTruncatedFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	cfg := &config.Config{DataDir: tmpDir}
+	saveCluster(t, cfg, "first", "second")
+
+	cluster, err := loadCluster(t, cfg)
+	require.Nil(t, err)
+	assert.Equal(t, "second", cluster)
+	assertFileMode(t, filepath.Join(tmpDir, "ecs_agent_data.json.bak"))
+
+	// Simulate the agent being killed while writing the state file
+	dataFile := filepath.Join(tmpDir, "ecs_agent_data.json")
+	info, err := os.Stat(dataFile)
+	require.Nil(t, err)
+	require.Nil(t, os.Truncate(dataFile, info.Size()/2))
+
+	cluster, err = loadCluster(t, cfg)
+	assert.Nil(t, err, "Expected the backup to be loaded")
+	assert.Equal(t, "first", cluster)
+}
+
+func TestStateManagerRemovedFileIsNotRecovered(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	cfg := &config.Config{DataDir: tmpDir}
+	saveCluster(t, cfg, "first", "second")
+
+	// Removing the state file resets the agent, even with a backup around
+	require.Nil(t, os.Remove(filepath.Join(tmpDir, "ecs_agent_data.json")))
+
+	cluster, err := loadCluster(t, cfg)
+	assert.Nil(t, err)
+	assert.Empty(t, cluster, "Expected the backup not to be loaded")
+}
+
+func TestStateManagerCorruptFileWithoutBackup(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	cfg := &config.Config{DataDir: tmpDir}
+	require.Nil(t, ioutil.WriteFile(filepath.Join(tmpDir, "ecs_agent_data.json"), []byte(`{"Data":{"Cluster":"fir`), 0600))
+
+	_, err = loadCluster(t, cfg)
+	assert.NotNil(t, err, "Expected a corrupt state file without a backup not to load")
+}
@@ -86,6 +86,12 @@ func (manager *basicStateManager) readFile() ([]byte, error) {
 	return deps.fs.ReadAll(file)
 }
 
+// readBackupFile returns no backup; the registry points at the new file only
+// once it's flushed to disk, so the state file is never partially written
+func (manager *basicStateManager) readBackupFile() ([]byte, error) {
+	return nil, nil
+}
+
 func (manager *basicStateManager) getPath() (string, error) {
 	deps := manager.platformDependencies.(windowsDependencies)
 	key, err := deps.registry.OpenKey(ecsDataFileRootKey, ecsDataFileKeyPath, registry.READ)
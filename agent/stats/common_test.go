@@ -172,8 +172,8 @@ func validateMetricsMetadata(metadata *ecstcs.MetricsMetadata) error {
 
 func createFakeContainerStats() []*ContainerStats {
 	return []*ContainerStats{
-		&ContainerStats{22400432, 1839104, parseNanoTime("2015-02-12T21:22:05.131117533Z")},
-		&ContainerStats{116499979, 3649536, parseNanoTime("2015-02-12T21:22:05.232291187Z")},
+		&ContainerStats{22400432, 1839104, parseNanoTime("2015-02-12T21:22:05.131117533Z"), nil, nil, nil},
+		&ContainerStats{116499979, 3649536, parseNanoTime("2015-02-12T21:22:05.232291187Z"), nil, nil, nil},
 	}
 }
 
@@ -14,11 +14,15 @@
 package stats
 
 import (
+	"errors"
 	"time"
 
 	ecsengine "github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/stats/resolver"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
 	"golang.org/x/net/context"
 )
 
@@ -31,22 +35,27 @@ const (
 	ContainerStatsBufferLength = 120
 )
 
-func newStatsContainer(dockerID string, client ecsengine.DockerClient, resolver resolver.ContainerMetadataResolver) *StatsContainer {
+func newStatsContainer(dockerID string, client ecsengine.DockerClient, resolver resolver.ContainerMetadataResolver, pollInterval time.Duration) *StatsContainer {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &StatsContainer{
 		containerMetadata: &ContainerMetadata{
 			DockerID: dockerID,
 		},
-		ctx:      ctx,
-		cancel:   cancel,
-		client:   client,
-		resolver: resolver,
+		ctx:          ctx,
+		cancel:       cancel,
+		client:       client,
+		resolver:     resolver,
+		pollInterval: pollInterval,
+		time:         &ttime.DefaultTime{},
 	}
 }
 
 func (container *StatsContainer) StartStatsCollection() {
-	// Create the queue to store utilization data from docker stats
-	container.statsQueue = NewQueue(ContainerStatsBufferLength)
+	// Create the queue to store utilization data from docker stats, unless
+	// one was restored from saved state
+	if container.statsQueue == nil {
+		container.statsQueue = NewQueue(ContainerStatsBufferLength)
+	}
 	go container.collect()
 }
 
@@ -62,7 +71,12 @@ func (container *StatsContainer) collect() {
 			seelog.Debugf("Stopping stats collection for container %s", dockerID)
 			return
 		default:
-			err := container.processStatsStream()
+			var err error
+			if container.pollInterval > 0 {
+				err = container.pollStats()
+			} else {
+				err = container.processStatsStream()
+			}
 			if err != nil {
 				// Currenlty, the only error that we get here is if go-dockerclient is unable
 				// to decode the stats payload properly. Other errors such as
@@ -82,7 +96,7 @@ func (container *StatsContainer) collect() {
 				// docker task engine. If the docker task engine has already removed
 				// the container from its state, there's no point in stats engine tracking the
 				// container. So, clean-up anyway.
-				seelog.Warnf("Error determining if the container %s is terminal, stopping stats collection: %v", dockerID, err)
+				logger.Warn("Error determining if the container is terminal, stopping stats collection", logger.Fields{"container": dockerID, "err": err})
 				container.StopStatsCollection()
 			} else if terminal {
 				seelog.Infof("Container %s is terminal, stopping stats collection", dockerID)
@@ -100,16 +114,75 @@ func (container *StatsContainer) processStatsStream() error {
 		return err
 	}
 	for rawStat := range dockerStats {
-		stat, err := dockerStatsToContainerStats(rawStat)
-		if err == nil {
-			container.statsQueue.Add(stat)
-		} else {
-			seelog.Warnf("Error converting stats for container %s: %v", dockerID, err)
+		container.addStats(rawStat)
+	}
+	return nil
+}
+
+// pollStats adds a sample of the stats of the container every poll interval,
+// until docker stops returning stats for the container, such as once it stops
+func (container *StatsContainer) pollStats() error {
+	for {
+		err := container.sampleStats()
+		if err != nil {
+			return err
 		}
+		select {
+		case <-container.ctx.Done():
+			return nil
+		case <-container.time.After(container.pollInterval):
+		}
+	}
+}
+
+// sampleStats adds the next stats docker reports for the container and closes
+// the stats stream
+func (container *StatsContainer) sampleStats() error {
+	dockerID := container.containerMetadata.DockerID
+	seelog.Debugf("Polling stats for container %s", dockerID)
+	ctx, cancel := context.WithCancel(container.ctx)
+	dockerStats, err := container.client.Stats(dockerID, ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	rawStat, ok := <-dockerStats
+	cancel()
+	// The docker client blocks sending stats read before the stream was
+	// closed until they're received
+	for range dockerStats {
 	}
+	if !ok {
+		return errors.New("stats stream closed before reporting stats")
+	}
+	container.addStats(rawStat)
 	return nil
 }
 
+func (container *StatsContainer) addStats(rawStat *docker.Stats) {
+	stat, err := dockerStatsToContainerStats(rawStat)
+	if err != nil {
+		logger.Warn("Error converting stats", logger.Fields{"container": container.containerMetadata.DockerID, "err": err})
+		return
+	}
+	container.statsQueue.Add(stat)
+	container.setLastStats(rawStat)
+}
+
+// LastStats returns the most recent stats reported by docker for the
+// container, or nil if none have been collected yet
+func (container *StatsContainer) LastStats() *docker.Stats {
+	container.lastStatsLock.RLock()
+	defer container.lastStatsLock.RUnlock()
+	return container.lastStats
+}
+
+func (container *StatsContainer) setLastStats(stats *docker.Stats) {
+	container.lastStatsLock.Lock()
+	defer container.lastStatsLock.Unlock()
+	container.lastStats = stats
+}
+
 func (container *StatsContainer) terminal() (bool, error) {
 	dockerContainer, err := container.resolver.ResolveContainer(container.containerMetadata.DockerID)
 	if err != nil {
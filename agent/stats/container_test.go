@@ -24,6 +24,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/api"
 	ecsengine "github.com/aws/amazon-ecs-agent/agent/engine"
 	mock_resolver "github.com/aws/amazon-ecs-agent/agent/stats/resolver/mock"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
 	"golang.org/x/net/context"
@@ -202,3 +203,84 @@ func TestContainerStatsCollectionStopsIfContainerIsTerminal(t *testing.T) {
 	case <-ctx.Done():
 	}
 }
+
+// polledStats returns a closed stats channel holding a single sample
+func polledStats(read time.Time) chan *docker.Stats {
+	dockerStat := &docker.Stats{Read: read}
+	dockerStat.CPUStats.CPUUsage.PercpuUsage = []uint64{100}
+	dockerStat.CPUStats.CPUUsage.TotalUsage = 100
+	dockerStat.MemoryStats.Usage = 1024
+	stats := make(chan *docker.Stats, 1)
+	stats <- dockerStat
+	close(stats)
+	return stats
+}
+
+func TestContainerStatsPolling(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDockerClient := ecsengine.NewMockDockerClient(ctrl)
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	mockTime := mock_ttime.NewMockTime(ctrl)
+
+	dockerID := "container1"
+	pollInterval := 15 * time.Second
+	container := newStatsContainer(dockerID, mockDockerClient, resolver, pollInterval)
+	container.time = mockTime
+
+	// Stats are sampled once when collection starts and once every time the
+	// configured interval elapses
+	tick := make(chan time.Time, 1)
+	polled := make(chan struct{})
+	now := time.Now()
+	gomock.InOrder(
+		mockDockerClient.EXPECT().Stats(dockerID, gomock.Any()).Return(polledStats(now), nil),
+		mockTime.EXPECT().After(pollInterval).Return(tick),
+		mockDockerClient.EXPECT().Stats(dockerID, gomock.Any()).Return(polledStats(now.Add(pollInterval)), nil),
+		mockTime.EXPECT().After(pollInterval).Do(func(time.Duration) { close(polled) }).Return(make(chan time.Time)),
+	)
+	resolver.EXPECT().ResolveContainer(dockerID).Return(&api.DockerContainer{Container: &api.Container{KnownStatus: api.ContainerRunning}}, nil).AnyTimes()
+
+	container.StartStatsCollection()
+	tick <- now.Add(pollInterval)
+	<-polled
+	container.StopStatsCollection()
+
+	if lastStats := container.LastStats(); lastStats == nil || !lastStats.Read.Equal(now.Add(pollInterval)) {
+		t.Errorf("Expected the second sample to be the last stats, got: %v", lastStats)
+	}
+	memStatsSet, err := container.statsQueue.GetMemoryStatsSet()
+	if err != nil {
+		t.Fatal("Error gettting memory stats set:", err)
+	}
+	if *memStatsSet.SampleCount != 2 {
+		t.Errorf("Expected 2 samples, got: %d", *memStatsSet.SampleCount)
+	}
+}
+
+func TestContainerStatsPollingStreamClosed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDockerClient := ecsengine.NewMockDockerClient(ctrl)
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	mockTime := mock_ttime.NewMockTime(ctrl)
+
+	dockerID := "container1"
+	container := newStatsContainer(dockerID, mockDockerClient, resolver, 15*time.Second)
+	container.time = mockTime
+
+	// A stream closed without stats, such as for a stopped container, ends
+	// polling until the container is checked; the mock time fails the test if
+	// polling waits for the interval
+	closedChan := make(chan *docker.Stats)
+	close(closedChan)
+	stopped := make(chan struct{})
+	gomock.InOrder(
+		mockDockerClient.EXPECT().Stats(dockerID, gomock.Any()).Return(closedChan, nil),
+		resolver.EXPECT().ResolveContainer(dockerID).Do(func(string) { close(stopped) }).Return(&api.DockerContainer{Container: &api.Container{KnownStatus: api.ContainerStopped}}, nil),
+	)
+
+	container.StartStatsCollection()
+	<-stopped
+	<-container.ctx.Done()
+}
@@ -0,0 +1,59 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// maxDimensionLength is the longest name or value of a dimension CloudWatch
+// accepts
+const maxDimensionLength = 255
+
+// metricDimensions returns the dimensions promoted from the docker labels of
+// a container, in the order of dimensionLabels. Labels the container doesn't
+// have, or that have an empty value, are skipped
+func metricDimensions(labels map[string]string, dimensionLabels []string) []*ecstcs.Dimension {
+	var dimensions []*ecstcs.Dimension
+	for _, label := range dimensionLabels {
+		value := sanitizeDimension(labels[label])
+		if value == "" {
+			continue
+		}
+		dimensions = append(dimensions, &ecstcs.Dimension{
+			Name:  aws.String(sanitizeDimension(label)),
+			Value: aws.String(value),
+		})
+	}
+	return dimensions
+}
+
+// sanitizeDimension replaces the characters of a dimension name or value that
+// CloudWatch doesn't accept, anything other than printable ASCII, with '_' and
+// truncates it to the longest length CloudWatch accepts
+func sanitizeDimension(s string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if len(sanitized) > maxDimensionLength {
+		sanitized = sanitized[:maxDimensionLength]
+	}
+	return sanitized
+}
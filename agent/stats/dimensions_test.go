@@ -0,0 +1,60 @@
+//+build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestMetricDimensions(t *testing.T) {
+	labels := map[string]string{
+		"app":   "foo",
+		"team":  "",
+		"stage": "prod",
+		"other": "bar",
+	}
+	dimensions := metricDimensions(labels, []string{"stage", "app", "team", "missing"})
+	if len(dimensions) != 2 {
+		t.Fatalf("Expected 2 dimensions, got: %v", dimensions)
+	}
+	expected := [][2]string{{"stage", "prod"}, {"app", "foo"}}
+	for i, dimension := range dimensions {
+		name, value := aws.StringValue(dimension.Name), aws.StringValue(dimension.Value)
+		if name != expected[i][0] || value != expected[i][1] {
+			t.Errorf("Unexpected dimension %d: %s=%s, expected %s=%s", i, name, value, expected[i][0], expected[i][1])
+		}
+	}
+}
+
+func TestSanitizeDimension(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{"foo-bar_1.0/x:y", "foo-bar_1.0/x:y"},
+		{" padded ", "padded"},
+		{"tab\tnew\nline", "tab_new_line"},
+		{"café", "caf_"},
+		{strings.Repeat("a", 300), strings.Repeat("a", 255)},
+	}
+	for _, tc := range testCases {
+		if sanitized := sanitizeDimension(tc.value); sanitized != tc.expected {
+			t.Errorf("Expected %q to be sanitized to %q, got: %q", tc.value, tc.expected, sanitized)
+		}
+	}
+}
@@ -21,12 +21,14 @@ import (
 	"time"
 
 	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
 	"github.com/pborman/uuid"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	ecsengine "github.com/aws/amazon-ecs-agent/agent/engine"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/stats/resolver"
 	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
 	"github.com/aws/aws-sdk-go/aws"
@@ -62,6 +64,18 @@ type DockerStatsEngine struct {
 	tasksToContainers map[string]map[string]*StatsContainer
 	// tasksToDefinitions maps task arns to task definiton name and family metadata objects.
 	tasksToDefinitions map[string]*taskDefinition
+	// savedQueues maps docker ids to usage stats loaded from saved state, for
+	// containers that haven't been added yet
+	savedQueues map[string]*Queue
+	// outOfMemoryKills counts the containers that stopped because they were
+	// killed for their memory usage
+	outOfMemoryKills uint64
+	// pollInterval is the time between polls of the stats of containers, or
+	// zero if their stats are streamed
+	pollInterval time.Duration
+	// dimensionLabels are the docker labels of containers published as
+	// dimensions of their metrics
+	dimensionLabels []string
 }
 
 // dockerStatsEngine is a singleton object of DockerStatsEngine.
@@ -105,6 +119,10 @@ func NewDockerStatsEngine(cfg *config.Config, client ecsengine.DockerClient, con
 			tasksToDefinitions:         make(map[string]*taskDefinition),
 			containerChangeEventStream: containerChangeEventStream,
 		}
+		dockerStatsEngine.dimensionLabels = cfg.MetricsDimensionLabels
+		if cfg.PollMetrics {
+			dockerStatsEngine.pollInterval = cfg.PollingMetricsWaitDuration
+		}
 	}
 
 	return dockerStatsEngine
@@ -182,10 +200,60 @@ func (engine *DockerStatsEngine) addExistingContainers() error {
 	for _, containerID := range listContainersResponse.DockerIDs {
 		engine.addContainer(containerID)
 	}
+	// Any saved stats that weren't picked up belong to containers that are
+	// gone now
+	engine.discardSavedQueues()
 
 	return nil
 }
 
+// ContainerDockerStats returns the most recent docker stats collected for the
+// container. The boolean is false if the container isn't being watched or no
+// stats have been collected for it yet
+func (engine *DockerStatsEngine) ContainerDockerStats(dockerID string) (*docker.Stats, bool) {
+	engine.containersLock.RLock()
+	defer engine.containersLock.RUnlock()
+
+	for _, containers := range engine.tasksToContainers {
+		container, ok := containers[dockerID]
+		if !ok {
+			continue
+		}
+		stats := container.LastStats()
+		return stats, stats != nil
+	}
+	return nil, false
+}
+
+// TaskDockerStats returns the most recent docker stats collected for each
+// container in the task, keyed by docker id. Containers without collected
+// stats are left out. The boolean is false if the task isn't being watched
+func (engine *DockerStatsEngine) TaskDockerStats(taskArn string) (map[string]*docker.Stats, bool) {
+	engine.containersLock.RLock()
+	defer engine.containersLock.RUnlock()
+
+	containers, ok := engine.tasksToContainers[taskArn]
+	if !ok {
+		return nil, false
+	}
+	taskStats := make(map[string]*docker.Stats)
+	for dockerID, container := range containers {
+		if stats := container.LastStats(); stats != nil {
+			taskStats[dockerID] = stats
+		}
+	}
+	return taskStats, true
+}
+
+// OutOfMemoryKills returns the number of containers that stopped because they
+// were killed for their memory usage since the agent started
+func (engine *DockerStatsEngine) OutOfMemoryKills() uint64 {
+	engine.containersLock.RLock()
+	defer engine.containersLock.RUnlock()
+
+	return engine.outOfMemoryKills
+}
+
 // GetInstanceMetrics gets all task metrics and instance metadata from stats engine.
 func (engine *DockerStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs.TaskMetric, error) {
 	var taskMetrics []*ecstcs.TaskMetric
@@ -260,6 +328,12 @@ func (engine *DockerStatsEngine) handleDockerEvents(events ...interface{}) error
 		case api.ContainerRunning:
 			engine.addContainer(dockerContainerChangeEvent.DockerID)
 		case api.ContainerStopped:
+			if dockerContainerChangeEvent.Error != nil && dockerContainerChangeEvent.Error.ErrorName() == (ecsengine.OutOfMemoryError{}).ErrorName() {
+				logger.Info("Container killed due to memory usage", logger.Fields{"container": dockerContainerChangeEvent.DockerID})
+				engine.containersLock.Lock()
+				engine.outOfMemoryKills++
+				engine.containersLock.Unlock()
+			}
 			engine.removeContainer(dockerContainerChangeEvent.DockerID)
 		default:
 			seelog.Debugf("Ignoring event for container, id: %s, status: %d", dockerContainerChangeEvent.DockerID, dockerContainerChangeEvent.Status)
@@ -313,13 +387,36 @@ func (engine *DockerStatsEngine) addContainer(dockerID string) {
 		engine.tasksToContainers[task.Arn] = make(map[string]*StatsContainer)
 	}
 
-	seelog.Debugf("Adding container to stats watch list, id: %s, task: %s", dockerID, task.Arn)
-	container := newStatsContainer(dockerID, engine.client, engine.resolver)
+	logger.Debug("Adding container to stats watch list", logger.Fields{"container": dockerID, "task": task.Arn})
+	container := newStatsContainer(dockerID, engine.client, engine.resolver, engine.pollInterval)
+	if queue, ok := engine.takeSavedQueue(dockerID); ok {
+		seelog.Debugf("Resuming stats collection from saved state, id: %s", dockerID)
+		container.statsQueue = queue
+	}
+	if len(engine.dimensionLabels) > 0 {
+		container.dimensions = engine.containerDimensions(task, dockerID)
+	}
 	engine.tasksToContainers[task.Arn][dockerID] = container
 	engine.tasksToDefinitions[task.Arn] = &taskDefinition{family: task.Family, version: task.Version}
 	container.StartStatsCollection()
 }
 
+// containerDimensions returns the dimensions promoted from the docker labels
+// the task definition sets on the container
+func (engine *DockerStatsEngine) containerDimensions(task *api.Task, dockerID string) []*ecstcs.Dimension {
+	dockerContainer, err := engine.resolver.ResolveContainer(dockerID)
+	if err != nil {
+		seelog.Debugf("Could not resolve container for metrics dimensions, err: %v, id: %s", err, dockerID)
+		return nil
+	}
+	config, configErr := task.DockerConfig(dockerContainer.Container)
+	if configErr != nil {
+		seelog.Debugf("Could not get docker labels for metrics dimensions, err: %v, id: %s", configErr, dockerID)
+		return nil
+	}
+	return metricDimensions(config.Labels, engine.dimensionLabels)
+}
+
 // removeContainer deletes the container from the map of containers being watched.
 // It also stops the periodic usage data collection for the container.
 func (engine *DockerStatsEngine) removeContainer(dockerID string) {
@@ -389,32 +486,56 @@ func (engine *DockerStatsEngine) getContainerMetricsForTask(taskArn string) ([]*
 			// docker task engine. If the docker task engine has already removed
 			// the container from its state, there's no point in stats engine tracking the
 			// container. So, clean-up anyway.
-			seelog.Warnf("Error determining if the container %s is terminal, cleaning up and skipping", dockerID, err)
+			logger.Warn("Error determining if the container is terminal, cleaning up and skipping", logger.Fields{"container": dockerID, "err": err})
 			engine.doRemoveContainer(container, taskArn)
 			continue
 		} else if terminal {
 			// Container is in knonwn terminal state. Stop collection metrics.
-			seelog.Infof("Container %s is terminal, cleaning up and skipping", dockerID)
+			logger.Info("Container is terminal, cleaning up and skipping", logger.Fields{"container": dockerID})
 			engine.doRemoveContainer(container, taskArn)
 			continue
 		}
 		// Container is not terminal. Get CPU stats set.
 		cpuStatsSet, err := container.statsQueue.GetCPUStatsSet()
 		if err != nil {
-			seelog.Warnf("Error getting cpu stats, err: %v, container: %v", err, dockerID)
+			logger.Warn("Error getting cpu stats", logger.Fields{"container": dockerID, "err": err})
 			continue
 		}
 
 		// Get memory stats set.
 		memoryStatsSet, err := container.statsQueue.GetMemoryStatsSet()
 		if err != nil {
-			seelog.Warnf("Error getting memory stats, err: %v, container: %v", err, dockerID)
+			logger.Warn("Error getting memory stats", logger.Fields{"container": dockerID, "err": err})
 			continue
 		}
 
+		// Not every storage driver accounts for block IO, so containers
+		// without storage stats are still reported
+		storageStatsSet, err := container.statsQueue.GetStorageStatsSet()
+		if err != nil {
+			seelog.Debugf("No storage stats for container %s: %v", dockerID, err)
+		}
+
+		// Containers without network interfaces of their own, such as those
+		// in the host network mode, have no network stats
+		networkStatsSets, err := container.statsQueue.GetNetworkStatsSets()
+		if err != nil {
+			seelog.Debugf("No network stats for container %s: %v", dockerID, err)
+		}
+
+		// Containers whose cpu has no quota are never throttled
+		throttlingStatsSet, err := container.statsQueue.GetThrottlingStatsSet()
+		if err != nil {
+			seelog.Debugf("No cpu throttling stats for container %s: %v", dockerID, err)
+		}
+
 		containerMetrics = append(containerMetrics, &ecstcs.ContainerMetric{
-			CpuStatsSet:    cpuStatsSet,
-			MemoryStatsSet: memoryStatsSet,
+			CpuStatsSet:        cpuStatsSet,
+			Dimensions:         container.dimensions,
+			MemoryStatsSet:     memoryStatsSet,
+			NetworkStatsSets:   networkStatsSets,
+			StorageStatsSet:    storageStatsSet,
+			ThrottlingStatsSet: throttlingStatsSet,
 		})
 
 	}
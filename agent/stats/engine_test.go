@@ -176,8 +176,8 @@ func TestStatsEngineMetadataInStatsSets(t *testing.T) {
 	engine.containerInstanceArn = defaultContainerInstance
 	engine.addContainer("c1")
 	containerStats := []*ContainerStats{
-		&ContainerStats{22400432, 1839104, parseNanoTime("2015-02-12T21:22:05.131117533Z")},
-		&ContainerStats{116499979, 3649536, parseNanoTime("2015-02-12T21:22:05.232291187Z")},
+		&ContainerStats{22400432, 1839104, parseNanoTime("2015-02-12T21:22:05.131117533Z"), nil, nil, nil},
+		&ContainerStats{116499979, 3649536, parseNanoTime("2015-02-12T21:22:05.232291187Z"), nil, nil, nil},
 	}
 	containers, _ := engine.tasksToContainers["t1"]
 	for _, statsContainer := range containers {
@@ -211,6 +211,44 @@ func TestStatsEngineMetadataInStatsSets(t *testing.T) {
 	}
 }
 
+func TestStatsEngineDimensionsInStatsSets(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(mockCtrl)
+	t1 := &api.Task{Arn: "t1", Family: "f1"}
+	dockerConfig := `{"Labels":{"app":"foo\tbar","team":"metrics","other":"ignored"}}`
+	resolver.EXPECT().ResolveTask("c1").AnyTimes().Return(t1, nil)
+	resolver.EXPECT().ResolveContainer("c1").AnyTimes().Return(&api.DockerContainer{
+		Container: &api.Container{DockerConfig: api.DockerConfig{Config: &dockerConfig}},
+	}, nil)
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineDimensionsInStatsSets"))
+	engine.resolver = resolver
+	engine.dimensionLabels = []string{"app", "team"}
+	defer func() { engine.dimensionLabels = nil }()
+	engine.addContainer("c1")
+	defer engine.removeContainer("c1")
+	for _, statsContainer := range engine.tasksToContainers["t1"] {
+		statsContainer.statsQueue.Add(&ContainerStats{22400432, 1839104, parseNanoTime("2015-02-12T21:22:05.131117533Z"), nil, nil, nil})
+		statsContainer.statsQueue.Add(&ContainerStats{116499979, 3649536, parseNanoTime("2015-02-12T21:22:05.232291187Z"), nil, nil, nil})
+	}
+
+	_, taskMetrics, err := engine.GetInstanceMetrics()
+	if err != nil {
+		t.Fatalf("Error gettting instance metrics: %v", err)
+	}
+	if len(taskMetrics) != 1 || len(taskMetrics[0].ContainerMetrics) != 1 {
+		t.Fatalf("Unexpected task metrics: %v", taskMetrics)
+	}
+	dimensions := taskMetrics[0].ContainerMetrics[0].Dimensions
+	if len(dimensions) != 2 {
+		t.Fatalf("Expected 2 dimensions, got: %v", dimensions)
+	}
+	if *dimensions[0].Name != "app" || *dimensions[0].Value != "foo_bar" || *dimensions[1].Name != "team" || *dimensions[1].Value != "metrics" {
+		t.Errorf("Unexpected dimensions: %v", dimensions)
+	}
+}
+
 func TestStatsEngineInvalidTaskEngine(t *testing.T) {
 	statsEngine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineInvalidTaskEngine"))
 	taskEngine := &MockTaskEngine{}
@@ -250,3 +288,71 @@ func TestStatsEngineTerminalTask(t *testing.T) {
 		t.Fatalf("Error validating metadata: %v", err)
 	}
 }
+
+func TestStatsEngineContainerDockerStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	mockDockerClient := ecsengine.NewMockDockerClient(ctrl)
+	t1 := &api.Task{Arn: "t1", Family: "f1"}
+	resolver.EXPECT().ResolveTask("c1").AnyTimes().Return(t1, nil)
+	resolver.EXPECT().ResolveContainer(gomock.Any()).AnyTimes().Return(&api.DockerContainer{
+		Container: &api.Container{},
+	}, nil)
+	mockStatsChannel := make(chan *docker.Stats)
+	defer close(mockStatsChannel)
+	mockDockerClient.EXPECT().Stats(gomock.Any(), gomock.Any()).Return(mockStatsChannel, nil).AnyTimes()
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineContainerDockerStats"))
+	engine.resolver = resolver
+	engine.client = mockDockerClient
+	defer engine.removeAll()
+
+	engine.addContainer("c1")
+	if _, ok := engine.ContainerDockerStats("c1"); ok {
+		t.Error("Expected no stats before any are collected")
+	}
+	taskStats, ok := engine.TaskDockerStats("t1")
+	if !ok || len(taskStats) != 0 {
+		t.Errorf("Expected empty stats for watched task, got: %v, %v", taskStats, ok)
+	}
+	if _, ok := engine.TaskDockerStats("t2"); ok {
+		t.Error("Expected no stats for unknown task")
+	}
+
+	dockerStats := &docker.Stats{Networks: map[string]docker.NetworkStats{"eth0": docker.NetworkStats{RxBytes: 1}}}
+	engine.tasksToContainers["t1"]["c1"].setLastStats(dockerStats)
+	stats, ok := engine.ContainerDockerStats("c1")
+	if !ok || stats != dockerStats {
+		t.Errorf("Expected collected stats, got: %v, %v", stats, ok)
+	}
+	taskStats, _ = engine.TaskDockerStats("t1")
+	if taskStats["c1"] != dockerStats {
+		t.Errorf("Expected collected stats for task, got: %v", taskStats)
+	}
+}
+
+func TestStatsEngineCountsOutOfMemoryKills(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	resolver.EXPECT().ResolveTask(gomock.Any()).AnyTimes().Return(nil, fmt.Errorf("unmapped container"))
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineCountsOutOfMemoryKills"))
+	engine.resolver = resolver
+	defer engine.removeAll()
+	before := engine.OutOfMemoryKills()
+
+	err := engine.handleDockerEvents(
+		ecsengine.DockerContainerChangeEvent{Status: api.ContainerStopped, DockerContainerMetadata: ecsengine.DockerContainerMetadata{DockerID: "oom", Error: ecsengine.OutOfMemoryError{}}},
+		ecsengine.DockerContainerChangeEvent{Status: api.ContainerStopped, DockerContainerMetadata: ecsengine.DockerContainerMetadata{DockerID: "exited"}},
+		ecsengine.DockerContainerChangeEvent{Status: api.ContainerRunning, DockerContainerMetadata: ecsengine.DockerContainerMetadata{DockerID: "running", Error: ecsengine.OutOfMemoryError{}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kills := engine.OutOfMemoryKills() - before; kills != 1 {
+		t.Errorf("Expected 1 out of memory kill, got: %d", kills)
+	}
+}
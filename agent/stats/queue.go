@@ -16,9 +16,11 @@ package stats
 import (
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 
 	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/cihub/seelog"
 )
 
@@ -61,10 +63,16 @@ func (queue *Queue) Add(rawStat *ContainerStats) {
 		MemoryUsageInMegs: uint32(rawStat.memoryUsage / BytesInMiB),
 		Timestamp:         rawStat.timestamp,
 		cpuUsage:          rawStat.cpuUsage,
+		storageUsage:      rawStat.storageUsage,
+		networkUsage:      rawStat.networkUsage,
+		throttlingUsage:   rawStat.throttlingUsage,
 	}
 	if queueLength != 0 {
 		// % utilization can be calculated only when queue is non-empty.
 		lastStat := queue.buffer[queueLength-1]
+		stat.storageUsageDelta = storageUsageSince(lastStat.storageUsage, rawStat.storageUsage)
+		stat.networkUsageDelta = networkUsageSince(lastStat.networkUsage, rawStat.networkUsage)
+		stat.throttlingUsageDelta = throttlingUsageSince(lastStat.throttlingUsage, rawStat.throttlingUsage)
 		timeSinceLastStat := float32(rawStat.timestamp.Sub(lastStat.Timestamp).Nanoseconds())
 		if timeSinceLastStat > 0 {
 			cpuUsageSinceLastStat := float32(rawStat.cpuUsage - lastStat.cpuUsage)
@@ -94,6 +102,124 @@ func (queue *Queue) GetMemoryStatsSet() (*ecstcs.CWStatsSet, error) {
 	return queue.getCWStatsSet(getMemoryUsagePerc)
 }
 
+// GetStorageStatsSet gets the stats sets for the bytes and operations read
+// and written on block devices between stats. The stats sets for operations
+// are left out if docker didn't report them. It returns an error if docker
+// reported no block IO for the container.
+func (queue *Queue) GetStorageStatsSet() (*ecstcs.StorageStatsSet, error) {
+	storageStatsSet := &ecstcs.StorageStatsSet{}
+	statsSets := []struct {
+		set      **ecstcs.CWStatsSet
+		getUsage getUsageFunc
+		optional bool
+	}{
+		{&storageStatsSet.ReadSizeBytes, getStorageReadBytes, false},
+		{&storageStatsSet.WriteSizeBytes, getStorageWriteBytes, false},
+		{&storageStatsSet.ReadOps, getStorageReadOps, true},
+		{&storageStatsSet.WriteOps, getStorageWriteOps, true},
+	}
+	for _, statsSet := range statsSets {
+		set, err := queue.getCWStatsSet(statsSet.getUsage)
+		if err != nil {
+			return nil, err
+		}
+		if *set.SampleCount == 0 {
+			if statsSet.optional {
+				continue
+			}
+			return nil, fmt.Errorf("No storage stats in the queue")
+		}
+		*statsSet.set = set
+	}
+	return storageStatsSet, nil
+}
+
+// GetThrottlingStatsSet gets the stats sets for the CFS enforcement periods
+// the container ran in and how many and for how long, in nanoseconds, its cpu
+// was throttled in them between stats. It returns an error if docker reported
+// no throttling for the container, as when its cpu has no quota.
+func (queue *Queue) GetThrottlingStatsSet() (*ecstcs.ThrottlingStatsSet, error) {
+	throttlingStatsSet := &ecstcs.ThrottlingStatsSet{}
+	statsSets := []struct {
+		set      **ecstcs.CWStatsSet
+		getUsage func(*ThrottlingStats) uint64
+	}{
+		{&throttlingStatsSet.Periods, func(s *ThrottlingStats) uint64 { return s.Periods }},
+		{&throttlingStatsSet.ThrottledPeriods, func(s *ThrottlingStats) uint64 { return s.ThrottledPeriods }},
+		{&throttlingStatsSet.ThrottledTime, func(s *ThrottlingStats) uint64 { return s.ThrottledTime }},
+	}
+	for _, statsSet := range statsSets {
+		set, err := queue.getCWStatsSet(getThrottlingUsage(statsSet.getUsage))
+		if err != nil {
+			return nil, err
+		}
+		if *set.SampleCount == 0 {
+			return nil, fmt.Errorf("No throttling stats in the queue")
+		}
+		*statsSet.set = set
+	}
+	return throttlingStatsSet, nil
+}
+
+// GetNetworkStatsSets gets the stats sets for the bytes and packets received
+// and transmitted between stats on each network interface, ordered by the
+// name of the interface. It returns an error if docker reported no network
+// usage for the container.
+func (queue *Queue) GetNetworkStatsSets() ([]*ecstcs.NetworkStatsSet, error) {
+	var networkStatsSets []*ecstcs.NetworkStatsSet
+	for _, name := range queue.networkInterfaces() {
+		networkStatsSet := &ecstcs.NetworkStatsSet{InterfaceName: aws.String(name)}
+		statsSets := []struct {
+			set      **ecstcs.CWStatsSet
+			getUsage func(*NetworkStats) uint64
+		}{
+			{&networkStatsSet.RxBytes, func(s *NetworkStats) uint64 { return s.RxBytes }},
+			{&networkStatsSet.RxPackets, func(s *NetworkStats) uint64 { return s.RxPackets }},
+			{&networkStatsSet.TxBytes, func(s *NetworkStats) uint64 { return s.TxBytes }},
+			{&networkStatsSet.TxPackets, func(s *NetworkStats) uint64 { return s.TxPackets }},
+		}
+		hasSamples := true
+		for _, statsSet := range statsSets {
+			set, err := queue.getCWStatsSet(getNetworkUsage(name, statsSet.getUsage))
+			if err != nil {
+				return nil, err
+			}
+			if *set.SampleCount == 0 {
+				hasSamples = false
+				break
+			}
+			*statsSet.set = set
+		}
+		if hasSamples {
+			networkStatsSets = append(networkStatsSets, networkStatsSet)
+		}
+	}
+	if len(networkStatsSets) == 0 {
+		return nil, fmt.Errorf("No network stats in the queue")
+	}
+	return networkStatsSets, nil
+}
+
+// networkInterfaces returns the sorted names of the network interfaces that
+// have usage in the queue
+func (queue *Queue) networkInterfaces() []string {
+	queue.bufferLock.RLock()
+	defer queue.bufferLock.RUnlock()
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, stat := range queue.buffer {
+		for name := range stat.networkUsageDelta {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetRawUsageStats gets the array of most recent raw UsageStats, in descending
 // order of timestamps.
 func (queue *Queue) GetRawUsageStats(numStats int) ([]UsageStats, error) {
@@ -131,6 +257,116 @@ func getMemoryUsagePerc(s *UsageStats) float64 {
 	return float64(s.MemoryUsageInMegs)
 }
 
+func getStorageReadBytes(s *UsageStats) float64 {
+	if s.storageUsageDelta == nil {
+		return math.NaN()
+	}
+	return float64(s.storageUsageDelta.ReadBytes)
+}
+
+func getStorageWriteBytes(s *UsageStats) float64 {
+	if s.storageUsageDelta == nil {
+		return math.NaN()
+	}
+	return float64(s.storageUsageDelta.WriteBytes)
+}
+
+func getStorageReadOps(s *UsageStats) float64 {
+	if s.storageUsageDelta == nil || s.storageUsageDelta.OpsUnknown {
+		return math.NaN()
+	}
+	return float64(s.storageUsageDelta.ReadOps)
+}
+
+func getStorageWriteOps(s *UsageStats) float64 {
+	if s.storageUsageDelta == nil || s.storageUsageDelta.OpsUnknown {
+		return math.NaN()
+	}
+	return float64(s.storageUsageDelta.WriteOps)
+}
+
+// storageUsageSince returns the block IO between two stats, or nil if it's
+// unknown for either or if the counters were reset, such as when the
+// container restarted
+func storageUsageSince(last *StorageStats, current *StorageStats) *StorageStats {
+	if last == nil || current == nil {
+		return nil
+	}
+	if current.ReadBytes < last.ReadBytes || current.WriteBytes < last.WriteBytes || current.ReadOps < last.ReadOps || current.WriteOps < last.WriteOps {
+		return nil
+	}
+	return &StorageStats{
+		ReadBytes:  current.ReadBytes - last.ReadBytes,
+		WriteBytes: current.WriteBytes - last.WriteBytes,
+		ReadOps:    current.ReadOps - last.ReadOps,
+		WriteOps:   current.WriteOps - last.WriteOps,
+		OpsUnknown: last.OpsUnknown || current.OpsUnknown,
+	}
+}
+
+// getThrottlingUsage returns a getUsageFunc for a counter of cpu throttling
+func getThrottlingUsage(getCounter func(*ThrottlingStats) uint64) getUsageFunc {
+	return func(s *UsageStats) float64 {
+		if s.throttlingUsageDelta == nil {
+			return math.NaN()
+		}
+		return float64(getCounter(s.throttlingUsageDelta))
+	}
+}
+
+// throttlingUsageSince returns the cpu throttling between two stats, or nil if
+// it's unknown for either or if the counters were reset
+func throttlingUsageSince(last *ThrottlingStats, current *ThrottlingStats) *ThrottlingStats {
+	if last == nil || current == nil {
+		return nil
+	}
+	if current.Periods < last.Periods || current.ThrottledPeriods < last.ThrottledPeriods || current.ThrottledTime < last.ThrottledTime {
+		return nil
+	}
+	return &ThrottlingStats{
+		Periods:          current.Periods - last.Periods,
+		ThrottledPeriods: current.ThrottledPeriods - last.ThrottledPeriods,
+		ThrottledTime:    current.ThrottledTime - last.ThrottledTime,
+	}
+}
+
+// getNetworkUsage returns a getUsageFunc for a counter of a network interface
+func getNetworkUsage(name string, getCounter func(*NetworkStats) uint64) getUsageFunc {
+	return func(s *UsageStats) float64 {
+		delta, ok := s.networkUsageDelta[name]
+		if !ok {
+			return math.NaN()
+		}
+		return float64(getCounter(delta))
+	}
+}
+
+// networkUsageSince returns the network usage between two stats of each
+// interface whose usage is known for both, skipping interfaces whose counters
+// were reset
+func networkUsageSince(last map[string]*NetworkStats, current map[string]*NetworkStats) map[string]*NetworkStats {
+	var delta map[string]*NetworkStats
+	for name, currentStats := range current {
+		lastStats, ok := last[name]
+		if !ok {
+			continue
+		}
+		if currentStats.RxBytes < lastStats.RxBytes || currentStats.RxPackets < lastStats.RxPackets || currentStats.TxBytes < lastStats.TxBytes || currentStats.TxPackets < lastStats.TxPackets {
+			continue
+		}
+		if delta == nil {
+			delta = make(map[string]*NetworkStats)
+		}
+		delta[name] = &NetworkStats{
+			RxBytes:   currentStats.RxBytes - lastStats.RxBytes,
+			RxPackets: currentStats.RxPackets - lastStats.RxPackets,
+			TxBytes:   currentStats.TxBytes - lastStats.TxBytes,
+			TxPackets: currentStats.TxPackets - lastStats.TxPackets,
+		}
+	}
+	return delta
+}
+
 type getUsageFunc func(*UsageStats) float64
 
 // getCWStatsSet gets the stats set for either CPU or Memory based on the
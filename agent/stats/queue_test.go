@@ -19,6 +19,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
 	"github.com/aws/aws-sdk-go/aws"
 )
 
@@ -309,3 +310,194 @@ func TestCpuStatsSetNotSetToInfinity(t *testing.T) {
 		t.Errorf("Computed cpuStatsSet.SampleCount (%d) != expected value (%d)", sampleCount, 1)
 	}
 }
+
+func TestStorageStatsSet(t *testing.T) {
+	timestamps := getTimestamps()[:4]
+	storageUsage := []*StorageStats{
+		{ReadBytes: 1000, WriteBytes: 2000, ReadOps: 10, WriteOps: 20},
+		{ReadBytes: 1500, WriteBytes: 2000, ReadOps: 15, WriteOps: 20},
+		// Counters that went backwards, such as after a restart, aren't used
+		{ReadBytes: 100, WriteBytes: 200, ReadOps: 1, WriteOps: 2},
+		{ReadBytes: 400, WriteBytes: 1200, ReadOps: 4, WriteOps: 12},
+	}
+
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.Add(&ContainerStats{cpuUsage: uint64(i), memoryUsage: 1, storageUsage: storageUsage[i], timestamp: timestamp})
+	}
+	storageStatsSet, err := queue.GetStorageStatsSet()
+	if err != nil {
+		t.Fatalf("Error getting storage stats set: %v", err)
+	}
+
+	expected := []struct {
+		name     string
+		set      *ecstcs.CWStatsSet
+		min, max float64
+	}{
+		{"ReadSizeBytes", storageStatsSet.ReadSizeBytes, 300, 500},
+		{"WriteSizeBytes", storageStatsSet.WriteSizeBytes, 0, 1000},
+		{"ReadOps", storageStatsSet.ReadOps, 3, 5},
+		{"WriteOps", storageStatsSet.WriteOps, 0, 10},
+	}
+	for _, e := range expected {
+		if aws.Int64Value(e.set.SampleCount) != 2 {
+			t.Errorf("%s: unexpected sample count: %d", e.name, aws.Int64Value(e.set.SampleCount))
+		}
+		if aws.Float64Value(e.set.Min) != e.min || aws.Float64Value(e.set.Max) != e.max || aws.Float64Value(e.set.Sum) != e.min+e.max {
+			t.Errorf("%s: unexpected stats set: %s", e.name, e.set)
+		}
+	}
+}
+
+func TestStorageStatsSetWithoutStorageUsage(t *testing.T) {
+	queue := NewQueue(3)
+	for i, timestamp := range getTimestamps()[:3] {
+		queue.Add(&ContainerStats{cpuUsage: uint64(i), memoryUsage: 1, timestamp: timestamp})
+	}
+	if _, err := queue.GetStorageStatsSet(); err == nil {
+		t.Error("Expected an error getting storage stats set without storage usage")
+	}
+	if _, err := queue.GetCPUStatsSet(); err != nil {
+		t.Errorf("Error getting cpu stats set: %v", err)
+	}
+}
+
+func TestNetworkStatsSets(t *testing.T) {
+	timestamps := getTimestamps()[:3]
+	networkUsage := []map[string]*NetworkStats{
+		{
+			"eth0": {RxBytes: 1000, RxPackets: 10, TxBytes: 2000, TxPackets: 20},
+		},
+		// An interface attached later is reported once it has two stats
+		{
+			"eth0": {RxBytes: 1500, RxPackets: 15, TxBytes: 2200, TxPackets: 22},
+			"eth1": {RxBytes: 100, RxPackets: 1, TxBytes: 200, TxPackets: 2},
+		},
+		{
+			"eth0": {RxBytes: 1600, RxPackets: 16, TxBytes: 2500, TxPackets: 25},
+			"eth1": {RxBytes: 400, RxPackets: 4, TxBytes: 600, TxPackets: 6},
+		},
+	}
+
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.Add(&ContainerStats{cpuUsage: uint64(i), memoryUsage: 1, networkUsage: networkUsage[i], timestamp: timestamp})
+	}
+	networkStatsSets, err := queue.GetNetworkStatsSets()
+	if err != nil {
+		t.Fatalf("Error getting network stats sets: %v", err)
+	}
+	if len(networkStatsSets) != 2 {
+		t.Fatalf("Unexpected network stats sets: %v", networkStatsSets)
+	}
+
+	expected := []struct {
+		name                                               string
+		sampleCount                                        int64
+		rxBytesSum, rxPacketsSum, txBytesSum, txPacketsSum float64
+	}{
+		{"eth0", 2, 600, 6, 500, 5},
+		{"eth1", 1, 300, 3, 400, 4},
+	}
+	for i, e := range expected {
+		set := networkStatsSets[i]
+		if aws.StringValue(set.InterfaceName) != e.name {
+			t.Errorf("Unexpected interface name: %s, expected %s", aws.StringValue(set.InterfaceName), e.name)
+		}
+		if aws.Int64Value(set.RxBytes.SampleCount) != e.sampleCount {
+			t.Errorf("%s: unexpected sample count: %d", e.name, aws.Int64Value(set.RxBytes.SampleCount))
+		}
+		sums := []float64{aws.Float64Value(set.RxBytes.Sum), aws.Float64Value(set.RxPackets.Sum), aws.Float64Value(set.TxBytes.Sum), aws.Float64Value(set.TxPackets.Sum)}
+		expectedSums := []float64{e.rxBytesSum, e.rxPacketsSum, e.txBytesSum, e.txPacketsSum}
+		for j := range sums {
+			if sums[j] != expectedSums[j] {
+				t.Errorf("%s: unexpected sums: %v, expected %v", e.name, sums, expectedSums)
+				break
+			}
+		}
+	}
+}
+
+func TestNetworkStatsSetsWithoutNetworkUsage(t *testing.T) {
+	queue := NewQueue(3)
+	for i, timestamp := range getTimestamps()[:3] {
+		queue.Add(&ContainerStats{cpuUsage: uint64(i), memoryUsage: 1, timestamp: timestamp})
+	}
+	if _, err := queue.GetNetworkStatsSets(); err == nil {
+		t.Error("Expected an error getting network stats sets without network usage")
+	}
+}
+
+func TestThrottlingStatsSet(t *testing.T) {
+	timestamps := getTimestamps()[:4]
+	throttlingUsage := []*ThrottlingStats{
+		{Periods: 100, ThrottledPeriods: 10, ThrottledTime: 50000000},
+		{Periods: 110, ThrottledPeriods: 10, ThrottledTime: 50000000},
+		{Periods: 130, ThrottledPeriods: 25, ThrottledTime: 90000000},
+		// Counters that went backwards, such as after a restart, aren't used
+		{Periods: 5, ThrottledPeriods: 1, ThrottledTime: 1000000},
+	}
+
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.Add(&ContainerStats{cpuUsage: uint64(i), memoryUsage: 1, throttlingUsage: throttlingUsage[i], timestamp: timestamp})
+	}
+	throttlingStatsSet, err := queue.GetThrottlingStatsSet()
+	if err != nil {
+		t.Fatalf("Error getting throttling stats set: %v", err)
+	}
+
+	expected := []struct {
+		name     string
+		set      *ecstcs.CWStatsSet
+		min, max float64
+	}{
+		{"Periods", throttlingStatsSet.Periods, 10, 20},
+		{"ThrottledPeriods", throttlingStatsSet.ThrottledPeriods, 0, 15},
+		{"ThrottledTime", throttlingStatsSet.ThrottledTime, 0, 40000000},
+	}
+	for _, e := range expected {
+		if aws.Int64Value(e.set.SampleCount) != 2 {
+			t.Errorf("%s: unexpected sample count: %d", e.name, aws.Int64Value(e.set.SampleCount))
+		}
+		if aws.Float64Value(e.set.Min) != e.min || aws.Float64Value(e.set.Max) != e.max || aws.Float64Value(e.set.Sum) != e.min+e.max {
+			t.Errorf("%s: unexpected stats set: %s", e.name, e.set)
+		}
+	}
+}
+
+func TestThrottlingStatsSetWithoutThrottlingUsage(t *testing.T) {
+	queue := NewQueue(3)
+	for i, timestamp := range getTimestamps()[:3] {
+		queue.Add(&ContainerStats{cpuUsage: uint64(i), memoryUsage: 1, timestamp: timestamp})
+	}
+	if _, err := queue.GetThrottlingStatsSet(); err == nil {
+		t.Error("Expected an error getting throttling stats set without throttling usage")
+	}
+}
+
+func TestStorageStatsSetWithoutOps(t *testing.T) {
+	timestamps := getTimestamps()[:3]
+	// Docker reports no block IO operations on cgroup v2
+	storageUsage := []*StorageStats{
+		{ReadBytes: 1000, WriteBytes: 2000, OpsUnknown: true},
+		{ReadBytes: 1500, WriteBytes: 2000, OpsUnknown: true},
+		{ReadBytes: 1800, WriteBytes: 2600, OpsUnknown: true},
+	}
+
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.Add(&ContainerStats{cpuUsage: uint64(i), memoryUsage: 1, storageUsage: storageUsage[i], timestamp: timestamp})
+	}
+	storageStatsSet, err := queue.GetStorageStatsSet()
+	if err != nil {
+		t.Fatalf("Error getting storage stats set: %v", err)
+	}
+	if storageStatsSet.ReadOps != nil || storageStatsSet.WriteOps != nil {
+		t.Errorf("Expected no stats sets for operations, got: %s", storageStatsSet)
+	}
+	if aws.Float64Value(storageStatsSet.ReadSizeBytes.Sum) != 800 || aws.Float64Value(storageStatsSet.WriteSizeBytes.Sum) != 600 {
+		t.Errorf("Unexpected stats sets for bytes: %s", storageStatsSet)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/cihub/seelog"
+)
+
+// statsStateVersion is the version of the format of savedStatsState. It must be
+// incremented for any change to the format; state saved with a different
+// version is discarded rather than loaded
+const statsStateVersion = 1
+
+// savedStatsState is the format in which the stats engine saves the usage
+// stats collected for each container, so that the aggregation window can
+// resume after an agent restart
+type savedStatsState struct {
+	Version int `json:"version"`
+	// Containers maps docker ids to the container's usage stats, oldest first
+	Containers map[string][]savedUsageStats `json:"containers"`
+}
+
+// savedUsageStats includes the raw cpu usage, which is needed to calculate
+// the utilization for the next stat added to the queue. The cpu utilization
+// is omitted when it couldn't be calculated, as NaN can't be encoded as json
+type savedUsageStats struct {
+	CPUUsagePerc      *float32  `json:"cpuUsagePerc,omitempty"`
+	MemoryUsageInMegs uint32    `json:"memoryUsageInMegs"`
+	Timestamp         time.Time `json:"timestamp"`
+	CPUUsage          uint64    `json:"cpuUsage"`
+}
+
+// MarshalJSON saves the usage stats of each container being watched
+func (engine *DockerStatsEngine) MarshalJSON() ([]byte, error) {
+	engine.containersLock.RLock()
+	defer engine.containersLock.RUnlock()
+
+	saved := savedStatsState{
+		Version:    statsStateVersion,
+		Containers: make(map[string][]savedUsageStats),
+	}
+	for _, containers := range engine.tasksToContainers {
+		for dockerID, container := range containers {
+			if container.statsQueue == nil {
+				continue
+			}
+			saved.Containers[dockerID] = container.statsQueue.save()
+		}
+	}
+	return json.Marshal(saved)
+}
+
+// UnmarshalJSON loads saved usage stats. They are used once the containers
+// they belong to are added to the engine
+func (engine *DockerStatsEngine) UnmarshalJSON(data []byte) error {
+	var saved savedStatsState
+	err := json.Unmarshal(data, &saved)
+	if err != nil {
+		return err
+	}
+	if saved.Version != statsStateVersion {
+		seelog.Warnf("Discarding saved stats with unsupported version %d, expected %d", saved.Version, statsStateVersion)
+		return nil
+	}
+
+	engine.containersLock.Lock()
+	defer engine.containersLock.Unlock()
+
+	engine.savedQueues = make(map[string]*Queue)
+	for dockerID, stats := range saved.Containers {
+		engine.savedQueues[dockerID] = newQueueFromSaved(ContainerStatsBufferLength, stats)
+	}
+	return nil
+}
+
+// takeSavedQueue returns the saved queue for the container, if any, so that
+// collection resumes where it left off. Each saved queue is only returned once.
+// containersLock must be held by the caller
+func (engine *DockerStatsEngine) takeSavedQueue(dockerID string) (*Queue, bool) {
+	queue, ok := engine.savedQueues[dockerID]
+	if ok {
+		delete(engine.savedQueues, dockerID)
+	}
+	return queue, ok
+}
+
+// discardSavedQueues drops saved queues that weren't used, as their containers
+// no longer exist
+func (engine *DockerStatsEngine) discardSavedQueues() {
+	engine.containersLock.Lock()
+	defer engine.containersLock.Unlock()
+
+	for dockerID := range engine.savedQueues {
+		seelog.Debugf("Discarding saved stats for container that no longer exists, id: %s", dockerID)
+	}
+	engine.savedQueues = nil
+}
+
+// save returns the queue's usage stats, oldest first
+func (queue *Queue) save() []savedUsageStats {
+	queue.bufferLock.RLock()
+	defer queue.bufferLock.RUnlock()
+
+	saved := make([]savedUsageStats, len(queue.buffer))
+	for i, stat := range queue.buffer {
+		saved[i] = savedUsageStats{
+			MemoryUsageInMegs: stat.MemoryUsageInMegs,
+			Timestamp:         stat.Timestamp,
+			CPUUsage:          stat.cpuUsage,
+		}
+		if !math.IsNaN(float64(stat.CPUUsagePerc)) && !math.IsInf(float64(stat.CPUUsagePerc), 0) {
+			cpuUsagePerc := stat.CPUUsagePerc
+			saved[i].CPUUsagePerc = &cpuUsagePerc
+		}
+	}
+	return saved
+}
+
+// newQueueFromSaved creates a queue holding the saved usage stats. If there are
+// more than maxSize, the oldest are dropped
+func newQueueFromSaved(maxSize int, saved []savedUsageStats) *Queue {
+	queue := NewQueue(maxSize)
+	if len(saved) > maxSize {
+		saved = saved[len(saved)-maxSize:]
+	}
+	for _, stat := range saved {
+		usageStats := UsageStats{
+			CPUUsagePerc:      float32(nan32()),
+			MemoryUsageInMegs: stat.MemoryUsageInMegs,
+			Timestamp:         stat.Timestamp,
+			cpuUsage:          stat.CPUUsage,
+		}
+		if stat.CPUUsagePerc != nil {
+			usageStats.CPUUsagePerc = *stat.CPUUsagePerc
+		}
+		queue.buffer = append(queue.buffer, usageStats)
+	}
+	return queue
+}
@@ -0,0 +1,161 @@
+//+build !integration
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	ecsengine "github.com/aws/amazon-ecs-agent/agent/engine"
+	mock_resolver "github.com/aws/amazon-ecs-agent/agent/stats/resolver/mock"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+)
+
+func TestStatsEngineSaveAndRestoreState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	mockDockerClient := ecsengine.NewMockDockerClient(ctrl)
+	t1 := &api.Task{Arn: "t1", Family: "f1"}
+	resolver.EXPECT().ResolveTask("c1").AnyTimes().Return(t1, nil)
+	resolver.EXPECT().ResolveContainer(gomock.Any()).AnyTimes().Return(&api.DockerContainer{
+		Container: &api.Container{},
+	}, nil)
+	mockStatsChannel := make(chan *docker.Stats)
+	defer close(mockStatsChannel)
+	mockDockerClient.EXPECT().Stats(gomock.Any(), gomock.Any()).Return(mockStatsChannel, nil).AnyTimes()
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineSaveAndRestoreState"))
+	engine.resolver = resolver
+	engine.client = mockDockerClient
+	engine.cluster = defaultCluster
+	engine.containerInstanceArn = defaultContainerInstance
+	defer engine.removeAll()
+
+	engine.addContainer("c1")
+	containerStats := createFakeContainerStats()
+	for _, stat := range containerStats {
+		engine.tasksToContainers["t1"]["c1"].statsQueue.Add(stat)
+	}
+
+	data, err := json.Marshal(engine)
+	if err != nil {
+		t.Fatalf("Error saving stats engine state: %v", err)
+	}
+
+	// Simulate a restart by dropping all containers and loading the saved state
+	engine.removeAll()
+	err = json.Unmarshal(data, engine)
+	if err != nil {
+		t.Fatalf("Error loading stats engine state: %v", err)
+	}
+	engine.addContainer("c1")
+
+	queue := engine.tasksToContainers["t1"]["c1"].statsQueue
+	restored, err := queue.GetRawUsageStats(len(containerStats))
+	if err != nil {
+		t.Fatalf("Error getting restored usage stats: %v", err)
+	}
+	if len(restored) != len(containerStats) {
+		t.Fatalf("Incorrect number of restored stats. Expected: %d, got: %d", len(containerStats), len(restored))
+	}
+	if restored[0].MemoryUsageInMegs != uint32(containerStats[1].memoryUsage/BytesInMiB) {
+		t.Errorf("Incorrect memory usage for latest restored stat: %d", restored[0].MemoryUsageInMegs)
+	}
+	if len(engine.savedQueues) != 0 {
+		t.Errorf("Saved queue not consumed when adding the container")
+	}
+
+	// Utilization must be calculated against the restored stats
+	queue.Add(&ContainerStats{
+		cpuUsage:    containerStats[1].cpuUsage + 100000000,
+		memoryUsage: containerStats[1].memoryUsage,
+		timestamp:   containerStats[1].timestamp.Add(100000000),
+	})
+	latest, _ := queue.GetRawUsageStats(1)
+	if math.IsNaN(float64(latest[0].CPUUsagePerc)) || latest[0].CPUUsagePerc != 100 {
+		t.Errorf("Incorrect cpu utilization after restore. Expected: 100, got: %f", latest[0].CPUUsagePerc)
+	}
+}
+
+func TestStatsEngineDiscardsUnusedSavedState(t *testing.T) {
+	queue := NewQueue(ContainerStatsBufferLength)
+	for _, stat := range createFakeContainerStats() {
+		queue.Add(stat)
+	}
+	data, err := json.Marshal(savedStatsState{
+		Version:    statsStateVersion,
+		Containers: map[string][]savedUsageStats{"gone": queue.save()},
+	})
+	if err != nil {
+		t.Fatalf("Error marshaling saved state: %v", err)
+	}
+
+	engine := &DockerStatsEngine{}
+	err = json.Unmarshal(data, engine)
+	if err != nil {
+		t.Fatalf("Error loading stats engine state: %v", err)
+	}
+	if len(engine.savedQueues) != 1 {
+		t.Fatalf("Expected saved queue to be loaded, got: %d", len(engine.savedQueues))
+	}
+	engine.discardSavedQueues()
+	if _, ok := engine.takeSavedQueue("gone"); ok {
+		t.Error("Expected saved queue to be discarded")
+	}
+}
+
+func TestStatsEngineIgnoresSavedStateWithDifferentVersion(t *testing.T) {
+	data, err := json.Marshal(savedStatsState{
+		Version: statsStateVersion + 1,
+		Containers: map[string][]savedUsageStats{
+			"c1": []savedUsageStats{{CPUUsage: 1}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error marshaling saved state: %v", err)
+	}
+
+	engine := &DockerStatsEngine{}
+	err = json.Unmarshal(data, engine)
+	if err != nil {
+		t.Fatalf("Expected state with a different version to be ignored, got: %v", err)
+	}
+	if len(engine.savedQueues) != 0 {
+		t.Errorf("Expected no saved queues, got: %d", len(engine.savedQueues))
+	}
+}
+
+func TestNewQueueFromSavedKeepsNewestStats(t *testing.T) {
+	saved := make([]savedUsageStats, 5)
+	for i := range saved {
+		saved[i] = savedUsageStats{
+			MemoryUsageInMegs: uint32(i),
+			CPUUsage:          uint64(i),
+		}
+	}
+	queue := newQueueFromSaved(3, saved)
+	if len(queue.buffer) != 3 {
+		t.Fatalf("Incorrect queue length. Expected: 3, got: %d", len(queue.buffer))
+	}
+	for i, stat := range queue.buffer {
+		if stat.MemoryUsageInMegs != uint32(i+2) || stat.cpuUsage != uint64(i+2) {
+			t.Errorf("Unexpected stat at %d: %+v", i, stat)
+		}
+	}
+}
@@ -14,18 +14,59 @@
 package stats
 
 import (
+	"sync"
 	"time"
 
 	ecsengine "github.com/aws/amazon-ecs-agent/agent/engine"
 	"github.com/aws/amazon-ecs-agent/agent/stats/resolver"
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+	docker "github.com/fsouza/go-dockerclient"
 	"golang.org/x/net/context"
 )
 
-// ContainerStats encapsulates the raw CPU and memory utilization from cgroup fs.
+// ContainerStats encapsulates the raw CPU, memory and block IO utilization
+// from cgroup fs.
 type ContainerStats struct {
 	cpuUsage    uint64
 	memoryUsage uint64
 	timestamp   time.Time
+	// storageUsage is nil if docker reported no block IO for the container,
+	// which some storage drivers don't account for
+	storageUsage *StorageStats
+	// networkUsage is keyed by the name of the network interface
+	networkUsage map[string]*NetworkStats
+	// throttlingUsage is nil if the container's cpu isn't limited by a CFS
+	// quota, so it's never throttled
+	throttlingUsage *ThrottlingStats
+}
+
+// StorageStats are the bytes and operations read and written by a container
+// on block devices. OpsUnknown is set if docker reported the bytes but not the
+// operations, as it does on cgroup v2.
+type StorageStats struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadOps    uint64
+	WriteOps   uint64
+	OpsUnknown bool
+}
+
+// NetworkStats are the bytes and packets received and transmitted by a
+// container on a network interface.
+type NetworkStats struct {
+	RxBytes   uint64
+	RxPackets uint64
+	TxBytes   uint64
+	TxPackets uint64
+}
+
+// ThrottlingStats are the CFS enforcement periods a container ran in and how
+// many and for how long, in nanoseconds, it was throttled in them.
+type ThrottlingStats struct {
+	Periods          uint64
+	ThrottledPeriods uint64
+	ThrottledTime    uint64
 }
 
 // UsageStats abstracts the format in which the queue stores data.
@@ -34,6 +75,19 @@ type UsageStats struct {
 	MemoryUsageInMegs uint32    `json:"memoryUsageInMegs"`
 	Timestamp         time.Time `json:"timestamp"`
 	cpuUsage          uint64
+	// storageUsage is the block IO of the container since it started and
+	// storageUsageDelta the block IO since the previous stats. Either is nil
+	// if it's unknown
+	storageUsage      *StorageStats
+	storageUsageDelta *StorageStats
+	// networkUsage and networkUsageDelta are the same for each network
+	// interface, keyed by its name
+	networkUsage      map[string]*NetworkStats
+	networkUsageDelta map[string]*NetworkStats
+	// throttlingUsage and throttlingUsageDelta are the same for cpu
+	// throttling
+	throttlingUsage      *ThrottlingStats
+	throttlingUsageDelta *ThrottlingStats
 }
 
 // ContainerMetadata contains meta-data information for a container.
@@ -49,6 +103,16 @@ type StatsContainer struct {
 	client            ecsengine.DockerClient
 	statsQueue        *Queue
 	resolver          resolver.ContainerMetadataResolver
+	// pollInterval is the time between polls of the stats of the container,
+	// or zero if its stats are streamed
+	pollInterval time.Duration
+	time         ttime.Time
+	// dimensions are published with the metrics of the container
+	dimensions []*ecstcs.Dimension
+	// lastStats is the most recent stats reported by docker, including the
+	// network stats that aren't aggregated in statsQueue
+	lastStats     *docker.Stats
+	lastStatsLock sync.RWMutex
 }
 
 // taskDefinition encapsulates family and version strings for a task definition
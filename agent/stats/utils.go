@@ -18,6 +18,7 @@ import (
 	"math"
 	"regexp"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/cihub/seelog"
@@ -28,29 +29,129 @@ import (
 // if there's an error reading network stats.
 const networkStatsErrorPattern = "open /sys/class/net/veth.*: no such file or directory"
 
+// defaultNetworkInterface is the name of the network interface that docker
+// reports stats for when it doesn't break them out by interface
+const defaultNetworkInterface = "eth0"
+
 var numCores = uint64(runtime.NumCPU())
 
+const (
+	// cgroupV1 and cgroupV2 are the versions of the cgroup hierarchy docker
+	// reads the stats of containers from
+	cgroupV1 = 1
+	cgroupV2 = 2
+)
+
 // nan32 returns a 32bit NaN.
 func nan32() float32 {
 	return (float32)(math.NaN())
 }
 
+// cgroupVersion returns the version of the cgroup hierarchy the stats were
+// read from. Docker reports the cpu usage of each core on cgroup v1 only
+func cgroupVersion(dockerStats *docker.Stats) int {
+	if len(dockerStats.CPUStats.CPUUsage.PercpuUsage) > 0 {
+		return cgroupV1
+	}
+	return cgroupV2
+}
+
 // dockerStatsToContainerStats returns a new object of the ContainerStats object from docker stats.
+// The stats mean the same on cgroup v1 and v2: docker reports the total cpu
+// usage and the memory usage, page cache included, of both alike
 func dockerStatsToContainerStats(dockerStats *docker.Stats) (*ContainerStats, error) {
-	// The length of PercpuUsage represents the number of cores in an instance.
-	if len(dockerStats.CPUStats.CPUUsage.PercpuUsage) == 0 {
+	version := cgroupVersion(dockerStats)
+	// Docker reports empty stats for containers that stopped. Stats read
+	// from cgroup v1 always have the usage of each core, and stats read from
+	// cgroup v2 the usage of the instance
+	if version == cgroupV2 && dockerStats.CPUStats.SystemCPUUsage == 0 {
 		seelog.Debug("Invalid container statistics reported, invalid stats payload from docker")
 		return nil, fmt.Errorf("Invalid container statistics reported")
 	}
 
 	cpuUsage := dockerStats.CPUStats.CPUUsage.TotalUsage / numCores
 	return &ContainerStats{
-		cpuUsage:    cpuUsage,
-		memoryUsage: dockerStats.MemoryStats.Usage,
-		timestamp:   dockerStats.Read,
+		cpuUsage:        cpuUsage,
+		memoryUsage:     dockerStats.MemoryStats.Usage,
+		storageUsage:    dockerStatsToStorageStats(dockerStats, version),
+		networkUsage:    dockerStatsToNetworkStats(dockerStats),
+		throttlingUsage: dockerStatsToThrottlingStats(dockerStats),
+		timestamp:       dockerStats.Read,
 	}, nil
 }
 
+// dockerStatsToThrottlingStats returns the cpu throttling of the container, or
+// nil if it ran in no CFS enforcement periods because its cpu has no quota.
+// Docker reports the nr_periods, nr_throttled and throttled_time fields of
+// cpu.stat on cgroup v1 and converts the throttled_usec field to nanoseconds
+// on cgroup v2, but leaves out the fields that are zero
+func dockerStatsToThrottlingStats(dockerStats *docker.Stats) *ThrottlingStats {
+	throttlingData := dockerStats.CPUStats.ThrottlingData
+	if throttlingData.Periods == 0 {
+		return nil
+	}
+	return &ThrottlingStats{
+		Periods:          throttlingData.Periods,
+		ThrottledPeriods: throttlingData.ThrottledPeriods,
+		ThrottledTime:    throttlingData.ThrottledTime,
+	}
+}
+
+// dockerStatsToNetworkStats returns the network usage of the container by
+// network interface. Docker versions before 1.9 report the default interface
+// of the container alone, outside of the networks map
+func dockerStatsToNetworkStats(dockerStats *docker.Stats) map[string]*NetworkStats {
+	networks := dockerStats.Networks
+	if len(networks) == 0 {
+		if dockerStats.Network == (docker.NetworkStats{}) {
+			return nil
+		}
+		networks = map[string]docker.NetworkStats{defaultNetworkInterface: dockerStats.Network}
+	}
+	networkStats := make(map[string]*NetworkStats, len(networks))
+	for name, network := range networks {
+		networkStats[name] = &NetworkStats{
+			RxBytes:   network.RxBytes,
+			RxPackets: network.RxPackets,
+			TxBytes:   network.TxBytes,
+			TxPackets: network.TxPackets,
+		}
+	}
+	return networkStats
+}
+
+// dockerStatsToStorageStats sums the block IO of the container across devices,
+// or returns nil if docker reported no block IO. On cgroup v2 docker reports
+// the bytes read and written but not the operations
+func dockerStatsToStorageStats(dockerStats *docker.Stats, version int) *StorageStats {
+	blkioStats := dockerStats.BlkioStats
+	if len(blkioStats.IOServiceBytesRecursive) == 0 && len(blkioStats.IOServicedRecursive) == 0 {
+		return nil
+	}
+	storageStats := &StorageStats{
+		OpsUnknown: version == cgroupV2 && len(blkioStats.IOServicedRecursive) == 0,
+	}
+	// Docker reports cgroup v1 operations capitalized and cgroup v2
+	// operations in lower case
+	for _, entry := range blkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			storageStats.ReadBytes += entry.Value
+		case "write":
+			storageStats.WriteBytes += entry.Value
+		}
+	}
+	for _, entry := range blkioStats.IOServicedRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			storageStats.ReadOps += entry.Value
+		case "write":
+			storageStats.WriteOps += entry.Value
+		}
+	}
+	return storageStats
+}
+
 // parseNanoTime returns the time object from a string formatted with RFC3339Nano layout.
 func parseNanoTime(value string) time.Time {
 	ts, _ := time.Parse(time.RFC3339Nano, value)
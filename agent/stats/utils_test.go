@@ -84,3 +84,352 @@ func TestDockerStatsToContainerStatsZeroCoresGeneratesError(t *testing.T) {
 		t.Error("Expected error converting container stats with empty PercpuUsage")
 	}
 }
+
+func TestDockerStatsToContainerStatsStorageUsage(t *testing.T) {
+	numCores = 4
+	jsonStat := `
+		{
+			"cpu_stats":{
+				"cpu_usage":{
+					"percpu_usage":[1, 2, 3, 4],
+					"total_usage":100
+				}
+			},
+			"blkio_stats":{
+				"io_service_bytes_recursive":[
+					{"major":202, "minor":0, "op":"Read", "value":4096},
+					{"major":202, "minor":0, "op":"Write", "value":8192},
+					{"major":202, "minor":0, "op":"Sync", "value":8192},
+					{"major":202, "minor":0, "op":"Total", "value":12288},
+					{"major":202, "minor":16, "op":"read", "value":1024},
+					{"major":202, "minor":16, "op":"write", "value":2048}
+				],
+				"io_serviced_recursive":[
+					{"major":202, "minor":0, "op":"Read", "value":2},
+					{"major":202, "minor":0, "op":"Write", "value":3},
+					{"major":202, "minor":0, "op":"Total", "value":5},
+					{"major":202, "minor":16, "op":"read", "value":1},
+					{"major":202, "minor":16, "op":"write", "value":1}
+				]
+			}
+		}`
+	dockerStat := &docker.Stats{}
+	json.Unmarshal([]byte(jsonStat), dockerStat)
+	containerStats, err := dockerStatsToContainerStats(dockerStat)
+	if err != nil {
+		t.Fatalf("Error converting container stats: %v", err)
+	}
+	if containerStats.storageUsage == nil {
+		t.Fatal("storageUsage should not be nil")
+	}
+	expected := StorageStats{ReadBytes: 5120, WriteBytes: 10240, ReadOps: 3, WriteOps: 4}
+	if *containerStats.storageUsage != expected {
+		t.Errorf("Unexpected value for storageUsage: %+v, expected %+v", *containerStats.storageUsage, expected)
+	}
+}
+
+func TestDockerStatsToContainerStatsEmptyStorageUsage(t *testing.T) {
+	numCores = 4
+	// Some storage drivers report no block IO at all
+	jsonStat := `
+		{
+			"cpu_stats":{
+				"cpu_usage":{
+					"percpu_usage":[1, 2, 3, 4],
+					"total_usage":100
+				}
+			},
+			"blkio_stats":{
+				"io_service_bytes_recursive":[],
+				"io_serviced_recursive":null
+			}
+		}`
+	dockerStat := &docker.Stats{}
+	json.Unmarshal([]byte(jsonStat), dockerStat)
+	containerStats, err := dockerStatsToContainerStats(dockerStat)
+	if err != nil {
+		t.Fatalf("Error converting container stats: %v", err)
+	}
+	if containerStats.storageUsage != nil {
+		t.Errorf("Expected no storageUsage, got: %+v", *containerStats.storageUsage)
+	}
+}
+
+func TestDockerStatsToContainerStatsNetworkUsage(t *testing.T) {
+	numCores = 4
+	jsonStat := `
+		{
+			"cpu_stats":{
+				"cpu_usage":{
+					"percpu_usage":[1, 2, 3, 4],
+					"total_usage":100
+				}
+			},
+			"networks":{
+				"eth0":{"rx_bytes":1000, "rx_packets":10, "rx_errors":1, "tx_bytes":2000, "tx_packets":20},
+				"eth1":{"rx_bytes":3000, "rx_packets":30, "tx_bytes":4000, "tx_packets":40, "tx_dropped":2}
+			}
+		}`
+	dockerStat := &docker.Stats{}
+	json.Unmarshal([]byte(jsonStat), dockerStat)
+	containerStats, err := dockerStatsToContainerStats(dockerStat)
+	if err != nil {
+		t.Fatalf("Error converting container stats: %v", err)
+	}
+	expected := map[string]NetworkStats{
+		"eth0": {RxBytes: 1000, RxPackets: 10, TxBytes: 2000, TxPackets: 20},
+		"eth1": {RxBytes: 3000, RxPackets: 30, TxBytes: 4000, TxPackets: 40},
+	}
+	if len(containerStats.networkUsage) != len(expected) {
+		t.Fatalf("Unexpected network interfaces: %v", containerStats.networkUsage)
+	}
+	for name, expectedStats := range expected {
+		networkStats, ok := containerStats.networkUsage[name]
+		if !ok {
+			t.Errorf("No networkUsage for interface %s", name)
+		} else if *networkStats != expectedStats {
+			t.Errorf("Unexpected networkUsage for interface %s: %+v, expected %+v", name, *networkStats, expectedStats)
+		}
+	}
+}
+
+func TestDockerStatsToContainerStatsSingleNetwork(t *testing.T) {
+	numCores = 4
+	// Older docker versions report the default interface alone
+	jsonStat := `
+		{
+			"cpu_stats":{
+				"cpu_usage":{
+					"percpu_usage":[1, 2, 3, 4],
+					"total_usage":100
+				}
+			},
+			"network":{"rx_bytes":1000, "rx_packets":10, "tx_bytes":2000, "tx_packets":20}
+		}`
+	dockerStat := &docker.Stats{}
+	json.Unmarshal([]byte(jsonStat), dockerStat)
+	containerStats, err := dockerStatsToContainerStats(dockerStat)
+	if err != nil {
+		t.Fatalf("Error converting container stats: %v", err)
+	}
+	networkStats, ok := containerStats.networkUsage["eth0"]
+	if len(containerStats.networkUsage) != 1 || !ok {
+		t.Fatalf("Unexpected network interfaces: %v", containerStats.networkUsage)
+	}
+	expected := NetworkStats{RxBytes: 1000, RxPackets: 10, TxBytes: 2000, TxPackets: 20}
+	if *networkStats != expected {
+		t.Errorf("Unexpected networkUsage: %+v, expected %+v", *networkStats, expected)
+	}
+}
+
+func TestDockerStatsToContainerStatsThrottlingCgroupV1(t *testing.T) {
+	numCores = 4
+	jsonStat := `
+		{
+			"cpu_stats":{
+				"cpu_usage":{
+					"percpu_usage":[1, 2, 3, 4],
+					"total_usage":100
+				},
+				"system_cpu_usage":1000,
+				"throttling_data":{"periods":120, "throttled_periods":30, "throttled_time":4500000000}
+			}
+		}`
+	dockerStat := &docker.Stats{}
+	json.Unmarshal([]byte(jsonStat), dockerStat)
+	containerStats, err := dockerStatsToContainerStats(dockerStat)
+	if err != nil {
+		t.Fatalf("Error converting container stats: %v", err)
+	}
+	if containerStats.throttlingUsage == nil {
+		t.Fatal("throttlingUsage should not be nil")
+	}
+	expected := ThrottlingStats{Periods: 120, ThrottledPeriods: 30, ThrottledTime: 4500000000}
+	if *containerStats.throttlingUsage != expected {
+		t.Errorf("Unexpected value for throttlingUsage: %+v, expected %+v", *containerStats.throttlingUsage, expected)
+	}
+}
+
+func TestDockerStatsToContainerStatsThrottlingCgroupV2(t *testing.T) {
+	numCores = 4
+	// Docker reports no usage by core on cgroup v2, and leaves out throttling
+	// fields that are zero
+	jsonStat := `
+		{
+			"cpu_stats":{
+				"cpu_usage":{
+					"total_usage":100,
+					"usage_in_usermode":60,
+					"usage_in_kernelmode":40
+				},
+				"system_cpu_usage":1000,
+				"throttling_data":{"periods":120}
+			}
+		}`
+	dockerStat := &docker.Stats{}
+	json.Unmarshal([]byte(jsonStat), dockerStat)
+	containerStats, err := dockerStatsToContainerStats(dockerStat)
+	if err != nil {
+		t.Fatalf("Error converting container stats: %v", err)
+	}
+	if containerStats.cpuUsage != 25 {
+		t.Error("Unexpected value for cpuUsage", containerStats.cpuUsage)
+	}
+	if containerStats.throttlingUsage == nil {
+		t.Fatal("throttlingUsage should not be nil")
+	}
+	expected := ThrottlingStats{Periods: 120}
+	if *containerStats.throttlingUsage != expected {
+		t.Errorf("Unexpected value for throttlingUsage: %+v, expected %+v", *containerStats.throttlingUsage, expected)
+	}
+}
+
+func TestDockerStatsToContainerStatsNoCPUQuota(t *testing.T) {
+	numCores = 4
+	for _, jsonStat := range []string{
+		// cgroup v1 reports zero periods
+		`{"cpu_stats":{"cpu_usage":{"percpu_usage":[1, 2, 3, 4], "total_usage":100}, "throttling_data":{"periods":0, "throttled_periods":0, "throttled_time":0}}}`,
+		// cgroup v2 leaves them out
+		`{"cpu_stats":{"cpu_usage":{"total_usage":100}, "system_cpu_usage":1000, "throttling_data":{}}}`,
+	} {
+		dockerStat := &docker.Stats{}
+		json.Unmarshal([]byte(jsonStat), dockerStat)
+		containerStats, err := dockerStatsToContainerStats(dockerStat)
+		if err != nil {
+			t.Fatalf("Error converting container stats: %v", err)
+		}
+		if containerStats.throttlingUsage != nil {
+			t.Errorf("Expected no throttlingUsage, got: %+v", *containerStats.throttlingUsage)
+		}
+	}
+}
+
+// cgroupV1Stats and cgroupV2Stats are the stats docker reports for the same
+// container usage when reading them from cgroup v1 and v2
+const (
+	cgroupV1Stats = `
+		{
+			"read":"2017-08-01T20:00:00.000000000Z",
+			"cpu_stats":{
+				"cpu_usage":{
+					"percpu_usage":[300000000, 100000000],
+					"total_usage":400000000,
+					"usage_in_usermode":300000000,
+					"usage_in_kernelmode":100000000
+				},
+				"system_cpu_usage":9000000000,
+				"throttling_data":{"periods":50, "throttled_periods":5, "throttled_time":25000000}
+			},
+			"memory_stats":{
+				"usage":52428800,
+				"max_usage":62914560,
+				"limit":268435456,
+				"stats":{"cache":10485760, "rss":41943040, "total_cache":10485760, "total_rss":41943040, "inactive_file":4194304}
+			},
+			"blkio_stats":{
+				"io_service_bytes_recursive":[
+					{"major":202, "minor":0, "op":"Read", "value":4096},
+					{"major":202, "minor":0, "op":"Write", "value":8192},
+					{"major":202, "minor":0, "op":"Sync", "value":12288},
+					{"major":202, "minor":0, "op":"Async", "value":0},
+					{"major":202, "minor":0, "op":"Total", "value":12288}
+				],
+				"io_serviced_recursive":[
+					{"major":202, "minor":0, "op":"Read", "value":1},
+					{"major":202, "minor":0, "op":"Write", "value":2},
+					{"major":202, "minor":0, "op":"Total", "value":3}
+				]
+			},
+			"networks":{
+				"eth0":{"rx_bytes":1000, "rx_packets":10, "tx_bytes":2000, "tx_packets":20}
+			}
+		}`
+	cgroupV2Stats = `
+		{
+			"read":"2017-08-01T20:00:00.000000000Z",
+			"cpu_stats":{
+				"cpu_usage":{
+					"total_usage":400000000,
+					"usage_in_usermode":300000000,
+					"usage_in_kernelmode":100000000
+				},
+				"system_cpu_usage":9000000000,
+				"online_cpus":2,
+				"throttling_data":{"periods":50, "throttled_periods":5, "throttled_time":25000000}
+			},
+			"memory_stats":{
+				"usage":52428800,
+				"limit":268435456,
+				"stats":{"anon":41943040, "file":10485760, "inactive_file":4194304, "active_file":6291456}
+			},
+			"blkio_stats":{
+				"io_service_bytes_recursive":[
+					{"major":202, "minor":0, "op":"read", "value":4096},
+					{"major":202, "minor":0, "op":"write", "value":8192}
+				],
+				"io_serviced_recursive":null
+			},
+			"networks":{
+				"eth0":{"rx_bytes":1000, "rx_packets":10, "tx_bytes":2000, "tx_packets":20}
+			}
+		}`
+)
+
+func TestDockerStatsToContainerStatsCgroupVersions(t *testing.T) {
+	numCores = 2
+	v1Stat := &docker.Stats{}
+	json.Unmarshal([]byte(cgroupV1Stats), v1Stat)
+	v2Stat := &docker.Stats{}
+	json.Unmarshal([]byte(cgroupV2Stats), v2Stat)
+	if version := cgroupVersion(v1Stat); version != cgroupV1 {
+		t.Errorf("Expected cgroup v1 stats, got v%d", version)
+	}
+	if version := cgroupVersion(v2Stat); version != cgroupV2 {
+		t.Errorf("Expected cgroup v2 stats, got v%d", version)
+	}
+
+	v1, err := dockerStatsToContainerStats(v1Stat)
+	if err != nil {
+		t.Fatalf("Error converting cgroup v1 stats: %v", err)
+	}
+	v2, err := dockerStatsToContainerStats(v2Stat)
+	if err != nil {
+		t.Fatalf("Error converting cgroup v2 stats: %v", err)
+	}
+
+	if v1.cpuUsage != 200000000 || v2.cpuUsage != v1.cpuUsage {
+		t.Errorf("Unexpected cpuUsage: v1 %d, v2 %d", v1.cpuUsage, v2.cpuUsage)
+	}
+	if v1.memoryUsage != 52428800 || v2.memoryUsage != v1.memoryUsage {
+		t.Errorf("Unexpected memoryUsage: v1 %d, v2 %d", v1.memoryUsage, v2.memoryUsage)
+	}
+	if !v1.timestamp.Equal(v2.timestamp) {
+		t.Errorf("Unexpected timestamps: v1 %s, v2 %s", v1.timestamp, v2.timestamp)
+	}
+	if *v1.throttlingUsage != *v2.throttlingUsage {
+		t.Errorf("Unexpected throttlingUsage: v1 %+v, v2 %+v", *v1.throttlingUsage, *v2.throttlingUsage)
+	}
+	if *v1.networkUsage["eth0"] != *v2.networkUsage["eth0"] {
+		t.Errorf("Unexpected networkUsage: v1 %+v, v2 %+v", *v1.networkUsage["eth0"], *v2.networkUsage["eth0"])
+	}
+
+	// Docker doesn't report block IO operations on cgroup v2
+	expected := StorageStats{ReadBytes: 4096, WriteBytes: 8192, ReadOps: 1, WriteOps: 2}
+	if *v1.storageUsage != expected {
+		t.Errorf("Unexpected cgroup v1 storageUsage: %+v, expected %+v", *v1.storageUsage, expected)
+	}
+	expected = StorageStats{ReadBytes: 4096, WriteBytes: 8192, OpsUnknown: true}
+	if *v2.storageUsage != expected {
+		t.Errorf("Unexpected cgroup v2 storageUsage: %+v, expected %+v", *v2.storageUsage, expected)
+	}
+}
+
+func TestDockerStatsToContainerStatsStoppedCgroupV2(t *testing.T) {
+	// Docker reports empty stats once the container stopped
+	jsonStat := `{"read":"0001-01-01T00:00:00Z", "cpu_stats":{"cpu_usage":{"total_usage":0}, "throttling_data":{}}, "memory_stats":{}}`
+	dockerStat := &docker.Stats{}
+	json.Unmarshal([]byte(jsonStat), dockerStat)
+	if _, err := dockerStatsToContainerStats(dockerStat); err == nil {
+		t.Error("Expected error converting empty container stats")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/stats"
@@ -34,19 +35,30 @@ import (
 // This is a very conservative estimate assuming max allowed string lengths for all fields.
 const tasksInMessage = 10
 
-// clientServer implements wsclient.ClientServer interface for metrics backend.
+// ClientServer is a client/server for the metrics backend that, on top of
+// publishing metrics periodically once serving, can publish them on demand.
+type ClientServer interface {
+	wsclient.ClientServer
+	// PublishMetrics publishes the current metrics of the stats engine
+	PublishMetrics() error
+}
+
+// clientServer implements ClientServer interface for metrics backend.
 type clientServer struct {
 	statsEngine            stats.Engine
 	publishTicker          *time.Ticker
 	endPublish             chan struct{}
 	publishMetricsInterval time.Duration
+	// publishLock keeps metrics published on demand and periodically from
+	// being written to the connection concurrently
+	publishLock sync.Mutex
 	wsclient.ClientServerImpl
 }
 
 // New returns a client/server to bidirectionally communicate with the backend.
 // The returned struct should have both 'Connect' and 'Serve' called upon it
 // before being used.
-func New(url string, region string, credentialProvider *credentials.Credentials, acceptInvalidCert bool, statsEngine stats.Engine, publishMetricsInterval time.Duration) wsclient.ClientServer {
+func New(url string, region string, credentialProvider *credentials.Credentials, acceptInvalidCert bool, statsEngine stats.Engine, publishMetricsInterval time.Duration) ClientServer {
 	cs := &clientServer{
 		statsEngine:            statsEngine,
 		publishTicker:          nil,
@@ -151,19 +163,34 @@ func (cs *clientServer) publishMetrics() {
 
 // publishMetricsOnce is invoked by the ticker to periodically publish metrics to backend.
 func (cs *clientServer) publishMetricsOnce() {
+	cs.PublishMetrics()
+}
+
+// PublishMetrics publishes the current metrics of the stats engine to the
+// backend, returning the first error met.
+func (cs *clientServer) PublishMetrics() error {
+	cs.publishLock.Lock()
+	defer cs.publishLock.Unlock()
+
 	// Get the list of objects to send to backend.
 	requests, err := cs.metricsToPublishMetricRequests()
 	if err != nil {
 		seelog.Warnf("Error getting instance metrics: %v", err)
+		return err
 	}
 
 	// Make the publish metrics request to the backend.
+	var publishErr error
 	for _, request := range requests {
 		err = cs.MakeRequest(request)
 		if err != nil {
 			seelog.Warnf("Error publishing metrics: %v. Request: %v", err, request)
+			if publishErr == nil {
+				publishErr = err
+			}
 		}
 	}
+	return publishErr
 }
 
 // metricsToPublishMetricRequests gets task metrics and converts them to a list of PublishMetricRequest
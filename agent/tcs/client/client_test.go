@@ -247,3 +247,28 @@ func TestDeregisterInstanceStream(t *testing.T) {
 		t.Error("Client should be closed after receiving deregister event")
 	}
 }
+
+func TestPublishMetricsOnDemand(t *testing.T) {
+	cs := New("localhost:443", "us-east-1", credentials.AnonymousCredentials, true, newNonIdleStatsEngine(tasksInMessage+1), testPublishMetricsInterval).(*clientServer)
+	ml := &messageLogger{make([][]byte, 0), make([][]byte, 0), false}
+	cs.Conn = ml
+
+	err := cs.PublishMetrics()
+	if err != nil {
+		t.Fatal("Error publishing metrics: ", err)
+	}
+	if len(ml.writes) != 2 {
+		t.Errorf("Expected %d requests to be written, got %d", 2, len(ml.writes))
+	}
+}
+
+func TestPublishMetricsOnDemandError(t *testing.T) {
+	cs, ml := testCS()
+	err := cs.(ClientServer).PublishMetrics()
+	if err == nil {
+		t.Error("Expected an error publishing metrics of an uninitialized stats engine")
+	}
+	if len(ml.writes) != 0 {
+		t.Errorf("Expected no requests to be written, got %d", len(ml.writes))
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tcshandler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/tcs/client"
+)
+
+// MetricsFlusher publishes the metrics of the connected telemetry session on
+// demand, so that the metrics gathered since the last periodic publish aren't
+// lost when the agent exits
+type MetricsFlusher struct {
+	client tcsclient.ClientServer
+	lock   sync.RWMutex
+}
+
+// NewMetricsFlusher returns a flusher to be passed to the telemetry session
+func NewMetricsFlusher() *MetricsFlusher {
+	return &MetricsFlusher{}
+}
+
+// Flush publishes the current metrics of the stats engine, waiting up to
+// timeout for them to be sent. It fails if no telemetry session is connected
+func (flusher *MetricsFlusher) Flush(timeout time.Duration) error {
+	flusher.lock.RLock()
+	client := flusher.client
+	flusher.lock.RUnlock()
+	if client == nil {
+		return errors.New("No telemetry session is connected to flush metrics to")
+	}
+
+	published := make(chan error, 1)
+	go func() {
+		published <- client.PublishMetrics()
+	}()
+
+	select {
+	case err := <-published:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("Timed out flushing metrics after %v", timeout)
+	}
+}
+
+// setClient sets the client of the connected session, or nil once the
+// session is over. A nil flusher ignores it
+func (flusher *MetricsFlusher) setClient(client tcsclient.ClientServer) {
+	if flusher == nil {
+		return
+	}
+	flusher.lock.Lock()
+	defer flusher.lock.Unlock()
+	flusher.client = client
+}
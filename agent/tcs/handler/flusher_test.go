@@ -0,0 +1,86 @@
+// Copyright 2014-2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tcshandler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/wsclient"
+	"github.com/stretchr/testify/assert"
+)
+
+// publishingClient is a telemetry client whose publishes are recorded, and
+// block until unblock is closed if it is set
+type publishingClient struct {
+	wsclient.ClientServer
+	published chan struct{}
+	unblock   chan struct{}
+	err       error
+}
+
+func (client *publishingClient) PublishMetrics() error {
+	client.published <- struct{}{}
+	if client.unblock != nil {
+		<-client.unblock
+	}
+	return client.err
+}
+
+func TestMetricsFlusherFlush(t *testing.T) {
+	client := &publishingClient{published: make(chan struct{}, 1)}
+	flusher := NewMetricsFlusher()
+	flusher.setClient(client)
+
+	err := flusher.Flush(time.Second)
+	assert.NoError(t, err)
+	select {
+	case <-client.published:
+	default:
+		t.Error("Expected metrics to be published")
+	}
+}
+
+func TestMetricsFlusherFlushError(t *testing.T) {
+	client := &publishingClient{published: make(chan struct{}, 1), err: errors.New("test error")}
+	flusher := NewMetricsFlusher()
+	flusher.setClient(client)
+
+	err := flusher.Flush(time.Second)
+	assert.Equal(t, client.err, err)
+}
+
+func TestMetricsFlusherFlushTimesOut(t *testing.T) {
+	client := &publishingClient{published: make(chan struct{}, 1), unblock: make(chan struct{})}
+	defer close(client.unblock)
+	flusher := NewMetricsFlusher()
+	flusher.setClient(client)
+
+	start := time.Now()
+	err := flusher.Flush(10 * time.Millisecond)
+	assert.Error(t, err, "Expected flush to time out")
+	assert.True(t, time.Since(start) < time.Second, "Expected flush to give up after its timeout")
+	<-client.published
+}
+
+func TestMetricsFlusherWithoutSession(t *testing.T) {
+	flusher := NewMetricsFlusher()
+	assert.Error(t, flusher.Flush(time.Second), "Expected an error without a connected session")
+
+	client := &publishingClient{published: make(chan struct{}, 1)}
+	flusher.setClient(client)
+	flusher.setClient(nil)
+	assert.Error(t, flusher.Flush(time.Second), "Expected an error once the session is over")
+}
@@ -35,6 +35,7 @@ type TelemetrySessionParams struct {
 	AcceptInvalidCert             bool
 	ECSClient                     api.ECSClient
 	TaskEngine                    engine.TaskEngine
+	MetricsFlusher                *MetricsFlusher
 	_time                         ttime.Time
 	_timeOnce                     sync.Once
 }
@@ -78,7 +78,15 @@ type ContainerMetric struct {
 
 	CpuStatsSet *CWStatsSet `locationName:"cpuStatsSet" type:"structure"`
 
+	Dimensions []*Dimension `locationName:"dimensions" type:"list"`
+
 	MemoryStatsSet *CWStatsSet `locationName:"memoryStatsSet" type:"structure"`
+
+	NetworkStatsSets []*NetworkStatsSet `locationName:"networkStatsSets" type:"list"`
+
+	StorageStatsSet *StorageStatsSet `locationName:"storageStatsSet" type:"structure"`
+
+	ThrottlingStatsSet *ThrottlingStatsSet `locationName:"throttlingStatsSet" type:"structure"`
 }
 
 // String returns the string representation
@@ -91,6 +99,24 @@ func (s ContainerMetric) GoString() string {
 	return s.String()
 }
 
+type Dimension struct {
+	_ struct{} `type:"structure"`
+
+	Name *string `locationName:"name" type:"string"`
+
+	Value *string `locationName:"value" type:"string"`
+}
+
+// String returns the string representation
+func (s Dimension) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s Dimension) GoString() string {
+	return s.String()
+}
+
 type HeartbeatMessage struct {
 	_ struct{} `type:"structure"`
 
@@ -161,6 +187,30 @@ func (s MetricsMetadata) GoString() string {
 	return s.String()
 }
 
+type NetworkStatsSet struct {
+	_ struct{} `type:"structure"`
+
+	InterfaceName *string `locationName:"interfaceName" type:"string"`
+
+	RxBytes *CWStatsSet `locationName:"rxBytes" type:"structure"`
+
+	RxPackets *CWStatsSet `locationName:"rxPackets" type:"structure"`
+
+	TxBytes *CWStatsSet `locationName:"txBytes" type:"structure"`
+
+	TxPackets *CWStatsSet `locationName:"txPackets" type:"structure"`
+}
+
+// String returns the string representation
+func (s NetworkStatsSet) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s NetworkStatsSet) GoString() string {
+	return s.String()
+}
+
 type PublishMetricsRequest struct {
 	_ struct{} `type:"structure"`
 
@@ -247,6 +297,28 @@ func (s StopTelemetrySessionMessage) GoString() string {
 	return s.String()
 }
 
+type StorageStatsSet struct {
+	_ struct{} `type:"structure"`
+
+	ReadOps *CWStatsSet `locationName:"readOps" type:"structure"`
+
+	ReadSizeBytes *CWStatsSet `locationName:"readSizeBytes" type:"structure"`
+
+	WriteOps *CWStatsSet `locationName:"writeOps" type:"structure"`
+
+	WriteSizeBytes *CWStatsSet `locationName:"writeSizeBytes" type:"structure"`
+}
+
+// String returns the string representation
+func (s StorageStatsSet) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s StorageStatsSet) GoString() string {
+	return s.String()
+}
+
 type TaskMetric struct {
 	_ struct{} `type:"structure"`
 
@@ -268,3 +340,23 @@ func (s TaskMetric) String() string {
 func (s TaskMetric) GoString() string {
 	return s.String()
 }
+
+type ThrottlingStatsSet struct {
+	_ struct{} `type:"structure"`
+
+	Periods *CWStatsSet `locationName:"periods" type:"structure"`
+
+	ThrottledPeriods *CWStatsSet `locationName:"throttledPeriods" type:"structure"`
+
+	ThrottledTime *CWStatsSet `locationName:"throttledTime" type:"structure"`
+}
+
+// String returns the string representation
+func (s ThrottlingStatsSet) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s ThrottlingStatsSet) GoString() string {
+	return s.String()
+}